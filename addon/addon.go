@@ -0,0 +1,394 @@
+package addon
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"langforge/bundle"
+	"langforge/diff"
+	"langforge/project"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed files
+var embeddedFS embed.FS
+
+func init() {
+	files, err := fs.Sub(embeddedFS, "files")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.AddOns, files)
+}
+
+// AddOn is a feature that can be applied to an existing project. It adds
+// files, Python requirements and .env variables and changes langforge.yaml.
+type AddOn struct {
+	Name        string
+	Description string
+	// Files are copied from the add-on's directory in files/ into the project.
+	// Existing files are left unchanged.
+	Files []string
+	// Requirements are appended to requirements.txt unless the project already
+	// requires the package.
+	Requirements []string
+	// Env holds variables that are appended to .env unless they are set. The
+	// value is computed when the add-on is applied.
+	Env []EnvVar
+	// Configure changes the project configuration.
+	Configure func(config *project.Config)
+}
+
+// EnvVar is a variable that an add-on adds to the .env file.
+type EnvVar struct {
+	Key   string
+	Value func(config *project.Config) (string, error)
+}
+
+func fixed(value string) func(*project.Config) (string, error) {
+	return func(*project.Config) (string, error) {
+		return value, nil
+	}
+}
+
+// randomKey generates an API key.
+func randomKey(*project.Config) (string, error) {
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "lf-" + hex.EncodeToString(key), nil
+}
+
+var addOns = []*AddOn{
+	{
+		Name:         "observability",
+		Description:  "Trace chain runs with LangSmith",
+		Requirements: []string{"langsmith"},
+		Env: []EnvVar{
+			{Key: "LANGCHAIN_TRACING_V2", Value: fixed("true")},
+			{Key: "LANGCHAIN_ENDPOINT", Value: fixed("https://api.smith.langchain.com")},
+			{Key: "LANGCHAIN_API_KEY", Value: fixed("")},
+			{Key: "LANGCHAIN_PROJECT", Value: func(config *project.Config) (string, error) {
+				return config.Name, nil
+			}},
+		},
+	},
+	{
+		Name:        "auth",
+		Description: "Require an API key for requests to the serve gateway",
+		Env: []EnvVar{
+			{Key: "LANGFORGE_API_KEY", Value: randomKey},
+		},
+		Configure: func(config *project.Config) {
+			if config.Auth == nil {
+				config.Auth = &project.AuthConfig{}
+			}
+			for _, key := range config.Auth.APIKeys {
+				if key == "${LANGFORGE_API_KEY}" {
+					return
+				}
+			}
+			config.Auth.APIKeys = append(config.Auth.APIKeys, "${LANGFORGE_API_KEY}")
+		},
+	},
+	{
+		Name:         "vectorstore-qdrant",
+		Description:  "Use a Qdrant vector store running in Docker",
+		Requirements: []string{"qdrant-client"},
+		Configure: func(config *project.Config) {
+			if config.VectorStore.Type != "qdrant" {
+				// the location of another store type does not apply to qdrant
+				config.VectorStore.Path = ""
+				config.VectorStore.URL = ""
+			}
+			config.VectorStore.Type = "qdrant"
+			config.VectorStore.Mode = "docker"
+			// the documents are ingested by the ingestion pipeline, unless
+			// the project has an ingest script
+			if config.VectorStore.Ingest == "" && config.VectorStore.Ingestion == nil {
+				config.VectorStore.Ingestion = &project.IngestionConfig{
+					Sources: []project.IngestionSource{{Name: "docs", Path: "docs"}},
+				}
+			}
+		},
+	},
+}
+
+// List returns all add-ons ordered by name.
+func List() []*AddOn {
+	list := append([]*AddOn{}, addOns...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Find returns the add-on with the given name.
+func Find(name string) (*AddOn, error) {
+	for _, addOn := range addOns {
+		if addOn.Name == name {
+			return addOn, nil
+		}
+	}
+	names := []string{}
+	for _, addOn := range List() {
+		names = append(names, addOn.Name)
+	}
+	return nil, fmt.Errorf("unknown add-on %q, available add-ons: %s", name, strings.Join(names, ", "))
+}
+
+// Change is a file that applying an add-on creates or modifies.
+type Change struct {
+	Path string
+	Old  string
+	New  string
+	// Created is set if the file does not exist yet.
+	Created bool
+	// Secret is set for files whose unchanged lines must not be shown.
+	Secret bool
+}
+
+// Patch returns the change as a patch. The unchanged lines of secret files
+// are omitted from its hunks.
+func (c *Change) Patch() *diff.Patch {
+	oldName := "a/" + c.Path
+	if c.Created {
+		oldName = "/dev/null"
+	}
+	context := 3
+	if c.Secret {
+		context = 0
+	}
+	return diff.New(oldName, "b/"+c.Path, c.Old, c.New, context)
+}
+
+// Diff returns the change as a unified diff. The unchanged lines of secret
+// files are omitted.
+func (c *Change) Diff() string {
+	return c.Patch().String()
+}
+
+// Plan computes the changes that applying the add-on makes to the project in
+// dir. Nothing is written. Files that exist with different contents are kept
+// and returned as skipped.
+func Plan(dir string, addOn *AddOn) (changes []*Change, skipped []string, err error) {
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := bundle.FS(bundle.AddOns)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range addOn.Files {
+		data, err := fs.ReadFile(files, addOn.Name+"/"+name)
+		if err != nil {
+			return nil, nil, err
+		}
+		existing, err := readFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		if existing == nil {
+			changes = append(changes, &Change{Path: name, New: string(data), Created: true})
+		} else if *existing != string(data) {
+			skipped = append(skipped, name)
+		}
+	}
+
+	if len(addOn.Requirements) > 0 {
+		change, err := planRequirements(dir, addOn.Requirements)
+		if err != nil {
+			return nil, nil, err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	if len(addOn.Env) > 0 {
+		change, err := planEnv(dir, config, addOn.Env)
+		if err != nil {
+			return nil, nil, err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	if addOn.Configure != nil {
+		change, err := planConfig(dir, config, addOn.Configure)
+		if err != nil {
+			return nil, nil, err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, skipped, nil
+}
+
+// Apply writes the planned changes to the project in dir.
+func Apply(dir string, changes []*Change) error {
+	for _, change := range changes {
+		path := filepath.Join(dir, change.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if change.Secret {
+			mode = 0600
+		}
+		if err := os.WriteFile(path, []byte(change.New), mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile returns the contents of a file or nil if it does not exist.
+func readFile(path string) (*string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	text := string(data)
+	return &text, nil
+}
+
+// appendLines appends lines to text, adding a missing final line break.
+func appendLines(text string, lines []string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + strings.Join(lines, "\n") + "\n"
+}
+
+var (
+	requirementName = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)`)
+	nameSeparators  = regexp.MustCompile(`[-_.]+`)
+)
+
+// normalizeName normalizes a Python package name as specified by PEP 503.
+func normalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, "-"))
+}
+
+func planRequirements(dir string, requirements []string) (*Change, error) {
+	existing, err := readFile(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		return nil, err
+	}
+	change := &Change{Path: "requirements.txt", Created: existing == nil}
+	if existing != nil {
+		change.Old = *existing
+	}
+
+	required := map[string]bool{}
+	for _, line := range strings.Split(change.Old, "\n") {
+		if m := requirementName.FindStringSubmatch(line); m != nil {
+			required[normalizeName(m[1])] = true
+		}
+	}
+
+	added := []string{}
+	for _, requirement := range requirements {
+		m := requirementName.FindStringSubmatch(requirement)
+		if m != nil && !required[normalizeName(m[1])] {
+			added = append(added, requirement)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	change.New = appendLines(change.Old, added)
+	return change, nil
+}
+
+var envKey = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=`)
+
+func planEnv(dir string, config *project.Config, vars []EnvVar) (*Change, error) {
+	existing, err := readFile(filepath.Join(dir, ".env"))
+	if err != nil {
+		return nil, err
+	}
+	change := &Change{Path: ".env", Created: existing == nil, Secret: true}
+	if existing != nil {
+		change.Old = *existing
+	}
+
+	set := map[string]bool{}
+	for _, line := range strings.Split(change.Old, "\n") {
+		if m := envKey.FindStringSubmatch(line); m != nil {
+			set[m[1]] = true
+		}
+	}
+
+	added := []string{}
+	for _, v := range vars {
+		if set[v.Key] {
+			continue
+		}
+		value, err := v.Value(config)
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, fmt.Sprintf("%s=%q", v.Key, value))
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	change.New = appendLines(change.Old, added)
+	return change, nil
+}
+
+func planConfig(dir string, config *project.Config, configure func(*project.Config)) (*Change, error) {
+	existing, err := readFile(project.ConfigPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	change := &Change{Path: project.ConfigFileName, Created: existing == nil}
+	if existing != nil {
+		change.Old = *existing
+	}
+
+	// compare the marshaled configurations, so that formatting differences of
+	// the existing file do not count as changes
+	before, err := project.MarshalConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	configure(config)
+	after, err := project.MarshalConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if string(before) == string(after) && existing != nil {
+		return nil, nil
+	}
+
+	change.New = string(after)
+	if existing != nil {
+		// keep the comments of the file
+		updated, err := project.UpdateConfig([]byte(*existing), config)
+		if err != nil {
+			return nil, err
+		}
+		change.New = string(updated)
+	}
+	return change, nil
+}
@@ -0,0 +1,311 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"langforge/project"
+	"langforge/sqlite"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// timeFormat stores times in UTC with a fixed width, so that they sort
+// chronologically as text.
+const timeFormat = "2006-01-02T15:04:05.000000Z"
+
+// FileName is the name of the analytics database in the project's state directory.
+const FileName = "analytics.db"
+
+const schemaSQL = `CREATE TABLE IF NOT EXISTS requests (
+	time TEXT NOT NULL,
+	chain TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	latency_ms INTEGER NOT NULL,
+	prompt_tokens INTEGER NOT NULL DEFAULT 0,
+	completion_tokens INTEGER NOT NULL DEFAULT 0,
+	cost REAL NOT NULL DEFAULT 0,
+	variant TEXT NOT NULL DEFAULT '',
+	key TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS requests_time ON requests (time);`
+
+// Event holds the metadata of a chain request handled by the gateway. Token
+// counts and cost are reported by the worker and zero if unknown. Variant is
+// "primary" or "canary" for chains with a canary and empty otherwise. Key is
+// the name of the API key of the request, e.g. "team-a" or "key 2" for the
+// second unnamed key, and empty if the gateway requires no key.
+type Event struct {
+	Time             time.Time `json:"time"`
+	Chain            string    `json:"chain"`
+	Status           int       `json:"status"`
+	LatencyMs        int64     `json:"latency_ms"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	Variant          string    `json:"variant"`
+	Key              string    `json:"key"`
+}
+
+// DB is the analytics database of a project.
+type DB struct {
+	db *sqlite.DB
+}
+
+// Path returns the path of the analytics database of the project in dir.
+func Path(dir string) string {
+	return filepath.Join(project.StateDir(dir), FileName)
+}
+
+// Open opens the analytics database of the project in dir and creates it if
+// it does not exist.
+func Open(dir string) (*DB, error) {
+	db, err := sqlite.Open(Path(dir))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(schemaSQL); err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+// migrate adds the columns of newer versions to databases created before.
+func migrate(db *sqlite.DB) error {
+	columns := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := db.Query("PRAGMA table_info(requests);", &columns); err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, column := range columns {
+		existing[column.Name] = true
+	}
+	sql := ""
+	for _, column := range []string{"variant", "key"} {
+		if !existing[column] {
+			sql += fmt.Sprintf("ALTER TABLE requests ADD COLUMN %s TEXT NOT NULL DEFAULT '';\n", column)
+		}
+	}
+	if sql == "" {
+		return nil
+	}
+	return db.Exec(sql)
+}
+
+// Insert stores events in a single transaction. If the transaction fails,
+// the events are stored one by one, so that an event that cannot be stored
+// does not lose the others, and the error tells how many were lost.
+func (d *DB) Insert(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	sql := ""
+	for _, e := range events {
+		sql += insertSQL(e)
+	}
+	err := d.db.Exec(sql)
+	if err == nil || len(events) == 1 {
+		return err
+	}
+	lost := 0
+	for _, e := range events {
+		if err := d.db.Exec(insertSQL(e)); err != nil {
+			lost++
+		}
+	}
+	if lost > 0 {
+		return fmt.Errorf("%d of %d events were not stored: %v", lost, len(events), err)
+	}
+	return nil
+}
+
+func insertSQL(e Event) string {
+	return fmt.Sprintf("INSERT INTO requests (time, chain, status, latency_ms, prompt_tokens, completion_tokens, cost, variant, key) VALUES (%s, %s, %d, %d, %d, %d, %s, %s, %s);\n",
+		sqlite.Quote(e.Time.UTC().Format(timeFormat)), sqlite.Quote(e.Chain), e.Status, e.LatencyMs,
+		e.PromptTokens, e.CompletionTokens, strconv.FormatFloat(e.Cost, 'f', -1, 64), sqlite.Quote(e.Variant), sqlite.Quote(e.Key))
+}
+
+// sinceClause restricts a query to events after since. A zero time selects all events.
+func sinceClause(since time.Time) string {
+	if since.IsZero() {
+		return "1 = 1"
+	}
+	return "time >= " + sqlite.Quote(since.UTC().Format(timeFormat))
+}
+
+// ChainStats summarizes the requests of a chain, separately for the versions
+// of a chain with a canary.
+type ChainStats struct {
+	Chain        string  `json:"chain"`
+	Variant      string  `json:"variant"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P95LatencyMs int64   `json:"p95_latency_ms"`
+	Tokens       int     `json:"tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// TopChains returns the statistics of all chains requested after since, most
+// requested first.
+func (d *DB) TopChains(since time.Time) ([]ChainStats, error) {
+	stats := []ChainStats{}
+	err := d.db.Query(fmt.Sprintf(`SELECT chain, variant, COUNT(*) AS requests,
+	SUM(CASE WHEN status >= 400 THEN 1 ELSE 0 END) AS errors,
+	AVG(latency_ms) AS avg_latency_ms,
+	SUM(prompt_tokens + completion_tokens) AS tokens,
+	SUM(cost) AS cost
+FROM requests WHERE %s GROUP BY chain, variant ORDER BY requests DESC, chain, variant;`, sinceClause(since)), &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite has no percentile function, so compute the p95 latency here
+	latencies := []struct {
+		Chain     string `json:"chain"`
+		Variant   string `json:"variant"`
+		LatencyMs int64  `json:"latency_ms"`
+	}{}
+	err = d.db.Query(fmt.Sprintf("SELECT chain, variant, latency_ms FROM requests WHERE %s;", sinceClause(since)), &latencies)
+	if err != nil {
+		return nil, err
+	}
+	byChain := map[[2]string][]int64{}
+	for _, l := range latencies {
+		key := [2]string{l.Chain, l.Variant}
+		byChain[key] = append(byChain[key], l.LatencyMs)
+	}
+	for i := range stats {
+		stats[i].P95LatencyMs = percentile(byChain[[2]string{stats[i].Chain, stats[i].Variant}], 0.95)
+	}
+
+	return stats, nil
+}
+
+// percentile returns the value below which the fraction p of values falls.
+func percentile(values []int64, p float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	index := int(float64(len(values))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(values) {
+		index = len(values) - 1
+	}
+	return values[index]
+}
+
+// KeyStats summarizes the requests of an API key by chain.
+type KeyStats struct {
+	Key          string  `json:"key"`
+	Chain        string  `json:"chain"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	Tokens       int     `json:"tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// KeyUsage returns the statistics of the chains requested with each API key
+// after since, ordered by key and the most requested chains first. Requests
+// without a key are left out.
+func (d *DB) KeyUsage(since time.Time) ([]KeyStats, error) {
+	stats := []KeyStats{}
+	err := d.db.Query(fmt.Sprintf(`SELECT key, chain, COUNT(*) AS requests,
+	SUM(CASE WHEN status >= 400 THEN 1 ELSE 0 END) AS errors,
+	AVG(latency_ms) AS avg_latency_ms,
+	SUM(prompt_tokens + completion_tokens) AS tokens,
+	SUM(cost) AS cost
+FROM requests WHERE %s AND key != '' GROUP BY key, chain ORDER BY key, requests DESC, chain;`, sinceClause(since)), &stats)
+	return stats, err
+}
+
+// KeyCosts returns the cost of the requests of each API key after since.
+func (d *DB) KeyCosts(since time.Time) (map[string]float64, error) {
+	rows := []struct {
+		Key  string  `json:"key"`
+		Cost float64 `json:"cost"`
+	}{}
+	err := d.db.Query(fmt.Sprintf("SELECT key, SUM(cost) AS cost FROM requests WHERE %s AND key != '' GROUP BY key;", sinceClause(since)), &rows)
+	if err != nil {
+		return nil, err
+	}
+	costs := map[string]float64{}
+	for _, row := range rows {
+		costs[row.Key] = row.Cost
+	}
+	return costs, nil
+}
+
+// DayCost holds the token usage and cost of all requests on a day.
+type DayCost struct {
+	Day      string  `json:"day"`
+	Requests int     `json:"requests"`
+	Tokens   int     `json:"tokens"`
+	Cost     float64 `json:"cost"`
+}
+
+// CostByDay returns the token usage and cost per day (UTC) after since, oldest first.
+func (d *DB) CostByDay(since time.Time) ([]DayCost, error) {
+	days := []DayCost{}
+	err := d.db.Query(fmt.Sprintf(`SELECT substr(time, 1, 10) AS day, COUNT(*) AS requests,
+	SUM(prompt_tokens + completion_tokens) AS tokens, SUM(cost) AS cost
+FROM requests WHERE %s GROUP BY day ORDER BY day;`, sinceClause(since)), &days)
+	return days, err
+}
+
+// Cost returns the cost of all requests after since.
+func (d *DB) Cost(since time.Time) (float64, error) {
+	rows := []struct {
+		Cost float64 `json:"cost"`
+	}{}
+	err := d.db.Query(fmt.Sprintf("SELECT COALESCE(SUM(cost), 0) AS cost FROM requests WHERE %s;", sinceClause(since)), &rows)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return rows[0].Cost, nil
+}
+
+// Events returns all events after since, oldest first.
+func (d *DB) Events(since time.Time) ([]Event, error) {
+	events := []Event{}
+	err := d.db.Query(fmt.Sprintf("SELECT * FROM requests WHERE %s ORDER BY time;", sinceClause(since)), &events)
+	return events, err
+}
+
+// ExportCSV writes all events after since as CSV with a header row.
+func (d *DB) ExportCSV(w io.Writer, since time.Time) error {
+	events, err := d.Events(since)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "chain", "status", "latency_ms", "prompt_tokens", "completion_tokens", "cost", "variant", "key"})
+	for _, e := range events {
+		writer.Write([]string{
+			e.Time.Format(time.RFC3339Nano),
+			e.Chain,
+			strconv.Itoa(e.Status),
+			strconv.FormatInt(e.LatencyMs, 10),
+			strconv.Itoa(e.PromptTokens),
+			strconv.Itoa(e.CompletionTokens),
+			strconv.FormatFloat(e.Cost, 'f', -1, 64),
+			e.Variant,
+			e.Key,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// flushInterval is how often buffered events are written to the database.
+const flushInterval = 2 * time.Second
+
+// maxBuffered is the number of events that are buffered before the oldest are
+// dropped because the database cannot keep up.
+const maxBuffered = 10000
+
+// Writer buffers events and writes them to the database in batches, so that
+// recording a request never delays its response.
+type Writer struct {
+	db *DB
+
+	mu      sync.Mutex
+	pending []Event
+	dropped int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWriter starts a writer that flushes events to db in the background.
+func NewWriter(db *DB) *Writer {
+	w := &Writer{
+		db:   db,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Record queues an event for writing.
+func (w *Writer) Record(event Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) >= maxBuffered {
+		w.pending = w.pending[1:]
+		w.dropped++
+	}
+	w.pending = append(w.pending, event)
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.stop:
+			w.flush()
+			return
+		}
+	}
+}
+
+func (w *Writer) flush() {
+	w.mu.Lock()
+	events, dropped := w.pending, w.dropped
+	w.pending, w.dropped = nil, 0
+	w.mu.Unlock()
+
+	if dropped > 0 {
+		fmt.Printf("Analytics dropped %d requests because the database could not keep up.\n", dropped)
+	}
+	if err := w.db.Insert(events); err != nil {
+		// analytics must never break serving requests
+		fmt.Println("Error writing analytics:", err)
+	}
+}
+
+// Close writes the remaining events and stops the writer.
+func (w *Writer) Close() error {
+	close(w.stop)
+	<-w.done
+	return nil
+}
@@ -0,0 +1,267 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/client"
+	"langforge/provider"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency is the number of records that are sent to the chain at
+// the same time by default.
+const DefaultConcurrency = 4
+
+// Record is a record of the input of a batch. Index counts the records from 1.
+type Record struct {
+	Index  int
+	Fields map[string]any
+}
+
+// Result is the line of the output of a batch for a record.
+type Result struct {
+	Index   int            `json:"index"`
+	Inputs  map[string]any `json:"inputs"`
+	Outputs map[string]any `json:"outputs,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	// Code identifies provider errors, see provider.Code.
+	Code provider.Code `json:"code,omitempty"`
+}
+
+// Options configures a batch run.
+type Options struct {
+	Chain string
+	// Concurrency is the number of records that are sent to the chain at the
+	// same time.
+	Concurrency int
+	// Done holds the indexes of the records that an earlier run processed,
+	// which are skipped.
+	Done map[int]bool
+	// OnResult is called after the result of a record has been written.
+	OnResult func(result Result)
+}
+
+// Summary counts the records of a batch run.
+type Summary struct {
+	Succeeded int
+	Failed    int
+	Skipped   int
+}
+
+// ReadRecords calls fn with the records of a JSONL or CSV file in order,
+// without loading the file into memory. The format is determined by the file
+// extension. Values of JSONL records keep their JSON types; those of CSV
+// records are strings.
+func ReadRecords(path string, fn func(record Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return readJSONL(file, fn)
+	case ".csv":
+		return readCSV(file, fn)
+	default:
+		return fmt.Errorf("unsupported input format %q, expected .jsonl or .csv", filepath.Ext(path))
+	}
+}
+
+func readJSONL(r io.Reader, fn func(record Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line, index := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := make(map[string]any)
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return fmt.Errorf("invalid JSON on line %d: %v", line, err)
+		}
+		index++
+		if err := fn(Record{Index: index, Fields: fields}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readCSV(r io.Reader, fn func(record Record) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for index := 1; ; index++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fields := make(map[string]any)
+		for i, column := range header {
+			if i < len(values) {
+				fields[column] = values[i]
+			}
+		}
+		if err := fn(Record{Index: index, Fields: fields}); err != nil {
+			return err
+		}
+	}
+}
+
+// CountRecords returns the number of records of a JSONL or CSV file.
+func CountRecords(path string) (int, error) {
+	count := 0
+	err := ReadRecords(path, func(record Record) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Resume prepares the output of an interrupted run for resuming it. It
+// returns the indexes of the records that succeeded and removes the results
+// of failed records from the output, so that they are tried again. A missing
+// output is empty.
+func Resume(outputPath string) (map[int]bool, error) {
+	done := map[int]bool{}
+	data, err := os.ReadFile(outputPath)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kept := []byte{}
+	removed := false
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var result Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil || result.Index < 1 {
+			// the last line of an interrupted run may be incomplete
+			if i == strings.Count(string(data), "\n") {
+				removed = true
+				continue
+			}
+			return nil, fmt.Errorf("%s is not the output of a batch run, line %d is invalid", outputPath, i+1)
+		}
+		if result.Error != "" || done[result.Index] {
+			removed = true
+			continue
+		}
+		done[result.Index] = true
+		kept = append(kept, line...)
+		kept = append(kept, '\n')
+	}
+	if !removed {
+		return done, nil
+	}
+
+	temp := outputPath + ".tmp"
+	if err := os.WriteFile(temp, kept, 0644); err != nil {
+		return nil, err
+	}
+	return done, os.Rename(temp, outputPath)
+}
+
+// Run sends the records of the input file to the chain through c and appends
+// a result for each of them to out, in the order in which they finish. The
+// results of records that are canceled with ctx are not written, so that a
+// later run processes them.
+func Run(ctx context.Context, c *client.Client, inputPath string, out io.Writer, options Options) (*Summary, error) {
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	summary := &Summary{}
+	var mu sync.Mutex
+	var writeErr error
+	write := func(result Result) {
+		data, err := json.Marshal(result)
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			_, err = out.Write(append(data, '\n'))
+		}
+		if err != nil {
+			if writeErr == nil {
+				writeErr = err
+			}
+			return
+		}
+		if result.Error != "" {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		if options.OnResult != nil {
+			options.OnResult(result)
+		}
+	}
+
+	records := make(chan Record)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range records {
+				outputs, err := c.Invoke(ctx, options.Chain, record.Fields)
+				if ctx.Err() != nil {
+					continue
+				}
+				result := Result{Index: record.Index, Inputs: record.Fields, Outputs: outputs}
+				if err != nil {
+					result.Error = err.Error()
+					result.Code = provider.CodeOf(err)
+				}
+				write(result)
+			}
+		}()
+	}
+
+	err := ReadRecords(inputPath, func(record Record) error {
+		if options.Done[record.Index] {
+			summary.Skipped++
+			return nil
+		}
+		select {
+		case records <- record:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(records)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	if writeErr != nil {
+		err = writeErr
+	}
+	return summary, err
+}
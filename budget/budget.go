@@ -0,0 +1,216 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"langforge/analytics"
+	"langforge/notify"
+	"langforge/project"
+	"langforge/state"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultThresholds are the percentages of a limit that are notified if the
+// budget declares none.
+var DefaultThresholds = []float64{80, 100}
+
+// checkInterval is how often the watcher compares the cost to the budget.
+const checkInterval = time.Minute
+
+// collection holds the thresholds that were notified, so that a restart does
+// not notify them again.
+const collection = "budget"
+
+// Usage is the cost of the LLM requests in a period of the budget.
+type Usage struct {
+	// Period is "daily" or "monthly".
+	Period string
+	// Label names the day or month, e.g. "2026-10-16" or "2026-10".
+	Label string
+	Spent float64
+	Limit float64
+}
+
+// Percent returns the cost as a percentage of the limit.
+func (u Usage) Percent() float64 {
+	return u.Spent / u.Limit * 100
+}
+
+// Status returns the cost of the current UTC day and month for the limits of
+// the budget.
+func Status(db *analytics.DB, config project.BudgetConfig, now time.Time) ([]Usage, error) {
+	now = now.UTC()
+	periods := []struct {
+		name  string
+		limit float64
+		start time.Time
+		label string
+	}{
+		{"daily", config.Daily, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), now.Format("2006-01-02")},
+		{"monthly", config.Monthly, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), now.Format("2006-01")},
+	}
+
+	usages := []Usage{}
+	for _, period := range periods {
+		if period.limit <= 0 {
+			continue
+		}
+		spent, err := db.Cost(period.start)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, Usage{Period: period.name, Label: period.label, Spent: spent, Limit: period.limit})
+	}
+	return usages, nil
+}
+
+// Enabled reports whether the budget declares a limit.
+func Enabled(config project.BudgetConfig) bool {
+	return config.Daily > 0 || config.Monthly > 0
+}
+
+func thresholds(config project.BudgetConfig) []float64 {
+	if len(config.Thresholds) == 0 {
+		return DefaultThresholds
+	}
+	sorted := append([]float64{}, config.Thresholds...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// Watcher notifies the notification channels of a project when the cost of
+// its LLM requests reaches a threshold of its budget. Every threshold is
+// notified once per day or month.
+type Watcher struct {
+	dir    string
+	db     *analytics.DB
+	mu     sync.Mutex
+	config *project.Config
+	stop   chan struct{}
+	// Output receives the messages of the watcher.
+	Output io.Writer
+}
+
+// NewWatcher returns a watcher for the budget of the project in dir, whose
+// cost is read from db.
+func NewWatcher(dir string, db *analytics.DB, config *project.Config) *Watcher {
+	return &Watcher{
+		dir:    dir,
+		db:     db,
+		config: config,
+		stop:   make(chan struct{}),
+		Output: os.Stdout,
+	}
+}
+
+// Update replaces the budget and the notification channels with those of
+// config.
+func (w *Watcher) Update(config *project.Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.config = config
+}
+
+// Start checks the budget in the background until Stop is called.
+func (w *Watcher) Start() {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			if err := w.check(time.Now()); err != nil {
+				fmt.Fprintln(w.Output, "Error checking the budget:", err)
+			}
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops checking the budget.
+func (w *Watcher) Stop() {
+	close(w.stop)
+}
+
+func (w *Watcher) check(now time.Time) error {
+	w.mu.Lock()
+	config := w.config
+	w.mu.Unlock()
+	if !Enabled(config.Budget) {
+		return nil
+	}
+
+	usages, err := Status(w.db, config.Budget, now)
+	if err != nil {
+		return err
+	}
+	store, err := state.Open(w.dir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	for _, usage := range usages {
+		// when the cost jumps over several thresholds, only the highest is
+		// notified
+		notified := 0.0
+		for _, threshold := range thresholds(config.Budget) {
+			if usage.Percent() < threshold {
+				break
+			}
+			key := fmt.Sprintf("%s-%s-%g", usage.Period, usage.Label, threshold)
+			var crossed time.Time
+			if err := store.Get(collection, key, &crossed); err == nil {
+				continue
+			} else if err != state.ErrNotFound {
+				return err
+			}
+			if err := store.Put(collection, key, now); err != nil {
+				return err
+			}
+			notified = threshold
+		}
+		if notified > 0 {
+			w.notify(config, usage, notified)
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) notify(config *project.Config, usage Usage, threshold float64) {
+	name := "Daily"
+	if usage.Period == "monthly" {
+		name = "Monthly"
+	}
+	title := fmt.Sprintf("%s LLM budget %g%% used", name, threshold)
+	if threshold >= 100 {
+		title = fmt.Sprintf("%s LLM budget exceeded", name)
+	}
+	message := fmt.Sprintf("$%.2f of $%.2f spent on %s (UTC).", usage.Spent, usage.Limit, usage.Label)
+	if usage.Period == "monthly" {
+		message = fmt.Sprintf("$%.2f of $%.2f spent in %s (UTC).", usage.Spent, usage.Limit, usage.Label)
+	}
+	fmt.Fprintf(w.Output, "%s: %s\n", title, message)
+
+	event := &notify.Event{
+		Kind:    notify.BudgetThreshold,
+		Project: config.Name,
+		Title:   title,
+		Message: message,
+		Fields: map[string]string{
+			"period":    usage.Period,
+			"spent":     fmt.Sprintf("$%.2f", usage.Spent),
+			"limit":     fmt.Sprintf("$%.2f", usage.Limit),
+			"threshold": fmt.Sprintf("%g%%", threshold),
+		},
+	}
+	if err := notify.Send(context.Background(), config.Notifications, event); err != nil {
+		fmt.Fprintln(w.Output, err)
+	}
+}
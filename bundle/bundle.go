@@ -0,0 +1,332 @@
+package bundle
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"langforge/userconfig"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sections of a bundle. Each section replaces the built-in files of a part
+// of langforge.
+const (
+	// Integrations is the index of the integrations that 'langforge
+	// integrations' offers, with their packages.
+	Integrations = "integrations"
+	// Demo is the project of 'langforge create --demo' and of the setup.
+	Demo = "templates/demo"
+	// AddOns are the files that add-ons add to projects.
+	AddOns = "templates/addons"
+	// Projects are the project templates of 'langforge new'.
+	Projects = "templates/projects"
+	// Clients are the templates of 'langforge client'.
+	Clients = "templates/clients"
+	// Packages are the templates of the Python packages of 'langforge package'.
+	Packages = "templates/packages"
+	// Docker are the templates of the Docker files of 'langforge docker'.
+	Docker = "templates/docker"
+)
+
+// Names of the files of a bundle that are not part of a section.
+const (
+	manifestName  = "manifest.json"
+	signatureName = "manifest.sig"
+)
+
+// KeyFileName is the name of the file in the global directory that holds the
+// key that bundles are signed with.
+const KeyFileName = "bundle.key"
+
+// Manifest describes the contents of a bundle. Its signature covers the
+// checksums of all files, so no file of a bundle can be changed unnoticed.
+type Manifest struct {
+	Created  time.Time `json:"created"`
+	Sections []string  `json:"sections"`
+	// Files maps the paths of the files to their SHA-256 checksums.
+	Files map[string]string `json:"files"`
+}
+
+// Bundle is a verified offline bundle.
+type Bundle struct {
+	Path     string
+	Manifest Manifest
+	files    *zip.ReadCloser
+}
+
+var builtin = map[string]fs.FS{}
+
+// Register registers the built-in files of a section, which the packages of
+// langforge embed, in an init function.
+func Register(section string, files fs.FS) {
+	if _, ok := builtin[section]; ok {
+		panic(fmt.Sprintf("bundle: section %s is already registered", section))
+	}
+	builtin[section] = files
+}
+
+// FS returns the files of a section: those of the bundle of the user's
+// configuration if it has the section, and the built-in ones otherwise.
+func FS(section string) (fs.FS, error) {
+	b, err := Configured()
+	if err != nil {
+		return nil, err
+	}
+	if b != nil && b.Has(section) {
+		return fs.Sub(b.files, section)
+	}
+	files, ok := builtin[section]
+	if !ok {
+		return nil, fmt.Errorf("unknown bundle section %s", section)
+	}
+	return files, nil
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *Bundle
+	loadErr  error
+)
+
+// Configured returns the bundle of the user's configuration, or nil if none
+// is configured. It is opened and verified once.
+func Configured() (*Bundle, error) {
+	loadOnce.Do(func() {
+		config, err := userconfig.Load()
+		if err != nil {
+			loadErr = err
+			return
+		}
+		if config.Bundle.Path == "" {
+			return
+		}
+		loaded, loadErr = Open(config.Bundle.Path, config.Bundle.PublicKeys)
+		if loadErr != nil {
+			loadErr = fmt.Errorf("the bundle configured in the langforge configuration cannot be used: %w", loadErr)
+		}
+	})
+	return loaded, loadErr
+}
+
+// Has reports whether the bundle replaces the files of a section.
+func (b *Bundle) Has(section string) bool {
+	for _, s := range b.Manifest.Sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the file of the bundle.
+func (b *Bundle) Close() error {
+	return b.files.Close()
+}
+
+// Open opens the bundle at path and verifies that it was signed with one of
+// the trusted public keys and that its files match the signed manifest.
+func Open(path string, publicKeys []string) (*Bundle, error) {
+	if len(publicKeys) == 0 {
+		return nil, errors.New("no public key is configured to verify bundles with")
+	}
+	files, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Path: path, files: files}
+	if err := b.verify(publicKeys); err != nil {
+		files.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
+
+func (b *Bundle) verify(publicKeys []string) error {
+	manifest, err := fs.ReadFile(b.files, manifestName)
+	if err != nil {
+		return errors.New("the bundle has no manifest")
+	}
+	signature, err := fs.ReadFile(b.files, signatureName)
+	if err != nil {
+		return errors.New("the bundle is not signed")
+	}
+	signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return errors.New("the signature of the bundle is invalid")
+	}
+
+	trusted := false
+	for _, encoded := range publicKeys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key %q", encoded)
+		}
+		if ed25519.Verify(key, manifest, signature) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return errors.New("the bundle is not signed with a trusted key")
+	}
+
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return fmt.Errorf("invalid manifest: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, file := range b.files.File {
+		if file.FileInfo().IsDir() || file.Name == manifestName || file.Name == signatureName {
+			continue
+		}
+		checksum, ok := b.Manifest.Files[file.Name]
+		if !ok {
+			return fmt.Errorf("%s is not part of the signed manifest", file.Name)
+		}
+		actual, err := zipChecksum(file)
+		if err != nil {
+			return err
+		}
+		if actual != checksum {
+			return fmt.Errorf("%s was changed after the bundle was signed", file.Name)
+		}
+		seen[file.Name] = true
+	}
+	for name := range b.Manifest.Files {
+		if !seen[name] {
+			return fmt.Errorf("%s of the manifest is missing", name)
+		}
+	}
+	return nil
+}
+
+func zipChecksum(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Export writes the files of all sections into a bundle at target, signed
+// with the private key. The sections that exist as directories in dir, e.g.
+// the integrations directory of an extracted bundle, replace the built-in
+// files of the section; dir may be empty.
+func Export(target string, privateKey ed25519.PrivateKey, dir string) (*Manifest, error) {
+	sections := []string{}
+	sources := map[string]fs.FS{}
+	for section, files := range builtin {
+		sections = append(sections, section)
+		sources[section] = files
+		if dir == "" {
+			continue
+		}
+		sectionDir := filepath.Join(dir, filepath.FromSlash(section))
+		if info, err := os.Stat(sectionDir); err == nil && info.IsDir() {
+			sources[section] = os.DirFS(sectionDir)
+		}
+	}
+	sort.Strings(sections)
+
+	out, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	writer := zip.NewWriter(out)
+
+	manifest := &Manifest{Created: time.Now().UTC(), Sections: sections, Files: map[string]string{}}
+	for _, section := range sections {
+		err := fs.WalkDir(sources[section], ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(sources[section], name)
+			if err != nil {
+				return err
+			}
+			zipName := path.Join(section, name)
+			w, err := writer.Create(zipName)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			checksum := sha256.Sum256(data)
+			manifest.Files[zipName] = hex.EncodeToString(checksum[:])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	for name, contents := range map[string][]byte{
+		manifestName:  data,
+		signatureName: []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data)) + "\n"),
+	} {
+		w, err := writer.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(contents); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, out.Close()
+}
+
+// GenerateKey generates a key pair to sign bundles with and returns the
+// base64-encoded public and private keys.
+func GenerateKey() (string, string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(public), base64.StdEncoding.EncodeToString(private), nil
+}
+
+// ReadKey reads a base64-encoded private key written by 'langforge bundle
+// keygen'.
+func ReadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%s is not a private key of langforge bundles", path)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// PublicKey returns the base64-encoded public key of a private key.
+func PublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
@@ -0,0 +1,301 @@
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes of a cassette.
+const (
+	// Record forwards requests that the cassette has no response for to the
+	// provider and appends the responses to the cassette. Recorded requests
+	// are replayed.
+	Record = "record"
+	// Replay answers all requests from the cassette and fails requests that
+	// were not recorded, without contacting the provider.
+	Replay = "replay"
+)
+
+// ReplayAPIKey is the API key that clients use in replay mode if no key is
+// set, so that replaying needs no credentials.
+const ReplayAPIKey = "sk-langforge-replay"
+
+// Interaction is a recorded provider request and its response. Credentials
+// are never recorded.
+type Interaction struct {
+	Key         string          `json:"key"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Request     json.RawMessage `json:"request,omitempty"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"contentType,omitempty"`
+	Response    string          `json:"response"`
+	Recorded    time.Time       `json:"recorded"`
+}
+
+// Cassette is an OpenAI compatible API on the loopback interface that records
+// the calls of clients to the provider and replays them. Identical requests
+// receive the same response, so that an evaluation is deterministic.
+// Several processes, e.g. a served application and an evaluation, may share
+// a cassette file, since interactions are only appended.
+type Cassette struct {
+	path     string
+	mode     string
+	upstream string
+
+	mu           sync.Mutex
+	interactions map[string]*Interaction
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// Open loads the cassette at path and starts serving it on a free port.
+// upstream is the base URL of the provider API, e.g.
+// "https://api.openai.com/v1", which is only contacted in record mode.
+func Open(path string, mode string, upstream string) (*Cassette, error) {
+	if mode != Record && mode != Replay {
+		return nil, fmt.Errorf("unknown cassette mode %q, use %s or %s", mode, Record, Replay)
+	}
+	c := &Cassette{
+		path:         path,
+		mode:         mode,
+		upstream:     strings.TrimSuffix(upstream, "/"),
+		interactions: map[string]*Interaction{},
+	}
+	if err := c.load(); err != nil {
+		if !os.IsNotExist(err) || mode == Replay {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	c.listener = listener
+	c.server = &http.Server{Handler: c}
+	go c.server.Serve(listener)
+	return c, nil
+}
+
+func (c *Cassette) load() error {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		interaction := &Interaction{}
+		if err := json.Unmarshal(line, interaction); err != nil {
+			return fmt.Errorf("invalid interaction in cassette %s: %v", c.path, err)
+		}
+		if c.interactions[interaction.Key] == nil {
+			c.interactions[interaction.Key] = interaction
+		}
+	}
+	return scanner.Err()
+}
+
+// URL returns the base URL of the API, to be used as OPENAI_API_BASE.
+func (c *Cassette) URL() string {
+	return fmt.Sprintf("http://%s/v1", c.listener.Addr().String())
+}
+
+// Env returns the environment variables that point OpenAI clients of
+// LangChain, LangChain.js and langforge to the cassette.
+func (c *Cassette) Env() map[string]string {
+	env := map[string]string{
+		"OPENAI_API_BASE": c.URL(),
+		"OPENAI_BASE_URL": c.URL(),
+	}
+	if c.mode == Replay && os.Getenv("OPENAI_API_KEY") == "" {
+		env["OPENAI_API_KEY"] = ReplayAPIKey
+	}
+	return env
+}
+
+// Mode returns the mode of the cassette, Record or Replay.
+func (c *Cassette) Mode() string {
+	return c.mode
+}
+
+// Close stops serving the cassette.
+func (c *Cassette) Close() error {
+	return c.server.Close()
+}
+
+// key identifies a request by its method, path and body. JSON bodies are
+// normalized, so that the order of their fields does not matter.
+func key(method string, path string, body []byte) string {
+	var value any
+	if json.Unmarshal(body, &value) == nil {
+		if normalized, err := json.Marshal(value); err == nil {
+			body = normalized
+		}
+	}
+	sum := sha256.Sum256([]byte(method + " " + path + "\n" + string(body)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// lookup returns the recorded response for a key, or nil.
+func (c *Cassette) lookup(key string) *Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.interactions[key]
+}
+
+func (c *Cassette) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	k := key(r.Method, path, body)
+
+	if interaction := c.lookup(k); interaction != nil {
+		if interaction.ContentType != "" {
+			w.Header().Set("Content-Type", interaction.ContentType)
+		}
+		w.WriteHeader(interaction.Status)
+		io.WriteString(w, interaction.Response)
+		return
+	}
+
+	if c.mode == Replay {
+		fmt.Printf("Cassette %s has no response for %s %s, record it with --record-llm\n", c.path, r.Method, path)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("the request was not recorded in the cassette %s", c.path))
+		return
+	}
+	c.record(w, r, path, k, body)
+}
+
+// record forwards a request to the provider, streams the response to the
+// client and appends successful responses to the cassette.
+func (c *Cassette) record(w http.ResponseWriter, r *http.Request, path string, k string, body []byte) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, c.upstream+path, bytes.NewReader(body))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	for _, name := range []string{"Authorization", "Content-Type", "Accept", "OpenAI-Organization", "OpenAI-Project", "OpenAI-Beta"} {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	// pass streamed responses on as they arrive
+	flusher, _ := w.(http.Flusher)
+	var recorded bytes.Buffer
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buffer)
+		if n > 0 {
+			recorded.Write(buffer[:n])
+			w.Write(buffer[:n])
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	// errors such as rate limits are not replayed
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return
+	}
+	interaction := &Interaction{
+		Key:         k,
+		Method:      r.Method,
+		Path:        path,
+		Status:      resp.StatusCode,
+		ContentType: contentType,
+		Response:    recorded.String(),
+		Recorded:    time.Now().UTC(),
+	}
+	if json.Valid(body) {
+		interaction.Request = body
+	}
+	if err := c.append(interaction); err != nil {
+		fmt.Printf("Error recording to cassette %s: %v\n", c.path, err)
+	}
+}
+
+// append adds an interaction to the cassette file. Each interaction is a
+// single write of one line, so that processes sharing the file do not
+// interleave their interactions.
+func (c *Cassette) append(interaction *Interaction) error {
+	data, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interactions[interaction.Key] != nil {
+		// a concurrent identical request was recorded first
+		return nil
+	}
+	c.interactions[interaction.Key] = interaction
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// writeError writes an error in the format of the OpenAI API.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": "invalid_request_error"},
+	})
+}
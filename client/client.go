@@ -0,0 +1,251 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/provider"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultURL is the address the serve command listens on by default.
+const DefaultURL = "http://localhost:2204"
+
+// Client invokes the chains of a served LangChain application over HTTP.
+type Client struct {
+	baseURL string
+	apiKey  string
+	header  http.Header
+	http    *http.Client
+}
+
+// Error is returned when a chain fails or the server rejects a request.
+type Error struct {
+	Chain string
+	// Status is the HTTP status of the response, or zero for errors that are
+	// streamed after the response started.
+	Status int
+	// Code identifies provider errors, e.g. provider.RateLimited. It is empty
+	// for other errors.
+	Code    provider.Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("chain %q failed: %s (%s)", e.Chain, e.Message, e.Code)
+	}
+	return fmt.Sprintf("chain %q failed: %s", e.Chain, e.Message)
+}
+
+// ErrorCode returns the code of the error.
+func (e *Error) ErrorCode() provider.Code {
+	return e.Code
+}
+
+// newError returns the error of an error response or event of a chain. The
+// code of errors from workers that are not behind a gateway is derived from
+// their details.
+func newError(chain string, status int, failure *provider.ErrorBody) *Error {
+	failure.Normalize()
+	return &Error{Chain: chain, Status: status, Code: failure.Code, Message: failure.Error}
+}
+
+// New returns a client for the application served at baseURL.
+func New(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		header:  http.Header{},
+		http:    http.DefaultClient,
+	}
+}
+
+// NewWithTransport returns a client that sends its requests with transport,
+// e.g. directly to a worker with a protocol.Client.
+func NewWithTransport(baseURL string, transport http.RoundTripper) *Client {
+	c := New(baseURL)
+	c.http = &http.Client{Transport: transport}
+	return c
+}
+
+// SetAPIKey sends the given API key with every request, for gateways that
+// require authentication.
+func (c *Client) SetAPIKey(apiKey string) {
+	c.apiKey = apiKey
+}
+
+// SetHeader sends a header with every request, or stops sending it if value
+// is empty.
+func (c *Client) SetHeader(name string, value string) {
+	if value == "" {
+		c.header.Del(name)
+		return
+	}
+	c.header.Set(name, value)
+}
+
+// Invoke calls the chain with the given name and returns its outputs.
+func (c *Client) Invoke(ctx context.Context, chain string, inputs map[string]any) (map[string]any, error) {
+	resp, err := c.post(ctx, chain, inputs, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readOutputs(chain, resp)
+}
+
+// modelHeader is gateway.ModelHeader, which names the model of a response.
+const modelHeader = "X-Langforge-Model"
+
+// InvokeModel calls the chain with the given name like Invoke and also
+// returns the model that generated the outputs, as the gateway names it, or
+// an empty string if the gateway did not name one, e.g. because the chain
+// used several models.
+func (c *Client) InvokeModel(ctx context.Context, chain string, inputs map[string]any) (map[string]any, string, error) {
+	resp, err := c.post(ctx, chain, inputs, false)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	outputs, err := readOutputs(chain, resp)
+	return outputs, resp.Header.Get(modelHeader), err
+}
+
+// Stream calls the chain with the given name, passes the tokens of streaming
+// LLMs to onToken as they are generated and returns the outputs.
+func (c *Client) Stream(ctx context.Context, chain string, inputs map[string]any, onToken func(token string)) (map[string]any, error) {
+	resp, err := c.post(ctx, chain, inputs, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// errors and chains with guardrails are answered with a JSON body
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readOutputs(chain, resp)
+	}
+
+	event := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data := []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			switch event {
+			case "token":
+				var token struct {
+					Token string `json:"token"`
+				}
+				if err := json.Unmarshal(data, &token); err == nil && onToken != nil {
+					onToken(token.Token)
+				}
+			case "result":
+				outputs := make(map[string]any)
+				if err := json.Unmarshal(data, &outputs); err != nil {
+					return nil, fmt.Errorf("unexpected result from chain %q: %v", chain, err)
+				}
+				return outputs, nil
+			case "error":
+				var failure provider.ErrorBody
+				json.Unmarshal(data, &failure)
+				return nil, newError(chain, 0, &failure)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("chain %q ended without a result", chain)
+}
+
+// post sends inputs to a chain, asking for server-sent events if stream is set.
+func (c *Client) post(ctx context.Context, chain string, inputs map[string]any, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/"+url.PathEscape(chain), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return c.http.Do(req)
+}
+
+// readOutputs reads the outputs of a chain from a JSON response.
+func readOutputs(chain string, resp *http.Response) (map[string]any, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string]any)
+	if err := json.Unmarshal(data, &outputs); err != nil {
+		return nil, fmt.Errorf("unexpected response from chain %q (status %d): %s", chain, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure provider.ErrorBody
+		json.Unmarshal(data, &failure)
+		if failure.Error == "" {
+			failure.Error = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+		return nil, newError(chain, resp.StatusCode, &failure)
+	}
+
+	return outputs, nil
+}
+
+// OutputText returns the output of a chain as text. If key is empty and the chain
+// returned exactly one output, that output is used.
+func OutputText(outputs map[string]any, key string) (string, error) {
+	if key == "" {
+		if len(outputs) != 1 {
+			keys := []string{}
+			for k := range outputs {
+				keys = append(keys, k)
+			}
+			return "", fmt.Errorf("chain returned multiple outputs %v, specify the output key", keys)
+		}
+		for k := range outputs {
+			key = k
+		}
+	}
+
+	value, ok := outputs[key]
+	if !ok {
+		return "", fmt.Errorf("chain output %q not found", key)
+	}
+
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/deps"
+	"langforge/python"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/userconfig"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <provider>...",
+	Short: "Add the packages of LLM providers and vector stores to the project",
+	Long: `The add command adds the client packages of LLM providers, vector stores
+and other services, such as openai, anthropic, huggingface, chroma or
+pinecone, to the project at the versions that langforge is tested with, and
+installs them:
+
+  langforge add openai chroma
+
+Python projects get the packages in requirements.txt and installed into the
+project environment, or added with poetry, uv or pipenv if the project has a
+poetry.lock, uv.lock or Pipfile.lock; projects with a package.json get them
+as exact dependencies with the package manager of the project. Packages that the
+project already requires keep their versions. The API keys of the providers
+are added to .env, with the keys of 'langforge keys' if they are set.
+
+--print prints the lines of requirements.txt or the dependencies of
+package.json instead of changing the project, and --list lists the
+providers.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if list, _ := cmd.Flags().GetBool("list"); !list && len(args) == 0 {
+			return fmt.Errorf("requires the names of the providers, see 'langforge add --list'")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			listProvidersCmd()
+			return
+		}
+		ecosystem := ""
+		if npm, _ := cmd.Flags().GetBool("npm"); npm {
+			ecosystem = deps.Npm
+		}
+		if pip, _ := cmd.Flags().GetBool("python"); pip {
+			ecosystem = deps.Python
+		}
+		printOnly, err := cmd.Flags().GetBool("print")
+		if err != nil {
+			panic(err)
+		}
+		addProvidersCmd(args, ecosystem, printOnly)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+	addCmd.Flags().Bool("print", false, "print the requirements or dependencies instead of adding them")
+	addCmd.Flags().Bool("list", false, "list the providers")
+	addCmd.Flags().Bool("python", false, "add the Python packages, also to projects with a package.json")
+	addCmd.Flags().Bool("npm", false, "add the npm packages, also to projects without a package.json")
+	addCmd.MarkFlagsMutuallyExclusive("python", "npm")
+}
+
+func listProvidersCmd() {
+	providers, err := deps.Providers()
+	if err != nil {
+		panic(err)
+	}
+	rows := [][]string{}
+	for _, provider := range providers {
+		rows = append(rows, []string{provider.Name, provider.Title, strings.Join(provider.Python, " "), strings.Join(provider.Npm, " ")})
+	}
+	if err := tui.PrintTable([]string{"Name", "Title", "Python", "npm"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func addProvidersCmd(names []string, ecosystem string, printOnly bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	providers, err := deps.LookupProviders(names)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	packageJSON := filepath.Join(cwd, "package.json")
+	if ecosystem == "" {
+		ecosystem = deps.Python
+		if _, err := os.Stat(packageJSON); err == nil {
+			ecosystem = deps.Npm
+		}
+	}
+
+	packages, missing := deps.ProviderPackages(providers, ecosystem)
+	for _, provider := range missing {
+		fmt.Printf("%s has no %s packages.\n", provider.Title, ecosystem)
+	}
+	if len(packages) == 0 {
+		os.Exit(1)
+	}
+
+	if printOnly {
+		fragment := deps.RequirementsFragment(packages)
+		if ecosystem == deps.Npm {
+			fragment, err = deps.PackageJSONFragment(packages)
+			if err != nil {
+				panic(err)
+			}
+		}
+		fmt.Print(fragment)
+		return
+	}
+
+	if ecosystem == deps.Npm {
+		addNpmPackages(cwd, packageJSON, packages)
+	} else {
+		addPythonPackages(cwd, packages)
+	}
+
+	apiKeys := []string{}
+	for _, provider := range providers {
+		apiKeys = append(apiKeys, provider.ApiKeys...)
+	}
+	if len(apiKeys) == 0 {
+		return
+	}
+	dotEnvPath := filepath.Join(cwd, ".env")
+	if err := system.EnsureEnv(dotEnvPath, apiKeys); err != nil {
+		panic(err)
+	}
+	if _, err := userconfig.ApplyKeys(dotEnvPath, apiKeys); err != nil {
+		panic(err)
+	}
+	unsetKeys, err := system.UnsetAPIKeys(dotEnvPath, apiKeys)
+	if err != nil {
+		panic(err)
+	}
+	if len(unsetKeys) > 0 {
+		fmt.Println("The following API keys are not set yet:", unsetKeys)
+		fmt.Println("Run 'langforge keys' to set them.")
+	}
+}
+
+func addPythonPackages(dir string, packages []string) {
+	manager, err := system.FindPythonProjectManager(dir)
+	if err != nil {
+		fmt.Println("Error adding the packages:", err)
+		os.Exit(1)
+	}
+	if manager != nil {
+		addLockedPythonPackages(dir, manager, packages)
+		return
+	}
+
+	added, kept, err := deps.AddRequirements(filepath.Join(dir, "requirements.txt"), packages)
+	if err != nil {
+		panic(err)
+	}
+	for _, line := range kept {
+		fmt.Printf("Keeping %s of requirements.txt.\n", line)
+	}
+	if len(added) == 0 {
+		fmt.Println("requirements.txt already has the packages.")
+		return
+	}
+	fmt.Printf("Added %s to requirements.txt.\n", strings.Join(added, ", "))
+
+	if !activateProjectEnvironment(dir) {
+		os.Exit(1)
+	}
+	if err := python.InstallPackages(added); err != nil {
+		fmt.Println("Error installing the packages:", err)
+		os.Exit(1)
+	}
+}
+
+// addLockedPythonPackages adds packages to a project whose dependencies
+// poetry, uv or pipenv manage in a lockfile, with the tool of the project.
+func addLockedPythonPackages(dir string, manager *system.PythonProjectManager, packages []string) {
+	added, kept, err := deps.UnlockedRequirements(filepath.Join(dir, manager.Lockfile), packages)
+	if err != nil {
+		panic(err)
+	}
+	for _, pkg := range kept {
+		fmt.Printf("Keeping %s of %s.\n", pkg, manager.Lockfile)
+	}
+	if len(added) == 0 {
+		fmt.Printf("%s already has the packages.\n", manager.Lockfile)
+		return
+	}
+
+	if !activateProjectEnvironment(dir) {
+		os.Exit(1)
+	}
+	if err := python.AddProjectPackages(dir, added); err != nil {
+		fmt.Println("Error adding the packages:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added %s with %s.\n", strings.Join(added, ", "), manager.Name)
+}
+
+func addNpmPackages(dir string, packageJSON string, packages []string) {
+	dependencies, devDependencies, err := deps.PackageJSONDependencies(packageJSON)
+	if err != nil && !os.IsNotExist(err) {
+		panic(err)
+	}
+	added := []string{}
+	for _, pkg := range packages {
+		name, _ := deps.SplitNpmPackage(pkg)
+		if version, ok := dependencies[name]; ok {
+			fmt.Printf("Keeping %s %s of package.json.\n", name, version)
+			continue
+		}
+		if version, ok := devDependencies[name]; ok {
+			fmt.Printf("Keeping %s %s of package.json.\n", name, version)
+			continue
+		}
+		added = append(added, pkg)
+	}
+	if len(added) == 0 {
+		fmt.Println("package.json already has the packages.")
+		return
+	}
+
+	installer, err := system.FindNodePackageManager(dir)
+	if err != nil {
+		fmt.Println("Error adding the packages:", err)
+		os.Exit(1)
+	}
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	runner := &system.Runner{Dir: dir, Retry: &system.InstallRetry}
+	if err := installer.AddExact(ctx, runner, added...); err != nil {
+		fmt.Println("Error adding the packages:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added %s to package.json.\n", strings.Join(added, ", "))
+}
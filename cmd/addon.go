@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/addon"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var addOnCmd = &cobra.Command{
+	Use:   "add-on",
+	Short: "Add features to an existing LangChain application",
+	Long: `The add-on command patches an existing project with a feature: it adds files,
+Python requirements, .env variables and langforge.yaml settings. Files that
+already exist are never overwritten and settings of the project are kept where
+the add-on does not need to change them.
+
+Use --dry-run to see the changes as a diff before applying them, or
+--interactive to review the changes to each file and choose which to apply.
+Unchanged lines of the .env file are not shown, since it usually contains
+secrets.`,
+}
+
+var addOnListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available add-ons",
+	Run: func(cmd *cobra.Command, args []string) {
+		listAddOnsCmd()
+	},
+}
+
+var addOnApplyCmd = &cobra.Command{
+	Use:   "apply [add-on]",
+	Short: "Apply an add-on to the project in the current directory",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("add-on name is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			panic(err)
+		}
+		interactive, err := cmd.Flags().GetBool("interactive")
+		if err != nil {
+			panic(err)
+		}
+		applyAddOnCmd(args[0], dryRun, interactive)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addOnCmd)
+	addOnCmd.AddCommand(addOnListCmd)
+	addOnCmd.AddCommand(addOnApplyCmd)
+	addOnApplyCmd.Flags().Bool("dry-run", false, "print the changes as a diff without applying them")
+	addOnApplyCmd.Flags().BoolP("interactive", "i", false, "review the changes to each file and choose which to apply")
+}
+
+func listAddOnsCmd() {
+	rows := [][]string{}
+	for _, addOn := range addon.List() {
+		rows = append(rows, []string{addOn.Name, addOn.Description})
+	}
+
+	err := tui.PrintTable([]string{"Add-on", "Description"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func applyAddOnCmd(name string, dryRun bool, interactive bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	addOn, err := addon.Find(name)
+	if err != nil {
+		panic(err)
+	}
+
+	changes, skipped, err := addon.Plan(cwd, addOn)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, path := range skipped {
+		fmt.Printf("Skipping %s, the file already exists.\n", path)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("The add-on %s is already applied.\n", name)
+		return
+	}
+
+	if dryRun {
+		for _, change := range changes {
+			fmt.Print(change.Diff())
+		}
+		return
+	}
+
+	if interactive {
+		changes = reviewChanges(changes)
+		if len(changes) == 0 {
+			fmt.Printf("No changes of the add-on %s were applied.\n", name)
+			return
+		}
+	}
+
+	if err := addon.Apply(cwd, changes); err != nil {
+		panic(err)
+	}
+	requirements := false
+	for _, change := range changes {
+		if change.Created {
+			fmt.Printf("Created %s\n", change.Path)
+		} else {
+			fmt.Printf("Updated %s\n", change.Path)
+		}
+		requirements = requirements || change.Path == "requirements.txt"
+	}
+	fmt.Printf("Applied add-on %s.\n", name)
+	if manager, lockfile := system.DetectPythonProjectManager(cwd); requirements && manager != "" {
+		fmt.Printf("The project uses %s according to %s, add the new requirements of requirements.txt with %s.\n", manager, lockfile, manager)
+	} else if requirements {
+		fmt.Println("Install the new requirements with 'pip install -r requirements.txt'.")
+	}
+}
+
+// reviewChanges lets the user choose the changes to apply to each file and
+// returns the changes with the chosen contents. Files without chosen changes
+// are left out.
+func reviewChanges(changes []*addon.Change) []*addon.Change {
+	reviewed := []*addon.Change{}
+	for _, change := range changes {
+		content, changed, err := tui.ReviewPatch(change.Patch())
+		if err != nil {
+			panic(err)
+		}
+		tui.EmptyLine()
+		if changed {
+			change.New = content
+			reviewed = append(reviewed, change)
+		}
+	}
+	return reviewed
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/analytics"
+	"langforge/tui"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "Query the requests served by your LangChain application",
+	Long: `The analytics command queries the metadata of the chain requests that
+'langforge serve' recorded in .langforge/analytics.db: the chain, the status,
+the latency, the API key and, for OpenAI models, the token usage and cost.
+
+All subcommands cover the last 30 days by default. Use --days to change the
+period or --days 0 to include all requests.`,
+}
+
+var analyticsTopCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show the most requested chains with their error rate, latency and cost",
+	Run: func(cmd *cobra.Command, args []string) {
+		topChainsCmd(analyticsSince(cmd))
+	},
+}
+
+var analyticsCostCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Show the token usage and cost per day",
+	Run: func(cmd *cobra.Command, args []string) {
+		costByDayCmd(analyticsSince(cmd))
+	},
+}
+
+var analyticsKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Show the requests, errors and cost of each API key by chain",
+	Run: func(cmd *cobra.Command, args []string) {
+		keyUsageCmd(analyticsSince(cmd))
+	},
+}
+
+var analyticsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the recorded requests as CSV",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			panic(err)
+		}
+		exportAnalyticsCmd(analyticsSince(cmd), output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyticsCmd)
+	analyticsCmd.AddCommand(analyticsTopCmd)
+	analyticsCmd.AddCommand(analyticsCostCmd)
+	analyticsCmd.AddCommand(analyticsKeysCmd)
+	analyticsCmd.AddCommand(analyticsExportCmd)
+	analyticsCmd.PersistentFlags().Int("days", 30, "number of days to include, 0 for all requests")
+	analyticsExportCmd.Flags().StringP("output", "o", "", "write the CSV to this file instead of stdout")
+}
+
+// analyticsSince returns the start of the period selected with --days.
+func analyticsSince(cmd *cobra.Command) time.Time {
+	days, err := cmd.Flags().GetInt("days")
+	if err != nil {
+		panic(err)
+	}
+	if days <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+func openAnalytics() *analytics.DB {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if _, err := os.Stat(analytics.Path(cwd)); os.IsNotExist(err) {
+		fmt.Println("No requests have been recorded yet. Run 'langforge serve' to record requests.")
+		os.Exit(0)
+	}
+
+	db, err := analytics.Open(cwd)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func topChainsCmd(since time.Time) {
+	stats, err := openAnalytics().TopChains(since)
+	if err != nil {
+		panic(err)
+	}
+	if len(stats) == 0 {
+		fmt.Println("No requests found.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, s := range stats {
+		chain := s.Chain
+		if s.Variant != "" {
+			chain += " (" + s.Variant + ")"
+		}
+		rows = append(rows, []string{
+			chain,
+			strconv.Itoa(s.Requests),
+			fmt.Sprintf("%.1f%%", float64(s.Errors)*100/float64(s.Requests)),
+			fmt.Sprintf("%.0f ms", s.AvgLatencyMs),
+			fmt.Sprintf("%d ms", s.P95LatencyMs),
+			strconv.Itoa(s.Tokens),
+			fmt.Sprintf("$%.4f", s.Cost),
+		})
+	}
+
+	err = tui.PrintTable([]string{"Chain", "Requests", "Errors", "Avg latency", "P95 latency", "Tokens", "Cost"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func costByDayCmd(since time.Time) {
+	days, err := openAnalytics().CostByDay(since)
+	if err != nil {
+		panic(err)
+	}
+	if len(days) == 0 {
+		fmt.Println("No requests found.")
+		return
+	}
+
+	rows := [][]string{}
+	total := 0.0
+	for _, d := range days {
+		rows = append(rows, []string{d.Day, strconv.Itoa(d.Requests), strconv.Itoa(d.Tokens), fmt.Sprintf("$%.4f", d.Cost)})
+		total += d.Cost
+	}
+
+	err = tui.PrintTable([]string{"Day", "Requests", "Tokens", "Cost"}, rows)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Total cost: $%.4f\n", total)
+}
+
+func keyUsageCmd(since time.Time) {
+	stats, err := openAnalytics().KeyUsage(since)
+	if err != nil {
+		panic(err)
+	}
+	if len(stats) == 0 {
+		fmt.Println("No requests with API keys found.")
+		return
+	}
+
+	rows := [][]string{}
+	totals := map[string]float64{}
+	keys := []string{}
+	for _, s := range stats {
+		if _, ok := totals[s.Key]; !ok {
+			keys = append(keys, s.Key)
+		}
+		totals[s.Key] += s.Cost
+		rows = append(rows, []string{
+			s.Key,
+			s.Chain,
+			strconv.Itoa(s.Requests),
+			fmt.Sprintf("%.1f%%", float64(s.Errors)*100/float64(s.Requests)),
+			fmt.Sprintf("%.0f ms", s.AvgLatencyMs),
+			strconv.Itoa(s.Tokens),
+			fmt.Sprintf("$%.4f", s.Cost),
+		})
+	}
+
+	err = tui.PrintTable([]string{"Key", "Chain", "Requests", "Errors", "Avg latency", "Tokens", "Cost"}, rows)
+	if err != nil {
+		panic(err)
+	}
+	for _, key := range keys {
+		fmt.Printf("Total cost of %s: $%.4f\n", key, totals[key])
+	}
+}
+
+func exportAnalyticsCmd(since time.Time, output string) {
+	db := openAnalytics()
+
+	if output == "" {
+		if err := db.ExportCSV(os.Stdout, since); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	if err := db.ExportCSV(file, since); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Exported requests to %s\n", output)
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/batch"
+	"langforge/client"
+	"langforge/dataset"
+	"langforge/gateway"
+	"langforge/permission"
+	"langforge/project"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var runBatchCmd = &cobra.Command{
+	Use:   "run-batch",
+	Short: "Run a chain on every record of a JSONL or CSV file",
+	Long: `The run-batch command sends each record of a JSONL or CSV file to a chain of
+your served LangChain application and writes the outputs to a JSONL file, for
+offline jobs such as enriching a dataset:
+
+  langforge run-batch --input data.jsonl --chain qa --output results.jsonl
+
+The fields of a record are the inputs of the chain. Each line of the output
+holds the index of a record, counting from 1, its inputs and either the outputs
+of the chain or the error. Lines are written as records finish, so they are
+not in input order when records run concurrently (--concurrency).
+
+If the output exists, the run resumes it: records that succeeded are skipped
+and failed ones are tried again. Press Ctrl-C to stop a run and run the same
+command to resume it later, or pass --restart to start over.
+
+The input is either a path or the name of a dataset registered with
+'langforge data add'. The application has to be running, e.g. with
+'langforge serve'. If the gateway requires an API key, it is read from
+LANGFORGE_API_KEY.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runBatchAppCmd(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runBatchCmd)
+	runBatchCmd.Flags().String("input", "", "JSONL or CSV file with a record per invocation")
+	runBatchCmd.Flags().String("chain", "", "chain to run")
+	runBatchCmd.Flags().String("output", "", "JSONL file for the results (default <input>.results.jsonl)")
+	runBatchCmd.Flags().Int("concurrency", batch.DefaultConcurrency, "number of records to run at the same time")
+	runBatchCmd.Flags().String("url", client.DefaultURL, "URL of the served LangChain application")
+	runBatchCmd.Flags().String("preset", "", "preset of langforge.yaml that the chain is invoked with")
+	runBatchCmd.Flags().Bool("restart", false, "discard the results of an earlier run instead of resuming it")
+	runBatchCmd.Flags().Bool("force", false, "discard the results of an earlier run without asking")
+	runBatchCmd.MarkFlagRequired("input")
+	runBatchCmd.MarkFlagRequired("chain")
+}
+
+// batchOutputPath returns the default output of a batch run for an input.
+func batchOutputPath(input string) string {
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ".results.jsonl"
+}
+
+func runBatchAppCmd(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	input, err := flags.GetString("input")
+	if err != nil {
+		panic(err)
+	}
+	chain, err := flags.GetString("chain")
+	if err != nil {
+		panic(err)
+	}
+	output, err := flags.GetString("output")
+	if err != nil {
+		panic(err)
+	}
+	concurrency, err := flags.GetInt("concurrency")
+	if err != nil {
+		panic(err)
+	}
+	url, err := flags.GetString("url")
+	if err != nil {
+		panic(err)
+	}
+	restart, err := flags.GetBool("restart")
+	if err != nil {
+		panic(err)
+	}
+	preset, err := flags.GetString("preset")
+	if err != nil {
+		panic(err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	inputPath, err := dataset.Resolve(cwd, config, input)
+	if err != nil {
+		panic(err)
+	}
+	if output == "" {
+		output = batchOutputPath(inputPath)
+	}
+	env, err := system.GetEnv(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	if restart {
+		if _, err := os.Stat(output); err == nil {
+			if !confirmOperation(permission.OverwriteFile, fmt.Sprintf("Discard the results in %s?", output), forced(cmd)) {
+				return
+			}
+			if err := os.Remove(output); err != nil {
+				panic(err)
+			}
+		}
+	}
+	done, err := batch.Resume(output)
+	if err != nil {
+		panic(err)
+	}
+
+	total, err := batch.CountRecords(inputPath)
+	if err != nil {
+		panic(err)
+	}
+	if len(done) > 0 {
+		fmt.Printf("Resuming %s: %d of %d records are done.\n", output, len(done), total)
+	}
+	fmt.Printf("Running chain '%s' on %d records from %s...\n", chain, total-len(done), input)
+
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	// Ctrl-C stops the run after writing the results of finished records
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		cancel()
+	}()
+
+	bar := tui.StartProgress("Records", total-len(done))
+	finished := 0
+	chainClient := newChainClient(url, env)
+	chainClient.SetHeader(gateway.PresetHeader, preset)
+	summary, err := batch.Run(ctx, chainClient, inputPath, file, batch.Options{
+		Chain:       chain,
+		Concurrency: concurrency,
+		Done:        done,
+		OnResult: func(result batch.Result) {
+			finished++
+			switch {
+			case bar != nil:
+				bar.Increment()
+			case finished%100 == 0:
+				fmt.Printf("%d of %d records done.\n", finished, total-len(done))
+			}
+		},
+	})
+	if bar != nil {
+		bar.Stop()
+	}
+	if err != nil && ctx.Err() == nil {
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	fmt.Printf("%d records succeeded, %d failed", summary.Succeeded, summary.Failed)
+	if summary.Skipped > 0 {
+		fmt.Printf(", %d were done before", summary.Skipped)
+	}
+	fmt.Printf(". Results written to %s.\n", output)
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted, run the same command again to resume.")
+		os.Exit(1)
+	}
+	if summary.Failed > 0 {
+		fmt.Println("Run the same command again to retry the failed records.")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/bundle"
+	"langforge/state"
+	"langforge/userconfig"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Export and verify signed offline bundles of templates and the integration index",
+	Long: `The bundle command exports the built-in templates and the integration index of
+langforge into a signed offline bundle, which langforge on an air-gapped
+machine reads from disk instead of its built-in files:
+
+  langforge bundle keygen
+  langforge bundle export langforge-bundle.zip
+
+A bundle holds the index of the integrations that 'langforge integrations'
+offers, the demo project, the files of add-ons, the templates of generated
+clients, of Python packages and of Docker files. Its manifest lists the
+checksums of all files and is signed with the key of 'langforge bundle
+keygen', so a bundle that was changed after it was signed is rejected.
+
+Copy the bundle to the air-gapped machine and configure it with the public
+key that keygen printed in the configuration of the user, config.yaml in the
+langforge directory of the user's configuration directory or in
+LANGFORGE_HOME:
+
+  bundle:
+    path: /opt/langforge/langforge-bundle.zip
+    publicKeys:
+      - <public key>
+
+To change the files of a bundle, e.g. to add the integrations of a private
+package index, extract a bundle, edit the files of its sections and export the
+directory with --from. Sections that the directory does not have keep the
+built-in files. Check a bundle with 'langforge bundle verify'.`,
+}
+
+var bundleKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Create the key that bundles are signed with",
+	Run: func(cmd *cobra.Command, args []string) {
+		keygenBundleCmd(forced(cmd))
+	},
+}
+
+var bundleExportCmd = &cobra.Command{
+	Use:   "export [path]",
+	Short: "Write the built-in templates and integration index into a signed bundle",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("path is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := cmd.Flags().GetString("key")
+		if err != nil {
+			panic(err)
+		}
+		from, err := cmd.Flags().GetString("from")
+		if err != nil {
+			panic(err)
+		}
+		exportBundleCmd(args[0], key, from)
+	},
+}
+
+var bundleVerifyCmd = &cobra.Command{
+	Use:   "verify [path]",
+	Short: "Verify the signature of a bundle, by default of the configured one",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := ""
+		if len(args) > 0 {
+			path = args[0]
+		}
+		verifyBundleCmd(path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	markProjectIndependent(bundleCmd)
+	bundleCmd.AddCommand(bundleKeygenCmd)
+	bundleCmd.AddCommand(bundleExportCmd)
+	bundleCmd.AddCommand(bundleVerifyCmd)
+	bundleKeygenCmd.Flags().Bool("force", false, "replace an existing key without asking")
+	bundleExportCmd.Flags().String("from", "", "directory whose sections replace the built-in files, e.g. an extracted bundle")
+	bundleExportCmd.Flags().String("key", "", "private key to sign the bundle with (default the key of 'langforge bundle keygen')")
+}
+
+// bundleKeyPath returns the path of the key of 'langforge bundle keygen'.
+func bundleKeyPath() string {
+	dir, err := state.GlobalDir()
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(dir, bundle.KeyFileName)
+}
+
+func keygenBundleCmd(force bool) {
+	path := bundleKeyPath()
+	public, private, err := bundle.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	// bundles signed with the replaced key no longer verify with the new
+	// public key, so replacing it is up to the policy like other overwrites
+	if !confirmOverwrite(path, []byte(private+"\n"), force) {
+		fmt.Printf("Kept the key %s.\n", path)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(path, []byte(private+"\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Key written to %s.\n", path)
+	fmt.Println("Trust the bundles signed with it by adding its public key to the publicKeys of")
+	fmt.Println("the bundle in the configuration of the user on the air-gapped machine:")
+	fmt.Println()
+	fmt.Println("  " + public)
+}
+
+func exportBundleCmd(path string, keyPath string, from string) {
+	if keyPath == "" {
+		keyPath = bundleKeyPath()
+	}
+	key, err := bundle.ReadKey(keyPath)
+	if os.IsNotExist(err) {
+		panic(fmt.Errorf("%s does not exist, create a key with 'langforge bundle keygen'", keyPath))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	manifest, err := bundle.Export(path, key, from)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Bundle with %d files of %s written to %s.\n", len(manifest.Files), strings.Join(manifest.Sections, ", "), path)
+	fmt.Printf("It is signed with the public key %s.\n", bundle.PublicKey(key))
+}
+
+func verifyBundleCmd(path string) {
+	config, err := userconfig.Load()
+	if err != nil {
+		panic(err)
+	}
+	if path == "" {
+		path = config.Bundle.Path
+	}
+	if path == "" {
+		fmt.Println("No bundle is configured, langforge uses its built-in files.")
+		return
+	}
+
+	b, err := bundle.Open(path, config.Bundle.PublicKeys)
+	if err != nil {
+		panic(err)
+	}
+	defer b.Close()
+
+	fmt.Printf("%s is signed with a trusted key.\n", path)
+	fmt.Printf("Created: %s\n", b.Manifest.Created.Local().Format("2006-01-02 15:04"))
+	fmt.Printf("Sections: %s\n", strings.Join(b.Manifest.Sections, ", "))
+	fmt.Printf("Files: %d\n", len(b.Manifest.Files))
+}
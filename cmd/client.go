@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/codegen"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Generate clients for the served chains",
+}
+
+var clientGenCmd = &cobra.Command{
+	Use:   "gen",
+	Short: "Generate a typed TypeScript, Python or Go client",
+	Long: `The gen command generates a typed client for the chains of your application
+from the schemas in .langforge/schemas.json. The client has an input and an
+output type and a method per chain, as well as a streaming method that yields
+"token", "end" and "error" events as they are sent by the server.
+
+Run 'langforge schema extract <notebook>' or 'langforge serve' first to extract
+the schemas, and regenerate the client whenever the chains change.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		lang, err := cmd.Flags().GetString("lang")
+		if err != nil {
+			panic(err)
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			panic(err)
+		}
+		pkg, err := cmd.Flags().GetString("package")
+		if err != nil {
+			panic(err)
+		}
+		generateClientCmd(lang, output, pkg, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clientCmd)
+	clientCmd.AddCommand(clientGenCmd)
+	clientGenCmd.Flags().String("lang", "ts", "client language: "+strings.Join(codegen.Languages, ", "))
+	clientGenCmd.Flags().StringP("output", "o", "", "output file (default depends on the language)")
+	clientGenCmd.Flags().String("package", "langforgeclient", "package name of Go clients")
+	clientGenCmd.Flags().Bool("force", false, "overwrite a changed output file without asking")
+}
+
+func generateClientCmd(lang string, output string, pkg string, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	schemas, err := loadSchemas(cwd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	source, err := codegen.Generate(lang, pkg, schemas)
+	if err != nil {
+		panic(err)
+	}
+
+	if output == "" {
+		output = codegen.DefaultOutput(lang)
+	}
+
+	if !confirmOverwrite(output, source, force) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	err = os.WriteFile(output, source, 0644)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Client for %d chains written to %s\n", len(schemas.Chains), output)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/connectors"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/userconfig"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var connectCmd = &cobra.Command{
+	Use:       "connect <confluence|notion|gdrive>",
+	Short:     "Set up the credentials of Confluence, Notion or Google Drive for ingestion",
+	ValidArgs: []string{connectors.Confluence, connectors.Notion, connectors.GoogleDrive},
+	Args:      cobra.ExactValidArgs(1),
+	Long: `The connect command asks for the credentials of a service whose documents
+ingestion sources can ingest, checks them with the service and stores them
+with your keys, where the sources find them:
+
+  confluence  the email of your Atlassian account and an API token, created
+              at https://id.atlassian.com/manage-profile/security/api-tokens
+              (CONFLUENCE_EMAIL, CONFLUENCE_API_TOKEN)
+  notion      the token of an internal integration, created at
+              https://www.notion.so/my-integrations; share the pages or
+              databases to ingest with the integration (NOTION_TOKEN)
+  gdrive      the client ID and secret of an OAuth client of type Desktop app
+              of your Google Cloud project, with the Drive API enabled; the
+              command opens the browser to authorize read access to your
+              files and stores the refresh token (GOOGLE_DRIVE_CLIENT_ID,
+              GOOGLE_DRIVE_CLIENT_SECRET, GOOGLE_DRIVE_REFRESH_TOKEN)
+
+Sources without credentials of their own use these keys, so they can be
+shared by all projects. The command prints a source to add to langforge.yaml.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		connectCmdRun(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(connectCmd)
+	markProjectIndependent(connectCmd)
+}
+
+func connectCmdRun(kind string) {
+	ctx := context.Background()
+	location := &connectors.Location{Kind: kind}
+	credentials := map[string]string{}
+	keys := map[string]string{}
+	source := ""
+
+	switch kind {
+	case connectors.Confluence:
+		site, err := tui.PromptString("Confluence site (e.g. acme.atlassian.net)", "")
+		if err != nil {
+			panic(err)
+		}
+		location.Host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(site), "https://"), "/")
+		credentials["email"], err = tui.PromptString("Email of your Atlassian account", "")
+		if err != nil {
+			panic(err)
+		}
+		credentials["api_token"], err = tui.PromptPassword("API token")
+		if err != nil {
+			panic(err)
+		}
+		keys["CONFLUENCE_EMAIL"] = credentials["email"]
+		keys["CONFLUENCE_API_TOKEN"] = credentials["api_token"]
+		source = "confluence://" + location.Host + "/SPACE"
+	case connectors.Notion:
+		token, err := tui.PromptPassword("Token of the Notion integration")
+		if err != nil {
+			panic(err)
+		}
+		credentials["token"] = token
+		keys["NOTION_TOKEN"] = token
+		source = "notion://"
+	case connectors.GoogleDrive:
+		clientID, err := tui.PromptString("Client ID of the OAuth client", "")
+		if err != nil {
+			panic(err)
+		}
+		clientSecret, err := tui.PromptPassword("Client secret")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("Opening the browser to authorize read access to your Google Drive...")
+		refreshToken, err := connectors.AuthorizeGoogle(ctx, clientID, clientSecret, connectors.GoogleDriveScope, func(url string) error {
+			if err := system.OpenBrowser(url); err != nil {
+				fmt.Println("Open this URL in your browser:", url)
+			}
+			return nil
+		})
+		if err != nil {
+			panic(err)
+		}
+		credentials["client_id"] = clientID
+		credentials["client_secret"] = clientSecret
+		credentials["refresh_token"] = refreshToken
+		keys["GOOGLE_DRIVE_CLIENT_ID"] = clientID
+		keys["GOOGLE_DRIVE_CLIENT_SECRET"] = clientSecret
+		keys["GOOGLE_DRIVE_REFRESH_TOKEN"] = refreshToken
+		source = "gdrive://FOLDER_ID"
+	}
+
+	who, err := connectors.Verify(ctx, location, credentials)
+	if err != nil {
+		fmt.Println("The credentials were not accepted:", err)
+		return
+	}
+	for name, value := range keys {
+		if err := userconfig.SetKey(name, value); err != nil {
+			panic(err)
+		}
+	}
+
+	if who != "" {
+		fmt.Printf("Connected to %s as %s.\n", kind, who)
+	} else {
+		fmt.Printf("Connected to %s.\n", kind)
+	}
+	tui.EmptyLine()
+	fmt.Println("Add a source to the ingestion of langforge.yaml and run 'langforge ingest':")
+	tui.EmptyLine()
+	fmt.Println("  vectorstore:")
+	fmt.Println("    ingestion:")
+	fmt.Println("      sources:")
+	fmt.Println("        - path: " + source)
+}
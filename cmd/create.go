@@ -6,10 +6,13 @@ package cmd
 import (
 	"fmt"
 	"langforge/python"
+	"langforge/shim"
 	"langforge/system"
 	"langforge/tui"
+	"langforge/userconfig"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -21,7 +24,16 @@ var createCmd = &cobra.Command{
 	Long: `The create command generates a new LangChain application with LangForge. 
 	
 It sets up a virtual environment, installs dependencies, 
-and configures API keys, allowing you to get started quickly`,
+and configures API keys, allowing you to get started quickly.
+
+The virtual environment uses the first python3 or python in the PATH that is
+Python 3.8 or newer. In a terminal, you choose among the interpreters of the
+machine if there are several, see 'langforge runtimes'. Select one with
+--python, by path or by version, e.g. --python 3.11.
+
+With --conda, the project gets a conda environment in .conda instead, created
+with conda, mamba or micromamba, whichever is found first. --python then sets
+the version of Python that is installed into it, e.g. --conda --python 3.11.`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return fmt.Errorf("app name is missing")
@@ -29,15 +41,26 @@ and configures API keys, allowing you to get started quickly`,
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		createAppCmd(args[0])
+		pythonSpec, err := cmd.Flags().GetString("python")
+		if err != nil {
+			panic(err)
+		}
+		conda, err := cmd.Flags().GetBool("conda")
+		if err != nil {
+			panic(err)
+		}
+		createAppCmd(args[0], pythonSpec, conda)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(createCmd)
+	markProjectIndependent(createCmd)
+	createCmd.Flags().String("python", "", "create the virtual environment with this Python interpreter, given by path or version")
+	createCmd.Flags().Bool("conda", false, "create a conda environment instead of a virtual environment")
 }
 
-func createAppCmd(appName string) {
+func createAppCmd(appName string, pythonSpec string, conda bool) {
 
 	currentDir, err := os.Getwd()
 	if err != nil {
@@ -54,10 +77,15 @@ func createAppCmd(appName string) {
 		panic(fmt.Errorf("file with name '%s' already exists", dir))
 	}
 
+	config, err := userconfig.Load()
+	if err != nil {
+		panic(err)
+	}
+
 	tui.DisplayBanner()
 
 	// Check if a virtual environment should be created
-	shouldCreateEnvironment, err := tui.PromptYesNo("Create a virtual environment for your 🦜️🔗LangChain app?", true)
+	shouldCreateEnvironment, err := tui.PromptYesNo("Create a virtual environment for your 🦜️🔗LangChain app?", config.ShouldCreateEnvironment())
 	if err != nil {
 		panic(err)
 	}
@@ -70,6 +98,21 @@ func createAppCmd(appName string) {
 	}
 	tui.EmptyLine()
 
+	pythonPath := ""
+	if shouldCreateEnvironment && conda {
+		if strings.ContainsAny(pythonSpec, `/\`) {
+			panic(fmt.Errorf("--python takes a version with --conda, e.g. --python 3.11"))
+		}
+		if _, err := system.FindConda(); err != nil {
+			panic(err)
+		}
+	} else if shouldCreateEnvironment {
+		pythonPath, err = choosePython(pythonSpec)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	if !shouldCreateEnvironment {
 		err := handler.DetermineInstalledIntegrations()
 		if err != nil {
@@ -82,12 +125,28 @@ func createAppCmd(appName string) {
 		panic(err)
 	}
 
-	if shouldCreateEnvironment {
+	if shouldCreateEnvironment && conda {
+		fmt.Println("Creating conda environment...")
+		tui.EmptyLine()
+
+		envDir := filepath.Join(appName, python.CondaEnvName)
+		if err := python.CreateCondaEnv(envDir, pythonSpec); err != nil {
+			panic(err)
+		}
+		if err := python.ActivateCondaEnv(envDir); err != nil {
+			panic(err)
+		}
+	} else if shouldCreateEnvironment {
 		fmt.Println("Creating virtual environment...")
 		tui.EmptyLine()
 
 		// Create the virtual environment
-		if err := python.CreateVirtualEnv(".venv", appName); err != nil {
+		if pythonPath != "" {
+			err = python.CreateVirtualEnvWithPython(pythonPath, filepath.Join(appName, ".venv"))
+		} else {
+			err = python.CreateVirtualEnv(".venv", appName)
+		}
+		if err != nil {
 			panic(err)
 		}
 
@@ -102,6 +161,11 @@ func createAppCmd(appName string) {
 		panic(err)
 	}
 
+	// the worker that serves the app's notebooks
+	if err := shim.Python.Generate(dir); err != nil {
+		panic(err)
+	}
+
 	// Ensure the environment has all required keys in the .env file
 	dotEnvPath := filepath.Join(appName, ".env")
 	apiKeys := handler.InstalledIntegrationsApiKeys()
@@ -110,6 +174,12 @@ func createAppCmd(appName string) {
 		panic(err)
 	}
 
+	// Start with the API keys configured by 'langforge setup'
+	_, err = userconfig.ApplyKeys(dotEnvPath, apiKeys)
+	if err != nil {
+		panic(err)
+	}
+
 	if len(apiKeys) > 0 {
 		unsetKeys, err := system.UnsetAPIKeys(dotEnvPath, apiKeys)
 		if err != nil {
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/dataset"
+	"langforge/project"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Manage the datasets of your LangChain application",
+	Long: `The data command registers datasets in langforge.yaml so that evaluations
+and ingestion scripts can refer to them by name instead of hardcoded paths.
+
+Datasets can be local files or URLs. Remote datasets are downloaded into
+.langforge/datasets and verified against their SHA-256 checksum. Local
+datasets are only pinned to their checksum with 'langforge data add --pin',
+since they are usually edited along with the project.`,
+}
+
+var dataAddCmd = &cobra.Command{
+	Use:   "add [name] [path-or-url]",
+	Short: "Register a dataset",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("dataset name and source are required")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		description, err := cmd.Flags().GetString("description")
+		if err != nil {
+			panic(err)
+		}
+		pin, err := cmd.Flags().GetBool("pin")
+		if err != nil {
+			panic(err)
+		}
+		addDatasetCmd(args[0], args[1], description, pin)
+	},
+}
+
+var dataListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registered datasets",
+	Run: func(cmd *cobra.Command, args []string) {
+		listDatasetsCmd()
+	},
+}
+
+var dataFetchCmd = &cobra.Command{
+	Use:   "fetch [name...]",
+	Short: "Download registered datasets into the cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			panic(err)
+		}
+		fetchDatasetsCmd(args, force)
+	},
+}
+
+var dataPathCmd = &cobra.Command{
+	Use:   "path [name]",
+	Short: "Print the local path of a dataset, fetching it if necessary",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("dataset name is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		datasetPathCmd(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dataCmd)
+	dataCmd.AddCommand(dataAddCmd)
+	dataCmd.AddCommand(dataListCmd)
+	dataCmd.AddCommand(dataFetchCmd)
+	dataCmd.AddCommand(dataPathCmd)
+	dataAddCmd.Flags().String("description", "", "description of the dataset")
+	dataAddCmd.Flags().Bool("pin", false, "pin a local dataset to its checksum, remote datasets are always pinned")
+	dataFetchCmd.Flags().Bool("force", false, "download datasets again even if they are cached")
+}
+
+func addDatasetCmd(name string, source string, description string, pin bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	if config.FindDataset(name) != nil {
+		panic(fmt.Errorf("dataset %q is already registered", name))
+	}
+
+	if !dataset.IsURL(source) {
+		// Store local datasets relative to the project so the project stays portable
+		absSource, err := filepath.Abs(source)
+		if err != nil {
+			panic(err)
+		}
+		if rel, err := filepath.Rel(cwd, absSource); err == nil && !strings.HasPrefix(rel, "..") {
+			source = filepath.ToSlash(rel)
+		} else {
+			source = absSource
+		}
+	}
+
+	ds := project.DatasetConfig{
+		Name:        name,
+		Source:      source,
+		Description: description,
+	}
+
+	path, err := dataset.Fetch(cwd, &ds, true)
+	if err != nil {
+		panic(err)
+	}
+
+	// Local datasets are pinned on request only, otherwise editing them
+	// would break every command that reads them
+	if dataset.IsURL(source) || pin {
+		ds.SHA256, err = dataset.Checksum(path)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	config.Datasets = append(config.Datasets, ds)
+	if !saveConfig(cwd, config) {
+		fmt.Printf("Kept %s, dataset '%s' is not registered.\n", project.ConfigFileName, name)
+		return
+	}
+
+	if ds.SHA256 != "" {
+		fmt.Printf("Registered dataset '%s' (sha256 %s).\n", name, ds.SHA256[:12])
+	} else {
+		fmt.Printf("Registered dataset '%s'.\n", name)
+	}
+}
+
+func listDatasetsCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(config.Datasets) == 0 {
+		fmt.Println("No datasets registered. Use 'langforge data add' to register one.")
+		return
+	}
+
+	rows := [][]string{}
+	for i := range config.Datasets {
+		ds := &config.Datasets[i]
+		status := "missing"
+		if _, err := os.Stat(dataset.LocalPath(cwd, ds)); err == nil {
+			status = "available"
+		}
+		rows = append(rows, []string{ds.Name, ds.Source, status, ds.Description})
+	}
+
+	err = tui.PrintTable([]string{"Name", "Source", "Status", "Description"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func fetchDatasetsCmd(names []string, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	datasets := []*project.DatasetConfig{}
+	if len(names) == 0 {
+		for i := range config.Datasets {
+			datasets = append(datasets, &config.Datasets[i])
+		}
+	} else {
+		for _, name := range names {
+			ds := config.FindDataset(name)
+			if ds == nil {
+				panic(fmt.Errorf("dataset %q is not registered", name))
+			}
+			datasets = append(datasets, ds)
+		}
+	}
+
+	for _, ds := range datasets {
+		path, err := dataset.Fetch(cwd, ds, force)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s: %s\n", ds.Name, path)
+	}
+}
+
+func datasetPathCmd(name string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	ds := config.FindDataset(name)
+	if ds == nil {
+		panic(fmt.Errorf("dataset %q is not registered", name))
+	}
+
+	path, err := dataset.Fetch(cwd, ds, false)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(path)
+}
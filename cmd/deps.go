@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/deps"
+	"langforge/python"
+	"langforge/system"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var depsCmd = &cobra.Command{
+	Use:   "deps",
+	Short: "Inspect the dependencies of your LangChain applications",
+}
+
+var depsCheckCmd = &cobra.Command{
+	Use:   "check [project-dir...]",
+	Short: "Check projects for conflicting dependency constraints",
+	Long: `The check command reads the Python requirements (requirements.txt and the
+package dependencies in langforge.yaml) and npm dependencies (package.json) of
+several projects and reports packages that no single version can satisfy, e.g.
+two applications pinning incompatible pydantic majors. Run it before installing
+the projects into a shared environment.
+
+Without arguments, all projects below the current directory that contain a
+langforge.yaml file are checked. For each conflict, a minimal set of
+requirements that cannot be satisfied together is shown. The command exits
+with a non-zero status if there are conflicts, so it can be used in CI.
+
+Exclusions (!=), environment markers and requirements that are not plain
+version ranges, such as URLs or git references, are not checked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkDepsCmd(args)
+	},
+}
+
+var depsInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the dependencies of the project for the hardware of this machine",
+	Long: `The install command installs the Python dependencies of the project into its
+environment: with poetry, uv or pipenv at the versions of their lockfile, or
+with pip from requirements.txt. The npm dependencies of package.json are
+installed at the same time.
+
+Packages such as torch and faiss need other builds on machines with an NVIDIA
+GPU than on those without. The dependencies that differ are kept out of the
+base dependencies and declared as overlays, which are installed with them on
+the matching hardware. pip installs requirements-gpu.txt or
+requirements-cpu.txt together with requirements.txt, the tools of lockfiles
+install an extra (uv, poetry) or category (pipenv) with the locked
+dependencies. Projects with a lockfile need the extra, deps fails if an overlay
+only has a requirements file:
+
+  overlays:
+    gpu:
+      requirements: requirements-gpu.txt   # the default
+      extra: cu121
+    cpu:
+      extra: cpu
+
+A requirements file of an overlay may set the index of its wheels, e.g.
+
+  --extra-index-url https://download.pytorch.org/whl/cu121
+  torch==2.3.1+cu121
+  faiss-gpu==1.7.2
+
+A GPU is detected with nvidia-smi. --hardware, or the LANGFORGE_HARDWARE
+variable of the new and import commands, selects the overlay instead, e.g. to
+build an image for GPU servers on a laptop.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installDepsCmd()
+	},
+}
+
+var installHardware string
+
+func init() {
+	rootCmd.AddCommand(depsCmd)
+	markProjectIndependent(depsCmd)
+	depsCmd.AddCommand(depsCheckCmd)
+	depsInstallCmd.Flags().StringVar(&installHardware, "hardware", "", "install the overlay of gpu or cpu instead of that of the detected hardware")
+	depsCmd.AddCommand(depsInstallCmd)
+}
+
+func checkDepsCmd(dirs []string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if len(dirs) == 0 {
+		dirs, err = deps.FindProjects(cwd)
+		if err != nil {
+			panic(err)
+		}
+		if len(dirs) == 0 {
+			fmt.Println("No projects found. Pass the project directories to check.")
+			return
+		}
+	}
+
+	requirements := []*deps.Requirement{}
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			panic(err)
+		}
+		collected, err := deps.Collect(cwd, dir)
+		if err != nil {
+			panic(err)
+		}
+		requirements = append(requirements, collected...)
+	}
+
+	conflicts := deps.Check(requirements)
+	if len(conflicts) == 0 {
+		fmt.Printf("No conflicts between the dependencies of %d projects.\n", len(dirs))
+		return
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Printf("%s package %s has conflicting requirements:\n", conflict.Ecosystem, conflict.Package)
+		for _, r := range conflict.Requirements {
+			fmt.Printf("  %s, i.e. %s\n", r, r.Range)
+		}
+	}
+	fmt.Printf("Found %d conflicts. Align the constraints before installing the projects together.\n", len(conflicts))
+	os.Exit(1)
+}
+
+func installDepsCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	steps := []system.Step{}
+	step, ok, err := pythonDependenciesStep(cwd, installHardware)
+	if err != nil {
+		panic(err)
+	}
+	if ok {
+		steps = append(steps, step)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "package.json")); err == nil {
+		installer, err := system.FindNodePackageManager(cwd)
+		if err != nil {
+			panic(err)
+		}
+		steps = append(steps, system.Step{Name: installer.Name(), Run: installer.Install})
+	}
+	if len(steps) == 0 {
+		fmt.Println("The project has no requirements.txt, lockfile or package.json to install.")
+		return
+	}
+
+	fmt.Println("Installing dependencies...")
+	ctx, stop := system.InterruptContext()
+	err = (&system.Runner{Dir: cwd, Retry: &system.InstallRetry}).RunParallel(ctx, steps, 0)
+	stop()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// pythonDependenciesStep returns the step that installs the Python
+// dependencies of the project in dir with the overlay of hardware, or of the
+// detected hardware if it is empty, see python.DependenciesStep, and says
+// which overlay it installs.
+func pythonDependenciesStep(dir string, hardware string) (system.Step, bool, error) {
+	var overlay *python.Overlay
+	var err error
+	if hardware == "" {
+		overlay, err = python.DetectOverlay(dir)
+	} else {
+		overlay, err = python.ProjectOverlay(dir, hardware)
+		if overlay != nil {
+			overlay.Description = "--hardware " + hardware
+		}
+	}
+	if err != nil {
+		return system.Step{}, false, err
+	}
+	if overlay != nil {
+		fmt.Printf("Installing the %s dependency overlay (%s).\n", overlay.Hardware, overlay.Description)
+	}
+	return python.DependenciesStep(dir, overlay)
+}
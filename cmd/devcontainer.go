@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/docker"
+	"langforge/project"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var devcontainerCmd = &cobra.Command{
+	Use:   "devcontainer",
+	Short: "Generate a VS Code development container for your application",
+	Long: `The devcontainer command generates .devcontainer/devcontainer.json and a
+Dockerfile, so that VS Code users work on your application in a reproducible
+environment with "Dev Containers: Reopen in Container".
+
+The container has the Python version of the project environment and
+langforge, with the Python and Jupyter extensions of VS Code and .venv as the
+interpreter of notebooks. When it is created, the dependencies are installed
+into .venv with the tool of the project: pip from requirements.txt, or poetry,
+uv or pipenv if the project has their lockfile. Projects with a package.json
+also get Node.js, and LangChain.js applications are based on the Node.js
+image of their version instead. The ports of 'langforge serve' and of
+JupyterLab are forwarded.
+
+The .env file stays in the workspace, where langforge reads it. The changes
+to files that you edited are shown, and you choose which of them to apply,
+unless --force applies all of them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := cmd.Flags().GetString("version")
+		if err != nil {
+			panic(err)
+		}
+		generateDevcontainerCmd(version, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devcontainerCmd)
+	devcontainerCmd.Flags().String("version", "", "Python or Node.js version of the container (default: that of the project environment)")
+	devcontainerCmd.Flags().Bool("force", false, "overwrite changed files without asking")
+}
+
+func generateDevcontainerCmd(version string, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	devcontainer := docker.NewDevcontainer(cwd, config, version)
+	files, err := docker.GenerateDevcontainer(devcontainer)
+	if err != nil {
+		panic(err)
+	}
+
+	err = os.MkdirAll(filepath.Join(cwd, docker.DevcontainerDir), 0755)
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range docker.DevcontainerFileNames {
+		path := filepath.Join(docker.DevcontainerDir, name)
+		data, ok := reviewOverwrite(path, files[name], force)
+		if !ok {
+			continue
+		}
+		err = os.WriteFile(filepath.Join(cwd, path), data, 0644)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Wrote %s\n", filepath.ToSlash(path))
+	}
+	fmt.Printf("The container has %s %s. Open the project in it with \"Dev Containers: Reopen in Container\" in VS Code.\n", devcontainer.Runtime, devcontainer.Version)
+}
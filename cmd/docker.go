@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/docker"
+	"langforge/project"
+	"langforge/python"
+	"langforge/shim"
+	"langforge/system"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var dockerCmd = &cobra.Command{
+	Use:   "docker [notebook.ipynb | app.ts]",
+	Short: "Generate a Dockerfile and docker-compose.yml to ship your application",
+	Long: `The docker command generates a multi-stage Dockerfile, a docker-compose.yml and
+a .dockerignore that package your application with langforge, which serves its
+chains in the container like 'langforge serve'. It serves the notebook or
+module of the first chain of langforge.yaml unless another one is given.
+
+The image is based on the official Python image of the version of the project
+environment, or on the Node.js image for JavaScript and TypeScript modules.
+The dependencies are installed in a build stage with the tool of the project:
+pip from requirements.txt, or poetry, uv or pipenv if the project has their
+lockfile, and the package manager of package.json for Node.js. They are copied
+before the rest of the project and installed with a cache mount, so changes
+of the code do not reinstall them. If a pip project has no requirements.txt,
+the installed packages are pinned in one.
+
+The .env file is not copied into the image; docker compose passes it to the
+container. The state of langforge, e.g. analytics and an embedded vector
+store, is kept in a volume. Build and start the application with:
+
+  docker compose up --build
+
+The changes to files that you edited are shown, and you choose which of them
+to apply, unless --force applies all of them.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		port, err := cmd.Flags().GetInt("port")
+		if err != nil {
+			panic(err)
+		}
+		version, err := cmd.Flags().GetString("version")
+		if err != nil {
+			panic(err)
+		}
+		options := docker.Options{Port: port, Version: version}
+		if len(args) > 0 {
+			options.Entry = args[0]
+		}
+		generateDockerCmd(options, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dockerCmd)
+	dockerCmd.Flags().Int("port", docker.DefaultPort, "port that the container serves the application on")
+	dockerCmd.Flags().String("version", "", "Python or Node.js version of the base image (default: that of the project environment)")
+	dockerCmd.Flags().Bool("force", false, "overwrite changed files without asking")
+}
+
+func generateDockerCmd(options docker.Options, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	manager, _ := system.DetectPythonProjectManager(cwd)
+	requirements := filepath.Join(cwd, "requirements.txt")
+	if options.Entry == "" && len(config.Chains) > 0 {
+		options.Entry = config.Chains[0].Notebook
+	}
+	if options.Entry != "" && !shim.IsNode(options.Entry) && manager == "" && !fileExists(requirements) {
+		err = python.WriteRequirementsTxt(requirements)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("Pinned the installed packages in requirements.txt.")
+	}
+
+	spec, err := docker.NewSpec(cwd, config, options)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	files, err := docker.Generate(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range docker.FileNames {
+		data, ok := reviewOverwrite(name, files[name], force)
+		if !ok {
+			continue
+		}
+		err = os.WriteFile(filepath.Join(cwd, name), data, 0644)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Wrote %s\n", name)
+	}
+	fmt.Printf("The image serves %s with %s %s on port %d. Start it with 'docker compose up --build'.\n", spec.Entry, spec.Runtime, spec.Version, spec.Port)
+}
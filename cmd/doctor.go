@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/state"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the tools, network access and disk space that langforge needs",
+	Long: `The doctor command checks the Python interpreter and its venv module, pip
+or uv, Node.js and npm, access to PyPI and npm through the proxy settings of
+your environment, and the free space and permissions of the current
+directory, the langforge directory (LANGFORGE_HOME) and the temporary
+directory. Problems come with hints on how to fix them.
+
+--json prints the report as JSON, e.g. to attach it to a bug report. The
+command exits with status 1 if a check failed, so that CI jobs can run it
+before installing; warnings, such as a missing Node.js, do not fail it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			panic(err)
+		}
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			panic(err)
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			panic(err)
+		}
+		doctorAppCmd(asJSON, offline, timeout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("json", false, "print the report as JSON")
+	doctorCmd.Flags().Bool("offline", false, "skip the checks of PyPI and npm")
+	doctorCmd.Flags().Duration("timeout", 10*time.Second, "timeout for each request to PyPI and npm")
+}
+
+func doctorAppCmd(asJSON bool, offline bool, timeout time.Duration) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	paths := []string{}
+	candidates := []string{cwd, os.TempDir()}
+	if globalDir, err := state.GlobalDir(); err == nil {
+		candidates = []string{cwd, globalDir, os.TempDir()}
+	}
+	for _, path := range candidates {
+		path = filepath.Clean(path)
+		duplicate := false
+		for _, p := range paths {
+			duplicate = duplicate || p == path
+		}
+		if !duplicate {
+			paths = append(paths, path)
+		}
+	}
+
+	// the project's .env may override the URLs of the registries
+	env, err := system.GetEnv(cwd)
+	if err != nil {
+		env = map[string]string{}
+	}
+
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	report := system.Diagnose(ctx, system.DiagnoseOptions{Paths: paths, Offline: offline, Timeout: timeout, Env: env})
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printDiagnoseReport(report)
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func printDiagnoseReport(report *system.DiagnoseReport) {
+	fmt.Printf("System: %s (%s/%s)\n", report.Platform, report.OS, report.Arch)
+	tui.EmptyLine()
+
+	rows := [][]string{}
+	problems := []system.Diagnostic{}
+	for _, d := range report.Diagnostics {
+		detail := d.Detail
+		if d.Problem != "" {
+			detail = d.Problem
+			problems = append(problems, d)
+		}
+		rows = append(rows, []string{d.Name, strings.ToUpper(d.Status), detail})
+	}
+	if err := tui.PrintTable([]string{"Check", "Status", "Detail"}, rows); err != nil {
+		panic(err)
+	}
+
+	if len(problems) == 0 {
+		tui.EmptyLine()
+		fmt.Println("No problems found.")
+		return
+	}
+	tui.EmptyLine()
+	for _, d := range problems {
+		fmt.Printf("%s: %s\n", d.Name, d.Problem)
+		if d.Hint != "" {
+			fmt.Printf("  %s\n", d.Hint)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/envfile"
+	"langforge/python"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Show the variables of the .env file with their secrets masked",
+	Long: `The env command lists the variables of the .env file of the project. The
+values of secrets, variables whose names end in KEY, TOKEN, SECRET or
+PASSWORD and values in the format of well-known API keys, are masked.
+
+langforge passes the variables of the .env file to the processes of the
+project, such as workers, ingest scripts and tests, rather than setting them
+in its own environment, and masks their secrets in the output of the
+processes and in the logs of scheduled tasks. Variables of the environment
+take precedence over the .env file.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		showEnvCmd()
+	},
+}
+
+var envCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the variables of the .env file",
+	Long: `The check command reports the API keys of the installed integrations that
+are not set in the .env file, values that are placeholders such as
+"your-api-key", values with surrounding whitespace or quotes and API keys
+that do not have the format of their provider, e.g. an OPENAI_API_KEY that
+does not start with sk-. It exits with status 1 if there are problems.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkEnvCmd()
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envCheckCmd)
+	rootCmd.AddCommand(envCmd)
+}
+
+func showEnvCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	env, err := envfile.Load(cwd)
+	if err != nil {
+		panic(err)
+	}
+	if len(env) == 0 {
+		fmt.Println("The project has no variables in .env.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, key := range env.Keys() {
+		value := env[key]
+		if envfile.IsSecret(key, value) && value != "" {
+			value = envfile.MaskValue(value)
+		}
+		if _, ok := os.LookupEnv(key); ok {
+			value += " (overridden by the environment)"
+		}
+		rows = append(rows, []string{key, value})
+	}
+	if err := tui.PrintTable([]string{"Variable", "Value"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func checkEnvCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	env, err := envfile.Load(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	// the API keys of Python integrations do not apply to Node projects
+	required := []string{}
+	if _, err := os.Stat(filepath.Join(cwd, "package.json")); os.IsNotExist(err) {
+		handler := python.NewPythonHandler(cwd)
+		if err := handler.DetermineInstalledIntegrations(); err == nil {
+			required = handler.InstalledIntegrationsApiKeys()
+		}
+	}
+
+	problems := envfile.Validate(env, required)
+	if len(problems) == 0 {
+		fmt.Println("The .env file has no problems.")
+		return
+	}
+	for _, problem := range problems {
+		fmt.Printf("  ✗ %s\n", problem)
+	}
+	fmt.Println("Set the variables in .env or run 'langforge keys'.")
+	os.Exit(1)
+}
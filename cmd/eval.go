@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/cassette"
+	"langforge/client"
+	"langforge/dataset"
+	"langforge/diff"
+	"langforge/eval"
+	"langforge/gateway"
+	"langforge/project"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [name]",
+	Short: "Evaluate a chain against a dataset",
+	Long: `The eval command runs a chain of your served LangChain application against
+a JSONL or CSV dataset and scores the outputs with the scorers of an
+evaluation in langforge.yaml: exact, contains, regex or llm, which asks a
+model of OpenAI, Anthropic or Google Gemini. The command exits with a non-zero
+status if the pass rate is below the threshold, so it can be used in CI.
+
+The application has to be running, e.g. with 'langforge serve'. If the
+gateway requires an API key, it is read from LANGFORGE_API_KEY.
+
+--record-llm and --replay-llm record and replay the requests of the llm
+scorer, --baseline compares the run with a baseline recorded with
+--update-baseline, and --temperature and --seed fix the sampling of the chain,
+which the gateway only accepts with an admin API key.
+
+The reference of evaluations, with examples of the evals section of
+langforge.yaml, is docs/eval.md in the LangForge repository:
+https://github.com/mme/langforge/blob/main/docs/eval.md`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		runEvalCmd(cmd, name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+	evalCmd.Flags().String("url", client.DefaultURL, "URL of the served LangChain application")
+	evalCmd.Flags().String("preset", "", "preset of langforge.yaml that the chain is invoked with (overrides langforge.yaml)")
+	evalCmd.Flags().Float64("temperature", 0, "temperature of the LLMs of the chain (overrides langforge.yaml)")
+	evalCmd.Flags().Int("seed", 0, "seed of the LLMs of the chain and the llm scorer (overrides langforge.yaml)")
+	evalCmd.Flags().String("chain", "", "chain to evaluate (overrides langforge.yaml)")
+	evalCmd.Flags().String("dataset", "", "JSONL or CSV dataset (overrides langforge.yaml)")
+	evalCmd.Flags().String("expected-key", "", "dataset field holding the expected output")
+	evalCmd.Flags().String("output-key", "", "chain output to score")
+	evalCmd.Flags().StringSlice("scorer", []string{}, "scorers to apply: exact, contains, regex, llm (overrides langforge.yaml)")
+	evalCmd.Flags().Float64("threshold", -1, "minimum pass rate between 0 and 1 (default 1)")
+	evalCmd.Flags().String("report", "", "write a JSON report to this file")
+	evalCmd.Flags().String("record-llm", "", "record the LLM requests of the scorers in this cassette")
+	evalCmd.Flags().String("replay-llm", "", "answer the LLM requests of the scorers from this cassette")
+	evalCmd.Flags().Bool("baseline", false, "compare the run with the baseline and fail on regressions")
+	evalCmd.Flags().Bool("update-baseline", false, "write the run as the new baseline")
+	evalCmd.Flags().String("baseline-file", "", "baseline to compare with or update (overrides langforge.yaml)")
+	evalCmd.Flags().Float64("tolerance", 0, "score drop per case that is not a regression")
+}
+
+func runEvalCmd(cmd *cobra.Command, name string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	evalConfig := project.EvalConfig{}
+	if name != "" {
+		found := config.FindEval(name)
+		if found == nil {
+			panic(fmt.Errorf("evaluation %q is not declared in %s", name, project.ConfigFileName))
+		}
+		evalConfig = *found
+	} else if len(config.Evals) == 1 && !cmd.Flags().Changed("chain") {
+		evalConfig = config.Evals[0]
+	}
+
+	flags := cmd.Flags()
+	if chain, _ := flags.GetString("chain"); chain != "" {
+		evalConfig.Chain = chain
+	}
+	if datasetFlag, _ := flags.GetString("dataset"); datasetFlag != "" {
+		evalConfig.Dataset = datasetFlag
+	}
+	if expectedKey, _ := flags.GetString("expected-key"); expectedKey != "" {
+		evalConfig.ExpectedKey = expectedKey
+	}
+	if outputKey, _ := flags.GetString("output-key"); outputKey != "" {
+		evalConfig.OutputKey = outputKey
+	}
+	if scorers, _ := flags.GetStringSlice("scorer"); len(scorers) > 0 {
+		evalConfig.Scorers = []project.ScorerConfig{}
+		for _, scorer := range scorers {
+			evalConfig.Scorers = append(evalConfig.Scorers, project.ScorerConfig{Type: scorer})
+		}
+	}
+	if threshold, _ := flags.GetFloat64("threshold"); threshold >= 0 {
+		evalConfig.Threshold = threshold
+	} else if evalConfig.Threshold == 0 {
+		evalConfig.Threshold = 1
+	}
+	if len(evalConfig.Scorers) == 0 {
+		evalConfig.Scorers = []project.ScorerConfig{{Type: "exact"}}
+	}
+	if baselineFile, _ := flags.GetString("baseline-file"); baselineFile != "" {
+		evalConfig.Baseline = baselineFile
+	}
+	if preset, _ := flags.GetString("preset"); preset != "" {
+		evalConfig.Preset = preset
+	}
+	if flags.Changed("temperature") {
+		temperature, _ := flags.GetFloat64("temperature")
+		evalConfig.Temperature = &temperature
+	}
+	if flags.Changed("seed") {
+		seed, _ := flags.GetInt("seed")
+		evalConfig.Seed = &seed
+	}
+	if evalConfig.Temperature != nil && *evalConfig.Temperature < 0 {
+		panic(fmt.Errorf("the temperature must not be negative"))
+	}
+
+	if evalConfig.Chain == "" || evalConfig.Dataset == "" {
+		panic(fmt.Errorf("chain and dataset are required, declare an evaluation in %s or use --chain and --dataset", project.ConfigFileName))
+	}
+
+	env, err := system.GetEnv(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	record, _ := flags.GetString("record-llm")
+	replay, _ := flags.GetString("replay-llm")
+	if mode, path := llmCassetteMode(record, replay); mode != "" {
+		c, err := cassette.Open(path, mode, providerBaseURL(env))
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+		env["OPENAI_API_BASE"] = c.URL()
+		if mode == cassette.Replay && env["OPENAI_API_KEY"] == "" {
+			env["OPENAI_API_KEY"] = cassette.ReplayAPIKey
+		}
+	}
+
+	scorers, err := eval.NewScorers(evalConfig.Scorers, env, evalConfig.Seed)
+	if err != nil {
+		panic(err)
+	}
+
+	// Datasets can be referenced by their registered name or by path
+	datasetPath, err := dataset.Resolve(cwd, config, evalConfig.Dataset)
+	if err != nil {
+		panic(err)
+	}
+	examples, err := eval.LoadDataset(datasetPath)
+	if err != nil {
+		panic(err)
+	}
+
+	url, _ := flags.GetString("url")
+	fmt.Printf("Evaluating chain '%s' on %d examples from %s...\n", evalConfig.Chain, len(examples), evalConfig.Dataset)
+	tui.EmptyLine()
+
+	preset, params, err := evalGeneration(config, evalConfig)
+	if err != nil {
+		panic(err)
+	}
+	chainClient := newChainClient(url, env)
+	chainClient.SetHeader(gateway.PresetHeader, evalConfig.Preset)
+	if evalConfig.Temperature != nil || evalConfig.Seed != nil {
+		generation, err := json.Marshal(&gateway.Generation{Temperature: evalConfig.Temperature, Seed: evalConfig.Seed})
+		if err != nil {
+			panic(err)
+		}
+		chainClient.SetHeader(gateway.GenerationHeader, string(generation))
+	}
+	report := eval.Run(context.Background(), chainClient, examples, eval.Options{
+		Name:        evalConfig.Name,
+		Chain:       evalConfig.Chain,
+		Dataset:     evalConfig.Dataset,
+		ExpectedKey: evalConfig.ExpectedKey,
+		OutputKey:   evalConfig.OutputKey,
+		Scorers:     scorers,
+		Threshold:   evalConfig.Threshold,
+		Preset:      preset,
+		Params:      params,
+	})
+
+	printEvalReport(report)
+
+	if reportPath, _ := flags.GetString("report"); reportPath != "" {
+		err = report.WriteJSON(reportPath)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Report written to %s.\n", reportPath)
+	}
+
+	success := report.Success
+	baselinePath := evalConfig.Baseline
+	if baselinePath == "" {
+		name := evalConfig.Name
+		if name == "" {
+			name = evalConfig.Chain
+		}
+		baselinePath = eval.BaselinePath(cwd, name)
+	}
+	if compare, _ := flags.GetBool("baseline"); compare {
+		baseline, err := eval.LoadReport(baselinePath)
+		if os.IsNotExist(err) {
+			panic(fmt.Errorf("no baseline at %s, record one with --update-baseline", baselinePath))
+		}
+		if err != nil {
+			panic(err)
+		}
+		tolerance, _ := flags.GetFloat64("tolerance")
+		comparison := eval.Compare(baseline, report, tolerance)
+		tui.EmptyLine()
+		printBaselineComparison(baseline, report, comparison, baselinePath)
+		if len(comparison.Regressions) > 0 {
+			success = false
+		}
+	}
+
+	if update, _ := flags.GetBool("update-baseline"); update {
+		if err := os.MkdirAll(filepath.Dir(baselinePath), 0755); err != nil {
+			panic(err)
+		}
+		if err := report.WriteJSON(baselinePath); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Baseline written to %s.\n", baselinePath)
+	}
+
+	if !success {
+		os.Exit(1)
+	}
+}
+
+// evalGeneration returns the preset that the chain of an evaluation is
+// invoked with, the one of the evaluation or else the one of the chain, and
+// its generation parameters with the temperature and the seed of the
+// evaluation.
+func evalGeneration(config *project.Config, evalConfig project.EvalConfig) (string, map[string]any, error) {
+	preset := evalConfig.Preset
+	if chain := config.FindChain(evalConfig.Chain); preset == "" && chain != nil {
+		preset = chain.Preset
+	}
+	params := map[string]any{}
+	if preset != "" {
+		found := config.FindPreset(preset)
+		if found == nil {
+			return "", nil, fmt.Errorf("preset %s is not declared in %s", preset, project.ConfigFileName)
+		}
+		params = gateway.PresetParams(found)
+	}
+	generation := &gateway.Generation{Temperature: evalConfig.Temperature, Seed: evalConfig.Seed}
+	generation.Apply(params)
+	if len(params) == 0 {
+		params = nil
+	}
+	return preset, params, nil
+}
+
+func printBaselineComparison(baseline *eval.Report, report *eval.Report, comparison *eval.Comparison, path string) {
+	fmt.Printf("Comparing with the baseline %s.\n", path)
+	if baseline.Chain != report.Chain || baseline.Dataset != report.Dataset {
+		fmt.Printf("The baseline was recorded for chain '%s' on %s.\n", baseline.Chain, baseline.Dataset)
+	}
+	if len(comparison.Settings) > 0 {
+		fmt.Printf("The run is not comparable with the baseline, it used %s.\n", strings.Join(comparison.Settings, ", "))
+	}
+	tui.EmptyLine()
+
+	for _, change := range comparison.Regressions {
+		fmt.Println(tui.Bold("Regression in case #%d: %s", change.Current.Index, strings.Join(change.Reasons, ", ")))
+		fmt.Printf("Input: %s\n", truncate(caseInputs(change.Current), 100))
+		switch {
+		case change.Current.Error != "":
+			fmt.Printf("Error: %s\n", change.Current.Error)
+		case change.Baseline.Output != change.Current.Output:
+			tui.PrintPatch(diff.New("baseline", "current", change.Baseline.Output+"\n", change.Current.Output+"\n", 3))
+		default:
+			fmt.Println("The output is unchanged.")
+		}
+		tui.EmptyLine()
+	}
+
+	for _, scorer := range report.Scorers {
+		if old, ok := baseline.MeanScores[scorer]; ok {
+			fmt.Printf("Mean %s score: %.2f (baseline %.2f)\n", scorer, report.MeanScores[scorer], old)
+		}
+	}
+	fmt.Printf("%d regressions, %d improvements, %d unchanged cases", len(comparison.Regressions), len(comparison.Improvements), comparison.Unchanged)
+	if len(comparison.Added) > 0 || len(comparison.Removed) > 0 {
+		fmt.Printf(", %d new cases, %d cases of the baseline not run", len(comparison.Added), len(comparison.Removed))
+	}
+	fmt.Println(".")
+	if len(comparison.Regressions) > 0 {
+		fmt.Println(tui.Bold("Regressions against the baseline."))
+	}
+}
+
+// caseInputs returns the inputs of a case in the order of their keys.
+func caseInputs(c eval.CaseResult) string {
+	keys := []string{}
+	for key := range c.Inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	inputs := []string{}
+	for _, key := range keys {
+		inputs = append(inputs, c.Inputs[key])
+	}
+	return strings.Join(inputs, " | ")
+}
+
+func printEvalReport(report *eval.Report) {
+	header := []string{"#", "Input", "Expected", "Output"}
+	header = append(header, report.Scorers...)
+	header = append(header, "Result")
+
+	rows := [][]string{}
+	for _, c := range report.Cases {
+		row := []string{fmt.Sprint(c.Index), truncate(caseInputs(c), 30), truncate(c.Expected, 30), truncate(c.Output, 30)}
+		for _, scorer := range report.Scorers {
+			if score, ok := c.Scores[scorer]; ok {
+				row = append(row, fmt.Sprintf("%.2f", score))
+			} else {
+				row = append(row, "-")
+			}
+		}
+
+		switch {
+		case c.ErrorCode != "":
+			row = append(row, "error: "+string(c.ErrorCode))
+		case c.Error != "":
+			row = append(row, "error: "+truncate(c.Error, 40))
+		case c.Passed:
+			row = append(row, "pass")
+		default:
+			row = append(row, "fail")
+		}
+		rows = append(rows, row)
+	}
+
+	if err := tui.PrintTable(header, rows); err != nil {
+		panic(err)
+	}
+	tui.EmptyLine()
+
+	for _, scorer := range report.Scorers {
+		fmt.Printf("Mean %s score: %.2f\n", scorer, report.MeanScores[scorer])
+	}
+	fmt.Printf("Passed %d of %d cases (%.0f%%, threshold %.0f%%).\n", report.Passed, report.Passed+report.Failed, report.PassRate*100, report.Threshold*100)
+
+	if report.Success {
+		fmt.Println(tui.Bold("Evaluation passed."))
+	} else {
+		fmt.Println(tui.Bold("Evaluation failed."))
+	}
+}
+
+// newChainClient returns a client for the application served at url, which
+// authenticates with LANGFORGE_API_KEY from the environment or .env.
+func newChainClient(url string, env map[string]string) *client.Client {
+	chainClient := client.New(url)
+	if apiKey := os.Getenv("LANGFORGE_API_KEY"); apiKey != "" {
+		chainClient.SetAPIKey(apiKey)
+	} else {
+		chainClient.SetAPIKey(env["LANGFORGE_API_KEY"])
+	}
+	return chainClient
+}
+
+// truncate shortens text to at most n runes and collapses it to a single line.
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/permission"
+	"langforge/project"
+	"langforge/python"
+	"langforge/system"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [archive.tar.gz]",
+	Short: "Export your LangChain application as a portable archive",
+	Long: `The export command writes your LangChain application to a portable archive.
+
+The archive contains your code, notebooks, langforge.yaml and a freshly pinned
+requirements.txt in place of that of the project, which is left unchanged,
+together with a manifest describing the Python version and platform it was
+created with. Use 'langforge import' to restore it on another machine.
+
+The .env file is only included when --include-env is given, since it usually
+contains secrets. The other files are scanned for secrets first (see
+'langforge scan secrets'). Exporting secrets and overwriting an existing archive
+require confirmation, which --force skips (see 'langforge policy').`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		includeEnv, err := cmd.Flags().GetBool("include-env")
+		if err != nil {
+			panic(err)
+		}
+		exclude, err := cmd.Flags().GetStringSlice("exclude")
+		if err != nil {
+			panic(err)
+		}
+		archivePath := ""
+		if len(args) > 0 {
+			archivePath = args[0]
+		}
+		exportAppCmd(archivePath, project.ArchiveOptions{
+			IncludeEnv: includeEnv,
+			Exclude:    exclude,
+		}, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().Bool("include-env", false, "include the .env file (and its secrets) in the archive")
+	exportCmd.Flags().StringSlice("exclude", []string{}, "additional file patterns to leave out of the archive")
+	exportCmd.Flags().Bool("force", false, "do not ask for confirmation")
+}
+
+func exportAppCmd(archivePath string, options project.ArchiveOptions, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	if archivePath == "" {
+		archivePath = config.Name + ".tar.gz"
+	}
+
+	if options.IncludeEnv && !confirmOperation(permission.ExportSecrets, "The archive will contain the secrets of .env. Continue?", force) {
+		return
+	}
+	if _, err := os.Stat(archivePath); err == nil && !confirmOperation(permission.OverwriteFile, fmt.Sprintf("Overwrite %s?", archivePath), force) {
+		return
+	}
+	if !confirmNoSecrets(cwd, force) {
+		return
+	}
+
+	// Pin the currently installed packages in the archive, the requirements.txt
+	// of the project stays as it is
+	requirements, err := python.FreezeRequirements()
+	if err != nil {
+		panic(err)
+	}
+	options.Contents = map[string][]byte{"requirements.txt": requirements}
+
+	handler := python.NewPythonHandler(cwd)
+	err = handler.DetermineInstalledIntegrations()
+	if err != nil {
+		panic(err)
+	}
+
+	manifest := &project.Manifest{
+		Name:     config.Name,
+		Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		ApiKeys:  handler.InstalledIntegrationsApiKeys(),
+	}
+
+	if pythonPath, err := system.FindPython(); err == nil {
+		manifest.Python, err = system.PythonVersion(pythonPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = project.WriteArchive(cwd, archivePath, manifest, options)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Exported %d files to %s.\n", manifest.ExportedFiles, archivePath)
+	if !options.IncludeEnv && len(manifest.ApiKeys) > 0 {
+		fmt.Println("API keys were not exported. Use 'langforge keys' to set them after importing.")
+	}
+}
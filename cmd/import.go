@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"langforge/shim"
+	"langforge/system"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [archive.tar.gz] [app-name]",
+	Short: "Import a LangChain application from an archive",
+	Long: `The import command reconstructs a LangChain application from an archive
+created with 'langforge export'.
+
+It extracts the project, creates a virtual environment, installs the pinned
+requirements and, at the same time, the JavaScript dependencies of a
+package.json with the package manager of the project, and prepares the .env
+file with the API keys the project needs. Lockfiles are honored: projects
+with a poetry.lock, uv.lock or Pipfile.lock are installed with poetry, uv or
+pipenv instead of pip, and a package-lock.json, yarn.lock or pnpm-lock.yaml
+is installed as is, like 'npm ci'.
+With --conda, it creates a conda environment in .conda instead, with the
+Python version the project was exported with.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("archive is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		noVenv, err := cmd.Flags().GetBool("no-venv")
+		if err != nil {
+			panic(err)
+		}
+		appName := ""
+		if len(args) > 1 {
+			appName = args[1]
+		}
+		conda, err := cmd.Flags().GetBool("conda")
+		if err != nil {
+			panic(err)
+		}
+		importAppCmd(args[0], appName, !noVenv, conda)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	markProjectIndependent(importCmd)
+	importCmd.Flags().Bool("no-venv", false, "install dependencies in the current environment instead of a new virtual environment")
+	importCmd.Flags().Bool("conda", false, "create a conda environment instead of a virtual environment")
+}
+
+func importAppCmd(archivePath string, appName string, createEnvironment bool, conda bool) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	if appName == "" {
+		appName = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(archivePath), ".gz"), ".tar")
+	}
+
+	dir := filepath.Join(currentDir, appName)
+	if _, err := os.Stat(dir); err == nil {
+		panic(fmt.Errorf("file with name '%s' already exists", dir))
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		panic(err)
+	}
+
+	manifest, err := project.ExtractArchive(archivePath, dir)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Extracted '%s' to %s.\n", manifest.Name, dir)
+
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	if manifest.Platform != "" && manifest.Platform != platform {
+		fmt.Printf("Note: the project was exported on %s, this machine is %s.\n", manifest.Platform, platform)
+	}
+
+	if createEnvironment && conda {
+		fmt.Println("Creating conda environment...")
+		envDir := filepath.Join(dir, python.CondaEnvName)
+		if err := python.CreateCondaEnv(envDir, majorMinor(manifest.Python)); err != nil {
+			panic(err)
+		}
+		if err := python.ActivateCondaEnv(envDir); err != nil {
+			panic(err)
+		}
+	} else if createEnvironment {
+		fmt.Println("Creating virtual environment...")
+		if err := python.CreateVirtualEnv(".venv", dir); err != nil {
+			panic(err)
+		}
+		if err := python.ActivateEnvironment(".venv", dir); err != nil {
+			panic(err)
+		}
+	}
+
+	if manifest.Python != "" {
+		if pythonPath, err := system.FindPython(); err == nil {
+			version, err := system.PythonVersion(pythonPath)
+			if err == nil && majorMinor(version) != majorMinor(manifest.Python) {
+				fmt.Printf("Warning: the project was exported with Python %s, but Python %s will be used.\n", manifest.Python, version)
+			}
+		}
+	}
+
+	// the Python and Node.js dependencies are installed at the same time
+	steps := []system.Step{}
+	if step, ok, err := pythonDependenciesStep(dir, ""); err != nil {
+		fmt.Printf("The Python dependencies are not installed: %v.\n", err)
+	} else if ok {
+		steps = append(steps, step)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+		if installer, err := system.FindNodePackageManager(dir); err != nil {
+			fmt.Printf("The dependencies of package.json are not installed: %v.\n", err)
+		} else {
+			steps = append(steps, system.Step{
+				Name: installer.Name(),
+				Run:  installer.Install,
+			})
+		}
+	}
+	if len(steps) > 0 {
+		fmt.Println("Installing dependencies...")
+		ctx, stop := system.InterruptContext()
+		err := (&system.Runner{Dir: dir, Retry: &system.InstallRetry}).RunParallel(ctx, steps, 0)
+		stop()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if err := shim.Python.Generate(dir); err != nil {
+		panic(err)
+	}
+
+	if len(manifest.ApiKeys) > 0 {
+		dotEnvPath := filepath.Join(dir, ".env")
+		if err := system.EnsureEnv(dotEnvPath, manifest.ApiKeys); err != nil {
+			panic(err)
+		}
+
+		unsetKeys, err := system.UnsetAPIKeys(dotEnvPath, manifest.ApiKeys)
+		if err != nil {
+			panic(err)
+		}
+		if len(unsetKeys) > 0 {
+			fmt.Println("The following API keys are not set yet:", unsetKeys)
+			fmt.Println("Run 'langforge keys' in the project directory to set them.")
+		}
+	}
+
+	fmt.Printf("Successfully imported 🦜️🔗LangChain application '%s'.\n", appName)
+}
+
+// majorMinor returns the major and minor part of a version string, e.g. "3.11" for "3.11.4".
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
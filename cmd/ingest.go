@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/dataset"
+	"langforge/eval"
+	"langforge/project"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/vectorstore"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var ingestCmd = &cobra.Command{
+	Use:   "ingest",
+	Short: "Ingest the documents of the ingestion sources into the vector store",
+	Long: `The ingest command loads the documents of the sources declared in
+vectorstore.ingestion of langforge.yaml, splits them into chunks, embeds the
+chunks and writes them to the vector store of the project, without an ingest
+script. Sources are directories of the project, prefixes of S3, Google Cloud
+Storage and Azure Blob Storage, and Confluence, Notion and Google Drive.
+
+Only documents that changed since they were ingested are ingested again, and
+the chunks of removed documents are deleted; --full ingests all documents.
+Documents that cannot be loaded are reported, and the command then exits with
+status 1.
+
+The reference of the ingestion, with examples of every source, is
+docs/ingest.md in the LangForge repository:
+https://github.com/mme/langforge/blob/main/docs/ingest.md`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sources, err := cmd.Flags().GetStringSlice("source")
+		if err != nil {
+			panic(err)
+		}
+		full, err := cmd.Flags().GetBool("full")
+		if err != nil {
+			panic(err)
+		}
+		ingestDocumentsCmd(sources, full)
+	},
+}
+
+var ingestTuneCmd = &cobra.Command{
+	Use:   "tune",
+	Short: "Benchmark the retrieval of chunking strategies and sizes",
+	Long: `The tune command compares chunking configurations of the ingestion on the
+questions of a dataset: the documents of the sources in the project are
+split with each combination of strategies, chunk sizes and overlaps, the
+chunks are embedded with the embedding model of the ingestion and the k
+chunks most similar to each question are retrieved. The vector store is not
+changed; the embeddings cache, if set, saves embedding the same chunks again.
+
+  vectorstore:
+    ingestion:
+      tune:
+        eval: qa               # the dataset of this evaluation, or
+        dataset: data/qa.jsonl # a dataset by path or registered name
+        questionKey: question
+        strategies: [recursive, markdown] # also character and token
+        chunkSizes: [500, 1000, 2000]     # tokens for token
+        chunkOverlaps: [0, 100]
+        k: 5
+
+A retrieved chunk is relevant if its document is in the "sources" field of
+the example, a list of paths in the project, or otherwise if it contains the
+expected answer. The hit rate is the share of questions with a relevant chunk
+among the k, MRR the mean reciprocal rank of the first relevant chunk and
+recall the share of the relevant documents that were retrieved. The best
+configuration by MRR is suggested for langforge.yaml.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tuneIngestionCmd(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ingestCmd)
+	ingestCmd.Flags().StringSlice("source", nil, "only ingest the sources with these names")
+	ingestCmd.Flags().Bool("full", false, "ingest all documents, also unchanged ones")
+
+	ingestCmd.AddCommand(ingestTuneCmd)
+	ingestTuneCmd.Flags().StringSlice("source", nil, "only chunk the documents of the sources with these names")
+	ingestTuneCmd.Flags().String("eval", "", "use the dataset of this evaluation")
+	ingestTuneCmd.Flags().String("dataset", "", "the dataset of questions, by path or registered name")
+	ingestTuneCmd.Flags().String("question-key", "", "the field of the questions in the dataset")
+	ingestTuneCmd.Flags().StringSlice("strategy", nil, "the chunking strategies: recursive, character, markdown or token")
+	ingestTuneCmd.Flags().IntSlice("chunk-size", nil, "the chunk sizes to compare")
+	ingestTuneCmd.Flags().IntSlice("chunk-overlap", nil, "the chunk overlaps to compare")
+	ingestTuneCmd.Flags().Int("k", 0, "the number of chunks retrieved per question (default 5)")
+	ingestTuneCmd.Flags().Int("limit", 100, "the maximum number of questions, 0 for all")
+}
+
+func ingestDocumentsCmd(sources []string, full bool) {
+	cwd, _, store := loadVectorStore()
+	if !vectorstore.HasPipeline(store.Config()) {
+		fmt.Println("No ingestion sources configured. Declare them in vectorstore.ingestion of langforge.yaml, see 'langforge ingest --help'.")
+		os.Exit(1)
+	}
+
+	if err := store.Init(); err != nil {
+		panic(err)
+	}
+	runIngestion(cwd, store, vectorstore.IngestOptions{Sources: sources, Full: full})
+}
+
+// runIngestion runs the ingestion pipeline, printing each document, and
+// prints a summary per source.
+func runIngestion(dir string, store vectorstore.Store, options vectorstore.IngestOptions) {
+	ctx, stop := system.InterruptContext()
+	defer stop()
+
+	options.Progress = func(source string, document string, chunks int, deleted bool) {
+		if deleted {
+			fmt.Printf("  %s: removed\n", document)
+			return
+		}
+		if chunks == 1 {
+			fmt.Printf("  %s: 1 chunk\n", document)
+			return
+		}
+		fmt.Printf("  %s: %d chunks\n", document, chunks)
+	}
+	options.Failure = func(source string, document string, message string) {
+		fmt.Printf("  %s: failed\n", document)
+	}
+	results, err := vectorstore.Ingest(ctx, dir, store, options)
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted, run the same command again to continue.")
+			os.Exit(1)
+		}
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	rows := [][]string{}
+	failed := 0
+	for _, result := range results {
+		rows = append(rows, []string{result.Source, fmt.Sprint(result.Ingested), fmt.Sprint(result.Deleted),
+			fmt.Sprint(result.Unchanged), fmt.Sprint(len(result.Failed)), fmt.Sprint(result.Chunks)})
+		failed += len(result.Failed)
+	}
+	err = tui.PrintTable([]string{"Source", "Ingested", "Removed", "Unchanged", "Failed", "Chunks"}, rows)
+	if err != nil {
+		panic(err)
+	}
+	if failed == 0 {
+		return
+	}
+
+	tui.EmptyLine()
+	fmt.Println("Documents that could not be loaded:")
+	for _, result := range results {
+		for _, failure := range result.Failed {
+			fmt.Printf("  ✗ %s: %s\n", failure.Document, failure.Message)
+		}
+	}
+	fmt.Println("They keep their previous chunks and are ingested again in the next run.")
+	os.Exit(1)
+}
+
+func tuneIngestionCmd(cmd *cobra.Command) {
+	cwd, config, store := loadVectorStore()
+	if !vectorstore.HasPipeline(store.Config()) {
+		fmt.Println("No ingestion sources configured. Declare them in vectorstore.ingestion of langforge.yaml, see 'langforge ingest --help'.")
+		os.Exit(1)
+	}
+
+	tune := project.TuneConfig{}
+	if store.Config().Ingestion.Tune != nil {
+		tune = *store.Config().Ingestion.Tune
+	}
+	flags := cmd.Flags()
+	if evalName, _ := flags.GetString("eval"); evalName != "" {
+		tune.Eval, tune.Dataset = evalName, ""
+	}
+	if datasetFlag, _ := flags.GetString("dataset"); datasetFlag != "" {
+		tune.Dataset = datasetFlag
+	}
+	if questionKey, _ := flags.GetString("question-key"); questionKey != "" {
+		tune.QuestionKey = questionKey
+	}
+	if strategies, _ := flags.GetStringSlice("strategy"); len(strategies) > 0 {
+		tune.Strategies = strategies
+	}
+	if sizes, _ := flags.GetIntSlice("chunk-size"); len(sizes) > 0 {
+		tune.ChunkSizes = sizes
+	}
+	if overlaps, _ := flags.GetIntSlice("chunk-overlap"); len(overlaps) > 0 {
+		tune.ChunkOverlaps = overlaps
+	}
+	if k, _ := flags.GetInt("k"); k > 0 {
+		tune.K = k
+	}
+
+	// the dataset of the evaluation, or of the only one
+	expectedKey := eval.DefaultExpectedKey
+	evalConfig := config.FindEval(tune.Eval)
+	if tune.Eval != "" && evalConfig == nil {
+		panic(fmt.Errorf("evaluation %q is not declared in %s", tune.Eval, project.ConfigFileName))
+	}
+	if evalConfig == nil && tune.Dataset == "" && len(config.Evals) == 1 {
+		evalConfig = &config.Evals[0]
+	}
+	if evalConfig != nil {
+		if tune.Dataset == "" {
+			tune.Dataset = evalConfig.Dataset
+		}
+		if evalConfig.ExpectedKey != "" {
+			expectedKey = evalConfig.ExpectedKey
+		}
+	}
+	if tune.Dataset == "" {
+		fmt.Println("No dataset of questions. Use --dataset or --eval, or set vectorstore.ingestion.tune.dataset in langforge.yaml.")
+		os.Exit(1)
+	}
+	datasetPath, err := dataset.Resolve(cwd, config, tune.Dataset)
+	if err != nil {
+		panic(err)
+	}
+	loaded, err := eval.LoadDataset(datasetPath)
+	if err != nil {
+		panic(err)
+	}
+	examples, err := tuneExamples(loaded, tune.QuestionKey, expectedKey)
+	if err != nil {
+		panic(err)
+	}
+	if skipped := len(loaded) - len(examples); skipped > 0 {
+		fmt.Printf("Skipping %d examples without a question or an expected answer or sources.\n", skipped)
+	}
+	if limit, _ := flags.GetInt("limit"); limit > 0 && len(examples) > limit {
+		examples = examples[:limit]
+	}
+	if len(examples) == 0 {
+		fmt.Printf("%s has no questions with an expected answer or sources.\n", tune.Dataset)
+		os.Exit(1)
+	}
+	sources, _ := flags.GetStringSlice("source")
+
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	fmt.Printf("Benchmarking chunking configurations on %d questions from %s...\n", len(examples), tune.Dataset)
+	results, err := vectorstore.Tune(ctx, cwd, store.Config(), vectorstore.TuneOptions{
+		Sources:       sources,
+		Strategies:    tune.Strategies,
+		ChunkSizes:    tune.ChunkSizes,
+		ChunkOverlaps: tune.ChunkOverlaps,
+		K:             tune.K,
+		Examples:      examples,
+		Progress: func(result vectorstore.TuneResult) {
+			fmt.Printf("  %s, %d/%d: %d chunks\n", result.Strategy, result.ChunkSize, result.ChunkOverlap, result.Chunks)
+		},
+		Failure: func(source string, document string, message string) {
+			fmt.Printf("  %s: failed: %s\n", document, message)
+		},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted.")
+			os.Exit(1)
+		}
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	rows := [][]string{}
+	for _, result := range results {
+		rows = append(rows, []string{result.Strategy, fmt.Sprint(result.ChunkSize), fmt.Sprint(result.ChunkOverlap),
+			fmt.Sprint(result.Chunks), fmt.Sprintf("%.2f", result.HitRate), fmt.Sprintf("%.3f", result.MRR), fmt.Sprintf("%.2f", result.Recall)})
+	}
+	if err := tui.PrintTable([]string{"Strategy", "Chunk size", "Overlap", "Chunks", "Hit rate", "MRR", "Recall"}, rows); err != nil {
+		panic(err)
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	// the best by MRR, then hit rate, then the fewest chunks, which are the
+	// cheapest to embed and store
+	best := append([]vectorstore.TuneResult{}, results...)
+	sort.SliceStable(best, func(i, j int) bool {
+		if best[i].MRR != best[j].MRR {
+			return best[i].MRR > best[j].MRR
+		}
+		if best[i].HitRate != best[j].HitRate {
+			return best[i].HitRate > best[j].HitRate
+		}
+		return best[i].Chunks < best[j].Chunks
+	})
+	tui.EmptyLine()
+	fmt.Printf("The best configuration is %s chunks of %d with an overlap of %d. Set it in langforge.yaml:\n\n", best[0].Strategy, best[0].ChunkSize, best[0].ChunkOverlap)
+	fmt.Printf("  vectorstore:\n    ingestion:\n      chunkSize: %d\n      chunkOverlap: %d\n", best[0].ChunkSize, best[0].ChunkOverlap)
+	if best[0].Strategy != "recursive" {
+		fmt.Println("\nThe ingestion splits with the recursive strategy; use the other strategy in an ingest script.")
+	}
+}
+
+// tuneExamples returns the questions of a dataset for the benchmark, with
+// their expected answers and the documents in their "sources" field, a JSON
+// list or paths separated by commas. Without a question key, the question is
+// the only other field or the question, input or query field. Examples
+// without a question, an expected answer or sources are left out.
+func tuneExamples(examples []eval.Example, questionKey string, expectedKey string) ([]vectorstore.TuneExample, error) {
+	result := []vectorstore.TuneExample{}
+	for _, example := range examples {
+		key := questionKey
+		if key == "" {
+			others := []string{}
+			for field := range example.Fields {
+				if field != expectedKey && field != "sources" {
+					others = append(others, field)
+				}
+			}
+			if len(others) == 1 {
+				key = others[0]
+			}
+			for _, candidate := range []string{"question", "input", "query"} {
+				if _, ok := example.Fields[candidate]; ok && key == "" {
+					key = candidate
+				}
+			}
+			if key == "" {
+				return nil, fmt.Errorf("example %d has no question field, set it with --question-key", example.Index)
+			}
+		}
+
+		sources := []string{}
+		if value := strings.TrimSpace(example.Fields["sources"]); strings.HasPrefix(value, "[") {
+			if err := json.Unmarshal([]byte(value), &sources); err != nil {
+				return nil, fmt.Errorf("the sources of example %d are not a list of paths: %v", example.Index, err)
+			}
+		} else if value != "" {
+			sources = strings.Split(value, ",")
+		}
+		paths := []string{}
+		for _, source := range sources {
+			if source = strings.TrimPrefix(strings.TrimSpace(source), "./"); source != "" {
+				paths = append(paths, source)
+			}
+		}
+
+		question := strings.TrimSpace(example.Fields[key])
+		expected := strings.TrimSpace(example.Fields[expectedKey])
+		if question == "" || (expected == "" && len(paths) == 0) {
+			continue
+		}
+		result = append(result, vectorstore.TuneExample{Question: question, Expected: expected, Sources: paths})
+	}
+	return result, nil
+}
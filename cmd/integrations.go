@@ -5,7 +5,6 @@ import (
 	"langforge/python"
 	"langforge/tui"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -31,16 +30,8 @@ func editIntegrations() {
 		return
 	}
 
-	venvDir := filepath.Join(cwd, ".venv")
-	if _, err := os.Stat(venvDir); err == nil {
-		// Activate the virtual environment
-		err = python.ActivateEnvironment(venvDir)
-		if err != nil {
-			fmt.Println("Error activating virtual environment:", err)
-			return
-		}
-	} else {
-		fmt.Println("No virtual environment found. Continuing in the current environment.")
+	if !activateProjectEnvironment(cwd) {
+		return
 	}
 
 	handler := python.NewPythonHandler(cwd)
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"langforge/jobs"
+	"langforge/processes"
+	"langforge/tui"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Inspect and resume long-running jobs",
+	Long: `The jobs command lists the long-running jobs of your LangChain application,
+such as bulk document ingestion, shows their logs and resumes jobs that failed
+or were interrupted. Jobs are kept in the project's state store (see
+'langforge state') and their logs in .langforge/jobs.`,
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the jobs of the project",
+	Run: func(cmd *cobra.Command, args []string) {
+		listJobsCmd()
+	},
+}
+
+var jobsLogsCmd = &cobra.Command{
+	Use:   "logs [id]",
+	Short: "Print the log of a job",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("job id is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		follow, err := cmd.Flags().GetBool("follow")
+		if err != nil {
+			panic(err)
+		}
+		jobLogsCmd(args[0], follow)
+	},
+}
+
+var jobsResumeCmd = &cobra.Command{
+	Use:   "resume [id]",
+	Short: "Resume a failed or interrupted job",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("job id is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		background, err := cmd.Flags().GetBool("background")
+		if err != nil {
+			panic(err)
+		}
+		resumeJobCmd(args[0], background)
+	},
+}
+
+// jobsRunCmd runs a job in a background process started by startJob.
+var jobsRunCmd = &cobra.Command{
+	Use:    "run [id]",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			panic(err)
+		}
+		job, err := jobs.Load(cwd, args[0])
+		if err != nil {
+			panic(err)
+		}
+		unregister, err := processes.RegisterSelf(processes.Job, cwd, 0)
+		if err != nil {
+			fmt.Println("Error registering the job, 'langforge ps' does not list it:", err)
+		}
+		defer unregister()
+		if err := jobs.Run(cwd, job, nil); err != nil {
+			unregister()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsLogsCmd)
+	jobsCmd.AddCommand(jobsResumeCmd)
+	jobsCmd.AddCommand(jobsRunCmd)
+	jobsLogsCmd.Flags().BoolP("follow", "f", false, "keep printing the log while the job is running")
+	jobsResumeCmd.Flags().Bool("background", false, "run the job in a background process")
+}
+
+func listJobsCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	list, err := jobs.List(cwd)
+	if err != nil {
+		panic(err)
+	}
+	if len(list) == 0 {
+		fmt.Println("No jobs found.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, job := range list {
+		rows = append(rows, []string{
+			job.ID,
+			job.Kind,
+			job.Status,
+			fmt.Sprintf("%d/%d", job.Done, len(job.Items)),
+			job.Created.Format("2006-01-02 15:04:05"),
+			truncate(job.Error, 40),
+		})
+	}
+
+	err = tui.PrintTable([]string{"ID", "Kind", "Status", "Progress", "Created", "Error"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func jobLogsCmd(id string, follow bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if _, err := jobs.Load(cwd, id); err != nil {
+		panic(err)
+	}
+
+	file, err := os.Open(jobs.LogPath(cwd, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("The job has not written any output yet.")
+			return
+		}
+		panic(err)
+	}
+	defer file.Close()
+
+	for {
+		if _, err := io.Copy(os.Stdout, file); err != nil {
+			panic(err)
+		}
+		if !follow {
+			return
+		}
+		job, err := jobs.Load(cwd, id)
+		if err != nil {
+			panic(err)
+		}
+		if job.Status != jobs.Running && job.Status != jobs.Queued {
+			io.Copy(os.Stdout, file)
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func resumeJobCmd(id string, background bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	job, err := jobs.Load(cwd, id)
+	if err != nil {
+		panic(err)
+	}
+
+	startJob(cwd, job, background)
+}
+
+// startJob runs the job with a progress display, or starts a background
+// process that runs it.
+func startJob(dir string, job *jobs.Job, background bool) {
+	if background {
+		executable, err := os.Executable()
+		if err != nil {
+			panic(err)
+		}
+		cmd := exec.Command(executable, "jobs", "run", job.ID)
+		cmd.Dir = dir
+		if err := cmd.Start(); err != nil {
+			panic(err)
+		}
+		cmd.Process.Release()
+		fmt.Printf("Started job %s in the background.\n", job.ID)
+		fmt.Printf("Run 'langforge jobs logs -f %s' to follow its progress.\n", job.ID)
+		return
+	}
+
+	fmt.Printf("Running job %s (log: %s)\n", job.ID, jobs.LogPath(dir, job.ID))
+	err := jobs.Run(dir, job, func(job *jobs.Job) {
+		fmt.Printf("  %d/%d done (%d%%)\n", job.Done, len(job.Items), job.Done*100/len(job.Items))
+	})
+	if err != nil {
+		fmt.Printf("Job %s failed: %v\n", job.ID, err)
+		fmt.Printf("Run 'langforge jobs resume %s' to continue where it stopped.\n", job.ID)
+		os.Exit(1)
+	}
+	fmt.Printf("Job %s finished.\n", job.ID)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/jupyter"
+	"langforge/tui"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var kernelCmd = &cobra.Command{
+	Use:   "kernel",
+	Short: "Register the project environment as a Jupyter kernel",
+	Long: `The kernel command registers the environment of the project as a kernel of
+your Jupyter, so that notebooks in any JupyterLab, VS Code or other Jupyter
+client can run in it, and lists and removes kernels.`,
+}
+
+var kernelInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Register the project environment as a Jupyter kernel",
+	Long: `The install command installs ipykernel into the environment of the project,
+unless it has it, and registers a kernel that runs the interpreter of the
+environment in the Jupyter data directory of the user (JUPYTER_DATA_DIR, or
+~/.local/share/jupyter, ~/Library/Jupyter or %APPDATA%\jupyter). The kernel
+is named langforge-<project> and shown as "Python (<project>)" unless --name
+and --display-name say otherwise. It has the executables of the environment
+in the PATH and loads the IPython startup scripts of the project. Installing
+a kernel again updates it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			panic(err)
+		}
+		displayName, err := cmd.Flags().GetString("display-name")
+		if err != nil {
+			panic(err)
+		}
+		installKernelCmd(jupyter.Options{Name: name, DisplayName: displayName})
+	},
+}
+
+var kernelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the Jupyter kernels of the user",
+	Long: `The list command lists the Jupyter kernels of the user, with the projects of
+the kernels that langforge registered. Kernels whose interpreter no longer
+exists, e.g. because their environment was removed, are marked as missing.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listKernelsCmd()
+	},
+}
+
+var kernelRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a Jupyter kernel",
+	Long: `The remove command removes the kernel with the given name, or without a name
+the kernels that langforge registered for the project.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		removeKernelCmd(name)
+	},
+}
+
+func init() {
+	kernelInstallCmd.Flags().String("name", "", "the name of the kernel, langforge-<project> by default")
+	kernelInstallCmd.Flags().String("display-name", "", "the name of the kernel in JupyterLab")
+	kernelCmd.AddCommand(kernelInstallCmd)
+	kernelCmd.AddCommand(kernelListCmd)
+	kernelCmd.AddCommand(kernelRemoveCmd)
+	rootCmd.AddCommand(kernelCmd)
+	markProjectIndependent(kernelListCmd)
+}
+
+func installKernelCmd(options jupyter.Options) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	kernel, err := jupyter.Install(cwd, options)
+	if err != nil {
+		fmt.Println("Error installing the kernel:", err)
+		return
+	}
+	fmt.Printf("Installed the kernel %s (%s), which runs %s.\n", kernel.Name, kernel.DisplayName, kernel.Python)
+}
+
+func listKernelsCmd() {
+	kernels, err := jupyter.List()
+	if err != nil {
+		panic(err)
+	}
+	if len(kernels) == 0 {
+		fmt.Println("No Jupyter kernels of the user.")
+		return
+	}
+	rows := [][]string{}
+	for _, kernel := range kernels {
+		status := ""
+		if kernel.Missing() {
+			status = "missing"
+		}
+		rows = append(rows, []string{kernel.Name, kernel.DisplayName, kernel.Language, kernel.Project, status})
+	}
+	if err := tui.PrintTable([]string{"Name", "Display name", "Language", "Project", "Status"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func removeKernelCmd(name string) {
+	names := []string{name}
+	if name == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			panic(err)
+		}
+		kernels, err := jupyter.ForProject(cwd)
+		if err != nil {
+			panic(err)
+		}
+		if len(kernels) == 0 {
+			fmt.Println("No kernels of this project.")
+			return
+		}
+		names = nil
+		for _, kernel := range kernels {
+			names = append(names, kernel.Name)
+		}
+	}
+	for _, name := range names {
+		if err := jupyter.Remove(name); err != nil {
+			fmt.Println("Error removing the kernel:", err)
+			return
+		}
+		fmt.Printf("Removed the kernel %s.\n", name)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"langforge/python"
 	"langforge/system"
 	"langforge/tui"
+	"langforge/userconfig"
 	"os"
 	"path/filepath"
 
@@ -35,14 +36,9 @@ func editApiKeysCmd() {
 
 	handler := python.NewPythonHandler(currentDir)
 
-	venvDir := filepath.Join(currentDir, ".venv")
-	if _, err := os.Stat(venvDir); err == nil {
-		// Activate the virtual environment
-		err = python.ActivateEnvironment(venvDir)
-		if err != nil {
-			fmt.Println("Error activating virtual environment:", err)
-			return
-		}
+	if _, err := python.ActivateProjectEnvironment(currentDir); err != nil {
+		fmt.Println("Error activating the project environment:", err)
+		return
 	}
 
 	err = handler.DetermineInstalledIntegrations()
@@ -59,6 +55,12 @@ func editApiKeysCmd() {
 		panic(err)
 	}
 
+	// Keys that are not set default to those configured by 'langforge setup'
+	_, err = userconfig.ApplyKeys(dotEnvPath, apiKeys)
+	if err != nil {
+		panic(err)
+	}
+
 	// Load the environment from the .env file
 	env, err := system.ReadEnv(dotEnvPath)
 	if err != nil {
@@ -1,12 +1,16 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
+	"langforge/processes"
+	"langforge/project"
 	"langforge/python"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -16,7 +20,11 @@ var jupyterLabCmd = &cobra.Command{
 	Short: " launches JupyterLab for instant coding in your virtual environment.",
 	Long: `The lab command starts a JupyterLab server within your virtual environment and 
 launches a browser window, enabling you to begin coding immediately in an 
-interactive workspace.`,
+interactive workspace.
+
+If JupyterLab crashes, it is restarted after a delay that grows with each crash,
+up to five times within ten minutes. Its output is also appended to
+.langforge/lab.log.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		startJupyterLabCmd()
 	},
@@ -33,16 +41,8 @@ func startJupyterLabCmd() {
 		return
 	}
 
-	venvDir := filepath.Join(cwd, ".venv")
-	if _, err := os.Stat(venvDir); err == nil {
-		// Activate the virtual environment
-		err = python.ActivateEnvironment(venvDir)
-		if err != nil {
-			fmt.Println("Error activating virtual environment:", err)
-			return
-		}
-	} else {
-		fmt.Println("No virtual environment found. Continuing in the current environment.")
+	if !activateProjectEnvironment(cwd) {
+		return
 	}
 
 	err = python.SetJupyterEnvironmentVariables(cwd)
@@ -50,14 +50,40 @@ func startJupyterLabCmd() {
 		panic(err)
 	}
 
-	// Start the Jupyter Notebook server
-	labCmd := exec.Command("jupyter", "lab")
-	var labOutput bytes.Buffer
-	labCmd.Stdout = &labOutput
-	labCmd.Stderr = os.Stderr
-
-	err = labCmd.Run()
+	// JupyterLab is restarted if it crashes, its output is kept in a log
+	lab := &processes.Supervisor{
+		Name:    "JupyterLab",
+		Command: func() *exec.Cmd { return exec.Command("jupyter", "lab") },
+		Output:  os.Stderr,
+		LogPath: filepath.Join(project.StateDir(cwd), processes.Lab+".log"),
+		OnRestart: func(crash error, delay time.Duration) {
+			fmt.Printf("JupyterLab exited (%v), restarting it in %s...\n", crash, delay)
+		},
+	}
+	err = lab.Start()
 	if err != nil {
 		panic(err)
 	}
+
+	unregister, err := processes.RegisterSelf(processes.Lab, cwd, 0)
+	if err != nil {
+		fmt.Println("Error registering JupyterLab, 'langforge ps' does not list it:", err)
+	}
+	defer unregister()
+
+	// 'langforge ps stop' terminates langforge, which stops JupyterLab with it
+	terminated := make(chan os.Signal, 1)
+	signal.Notify(terminated, syscall.SIGTERM)
+	select {
+	case <-terminated:
+		if err := lab.Stop(10 * time.Second); err != nil {
+			fmt.Println(err)
+		}
+	case <-lab.Done():
+		if err := lab.Err(); err != nil {
+			fmt.Println(err)
+			unregister()
+			os.Exit(1)
+		}
+	}
 }
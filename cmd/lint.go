@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/lint"
+	"langforge/project"
+	"langforge/tui"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [path...]",
+	Short: "Run the linters and formatters of your LangChain application",
+	Long: `The lint command runs ruff and black for Python code and eslint and prettier
+for TypeScript code inside the project's environment and summarizes the results.
+With --fix, problems are fixed and files are formatted in place.
+
+The tools and paths can be configured in langforge.yaml:
+
+  lint:
+    tools: [ruff, black]
+    paths: [src, tests]
+
+Use --install-hook to run the linters as a git pre-commit hook.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fix, err := cmd.Flags().GetBool("fix")
+		if err != nil {
+			panic(err)
+		}
+		installHook, err := cmd.Flags().GetBool("install-hook")
+		if err != nil {
+			panic(err)
+		}
+		lintAppCmd(args, fix, installHook)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().Bool("fix", false, "fix problems and format files in place")
+	lintCmd.Flags().Bool("install-hook", false, "install a git pre-commit hook that runs the linters")
+}
+
+func lintAppCmd(paths []string, fix bool, installHook bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if installHook {
+		path, err := lint.InstallHook(cwd)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Installed pre-commit hook at %s\n", path)
+		return
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	tools := config.Lint.Tools
+	if len(tools) == 0 {
+		tools = lint.Detect(cwd)
+	}
+	if len(tools) == 0 {
+		fmt.Println("No linters found for this project.")
+		return
+	}
+
+	if len(paths) == 0 {
+		paths = config.Lint.Paths
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	err = lint.EnsureTools(tools)
+	if err != nil {
+		panic(err)
+	}
+
+	failed := false
+	rows := [][]string{}
+	for _, tool := range tools {
+		result := lint.Run(cwd, tool, paths, fix)
+
+		status := "passed"
+		if fix {
+			status = "fixed"
+		}
+		if !result.OK() {
+			status = "failed"
+			failed = true
+		}
+
+		if result.Output != "" {
+			fmt.Println(tui.Bold("▶ %s", tool))
+			fmt.Println(result.Output)
+			tui.EmptyLine()
+		} else if !result.OK() {
+			fmt.Println(tui.Bold("▶ %s", tool))
+			fmt.Println(result.Err)
+			tui.EmptyLine()
+		}
+
+		rows = append(rows, []string{tool, status, result.Duration.Round(time.Millisecond).String()})
+	}
+
+	err = tui.PrintTable([]string{"Tool", "Result", "Duration"}, rows)
+	if err != nil {
+		panic(err)
+	}
+
+	if failed {
+		if !fix {
+			fmt.Println("Run 'langforge lint --fix' to fix problems automatically.")
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/cassette"
+	"langforge/mockllm"
+	"langforge/project"
+	"langforge/provider"
+	"os"
+)
+
+// llmEndpoint is a local OpenAI compatible server that replaces the provider.
+type llmEndpoint interface {
+	URL() string
+	Env() map[string]string
+	Close() error
+}
+
+// llmMock is the server of 'langforge serve --mock-llm', --record-llm or
+// --replay-llm, or nil.
+var llmMock llmEndpoint
+
+// startMockLLM starts the mock LLM server for the project in dir and points
+// the provider settings of the environment, which the worker inherits, to it.
+func startMockLLM(dir string) *mockllm.Server {
+	server, err := mockllm.Start(dir)
+	if err != nil {
+		panic(err)
+	}
+	useLLMEndpoint(server)
+	return server
+}
+
+// startCassette opens a cassette that records the provider calls of the
+// worker or replays them, and points the provider settings of the
+// environment to it.
+func startCassette(path string, mode string) *cassette.Cassette {
+	c, err := cassette.Open(path, mode, providerBaseURL(nil))
+	if err != nil {
+		panic(err)
+	}
+	useLLMEndpoint(c)
+	return c
+}
+
+// providerBaseURL returns the base URL of the OpenAI API from env, or from
+// the environment if env is nil.
+func providerBaseURL(env map[string]string) string {
+	base := os.Getenv("OPENAI_API_BASE")
+	if env != nil {
+		base = env["OPENAI_API_BASE"]
+	}
+	if base == "" {
+		return provider.DefaultOpenAIBaseURL
+	}
+	return base
+}
+
+func useLLMEndpoint(endpoint llmEndpoint) {
+	for key, value := range endpoint.Env() {
+		os.Setenv(key, value)
+	}
+	llmMock = endpoint
+}
+
+// llmOffline returns whether the provider is not contacted, since its
+// responses are mocked or replayed.
+func llmOffline() bool {
+	if c, ok := llmMock.(*cassette.Cassette); ok {
+		return c.Mode() == cassette.Replay
+	}
+	return llmMock != nil
+}
+
+// llmCassetteMode returns the cassette mode and path selected with
+// --record-llm or --replay-llm, or empty strings.
+func llmCassetteMode(record string, replay string) (string, string) {
+	switch {
+	case record != "" && replay != "":
+		panic(fmt.Errorf("--record-llm and --replay-llm cannot be combined"))
+	case record != "":
+		return cassette.Record, record
+	case replay != "":
+		return cassette.Replay, replay
+	}
+	return "", ""
+}
+
+// loadServeConfig loads the configuration of the project in dir for serving.
+// With a mock LLM or a cassette, the env variables of chains and canaries
+// must not point the worker back to a real provider.
+func loadServeConfig(dir string) (*project.Config, error) {
+	config, err := project.LoadConfig(dir)
+	if err != nil || llmMock == nil {
+		return config, err
+	}
+	for _, chain := range config.Chains {
+		for key := range llmMock.Env() {
+			delete(chain.Env, key)
+			if chain.Canary != nil {
+				delete(chain.Canary.Env, key)
+			}
+		}
+	}
+	return config, nil
+}
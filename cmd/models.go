@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/models"
+	"langforge/permission"
+	"langforge/project"
+	"langforge/tui"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Manage the disk space of locally cached models",
+	Long: `The models command shows the models that Hugging Face and Ollama have
+downloaded to this machine and removes the least recently used ones when the
+caches grow beyond a quota:
+
+  models:
+    quota: 50GB
+
+The LANGFORGE_MODEL_QUOTA environment variable overrides the quota of
+langforge.yaml. 'langforge serve' warns when the caches exceed the quota.
+
+Hugging Face models are read from the hub cache (HF_HUB_CACHE or HF_HOME),
+Ollama models from OLLAMA_MODELS or ~/.ollama/models. Ollama models are removed
+with 'ollama rm', which keeps layers that other models share.`,
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached models, least recently used first",
+	Run: func(cmd *cobra.Command, args []string) {
+		listModelsCmd(modelsQuota(cmd))
+	},
+}
+
+var modelsGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove the least recently used models until the caches fit the quota",
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			panic(err)
+		}
+		gcModelsCmd(modelsQuota(cmd), dryRun, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+	modelsCmd.AddCommand(modelsListCmd)
+	modelsCmd.AddCommand(modelsGCCmd)
+	modelsCmd.PersistentFlags().String("quota", "", "quota of the model caches, e.g. 50GB, instead of the configured one")
+	modelsGCCmd.Flags().Bool("dry-run", false, "list the models that would be removed without removing them")
+	modelsGCCmd.Flags().Bool("force", false, "remove the models without asking")
+	modelsGCCmd.Flags().BoolP("yes", "y", false, "remove the models without asking")
+	modelsGCCmd.Flags().MarkDeprecated("yes", "use --force instead")
+}
+
+// modelsQuota returns the quota selected with --quota, from the environment or
+// from langforge.yaml in the current directory, or zero if there is none.
+func modelsQuota(cmd *cobra.Command) int64 {
+	flag, err := cmd.Flags().GetString("quota")
+	if err != nil {
+		panic(err)
+	}
+	if flag != "" {
+		quota, err := models.ParseSize(flag)
+		if err != nil {
+			panic(err)
+		}
+		return quota
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	quota, err := models.Quota(config.Models.Quota)
+	if err != nil {
+		panic(err)
+	}
+	return quota
+}
+
+func listModelsCmd(quota int64) {
+	cached, err := models.Scan()
+	if err != nil {
+		panic(err)
+	}
+	if len(cached) == 0 {
+		fmt.Println("No cached models found.")
+		return
+	}
+
+	evict := map[*models.Model]bool{}
+	if quota > 0 {
+		for _, model := range models.Evictions(cached, quota) {
+			evict[model] = true
+		}
+	}
+
+	rows := [][]string{}
+	for _, model := range cached {
+		suggestion := ""
+		if evict[model] {
+			suggestion = "remove"
+		}
+		rows = append(rows, []string{model.Source, model.Name, models.FormatSize(model.Size), model.LastUsed.Format("2006-01-02 15:04"), suggestion})
+	}
+	err = tui.PrintTable([]string{"Source", "Model", "Size", "Last used", "Suggestion"}, rows)
+	if err != nil {
+		panic(err)
+	}
+
+	usage := models.Usage(cached)
+	if quota == 0 {
+		fmt.Printf("The caches use %s. Set models.quota in %s to limit them.\n", models.FormatSize(usage), project.ConfigFileName)
+		return
+	}
+	fmt.Printf("The caches use %s of the %s quota.\n", models.FormatSize(usage), models.FormatSize(quota))
+	if len(evict) > 0 {
+		fmt.Println("Run 'langforge models gc' to remove the suggested models.")
+	}
+}
+
+func gcModelsCmd(quota int64, dryRun bool, force bool) {
+	if quota == 0 {
+		fmt.Printf("No quota is set. Set models.quota in %s, %s or pass --quota.\n", project.ConfigFileName, models.QuotaEnv)
+		os.Exit(1)
+	}
+
+	cached, err := models.Scan()
+	if err != nil {
+		panic(err)
+	}
+	evict := models.Evictions(cached, quota)
+	if len(evict) == 0 {
+		fmt.Printf("The caches use %s and fit the %s quota.\n", models.FormatSize(models.Usage(cached)), models.FormatSize(quota))
+		return
+	}
+
+	rows := [][]string{}
+	var freed int64
+	for _, model := range evict {
+		rows = append(rows, []string{model.Source, model.Name, models.FormatSize(model.Size), model.LastUsed.Format("2006-01-02 15:04")})
+		freed += model.Size
+	}
+	err = tui.PrintTable([]string{"Source", "Model", "Size", "Last used"}, rows)
+	if err != nil {
+		panic(err)
+	}
+	if dryRun {
+		fmt.Printf("Removing these models would free up to %s.\n", models.FormatSize(freed))
+		return
+	}
+
+	if !confirmOperation(permission.RemoveModels, fmt.Sprintf("Remove %d models to free up to %s?", len(evict), models.FormatSize(freed)), force) {
+		return
+	}
+
+	failed := false
+	for _, model := range evict {
+		if err := models.Remove(model); err != nil {
+			fmt.Printf("Failed to remove %s: %v\n", model.Name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Removed %s\n", model.Name)
+	}
+	remaining, err := models.Scan()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("The caches now use %s of the %s quota.\n", models.FormatSize(models.Usage(remaining)), models.FormatSize(quota))
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// warnModelQuota warns if the model caches exceed the configured quota.
+func warnModelQuota(config *project.Config) {
+	quota, err := models.Quota(config.Models.Quota)
+	if err != nil {
+		fmt.Println("Warning:", err)
+		return
+	}
+	if quota == 0 {
+		return
+	}
+	cached, err := models.Scan()
+	if err != nil {
+		return
+	}
+	if usage := models.Usage(cached); usage > quota {
+		fmt.Printf("Warning: the model caches use %s, more than the %s quota. Run 'langforge models gc' to free space.\n", models.FormatSize(usage), models.FormatSize(quota))
+	}
+}
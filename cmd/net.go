@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/netcheck"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var netCmd = &cobra.Command{
+	Use:   "net",
+	Short: "Diagnose network problems",
+}
+
+var netCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the reachability of package registries and provider APIs",
+	Long: `The check command tests whether PyPI, npm, the OpenAI and Anthropic APIs,
+Hugging Face and Docker Hub can be reached through the proxy settings of your
+environment, and reports the latency of each service.
+
+Failures are classified as DNS, proxy, TLS, timeout or network problems to
+tell configuration issues apart from connectivity issues.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			panic(err)
+		}
+		netCheckAppCmd(timeout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(netCmd)
+	netCmd.AddCommand(netCheckCmd)
+	netCheckCmd.Flags().Duration("timeout", 10*time.Second, "timeout for each request")
+}
+
+func netCheckAppCmd(timeout time.Duration) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	// the project's .env may override index and API URLs
+	env, err := system.GetEnv(cwd)
+	if err != nil {
+		env = map[string]string{}
+	}
+
+	proxies := netcheck.ProxySettings()
+	if len(proxies) == 0 {
+		fmt.Println("Proxy: none configured")
+	} else {
+		fmt.Println("Proxy settings:")
+		for _, setting := range proxies {
+			fmt.Printf("  %s\n", setting)
+		}
+	}
+	tui.EmptyLine()
+
+	results := netcheck.CheckAll(context.Background(), netcheck.DefaultTargets(env), timeout)
+
+	failed := []*netcheck.Result{}
+	rows := [][]string{}
+	for _, result := range results {
+		via := "direct"
+		if result.Proxy != "" {
+			via = result.Proxy
+		}
+		status := fmt.Sprintf("OK (%d)", result.Status)
+		if !result.OK() {
+			status = "FAILED: " + result.Kind
+			failed = append(failed, result)
+		}
+		rows = append(rows, []string{
+			result.Target.Name,
+			result.Target.URL,
+			via,
+			status,
+			result.Latency.Round(time.Millisecond).String(),
+		})
+	}
+
+	err = tui.PrintTable([]string{"Service", "URL", "Via", "Result", "Latency"}, rows)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(failed) == 0 {
+		return
+	}
+
+	tui.EmptyLine()
+	for _, result := range failed {
+		fmt.Printf("%s: %v\n", tui.Bold(result.Target.Name), result.Err)
+		fmt.Printf("  %s\n", netcheck.Hint(result.Kind))
+	}
+	os.Exit(1)
+}
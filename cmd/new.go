@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"langforge/shim"
+	"langforge/system"
+	"langforge/templates"
+	"langforge/tui"
+	"langforge/userconfig"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new [template] [app-name]",
+	Short: "Create a new LangChain application from a template",
+	Long: `The new command creates a LangChain application from a template: the
+directory layout, requirements.txt or package.json, .env.example, langforge.yaml
+and the starter code of its chains. Without arguments, or with --list, it
+lists the templates.
+
+  langforge new python-chat my-app
+  langforge new typescript-chat my-app --provider anthropic
+
+The built-in templates use OpenAI, Anthropic or Google Gemini models: the
+provider of --provider (openai, anthropic or gemini), otherwise the one chosen
+in 'langforge setup', otherwise OpenAI. The provider decides the LangChain
+integration, the chat model, the embeddings of question answering (local
+Hugging Face models for Anthropic, which has none) and the API key in .env.
+
+Python templates get a virtual environment in .venv with their requirements
+installed, or a conda environment with --conda; --python chooses the
+interpreter like 'langforge create' does. TypeScript templates get their
+dependencies installed with the package manager of the project, npm unless
+the template says otherwise. --no-install only writes the files. The .env file
+starts with the API keys configured by 'langforge setup'.
+
+Besides the built-in templates, langforge finds the templates of the user in
+the templates directory of its global directory, one per subdirectory, which
+replace built-in ones of the same name. A template can also be given by the
+path of its directory, e.g. 'langforge new ./my-template my-app'. A template
+is a directory with a template.yaml:
+
+  description: A chat chain in a Jupyter notebook
+  language: python     # or typescript
+  entry: app.ipynb     # the notebook or module of the chains
+  apiKeys: [SERPAPI_API_KEY]
+  providers: [openai, anthropic, gemini]   # the first is the default
+
+All other files are copied into the project. Files ending in .tmpl are
+rendered with Go's text/template and written without the suffix; {{.Name}} is
+the name of the project and {{.PackageName}} the name as a package name.
+Templates with providers get the provider as {{.Provider}}, e.g.
+{{.Provider.PythonImport}}, {{.Provider.PythonChatModel 0.7}} or
+{{.Provider.APIKey}}, whose key the project needs besides apiKeys; {{json x}}
+writes x as JSON, e.g. code in notebooks.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		list, err := cmd.Flags().GetBool("list")
+		if err != nil {
+			panic(err)
+		}
+		if list || len(args) == 0 {
+			listTemplatesCmd()
+			return
+		}
+		if len(args) < 2 {
+			panic(fmt.Errorf("app name is missing"))
+		}
+
+		noInstall, err := cmd.Flags().GetBool("no-install")
+		if err != nil {
+			panic(err)
+		}
+		noVenv, err := cmd.Flags().GetBool("no-venv")
+		if err != nil {
+			panic(err)
+		}
+		pythonSpec, err := cmd.Flags().GetString("python")
+		if err != nil {
+			panic(err)
+		}
+		conda, err := cmd.Flags().GetBool("conda")
+		if err != nil {
+			panic(err)
+		}
+		providerName, err := cmd.Flags().GetString("provider")
+		if err != nil {
+			panic(err)
+		}
+		newAppCmd(args[0], args[1], newAppOptions{
+			install:           !noInstall,
+			createEnvironment: !noVenv,
+			pythonSpec:        pythonSpec,
+			conda:             conda,
+			provider:          providerName,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	markProjectIndependent(newCmd)
+	newCmd.Flags().Bool("list", false, "list the templates")
+	newCmd.Flags().Bool("no-install", false, "only write the files, without installing dependencies")
+	newCmd.Flags().Bool("no-venv", false, "install the Python requirements in the current environment instead of a new virtual environment")
+	newCmd.Flags().String("python", "", "create the virtual environment with this Python interpreter, given by path or version")
+	newCmd.Flags().Bool("conda", false, "create a conda environment instead of a virtual environment")
+	newCmd.Flags().String("provider", "", "the LLM provider of the application: openai, anthropic or gemini")
+}
+
+type newAppOptions struct {
+	install           bool
+	createEnvironment bool
+	pythonSpec        string
+	conda             bool
+	provider          string
+}
+
+func listTemplatesCmd() {
+	list, err := templates.List()
+	if err != nil {
+		panic(err)
+	}
+	rows := [][]string{}
+	for _, t := range list {
+		rows = append(rows, []string{t.Name, t.Language, strings.Join(t.Providers, ", "), t.Source, t.Description})
+	}
+	if err := tui.PrintTable([]string{"Template", "Language", "Providers", "Source", "Description"}, rows); err != nil {
+		panic(err)
+	}
+	tui.EmptyLine()
+	fmt.Println("Create an application with 'langforge new <template> <app-name>'.")
+}
+
+func newAppCmd(templateName string, appName string, options newAppOptions) {
+	template, err := templates.Find(templateName)
+	if err != nil {
+		panic(err)
+	}
+	config, err := userconfig.Load()
+	if err != nil {
+		panic(err)
+	}
+	llmProvider, err := template.Provider(options.provider, config.Provider)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := filepath.Abs(appName)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		panic(fmt.Errorf("file with name '%s' already exists", dir))
+	}
+	if options.conda && strings.ContainsAny(options.pythonSpec, `/\`) {
+		panic(fmt.Errorf("--python takes a version with --conda, e.g. --python 3.11"))
+	}
+
+	pythonPath := ""
+	setUpPython := template.Language == templates.Python && options.install && options.createEnvironment
+	if setUpPython && !options.conda {
+		pythonPath, err = choosePython(options.pythonSpec)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(err)
+	}
+	files, err := template.Render(dir, templates.NewData(filepath.Base(dir), llmProvider))
+	if err != nil {
+		panic(err)
+	}
+	// a template with an invalid langforge.yaml would only fail later
+	if _, err := project.LoadConfig(dir); err != nil {
+		panic(fmt.Errorf("the template %s has an invalid langforge.yaml: %v", template.Name, err))
+	}
+	if llmProvider != nil {
+		fmt.Printf("Created %s from the template %s with %d files, using %s.\n", appName, template.Name, len(files), llmProvider.Title)
+	} else {
+		fmt.Printf("Created %s from the template %s with %d files.\n", appName, template.Name, len(files))
+	}
+
+	if options.install {
+		installTemplateDependencies(dir, template, pythonPath, options)
+	}
+
+	if err := shim.For(template.Entry).Generate(dir); err != nil {
+		panic(err)
+	}
+
+	unsetKeys := []string{}
+	if apiKeys := template.Keys(llmProvider); len(apiKeys) > 0 {
+		dotEnvPath := filepath.Join(dir, ".env")
+		if err := system.EnsureEnv(dotEnvPath, apiKeys); err != nil {
+			panic(err)
+		}
+		if _, err := userconfig.ApplyKeys(dotEnvPath, apiKeys); err != nil {
+			panic(err)
+		}
+		unsetKeys, err = system.UnsetAPIKeys(dotEnvPath, apiKeys)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	tui.EmptyLine()
+	fmt.Printf("Successfully created 🦜️🔗LangChain application '%s'. Next steps:\n", appName)
+	fmt.Printf("  cd %s\n", appName)
+	if len(unsetKeys) > 0 {
+		fmt.Printf("  langforge keys          # set %s\n", strings.Join(unsetKeys, ", "))
+	}
+	if !options.install {
+		if template.Language == templates.Python {
+			fmt.Println("  pip install -r requirements.txt")
+		} else {
+			fmt.Println("  npm install")
+		}
+	}
+	fmt.Printf("  langforge serve %s\n", filepath.ToSlash(template.Entry))
+}
+
+// installTemplateDependencies creates the environment of a Python project
+// and installs the dependencies of the project in dir.
+func installTemplateDependencies(dir string, template *templates.Template, pythonPath string, options newAppOptions) {
+	steps := []system.Step{}
+	if template.Language == templates.Python {
+		if options.createEnvironment && options.conda {
+			fmt.Println("Creating conda environment...")
+			envDir := filepath.Join(dir, python.CondaEnvName)
+			if err := python.CreateCondaEnv(envDir, options.pythonSpec); err != nil {
+				panic(err)
+			}
+			if err := python.ActivateCondaEnv(envDir); err != nil {
+				panic(err)
+			}
+		} else if options.createEnvironment {
+			fmt.Println("Creating virtual environment...")
+			var err error
+			if pythonPath != "" {
+				err = python.CreateVirtualEnvWithPython(pythonPath, filepath.Join(dir, ".venv"))
+			} else {
+				err = python.CreateVirtualEnv(".venv", dir)
+			}
+			if err != nil {
+				panic(err)
+			}
+			if err := python.ActivateEnvironment(".venv", dir); err != nil {
+				panic(err)
+			}
+		}
+		if step, ok, err := pythonDependenciesStep(dir, ""); err != nil {
+			fmt.Printf("The Python dependencies are not installed: %v.\n", err)
+		} else if ok {
+			steps = append(steps, step)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+		if installer, err := system.FindNodePackageManager(dir); err != nil {
+			fmt.Printf("The dependencies of package.json are not installed: %v.\n", err)
+		} else {
+			steps = append(steps, system.Step{
+				Name: installer.Name(),
+				Run:  installer.Install,
+			})
+		}
+	}
+	if len(steps) == 0 {
+		return
+	}
+
+	fmt.Println("Installing dependencies...")
+	ctx, stop := system.InterruptContext()
+	err := (&system.Runner{Dir: dir, Retry: &system.InstallRetry}).RunParallel(ctx, steps, 0)
+	stop()
+	if err != nil {
+		panic(err)
+	}
+}
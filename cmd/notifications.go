@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/notify"
+	"langforge/project"
+	"langforge/state"
+	"langforge/tui"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var notificationsCmd = &cobra.Command{
+	Use:   "notifications",
+	Short: "List and test the notification channels of the project",
+	Long: `The notifications command lists the channels that 'langforge serve' notifies of
+events of the project and sends them a test notification. Channels are
+declared in langforge.yaml: a Slack incoming webhook, a webhook that receives
+the events as JSON, or the desktop of the machine:
+
+  notifications:
+    - type: slack
+      url: ${SLACK_WEBHOOK_URL}
+      events: [deploy, budget]
+    - type: webhook
+      url: https://ops.example.com/hooks/langforge
+      headers:
+        Authorization: Bearer ${OPS_TOKEN}
+    - type: desktop
+      events: [worker.crash_loop, schedule.failed]
+
+A channel receives all events unless it lists the events or groups of events
+it is interested in:
+
+  deploy.completed    the server was restarted on SIGHUP, e.g. by a deploy
+  deploy.failed       the new server of a restart on SIGHUP did not start
+  budget.threshold    the cost of the LLM requests reached a threshold of the budget
+  worker.crash_loop   the worker crashed three times within ten minutes
+  schedule.failed     a scheduled task failed, see 'langforge schedule'
+
+The budget limits the cost recorded by analytics (see 'langforge analytics')
+in US dollars per UTC day and month. A notification is sent once per day or
+month when the cost reaches 80% and 100% of a limit, or the percentages given
+as thresholds:
+
+  budget:
+    daily: 20
+    monthly: 400
+    thresholds: [50, 90, 100]`,
+}
+
+var notificationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the notification channels",
+	Run: func(cmd *cobra.Command, args []string) {
+		listNotificationsCmd()
+	},
+}
+
+var notificationsTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a test notification to every channel",
+	Run: func(cmd *cobra.Command, args []string) {
+		testNotificationsCmd()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notificationsCmd)
+	notificationsCmd.AddCommand(notificationsListCmd)
+	notificationsCmd.AddCommand(notificationsTestCmd)
+}
+
+// loadNotifications returns the configuration of the project in the current
+// directory.
+func loadNotifications() *project.Config {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	exportDotEnv(cwd)
+	if len(config.Notifications) == 0 {
+		fmt.Println("No notification channels. Declare them under notifications in langforge.yaml.")
+	}
+	return config
+}
+
+func listNotificationsCmd() {
+	config := loadNotifications()
+	if len(config.Notifications) == 0 {
+		return
+	}
+
+	rows := [][]string{}
+	for _, channel := range config.Notifications {
+		events := "all"
+		if len(channel.Events) > 0 {
+			events = strings.Join(channel.Events, ", ")
+		}
+		rows = append(rows, []string{channel.Type, notify.Target(channel), events})
+	}
+	if err := tui.PrintTable([]string{"Type", "Target", "Events"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func testNotificationsCmd() {
+	config := loadNotifications()
+
+	event := &notify.Event{
+		Kind:    notify.Test,
+		Project: config.Name,
+		Title:   "Test notification",
+		Message: "Notifications of langforge reach this channel.",
+		Time:    time.Now(),
+	}
+	failed := false
+	for _, channelConfig := range config.Notifications {
+		channel, err := notify.New(channelConfig)
+		if err == nil {
+			err = channel.Send(context.Background(), event)
+		}
+		if err != nil {
+			failed = true
+			fmt.Printf("%s (%s): %v\n", channelConfig.Type, notify.Target(channelConfig), err)
+		} else {
+			fmt.Printf("%s (%s): sent\n", channelConfig.Type, notify.Target(channelConfig))
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// sendNotification sends an event of the project to its notification
+// channels. Failures are reported and otherwise ignored.
+func sendNotification(config *project.Config, event *notify.Event) {
+	if len(config.Notifications) == 0 {
+		return
+	}
+	event.Project = config.Name
+	if err := notify.Send(context.Background(), config.Notifications, event); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// A worker that crashes crashLoopCrashes times within crashLoopWindow is in a
+// crash loop, e.g. as a service that its supervisor restarts every time.
+const (
+	crashLoopCrashes = 3
+	crashLoopWindow  = 10 * time.Minute
+)
+
+// recordWorkerCrash records a crash of the worker of the project in dir in
+// the project state, across restarts of langforge, and notifies a crash loop.
+func recordWorkerCrash(dir string, config *project.Config, crash error) {
+	store, err := state.Open(dir)
+	if err != nil {
+		fmt.Println("Error recording the crash of the server:", err)
+		return
+	}
+	defer store.Close()
+
+	crashes := []time.Time{}
+	if err := store.Get("crashes", "worker", &crashes); err != nil && err != state.ErrNotFound {
+		fmt.Println("Error recording the crash of the server:", err)
+		return
+	}
+	now := time.Now()
+	recent := []time.Time{}
+	for _, t := range crashes {
+		if now.Sub(t) < crashLoopWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+
+	if len(recent) >= crashLoopCrashes {
+		sendNotification(config, &notify.Event{
+			Kind:    notify.WorkerCrashLoop,
+			Title:   "Worker crash loop",
+			Message: fmt.Sprintf("The server crashed %d times within %s, last with: %v", len(recent), crashLoopWindow, crash),
+		})
+		// the next notification needs as many crashes again
+		recent = []time.Time{}
+	}
+	if err := store.Put("crashes", "worker", recent); err != nil {
+		fmt.Println("Error recording the crash of the server:", err)
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Export your LangChain application as an installable Python package",
+	Long: `The package command turns your LangChain application into an installable Python package.
+
+It generates pyproject metadata with an entry point for each chain declared in
+langforge.yaml, builds a wheel and optionally publishes it to a package index,
+so your chains can be used as a library by other Python services.
+
+Before building, the project and the generated sources are scanned for secrets
+(see 'langforge scan secrets'). Building a package with possible secrets
+requires confirmation, which --force skips.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		outputDir, err := cmd.Flags().GetString("output")
+		if err != nil {
+			panic(err)
+		}
+		publish, err := cmd.Flags().GetBool("publish")
+		if err != nil {
+			panic(err)
+		}
+		index, err := cmd.Flags().GetString("index")
+		if err != nil {
+			panic(err)
+		}
+		packageAppCmd(outputDir, publish, index, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(packageCmd)
+	packageCmd.Flags().StringP("output", "o", "dist", "directory to write the wheel to")
+	packageCmd.Flags().Bool("publish", false, "publish the wheel to the configured package index")
+	packageCmd.Flags().String("index", "", "URL of the package index to publish to (overrides langforge.yaml)")
+	packageCmd.Flags().Bool("force", false, "do not ask for confirmation")
+}
+
+func packageAppCmd(outputDir string, publish bool, index string, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		return
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(config.Chains) == 0 {
+		panic(fmt.Errorf("no chains declared in %s", project.ConfigFileName))
+	}
+
+	spec, err := python.NewPackageSpec(cwd, config)
+	if err != nil {
+		panic(err)
+	}
+
+	buildDir, err := project.EnsureStateDir(cwd, "package")
+	if err != nil {
+		panic(err)
+	}
+
+	err = python.WritePackageSources(cwd, buildDir, spec)
+	if err != nil {
+		panic(err)
+	}
+
+	if !confirmNoSecrets(cwd, force) {
+		return
+	}
+
+	fmt.Printf("Building package %s %s...\n", spec.Name, spec.Version)
+
+	wheels, err := python.BuildWheel(spec, buildDir, filepath.Join(cwd, outputDir))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, wheel := range wheels {
+		fmt.Printf("Built %s\n", wheel)
+	}
+
+	if !publish {
+		return
+	}
+
+	if index == "" {
+		index = config.Package.Index
+	}
+
+	err = python.PublishWheels(wheels, index)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Successfully published %s %s.\n", spec.Name, spec.Version)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"langforge/diff"
+	"langforge/permission"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Show which destructive operations require confirmation",
+	Long: `The policy command shows how langforge treats operations that delete data,
+overwrite files or copy secrets out of the project. By default, they ask for
+confirmation, which --force skips; without a terminal, they fail unless --force
+is given.
+
+Teams lock down operations on shared machines with a policy file at
+/etc/langforge/policy.yaml (%ProgramData%\langforge\policy.yaml on Windows).
+Rules name an operation or a class of operations (delete, overwrite, secrets)
+and decide whether it is allowed, requires confirmation, requires confirmation
+in a terminal even with --force (always-confirm) or is denied:
+
+  rules:
+    secrets: deny
+    delete: always-confirm
+    file.overwrite: allow
+
+LANGFORGE_POLICY names a further policy file. Where both files have a rule,
+the stricter one applies.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		showPolicyCmd()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	markProjectIndependent(policyCmd)
+}
+
+func showPolicyCmd() {
+	policy, err := permission.LoadPolicy()
+	if err != nil {
+		panic(err)
+	}
+	rows := [][]string{}
+	for _, operation := range permission.Operations {
+		rows = append(rows, []string{operation.Name, string(operation.Class), string(policy.Decide(operation))})
+	}
+	if err := tui.PrintTable([]string{"Operation", "Class", "Decision"}, rows); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Policy: %s\n", policy.Source())
+}
+
+// forced returns whether --force, or the older --yes, was given.
+func forced(cmd *cobra.Command) bool {
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		panic(err)
+	}
+	if cmd.Flags().Lookup("yes") != nil {
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			panic(err)
+		}
+		force = force || yes
+	}
+	return force
+}
+
+// confirmOperation returns whether an operation may run, asking question if
+// the policy requires confirmation. force confirms the operation unless the
+// policy always asks. Denied operations and operations that need a
+// confirmation that cannot be asked for exit.
+func confirmOperation(operation permission.Operation, question string, force bool) bool {
+	if !needsConfirmation(operation, force) {
+		return true
+	}
+	confirmed, err := tui.PromptYesNo(question, false)
+	if err != nil {
+		panic(err)
+	}
+	return confirmed
+}
+
+// needsConfirmation returns whether the user has to confirm an operation in
+// the terminal, see confirmOperation.
+func needsConfirmation(operation permission.Operation, force bool) bool {
+	policy, err := permission.LoadPolicy()
+	if err != nil {
+		panic(err)
+	}
+
+	decision := policy.Decide(operation)
+	switch decision {
+	case permission.Allow:
+		return false
+	case permission.Deny:
+		fmt.Printf("Not allowed to %s (%s) by %s.\n", operation.Description, operation.Name, policy.Source())
+		os.Exit(1)
+	case permission.Confirm:
+		if force {
+			return false
+		}
+	}
+
+	if !tui.IsInteractive() {
+		if decision == permission.AlwaysConfirm {
+			fmt.Printf("Confirmation in a terminal is required to %s (%s) by %s.\n", operation.Description, operation.Name, policy.Source())
+		} else {
+			fmt.Printf("Confirmation is required to %s. Pass --force to confirm without asking.\n", operation.Description)
+		}
+		os.Exit(1)
+	}
+	return true
+}
+
+// confirmOverwrite returns whether data may be written to path, which requires
+// confirmation if path exists with other contents.
+func confirmOverwrite(path string, data []byte, force bool) bool {
+	existing, err := os.ReadFile(path)
+	if err != nil || bytes.Equal(existing, data) {
+		return true
+	}
+	return confirmOperation(permission.OverwriteFile, fmt.Sprintf("Overwrite %s?", path), force)
+}
+
+// reviewOverwrite returns the contents to write to path, a file generated from
+// a template, and whether to write them. If path exists with other contents
+// and the policy requires confirmation, the changes from its contents to data
+// are shown as a patch, of which the user applies all, some or none, so that
+// edits of the file can be kept.
+func reviewOverwrite(path string, data []byte, force bool) ([]byte, bool) {
+	existing, err := os.ReadFile(path)
+	if err != nil || bytes.Equal(existing, data) {
+		return data, true
+	}
+	if !needsConfirmation(permission.OverwriteFile, force) {
+		return data, true
+	}
+	rel := filepath.ToSlash(path)
+	content, changed, err := tui.ReviewPatch(diff.New("a/"+rel, "b/"+rel, string(existing), string(data), 3))
+	if err != nil {
+		panic(err)
+	}
+	return []byte(content), changed
+}
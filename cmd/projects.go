@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/projects"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// projectIndependent annotates commands that do not run in a project, to
+// which --project and the project selected with 'langforge projects switch'
+// do not apply.
+const projectIndependent = "projectIndependent"
+
+// projectEnv names the project that commands run in, like --project.
+const projectEnv = "LANGFORGE_PROJECT"
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "List the recently used projects and switch between them",
+	Long: `The projects command lists the projects that langforge was used in and selects
+the project that commands run in when they are started outside of a project
+directory:
+
+  langforge projects switch support-bot
+  langforge serve app.ipynb        # serves support-bot from any directory
+
+To run a single command in a project, pass its name or directory with
+--project (or set LANGFORGE_PROJECT), e.g.
+'langforge --project support-bot eval qa'. Commands run in the project
+directory, so paths in their arguments are relative to it.
+
+The projects are kept per user in the langforge directory of the user's
+configuration directory, or in LANGFORGE_HOME if it is set.`,
+}
+
+var projectsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the recently used projects, most recent first",
+	Run: func(cmd *cobra.Command, args []string) {
+		listProjectsCmd()
+	},
+}
+
+var projectsSwitchCmd = &cobra.Command{
+	Use:   "switch [name | directory]",
+	Short: "Select the project that commands run in outside of a project directory",
+	Args: func(cmd *cobra.Command, args []string) error {
+		clear, err := cmd.Flags().GetBool("clear")
+		if err != nil {
+			return err
+		}
+		if len(args) < 1 && !clear {
+			return fmt.Errorf("project is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		clear, err := cmd.Flags().GetBool("clear")
+		if err != nil {
+			panic(err)
+		}
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		switchProjectCmd(name, clear)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(projectsCmd)
+	markProjectIndependent(projectsCmd)
+	projectsCmd.AddCommand(projectsListCmd)
+	projectsCmd.AddCommand(projectsSwitchCmd)
+	projectsSwitchCmd.Flags().Bool("clear", false, "run commands in the current directory again")
+	rootCmd.PersistentFlags().StringP("project", "P", "", "run the command in this project, by name or directory")
+}
+
+// markProjectIndependent marks a command and its subcommands as not running
+// in a project.
+func markProjectIndependent(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[projectIndependent] = "true"
+}
+
+// isProjectIndependent returns whether a command or one of its parents is
+// marked as not running in a project.
+func isProjectIndependent(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[projectIndependent] != "" {
+			return true
+		}
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
+		}
+	}
+	return false
+}
+
+// enterProject changes to the directory of the project that cmd runs in: the
+// one named with --project or LANGFORGE_PROJECT, or the switched project if the
+// current directory is not a project. The project is recorded as used.
+func enterProject(cmd *cobra.Command) {
+	if isProjectIndependent(cmd) {
+		return
+	}
+	name, err := cmd.Flags().GetString("project")
+	if err != nil {
+		panic(err)
+	}
+	if name == "" {
+		name = os.Getenv(projectEnv)
+	}
+	name = system.NativePath(name)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	var selected *projects.Project
+	switch {
+	case name != "":
+		selected, err = projects.Find(name)
+	case !projects.IsProject(cwd):
+		selected, err = projects.Current()
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if selected != nil && selected.Dir != cwd {
+		if !projects.IsProject(selected.Dir) {
+			if name != "" {
+				panic(fmt.Errorf("project %s is no longer in %s", selected.Name, selected.Dir))
+			}
+			fmt.Fprintf(os.Stderr, "Warning: the switched project %s is no longer in %s, run 'langforge projects switch' to select another one.\n", selected.Name, selected.Dir)
+			return
+		}
+		if err := os.Chdir(selected.Dir); err != nil {
+			panic(err)
+		}
+		if name == "" {
+			fmt.Fprintf(os.Stderr, "Using project %s in %s\n", selected.Name, selected.Dir)
+		}
+		cwd = selected.Dir
+	}
+
+	if projects.IsProject(cwd) {
+		// the history is a convenience, failing to record it is not an error
+		projects.Touch(cwd)
+	}
+}
+
+func listProjectsCmd() {
+	recent, err := projects.List()
+	if err != nil {
+		panic(err)
+	}
+	if len(recent) == 0 {
+		fmt.Println("No projects recorded yet. Projects are recorded when langforge runs in them.")
+		return
+	}
+	current, err := projects.Current()
+	if err != nil {
+		panic(err)
+	}
+
+	rows := [][]string{}
+	for _, p := range recent {
+		marker := ""
+		if current != nil && current.Dir == p.Dir {
+			marker = "*"
+		}
+		dir := p.Dir
+		if !projects.IsProject(p.Dir) {
+			dir += " (missing)"
+		}
+		rows = append(rows, []string{marker, p.Name, dir, p.LastUsed.Format("2006-01-02 15:04")})
+	}
+	if err := tui.PrintTable([]string{"", "Project", "Directory", "Last used"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func switchProjectCmd(name string, clear bool) {
+	if clear {
+		if err := projects.Switch(nil); err != nil {
+			panic(err)
+		}
+		fmt.Println("Commands run in the current directory again.")
+		return
+	}
+
+	selected, err := projects.Find(name)
+	if err != nil {
+		panic(err)
+	}
+	if !projects.IsProject(selected.Dir) {
+		panic(fmt.Errorf("project %s is no longer in %s", selected.Name, selected.Dir))
+	}
+	if err := projects.Switch(selected); err != nil {
+		panic(err)
+	}
+	if err := projects.Touch(selected.Dir); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Switched to project %s in %s. Commands started outside of a project directory run in it.\n", selected.Name, selected.Dir)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/prompt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Manage the prompt templates of your LangChain application",
+	Long: `The prompt command manages the prompt templates in the prompts directory.
+
+Prompt templates are text files with an optional YAML front matter that
+declares metadata such as the version, variables and model hints:
+
+  ---
+  version: 2
+  description: Answer questions about a document
+  variables: [context, question]
+  model:
+    name: gpt-3.5-turbo
+    temperature: 0
+  ---
+  Answer the question based on the context below.
+
+  Context: {context}
+  Question: {question}
+
+In notebooks and served applications, use load_prompt("name") or
+load_prompt("name@version") to get a PromptTemplate.`,
+}
+
+var promptListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the prompt templates of your LangChain application",
+	Run: func(cmd *cobra.Command, args []string) {
+		listPromptsCmd()
+	},
+}
+
+var promptRenderCmd = &cobra.Command{
+	Use:   "render [name[@version]]",
+	Short: "Render a prompt template with variables",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("prompt name is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		vars, err := cmd.Flags().GetStringArray("var")
+		if err != nil {
+			panic(err)
+		}
+		renderPromptCmd(args[0], vars)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+	promptCmd.AddCommand(promptListCmd)
+	promptCmd.AddCommand(promptRenderCmd)
+	promptRenderCmd.Flags().StringArray("var", []string{}, "variable to substitute, as key=value (can be repeated)")
+}
+
+func listPromptsCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	prompts, err := prompt.LoadAll(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(prompts) == 0 {
+		fmt.Printf("No prompt templates found in %s.\n", prompt.Dir(cwd))
+		return
+	}
+
+	for _, p := range prompts {
+		line := fmt.Sprintf("%s@%d", p.Name, p.Version)
+		if len(p.Variables) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(p.Variables, ", "))
+		}
+		if p.Description != "" {
+			line += " - " + p.Description
+		}
+		fmt.Println(line)
+	}
+}
+
+func renderPromptCmd(ref string, vars []string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error getting current directory:", err)
+		return
+	}
+
+	p, err := prompt.Load(cwd, ref)
+	if err != nil {
+		panic(err)
+	}
+
+	values := make(map[string]string)
+	for _, v := range vars {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok {
+			panic(fmt.Errorf("invalid variable %q, expected key=value", v))
+		}
+		values[key] = value
+	}
+
+	rendered, err := p.Render(values)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print(rendered)
+	if !strings.HasSuffix(rendered, "\n") {
+		fmt.Println()
+	}
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/processes"
+	"langforge/tui"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List, stop and restart the servers and processes that langforge started",
+	Long: `The ps command lists the long-running processes that langforge started in all
+projects of the user: the gateways of 'langforge serve' and their workers, the
+JupyterLab of 'langforge lab' and jobs that run in the background. A worker is
+orphaned if the langforge process that started it has exited without stopping
+it.
+
+  langforge ps
+  langforge ps stop 4242
+  langforge ps stop --orphaned
+  langforge ps restart 4242
+
+Processes are asked to exit and killed if they have not after --timeout.
+Stopping a process also stops the processes it started. Gateways on Linux and
+macOS restart their worker without downtime, as on SIGHUP; other processes are
+stopped and started again in the background, with their output written to
+.langforge/<kind>.log in their project. Workers are restarted by restarting
+their gateway.
+
+The processes are kept per user in the langforge directory of the user's
+configuration directory, or in LANGFORGE_HOME if it is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listProcessesCmd()
+	},
+}
+
+var psStopCmd = &cobra.Command{
+	Use:   "stop [pid...]",
+	Short: "Stop processes that langforge started",
+	Args: func(cmd *cobra.Command, args []string) error {
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			return err
+		}
+		orphaned, err := cmd.Flags().GetBool("orphaned")
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 && !all && !orphaned {
+			return fmt.Errorf("pid is missing, or pass --all or --orphaned")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			panic(err)
+		}
+		orphaned, err := cmd.Flags().GetBool("orphaned")
+		if err != nil {
+			panic(err)
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			panic(err)
+		}
+		stopProcessesCmd(args, all, orphaned, timeout)
+	},
+}
+
+var psRestartCmd = &cobra.Command{
+	Use:   "restart [pid]",
+	Short: "Restart a process that langforge started",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("pid is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			panic(err)
+		}
+		restartProcessCmd(args[0], timeout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(psCmd)
+	markProjectIndependent(psCmd)
+	psCmd.AddCommand(psStopCmd)
+	psCmd.AddCommand(psRestartCmd)
+	psStopCmd.Flags().Bool("all", false, "stop all processes")
+	psStopCmd.Flags().Bool("orphaned", false, "stop the orphaned workers")
+	psStopCmd.Flags().Duration("timeout", 10*time.Second, "time to wait for a process to exit before it is killed")
+	psRestartCmd.Flags().Duration("timeout", 10*time.Second, "time to wait for a process to exit before it is killed")
+}
+
+func listProcessesCmd() {
+	list, err := processes.List()
+	if err != nil {
+		panic(err)
+	}
+	if len(list) == 0 {
+		fmt.Println("langforge has no running processes.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, p := range list {
+		port := "-"
+		if p.Port != 0 {
+			port = strconv.Itoa(p.Port)
+		}
+		status := "running"
+		if p.Orphaned() {
+			status = "orphaned"
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(p.PID),
+			p.Kind,
+			p.Project,
+			port,
+			time.Since(p.Started).Round(time.Second).String(),
+			status,
+			p.Dir,
+		})
+	}
+	if err := tui.PrintTable([]string{"PID", "Kind", "Project", "Port", "Uptime", "Status", "Directory"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+// parsePID returns the PID of a process argument.
+func parsePID(arg string) int {
+	pid, err := strconv.Atoi(arg)
+	if err != nil || pid <= 0 {
+		panic(fmt.Errorf("invalid pid %q", arg))
+	}
+	return pid
+}
+
+func stopProcessesCmd(args []string, all bool, orphaned bool, timeout time.Duration) {
+	selected := []*processes.Process{}
+	if all || orphaned {
+		list, err := processes.List()
+		if err != nil {
+			panic(err)
+		}
+		for _, p := range list {
+			if all || p.Orphaned() {
+				selected = append(selected, p)
+			}
+		}
+	}
+	for _, arg := range args {
+		p, err := processes.Find(parsePID(arg))
+		if err != nil {
+			panic(err)
+		}
+		selected = append(selected, p)
+	}
+	if len(selected) == 0 {
+		fmt.Println("No processes to stop.")
+		return
+	}
+
+	failed := false
+	for _, p := range selected {
+		// a process may have been stopped with the process that started it
+		if !p.Running() {
+			continue
+		}
+		if err := processes.Stop(p, timeout); err != nil {
+			fmt.Println(err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Stopped %s.\n", p)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func restartProcessCmd(arg string, timeout time.Duration) {
+	p, err := processes.Find(parsePID(arg))
+	if err != nil {
+		panic(err)
+	}
+	logPath, err := processes.Restart(p, timeout)
+	if err == processes.ErrNotRestartable && p.Orphaned() {
+		panic(fmt.Errorf("%s is orphaned, stop it with 'langforge ps stop %d'", p, p.PID))
+	}
+	if err == processes.ErrNotRestartable && p.Parent != 0 {
+		panic(fmt.Errorf("%s is restarted by its parent, restart pid %d instead", p, p.Parent))
+	}
+	if err != nil {
+		panic(err)
+	}
+	if logPath == "" {
+		fmt.Printf("Restarting the worker of %s.\n", p)
+		return
+	}
+	fmt.Printf("Restarted %s in the background, its output is written to %s.\n", p, logPath)
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/client"
+	"langforge/gateway"
+	"langforge/project"
+	"langforge/python"
+	"langforge/repl"
+	"langforge/schema"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl [chain]",
+	Short: "Invoke a chain interactively",
+	Long: `The repl command starts the worker of a notebook or module and invokes a chain
+with the inputs you enter, printing the tokens of streaming LLMs as they are
+generated. It is quicker than curl or a notebook for checking a change.
+
+The notebook is the one of the chain in langforge.yaml, or the one given with
+--notebook. Inputs may span several lines: end a line with \ to continue it, or
+enter the lines between two """ lines. Chains with several inputs ask for each
+of them or take a JSON object, and chains with memory keep the conversation.
+Type :help for the commands of the REPL.
+
+The inputs are kept in .langforge/repl_history; arrow keys recall the inputs of
+the current session and !<n> those of earlier ones. The worker's output is
+written to .langforge/repl.log. The env variables that langforge.yaml declares
+for a chain are set while it runs, as in 'langforge serve', and its LLMs are
+called with the parameters of the chain's preset or the one given with
+--preset.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		notebook, err := cmd.Flags().GetString("notebook")
+		if err != nil {
+			panic(err)
+		}
+		preset, err := cmd.Flags().GetString("preset")
+		if err != nil {
+			panic(err)
+		}
+		chain := ""
+		if len(args) > 0 {
+			chain = args[0]
+		}
+		replAppCmd(chain, notebook, preset)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+	replCmd.Flags().String("notebook", "", "notebook or module that defines the chain (default from langforge.yaml)")
+	replCmd.Flags().String("preset", "", "preset of langforge.yaml whose parameters the LLMs are called with")
+}
+
+// chainParams returns the encoded parameters of the preset that invocations
+// of a chain use: the given preset, or else the preset of the chain in
+// langforge.yaml. It returns an empty string if no preset applies.
+func chainParams(config *project.Config, preset string, chain string) (string, error) {
+	if preset == "" {
+		if c := config.FindChain(chain); c != nil {
+			preset = c.Preset
+		}
+	}
+	if preset == "" {
+		return "", nil
+	}
+	p := config.FindPreset(preset)
+	if p == nil {
+		return "", fmt.Errorf("preset %s is not declared in %s", preset, project.ConfigFileName)
+	}
+	return gateway.EncodePreset(p)
+}
+
+// replEntry returns the notebook or module of a chain according to
+// langforge.yaml, or the one of all declared chains if chain is empty or not
+// declared.
+func replEntry(config *project.Config, chain string) (string, error) {
+	if chain != "" && config.FindChain(chain) == nil {
+		chain = ""
+	}
+	entry := ""
+	for _, c := range config.Chains {
+		if chain != "" && c.Name != chain {
+			continue
+		}
+		if entry != "" && c.Notebook != entry {
+			return "", fmt.Errorf("the chains are defined in several notebooks, choose one with --notebook")
+		}
+		entry = c.Notebook
+	}
+	if entry == "" {
+		return "", fmt.Errorf("no notebook is declared for the chain in %s, pass it with --notebook", project.ConfigFileName)
+	}
+	return entry, nil
+}
+
+func replAppCmd(chain string, notebookPath string, preset string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	if notebookPath == "" {
+		notebookPath, err = replEntry(config, chain)
+		if err != nil {
+			panic(err)
+		}
+	}
+	for _, c := range config.Chains {
+		if _, err := chainParams(config, preset, c.Name); err != nil {
+			panic(err)
+		}
+	}
+	if _, err := chainParams(config, preset, ""); err != nil {
+		panic(err)
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+	if err := python.SetJupyterEnvironmentVariables(cwd); err != nil {
+		panic(err)
+	}
+	exportDotEnv(cwd)
+	exportEmbeddingsCache(cwd, config)
+
+	prepareWorker(cwd, notebookPath, false)
+
+	stateDir, err := project.EnsureStateDir(cwd)
+	if err != nil {
+		panic(err)
+	}
+	logPath := filepath.Join(stateDir, "repl.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		panic(err)
+	}
+	defer logFile.Close()
+
+	fmt.Printf("Starting the worker for %s...\n", notebookPath)
+	limits, err := workerLimits(config)
+	if err != nil {
+		panic(err)
+	}
+	w, err := startWorker(notebookPath, logFile, limits)
+	if err != nil {
+		panic(err)
+	}
+	defer w.stop()
+
+	// stop waiting if the worker exits, e.g. because the notebook fails
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-w.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	schemas, err := schema.WaitAndFetch(ctx, w.client, 5*time.Minute)
+	cancel()
+	if err != nil {
+		w.stop()
+		fmt.Printf("The worker did not start, see %s for its output.\n", logPath)
+		os.Exit(1)
+	}
+	if len(schemas.Chains) == 0 {
+		w.stop()
+		fmt.Printf("%s defines no chains.\n", notebookPath)
+		os.Exit(1)
+	}
+
+	if chain == "" {
+		chain = schemas.Chains[0].Name
+		if len(schemas.Chains) > 1 && tui.IsInteractive() {
+			names := []string{}
+			for _, c := range schemas.Chains {
+				names = append(names, c.Name)
+			}
+			choice, err := tui.EditSelect("Which chain would you like to invoke?", names, false)
+			if err != nil {
+				panic(err)
+			}
+			chain = names[choice]
+		}
+	}
+
+	envs := map[string]string{}
+	for _, c := range config.Chains {
+		if len(c.Env) == 0 {
+			continue
+		}
+		envs[c.Name], err = gateway.EncodeEnv(c.Env)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	session := &repl.Session{
+		Client:  client.NewWithTransport("http://worker", w.client),
+		Schemas: schemas,
+		Headers: func(chain string) map[string]string {
+			params, _ := chainParams(config, preset, chain)
+			return map[string]string{gateway.EnvHeader: envs[chain], gateway.ParamsHeader: params}
+		},
+		HistoryPath: filepath.Join(stateDir, repl.HistoryFileName),
+	}
+	if err := session.Run(chain, os.Stdin, os.Stdout); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/gateway"
+	"langforge/tui"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay [replay.jsonl]",
+	Short: "Re-send captured requests to a local server",
+	Long: `The replay command re-sends requests captured with 'langforge serve --capture'
+to a running server, usually a local development server, and compares the
+responses with the recorded ones. This makes it easy to reproduce issues seen
+in production.
+
+Captured requests are sanitized: credentials are removed from the headers and
+PII is redacted from the bodies, so the replayed inputs contain placeholders
+such as [EMAIL] instead of the original values.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("replay file is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		target, err := cmd.Flags().GetString("url")
+		if err != nil {
+			panic(err)
+		}
+		chain, err := cmd.Flags().GetString("chain")
+		if err != nil {
+			panic(err)
+		}
+		indexes, err := cmd.Flags().GetIntSlice("index")
+		if err != nil {
+			panic(err)
+		}
+		verbose, err := cmd.Flags().GetBool("verbose")
+		if err != nil {
+			panic(err)
+		}
+		replayRequestsCmd(args[0], target, chain, indexes, verbose)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().String("url", "http://localhost:2204", "server to send the requests to")
+	replayCmd.Flags().String("chain", "", "only replay requests to this chain")
+	replayCmd.Flags().IntSlice("index", nil, "only replay the requests with these indexes (starting at 1)")
+	replayCmd.Flags().BoolP("verbose", "v", false, "print the recorded and replayed responses")
+}
+
+func replayRequestsCmd(path string, target string, chain string, indexes []int, verbose bool) {
+	records, err := gateway.LoadRecords(path)
+	if err != nil {
+		panic(err)
+	}
+
+	selected := map[int]bool{}
+	for _, index := range indexes {
+		selected[index] = true
+	}
+
+	rows := [][]string{}
+	failed := 0
+	for i, record := range records {
+		index := i + 1
+		if chain != "" && record.Chain != chain {
+			continue
+		}
+		if len(selected) > 0 && !selected[index] {
+			continue
+		}
+
+		row := []string{strconv.Itoa(index), record.Chain, strconv.Itoa(record.Status)}
+		result, err := gateway.Replay(context.Background(), target, record)
+		if err != nil {
+			failed++
+			rows = append(rows, append(row, "-", "-", "error: "+truncate(err.Error(), 40)))
+			continue
+		}
+
+		outcome := "same"
+		if !result.Matches {
+			outcome = "changed"
+		}
+		rows = append(rows, append(row, strconv.Itoa(result.Status), result.Duration.Round(time.Millisecond).String(), outcome))
+
+		if verbose {
+			fmt.Println(tui.Bold("#%d %s", index, record.Chain))
+			fmt.Printf("Request:  %s\n", record.Request)
+			fmt.Printf("Recorded: %s\n", record.Response)
+			fmt.Printf("Replayed: %s\n", result.Response)
+			tui.EmptyLine()
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No matching requests found.")
+		return
+	}
+
+	err = tui.PrintTable([]string{"#", "Chain", "Recorded", "Replayed", "Duration", "Result"}, rows)
+	if err != nil {
+		panic(err)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d requests could not be replayed.\n", failed, len(rows))
+		os.Exit(1)
+	}
+}
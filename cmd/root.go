@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"langforge/system"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -19,6 +20,15 @@ var rootCmd = &cobra.Command{
 It simplifies the process by handling dependencies, providing 
 Jupyter notebooks for experimentation, and enabling you to 
 interact with your chains via a REST API.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			panic(err)
+		}
+		system.SetDryRun(dryRun)
+		offerSetup(cmd)
+		enterProject(cmd)
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -40,14 +50,18 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.langforge.yaml)")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "print the commands, working directories and environment changes of installs and other commands that change the machine instead of running them")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// recoverFromPanic prints the error of a failed command and exits with status
+// 1, so that scripts and scheduled tasks notice the failure.
 func recoverFromPanic() {
 	if r := recover(); r != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", r)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/system"
+	"langforge/tui"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var runtimesCmd = &cobra.Command{
+	Use:   "runtimes",
+	Short: "List the Python and Node.js interpreters and Python installers of this machine",
+	Long: `The runtimes command lists the Python and Node.js interpreters that langforge
+finds on this machine: in the PATH, the versions installed with pyenv and
+nvm, the conda environments and, on Windows, the interpreters of the py
+launcher and of the registry. Each interpreter is listed with its version,
+its architecture and where it was found.
+
+By default, new virtual environments use the first python3 or python in the
+PATH that is Python 3.8 or newer; on Windows, the newest Python 3.8 or newer
+that the PATH, the py launcher or the registry has. Choose another
+interpreter with 'langforge create --python', either by path or by version,
+e.g. --python 3.11 or --python ">=3.10,<3.13". In a terminal, create asks
+which interpreter to use if there are several.
+
+The command also lists the tools that install Python packages: uv, pip, pipx
+and poetry. langforge installs packages with uv if it is in the PATH, which
+is many times faster, and with pip of the Python interpreter otherwise. Set
+LANGFORGE_INSTALLER=pip to always use pip.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listRuntimesCmd()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runtimesCmd)
+	markProjectIndependent(runtimesCmd)
+}
+
+func listRuntimesCmd() {
+	runtimes := system.DiscoverRuntimes()
+	pythons, hasDefault := pythonRuntimes(runtimes)
+	runtimes = append(pythons, system.FilterRuntimes(runtimes, "node")...)
+	if len(runtimes) == 0 {
+		fmt.Println("No Python or Node.js interpreters found.")
+		return
+	}
+
+	rows := [][]string{}
+	for i, r := range runtimes {
+		name := r.Name
+		if i == 0 && hasDefault {
+			name += " (default)"
+		}
+		rows = append(rows, []string{name, r.Version, r.Arch, r.Source, r.Path})
+	}
+	if err := tui.PrintTable([]string{"Runtime", "Version", "Arch", "Source", "Path"}, rows); err != nil {
+		panic(err)
+	}
+
+	installers := system.FindPythonInstallers()
+	if len(installers) == 0 {
+		return
+	}
+	used, _ := system.FindPythonInstaller()
+	rows = [][]string{}
+	for _, installer := range installers {
+		name, path := installer.Name, installer.Path
+		if used != nil && used.Name == installer.Name {
+			name += " (used)"
+		}
+		if installer.Name == system.InstallerPip {
+			path += " -m pip"
+		}
+		rows = append(rows, []string{name, installer.Version, path})
+	}
+	tui.EmptyLine()
+	if err := tui.PrintTable([]string{"Installer", "Version", "Path"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+// choosePython returns the Python interpreter that a new virtual environment
+// is created with. spec is the path of an interpreter or a version
+// constraint, where a plain version such as "3.11" matches its releases.
+// Without spec, the user chooses in a terminal if there are several
+// interpreters. An empty path means the default interpreter.
+func choosePython(spec string) (string, error) {
+	if spec != "" && (strings.ContainsAny(spec, `/\`) || fileExists(spec)) {
+		if _, err := system.PythonVersion(spec); err != nil {
+			return "", fmt.Errorf("%s is not a Python interpreter: %v", spec, err)
+		}
+		return spec, nil
+	}
+
+	pythons, _ := pythonRuntimes(system.DiscoverRuntimes())
+	matches := func(version string) bool { return true }
+	if spec != "" {
+		if spec[0] >= '0' && spec[0] <= '9' {
+			spec = "==" + spec
+		}
+		var err error
+		matches, err = system.ParseVersionConstraint(spec)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// interpreters that LangChain does not support are not offered
+		matches, _ = system.ParseVersionConstraint(system.DefaultPythonConstraint)
+	}
+
+	candidates := []system.Runtime{}
+	for _, r := range pythons {
+		if matches(r.Version) {
+			candidates = append(candidates, r)
+		}
+	}
+
+	switch {
+	case len(candidates) == 0 && spec != "":
+		return "", fmt.Errorf("no Python interpreter satisfies %s, see 'langforge runtimes'", spec)
+	case len(candidates) == 0:
+		return "", nil
+	case spec != "" || len(candidates) == 1 || !tui.IsInteractive():
+		return candidates[0].Path, nil
+	}
+
+	options := []string{}
+	for _, r := range candidates {
+		options = append(options, fmt.Sprintf("Python %s (%s, %s) %s", r.Version, r.Arch, r.Source, r.Path))
+	}
+	choice, err := tui.EditSelect("Which Python should the virtual environment use?", options, false)
+	if err != nil {
+		return "", err
+	}
+	return candidates[choice].Path, nil
+}
+
+// pythonRuntimes returns the Python interpreters among runtimes with the one
+// that FindPython picks first, and whether there is such a default. The
+// default is added if it was not discovered, e.g. because it is a pyenv shim.
+func pythonRuntimes(runtimes []system.Runtime) ([]system.Runtime, bool) {
+	pythons := system.FilterRuntimes(runtimes, "python")
+	defaultPython, err := system.FindPython()
+	if err != nil {
+		return pythons, false
+	}
+
+	for i, r := range pythons {
+		if samePath(r.Path, defaultPython) {
+			return append(append([]system.Runtime{r}, pythons[:i]...), pythons[i+1:]...), true
+		}
+	}
+	version, err := system.PythonVersion(defaultPython)
+	if err != nil {
+		return pythons, false
+	}
+	arch, _ := system.PythonArch(defaultPython)
+	r := system.Runtime{Name: "python", Version: version, Path: defaultPython, Arch: arch, Source: system.SourcePath}
+	return append([]system.Runtime{r}, pythons...), true
+}
+
+// samePath reports whether two paths lead to the same file.
+func samePath(a string, b string) bool {
+	resolvedA, errA := filepath.EvalSymlinks(a)
+	resolvedB, errB := filepath.EvalSymlinks(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return resolvedA == resolvedB
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/permission"
+	"langforge/secrets"
+	"langforge/tui"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Scan your LangChain application for problems",
+}
+
+var scanSecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Scan the project for committed API keys",
+	Long: `The secrets command scans the files and notebooks of the project, including
+cell outputs, and the package sources generated by 'langforge package' for API
+keys and other secrets that should be in .env instead. Secrets are detected by
+the key formats of LLM providers and other services and by random looking
+values assigned to keys, tokens and passwords. The .env file itself is not
+scanned.
+
+'langforge package' and 'langforge export' run the scan and ask for
+confirmation if it finds secrets.
+
+False positives are allowed in .secretsignore, with either the fingerprint of
+a finding or a gitignore-style pattern of files that are not scanned:
+
+  # test fixtures with fake keys
+  tests/fixtures/
+  3f9a1c2b7d4e8f60
+
+The command exits with status 1 if it finds secrets.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		scanSecretsAppCmd()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+	scanCmd.AddCommand(scanSecretsCmd)
+}
+
+func scanSecretsAppCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	findings := scanSecrets(cwd)
+	if len(findings) == 0 {
+		fmt.Println("No secrets found.")
+		return
+	}
+	printSecretFindings(findings)
+	os.Exit(1)
+}
+
+func scanSecrets(dir string) []secrets.Finding {
+	allowlist, err := secrets.LoadAllowlist(dir)
+	if err != nil {
+		panic(err)
+	}
+	findings, err := secrets.Scan(dir, allowlist)
+	if err != nil {
+		panic(err)
+	}
+	return findings
+}
+
+func printSecretFindings(findings []secrets.Finding) {
+	rows := [][]string{}
+	for _, finding := range findings {
+		rows = append(rows, []string{finding.Location(), finding.Rule, finding.Secret, finding.Fingerprint})
+	}
+	if err := tui.PrintTable([]string{"Location", "Rule", "Secret", "Fingerprint"}, rows); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Found %d possible secrets. Move them to .env, or add the fingerprints of false positives to %s.\n", len(findings), secrets.AllowlistFileName)
+}
+
+// confirmNoSecrets scans the project in dir before its files leave the
+// machine and returns whether to continue.
+func confirmNoSecrets(dir string, force bool) bool {
+	findings := scanSecrets(dir)
+	if len(findings) == 0 {
+		return true
+	}
+	printSecretFindings(findings)
+	return confirmOperation(permission.ShipSecrets, "Continue with possible secrets?", force)
+}
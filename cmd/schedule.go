@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/schedule"
+	"langforge/tui"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "List and run the scheduled tasks of the project",
+	Long: `The schedule command lists the tasks that 'langforge serve' runs on a schedule,
+their history, and runs them on demand. Tasks are langforge commands that
+are declared with a cron expression in langforge.yaml, e.g. to re-ingest the
+documents every night and to run an evaluation every Monday:
+
+  schedule:
+    - name: nightly-ingest
+      cron: "0 3 * * *"
+      command: vectorstore ingest
+    - name: weekly-eval
+      cron: "0 6 * * mon"
+      command: eval qa
+      timeout: 1h
+
+Cron expressions have five fields, minute, hour, day of month, month and day
+of week, in local time; @hourly, @daily, @weekly and @monthly are shortcuts.
+A task is skipped while its previous run has not finished.
+
+Every run is recorded as a job (see 'langforge jobs'), whose log holds the
+output of the command. When a scheduled run fails, the notification channels
+of the project are notified, see 'langforge notifications'.
+
+Tasks only run while the gateway runs, e.g. as a service installed with
+'langforge serve install-service'. Use 'langforge serve --no-schedule' on all
+but one instance when the gateway runs more than once.`,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scheduled tasks with their next and last run",
+	Run: func(cmd *cobra.Command, args []string) {
+		listScheduleCmd()
+	},
+}
+
+var scheduleHistoryCmd = &cobra.Command{
+	Use:   "history [task]",
+	Short: "List the runs of the scheduled tasks, most recent first",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		scheduleHistoryCmdRun(name)
+	},
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run [task]",
+	Short: "Run a scheduled task now",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("task is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		background, err := cmd.Flags().GetBool("background")
+		if err != nil {
+			panic(err)
+		}
+		runScheduledTaskCmd(args[0], background)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleHistoryCmd)
+	scheduleCmd.AddCommand(scheduleRunCmd)
+	scheduleRunCmd.Flags().Bool("background", false, "run the task in a background process")
+}
+
+// loadScheduledTasks returns the current directory and its scheduled tasks.
+func loadScheduledTasks() (string, []*schedule.Task) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	tasks, err := schedule.ParseTasks(config.Schedule)
+	if err != nil {
+		panic(err)
+	}
+	return cwd, tasks
+}
+
+func listScheduleCmd() {
+	cwd, tasks := loadScheduledTasks()
+	if len(tasks) == 0 {
+		fmt.Println("No scheduled tasks. Declare them under schedule in langforge.yaml.")
+		return
+	}
+
+	history, err := schedule.History(cwd, "")
+	if err != nil {
+		panic(err)
+	}
+
+	rows := [][]string{}
+	now := time.Now()
+	for _, task := range tasks {
+		next := "never"
+		if t := task.Cron.Next(now); !t.IsZero() {
+			next = t.Format("2006-01-02 15:04")
+		}
+		last := "-"
+		for _, run := range history {
+			if run.Params["task"] == task.Name {
+				last = fmt.Sprintf("%s %s", run.Status, run.Created.Format("2006-01-02 15:04"))
+				break
+			}
+		}
+		rows = append(rows, []string{task.Name, task.Cron.String(), task.Command, next, last})
+	}
+	if err := tui.PrintTable([]string{"Task", "Cron", "Command", "Next run", "Last run"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func scheduleHistoryCmdRun(name string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	runs, err := schedule.History(cwd, name)
+	if err != nil {
+		panic(err)
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs found.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, run := range runs {
+		duration := "-"
+		if run.Started != nil && run.Finished != nil {
+			duration = run.Finished.Sub(*run.Started).Round(time.Second).String()
+		}
+		rows = append(rows, []string{
+			run.ID,
+			run.Params["task"],
+			run.Params["trigger"],
+			run.Status,
+			run.Created.Format("2006-01-02 15:04:05"),
+			duration,
+			truncate(run.Error, 40),
+		})
+	}
+	if err := tui.PrintTable([]string{"Job", "Task", "Trigger", "Status", "Started", "Duration", "Error"}, rows); err != nil {
+		panic(err)
+	}
+	fmt.Println("Run 'langforge jobs logs <job>' to see the output of a run.")
+}
+
+func runScheduledTaskCmd(name string, background bool) {
+	cwd, tasks := loadScheduledTasks()
+	task := schedule.FindTask(tasks, name)
+	if task == nil {
+		panic(fmt.Errorf("no scheduled task named %s", name))
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	job, err := schedule.Enqueue(cwd, task, schedule.Manual)
+	if err != nil {
+		panic(err)
+	}
+	startJob(cwd, job, background)
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/gateway"
+	"langforge/project"
+	"langforge/protocol"
+	"langforge/python"
+	"langforge/schema"
+	"langforge/tui"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Extract and inspect the input and output schemas of your chains",
+	Long: `The schema command asks the Python server for the input and output schema of
+each chain and stores them in .langforge/schemas.json. The schemas are used to
+validate requests in the serve gateway, to generate an OpenAPI document and to
+generate typed clients.
+
+"langforge serve" refreshes the schemas automatically whenever the server starts.`,
+}
+
+var schemaExtractCmd = &cobra.Command{
+	Use:   "extract [notebook.ipynb | app.ts]",
+	Short: "Extract the chain schemas from a notebook or module",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("notebook is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		regenerateWorker, err := cmd.Flags().GetBool("regenerate-worker")
+		if err != nil {
+			panic(err)
+		}
+		extractSchemasCmd(args[0], regenerateWorker)
+	},
+}
+
+var schemaShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the extracted chain schemas",
+	Run: func(cmd *cobra.Command, args []string) {
+		showSchemasCmd()
+	},
+}
+
+var schemaOpenAPICmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Write an OpenAPI document for the served chains",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			panic(err)
+		}
+		openAPICmd(output, forced(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.AddCommand(schemaExtractCmd)
+	schemaCmd.AddCommand(schemaShowCmd)
+	schemaCmd.AddCommand(schemaOpenAPICmd)
+	schemaOpenAPICmd.Flags().StringP("output", "o", "", "write the document to this file instead of stdout")
+	schemaOpenAPICmd.Flags().Bool("force", false, "overwrite a changed output file without asking")
+	schemaExtractCmd.Flags().Bool("regenerate-worker", false, "regenerate the worker in .langforge, reviewing the changes to an edited worker in a terminal")
+}
+
+func extractSchemasCmd(notebookPath string, regenerateWorker bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	err = python.SetJupyterEnvironmentVariables(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	port, err := gateway.FreePort()
+	if err != nil {
+		panic(err)
+	}
+
+	prepareWorker(cwd, notebookPath, regenerateWorker)
+
+	fmt.Println("Starting server to extract chain schemas...")
+	cmd, err := startServer(notebookPath, port, nil)
+	if err != nil {
+		panic(err)
+	}
+	defer cmd.Process.Kill()
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	client := protocol.NewClient(fmt.Sprintf("127.0.0.1:%d", port))
+	defer client.Close()
+	schemas, err := schema.WaitAndFetch(ctx, client, 5*time.Minute)
+	if err != nil {
+		panic(fmt.Errorf("failed to extract chain schemas: %v", err))
+	}
+
+	err = schema.Save(cwd, schemas)
+	if err != nil {
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	printSchemas(schemas)
+	fmt.Printf("Schemas saved to %s\n", schema.Path(cwd))
+}
+
+func showSchemasCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	schemas, err := loadSchemas(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	printSchemas(schemas)
+	fmt.Printf("Extracted at %s\n", schemas.Extracted.Format("2006-01-02 15:04:05"))
+}
+
+func openAPICmd(output string, force bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	schemas, err := loadSchemas(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := json.MarshalIndent(schema.OpenAPI(config.Name, config.Version, schemas), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	if output == "" {
+		fmt.Println(string(data))
+		return
+	}
+
+	data = append(data, '\n')
+	if !confirmOverwrite(output, data, force) {
+		return
+	}
+	err = os.WriteFile(output, data, 0644)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("OpenAPI document written to %s\n", output)
+}
+
+// loadSchemas loads the extracted schemas and explains how to extract them if
+// that has not happened yet.
+func loadSchemas(dir string) (*schema.Schemas, error) {
+	schemas, err := schema.Load(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no chain schemas found, run 'langforge schema extract <notebook>' or 'langforge serve' first")
+		}
+		return nil, err
+	}
+	return schemas, nil
+}
+
+func printSchemas(schemas *schema.Schemas) {
+	if len(schemas.Chains) == 0 {
+		fmt.Println("No chains found.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, chain := range schemas.Chains {
+		rows = append(rows, []string{chain.Name, schemaFields(chain.Input), schemaFields(chain.Output)})
+	}
+
+	err := tui.PrintTable([]string{"Chain", "Inputs", "Outputs"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// schemaFields lists the properties of an object schema, marking optional ones.
+func schemaFields(s *schema.Schema) string {
+	if s == nil {
+		return ""
+	}
+
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	fields := []string{}
+	for name, property := range s.Properties {
+		field := name + ": " + property.Type
+		if !required[name] {
+			field += "?"
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
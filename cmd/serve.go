@@ -4,45 +4,201 @@ Copyright © Markus Ecker <markus.ecker@gmail.com>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"langforge/analytics"
+	"langforge/budget"
+	"langforge/cassette"
+	"langforge/client"
+	"langforge/diff"
+	"langforge/gateway"
+	"langforge/mockllm"
+	"langforge/notify"
+	"langforge/ports"
+	"langforge/preflight"
+	"langforge/processes"
+	"langforge/project"
+	"langforge/prompt"
+	"langforge/protocol"
 	"langforge/python"
+	"langforge/schedule"
+	"langforge/schema"
+	"langforge/shim"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/tunnel"
+	"langforge/vectorstore"
+	"langforge/watcher"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var serveCmd = &cobra.Command{
-	Use:   "serve [notebook.ipynb]",
+	Use:   "serve [notebook.ipynb | app.ts]",
 	Short: "Serve a LangChain application",
-	Long:  `The serve command serves a LangChain application from a Jupyter notebook.`,
-	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 1 {
-			return fmt.Errorf("notebook is missing")
-		}
-		return nil
-	},
+	Long: `The serve command serves a LangChain application from a Jupyter notebook, or a
+LangChain.js application from a JavaScript or TypeScript module whose exported
+chains and runnables are served by name. Without an argument, it serves the
+notebook or module of the first chain of langforge.yaml, so that 'langforge
+serve --dev' is the development server of projects created with 'langforge
+new'.
+
+Requests are received by a gateway in front of the worker that runs the
+chains. The gateway checks API keys, applies guardrails, presets, canaries,
+structured outputs and transforms, serves an OpenAI compatible API, uploads,
+health checks and images, and records analytics, as configured in
+langforge.yaml.
+
+Before the port is bound, preflight checks verify the environment variables,
+API keys, models, vector store and imports of the notebook; skip them with
+--skip-preflight. With --dev, the server is restarted whenever the notebook,
+langforge.yaml or a prompt template changes, and SIGHUP restarts it in any
+mode. --mock-llm, --record-llm and --replay-llm run the application without
+the provider, --capture records the requests for 'langforge replay' and
+--tunnel exposes the gateway at a public HTTPS URL, which requires API keys in
+auth unless --tunnel-insecure is given.
+
+The configuration reference of the gateway, with examples of every section of
+langforge.yaml, is docs/serve.md in the LangForge repository:
+https://github.com/mme/langforge/blob/main/docs/serve.md`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		entry, err := serveEntry(args)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 		// Directly get the port value without checking for the flag's change
 		port, err := cmd.Flags().GetInt("port")
 		if err != nil {
 			fmt.Printf("Error parsing port: %v\n", err)
 			return
 		}
-		serveAppCmd(args[0], port)
+		options := serveOptions{port: port}
+		options.dev, err = cmd.Flags().GetBool("dev")
+		if err != nil {
+			panic(err)
+		}
+		options.capture, err = cmd.Flags().GetString("capture")
+		if err != nil {
+			panic(err)
+		}
+		options.skipPreflight, err = cmd.Flags().GetBool("skip-preflight")
+		if err != nil {
+			panic(err)
+		}
+		options.noAnalytics, err = cmd.Flags().GetBool("no-analytics")
+		if err != nil {
+			panic(err)
+		}
+		options.skipWarmup, err = cmd.Flags().GetBool("skip-warmup")
+		if err != nil {
+			panic(err)
+		}
+		options.noSchedule, err = cmd.Flags().GetBool("no-schedule")
+		if err != nil {
+			panic(err)
+		}
+		options.tunnel, err = cmd.Flags().GetString("tunnel")
+		if err != nil {
+			panic(err)
+		}
+		options.tunnelInsecure, err = cmd.Flags().GetBool("tunnel-insecure")
+		if err != nil {
+			panic(err)
+		}
+		options.autoPort, err = cmd.Flags().GetBool("auto-port")
+		if err != nil {
+			panic(err)
+		}
+		options.regenerateWorker, err = cmd.Flags().GetBool("regenerate-worker")
+		if err != nil {
+			panic(err)
+		}
+		options.mockLLM, err = cmd.Flags().GetBool("mock-llm")
+		if err != nil {
+			panic(err)
+		}
+		options.recordLLM, err = cmd.Flags().GetString("record-llm")
+		if err != nil {
+			panic(err)
+		}
+		options.replayLLM, err = cmd.Flags().GetString("replay-llm")
+		if err != nil {
+			panic(err)
+		}
+		serveAppCmd(entry, options)
 	},
 }
 
+// serveEntry returns the notebook or module to serve: the argument, or that of
+// the first chain of langforge.yaml.
+func serveEntry(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		return "", err
+	}
+	if len(config.Chains) == 0 || config.Chains[0].Notebook == "" {
+		return "", fmt.Errorf("notebook is missing, name it or declare the chains in %s", project.ConfigFileName)
+	}
+	return config.Chains[0].Notebook, nil
+}
+
 func init() {
 	rootCmd.AddCommand(serveCmd)
 	serveCmd.Flags().Int("port", 2204, "port number to serve LangChain application")
+	serveCmd.Flags().Bool("dev", false, "development mode: reload when the notebook or prompt templates change")
+	serveCmd.Flags().Bool("skip-preflight", false, "do not check the environment before starting the server")
+	serveCmd.Flags().Bool("skip-warmup", false, "do not invoke the chains with their warm-up inputs when a server starts")
+	serveCmd.Flags().String("capture", "", "record sanitized requests and responses to this replay file")
+	serveCmd.Flags().Bool("no-analytics", false, "do not record request metadata in the analytics database")
+	serveCmd.Flags().Bool("no-schedule", false, "do not run the scheduled tasks of langforge.yaml")
+	serveCmd.Flags().String("tunnel", "", "expose the gateway at a public URL with cloudflared or ngrok")
+	serveCmd.Flags().Lookup("tunnel").NoOptDefVal = "auto"
+	serveCmd.Flags().Bool("tunnel-insecure", false, "allow --tunnel without API keys in auth, so that anyone who knows the URL may use the gateway")
+	serveCmd.Flags().Bool("auto-port", false, "use the next free port if the port is in use")
+	serveCmd.Flags().Bool("mock-llm", false, "answer LLM requests with the canned responses of llm-mocks.yaml instead of calling the provider")
+	serveCmd.Flags().String("record-llm", "", "record the LLM requests of the worker in this cassette")
+	serveCmd.Flags().String("replay-llm", "", "answer the LLM requests of the worker from this cassette")
+	serveCmd.Flags().Bool("regenerate-worker", false, "regenerate the worker in .langforge, reviewing the changes to an edited worker in a terminal")
+}
+
+// serveOptions holds the flags of the serve command.
+type serveOptions struct {
+	port             int
+	dev              bool
+	capture          string
+	skipPreflight    bool
+	noAnalytics      bool
+	skipWarmup       bool
+	noSchedule       bool
+	tunnel           string
+	tunnelInsecure   bool
+	autoPort         bool
+	regenerateWorker bool
+	mockLLM          bool
+	recordLLM        string
+	replayLLM        string
 }
 
-func serveAppCmd(notebookPath string, port int) {
+func serveAppCmd(notebookPath string, options serveOptions) {
 
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -50,49 +206,864 @@ func serveAppCmd(notebookPath string, port int) {
 		return
 	}
 
-	venvDir := filepath.Join(cwd, ".venv")
-	if _, err := os.Stat(venvDir); err == nil {
-		// Activate the virtual environment
-		err = python.ActivateEnvironment(venvDir)
+	if !activateProjectEnvironment(cwd) {
+		return
+	}
+
+	err = python.SetJupyterEnvironmentVariables(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	exportDotEnv(cwd)
+
+	mode, cassettePath := llmCassetteMode(options.recordLLM, options.replayLLM)
+	if options.mockLLM && mode != "" {
+		panic(fmt.Errorf("--mock-llm cannot be combined with --%s-llm", mode))
+	}
+	if options.mockLLM {
+		mock := startMockLLM(cwd)
+		defer mock.Close()
+		fmt.Printf("Mocking LLM responses with %s at %s\n", mockllm.FileName, mock.URL())
+	}
+	if mode != "" {
+		c := startCassette(cassettePath, mode)
+		defer c.Close()
+		if mode == cassette.Record {
+			fmt.Printf("Recording LLM requests to %s\n", cassettePath)
+		} else {
+			fmt.Printf("Replaying LLM responses from %s\n", cassettePath)
+		}
+	}
+
+	config, err := loadServeConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	if options.tunnel != "" && config.Auth == nil && !options.tunnelInsecure {
+		panic(fmt.Errorf("--tunnel exposes the chains and uploads of the gateway to anyone who knows its URL, configure API keys in auth of %s or pass --tunnel-insecure", project.ConfigFileName))
+	}
+	if options.tunnel != "" && config.Auth == nil && rateLimited(config) {
+		fmt.Println("Warning: all clients of the tunnel share the rate limit, since they have no API keys.")
+	}
+	exportEmbeddingsCache(cwd, config)
+
+	options.port = resolvePort(options.port, options.autoPort)
+
+	unregister, err := processes.RegisterSelf(processes.Serve, cwd, options.port)
+	if err != nil {
+		fmt.Println("Error registering the server, 'langforge ps' does not list it:", err)
+	}
+	defer unregister()
+
+	if !options.skipPreflight && !runPreflight(cwd, notebookPath, config) {
+		os.Exit(1)
+	}
+	warnModelQuota(config)
+
+	prepareWorker(cwd, notebookPath, options.regenerateWorker)
+
+	limits, err := workerLimits(config)
+	if err != nil {
+		panic(err)
+	}
+	current, err := startWorker(notebookPath, nil, limits)
+	if err != nil {
+		panic(err)
+	}
+
+	gw, err := gateway.New(current.client, config)
+	if err != nil {
+		panic(err)
+	}
+	checks, err := readinessChecks(cwd, config)
+	if err != nil {
+		panic(err)
+	}
+	gw.SetReadinessChecks(checks)
+	ingest, err := fileIngester(cwd, config)
+	if err != nil {
+		panic(err)
+	}
+	gw.SetIngester(ingest)
+	defer gw.Close()
+
+	if options.capture != "" {
+		recorder, err := gateway.NewRecorder(options.capture)
+		if err != nil {
+			panic(err)
+		}
+		defer recorder.Close()
+		gw.SetRecorder(recorder)
+		fmt.Printf("Capturing requests to %s\n", options.capture)
+	}
+
+	var db *analytics.DB
+	if !options.noAnalytics {
+		db, err = analytics.Open(cwd)
+		if err != nil {
+			fmt.Println("Analytics are disabled:", err)
+			db = nil
+		} else {
+			writer := analytics.NewWriter(db)
+			defer writer.Close()
+			gw.SetAnalytics(writer)
+			if err := gw.LoadKeyCosts(db); err != nil {
+				fmt.Println("Error reading the costs of the API keys:", err)
+			}
+		}
+	}
+	if db == nil && gateway.HasKeyBudgets(config.Auth) {
+		fmt.Println("The budgets of the API keys are not enforced, since analytics are disabled.")
+	}
+
+	var budgetWatcher *budget.Watcher
+	if db != nil {
+		budgetWatcher = budget.NewWatcher(cwd, db, config)
+		budgetWatcher.Start()
+		defer budgetWatcher.Stop()
+	} else if budget.Enabled(config.Budget) {
+		fmt.Println("The budget is not watched, since analytics are disabled.")
+	}
+
+	server, err := gateway.NewServer(fmt.Sprintf(":%d", options.port), gw, config.Gateway.Server)
+	if err != nil {
+		panic(err)
+	}
+	go func() {
+		if server.TLSConfig != nil {
+			fmt.Printf("Gateway listening on port %d with HTTPS\n", options.port)
+		} else {
+			fmt.Printf("Gateway listening on port %d\n", options.port)
+		}
+		err := gateway.ListenAndServe(server)
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	if options.tunnel != "" {
+		if server.TLSConfig != nil {
+			panic(fmt.Errorf("--tunnel cannot be combined with the TLS certificate of gateway.server, the tunnel serves HTTPS itself"))
+		}
+		t, err := tunnel.Start(options.tunnel, options.port, confirmDownload)
+		if err != nil {
+			panic(err)
+		}
+		closing := make(chan struct{})
+		defer t.Close()
+		defer close(closing)
+		fmt.Printf("Tunnel (%s) serving at %s\n", t.Provider, t.URL)
+		go func() {
+			<-t.Done()
+			select {
+			case <-closing:
+			default:
+				fmt.Println("The tunnel client exited, the gateway is only reachable locally.")
+			}
+		}()
+	}
+
+	warmup := config
+	if options.skipWarmup {
+		warmup = nil
+	}
+	go refreshSchemas(cwd, current.client, gw, warmup)
+
+	var scheduler *schedule.Scheduler
+	if !options.noSchedule {
+		scheduler, err = schedule.NewScheduler(cwd, config)
 		if err != nil {
-			fmt.Println("Error activating virtual environment:", err)
+			panic(err)
+		}
+		scheduler.Start()
+		defer scheduler.Stop()
+		if tasks := scheduler.Tasks(); len(tasks) > 0 {
+			fmt.Printf("Running %d scheduled tasks, see 'langforge schedule list'\n", len(tasks))
+		}
+	}
+
+	reload := make(chan []string, 1)
+	requestReload := func(changed []string) {
+		select {
+		case reload <- changed:
+		default:
+		}
+	}
+
+	// SIGHUP restarts the server, e.g. after a deploy on a single host
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			requestReload(nil)
+		}
+	}()
+
+	if options.dev {
+		watchForReload(cwd, notebookPath, config, requestReload)
+	}
+
+	// stop on interrupts, so that the tunnel is closed and buffered analytics
+	// are written
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	workerRestarts := &restarts{}
+	for {
+		select {
+		case <-interrupt:
+			current.stop()
 			return
+		case <-current.done:
+			crash := current.exitError()
+			if crash != nil {
+				recordWorkerCrash(cwd, config, crash)
+			}
+			restart, delay := workerRestarts.next(config.Worker, crash)
+			if !restart {
+				if crash != nil {
+					panic(crash)
+				}
+				return
+			}
+			if crash != nil {
+				fmt.Printf("Error: %v\n", crash)
+			}
+			fmt.Printf("The server exited, restarting it in %s...\n", delay)
+			select {
+			case <-interrupt:
+				return
+			case <-time.After(delay):
+			}
+			if limits, err = workerLimits(config); err != nil {
+				panic(err)
+			}
+			next, err := startWorker(notebookPath, nil, limits)
+			if err != nil {
+				panic(err)
+			}
+			current = next
+			gw.SetBackend(current.client)
+			go refreshSchemas(cwd, current.client, gw, warmup)
+		case changed := <-reload:
+			if len(changed) > 0 {
+				fmt.Printf("%s changed, restarting server...\n", strings.Join(changed, ", "))
+			} else {
+				fmt.Println("Restarting server...")
+			}
+			start := time.Now()
+			next, err := restartWorker(cwd, notebookPath, gw, current, !options.skipWarmup)
+			if err != nil {
+				fmt.Printf("Error restarting server, the current server keeps running: %v\n", err)
+				// restarts on SIGHUP are deploys, those of --dev are edits
+				if changed == nil {
+					go sendNotification(config, &notify.Event{
+						Kind:    notify.DeployFailed,
+						Title:   "Deploy failed",
+						Message: fmt.Sprintf("The new server did not start, the current server keeps running: %v", err),
+					})
+				}
+				continue
+			}
+			current = next
+			config = reloadServeConfig(cwd, config, scheduler, budgetWatcher)
+			if changed == nil {
+				go sendNotification(config, &notify.Event{
+					Kind:    notify.DeployCompleted,
+					Title:   "Deploy completed",
+					Message: fmt.Sprintf("The new server is serving after %s.", time.Since(start).Round(time.Second)),
+				})
+			}
 		}
-	} else {
-		fmt.Println("No virtual environment found. Continuing in the current environment.")
 	}
+}
 
-	err = python.SetJupyterEnvironmentVariables(cwd)
+// reloadServeConfig applies the configuration of the project in dir to the
+// scheduler and the budget watcher after a restart and returns it. If it is
+// invalid, the previous configuration stays in effect.
+func reloadServeConfig(dir string, previous *project.Config, scheduler *schedule.Scheduler, budgetWatcher *budget.Watcher) *project.Config {
+	config, err := loadServeConfig(dir)
+	if err != nil {
+		fmt.Printf("Error reloading langforge.yaml, the previous configuration stays in effect: %v\n", err)
+		return previous
+	}
+	if scheduler != nil {
+		if err := scheduler.Update(config); err != nil {
+			fmt.Printf("Error updating the scheduled tasks, the previous tasks keep running: %v\n", err)
+		}
+	}
+	if budgetWatcher != nil {
+		budgetWatcher.Update(config)
+	}
+	return config
+}
+
+// readinessChecks returns the checks of the backends of the project in dir
+// that the gateway's readiness probe waits for besides the worker.
+func readinessChecks(dir string, config *project.Config) ([]gateway.ReadinessCheck, error) {
+	if config.VectorStore == (project.VectorStoreConfig{}) {
+		return nil, nil
+	}
+	check, err := vectorstore.NewHealthCheck(dir, config.VectorStore)
+	if err != nil {
+		return nil, err
+	}
+	return []gateway.ReadinessCheck{{Name: "vectorstore", Check: check.Check}}, nil
+}
+
+// fileIngester returns the function that the gateway ingests uploads into the
+// vector store of the project in dir with, nil if uploads are off or the
+// project has no vector store.
+func fileIngester(dir string, config *project.Config) (gateway.IngestFunc, error) {
+	if config.Gateway.Files == nil || config.VectorStore == (project.VectorStoreConfig{}) {
+		return nil, nil
+	}
+	store, err := vectorstore.New(dir, config)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, path string, id string) (int, error) {
+		return vectorstore.IngestFile(ctx, dir, store, path, id)
+	}, nil
+}
+
+// watchForReload watches the notebook, the prompt templates and langforge.yaml
+// in development mode and auto-ingests documents if configured.
+func watchForReload(dir string, notebookPath string, config *project.Config, onChange func([]string)) {
+	if config.VectorStore.AutoIngest {
+		store, err := vectorstore.New(dir, config)
+		if err != nil {
+			panic(err)
+		}
+		if vectorstore.HasPipeline(store.Config()) {
+			fmt.Println("Re-ingesting the documents of the ingestion sources on changes")
+		} else {
+			fmt.Printf("Re-ingesting documents in %s on changes\n", store.Config().Docs)
+		}
+		go func() {
+			err := vectorstore.WatchDocuments(dir, store, nil)
+			if err != nil {
+				fmt.Println("Error watching documents:", err)
+			}
+		}()
+	}
+
+	notebook, err := filepath.Abs(notebookPath)
+	if err != nil {
+		panic(err)
+	}
+	notebookRel, err := filepath.Rel(dir, notebook)
 	if err != nil {
 		panic(err)
 	}
+	notebookRel = filepath.ToSlash(notebookRel)
+
+	options := watcher.Options{
+		Filter: func(rel string) bool {
+			if rel == notebookRel || rel == project.ConfigFileName || prompt.IsTemplatePath(rel) {
+				return true
+			}
+			// modules import other modules of the project
+			return shim.IsNode(notebookRel) && shim.IsNode(rel)
+		},
+	}
+	go func() {
+		err := watcher.Watch(dir, options, nil, onChange)
+		if err != nil {
+			fmt.Println("Error watching for changes:", err)
+		}
+	}()
+}
+
+// worker is a running Python server and the gateway's connection to it.
+type worker struct {
+	cmd     *exec.Cmd
+	client  *protocol.Client
+	done    chan struct{}
+	err     error
+	stopped int32
+	// killed is set if langforge killed the worker after it lost the
+	// connection to it.
+	killed      int32
+	limits      system.Limits
+	outOfMemory bool
+}
+
+// startWorker starts the Python server for the notebook on a free port within
+// the resource limits. Its output is written to output, or to the output of
+// langforge if it is nil.
+func startWorker(notebookPath string, output io.Writer, limits system.Limits) (*worker, error) {
+	port, err := gateway.FreePort()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := startServer(notebookPath, port, output)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &worker{
+		cmd:    cmd,
+		client: protocol.NewClient(fmt.Sprintf("127.0.0.1:%d", port)),
+		done:   make(chan struct{}),
+		limits: limits,
+	}
+	var group *system.ResourceGroup
+	if !limits.IsZero() {
+		if group, err = system.LimitProcess(cmd, limits); err != nil {
+			fmt.Println("The resources of the server are not limited:", err)
+		}
+	}
+	registerWorker(cmd.Process.Pid, port)
+	go func() {
+		w.err = cmd.Wait()
+		if group != nil {
+			w.outOfMemory = group.OutOfMemory()
+			group.Close()
+		}
+		processes.Unregister(cmd.Process.Pid)
+		w.client.Close()
+		close(w.done)
+	}()
 
-	// Add filename and --port arguments to the command
-	cmd := exec.Command("python", "-", notebookPath, "--port", strconv.Itoa(port))
-	stdin, err := cmd.StdinPipe()
+	// a server that stops answering is stopped, so that it is not left
+	// running without serving requests
+	go func() {
+		<-w.client.Done()
+		if atomic.LoadInt32(&w.stopped) == 0 {
+			select {
+			case <-w.done:
+			default:
+				fmt.Println("Lost the connection to the server:", w.client.Err())
+				atomic.StoreInt32(&w.killed, 1)
+				cmd.Process.Kill()
+			}
+		}
+	}()
+	return w, nil
+}
+
+// registerWorker records a worker that langforge started in the current
+// directory for 'langforge ps'. Failures are ignored, the worker is only not
+// listed.
+func registerWorker(pid int, port int) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	processes.Register(&processes.Process{
+		PID:    pid,
+		Kind:   processes.Worker,
+		Port:   port,
+		Dir:    cwd,
+		Parent: os.Getpid(),
+	})
+}
+
+func (w *worker) stop() {
+	atomic.StoreInt32(&w.stopped, 1)
+	w.cmd.Process.Kill()
+	<-w.done
+}
+
+// drainTimeout is how long a replaced worker may take to complete the
+// requests it is serving.
+const drainTimeout = 2 * time.Minute
+
+// restartWorker starts a new worker next to the current one and switches the
+// gateway to it once it is ready. The current worker is stopped after its
+// requests have completed. If the new worker fails to start, the current one
+// keeps serving. With warmup set, the chains are warmed up before the switch.
+func restartWorker(dir string, notebookPath string, gw *gateway.Gateway, current *worker, warmup bool) (*worker, error) {
+	config, err := loadServeConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := workerLimits(config)
+	if err != nil {
+		return nil, err
+	}
+	next, err := startWorker(notebookPath, nil, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	// stop waiting if the new worker exits, e.g. because the notebook fails
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-next.done:
+			cancel()
+		case <-next.client.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	schemas, err := schema.WaitAndFetch(ctx, next.client, 5*time.Minute)
+	cancel()
+	if err != nil {
+		select {
+		case <-next.done:
+			return nil, fmt.Errorf("the new server exited: %v", next.err)
+		default:
+		}
+		next.stop()
+		return nil, fmt.Errorf("the new server did not become ready: %v", err)
+	}
+
+	if warmup {
+		warmUp(next.client, config)
+	}
+
+	checks, err := readinessChecks(dir, config)
+	if err != nil {
+		next.stop()
+		return nil, err
+	}
+	ingest, err := fileIngester(dir, config)
+	if err != nil {
+		next.stop()
+		return nil, err
+	}
+	if err := gw.Reload(config); err != nil {
+		next.stop()
+		return nil, err
+	}
+	gw.SetReadinessChecks(checks)
+	gw.SetIngester(ingest)
+	if err := schema.Save(dir, schemas); err != nil {
+		fmt.Println("Error saving chain schemas:", err)
+	}
+	gw.SetSchemas(schemas)
+
+	previous := gw.SetBackend(next.client)
+	fmt.Println("Switched to the new server.")
+
+	go func() {
+		if !previous.Drain(drainTimeout) {
+			fmt.Println("Stopping the previous server with requests in flight.")
+		}
+		current.stop()
+	}()
+
+	return next, nil
+}
+
+// resolvePort returns the port to serve on. If the port is in use, the process
+// that listens on it is reported and the user may stop a previous langforge
+// instance or use the next free port.
+func resolvePort(port int, autoPort bool) int {
+	if ports.Available(port) {
+		return port
+	}
+
+	owner, err := ports.FindOwner(port)
+	if err != nil {
+		fmt.Println("Error identifying the process that uses the port:", err)
+	}
+	switch {
+	case owner == nil:
+		fmt.Printf("Port %d is in use by another process.\n", port)
+	case owner.IsLangforge():
+		fmt.Printf("Port %d is in use by %s, a previous langforge instance.\n", port, owner)
+	default:
+		fmt.Printf("Port %d is in use by %s.\n", port, owner)
+	}
+	if owner != nil && owner.Command != "" {
+		fmt.Printf("  %s\n", owner.Command)
+	}
+
+	next, err := ports.NextFree(port)
 	if err != nil {
 		panic(err)
 	}
+	if autoPort {
+		fmt.Printf("Using port %d instead.\n", next)
+		return next
+	}
+	if !tui.IsInteractive() {
+		fmt.Println("Stop the process, choose another port with --port or use --auto-port.")
+		os.Exit(1)
+	}
+
+	options := []string{fmt.Sprintf("Use port %d", next), "Cancel"}
+	stop := owner != nil && owner.IsLangforge()
+	if stop {
+		options = append([]string{fmt.Sprintf("Stop %s and use port %d", owner, port)}, options...)
+	}
+	choice, err := tui.EditSelect("What would you like to do?", options, false)
+	if err != nil {
+		panic(err)
+	}
+	if !stop {
+		choice++
+	}
+
+	switch choice {
+	case 0:
+		if err := ports.Stop(owner, port, 10*time.Second); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Stopped %s.\n", owner)
+		return port
+	case 1:
+		return next
+	default:
+		os.Exit(1)
+	}
+	return port
+}
 
-	pythonScript, err := python.ServerPy()
+// runPreflight runs the preflight checks and prints a checklist. It returns
+// false if any check failed.
+func runPreflight(dir string, notebookPath string, config *project.Config) bool {
+	env, err := system.GetEnv(dir)
 	if err != nil {
 		panic(err)
 	}
 
-	// Set Stdout and Stderr to stream the output
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// the API keys of Python integrations do not apply to Node workers
+	required := []string{}
+	if !shim.IsNode(notebookPath) {
+		handler := python.NewPythonHandler(dir)
+		err = handler.DetermineInstalledIntegrations()
+		if err != nil {
+			panic(err)
+		}
+		required = handler.InstalledIntegrationsApiKeys()
+	}
+
+	fmt.Println("Running preflight checks...")
+	checks := preflight.Run(context.Background(), preflight.Options{
+		Dir:      dir,
+		Notebook: notebookPath,
+		Config:   config,
+		Env:      env,
+		Required: required,
+		Timeout:  10 * time.Second,
+		MockLLM:  llmOffline(),
+	})
+
+	ok := true
+	for _, check := range checks {
+		switch {
+		case !check.OK():
+			ok = false
+			fmt.Printf("  ✗ %s\n", tui.Bold(check.Name))
+			for _, problem := range check.Problems {
+				fmt.Printf("      %s\n", problem)
+			}
+			fmt.Printf("      %s\n", check.Hint)
+		case check.Skipped != "":
+			fmt.Printf("  - %s (skipped: %s)\n", check.Name, check.Skipped)
+		default:
+			fmt.Printf("  ✓ %s\n", check.Name)
+		}
+	}
+	tui.EmptyLine()
+
+	if !ok {
+		fmt.Println("Preflight checks failed. Fix the problems above or use --skip-preflight.")
+	}
+	return ok
+}
+
+// refreshSchemas waits for the worker to start, stores the schemas of its chains
+// in the project state and hands them to the gateway. If warmup is not nil,
+// its chains are warmed up first, since the gateway reports ready once it has
+// the schemas.
+func refreshSchemas(dir string, client *protocol.Client, gw *gateway.Gateway, warmup *project.Config) {
+	schemas, err := schema.WaitAndFetch(context.Background(), client, 5*time.Minute)
+	if err != nil {
+		fmt.Println("Error extracting chain schemas:", err)
+		return
+	}
+	if warmup != nil {
+		warmUp(client, warmup)
+	}
+	if err := schema.Save(dir, schemas); err != nil {
+		fmt.Println("Error saving chain schemas:", err)
+	}
+	gw.SetSchemas(schemas)
+}
+
+// warmupTimeout bounds the time a warm-up invocation may take.
+const warmupTimeout = 2 * time.Minute
+
+// warmUp invokes the chains of config with their warm-up inputs on the worker
+// reached through transport, one at a time and with the env variables of the
+// chain and its preset. Failures are reported and otherwise ignored.
+func warmUp(transport http.RoundTripper, config *project.Config) {
+	for _, chain := range config.Chains {
+		if len(chain.Warmup) == 0 {
+			continue
+		}
+		c := client.NewWithTransport("http://worker", transport)
+		if len(chain.Env) > 0 {
+			env, err := gateway.EncodeEnv(chain.Env)
+			if err != nil {
+				fmt.Printf("Error warming up %s: %v\n", chain.Name, err)
+				continue
+			}
+			c.SetHeader(gateway.EnvHeader, env)
+		}
+		params, err := chainParams(config, "", chain.Name)
+		if err != nil {
+			fmt.Printf("Error warming up %s: %v\n", chain.Name, err)
+			continue
+		}
+		c.SetHeader(gateway.ParamsHeader, params)
 
-	err = cmd.Start()
+		fmt.Printf("Warming up %s...\n", chain.Name)
+		start := time.Now()
+		failed := false
+		for i, inputs := range chain.Warmup {
+			ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+			_, err := c.Invoke(ctx, chain.Name, inputs)
+			cancel()
+			if err != nil {
+				failed = true
+				fmt.Printf("Warm-up %d of %s failed: %v\n", i+1, chain.Name, err)
+			}
+		}
+		if !failed {
+			fmt.Printf("Warmed up %s in %s.\n", chain.Name, time.Since(start).Round(time.Millisecond))
+		}
+	}
+}
+
+// prepareWorker makes sure that the project in dir has a worker shim for the
+// entry point that speaks the protocol of the gateway. Incompatible shims are
+// regenerated if the user agrees.
+func prepareWorker(dir string, entry string, regenerate bool) {
+	workerShim := shim.For(entry)
+	if regenerate {
+		regenerateWorker(dir, workerShim)
+		return
+	}
+
+	status, err := workerShim.Ensure(dir)
 	if err != nil {
 		panic(err)
 	}
+	if message := workerShim.Message(status); message != "" {
+		fmt.Println(message)
+	}
 
-	io.WriteString(stdin, strings.TrimSpace(string(pythonScript)))
-	stdin.Close()
+	switch status.State {
+	case shim.Modified:
+		fmt.Printf("Using the edited worker %s.\n", workerShim.RelPath())
+	case shim.Incompatible:
+		fmt.Printf("The worker %s speaks protocol version %d, this version of langforge requires version %d.\n", workerShim.RelPath(), status.Protocol, protocol.Version)
+		if status.Modified {
+			fmt.Println("It was edited, regenerating it discards the changes.")
+		}
+		if !tui.IsInteractive() {
+			fmt.Println("Regenerate it with --regenerate-worker.")
+			os.Exit(1)
+		}
+		confirmed, err := tui.PromptYesNo("Regenerate the worker?", !status.Modified)
+		if err != nil {
+			panic(err)
+		}
+		if !confirmed {
+			os.Exit(1)
+		}
+		if err := workerShim.Generate(dir); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Regenerated the worker %s.\n", workerShim.RelPath())
+	}
+}
 
-	err = cmd.Wait()
+// regenerateWorker replaces the worker shim of the project in dir with the
+// generated one. In a terminal, the changes to an edited shim are reviewed
+// first, so that the user can keep their edits.
+func regenerateWorker(dir string, workerShim *shim.Shim) {
+	content, err := workerShim.Content()
 	if err != nil {
 		panic(err)
 	}
+	existing, err := os.ReadFile(workerShim.Path(dir))
+	if err != nil && !os.IsNotExist(err) {
+		panic(err)
+	}
+
+	if err == nil && string(existing) != content && tui.IsInteractive() {
+		rel := filepath.ToSlash(workerShim.RelPath())
+		patch := diff.New("a/"+rel, "b/"+rel, string(existing), content, 3)
+		content, changed, err := tui.ReviewPatch(patch)
+		if err != nil {
+			panic(err)
+		}
+		if !changed {
+			fmt.Printf("Kept the worker %s.\n", workerShim.RelPath())
+			return
+		}
+		if err := workerShim.Write(dir, content); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Updated the worker %s.\n", workerShim.RelPath())
+		return
+	}
+
+	if err := workerShim.Write(dir, content); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Regenerated the worker %s.\n", workerShim.RelPath())
+}
+
+// startServer starts the worker shim of the project in the current directory
+// for the given notebook or module on the loopback interface and returns the
+// running command.
+func startServer(notebookPath string, port int, output io.Writer) (*exec.Cmd, error) {
+	cmd, err := shim.For(notebookPath).Command(".", notebookPath, port)
+	if err != nil {
+		return nil, err
+	}
+
+	env, masker, err := projectEnviron(".", cmd.Env)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Env = env
+
+	// Set Stdout and Stderr to stream the output, without the secrets of
+	// the environment
+	cmd.Stdout = masker.Writer(os.Stdout)
+	cmd.Stderr = masker.Writer(os.Stderr)
+	if output != nil {
+		cmd.Stdout = masker.Writer(output)
+		cmd.Stderr = cmd.Stdout
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// confirmDownload asks whether to download a tool that langforge needs, e.g.
+// the client of a tunnel. Without a terminal, nothing is downloaded.
+func confirmDownload(question string) bool {
+	confirmed, err := tui.PromptYesNo(question, true)
+	if err != nil {
+		fmt.Println("Error asking for confirmation:", err)
+		return false
+	}
+	return confirmed
+}
+
+// rateLimited reports whether the gateway of config limits the rate of
+// requests per client.
+func rateLimited(config *project.Config) bool {
+	for _, entry := range config.Gateway.Middleware {
+		if entry.Name == gateway.MiddlewareRateLimit {
+			return true
+		}
+	}
+	return false
 }
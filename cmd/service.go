@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/permission"
+	"langforge/project"
+	"langforge/service"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var serveInstallServiceCmd = &cobra.Command{
+	Use:   "install-service [notebook.ipynb | app.ts]",
+	Short: "Start the gateway of the project on boot",
+	Long: `The install-service command registers 'langforge serve' for the project in the
+current directory with the service manager of the system, so that a deployed
+gateway starts on boot and is restarted when it fails:
+
+  Linux    a systemd unit, a user unit unless run as root
+  macOS    a launchd agent, a launch daemon if run as root
+  Windows  a scheduled task that runs at logon, or at startup as SYSTEM if
+           run as administrator
+
+User units of systemd only start on boot if lingering is enabled for the user
+with 'loginctl enable-linger'. The output of the server is appended to
+.langforge/service.log. Installing the service again replaces it.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("notebook is missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		port, err := cmd.Flags().GetInt("port")
+		if err != nil {
+			panic(err)
+		}
+		options := serveOptions{port: port}
+		options.skipPreflight, err = cmd.Flags().GetBool("skip-preflight")
+		if err != nil {
+			panic(err)
+		}
+		options.noAnalytics, err = cmd.Flags().GetBool("no-analytics")
+		if err != nil {
+			panic(err)
+		}
+		installServiceCmd(args[0], options)
+	},
+}
+
+var serveUninstallServiceCmd = &cobra.Command{
+	Use:   "uninstall-service",
+	Short: "Stop the gateway service of the project and remove it",
+	Run: func(cmd *cobra.Command, args []string) {
+		uninstallServiceCmd(forced(cmd))
+	},
+}
+
+var serveServiceStatusCmd = &cobra.Command{
+	Use:   "service-status",
+	Short: "Show whether the gateway service of the project is installed and running",
+	Run: func(cmd *cobra.Command, args []string) {
+		serviceStatusCmd()
+	},
+}
+
+func init() {
+	serveCmd.AddCommand(serveInstallServiceCmd)
+	serveCmd.AddCommand(serveUninstallServiceCmd)
+	serveCmd.AddCommand(serveServiceStatusCmd)
+	serveInstallServiceCmd.Flags().Int("port", 2204, "port number to serve LangChain application")
+	serveInstallServiceCmd.Flags().Bool("skip-preflight", false, "do not check the environment before starting the server")
+	serveInstallServiceCmd.Flags().Bool("no-analytics", false, "do not record request metadata in the analytics database")
+	serveUninstallServiceCmd.Flags().Bool("force", false, "do not ask for confirmation")
+}
+
+// projectService returns the directory and service name of the project in
+// the current directory.
+func projectService() (string, string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+	name := config.Name
+	if name == "" {
+		name = filepath.Base(cwd)
+	}
+	return cwd, service.Name(name)
+}
+
+func installServiceCmd(notebookPath string, options serveOptions) {
+	dir, name := projectService()
+
+	if _, err := os.Stat(filepath.Join(dir, notebookPath)); err != nil {
+		panic(fmt.Errorf("notebook %s not found: %v", notebookPath, err))
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	args := []string{executable, "serve", notebookPath, "--port", strconv.Itoa(options.port)}
+	if options.skipPreflight {
+		args = append(args, "--skip-preflight")
+	}
+	if options.noAnalytics {
+		args = append(args, "--no-analytics")
+	}
+
+	logPath := filepath.Join(dir, ".langforge", "service.log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		panic(err)
+	}
+
+	spec := &service.Spec{
+		Name:        name,
+		Description: fmt.Sprintf("LangForge gateway of %s", filepath.Base(dir)),
+		Dir:         dir,
+		Args:        args,
+		// service managers start commands with a minimal PATH, in which Python
+		// and the tools of the project are usually missing
+		Env:     map[string]string{"PATH": os.Getenv("PATH")},
+		LogPath: logPath,
+	}
+	location, err := service.Install(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Installed service %s (%s)\n", name, location)
+	fmt.Printf("The gateway listens on port %d, its output is appended to %s\n", options.port, logPath)
+}
+
+func uninstallServiceCmd(force bool) {
+	_, name := projectService()
+	if !confirmOperation(permission.UninstallService, fmt.Sprintf("Stop and remove the service %s?", name), force) {
+		return
+	}
+	if err := service.Uninstall(name); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Uninstalled service %s\n", name)
+}
+
+func serviceStatusCmd() {
+	_, name := projectService()
+	status, err := service.GetStatus(name)
+	if err != nil {
+		panic(err)
+	}
+
+	if !status.Installed {
+		fmt.Printf("Service %s is not installed.\n", name)
+		return
+	}
+	state := "stopped"
+	if status.Running {
+		state = "running"
+	}
+	fmt.Printf("Service %s is installed (%s) and %s.\n", name, status.Location, state)
+	if status.Detail != "" {
+		fmt.Println(status.Detail)
+	}
+}
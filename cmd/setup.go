@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"langforge/onboarding"
+	"langforge/provider"
+	"langforge/python"
+	"langforge/shim"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/userconfig"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Set up LangForge on this machine",
+	Long: `The setup command gets a machine ready for LangChain applications in a few
+minutes. It
+
+  1. checks Python, Node.js, git and Docker and offers to install missing
+     tools with Homebrew, winget, apt-get, dnf or pacman,
+  2. sets the defaults for new applications,
+  3. verifies and saves the API key of the LLM provider of new applications,
+     OpenAI, Anthropic or Google Gemini, which they get in their .env file,
+     and
+  4. optionally creates a demo application with a question answering chain.
+
+The setup is offered the first time langforge runs in a terminal. The
+configuration is kept in the langforge directory of the user's configuration
+directory, or in LANGFORGE_HOME.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setupAppCmd()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+	markProjectIndependent(setupCmd)
+}
+
+// offerSetup offers the setup the first time langforge runs in a terminal.
+// The command continues after the setup. It is not offered if the output is
+// redirected, where nobody would see the question.
+func offerSetup(cmd *cobra.Command) {
+	if cmd == setupCmd || !tui.IsInteractive() || !term.IsTerminal(int(os.Stdout.Fd())) || userconfig.Exists() {
+		return
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return
+		}
+	}
+
+	tui.EmptyLine()
+	fmt.Println(tui.Bold("Welcome to LangForge!"))
+	fmt.Println("The setup checks the tools that LangChain applications need, configures your")
+	fmt.Println("LLM provider and API key and creates a demo application.")
+	tui.EmptyLine()
+	run, err := tui.PromptYesNo("Run the setup now?", true)
+	if err != nil {
+		panic(err)
+	}
+	tui.EmptyLine()
+	if !run {
+		config, err := userconfig.Load()
+		if err != nil {
+			panic(err)
+		}
+		config.Onboarded = true
+		if err := userconfig.Save(config); err != nil {
+			panic(err)
+		}
+		fmt.Println("Run 'langforge setup' at any time.")
+		tui.EmptyLine()
+		return
+	}
+
+	setupAppCmd()
+	tui.EmptyLine()
+	fmt.Println(tui.Bold("Continuing with '%s'...", cmd.CommandPath()))
+	tui.EmptyLine()
+}
+
+func setupAppCmd() {
+	config, err := userconfig.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	tui.DisplayBanner()
+
+	fmt.Println(tui.Bold("1. Tools"))
+	tui.EmptyLine()
+	ready := setupTools()
+
+	tui.EmptyLine()
+	fmt.Println(tui.Bold("2. Defaults"))
+	tui.EmptyLine()
+	createEnvironment, err := tui.PromptYesNo("Create a virtual environment for new applications?", config.ShouldCreateEnvironment())
+	if err != nil {
+		panic(err)
+	}
+	config.CreateEnvironment = &createEnvironment
+	current, err := provider.Lookup(config.Provider)
+	if config.Provider == "" || err != nil {
+		current = provider.Providers[0]
+	}
+	info, err := chooseProvider(current.Title)
+	if err != nil {
+		panic(err)
+	}
+	config.Provider = info.Name
+
+	tui.EmptyLine()
+	fmt.Println(tui.Bold("3. %s API key", info.Title))
+	tui.EmptyLine()
+	setupProviderKey(info)
+
+	config.Onboarded = true
+	if err := userconfig.Save(config); err != nil {
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	fmt.Println(tui.Bold("4. Demo application"))
+	tui.EmptyLine()
+	if !ready {
+		fmt.Println("Install the missing tools and run 'langforge setup' again to create a demo application.")
+		return
+	}
+	createDemo, err := tui.PromptYesNo("Create a demo application with a question answering chain?", true)
+	if err != nil {
+		panic(err)
+	}
+	if !createDemo {
+		fmt.Println("Run 'langforge create <app-name>' to create your first application.")
+		return
+	}
+	name, err := tui.PromptString("Name of the demo application:", "langforge-demo")
+	if err != nil {
+		panic(err)
+	}
+	createDemoApp(strings.TrimSpace(name), createEnvironment)
+}
+
+// setupTools shows the detected tools, offers to install the missing ones
+// and returns whether the required tools are installed.
+func setupTools() bool {
+	statuses := onboarding.DetectTools()
+	printToolStatuses(statuses)
+
+	manager := onboarding.FindPackageManager()
+	installed := false
+	for _, status := range statuses {
+		if status.OK() {
+			continue
+		}
+		tool := status.Tool
+		var command []string
+		if manager != nil {
+			command = manager.InstallCommand(tool)
+		}
+		if command == nil {
+			fmt.Printf("Install %s from %s.\n", tool.Name, tool.Download)
+			continue
+		}
+
+		install, err := tui.PromptYesNo(fmt.Sprintf("Install %s with '%s'?", tool.Name, strings.Join(command, " ")), tool.Required)
+		if err != nil {
+			panic(err)
+		}
+		if !install {
+			continue
+		}
+		if err := manager.Install(tool); err != nil {
+			fmt.Printf("Installing %s failed: %v\n", tool.Name, err)
+			continue
+		}
+		installed = true
+	}
+
+	if installed {
+		tui.EmptyLine()
+		statuses = onboarding.DetectTools()
+		printToolStatuses(statuses)
+		fmt.Println("Tools installed in this run may only be found in a new terminal.")
+	}
+
+	for _, status := range statuses {
+		if status.Tool.Required && !status.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+func printToolStatuses(statuses []onboarding.Status) {
+	rows := [][]string{}
+	for _, status := range statuses {
+		state := "✓ " + status.Version
+		if !status.OK() {
+			state = "✗ " + status.Problem
+		}
+		purpose := status.Tool.Purpose
+		if !status.Tool.Required {
+			purpose += " (optional)"
+		}
+		rows = append(rows, []string{status.Tool.Name, state, purpose})
+	}
+	if err := tui.PrintTable([]string{"Tool", "Status", "Needed to"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+// chooseProvider asks for the LLM provider of new applications.
+func chooseProvider(current string) (*provider.Info, error) {
+	options := []string{}
+	for _, info := range provider.Providers {
+		options = append(options, info.Title)
+	}
+	choice, err := tui.EditSelect(fmt.Sprintf("Which LLM provider should new applications use? (currently %s)", current), options, false)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Providers[choice], nil
+}
+
+// setupProviderKey asks for the API key of the provider, verifies it and
+// saves it for new applications.
+func setupProviderKey(info *provider.Info) {
+	keys, err := userconfig.Keys()
+	if err != nil {
+		panic(err)
+	}
+
+	key := ""
+	if keys[info.APIKey] != "" {
+		keep, err := tui.PromptYesNo(fmt.Sprintf("%s is already configured. Keep it?", info.APIKey), true)
+		if err != nil {
+			panic(err)
+		}
+		if keep {
+			return
+		}
+	} else if value := os.Getenv(info.APIKey); value != "" {
+		use, err := tui.PromptYesNo(fmt.Sprintf("Use the %s of your environment for new applications?", info.APIKey), true)
+		if err != nil {
+			panic(err)
+		}
+		if use {
+			key = value
+		}
+	}
+
+	if key == "" {
+		fmt.Printf("New applications use %s. Create a key at %s.\n", info.Title, info.KeysURL)
+		key, err = tui.PromptPassword(fmt.Sprintf("Enter your %s API key (leave empty to skip):", info.Title))
+		if err != nil {
+			panic(err)
+		}
+		key = strings.TrimSpace(key)
+	}
+	if key == "" {
+		fmt.Println("Skipped. Run 'langforge keys' in an application to set it there.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	err = verifyProviderKey(ctx, info, key)
+	if err != nil {
+		fmt.Printf("The key could not be verified: %v\n", err)
+		save, err := tui.PromptYesNo("Save it anyway?", provider.CodeOf(err) != provider.AuthenticationFailed)
+		if err != nil {
+			panic(err)
+		}
+		if !save {
+			return
+		}
+	} else {
+		fmt.Println("The key works.")
+	}
+
+	if err := userconfig.SetKey(info.APIKey, key); err != nil {
+		panic(err)
+	}
+	fmt.Println("Saved. New applications get the key in their .env file.")
+}
+
+// verifyProviderKey lists the models of the provider with the key, which
+// fails if the provider rejects it.
+func verifyProviderKey(ctx context.Context, info *provider.Info, key string) error {
+	client, err := provider.New(info.Name, map[string]string{info.APIKey: key})
+	if err != nil {
+		return err
+	}
+	_, err = client.(provider.ModelLister).Models(ctx)
+	return err
+}
+
+// createDemoApp creates an application with the demo notebook and the
+// default integrations in the current directory.
+func createDemoApp(name string, createEnvironment bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+	dir := filepath.Join(cwd, name)
+	if _, err := os.Stat(dir); err == nil {
+		panic(fmt.Errorf("file with name '%s' already exists", dir))
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		panic(err)
+	}
+
+	if createEnvironment {
+		fmt.Println("Creating virtual environment...")
+		if err := python.CreateVirtualEnv(".venv", dir); err != nil {
+			panic(err)
+		}
+		if err := python.ActivateEnvironment(".venv", dir); err != nil {
+			panic(err)
+		}
+	}
+
+	handler := python.NewPythonHandler(dir)
+	if err := handler.DetermineInstalledIntegrations(); err != nil {
+		panic(err)
+	}
+	fmt.Println("Installing dependencies...")
+	tui.EmptyLine()
+	if err := handler.ExecuteChanges(); err != nil {
+		panic(err)
+	}
+
+	if err := shim.Python.Generate(dir); err != nil {
+		panic(err)
+	}
+	if err := onboarding.WriteDemo(dir, name); err != nil {
+		panic(err)
+	}
+
+	dotEnvPath := filepath.Join(dir, ".env")
+	apiKeys := handler.InstalledIntegrationsApiKeys()
+	if err := system.EnsureEnv(dotEnvPath, apiKeys); err != nil {
+		panic(err)
+	}
+	if _, err := userconfig.ApplyKeys(dotEnvPath, apiKeys); err != nil {
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	fmt.Printf("Successfully created the demo application '%s'. Try it:\n", name)
+	tui.EmptyLine()
+	fmt.Printf("  cd %s\n", name)
+	fmt.Printf("  langforge serve %s\n", onboarding.DemoNotebook)
+	fmt.Printf("  langforge repl %s    # in a second terminal\n", onboarding.DemoChain)
+	tui.EmptyLine()
+	fmt.Printf("Open the notebook with 'langforge lab' to change the chain.\n")
+}
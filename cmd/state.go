@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/state"
+	"langforge/tui"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and migrate the state store of your project",
+	Long: `The state command shows and migrates the store that keeps the state of your
+LangChain application, such as the job history.
+
+The backend is configured in langforge.yaml:
+
+  state:
+    backend: sqlite   # "file" (default) or "sqlite"
+    path: .langforge/state.db
+
+The file backend keeps one JSON file per record in .langforge/state. The sqlite
+backend keeps all records in a single database, which stays fast as the history
+grows. It requires the sqlite3 command, version 3.33 or newer.`,
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the configured backend and the number of records per collection",
+	Run: func(cmd *cobra.Command, args []string) {
+		showStateCmd()
+	},
+}
+
+var stateMigrateCmd = &cobra.Command{
+	Use:   "migrate [backend]",
+	Short: "Copy all records to another backend and switch to it",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("backend is missing, use one of %v", state.Backends)
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := cmd.Flags().GetString("path")
+		if err != nil {
+			panic(err)
+		}
+		migrateStateCmd(args[0], path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateMigrateCmd)
+	stateMigrateCmd.Flags().String("path", "", "location of the new store (default depends on the backend)")
+}
+
+func showStateCmd() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	resolved := state.Resolve(cwd, config.State)
+	fmt.Printf("Backend: %s\n", resolved.Backend)
+	fmt.Printf("Location: %s\n", resolved.Path)
+
+	store, err := state.New(cwd, config.State)
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	collections, err := store.Collections()
+	if err != nil {
+		panic(err)
+	}
+	if len(collections) == 0 {
+		fmt.Println("The store is empty.")
+		return
+	}
+
+	rows := [][]string{}
+	for _, collection := range collections {
+		records, err := store.List(collection)
+		if err != nil {
+			panic(err)
+		}
+		rows = append(rows, []string{collection, strconv.Itoa(len(records))})
+	}
+
+	err = tui.PrintTable([]string{"Collection", "Records"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func migrateStateCmd(backend string, path string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	target := project.StateConfig{Backend: backend, Path: path}
+	if state.Resolve(cwd, target) == state.Resolve(cwd, config.State) {
+		fmt.Println("The project already uses this state store.")
+		return
+	}
+
+	src, err := state.New(cwd, config.State)
+	if err != nil {
+		panic(err)
+	}
+	defer src.Close()
+
+	dst, err := state.New(cwd, target)
+	if err != nil {
+		panic(err)
+	}
+	defer dst.Close()
+
+	count, err := state.Copy(dst, src)
+	if err != nil {
+		panic(fmt.Errorf("failed to migrate the state store: %v", err))
+	}
+
+	previous := state.Resolve(cwd, config.State).Path
+	config.State = target
+	if !saveConfig(cwd, config) {
+		fmt.Printf("Copied %d records to the %s backend, %s still uses the previous store at %s.\n", count, backend, project.ConfigFileName, previous)
+		return
+	}
+
+	fmt.Printf("Copied %d records to the %s backend and updated %s.\n", count, backend, project.ConfigFileName)
+	fmt.Printf("The previous store at %s was left in place.\n", previous)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"langforge/testrun"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [-- runner arguments]",
+	Short: "Run the tests of your LangChain application",
+	Long: `The test command runs the project's tests with pytest, or with vitest or jest
+for TypeScript projects, inside the project's virtual environment and with the
+variables of the .env file set.
+
+Arguments after "--" are passed on to the test runner, e.g.
+
+  langforge test --junit reports/junit.xml -- -k retrieval`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runner, err := cmd.Flags().GetString("runner")
+		if err != nil {
+			panic(err)
+		}
+		junit, err := cmd.Flags().GetString("junit")
+		if err != nil {
+			panic(err)
+		}
+		coverage, err := cmd.Flags().GetBool("coverage")
+		if err != nil {
+			panic(err)
+		}
+		runTestsCmd(testrun.Options{
+			Runner:   runner,
+			JUnit:    junit,
+			Coverage: coverage,
+			Args:     args,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	testCmd.Flags().String("runner", "", "test runner to use: pytest, vitest or jest (default: detected)")
+	testCmd.Flags().String("junit", "", "write a JUnit XML report to this file")
+	testCmd.Flags().Bool("coverage", false, "collect code coverage")
+}
+
+func runTestsCmd(options testrun.Options) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	cmd, err := testrun.Command(cwd, options)
+	if err != nil {
+		panic(err)
+	}
+
+	err = cmd.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		panic(err)
+	}
+}
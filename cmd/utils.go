@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"langforge/diff"
+	"langforge/envfile"
+	"langforge/project"
+	"langforge/python"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/userconfig"
+	"os"
+	"path/filepath"
+)
+
+// activateProjectEnvironment activates the virtual or conda environment of
+// the project in dir if there is one. It returns false if activation failed.
+func activateProjectEnvironment(dir string) bool {
+	envDir, err := python.ActivateProjectEnvironment(dir)
+	switch {
+	case err != nil:
+		fmt.Println("Error activating the project environment:", err)
+		return false
+	case envDir != "":
+	case system.ActiveCondaEnv() != "":
+		fmt.Printf("No project environment found. Continuing in the conda environment %s.\n", system.ActiveCondaEnv())
+	default:
+		fmt.Println("No virtual environment found. Continuing in the current environment.")
+	}
+	return true
+}
+
+// exportDotEnv sets the variables of the .env file of the project in dir that
+// langforge.yaml references and that are not set in the environment, since
+// settings such as the API keys of guardrails are expanded by langforge. The
+// other variables are not set in langforge, they reach the processes of the
+// project through their environment, see projectEnviron.
+func exportDotEnv(dir string) {
+	dotEnv, err := envfile.Load(dir)
+	if err != nil {
+		panic(err)
+	}
+	config, err := os.ReadFile(filepath.Join(dir, project.ConfigFileName))
+	if err != nil && !os.IsNotExist(err) {
+		panic(err)
+	}
+	os.Expand(string(config), func(key string) string {
+		if value, ok := dotEnv[key]; ok {
+			if _, set := os.LookupEnv(key); !set {
+				os.Setenv(key, value)
+			}
+		}
+		return ""
+	})
+}
+
+// projectEnviron returns the environment of a process of the project in dir,
+// such as a worker, based on env or that of langforge if it is nil: with the
+// variables of the .env file that it does not set. The masker masks the
+// secrets of the .env file and the keys of the user in the output of the
+// process.
+func projectEnviron(dir string, env []string) ([]string, *envfile.Masker, error) {
+	dotEnv, err := envfile.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := userconfig.Keys()
+	if err != nil {
+		return nil, nil, err
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	return envfile.Environ(env, dotEnv), envfile.NewMasker(dotEnv, keys), nil
+}
+
+// saveConfig writes config to the langforge.yaml file of the project in dir.
+// In a terminal, the changes to an existing file are reviewed first, like
+// those of add-ons, so that users see what a command changes in the file they
+// maintain. It returns false if the user applied none of the changes.
+func saveConfig(dir string, config *project.Config) bool {
+	existing, err := os.ReadFile(project.ConfigPath(dir))
+	if err != nil || !tui.IsInteractive() {
+		if err := project.SaveConfig(dir, config); err != nil {
+			panic(err)
+		}
+		return true
+	}
+
+	data, err := project.UpdateConfig(existing, config)
+	if err != nil {
+		panic(err)
+	}
+	if bytes.Equal(existing, data) {
+		return true
+	}
+	patch := diff.New("a/"+project.ConfigFileName, "b/"+project.ConfigFileName, string(existing), string(data), 3)
+	content, changed, err := tui.ReviewPatch(patch)
+	if err != nil {
+		panic(err)
+	}
+	if !changed {
+		return false
+	}
+	if err := os.WriteFile(project.ConfigPath(dir), []byte(content), 0644); err != nil {
+		panic(err)
+	}
+	return true
+}
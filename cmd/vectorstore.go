@@ -0,0 +1,494 @@
+package cmd
+
+import (
+	"fmt"
+	"langforge/models"
+	"langforge/permission"
+	"langforge/project"
+	"langforge/system"
+	"langforge/tui"
+	"langforge/vectorstore"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var vectorstoreCmd = &cobra.Command{
+	Use:   "vectorstore",
+	Short: "Manage the local vector store of your LangChain application",
+	Long: `The vectorstore command manages a local Chroma or Qdrant instance for your
+LangChain application and runs your ingest script against it.
+
+The vector store is configured in langforge.yaml:
+
+  vectorstore:
+    type: chroma        # chroma, qdrant or pgvector
+    mode: embedded      # embedded (a local directory), docker (a local server)
+                        # or external (a server at url, e.g. of a deployment)
+    collection: langchain
+    ingest: ingest.py
+    docs: docs          # documents to ingest
+    autoIngest: true    # re-ingest changed documents during "serve --dev"
+
+Instead of an ingest script, the documents can be ingested by the pipeline
+declared in vectorstore.ingestion, see 'langforge ingest --help'.
+
+The ingest script receives the connection settings in the environment variables
+LANGFORGE_VECTORSTORE_TYPE, LANGFORGE_VECTORSTORE_COLLECTION and either
+LANGFORGE_VECTORSTORE_PATH (embedded) or LANGFORGE_VECTORSTORE_URL (docker and
+external).
+
+External stores are not started or reset by langforge; init checks that they
+are usable before ingesting. pgvector stores are always external, with a
+postgresql:// URL.
+
+When documents change, the ingest script additionally receives the changed and
+removed documents in LANGFORGE_INGEST_CHANGED and LANGFORGE_INGEST_DELETED so
+that it can update the store incrementally.
+
+The embeddings cache keeps the embeddings of texts by the SHA-256 of their
+content and embedding model, so that re-ingesting unchanged chunks does not
+embed them again, and pay for them again:
+
+  vectorstore:
+    embeddingsCache:
+      path: .langforge/embeddings.db   # the default
+
+Ingest scripts and the chains of the worker receive its path in
+LANGFORGE_EMBEDDINGS_CACHE and wrap their embedding model with the Python
+helper:
+
+  from langforge_embeddings import cached_embeddings   # in ingest scripts
+  embeddings = cached_embeddings(OpenAIEmbeddings())
+
+In notebooks and the worker, cached_embeddings is built in. Without the
+setting it returns the model unchanged. The cache outlives resets of the store
+and is cleared with 'langforge vectorstore cache clear'.`,
+}
+
+var vectorstoreInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create or start the vector store and ingest documents",
+	Run: func(cmd *cobra.Command, args []string) {
+		noIngest, err := cmd.Flags().GetBool("no-ingest")
+		if err != nil {
+			panic(err)
+		}
+		initVectorStoreCmd(!noIngest)
+	},
+}
+
+var vectorstoreResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Delete all data of the vector store and ingest documents again",
+	Run: func(cmd *cobra.Command, args []string) {
+		noIngest, err := cmd.Flags().GetBool("no-ingest")
+		if err != nil {
+			panic(err)
+		}
+		resetVectorStoreCmd(forced(cmd), !noIngest)
+	},
+}
+
+var vectorstoreStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the collections and document counts of the vector store",
+	Run: func(cmd *cobra.Command, args []string) {
+		vectorStoreStatusCmd()
+	},
+}
+
+var vectorstoreIngestCmd = &cobra.Command{
+	Use:   "ingest",
+	Short: "Ingest all documents as a resumable job",
+	Long: `The ingest command ingests the documents of the docs directory in batches as
+a job. The ingest script is run once per batch and receives the documents of
+the batch in LANGFORGE_INGEST_CHANGED. If the job fails or is interrupted, it
+can be resumed with 'langforge jobs resume'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		batchSize, err := cmd.Flags().GetInt("batch-size")
+		if err != nil {
+			panic(err)
+		}
+		background, err := cmd.Flags().GetBool("background")
+		if err != nil {
+			panic(err)
+		}
+		ingestVectorStoreCmd(batchSize, background)
+	},
+}
+
+var vectorstoreMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy the embeddings and metadata of a collection to another vector store",
+	Long: `The migrate command copies the ids, embeddings, documents and metadata of a
+collection from one vector store to another in batches, e.g. when a project
+outgrows the embedded store:
+
+  langforge vectorstore migrate --from chroma --to qdrant
+  langforge vectorstore migrate --from chroma --to pgvector --to-url postgresql://localhost/docs
+
+The store configured in langforge.yaml is used for the side of its type. The
+other side is the embedded store of the type in the project's vector store
+directory, or the server at --from-url or --to-url. The records are written in
+the layout of the LangChain integration of the target store, so chains read
+them like ingested documents once langforge.yaml configures the target store.
+
+Records are upserted by id, so an interrupted migration can be run again.
+Embeddings are copied as they are and must have been computed with the
+embedding model of the chains.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		from, err := cmd.Flags().GetString("from")
+		if err != nil {
+			panic(err)
+		}
+		to, err := cmd.Flags().GetString("to")
+		if err != nil {
+			panic(err)
+		}
+		fromURL, err := cmd.Flags().GetString("from-url")
+		if err != nil {
+			panic(err)
+		}
+		toURL, err := cmd.Flags().GetString("to-url")
+		if err != nil {
+			panic(err)
+		}
+		collection, err := cmd.Flags().GetString("collection")
+		if err != nil {
+			panic(err)
+		}
+		batchSize, err := cmd.Flags().GetInt("batch-size")
+		if err != nil {
+			panic(err)
+		}
+		migrateVectorStoreCmd(from, fromURL, to, toURL, collection, batchSize)
+	},
+}
+
+var vectorstoreCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Show the embeddings in the embeddings cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		embeddingsCacheCmd()
+	},
+}
+
+var vectorstoreCacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the embeddings cache, or the embeddings of a model",
+	Run: func(cmd *cobra.Command, args []string) {
+		model, err := cmd.Flags().GetString("model")
+		if err != nil {
+			panic(err)
+		}
+		clearEmbeddingsCacheCmd(model)
+	},
+}
+
+var vectorstoreWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Re-ingest documents whenever they change",
+	Run: func(cmd *cobra.Command, args []string) {
+		watchVectorStoreCmd()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vectorstoreCmd)
+	vectorstoreCmd.AddCommand(vectorstoreInitCmd)
+	vectorstoreCmd.AddCommand(vectorstoreResetCmd)
+	vectorstoreCmd.AddCommand(vectorstoreStatusCmd)
+	vectorstoreCmd.AddCommand(vectorstoreWatchCmd)
+	vectorstoreCmd.AddCommand(vectorstoreIngestCmd)
+	vectorstoreCmd.AddCommand(vectorstoreMigrateCmd)
+	vectorstoreCmd.AddCommand(vectorstoreCacheCmd)
+	vectorstoreCacheCmd.AddCommand(vectorstoreCacheClearCmd)
+	vectorstoreCacheClearCmd.Flags().String("model", "", "only delete the embeddings of this model, as listed by 'langforge vectorstore cache'")
+	vectorstoreIngestCmd.Flags().Int("batch-size", 20, "number of documents per run of the ingest script")
+	vectorstoreIngestCmd.Flags().Bool("background", false, "run the job in a background process")
+	vectorstoreMigrateCmd.Flags().String("from", "", "type of the store to copy from: chroma, qdrant or pgvector")
+	vectorstoreMigrateCmd.Flags().String("to", "", "type of the store to copy to: chroma, qdrant or pgvector")
+	vectorstoreMigrateCmd.Flags().String("from-url", "", "URL of the server of the store to copy from")
+	vectorstoreMigrateCmd.Flags().String("to-url", "", "URL of the server of the store to copy to")
+	vectorstoreMigrateCmd.Flags().String("collection", "", "collection to copy (default the configured collection)")
+	vectorstoreMigrateCmd.Flags().Int("batch-size", 100, "number of records to copy at a time")
+	vectorstoreMigrateCmd.MarkFlagRequired("from")
+	vectorstoreMigrateCmd.MarkFlagRequired("to")
+	vectorstoreInitCmd.Flags().Bool("no-ingest", false, "do not run the ingest script")
+	vectorstoreResetCmd.Flags().Bool("no-ingest", false, "do not run the ingest script after resetting")
+	vectorstoreResetCmd.Flags().Bool("force", false, "do not ask for confirmation")
+	vectorstoreResetCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+	vectorstoreResetCmd.Flags().MarkDeprecated("yes", "use --force instead")
+}
+
+func loadVectorStore() (string, *project.Config, vectorstore.Store) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("error getting current directory: %v", err))
+	}
+
+	if !activateProjectEnvironment(cwd) {
+		os.Exit(1)
+	}
+
+	config, err := project.LoadConfig(cwd)
+	if err != nil {
+		panic(err)
+	}
+
+	store, err := vectorstore.New(cwd, config)
+	if err != nil {
+		panic(err)
+	}
+
+	return cwd, config, store
+}
+
+func initVectorStoreCmd(ingest bool) {
+	cwd, config, store := loadVectorStore()
+
+	err := store.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	if ingest {
+		ingestVectorStore(cwd, config, store)
+	}
+
+	printVectorStoreStatus(store)
+}
+
+func resetVectorStoreCmd(force bool, ingest bool) {
+	cwd, config, store := loadVectorStore()
+
+	if !confirmOperation(permission.ResetVectorStore, "This deletes all documents in the vector store. Continue?", force) {
+		return
+	}
+
+	err := store.Reset()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Vector store has been reset.")
+
+	err = store.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	if ingest {
+		ingestVectorStore(cwd, config, store)
+	}
+
+	printVectorStoreStatus(store)
+}
+
+func ingestVectorStore(dir string, config *project.Config, store vectorstore.Store) {
+	if vectorstore.HasPipeline(store.Config()) {
+		fmt.Println("Ingesting documents...")
+		runIngestion(dir, store, vectorstore.IngestOptions{})
+		tui.EmptyLine()
+		return
+	}
+
+	script := vectorstore.Resolve(dir, config.VectorStore).Ingest
+	if script == "" {
+		fmt.Println("No ingest script configured. Skipping ingestion.")
+		return
+	}
+
+	fmt.Printf("Running %s...\n", script)
+	err := vectorstore.RunIngest(dir, store, script)
+	if err != nil {
+		panic(err)
+	}
+	tui.EmptyLine()
+}
+
+func vectorStoreStatusCmd() {
+	_, _, store := loadVectorStore()
+	printVectorStoreStatus(store)
+}
+
+func ingestVectorStoreCmd(batchSize int, background bool) {
+	cwd, _, store := loadVectorStore()
+
+	job, err := vectorstore.EnqueueIngest(cwd, store, batchSize)
+	if err != nil {
+		panic(err)
+	}
+
+	startJob(cwd, job, background)
+}
+
+func migrateVectorStoreCmd(from string, fromURL string, to string, toURL string, collection string, batchSize int) {
+	cwd, config, store := loadVectorStore()
+
+	source, err := vectorstore.Endpoint(cwd, config, from, fromURL)
+	if err != nil {
+		panic(err)
+	}
+	target, err := vectorstore.Endpoint(cwd, config, to, toURL)
+	if err != nil {
+		panic(err)
+	}
+	if collection == "" {
+		collection = source.Config().Collection
+	}
+
+	ctx, stop := system.InterruptContext()
+	defer stop()
+
+	var bar *pterm.ProgressbarPrinter
+	started, reported := false, 0
+	migrated, err := vectorstore.Migrate(ctx, source, target, vectorstore.MigrateOptions{
+		Collection: collection,
+		BatchSize:  batchSize,
+		Progress: func(migrated int, total int) {
+			if !started {
+				started = true
+				fmt.Printf("Copying %d records of %s from %s to %s...\n", total, collection, from, to)
+				bar = tui.StartProgress("Records", total)
+			}
+			switch {
+			case bar != nil:
+				bar.Add(migrated - reported)
+			case migrated > 0:
+				fmt.Printf("%d of %d records copied.\n", migrated, total)
+			}
+			reported = migrated
+		},
+	})
+	if bar != nil {
+		bar.Stop()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted after %d records, run the same command again to resume.\n", migrated)
+			os.Exit(1)
+		}
+		panic(err)
+	}
+
+	tui.EmptyLine()
+	fmt.Printf("Copied %d records of %s to the %s store at %s.\n", migrated, collection, to, vectorStoreLocation(target))
+	if store.Config().Type != to || toURL != "" {
+		fmt.Println("Configure the store in the vectorstore section of langforge.yaml to use it.")
+	}
+}
+
+// vectorStoreLocation returns the directory or URL of a store.
+func vectorStoreLocation(store vectorstore.Store) string {
+	if store.Config().Mode == "embedded" {
+		return store.Config().Path
+	}
+	return store.Config().URL
+}
+
+// loadEmbeddingsCache returns the path of the embeddings cache of the
+// project, and exits if the cache is not configured.
+func loadEmbeddingsCache() string {
+	_, _, store := loadVectorStore()
+	path := vectorstore.EmbeddingsCachePath(store.Config())
+	if path == "" {
+		fmt.Println("The embeddings cache is off. Turn it on with vectorstore.embeddingsCache in langforge.yaml.")
+		os.Exit(1)
+	}
+	return path
+}
+
+func embeddingsCacheCmd() {
+	path := loadEmbeddingsCache()
+	cached, err := vectorstore.EmbeddingsCacheStats(path)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Embeddings cache: %s\n", path)
+	if len(cached) == 0 {
+		fmt.Println("The cache is empty.")
+		return
+	}
+	total := 0
+	rows := [][]string{}
+	for _, model := range cached {
+		rows = append(rows, []string{model.Model, model.Kind, fmt.Sprint(model.Count), models.FormatSize(model.Bytes)})
+		total += model.Count
+	}
+	rows = append(rows, []string{tui.Bold("Total"), "", tui.Bold("%d", total), ""})
+	if err := tui.PrintTable([]string{"Model", "Kind", "Embeddings", "Size"}, rows); err != nil {
+		panic(err)
+	}
+}
+
+func clearEmbeddingsCacheCmd(model string) {
+	path := loadEmbeddingsCache()
+	if err := vectorstore.ClearEmbeddingsCache(path, model); err != nil {
+		panic(err)
+	}
+	if model != "" {
+		fmt.Printf("Deleted the embeddings of %s from the cache.\n", model)
+		return
+	}
+	fmt.Println("Embeddings cache cleared.")
+}
+
+// exportEmbeddingsCache passes the path of the embeddings cache of the project
+// in dir to the workers that langforge starts.
+func exportEmbeddingsCache(dir string, config *project.Config) {
+	if path := vectorstore.EmbeddingsCachePath(vectorstore.Resolve(dir, config.VectorStore)); path != "" {
+		os.Setenv("LANGFORGE_EMBEDDINGS_CACHE", path)
+	}
+}
+
+func watchVectorStoreCmd() {
+	cwd, _, store := loadVectorStore()
+
+	config := store.Config()
+	fmt.Printf("Watching %s for changes. Press Ctrl+C to stop.\n", config.Docs)
+	err := vectorstore.WatchDocuments(cwd, store, nil)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func printVectorStoreStatus(store vectorstore.Store) {
+	status, err := store.Status()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Vector store: %s (%s) at %s\n", status.Type, status.Mode, status.Location)
+	if !status.Available {
+		switch status.Mode {
+		case "docker":
+			fmt.Println("Status: not running. Run 'langforge vectorstore init'.")
+		case "external":
+			fmt.Println("Status: not reachable.")
+		default:
+			fmt.Println("Status: not initialized. Run 'langforge vectorstore init'.")
+		}
+		return
+	}
+
+	if len(status.Collections) == 0 {
+		fmt.Println("Status: available, no collections.")
+		return
+	}
+
+	total := 0
+	rows := [][]string{}
+	for _, collection := range status.Collections {
+		rows = append(rows, []string{collection.Name, fmt.Sprint(collection.Count)})
+		total += collection.Count
+	}
+	rows = append(rows, []string{tui.Bold("Total"), tui.Bold("%d", total)})
+
+	err = tui.PrintTable([]string{"Collection", "Documents"}, rows)
+	if err != nil {
+		panic(err)
+	}
+}
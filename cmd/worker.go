@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"langforge/models"
+	"langforge/project"
+	"langforge/system"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Restart policies of the worker of the serve command.
+const (
+	restartNever     = "never"
+	restartOnFailure = "on-failure"
+	restartAlways    = "always"
+)
+
+// defaultMaxRestarts is how many times a worker is restarted within
+// restartWindow unless worker.maxRestarts is set.
+const defaultMaxRestarts = 5
+
+// restartWindow is the time within which the restarts of a worker are
+// counted, the same as for crash loop notifications.
+const restartWindow = crashLoopWindow
+
+// workerLimits returns the resource limits of the worker of a project and
+// checks its restart policy.
+func workerLimits(config *project.Config) (system.Limits, error) {
+	limits := system.Limits{CPUs: config.Worker.CPUs}
+	if config.Worker.Memory != "" {
+		memory, err := models.ParseSize(config.Worker.Memory)
+		if err != nil {
+			return limits, fmt.Errorf("worker.memory: %v", err)
+		}
+		limits.Memory = memory
+	}
+	if config.Worker.CPUs < 0 {
+		return limits, fmt.Errorf("worker.cpus must be positive")
+	}
+	switch config.Worker.Restart {
+	case "", restartNever, restartOnFailure, restartAlways:
+	default:
+		return limits, fmt.Errorf("unknown worker.restart %q, expected never, on-failure or always", config.Worker.Restart)
+	}
+	return limits, nil
+}
+
+// exitError returns why the worker exited, or nil if it exited on its own
+// without an error. Running out of memory is reported as such.
+func (w *worker) exitError() error {
+	switch {
+	case w.outOfMemory:
+		return fmt.Errorf("the server ran out of memory at its limit of %s, raise worker.memory in %s: %v", models.FormatSize(w.limits.Memory), project.ConfigFileName, w.err)
+	case w.err != nil && killedBySignal(w.err) && atomic.LoadInt32(&w.killed) == 0:
+		return fmt.Errorf("the server was killed (%v), e.g. by the out-of-memory killer of the system", w.err)
+	}
+	return w.err
+}
+
+// killedBySignal reports whether a process exited because of SIGKILL.
+func killedBySignal(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGKILL
+}
+
+// restarts counts the restarts of the worker of the serve command.
+type restarts struct {
+	times []time.Time
+}
+
+// next reports whether the policy of config restarts a worker that exited
+// with crash, and how long to wait before. A worker that was restarted the
+// maximum number of times within restartWindow is not restarted again.
+func (r *restarts) next(config project.WorkerConfig, crash error) (bool, time.Duration) {
+	switch config.Restart {
+	case restartAlways:
+	case restartOnFailure:
+		if crash == nil {
+			return false, 0
+		}
+	default:
+		return false, 0
+	}
+
+	max := config.MaxRestarts
+	if max == 0 {
+		max = defaultMaxRestarts
+	}
+	now := time.Now()
+	recent := []time.Time{}
+	for _, t := range r.times {
+		if now.Sub(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	r.times = recent
+	if len(recent) >= max {
+		return false, 0
+	}
+	r.times = append(r.times, now)
+
+	// back off from a worker that exits right after it started
+	delay := time.Second << len(recent)
+	if delay > 30*time.Second {
+		delay = 30 * time.Second
+	}
+	return true, delay
+}
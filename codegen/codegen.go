@@ -0,0 +1,272 @@
+package codegen
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"langforge/bundle"
+	"langforge/schema"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+func init() {
+	files, err := fs.Sub(templates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.Clients, files)
+}
+
+// Languages are the languages clients can be generated for.
+var Languages = []string{"ts", "python", "go"}
+
+// Field is a property of a generated input or output type.
+type Field struct {
+	Name        string
+	JSONName    string
+	Type        string
+	Optional    bool
+	Description string
+}
+
+// Type is a generated input or output type.
+type Type struct {
+	Name   string
+	Fields []Field
+}
+
+// Method invokes a chain.
+type Method struct {
+	Name   string
+	Chain  string
+	Input  string
+	Output string
+}
+
+// File is the data passed to a client template.
+type File struct {
+	Package string
+	Types   []Type
+	Methods []Method
+}
+
+// DefaultOutput returns the default output path for a language.
+func DefaultOutput(lang string) string {
+	switch lang {
+	case "ts":
+		return "langforge-client.ts"
+	case "python":
+		return "langforge_client.py"
+	default:
+		return "langforgeclient/client.go"
+	}
+}
+
+// Generate renders a typed client for the chains in schemas. pkg is the
+// package name of Go clients and is ignored for other languages.
+func Generate(lang string, pkg string, schemas *schema.Schemas) ([]byte, error) {
+	var typeName func(*schema.Schema) string
+	var methodName func(string) string
+
+	switch lang {
+	case "ts":
+		typeName = tsType
+		methodName = lowerCamel
+	case "python":
+		typeName = pythonType
+		methodName = snake
+	case "go":
+		typeName = goType
+		methodName = upperCamel
+	default:
+		return nil, fmt.Errorf("unsupported language %q, expected one of %s", lang, strings.Join(Languages, ", "))
+	}
+
+	file := &File{Package: pkg}
+	for _, chain := range schemas.Chains {
+		base := upperCamel(chain.Name)
+		input := newType(base+"Input", chain.Input, lang, typeName)
+		output := newType(base+"Output", chain.Output, lang, typeName)
+		file.Types = append(file.Types, input, output)
+		file.Methods = append(file.Methods, Method{
+			Name:   methodName(chain.Name),
+			Chain:  chain.Name,
+			Input:  input.Name,
+			Output: output.Name,
+		})
+	}
+
+	files, err := bundle.FS(bundle.Clients)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.ParseFS(files, lang+".tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, file); err != nil {
+		return nil, err
+	}
+
+	if lang == "go" {
+		return format.Source(buf.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+func newType(name string, s *schema.Schema, lang string, typeName func(*schema.Schema) string) Type {
+	t := Type{Name: name}
+	if s == nil {
+		return t
+	}
+
+	required := map[string]bool{}
+	for _, key := range s.Required {
+		required[key] = true
+	}
+
+	keys := make([]string, 0, len(s.Properties))
+	for key := range s.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		property := s.Properties[key]
+		fieldName := key
+		if lang == "go" {
+			fieldName = upperCamel(key)
+		}
+		t.Fields = append(t.Fields, Field{
+			Name:        fieldName,
+			JSONName:    key,
+			Type:        typeName(property),
+			Optional:    !required[key],
+			Description: property.Description,
+		})
+	}
+	return t
+}
+
+func tsType(s *schema.Schema) string {
+	switch s.Type {
+	case "string":
+		return "string"
+	case "number", "integer":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		if s.Items != nil {
+			return tsType(s.Items) + "[]"
+		}
+		return "unknown[]"
+	case "object":
+		return "Record<string, unknown>"
+	default:
+		return "unknown"
+	}
+}
+
+func pythonType(s *schema.Schema) string {
+	switch s.Type {
+	case "string":
+		return "str"
+	case "number":
+		return "float"
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	case "array":
+		if s.Items != nil {
+			return "List[" + pythonType(s.Items) + "]"
+		}
+		return "List[Any]"
+	case "object":
+		return "Dict[str, Any]"
+	default:
+		return "Any"
+	}
+}
+
+func goType(s *schema.Schema) string {
+	switch s.Type {
+	case "string":
+		return "string"
+	case "number":
+		return "float64"
+	case "integer":
+		return "int64"
+	case "boolean":
+		return "bool"
+	case "array":
+		if s.Items != nil {
+			return "[]" + goType(s.Items)
+		}
+		return "[]any"
+	case "object":
+		return "map[string]any"
+	default:
+		return "any"
+	}
+}
+
+// words splits an identifier like "qa_chain" or "qaChain" into its words.
+func words(name string) []string {
+	result := []string{}
+	current := []rune{}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if len(current) > 0 {
+				result = append(result, string(current))
+				current = nil
+			}
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			result = append(result, string(current))
+			current = []rune{r}
+		default:
+			current = append(current, r)
+		}
+	}
+	if len(current) > 0 {
+		result = append(result, string(current))
+	}
+	return result
+}
+
+func upperCamel(name string) string {
+	var b strings.Builder
+	for _, word := range words(name) {
+		b.WriteString(strings.ToUpper(word[:1]) + strings.ToLower(word[1:]))
+	}
+	return b.String()
+}
+
+func lowerCamel(name string) string {
+	camel := upperCamel(name)
+	if camel == "" {
+		return camel
+	}
+	return strings.ToLower(camel[:1]) + camel[1:]
+}
+
+func snake(name string) string {
+	parts := words(name)
+	for i := range parts {
+		parts[i] = strings.ToLower(parts[i])
+	}
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,111 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// confluenceSlack is subtracted from the cursor, since CQL compares the time
+// of the last modification in the time zone of the user, to the minute.
+const confluenceSlack = 24 * time.Hour
+
+// confluence reads the pages of a space of Confluence Cloud with the REST API,
+// authenticated with the email and an API token of a user.
+type confluence struct {
+	base   string
+	space  string
+	client *client
+}
+
+func newConfluence(location *Location, credentials map[string]string) *confluence {
+	email, token := credentials["email"], credentials["api_token"]
+	return &confluence{
+		base:  "https://" + location.Host + "/wiki",
+		space: location.ID,
+		client: newClient("confluence "+location.Host+" "+email, 0, func(ctx context.Context, req *http.Request) error {
+			req.SetBasicAuth(email, token)
+			return nil
+		}),
+	}
+}
+
+type confluencePage struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Version struct {
+		Number int `json:"number"`
+	} `json:"version"`
+	Body struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+	Links struct {
+		WebUI string `json:"webui"`
+	} `json:"_links"`
+}
+
+// Changes searches the pages of the space with CQL, those modified since the
+// cursor, the time of the previous sync, unless it is empty. Removed pages
+// cannot be searched, so only complete syncs find them.
+func (c *confluence) Changes(ctx context.Context, cursor string) (*Changes, error) {
+	started := time.Now().UTC()
+	cql := fmt.Sprintf("space = %q and type = page", c.space)
+	if cursor != "" {
+		since, err := time.Parse(time.RFC3339, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Confluence cursor %q", cursor)
+		}
+		cql += fmt.Sprintf(" and lastmodified >= %q", since.Add(-confluenceSlack).Format("2006/01/02 15:04"))
+	}
+
+	changes := &Changes{Complete: cursor == "", Cursor: started.Format(time.RFC3339)}
+	endpoint := c.base + "/rest/api/content/search?" + url.Values{
+		"cql":    {cql},
+		"expand": {"version"},
+		"limit":  {"100"},
+	}.Encode()
+	for endpoint != "" {
+		var result struct {
+			Results []confluencePage `json:"results"`
+			Links   struct {
+				Next string `json:"next"`
+			} `json:"_links"`
+		}
+		if err := c.client.getJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return nil, err
+		}
+		for _, page := range result.Results {
+			changes.Documents = append(changes.Documents, Document{
+				ID:      page.ID,
+				Path:    safeName(page.Title) + ".html",
+				URL:     c.base + page.Links.WebUI,
+				Version: strconv.Itoa(page.Version.Number),
+			})
+		}
+		endpoint = ""
+		if result.Links.Next != "" {
+			endpoint = c.base + result.Links.Next
+		}
+	}
+	return changes, nil
+}
+
+// Fetch writes the storage format of a page, which is XHTML, as an HTML
+// document.
+func (c *confluence) Fetch(ctx context.Context, document Document, w io.Writer) error {
+	var page confluencePage
+	endpoint := c.base + "/rest/api/content/" + url.PathEscape(document.ID) + "?expand=body.storage"
+	if err := c.client.getJSON(ctx, http.MethodGet, endpoint, nil, &page); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "<html><head><title>%s</title></head><body><h1>%s</h1>%s</body></html>\n",
+		html.EscapeString(page.Title), html.EscapeString(page.Title), page.Body.Storage.Value)
+	return err
+}
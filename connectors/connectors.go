@@ -0,0 +1,298 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/provider"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kinds of connectors, which are the schemes of the paths of their sources.
+const (
+	Confluence  = "confluence"
+	Notion      = "notion"
+	GoogleDrive = "gdrive"
+)
+
+// requestTimeout bounds a request to the API of a service, including the
+// download of a document.
+const requestTimeout = 2 * time.Minute
+
+// maxRateLimitRetries is the number of times a request is retried that was
+// rejected with 403 because of a rate limit.
+const maxRateLimitRetries = 5
+
+// Document is a page or file of a service.
+type Document struct {
+	ID string `json:"id"`
+	// Path is the path of the document in the source, such as the folders
+	// and name of a file or the title of a page. Its extension is the format
+	// that Fetch writes.
+	Path string `json:"path"`
+	// URL opens the document in the browser and identifies it in the vector
+	// store.
+	URL string `json:"url"`
+	// Version changes whenever the document does.
+	Version string `json:"version"`
+	// Type is the media type of a file in the service, which tells Fetch
+	// whether to download or export it.
+	Type string `json:"type,omitempty"`
+}
+
+// Changes are the documents that changed since a cursor.
+type Changes struct {
+	// Documents were added or changed.
+	Documents []Document
+	// Removed are the IDs of documents that were removed.
+	Removed []string
+	// Complete is set if Documents are all documents of the source, so that
+	// the documents that are not among them were removed.
+	Complete bool
+	// Cursor is the cursor of the next sync.
+	Cursor string
+}
+
+// Connector reads the documents of a source in a service.
+type Connector interface {
+	// Changes returns the documents that changed since the cursor of the
+	// previous sync, or all documents if the cursor is empty.
+	Changes(ctx context.Context, cursor string) (*Changes, error)
+	// Fetch writes the contents of a document to w.
+	Fetch(ctx context.Context, document Document, w io.Writer) error
+}
+
+// Location is a source of documents in a service, given as a URL:
+//
+//	confluence://site/SPACE   the pages of a space of Confluence Cloud
+//	notion://                 the pages shared with a Notion integration
+//	notion://database-id      the pages of a Notion database
+//	gdrive://folder-id        the files in a folder of Google Drive
+type Location struct {
+	Kind string `json:"kind"`
+	// Host is the site of Confluence, e.g. acme.atlassian.net.
+	Host string `json:"host,omitempty"`
+	// ID is the key of a Confluence space, the ID of a Notion database or of
+	// a Drive folder.
+	ID string `json:"id,omitempty"`
+}
+
+// Parse parses the path of an ingestion source, returning nil if it is not
+// the URL of a connector.
+func Parse(path string) (*Location, error) {
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok {
+		return nil, nil
+	}
+	rest = strings.Trim(rest, "/")
+	switch scheme {
+	case Confluence:
+		host, space, _ := strings.Cut(rest, "/")
+		if host == "" || space == "" || strings.Contains(space, "/") {
+			return nil, fmt.Errorf("invalid Confluence source %s, expected confluence://site/SPACE, e.g. confluence://acme.atlassian.net/ENG", path)
+		}
+		return &Location{Kind: Confluence, Host: host, ID: space}, nil
+	case Notion:
+		if strings.Contains(rest, "/") {
+			return nil, fmt.Errorf("invalid Notion source %s, expected notion:// or notion://database-id", path)
+		}
+		return &Location{Kind: Notion, ID: strings.ReplaceAll(rest, "-", "")}, nil
+	case GoogleDrive:
+		if rest == "" || strings.Contains(rest, "/") {
+			return nil, fmt.Errorf("invalid Google Drive source %s, expected gdrive://folder-id", path)
+		}
+		return &Location{Kind: GoogleDrive, ID: rest}, nil
+	}
+	return nil, nil
+}
+
+// DefaultCredentials are the credentials of the sources of a kind that do not
+// set their own: the keys that 'langforge connect' stores.
+func DefaultCredentials(kind string) map[string]string {
+	switch kind {
+	case Confluence:
+		return map[string]string{"email": "${CONFLUENCE_EMAIL}", "api_token": "${CONFLUENCE_API_TOKEN}"}
+	case Notion:
+		return map[string]string{"token": "${NOTION_TOKEN}"}
+	case GoogleDrive:
+		return map[string]string{
+			"client_id":     "${GOOGLE_DRIVE_CLIENT_ID}",
+			"client_secret": "${GOOGLE_DRIVE_CLIENT_SECRET}",
+			"refresh_token": "${GOOGLE_DRIVE_REFRESH_TOKEN}",
+		}
+	}
+	return nil
+}
+
+// New returns the connector of a location with its credentials.
+func New(location *Location, credentials map[string]string) (Connector, error) {
+	require := func(names ...string) error {
+		for _, name := range names {
+			if credentials[name] == "" {
+				return fmt.Errorf("the %s source has no %s in its credentials, run 'langforge connect %s'", location.Kind, name, location.Kind)
+			}
+		}
+		return nil
+	}
+
+	switch location.Kind {
+	case Confluence:
+		if err := require("email", "api_token"); err != nil {
+			return nil, err
+		}
+		return newConfluence(location, credentials), nil
+	case Notion:
+		if err := require("token"); err != nil {
+			return nil, err
+		}
+		return newNotion(location, credentials), nil
+	case GoogleDrive:
+		if credentials["access_token"] == "" {
+			if err := require("client_id", "client_secret", "refresh_token"); err != nil {
+				return nil, err
+			}
+		}
+		return newDrive(location, credentials), nil
+	}
+	return nil, fmt.Errorf("unknown connector %s", location.Kind)
+}
+
+// client sends the requests of a connector. Requests to the same account are
+// paced by a shared limiter and retried when the service rate limits them.
+type client struct {
+	http      *http.Client
+	limiter   *provider.Limiter
+	authorize func(ctx context.Context, req *http.Request) error
+}
+
+func newClient(limiterKey string, requestsPerMinute int, authorize func(ctx context.Context, req *http.Request) error) *client {
+	limiter := provider.SharedLimiter(limiterKey, requestsPerMinute)
+	return &client{
+		http: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: provider.NewRateLimitTransport(http.DefaultTransport, limiter),
+		},
+		limiter:   limiter,
+		authorize: authorize,
+	}
+}
+
+// do sends a request with a JSON body unless body is nil and returns the
+// response, which is an error unless its status is 2xx.
+func (c *client) do(ctx context.Context, method string, endpoint string, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+			// rate limited requests are sent again
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+		}
+		req.Header.Set("Accept", "application/json")
+		if err := c.authorize(ctx, req); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return resp, nil
+		}
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		// Google rejects the requests over a quota with 403 rather than 429,
+		// which the transport retries
+		if resp.StatusCode == http.StatusForbidden && attempt < maxRateLimitRetries &&
+			strings.Contains(strings.ToLower(string(message)), "ratelimitexceeded") {
+			c.limiter.Pause(time.Second << attempt)
+			continue
+		}
+		u, _ := url.Parse(endpoint)
+		return nil, fmt.Errorf("%s %s: %s: %s", method, u.Host+u.Path, resp.Status, strings.TrimSpace(string(message)))
+	}
+}
+
+// getJSON sends a request and decodes the JSON of its response into result.
+func (c *client) getJSON(ctx context.Context, method string, endpoint string, body any, result any) error {
+	resp, err := c.do(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// safeName replaces the characters of a title that cannot be part of a path.
+func safeName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+// Verify checks credentials of a kind of connector with a request to the
+// service and returns the name of the user or integration they belong to. The
+// location of Confluence names the site; the IDs of locations are ignored.
+func Verify(ctx context.Context, location *Location, credentials map[string]string) (string, error) {
+	if _, err := New(location, credentials); err != nil {
+		return "", err
+	}
+	var who struct {
+		DisplayName string `json:"displayName"`
+		Name        string `json:"name"`
+		User        struct {
+			DisplayName  string `json:"displayName"`
+			EmailAddress string `json:"emailAddress"`
+		} `json:"user"`
+	}
+	var err error
+	switch location.Kind {
+	case Confluence:
+		c := newConfluence(location, credentials)
+		err = c.client.getJSON(ctx, http.MethodGet, c.base+"/rest/api/user/current", nil, &who)
+	case Notion:
+		err = newNotion(location, credentials).client.getJSON(ctx, http.MethodGet, notionAPI+"/users/me", nil, &who)
+	case GoogleDrive:
+		err = newDrive(location, credentials).client.getJSON(ctx, http.MethodGet, driveAPI+"/about?fields=user", nil, &who)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, name := range []string{who.DisplayName, who.Name, who.User.EmailAddress, who.User.DisplayName} {
+		if name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
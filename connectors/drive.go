@@ -0,0 +1,281 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	driveAPI = "https://www.googleapis.com/drive/v3"
+	// driveRequestsPerMinute paces the requests below the quota of Drive per
+	// user.
+	driveRequestsPerMinute = 600
+	driveFolder            = "application/vnd.google-apps.folder"
+	driveFileFields        = "id,name,mimeType,version,webViewLink,parents,trashed"
+)
+
+// driveExports are the formats that the files of Google Docs, Sheets and
+// Slides are exported in, with the extension of the documents. Other files of
+// Google apps, such as forms, have no contents to ingest.
+var driveExports = map[string]struct {
+	mimeType  string
+	extension string
+}{
+	"application/vnd.google-apps.document":     {"text/plain", ".txt"},
+	"application/vnd.google-apps.spreadsheet":  {"text/csv", ".csv"},
+	"application/vnd.google-apps.presentation": {"text/plain", ".txt"},
+}
+
+// drive reads the files in a folder of Google Drive and its subfolders, with
+// an OAuth access token of a user that is refreshed with a refresh token.
+type drive struct {
+	folder string
+	client *client
+	tokens *googleTokenSource
+}
+
+func newDrive(location *Location, credentials map[string]string) *drive {
+	tokens := &googleTokenSource{
+		clientID:     credentials["client_id"],
+		clientSecret: credentials["client_secret"],
+		refreshToken: credentials["refresh_token"],
+		accessToken:  credentials["access_token"],
+	}
+	if tokens.accessToken != "" && tokens.refreshToken == "" {
+		// an access token without a way to refresh it is used as it is
+		tokens.expiry = time.Now().Add(100 * 365 * 24 * time.Hour)
+	}
+	return &drive{
+		folder: location.ID,
+		tokens: tokens,
+		client: newClient("gdrive "+tokens.clientID+" "+tokens.refreshToken, driveRequestsPerMinute, func(ctx context.Context, req *http.Request) error {
+			token, err := tokens.token(ctx)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}),
+	}
+}
+
+type driveFile struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	MimeType    string   `json:"mimeType"`
+	Version     string   `json:"version"`
+	WebViewLink string   `json:"webViewLink"`
+	Parents     []string `json:"parents"`
+	Trashed     bool     `json:"trashed"`
+}
+
+// driveCursor is the cursor of Drive: the page token of the changes API and
+// the paths of the folders of the source, which tell whether a changed file
+// belongs to it.
+type driveCursor struct {
+	PageToken string            `json:"pageToken"`
+	Folders   map[string]string `json:"folders"`
+}
+
+// Changes lists all files of the folder if the cursor is empty, and the files
+// of the changes API since the page token of the cursor otherwise. Files that
+// were trashed, deleted or moved out of the folder are removed; the files of
+// a folder that was removed as a whole are only found by complete syncs.
+func (d *drive) Changes(ctx context.Context, cursor string) (*Changes, error) {
+	if cursor == "" {
+		return d.list(ctx)
+	}
+	state := driveCursor{}
+	if err := json.Unmarshal([]byte(cursor), &state); err != nil || state.PageToken == "" {
+		return nil, fmt.Errorf("invalid Google Drive cursor")
+	}
+
+	changes := &Changes{}
+	pageToken := state.PageToken
+	for {
+		var result struct {
+			Changes []struct {
+				FileID  string     `json:"fileId"`
+				Removed bool       `json:"removed"`
+				File    *driveFile `json:"file"`
+			} `json:"changes"`
+			NextPageToken     string `json:"nextPageToken"`
+			NewStartPageToken string `json:"newStartPageToken"`
+		}
+		endpoint := driveAPI + "/changes?" + url.Values{
+			"pageToken":      {pageToken},
+			"pageSize":       {"1000"},
+			"includeRemoved": {"true"},
+			"fields":         {"nextPageToken,newStartPageToken,changes(fileId,removed,file(" + driveFileFields + "))"},
+		}.Encode()
+		if err := d.client.getJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return nil, err
+		}
+
+		for _, change := range result.Changes {
+			file := change.File
+			parent := ""
+			if file != nil {
+				for _, p := range file.Parents {
+					if _, ok := state.Folders[p]; ok {
+						parent = p
+					}
+				}
+			}
+			if change.Removed || file == nil || file.Trashed || parent == "" {
+				delete(state.Folders, change.FileID)
+				changes.Removed = append(changes.Removed, change.FileID)
+				continue
+			}
+			filePath := path.Join(state.Folders[parent], safeName(file.Name))
+			if file.MimeType == driveFolder {
+				state.Folders[file.ID] = filePath
+				continue
+			}
+			if document, ok := driveDocument(file, filePath); ok {
+				changes.Documents = append(changes.Documents, document)
+			}
+		}
+
+		if result.NewStartPageToken != "" {
+			state.PageToken = result.NewStartPageToken
+			break
+		}
+		pageToken = result.NextPageToken
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	changes.Cursor = string(data)
+	return changes, nil
+}
+
+// list lists the files of the folder and its subfolders, and starts the
+// changes of the next sync.
+func (d *drive) list(ctx context.Context) (*Changes, error) {
+	// the changes that happen while the folder is listed are in the next sync
+	var start struct {
+		StartPageToken string `json:"startPageToken"`
+	}
+	if err := d.client.getJSON(ctx, http.MethodGet, driveAPI+"/changes/startPageToken", nil, &start); err != nil {
+		return nil, err
+	}
+
+	changes := &Changes{Complete: true}
+	state := driveCursor{PageToken: start.StartPageToken, Folders: map[string]string{d.folder: ""}}
+	pending := []string{d.folder}
+	for len(pending) > 0 {
+		folder := pending[0]
+		pending = pending[1:]
+		query := url.Values{
+			"q":        {fmt.Sprintf("'%s' in parents and trashed = false", strings.ReplaceAll(folder, "'", `\'`))},
+			"pageSize": {"1000"},
+			"fields":   {"nextPageToken,files(" + driveFileFields + ")"},
+		}
+		for {
+			var result struct {
+				Files         []driveFile `json:"files"`
+				NextPageToken string      `json:"nextPageToken"`
+			}
+			if err := d.client.getJSON(ctx, http.MethodGet, driveAPI+"/files?"+query.Encode(), nil, &result); err != nil {
+				return nil, err
+			}
+			for i := range result.Files {
+				file := &result.Files[i]
+				filePath := path.Join(state.Folders[folder], safeName(file.Name))
+				if file.MimeType == driveFolder {
+					if _, seen := state.Folders[file.ID]; !seen {
+						state.Folders[file.ID] = filePath
+						pending = append(pending, file.ID)
+					}
+					continue
+				}
+				if document, ok := driveDocument(file, filePath); ok {
+					changes.Documents = append(changes.Documents, document)
+				}
+			}
+			if result.NextPageToken == "" {
+				break
+			}
+			query.Set("pageToken", result.NextPageToken)
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	changes.Cursor = string(data)
+	return changes, nil
+}
+
+// driveDocument returns the document of a file, unless it is a file of a
+// Google app without contents to ingest.
+func driveDocument(file *driveFile, filePath string) (Document, bool) {
+	if strings.HasPrefix(file.MimeType, "application/vnd.google-apps.") {
+		export, ok := driveExports[file.MimeType]
+		if !ok {
+			return Document{}, false
+		}
+		filePath += export.extension
+	}
+	return Document{ID: file.ID, Path: filePath, URL: file.WebViewLink, Version: file.Version, Type: file.MimeType}, true
+}
+
+// Fetch exports the files of Google apps and downloads all others.
+func (d *drive) Fetch(ctx context.Context, document Document, w io.Writer) error {
+	endpoint := driveAPI + "/files/" + url.PathEscape(document.ID) + "?alt=media"
+	if export, ok := driveExports[document.Type]; ok {
+		endpoint = driveAPI + "/files/" + url.PathEscape(document.ID) + "/export?mimeType=" + url.QueryEscape(export.mimeType)
+	}
+	resp, err := d.client.do(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// googleTokenSource returns the access token of a user, which it refreshes
+// with the refresh token when it expires.
+type googleTokenSource struct {
+	clientID     string
+	clientSecret string
+	refreshToken string
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+func (s *googleTokenSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accessToken != "" && time.Now().Before(s.expiry) {
+		return s.accessToken, nil
+	}
+	token, err := exchangeGoogleToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"refresh_token": {s.refreshToken},
+	})
+	if err != nil {
+		return "", err
+	}
+	s.accessToken = token.AccessToken
+	// refresh a minute early, a request may take a while to arrive
+	s.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.accessToken, nil
+}
@@ -0,0 +1,280 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	notionAPI     = "https://api.notion.com/v1"
+	notionVersion = "2022-06-28"
+	// notionRequestsPerMinute is the average rate that Notion allows an
+	// integration.
+	notionRequestsPerMinute = 180
+	// notionSlack is subtracted from the cursor, since Notion rounds the
+	// times of the last edits to the minute.
+	notionSlack = 2 * time.Minute
+	// notionMaxDepth limits the nesting of the blocks of a page that are
+	// read, e.g. of toggles in toggles.
+	notionMaxDepth = 8
+)
+
+// notion reads the pages that are shared with a Notion integration, or the
+// pages of one of its databases, with the token of the integration.
+type notion struct {
+	database string
+	client   *client
+}
+
+func newNotion(location *Location, credentials map[string]string) *notion {
+	token := credentials["token"]
+	return &notion{
+		database: location.ID,
+		client: newClient("notion "+token, notionRequestsPerMinute, func(ctx context.Context, req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Notion-Version", notionVersion)
+			return nil
+		}),
+	}
+}
+
+type notionPage struct {
+	ID             string                    `json:"id"`
+	URL            string                    `json:"url"`
+	LastEditedTime time.Time                 `json:"last_edited_time"`
+	Archived       bool                      `json:"archived"`
+	InTrash        bool                      `json:"in_trash"`
+	Properties     map[string]notionProperty `json:"properties"`
+}
+
+type notionProperty struct {
+	Type  string           `json:"type"`
+	Title []notionRichText `json:"title"`
+}
+
+type notionRichText struct {
+	PlainText string `json:"plain_text"`
+}
+
+func (p *notionPage) title() string {
+	for _, property := range p.Properties {
+		if property.Type == "title" {
+			return plainText(property.Title)
+		}
+	}
+	return ""
+}
+
+// Changes lists the pages edited since the cursor, the time of the previous
+// sync, newest first, or all pages if the cursor is empty. Archived pages are
+// removed; pages that were deleted or unshared are only found by complete
+// syncs.
+func (n *notion) Changes(ctx context.Context, cursor string) (*Changes, error) {
+	started := time.Now().UTC()
+	var since time.Time
+	if cursor != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Notion cursor %q", cursor)
+		}
+		since = since.Add(-notionSlack)
+	}
+
+	changes := &Changes{Complete: cursor == "", Cursor: started.Format(time.RFC3339)}
+	endpoint := notionAPI + "/search"
+	query := map[string]any{
+		"filter":    map[string]string{"property": "object", "value": "page"},
+		"sort":      map[string]string{"direction": "descending", "timestamp": "last_edited_time"},
+		"page_size": 100,
+	}
+	if n.database != "" {
+		endpoint = notionAPI + "/databases/" + url.PathEscape(n.database) + "/query"
+		query = map[string]any{
+			"sorts":     []map[string]string{{"direction": "descending", "timestamp": "last_edited_time"}},
+			"page_size": 100,
+		}
+		if cursor != "" {
+			query["filter"] = map[string]any{
+				"timestamp":        "last_edited_time",
+				"last_edited_time": map[string]string{"on_or_after": since.Format(time.RFC3339)},
+			}
+		}
+	}
+
+	for {
+		var result struct {
+			Results    []notionPage `json:"results"`
+			HasMore    bool         `json:"has_more"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		if err := n.client.getJSON(ctx, http.MethodPost, endpoint, query, &result); err != nil {
+			return nil, err
+		}
+		for _, page := range result.Results {
+			if cursor != "" && page.LastEditedTime.Before(since) {
+				// the remaining pages were edited even earlier
+				return changes, nil
+			}
+			if page.Archived || page.InTrash {
+				changes.Removed = append(changes.Removed, page.ID)
+				continue
+			}
+			changes.Documents = append(changes.Documents, Document{
+				ID:      page.ID,
+				Path:    safeName(page.title()) + ".md",
+				URL:     page.URL,
+				Version: page.LastEditedTime.UTC().Format(time.RFC3339),
+			})
+		}
+		if !result.HasMore {
+			return changes, nil
+		}
+		query["start_cursor"] = result.NextCursor
+	}
+}
+
+type notionBlock struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	HasChildren bool   `json:"has_children"`
+	// the content of a block is in the field of its type
+	content map[string]any
+}
+
+// Fetch writes a page as Markdown: its title and its blocks, with the text of
+// the blocks that Markdown has no equivalent for.
+func (n *notion) Fetch(ctx context.Context, document Document, w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", strings.TrimSuffix(document.Path, ".md"))
+	if err := n.writeBlocks(ctx, &b, document.ID, 0); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (n *notion) writeBlocks(ctx context.Context, b *strings.Builder, id string, depth int) error {
+	query := url.Values{"page_size": {"100"}}
+	indent := strings.Repeat("  ", depth)
+	number := 0
+	for {
+		var result struct {
+			Results    []map[string]any `json:"results"`
+			HasMore    bool             `json:"has_more"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		endpoint := notionAPI + "/blocks/" + url.PathEscape(id) + "/children?" + query.Encode()
+		if err := n.client.getJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return err
+		}
+		for _, raw := range result.Results {
+			block := parseNotionBlock(raw)
+			if block.Type == "numbered_list_item" {
+				number++
+			} else {
+				number = 0
+			}
+			b.WriteString(renderNotionBlock(block, indent, number))
+			// child pages and databases are documents of their own
+			if block.HasChildren && depth < notionMaxDepth && block.Type != "child_page" && block.Type != "child_database" {
+				if err := n.writeBlocks(ctx, b, block.ID, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		if !result.HasMore {
+			return nil
+		}
+		query.Set("start_cursor", result.NextCursor)
+	}
+}
+
+func parseNotionBlock(raw map[string]any) notionBlock {
+	block := notionBlock{}
+	block.ID, _ = raw["id"].(string)
+	block.Type, _ = raw["type"].(string)
+	block.HasChildren, _ = raw["has_children"].(bool)
+	block.content, _ = raw[block.Type].(map[string]any)
+	return block
+}
+
+// text returns the plain text of the rich text of a block.
+func (b notionBlock) text() string {
+	items, _ := b.content["rich_text"].([]any)
+	var text strings.Builder
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok {
+			plain, _ := m["plain_text"].(string)
+			text.WriteString(plain)
+		}
+	}
+	return text.String()
+}
+
+func renderNotionBlock(block notionBlock, indent string, number int) string {
+	text := block.text()
+	switch block.Type {
+	case "heading_1":
+		return "## " + text + "\n\n"
+	case "heading_2":
+		return "### " + text + "\n\n"
+	case "heading_3":
+		return "#### " + text + "\n\n"
+	case "bulleted_list_item", "toggle":
+		return indent + "- " + text + "\n"
+	case "numbered_list_item":
+		return fmt.Sprintf("%s%d. %s\n", indent, number, text)
+	case "to_do":
+		checked, _ := block.content["checked"].(bool)
+		if checked {
+			return indent + "- [x] " + text + "\n"
+		}
+		return indent + "- [ ] " + text + "\n"
+	case "quote", "callout":
+		return "> " + text + "\n\n"
+	case "code":
+		language, _ := block.content["language"].(string)
+		return "```" + language + "\n" + text + "\n```\n\n"
+	case "divider":
+		return "---\n\n"
+	case "child_page":
+		title, _ := block.content["title"].(string)
+		return "Page: " + title + "\n\n"
+	case "bookmark", "embed", "link_preview":
+		link, _ := block.content["url"].(string)
+		return link + "\n\n"
+	case "table_row":
+		cells, _ := block.content["cells"].([]any)
+		row := []string{}
+		for _, cell := range cells {
+			items, _ := cell.([]any)
+			var text strings.Builder
+			for _, item := range items {
+				if m, ok := item.(map[string]any); ok {
+					plain, _ := m["plain_text"].(string)
+					text.WriteString(plain)
+				}
+			}
+			row = append(row, text.String())
+		}
+		return "| " + strings.Join(row, " | ") + " |\n"
+	}
+	if text == "" {
+		return ""
+	}
+	return indent + text + "\n\n"
+}
+
+func plainText(items []notionRichText) string {
+	var text strings.Builder
+	for _, item := range items {
+		text.WriteString(item.PlainText)
+	}
+	return text.String()
+}
@@ -0,0 +1,154 @@
+package connectors
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	googleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	// GoogleDriveScope only lets the connector read the files of the user.
+	GoogleDriveScope = "https://www.googleapis.com/auth/drive.readonly"
+)
+
+type googleToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// exchangeGoogleToken requests a token from the token endpoint of Google, with
+// an authorization code or a refresh token.
+func exchangeGoogleToken(ctx context.Context, form url.Values) (*googleToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := (&http.Client{Timeout: requestTimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		googleToken
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to get a Google access token: %s", resp.Status)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("failed to get a Google access token: %s %s", token.Error, token.Description)
+	}
+	return &token.googleToken, nil
+}
+
+// AuthorizeGoogle runs the OAuth flow of Google for installed apps: it opens
+// the consent page with open and receives the authorization code on a
+// loopback address, then returns the refresh token that it is exchanged for.
+// The client is an OAuth client of type Desktop app of the user's Google
+// Cloud project.
+func AuthorizeGoogle(ctx context.Context, clientID string, clientSecret string, scope string, open func(url string) error) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	redirectURI := "http://" + listener.Addr().String()
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	// PKCE keeps other programs that see the code from exchanging it
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		if message := query.Get("error"); message != "" {
+			io.WriteString(w, "Authorization failed, you can close this window.")
+			results <- result{err: fmt.Errorf("authorization failed: %s", message)}
+			return
+		}
+		io.WriteString(w, "langforge is authorized, you can close this window.")
+		results <- result{code: query.Get("code")}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := googleAuthURL + "?" + url.Values{
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {scope},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		// a refresh token is only returned with offline access, and again
+		// for a user that authorized the client before only with consent
+		"access_type": {"offline"},
+		"prompt":      {"consent"},
+	}.Encode()
+	if err := open(authURL); err != nil {
+		return "", err
+	}
+
+	var received result
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case received = <-results:
+	}
+	if received.err != nil {
+		return "", received.err
+	}
+
+	token, err := exchangeGoogleToken(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {received.code},
+		"code_verifier": {verifier},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"redirect_uri":  {redirectURI},
+	})
+	if err != nil {
+		return "", err
+	}
+	if token.RefreshToken == "" {
+		return "", fmt.Errorf("Google returned no refresh token")
+	}
+	return token.RefreshToken, nil
+}
+
+func randomString() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,143 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"langforge/project"
+	"langforge/system"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IsURL reports whether source refers to a remote dataset.
+func IsURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// CacheDir returns the directory in which downloaded datasets are cached.
+func CacheDir(dir string) string {
+	return filepath.Join(project.StateDir(dir), "datasets")
+}
+
+// LocalPath returns the path of the dataset's file on disk. For remote datasets
+// this is the location in the cache, which may not exist yet.
+func LocalPath(dir string, ds *project.DatasetConfig) string {
+	if !IsURL(ds.Source) {
+		if filepath.IsAbs(ds.Source) {
+			return ds.Source
+		}
+		return filepath.Join(dir, ds.Source)
+	}
+
+	ext := ""
+	if u, err := url.Parse(ds.Source); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	return filepath.Join(CacheDir(dir), ds.Name+ext)
+}
+
+// Checksum returns the hex encoded SHA-256 checksum of the file at path.
+func Checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Fetch makes sure the dataset is available locally and returns its path. Remote
+// datasets are downloaded unless they are already cached or force is set. If the
+// dataset has a checksum, the local file is verified against it.
+func Fetch(dir string, ds *project.DatasetConfig, force bool) (string, error) {
+	localPath := LocalPath(dir, ds)
+
+	if IsURL(ds.Source) {
+		_, err := os.Stat(localPath)
+		cached := err == nil
+		if cached && ds.SHA256 != "" {
+			checksum, err := Checksum(localPath)
+			if err != nil {
+				return "", err
+			}
+			cached = checksum == ds.SHA256
+		}
+
+		if force || !cached {
+			if err := download(ds.Source, localPath); err != nil {
+				return "", fmt.Errorf("failed to download dataset %q: %v", ds.Name, err)
+			}
+		}
+	} else if _, err := os.Stat(localPath); err != nil {
+		return "", fmt.Errorf("dataset %q not found: %v", ds.Name, err)
+	}
+
+	if ds.SHA256 != "" {
+		checksum, err := Checksum(localPath)
+		if err != nil {
+			return "", err
+		}
+		if checksum != ds.SHA256 {
+			return "", fmt.Errorf("checksum mismatch for dataset %q: expected %s, got %s", ds.Name, ds.SHA256, checksum)
+		}
+	}
+
+	return localPath, nil
+}
+
+func download(source string, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	resp, err := http.Get(source)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, system.ProgressReader(filepath.Base(target), resp.ContentLength, resp.Body))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+// Resolve returns the local path of the dataset referenced by nameOrPath. If it
+// is the name of a registered dataset, the dataset is fetched if necessary;
+// otherwise it is treated as a path relative to the project directory.
+func Resolve(dir string, config *project.Config, nameOrPath string) (string, error) {
+	if ds := config.FindDataset(nameOrPath); ds != nil {
+		return Fetch(dir, ds, false)
+	}
+
+	if filepath.IsAbs(nameOrPath) {
+		return nameOrPath, nil
+	}
+	return filepath.Join(dir, nameOrPath), nil
+}
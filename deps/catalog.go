@@ -0,0 +1,200 @@
+package deps
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed catalog.yaml
+var catalogYAML []byte
+
+// Provider is an LLM provider, vector store or other service with the
+// packages of its clients, pinned to exact versions: Python requirements such
+// as "openai==1.51.2" and npm packages such as "openai@4.67.3".
+type Provider struct {
+	Name    string   `yaml:"name"`
+	Title   string   `yaml:"title"`
+	Aliases []string `yaml:"aliases"`
+	Python  []string `yaml:"python"`
+	Npm     []string `yaml:"npm"`
+	ApiKeys []string `yaml:"apiKeys"`
+}
+
+// Packages returns the packages of the provider in an ecosystem.
+func (p *Provider) Packages(ecosystem string) []string {
+	if ecosystem == Npm {
+		return p.Npm
+	}
+	return p.Python
+}
+
+// Providers returns the catalog of providers, sorted by name.
+func Providers() ([]*Provider, error) {
+	providers := []*Provider{}
+	if err := yaml.Unmarshal(catalogYAML, &providers); err != nil {
+		return nil, err
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers, nil
+}
+
+// LookupProviders returns the providers with the given names or aliases, in
+// their order and without duplicates.
+func LookupProviders(names []string) ([]*Provider, error) {
+	providers, err := Providers()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*Provider{}
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+		for _, alias := range provider.Aliases {
+			byName[alias] = provider
+		}
+	}
+
+	result := []*Provider{}
+	seen := map[*Provider]bool{}
+	for _, name := range names {
+		provider, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q, see 'langforge add --list'", name)
+		}
+		if !seen[provider] {
+			seen[provider] = true
+			result = append(result, provider)
+		}
+	}
+	return result, nil
+}
+
+// ProviderPackages returns the packages of the providers in an ecosystem
+// without duplicates, and the providers that have no packages in it, e.g.
+// Python libraries without a JavaScript client.
+func ProviderPackages(providers []*Provider, ecosystem string) ([]string, []*Provider) {
+	packages := []string{}
+	missing := []*Provider{}
+	seen := map[string]bool{}
+	for _, provider := range providers {
+		if len(provider.Packages(ecosystem)) == 0 {
+			missing = append(missing, provider)
+		}
+		for _, pkg := range provider.Packages(ecosystem) {
+			if !seen[pkg] {
+				seen[pkg] = true
+				packages = append(packages, pkg)
+			}
+		}
+	}
+	return packages, missing
+}
+
+// SplitNpmPackage splits an npm package such as "@scope/name@1.2.3" into its
+// name and version.
+func SplitNpmPackage(pkg string) (string, string) {
+	if i := strings.LastIndex(pkg, "@"); i > 0 {
+		return pkg[:i], pkg[i+1:]
+	}
+	return pkg, ""
+}
+
+// RequirementsFragment returns Python packages as lines of requirements.txt.
+func RequirementsFragment(packages []string) string {
+	if len(packages) == 0 {
+		return ""
+	}
+	return strings.Join(packages, "\n") + "\n"
+}
+
+// PackageJSONFragment returns npm packages as the dependencies of a
+// package.json.
+func PackageJSONFragment(packages []string) (string, error) {
+	dependencies := map[string]string{}
+	for _, pkg := range packages {
+		name, version := SplitNpmPackage(pkg)
+		dependencies[name] = version
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"dependencies": dependencies}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// AddRequirements appends Python requirements to the requirements file at
+// path, which is created if it does not exist. Packages that the file
+// already requires keep their requirements, so that the versions a project
+// pinned are not changed. It returns the requirements that were added and
+// the lines of the file that were kept instead.
+func AddRequirements(path string, requirements []string) ([]string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	existing := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name := requirementName(line); name != "" {
+			existing[name] = strings.TrimSpace(strings.SplitN(line, "#", 2)[0])
+		}
+	}
+
+	added, kept := []string{}, []string{}
+	for _, requirement := range requirements {
+		if line, ok := existing[requirementName(requirement)]; ok {
+			kept = append(kept, line)
+			continue
+		}
+		added = append(added, requirement)
+	}
+	if len(added) == 0 {
+		return added, kept, nil
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += RequirementsFragment(added)
+	return added, kept, os.WriteFile(path, []byte(content), 0644)
+}
+
+// requirementName returns the normalized name of the package of a line of a
+// requirements file, empty for lines without one such as comments and
+// options.
+func requirementName(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+		return ""
+	}
+	m := pipRequirement.FindStringSubmatch(line)
+	if m == nil {
+		return ""
+	}
+	return normalizeName(m[1])
+}
+
+// PackageJSONDependencies returns the dependencies and dev dependencies of
+// the package.json at path.
+func PackageJSONDependencies(path string) (map[string]string, map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return manifest.Dependencies, manifest.DevDependencies, nil
+}
@@ -0,0 +1,313 @@
+package deps
+
+import (
+	"strings"
+)
+
+// bound is one end of an interval. A nil version is unbounded.
+type bound struct {
+	version   Version
+	inclusive bool
+}
+
+// interval is a contiguous range of versions.
+type interval struct {
+	lo bound
+	hi bound
+}
+
+func (i interval) empty() bool {
+	if i.lo.version == nil || i.hi.version == nil {
+		return false
+	}
+	c := i.lo.version.Compare(i.hi.version)
+	return c > 0 || (c == 0 && !(i.lo.inclusive && i.hi.inclusive))
+}
+
+func (i interval) intersect(j interval) interval {
+	result := i
+	if j.lo.version != nil {
+		if result.lo.version == nil {
+			result.lo = j.lo
+		} else if c := j.lo.version.Compare(result.lo.version); c > 0 || (c == 0 && !j.lo.inclusive) {
+			result.lo = j.lo
+		}
+	}
+	if j.hi.version != nil {
+		if result.hi.version == nil {
+			result.hi = j.hi
+		} else if c := j.hi.version.Compare(result.hi.version); c < 0 || (c == 0 && !j.hi.inclusive) {
+			result.hi = j.hi
+		}
+	}
+	return result
+}
+
+// Range is a set of versions as a union of intervals. A nil range contains
+// all versions.
+type Range []interval
+
+var anyVersion = Range{interval{}}
+
+// Any reports whether the range allows every version.
+func (r Range) Any() bool {
+	for _, i := range r {
+		if i.lo.version == nil && i.hi.version == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Intersect returns the versions that are in both ranges.
+func (r Range) Intersect(s Range) Range {
+	result := Range{}
+	for _, i := range r {
+		for _, j := range s {
+			if k := i.intersect(j); !k.empty() {
+				result = append(result, k)
+			}
+		}
+	}
+	return result
+}
+
+// Empty reports whether the range contains no version.
+func (r Range) Empty() bool {
+	return len(r) == 0
+}
+
+func atLeast(v Version) interval {
+	return interval{lo: bound{version: v, inclusive: true}}
+}
+
+func below(v Version) interval {
+	return interval{hi: bound{version: v}}
+}
+
+func between(lo Version, hi Version) interval {
+	return interval{lo: bound{version: lo, inclusive: true}, hi: bound{version: hi}}
+}
+
+func exactly(v Version) interval {
+	return interval{lo: bound{version: v, inclusive: true}, hi: bound{version: v, inclusive: true}}
+}
+
+// prefix returns the versions that start with the given components, e.g.
+// 1.2 matches 1.2.0 up to but excluding 1.3.
+func prefix(v Version) interval {
+	if len(v) == 0 {
+		return interval{}
+	}
+	return between(v, v.bump(len(v)-1))
+}
+
+// pipOperators are the PEP 440 comparison operators, longest first.
+var pipOperators = []string{"===", "==", "!=", "~=", "<=", ">=", "<", ">"}
+
+// ParsePip parses a PEP 440 version specifier such as ">=1.2,<2" or "==1.4.*".
+// Exclusions with "!=" are ignored. It reports false if the specifier cannot
+// be interpreted.
+func ParsePip(spec string) (Range, bool) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return anyVersion, true
+	}
+
+	result := interval{}
+	for _, clause := range strings.Split(spec, ",") {
+		clause = strings.TrimSpace(clause)
+		op := ""
+		for _, candidate := range pipOperators {
+			if strings.HasPrefix(clause, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return nil, false
+		}
+
+		text := strings.TrimSpace(strings.TrimPrefix(clause, op))
+		v, ok := parseVersion(text)
+		if !ok {
+			return nil, false
+		}
+		wildcard := strings.HasSuffix(text, ".*")
+
+		var i interval
+		switch op {
+		case "==", "===":
+			if wildcard {
+				i = prefix(v)
+			} else {
+				i = exactly(v)
+			}
+		case "!=":
+			continue
+		case "~=":
+			if len(v) < 2 {
+				return nil, false
+			}
+			i = between(v, v.bump(len(v)-2))
+		case ">=":
+			i = atLeast(v)
+		case ">":
+			i = interval{lo: bound{version: v}}
+		case "<=":
+			i = interval{hi: bound{version: v, inclusive: true}}
+		case "<":
+			i = below(v)
+		}
+		result = result.intersect(i)
+	}
+
+	if result.empty() {
+		return Range{}, true
+	}
+	return Range{result}, true
+}
+
+// ParseNpm parses an npm semver range such as "^1.2.3", "~1.2", ">=1 <3" or
+// "1.x || 2.x". It reports false for ranges that are not version numbers,
+// e.g. git URLs, file paths or dist-tags other than "latest".
+func ParseNpm(spec string) (Range, bool) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" || spec == "latest" {
+		return anyVersion, true
+	}
+	if strings.Contains(spec, ":") || strings.Contains(spec, "/") {
+		return nil, false
+	}
+
+	result := Range{}
+	for _, alternative := range strings.Split(spec, "||") {
+		i, ok := parseNpmAlternative(strings.TrimSpace(alternative))
+		if !ok {
+			return nil, false
+		}
+		if !i.empty() {
+			result = append(result, i)
+		}
+	}
+	return result, true
+}
+
+func parseNpmAlternative(alternative string) (interval, bool) {
+	fields := strings.Fields(alternative)
+
+	// hyphen range "1.2.3 - 2.3.4"
+	if len(fields) == 3 && fields[1] == "-" {
+		lo, ok := parseVersion(fields[0])
+		if !ok {
+			return interval{}, false
+		}
+		hi, ok := parseVersion(fields[2])
+		if !ok {
+			return interval{}, false
+		}
+		i := atLeast(lo)
+		if len(hi) < 3 {
+			return i.intersect(below(hi.bump(len(hi) - 1))), true
+		}
+		return i.intersect(interval{hi: bound{version: hi, inclusive: true}}), true
+	}
+
+	result := interval{}
+	for _, field := range fields {
+		i, ok := parseNpmComparator(field)
+		if !ok {
+			return interval{}, false
+		}
+		result = result.intersect(i)
+	}
+	return result, true
+}
+
+func parseNpmComparator(comparator string) (interval, bool) {
+	if comparator == "*" || comparator == "x" || comparator == "X" {
+		return interval{}, true
+	}
+
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(comparator, candidate) {
+			op = candidate
+			break
+		}
+	}
+	v, ok := parseVersion(strings.TrimPrefix(comparator, op))
+	if !ok {
+		return interval{}, false
+	}
+	partial := len(v) < 3
+
+	switch op {
+	case "^":
+		// the first non-zero component must not change
+		i := 0
+		for i < len(v)-1 && v[i] == 0 {
+			i++
+		}
+		return between(v, v.bump(i)), true
+	case "~":
+		if len(v) >= 2 {
+			return between(v, v.bump(1)), true
+		}
+		return between(v, v.bump(0)), true
+	case ">=":
+		return atLeast(v), true
+	case ">":
+		if partial {
+			return atLeast(v.bump(len(v) - 1)), true
+		}
+		return interval{lo: bound{version: v}}, true
+	case "<=":
+		if partial {
+			return below(v.bump(len(v) - 1)), true
+		}
+		return interval{hi: bound{version: v, inclusive: true}}, true
+	case "<":
+		return below(v), true
+	default:
+		if partial {
+			return prefix(v), true
+		}
+		return exactly(v), true
+	}
+}
+
+// String formats the range for messages, e.g. ">=1.2, <2".
+func (r Range) String() string {
+	if r.Any() {
+		return "any version"
+	}
+	if r.Empty() {
+		return "no version"
+	}
+
+	alternatives := []string{}
+	for _, i := range r {
+		parts := []string{}
+		if i.lo.version != nil && i.hi.version != nil && i.lo.inclusive && i.hi.inclusive && i.lo.version.Compare(i.hi.version) == 0 {
+			alternatives = append(alternatives, "=="+i.lo.version.String())
+			continue
+		}
+		if i.lo.version != nil {
+			op := ">"
+			if i.lo.inclusive {
+				op = ">="
+			}
+			parts = append(parts, op+i.lo.version.String())
+		}
+		if i.hi.version != nil {
+			op := "<"
+			if i.hi.inclusive {
+				op = "<="
+			}
+			parts = append(parts, op+i.hi.version.String())
+		}
+		alternatives = append(alternatives, strings.Join(parts, ", "))
+	}
+	return strings.Join(alternatives, " || ")
+}
@@ -0,0 +1,225 @@
+package deps
+
+import (
+	"bufio"
+	"fmt"
+	"langforge/project"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Ecosystems of requirements.
+const (
+	Python = "python"
+	Npm    = "npm"
+)
+
+// Requirement is a version constraint that a project declares for a package.
+type Requirement struct {
+	Project   string
+	Source    string
+	Ecosystem string
+	Package   string
+	Spec      string
+	Range     Range
+}
+
+func (r *Requirement) String() string {
+	spec := r.Spec
+	if spec == "" {
+		spec = "any version"
+	}
+	return fmt.Sprintf("%s requires %s %s (%s)", r.Project, r.Package, spec, r.Source)
+}
+
+// Conflict is a set of requirements for a package that no version satisfies.
+// Requirements lists a minimal set, usually two requirements.
+type Conflict struct {
+	Ecosystem    string
+	Package      string
+	Requirements []*Requirement
+}
+
+// pipRequirement splits a requirement line into the project name, the extras
+// and the version specifier.
+var pipRequirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*(.*)$`)
+
+// normalizeName normalizes a Python package name as specified by PEP 503.
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+func normalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, "-"))
+}
+
+// parsePipRequirement parses a line of a requirements file. It returns nil for
+// lines that are not versioned package requirements, e.g. URLs or options.
+func parsePipRequirement(line string) *Requirement {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	// environment markers select requirements by platform, ignore them
+	if i := strings.Index(line, ";"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "-") || strings.Contains(line, "@") || strings.Contains(line, "://") {
+		return nil
+	}
+
+	m := pipRequirement.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	r, ok := ParsePip(m[3])
+	if !ok {
+		return nil
+	}
+	return &Requirement{
+		Ecosystem: Python,
+		Package:   normalizeName(m[1]),
+		Spec:      strings.TrimSpace(m[3]),
+		Range:     r,
+	}
+}
+
+// Collect returns the requirements that the project in dir declares in its
+// requirements.txt, the package dependencies of its langforge.yaml and its
+// package.json. The project is named after its directory relative to root.
+func Collect(root string, dir string) ([]*Requirement, error) {
+	name, err := filepath.Rel(root, dir)
+	if err != nil || name == "." {
+		name = filepath.Base(dir)
+	}
+	name = filepath.ToSlash(name)
+
+	requirements := []*Requirement{}
+	add := func(r *Requirement, source string) {
+		r.Project = name
+		r.Source = source
+		requirements = append(requirements, r)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "requirements.txt"))
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if r := parsePipRequirement(scanner.Text()); r != nil {
+				add(r, "requirements.txt")
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range config.Package.Dependencies {
+		if r := parsePipRequirement(dependency); r != nil {
+			add(r, project.ConfigFileName)
+		}
+	}
+
+	dependencies, devDependencies, err := PackageJSONDependencies(filepath.Join(dir, "package.json"))
+	if err == nil {
+		for _, dependencies := range []map[string]string{dependencies, devDependencies} {
+			for pkg, spec := range dependencies {
+				if r, ok := ParseNpm(spec); ok {
+					add(&Requirement{Ecosystem: Npm, Package: pkg, Spec: spec, Range: r}, "package.json")
+				}
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return requirements, nil
+}
+
+// FindProjects returns the directories below root that contain a
+// langforge.yaml file, including root itself. Hidden directories, virtual
+// environments and node_modules are skipped.
+func FindProjects(root string) ([]string, error) {
+	projects := []string{}
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		name := entry.Name()
+		if path != root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "venv") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(project.ConfigPath(path)); err == nil {
+			projects = append(projects, path)
+		}
+		return nil
+	})
+	return projects, err
+}
+
+// Check returns the packages whose requirements cannot be satisfied by a
+// single version, ordered by ecosystem and package name.
+func Check(requirements []*Requirement) []*Conflict {
+	byPackage := map[string][]*Requirement{}
+	keys := []string{}
+	for _, r := range requirements {
+		if r.Range.Any() {
+			continue
+		}
+		key := r.Ecosystem + " " + r.Package
+		if _, ok := byPackage[key]; !ok {
+			keys = append(keys, key)
+		}
+		byPackage[key] = append(byPackage[key], r)
+	}
+	sort.Strings(keys)
+
+	conflicts := []*Conflict{}
+	for _, key := range keys {
+		if minimal := minimalConflict(byPackage[key]); minimal != nil {
+			conflicts = append(conflicts, &Conflict{
+				Ecosystem:    minimal[0].Ecosystem,
+				Package:      minimal[0].Package,
+				Requirements: minimal,
+			})
+		}
+	}
+	return conflicts
+}
+
+// minimalConflict returns a smallest set of requirements that cannot be
+// satisfied together, or nil if all of them can.
+func minimalConflict(requirements []*Requirement) []*Requirement {
+	for i, r := range requirements {
+		if r.Range.Empty() {
+			return []*Requirement{r}
+		}
+		for _, s := range requirements[i+1:] {
+			if r.Range.Intersect(s.Range).Empty() {
+				return []*Requirement{r, s}
+			}
+		}
+	}
+
+	// ranges with alternatives ("1.x || 3.x") can conflict without any two of
+	// them conflicting, name all of them in that case
+	all := anyVersion
+	for _, r := range requirements {
+		all = all.Intersect(r.Range)
+	}
+	if all.Empty() {
+		return requirements
+	}
+	return nil
+}
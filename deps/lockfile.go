@@ -0,0 +1,99 @@
+package deps
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lockedField matches the name and version fields of the packages of
+// poetry.lock and uv.lock, e.g. `name = "openai"`.
+var lockedField = regexp.MustCompile(`^(name|version)\s*=\s*"([^"]*)"`)
+
+// LockedPackages returns the versions of the Python packages of a lockfile of
+// poetry, uv or pipenv by their normalized names, with the packages that the
+// locked ones depend on.
+func LockedPackages(path string) (map[string]string, error) {
+	if filepath.Base(path) == "Pipfile.lock" {
+		return pipfileLockPackages(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	packages := map[string]string{}
+	name := ""
+	inPackage := false
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			// the fields of a package precede its tables, e.g. [package.extras]
+			inPackage = line == "[[package]]"
+			name = ""
+			continue
+		}
+		m := lockedField.FindStringSubmatch(line)
+		if !inPackage || m == nil {
+			continue
+		}
+		if m[1] == "name" {
+			name = normalizeName(m[2])
+			packages[name] = ""
+		} else if name != "" {
+			packages[name] = m[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return packages, nil
+}
+
+func pipfileLockPackages(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock struct {
+		Default map[string]struct {
+			Version string `json:"version"`
+		} `json:"default"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	packages := map[string]string{}
+	for name, pkg := range lock.Default {
+		packages[normalizeName(name)] = strings.TrimPrefix(pkg.Version, "==")
+	}
+	return packages, nil
+}
+
+// UnlockedRequirements returns the Python requirements whose packages the
+// lockfile at path does not have, and the locked packages of the others with
+// their versions, e.g. "openai 1.40.0", so that the versions a project
+// locked are not changed.
+func UnlockedRequirements(path string, requirements []string) ([]string, []string, error) {
+	locked, err := LockedPackages(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlocked, kept := []string{}, []string{}
+	for _, requirement := range requirements {
+		name := requirementName(requirement)
+		if version, ok := locked[name]; ok {
+			kept = append(kept, strings.TrimSpace(name+" "+version))
+			continue
+		}
+		unlocked = append(unlocked, requirement)
+	}
+	return unlocked, kept, nil
+}
@@ -0,0 +1,85 @@
+package deps
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is a release version as a list of numeric components. Pre-release
+// and local suffixes are ignored, so "2.0.0b1" is treated as "2.0.0".
+type Version []int
+
+// parseVersion parses the leading numeric components of s. Wildcard
+// components ("*", "x") end the version. It returns the version and the number
+// of components that were given.
+func parseVersion(s string) (Version, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, false
+	}
+
+	version := Version{}
+	for _, part := range strings.Split(s, ".") {
+		if part == "*" || part == "x" || part == "X" {
+			break
+		}
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			if len(version) == 0 {
+				return nil, false
+			}
+			break
+		}
+		n, err := strconv.Atoi(part[:end])
+		if err != nil {
+			return nil, false
+		}
+		version = append(version, n)
+		if end < len(part) {
+			// a suffix such as "b1" or "-rc.1" ends the release part
+			break
+		}
+	}
+	return version, true
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than w.
+// Missing components are zero.
+func (v Version) Compare(w Version) int {
+	for i := 0; i < len(v) || i < len(w); i++ {
+		a, b := 0, 0
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(w) {
+			b = w[i]
+		}
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	}
+	return 0
+}
+
+// bump returns the lowest version whose component i is higher than that of v,
+// e.g. bumping 1.4.2 at 1 gives 1.5.
+func (v Version) bump(i int) Version {
+	bumped := make(Version, i+1)
+	copy(bumped, v)
+	bumped[i]++
+	return bumped
+}
+
+func (v Version) String() string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,230 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// op is an edit of a line: ' ' keeps it, '-' removes it, '+' inserts it. The
+// line ends with its line break unless it is the last line of a file without
+// a final line break.
+type op struct {
+	kind byte
+	line string
+}
+
+// lines splits text into lines with their line breaks, so that a last line
+// without one differs from the same line with one.
+func lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	split := strings.SplitAfter(text, "\n")
+	if split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	}
+	return split
+}
+
+// edits returns the shortest edit script from a to b, computed from the
+// longest common subsequence. Files edited by langforge are small, so the
+// quadratic table is fine.
+func edits(a []string, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []op{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// Hunk is a group of nearby changes with their context lines.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// from and to delimit the hunk in the edit script of its patch
+	from int
+	to   int
+	ops  []op
+}
+
+// String returns the hunk in unified diff format.
+func (h *Hunk) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	for _, o := range h.ops[h.from:h.to] {
+		out.WriteByte(o.kind)
+		out.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return out.String()
+}
+
+// Patch holds the differences between two versions of a file as hunks, which
+// can be applied selectively.
+type Patch struct {
+	OldName string
+	NewName string
+	Hunks   []*Hunk
+	old     string
+	new     string
+	ops     []op
+}
+
+// New computes the patch from old to new with the given number of context
+// lines. oldName and newName label the two versions in the header.
+func New(oldName string, newName string, old string, new string, context int) *Patch {
+	p := &Patch{OldName: oldName, NewName: newName, old: old, new: new}
+	if old == new {
+		return p
+	}
+	p.ops = edits(lines(old), lines(new))
+	ops := p.ops
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// extend the hunk while changes are separated by at most 2*context lines
+		end := start
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		// line numbers of the hunk in both versions
+		hunk := &Hunk{OldStart: 1, NewStart: 1, from: from, to: to, ops: ops}
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				hunk.OldStart++
+			}
+			if o.kind != '-' {
+				hunk.NewStart++
+			}
+		}
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				hunk.OldLines++
+			}
+			if o.kind != '-' {
+				hunk.NewLines++
+			}
+		}
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+
+		p.Hunks = append(p.Hunks, hunk)
+		start = to
+	}
+	return p
+}
+
+// String returns the patch in unified diff format, or an empty string if the
+// versions are equal.
+func (p *Patch) String() string {
+	if len(p.Hunks) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", p.OldName, p.NewName)
+	for _, hunk := range p.Hunks {
+		out.WriteString(hunk.String())
+	}
+	return out.String()
+}
+
+// Apply returns the old version with the changes of the given hunks of the
+// patch applied. The changes of all other hunks are left out.
+func (p *Patch) Apply(hunks []*Hunk) string {
+	if len(hunks) == 0 {
+		return p.old
+	}
+	if len(hunks) == len(p.Hunks) {
+		return p.new
+	}
+
+	applied := make([]bool, len(p.ops))
+	for _, hunk := range hunks {
+		for i := hunk.from; i < hunk.to; i++ {
+			applied[i] = true
+		}
+	}
+
+	result := []string{}
+	for i, o := range p.ops {
+		switch {
+		case o.kind == ' ':
+			result = append(result, o.line)
+		case o.kind == '-' && !applied[i]:
+			result = append(result, o.line)
+		case o.kind == '+' && applied[i]:
+			result = append(result, o.line)
+		}
+	}
+	return strings.Join(result, "")
+}
+
+// Unified returns the differences between old and new in unified diff format
+// with the given number of context lines, or an empty string if they are
+// equal. oldName and newName label the two versions in the header.
+func Unified(oldName string, newName string, old string, new string, context int) string {
+	return New(oldName, newName, old, new, context).String()
+}
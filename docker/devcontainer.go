@@ -0,0 +1,133 @@
+package docker
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/shim"
+	"langforge/system"
+	"path/filepath"
+	"strings"
+)
+
+// DevcontainerDir is the directory of the development container of a project.
+const DevcontainerDir = ".devcontainer"
+
+// DevcontainerFileNames are the files that GenerateDevcontainer returns,
+// relative to DevcontainerDir.
+var DevcontainerFileNames = []string{"devcontainer.json", "Dockerfile"}
+
+// JupyterPort is the port of JupyterLab, which the development containers of
+// Python projects forward besides that of 'langforge serve'.
+const JupyterPort = 8888
+
+// Devcontainer describes the development container of a project, which is the
+// data of the templates.
+type Devcontainer struct {
+	Name string
+	// Runtime is Node for projects whose first chain is a JavaScript or
+	// TypeScript module, or that have a package.json and no Python
+	// dependencies, and Python otherwise.
+	Runtime string
+	// Version is the version of the image of the runtime, e.g. "3.11".
+	Version string
+	// NodeVersion is the version of the Node.js feature of Python projects
+	// with a package.json, empty for others.
+	NodeVersion string
+	// Pnpm is set if the Node.js feature installs pnpm.
+	Pnpm bool
+	// Tools are the packages that the Dockerfile installs globally with pip
+	// or npm, besides langforge: the tool of the Python dependencies or the
+	// package manager of Node.js projects.
+	Tools []string
+	// PostCreateCommand installs the dependencies of the project once the
+	// workspace is mounted, Python packages into .venv.
+	PostCreateCommand string
+	Extensions        []string
+	Ports             []int
+}
+
+// NewDevcontainer returns the development container of the project in dir.
+// Like NewSpec, it uses the versions of the interpreters of the active
+// environment unless version is given.
+func NewDevcontainer(dir string, config *project.Config, version string) *Devcontainer {
+	d := &Devcontainer{Name: config.Name, Ports: []int{DefaultPort}}
+	if d.Name == "" {
+		d.Name = filepath.Base(dir)
+	}
+
+	hasPackageJSON := fileExists(filepath.Join(dir, "package.json"))
+	pythonManager, _ := system.DetectPythonProjectManager(dir)
+	hasPython := pythonManager != "" || fileExists(filepath.Join(dir, "requirements.txt"))
+	d.Runtime = Python
+	switch {
+	case len(config.Chains) > 0 && shim.IsNode(config.Chains[0].Notebook):
+		d.Runtime = Node
+	case len(config.Chains) == 0 && hasPackageJSON && !hasPython:
+		d.Runtime = Node
+	}
+
+	if version == "" {
+		version = localVersion(d.Runtime)
+	}
+	d.Version = baseImageVersion(d.Runtime, version)
+
+	commands := []string{}
+	nodeManager, _ := system.DetectNodePackageManager(dir)
+	if d.Runtime == Python {
+		d.Ports = append(d.Ports, JupyterPort)
+		d.Extensions = []string{"ms-python.python", "ms-toolsai.jupyter"}
+		switch pythonManager {
+		case system.InstallerPoetry:
+			d.Tools = []string{"poetry"}
+			commands = append(commands, "POETRY_VIRTUALENVS_IN_PROJECT=true poetry install --no-root")
+		case system.InstallerUv:
+			d.Tools = []string{"uv"}
+			commands = append(commands, "uv sync --locked")
+		case system.InstallerPipenv:
+			d.Tools = []string{"pipenv"}
+			commands = append(commands, "PIPENV_VENV_IN_PROJECT=1 pipenv install --dev --deploy")
+		default:
+			commands = append(commands, "python -m venv .venv")
+			if hasPython {
+				commands = append(commands, ".venv/bin/pip install -r requirements.txt")
+			}
+		}
+		if hasPackageJSON {
+			d.NodeVersion = baseImageVersion(Node, localVersion(Node))
+			d.Pnpm = nodeManager == system.Pnpm
+			if nodeManager == system.Bun {
+				commands = append(commands, "npm install -g bun")
+			}
+		}
+	} else {
+		switch nodeManager {
+		case system.Pnpm, system.Bun:
+			d.Tools = []string{nodeManager}
+		}
+	}
+	if hasPackageJSON {
+		d.Extensions = append(d.Extensions, "dbaeumer.vscode-eslint")
+		commands = append(commands, system.NodeInstallCommand(dir))
+	}
+	d.Extensions = append(d.Extensions, "redhat.vscode-yaml")
+	d.PostCreateCommand = strings.Join(commands, " && ")
+	return d
+}
+
+// GenerateDevcontainer returns the contents of the files of
+// DevcontainerFileNames for the development container.
+func GenerateDevcontainer(d *Devcontainer) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	for _, name := range DevcontainerFileNames {
+		templateName := "devcontainer.json.tmpl"
+		if name == "Dockerfile" {
+			templateName = fmt.Sprintf("devcontainer.Dockerfile.%s.tmpl", d.Runtime)
+		}
+		data, err := render(templateName, d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %v", name, err)
+		}
+		result[name] = data
+	}
+	return result, nil
+}
@@ -0,0 +1,283 @@
+package docker
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"langforge/bundle"
+	"langforge/gateway"
+	"langforge/project"
+	"langforge/shim"
+	"langforge/system"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+func init() {
+	files, err := fs.Sub(templates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.Docker, files)
+}
+
+// DefaultPort is the port that the gateway listens on in the container.
+const DefaultPort = 2204
+
+// Versions of the base images if the version of the local interpreter is
+// unknown.
+const (
+	DefaultPythonVersion = "3.11"
+	DefaultNodeVersion   = "20"
+)
+
+// Runtimes of the images.
+const (
+	Python = "python"
+	Node   = "node"
+)
+
+// FileNames are the files that Generate returns, relative to the project
+// directory.
+var FileNames = []string{"Dockerfile", "docker-compose.yml", ".dockerignore"}
+
+// templateNames are the templates of the files, which are named without
+// leading dots since embed leaves out such files.
+var templateNames = map[string]string{
+	"Dockerfile":         "Dockerfile.%s.tmpl",
+	"docker-compose.yml": "docker-compose.yml.tmpl",
+	".dockerignore":      "dockerignore.tmpl",
+}
+
+// Options are the settings of the image of a project that are not detected.
+type Options struct {
+	// Entry is the notebook or module that the container serves, by default
+	// that of the first chain of langforge.yaml.
+	Entry string
+	Port  int
+	// Version is the version of Python or Node.js of the base image, by
+	// default that of the interpreter of the active environment.
+	Version string
+}
+
+// Spec describes the image of a project, which is the data of the templates.
+type Spec struct {
+	// Service is the name of the compose service and of the image: the name
+	// of the project in the characters that docker allows.
+	Service string
+	Entry   string
+	Port    int
+	// Runtime is Python for notebooks and Node for JavaScript and TypeScript
+	// modules.
+	Runtime string
+	// Version is the version of the base image, e.g. "3.11" or "20".
+	Version string
+	// Manager is the tool that installs the dependencies: pip, poetry, uv or
+	// pipenv for Python and npm, yarn, pnpm or bun for Node.js.
+	Manager string
+	// DependencyFiles are the files that the dependencies are installed from.
+	// They are copied before the rest of the project, so that the layer of
+	// the dependencies is only rebuilt when they change.
+	DependencyFiles []string
+	// InstallCommand installs the Node.js dependencies.
+	InstallCommand string
+	// Cache is the cache directory of Manager, which builds keep in a cache
+	// mount.
+	Cache string
+	// HealthPath is the liveness probe of the gateway.
+	HealthPath string
+}
+
+// packageCaches are the cache directories of the tools as root in the
+// official images.
+var packageCaches = map[string]string{
+	system.InstallerPip:    "/root/.cache/pip",
+	system.InstallerPoetry: "/root/.cache/pypoetry",
+	system.InstallerUv:     "/root/.cache/uv",
+	system.InstallerPipenv: "/root/.cache/pipenv",
+	system.Npm:             "/root/.npm",
+	system.Yarn:            "/usr/local/share/.cache/yarn",
+	system.Pnpm:            "/root/.local/share/pnpm/store",
+	system.Bun:             "/root/.bun/install/cache",
+}
+
+// pythonDependencyFiles are the files that the Python tools install from.
+var pythonDependencyFiles = map[string][]string{
+	system.InstallerPip:    {"requirements.txt"},
+	system.InstallerPoetry: {"pyproject.toml", "poetry.lock"},
+	system.InstallerUv:     {"pyproject.toml", "uv.lock"},
+	system.InstallerPipenv: {"Pipfile", "Pipfile.lock"},
+}
+
+// nodeDependencyFiles are the files that Node.js package managers install
+// from, if the project has them.
+var nodeDependencyFiles = []string{
+	"package.json",
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	".yarnrc.yml",
+	"pnpm-lock.yaml",
+	"pnpm-workspace.yaml",
+	"bun.lockb",
+	"bun.lock",
+	"bunfig.toml",
+	".npmrc",
+}
+
+var invalidServiceChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// NewSpec returns the image of the project in dir. The versions of the
+// interpreters are those of the active environment, so the project
+// environment should be activated first.
+func NewSpec(dir string, config *project.Config, options Options) (*Spec, error) {
+	spec := &Spec{
+		Service:    strings.Trim(invalidServiceChars.ReplaceAllString(strings.ToLower(config.Name), "-"), "-_"),
+		Entry:      filepath.ToSlash(options.Entry),
+		Port:       options.Port,
+		HealthPath: gateway.LivenessPath,
+	}
+	if spec.Service == "" {
+		spec.Service = "app"
+	}
+	if spec.Port == 0 {
+		spec.Port = DefaultPort
+	}
+	if spec.Entry == "" {
+		if len(config.Chains) == 0 {
+			return nil, fmt.Errorf("no chains declared in %s, name the notebook or module to serve", project.ConfigFileName)
+		}
+		spec.Entry = filepath.ToSlash(config.Chains[0].Notebook)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(spec.Entry))); err != nil {
+		return nil, fmt.Errorf("%s not found", spec.Entry)
+	}
+
+	if shim.IsNode(spec.Entry) {
+		spec.Runtime = Node
+		spec.Manager, _ = system.DetectNodePackageManager(dir)
+		spec.InstallCommand = system.NodeInstallCommand(dir)
+		for _, name := range nodeDependencyFiles {
+			if fileExists(filepath.Join(dir, name)) {
+				spec.DependencyFiles = append(spec.DependencyFiles, name)
+			}
+		}
+		if !fileExists(filepath.Join(dir, "package.json")) {
+			return nil, fmt.Errorf("the project has no package.json")
+		}
+	} else {
+		spec.Runtime = Python
+		spec.Manager, _ = system.DetectPythonProjectManager(dir)
+		if spec.Manager == "" {
+			spec.Manager = system.InstallerPip
+		}
+		spec.DependencyFiles = pythonDependencyFiles[spec.Manager]
+		for _, name := range spec.DependencyFiles {
+			if !fileExists(filepath.Join(dir, name)) {
+				return nil, fmt.Errorf("the project has no %s", name)
+			}
+		}
+	}
+	spec.Cache = packageCaches[spec.Manager]
+
+	version := options.Version
+	if version == "" {
+		version = localVersion(spec.Runtime)
+	}
+	spec.Version = baseImageVersion(spec.Runtime, version)
+	return spec, nil
+}
+
+// localVersion returns the version of the interpreter of the runtime in the
+// active environment, or an empty string if it is not installed.
+func localVersion(runtime string) string {
+	if runtime == Node {
+		if nodePath, err := system.FindNode(); err == nil {
+			version, _ := system.NodeVersion(nodePath)
+			return version
+		}
+		return ""
+	}
+	if pythonPath, err := system.FindPython(); err == nil {
+		version, _ := system.PythonVersion(pythonPath)
+		return version
+	}
+	return ""
+}
+
+var leadingVersion = regexp.MustCompile(`^v?(\d+)(\.\d+)?`)
+
+// baseImageVersion returns the tag of the base image of a version: the minor
+// release for Python, e.g. "3.11" for "3.11.4", and the major release for
+// Node.js, whose images receive the updates of a release line.
+func baseImageVersion(runtime string, version string) string {
+	match := leadingVersion.FindStringSubmatch(strings.TrimSpace(version))
+	switch {
+	case runtime == Node && match != nil:
+		return match[1]
+	case runtime == Node:
+		return DefaultNodeVersion
+	case match != nil && match[2] != "":
+		return match[1] + match[2]
+	default:
+		return DefaultPythonVersion
+	}
+}
+
+// Generate returns the contents of the files of FileNames for the image.
+func Generate(spec *Spec) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	for _, name := range FileNames {
+		templateName := templateNames[name]
+		if name == "Dockerfile" {
+			templateName = fmt.Sprintf(templateName, spec.Runtime)
+		}
+		data, err := render(templateName, spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %v", name, err)
+		}
+		result[name] = data
+	}
+	return result, nil
+}
+
+// render executes a template of the Docker section of the bundle.
+func render(name string, data any) ([]byte, error) {
+	files, err := bundle.FS(bundle.Docker)
+	if err != nil {
+		return nil, err
+	}
+	funcs := template.FuncMap{
+		"join": strings.Join,
+		"json": func(value any) (string, error) {
+			var encoded bytes.Buffer
+			encoder := json.NewEncoder(&encoded)
+			// commands are not embedded in HTML, so && stays readable
+			encoder.SetEscapeHTML(false)
+			err := encoder.Encode(value)
+			return strings.TrimSpace(encoded.String()), err
+		},
+	}
+	tmpl, err := template.New(name).Funcs(funcs).ParseFS(files, name)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package envfile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// FileName is the name of the .env file of a project.
+const FileName = ".env"
+
+// Env holds the variables of a .env file.
+type Env map[string]string
+
+// Read reads the .env file at path. A file that does not exist has no
+// variables.
+func Read(path string) (Env, error) {
+	env, err := godotenv.Read(path)
+	if os.IsNotExist(err) {
+		return Env{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Load reads the .env file of the project in dir.
+func Load(dir string) (Env, error) {
+	return Read(filepath.Join(dir, FileName))
+}
+
+// Write writes env to the .env file at path, sorted by name. The file is only
+// readable by the user, since it holds API keys.
+func Write(path string, env Env) error {
+	content, err := godotenv.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content+"\n"), 0600)
+}
+
+// Merge returns the variables of all envs, those of later ones taking
+// precedence.
+func Merge(envs ...Env) Env {
+	result := Env{}
+	for _, env := range envs {
+		for key, value := range env {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// Keys returns the names of the variables of env, sorted.
+func (e Env) Keys() []string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Render fills in a template such as .env.example: variables without a value
+// or with a placeholder value take the value of the first of sources that has
+// one, and references to variables as ${NAME} are expanded with the rendered
+// variables and the sources. Variables that no source has stay empty.
+func Render(template Env, sources ...Env) Env {
+	lookup := func(key string) (string, bool) {
+		for _, source := range sources {
+			if value, ok := source[key]; ok && value != "" {
+				return value, true
+			}
+		}
+		return "", false
+	}
+
+	filled := Env{}
+	for key, value := range template {
+		if value == "" || IsPlaceholder(value) {
+			value, _ = lookup(key)
+		}
+		filled[key] = value
+	}
+	rendered := Env{}
+	for key, value := range filled {
+		rendered[key] = os.Expand(value, func(name string) string {
+			if value := filled[name]; value != "" && !strings.Contains(value, "$") {
+				return value
+			}
+			value, _ := lookup(name)
+			return value
+		})
+	}
+	return rendered
+}
+
+// Environ returns base, a list of "key=value" entries such as os.Environ(),
+// with the variables of env that base does not set, for the environment of a
+// command: variables of the process take precedence over the .env file, as
+// with python-dotenv and dotenv for Node.js. The variables reach the command
+// without being set in the environment of langforge.
+func Environ(base []string, env Env) []string {
+	result := append([]string{}, base...)
+	set := map[string]bool{}
+	for _, entry := range base {
+		key, _, _ := strings.Cut(entry, "=")
+		set[normalizeKey(key)] = true
+	}
+	for _, key := range env.Keys() {
+		if !set[normalizeKey(key)] {
+			result = append(result, key+"="+env[key])
+		}
+	}
+	return result
+}
+
+// normalizeKey returns the name of a variable as the system compares it,
+// case-insensitively on Windows.
+func normalizeKey(key string) string {
+	if filepath.Separator == '\\' {
+		return strings.ToUpper(key)
+	}
+	return key
+}
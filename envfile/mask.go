@@ -0,0 +1,154 @@
+package envfile
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MinSecretLength is the length below which values are not masked, since
+// short values such as "true" or a port would mask unrelated output.
+const MinSecretLength = 8
+
+// secretNamePattern matches the names of variables that hold secrets.
+var secretNamePattern = regexp.MustCompile(`(?i)(?:KEY|TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIALS?|AUTH)$|API_KEY|SECRET|PASSWORD|PRIVATE`)
+
+// secretValuePattern matches values that are secrets whatever their names,
+// the API keys and tokens of well-known services.
+var secretValuePattern = regexp.MustCompile(`^(?:sk-|sk-ant-|hf_|gsk_|ghp_|gho_|github_pat_|xox[abpr]-|AKIA|AIza|lsv2_|ls__)`)
+
+// IsSecret reports whether the variable holds a secret, by its name or the
+// format of its value.
+func IsSecret(key string, value string) bool {
+	return secretNamePattern.MatchString(key) || secretValuePattern.MatchString(value)
+}
+
+// MaskValue hides a secret but the first characters of the longer ones, which
+// tell which key it is.
+func MaskValue(value string) string {
+	if len(value) < 16 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
+
+// Masker replaces the secrets of .env files in output, e.g. the output of
+// workers and scripts, with their masked values. The zero Masker and a nil
+// Masker mask nothing.
+type Masker struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+// NewMasker returns a masker of the secrets of envs.
+func NewMasker(envs ...Env) *Masker {
+	m := &Masker{}
+	for _, env := range envs {
+		for key, value := range env {
+			if IsSecret(key, value) {
+				m.Add(value)
+			}
+		}
+	}
+	return m
+}
+
+// Add adds secrets to the masker.
+func (m *Masker) Add(secrets ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.secrets == nil {
+		m.secrets = map[string]bool{}
+	}
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); len(secret) >= MinSecretLength {
+			m.secrets[secret] = true
+		}
+	}
+
+	// longer secrets first, so that a secret that contains another one is
+	// masked as a whole
+	sorted := make([]string, 0, len(m.secrets))
+	for secret := range m.secrets {
+		sorted = append(sorted, secret)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, secret := range sorted {
+		pairs = append(pairs, secret, MaskValue(secret))
+	}
+	m.replacer = strings.NewReplacer(pairs...)
+}
+
+// Empty reports whether the masker has no secrets.
+func (m *Masker) Empty() bool {
+	if m == nil {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.replacer == nil
+}
+
+// Mask returns s with the secrets masked.
+func (m *Masker) Mask(s string) string {
+	if m == nil {
+		return s
+	}
+	m.mu.RLock()
+	replacer := m.replacer
+	m.mu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// Writer returns a writer that masks the secrets of what is written to it
+// and writes it to w, line by line so that a secret is not split between two
+// writes. Incomplete lines are held back until they are complete or longer
+// than any line that is likely to hold a secret. Without secrets, it returns
+// w itself.
+func (m *Masker) Writer(w io.Writer) io.Writer {
+	if m.Empty() {
+		return w
+	}
+	return &maskWriter{masker: m, w: w}
+}
+
+// maxPending is the length of an incomplete line at which a mask writer
+// writes it anyway, e.g. a progress bar.
+const maxPending = 4096
+
+type maskWriter struct {
+	masker  *Masker
+	w       io.Writer
+	mu      sync.Mutex
+	pending []byte
+}
+
+func (w *maskWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	end := bytes.LastIndexAny(w.pending, "\n\r") + 1
+	if end == 0 && len(w.pending) >= maxPending {
+		end = len(w.pending)
+	}
+	if end > 0 {
+		if _, err := io.WriteString(w.w, w.masker.Mask(string(w.pending[:end]))); err != nil {
+			return 0, err
+		}
+		w.pending = append(w.pending[:0], w.pending[end:]...)
+	}
+	return len(p), nil
+}
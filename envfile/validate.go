@@ -0,0 +1,95 @@
+package envfile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Problem is a problem with a variable of a .env file.
+type Problem struct {
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Key + " " + p.Message
+}
+
+// keyPrefixes are the prefixes that the API keys of providers start with.
+var keyPrefixes = map[string][]string{
+	"OPENAI_API_KEY":           {"sk-"},
+	"ANTHROPIC_API_KEY":        {"sk-ant-"},
+	"GOOGLE_API_KEY":           {"AIza"},
+	"GEMINI_API_KEY":           {"AIza"},
+	"HUGGINGFACEHUB_API_TOKEN": {"hf_"},
+	"HF_TOKEN":                 {"hf_"},
+	"GROQ_API_KEY":             {"gsk_"},
+	"LANGCHAIN_API_KEY":        {"ls__", "lsv2_"},
+}
+
+// placeholderPattern matches the values of templates that stand for a value
+// to fill in, e.g. "your-api-key", "<token>", "sk-..." or "changeme".
+var placeholderPattern = regexp.MustCompile(`(?i)^(?:<.*>|\[.*\]|your[-_ ].*|.*\.\.\.|(?:[a-z]+[-_])*x{4,}|changeme|replace[-_ ]?me|todo|tbd)$`)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsPlaceholder reports whether a value stands for a value to fill in rather
+// than being one.
+func IsPlaceholder(value string) bool {
+	return placeholderPattern.MatchString(strings.TrimSpace(value))
+}
+
+// Validate checks the variables of env and reports the required ones that
+// are missing or empty, placeholders, values with surrounding whitespace or
+// quotes, which are likely pasted by mistake, API keys that do not have the
+// format of their provider and names that are not valid variable names. The
+// problems are sorted by variable.
+func Validate(env Env, required []string) []Problem {
+	problems := []Problem{}
+	isRequired := map[string]bool{}
+	for _, key := range required {
+		isRequired[key] = true
+	}
+	keys := env.Keys()
+	for key := range isRequired {
+		if _, ok := env[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := env[key]
+		switch {
+		case !ok || value == "":
+			if isRequired[key] {
+				problems = append(problems, Problem{key, "is not set"})
+			}
+			continue
+		case !namePattern.MatchString(key):
+			problems = append(problems, Problem{key, "is not a valid variable name"})
+		case IsPlaceholder(value):
+			problems = append(problems, Problem{key, "is a placeholder, set it to the actual value"})
+			continue
+		case strings.TrimSpace(value) != value:
+			problems = append(problems, Problem{key, "has leading or trailing whitespace"})
+		case len(value) > 1 && strings.ContainsAny(value[:1], `"'`) && value[len(value)-1] == value[0]:
+			problems = append(problems, Problem{key, "is quoted twice"})
+		}
+		if prefixes, known := keyPrefixes[key]; known && !hasAnyPrefix(strings.TrimSpace(value), prefixes) {
+			problems = append(problems, Problem{key, fmt.Sprintf("does not look like an API key, which starts with %s", strings.Join(prefixes, " or "))})
+		}
+	}
+	return problems
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,11 @@ type Integration struct {
 	ApiKeys             []string `yaml:"apiKeys"`
 	PreInstallCommands  []string `yaml:"preInstallCommands"`
 	PostInstallCommands []string `yaml:"postInstallCommands"`
+	// ArchPackages replaces Packages on the given architectures ("amd64", "arm64").
+	ArchPackages map[string][]string `yaml:"archPackages"`
+	// NoWheels lists the architectures for which some packages have no binary
+	// wheels and need to be built from source.
+	NoWheels []string `yaml:"noWheels"`
 }
 
 func (i *Integration) GetTitle() string {
@@ -39,9 +44,30 @@ func (i *Integration) Copy() *Integration {
 		ApiKeys:             i.ApiKeys,
 		PreInstallCommands:  i.PreInstallCommands,
 		PostInstallCommands: i.PostInstallCommands,
+		ArchPackages:        i.ArchPackages,
+		NoWheels:            i.NoWheels,
 	}
 }
 
+// PackagesFor returns the packages to install on the given architecture.
+func (i *Integration) PackagesFor(arch string) []string {
+	if packages, ok := i.ArchPackages[arch]; ok {
+		return packages
+	}
+	return i.Packages
+}
+
+// HasWheels reports whether the integration's packages can be installed from
+// binary wheels on the given architecture.
+func (i *Integration) HasWheels(arch string) bool {
+	for _, a := range i.NoWheels {
+		if a == arch {
+			return false
+		}
+	}
+	return true
+}
+
 func CopyIntegrations(integrations []*Integration) []*Integration {
 	result := []*Integration{}
 	for _, integration := range integrations {
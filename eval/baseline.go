@@ -0,0 +1,123 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BaselineDir is the directory of a project that holds the baselines of its
+// evaluations, which are committed with the project.
+const BaselineDir = "evals/baselines"
+
+// BaselinePath returns the path of the baseline of the evaluation or chain
+// with the given name in the project in dir.
+func BaselinePath(dir string, name string) string {
+	return filepath.Join(dir, filepath.FromSlash(BaselineDir), name+".json")
+}
+
+// LoadReport reads a report written by WriteJSON, e.g. a baseline.
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %v", path, err)
+	}
+	return report, nil
+}
+
+// CaseChange is a case whose result differs from the result of the same case,
+// with the same inputs and expected output, in the baseline.
+type CaseChange struct {
+	Baseline CaseResult
+	Current  CaseResult
+	// Reasons describe the changes, e.g. "no longer passes" or "exact score
+	// dropped from 1.00 to 0.00".
+	Reasons []string
+}
+
+// Comparison is the result of comparing a run with its baseline.
+type Comparison struct {
+	// Regressions are the cases that no longer pass or whose score dropped.
+	Regressions []CaseChange
+	// Improvements are the cases that pass now or whose score rose, and did
+	// not regress otherwise.
+	Improvements []CaseChange
+	Unchanged    int
+	// Added are the cases that the baseline does not have, Removed the cases
+	// of the baseline that the run does not have.
+	Added   []CaseResult
+	Removed []CaseResult
+	// Settings describe how the run was generated or scored differently
+	// than the baseline, e.g. "temperature 0.7 instead of 0", which makes
+	// its scores incomparable.
+	Settings []string
+}
+
+// Compare compares the cases of a run with those of its baseline. A score
+// that dropped by at most tolerance, e.g. of a noisy llm scorer, is not a
+// regression, and one that rose by at most tolerance is not an improvement.
+func Compare(baseline *Report, current *Report, tolerance float64) *Comparison {
+	comparison := &Comparison{Settings: settingsChanges(baseline.Settings, current.Settings)}
+	baselineCases := map[string]CaseResult{}
+	for _, c := range baseline.Cases {
+		baselineCases[caseKey(c)] = c
+	}
+
+	seen := map[string]bool{}
+	for _, c := range current.Cases {
+		key := caseKey(c)
+		seen[key] = true
+		before, ok := baselineCases[key]
+		if !ok {
+			comparison.Added = append(comparison.Added, c)
+			continue
+		}
+
+		regressions, improvements := []string{}, []string{}
+		switch {
+		case before.Passed && !c.Passed:
+			regressions = append(regressions, "no longer passes")
+		case !before.Passed && c.Passed:
+			improvements = append(improvements, "passes now")
+		}
+		for _, scorer := range current.Scorers {
+			old, hadScore := before.Scores[scorer]
+			score, hasScore := c.Scores[scorer]
+			switch {
+			case !hadScore || !hasScore:
+			case score < old-tolerance:
+				regressions = append(regressions, fmt.Sprintf("%s score dropped from %.2f to %.2f", scorer, old, score))
+			case score > old+tolerance:
+				improvements = append(improvements, fmt.Sprintf("%s score rose from %.2f to %.2f", scorer, old, score))
+			}
+		}
+
+		switch {
+		case len(regressions) > 0:
+			comparison.Regressions = append(comparison.Regressions, CaseChange{Baseline: before, Current: c, Reasons: regressions})
+		case len(improvements) > 0:
+			comparison.Improvements = append(comparison.Improvements, CaseChange{Baseline: before, Current: c, Reasons: improvements})
+		default:
+			comparison.Unchanged++
+		}
+	}
+
+	for _, c := range baseline.Cases {
+		if !seen[caseKey(c)] {
+			comparison.Removed = append(comparison.Removed, c)
+		}
+	}
+	return comparison
+}
+
+// caseKey identifies a case by its inputs and expected output, so that cases
+// are matched when examples are added to or reordered in the dataset.
+func caseKey(c CaseResult) string {
+	data, _ := json.Marshal([]interface{}{c.Inputs, c.Expected})
+	return string(data)
+}
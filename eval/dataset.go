@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Example is a single record of an evaluation dataset.
+type Example struct {
+	Index  int
+	Fields map[string]string
+}
+
+// LoadDataset reads the examples of a JSONL or CSV dataset. The format is
+// determined by the file extension.
+func LoadDataset(path string) ([]Example, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return readJSONL(file)
+	case ".csv":
+		return readCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported dataset format %q, expected .jsonl or .csv", filepath.Ext(path))
+	}
+}
+
+func readJSONL(r io.Reader) ([]Example, error) {
+	examples := []Example{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		record := make(map[string]any)
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("invalid JSON on line %d: %v", line, err)
+		}
+
+		fields := make(map[string]string)
+		for key, value := range record {
+			if s, ok := value.(string); ok {
+				fields[key] = s
+				continue
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = string(data)
+		}
+
+		examples = append(examples, Example{Index: len(examples) + 1, Fields: fields})
+	}
+
+	return examples, scanner.Err()
+}
+
+func readCSV(r io.Reader) ([]Example, error) {
+	reader := csv.NewReader(r)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return []Example{}, nil
+	}
+
+	header := records[0]
+	examples := []Example{}
+	for _, record := range records[1:] {
+		fields := make(map[string]string)
+		for i, column := range header {
+			if i < len(record) {
+				fields[column] = record[i]
+			}
+		}
+		examples = append(examples, Example{Index: len(examples) + 1, Fields: fields})
+	}
+
+	return examples, nil
+}
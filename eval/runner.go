@@ -0,0 +1,164 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"langforge/client"
+	"langforge/provider"
+	"os"
+	"sort"
+)
+
+// DefaultExpectedKey is the dataset field holding the expected output if none is configured.
+const DefaultExpectedKey = "expected"
+
+// Options configures an evaluation run.
+type Options struct {
+	Name        string
+	Chain       string
+	Dataset     string
+	ExpectedKey string
+	OutputKey   string
+	Scorers     []Scorer
+	// Threshold is the fraction of cases that must pass for the run to succeed.
+	Threshold float64
+	// Preset and Params are the preset and the generation parameters that
+	// the chain is invoked with, which the report records, see Settings.
+	Preset string
+	Params map[string]any
+}
+
+// CaseResult is the outcome of a single case of an evaluation run.
+type CaseResult struct {
+	Index    int                `json:"index"`
+	Inputs   map[string]string  `json:"inputs"`
+	Expected string             `json:"expected"`
+	Output   string             `json:"output"`
+	Scores   map[string]float64 `json:"scores"`
+	Passed   bool               `json:"passed"`
+	Error    string             `json:"error,omitempty"`
+	// ErrorCode identifies provider errors, see provider.Code.
+	ErrorCode provider.Code `json:"errorCode,omitempty"`
+}
+
+// Report summarizes an evaluation run.
+type Report struct {
+	Name       string             `json:"name,omitempty"`
+	Chain      string             `json:"chain"`
+	Dataset    string             `json:"dataset"`
+	Scorers    []string           `json:"scorers"`
+	Cases      []CaseResult       `json:"cases"`
+	MeanScores map[string]float64 `json:"meanScores"`
+	Passed     int                `json:"passed"`
+	Failed     int                `json:"failed"`
+	PassRate   float64            `json:"passRate"`
+	Threshold  float64            `json:"threshold"`
+	Success    bool               `json:"success"`
+	Settings   *Settings          `json:"settings,omitempty"`
+}
+
+// Run invokes the chain for each example through c and scores the outputs.
+// A case passes if every scorer gives it a score of at least 0.5.
+func Run(ctx context.Context, c *client.Client, examples []Example, options Options) *Report {
+	expectedKey := options.ExpectedKey
+	if expectedKey == "" {
+		expectedKey = DefaultExpectedKey
+	}
+
+	report := &Report{
+		Name:       options.Name,
+		Chain:      options.Chain,
+		Dataset:    options.Dataset,
+		Scorers:    []string{},
+		Cases:      []CaseResult{},
+		MeanScores: make(map[string]float64),
+		Threshold:  options.Threshold,
+		Settings:   &Settings{Preset: options.Preset, Params: options.Params},
+	}
+	for _, scorer := range options.Scorers {
+		report.Scorers = append(report.Scorers, scorer.Name())
+		if judge, ok := scorer.(*llmScorer); ok {
+			report.Settings.Judges = append(report.Settings.Judges, judge.judge())
+		}
+	}
+	models := map[string]bool{}
+
+	for _, example := range examples {
+		result := CaseResult{
+			Index:    example.Index,
+			Inputs:   make(map[string]string),
+			Expected: example.Fields[expectedKey],
+			Scores:   make(map[string]float64),
+		}
+
+		inputs := make(map[string]any)
+		for key, value := range example.Fields {
+			if key == expectedKey {
+				continue
+			}
+			inputs[key] = value
+			result.Inputs[key] = value
+		}
+
+		outputs, model, err := c.InvokeModel(ctx, options.Chain, inputs)
+		if model != "" && !models[model] {
+			models[model] = true
+			report.Settings.Models = append(report.Settings.Models, model)
+		}
+		if err == nil {
+			result.Output, err = client.OutputText(outputs, options.OutputKey)
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+			result.ErrorCode = provider.CodeOf(err)
+		} else {
+			result.Passed = true
+			evalCase := &Case{Inputs: result.Inputs, Expected: result.Expected, Output: result.Output}
+			for _, scorer := range options.Scorers {
+				score, err := scorer.Score(ctx, evalCase)
+				if err != nil {
+					result.Error = err.Error()
+					result.ErrorCode = provider.CodeOf(err)
+					result.Passed = false
+					continue
+				}
+				result.Scores[scorer.Name()] = score
+				if score < 0.5 {
+					result.Passed = false
+				}
+			}
+		}
+
+		for _, scorer := range options.Scorers {
+			report.MeanScores[scorer.Name()] += result.Scores[scorer.Name()]
+		}
+
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Cases = append(report.Cases, result)
+	}
+
+	if len(report.Cases) > 0 {
+		for name := range report.MeanScores {
+			report.MeanScores[name] /= float64(len(report.Cases))
+		}
+		report.PassRate = float64(report.Passed) / float64(len(report.Cases))
+	}
+	report.Success = len(report.Cases) > 0 && report.PassRate >= report.Threshold
+	sort.Strings(report.Settings.Models)
+
+	return report
+}
+
+// WriteJSON writes the report as JSON to path.
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
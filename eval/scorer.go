@@ -0,0 +1,229 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"langforge/project"
+	"langforge/provider"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Case is an example together with the output the chain produced for it.
+type Case struct {
+	Inputs   map[string]string
+	Expected string
+	Output   string
+}
+
+// Scorer assigns a score between 0 and 1 to the output of a case.
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, c *Case) (float64, error)
+}
+
+// NewScorers creates the scorers described by configs, see NewScorer. Scores
+// are keyed by the names of the scorers, so it refuses scorers with the same
+// name, e.g. two llm scorers without names.
+func NewScorers(configs []project.ScorerConfig, env map[string]string, seed *int) ([]Scorer, error) {
+	scorers := []Scorer{}
+	names := map[string]bool{}
+	for _, config := range configs {
+		scorer, err := NewScorer(config, env, seed)
+		if err != nil {
+			return nil, err
+		}
+		if names[scorer.Name()] {
+			return nil, fmt.Errorf("there are several scorers named %q, give them distinct names with name:", scorer.Name())
+		}
+		names[scorer.Name()] = true
+		scorers = append(scorers, scorer)
+	}
+	return scorers, nil
+}
+
+// NewScorer creates the scorer described by config. env is used to look up
+// provider API keys for LLM scorers, which judge with seed unless it is nil.
+func NewScorer(config project.ScorerConfig, env map[string]string, seed *int) (Scorer, error) {
+	name := config.Name
+	if name == "" {
+		name = config.Type
+	}
+	switch config.Type {
+	case "exact":
+		return &exactScorer{name: name, ignoreCase: config.IgnoreCase}, nil
+	case "contains":
+		return &containsScorer{name: name, ignoreCase: config.IgnoreCase}, nil
+	case "regex":
+		scorer := &regexScorer{name: name}
+		if config.Pattern != "" {
+			pattern := config.Pattern
+			if config.IgnoreCase {
+				pattern = "(?i)" + pattern
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex scorer pattern: %v", err)
+			}
+			scorer.pattern = re
+		}
+		scorer.ignoreCase = config.IgnoreCase
+		return scorer, nil
+	case "llm":
+		client, err := provider.New(config.Provider, env)
+		if err != nil {
+			return nil, err
+		}
+		return &llmScorer{name: name, client: client, model: config.Model, criteria: config.Criteria, seed: seed}, nil
+	default:
+		return nil, fmt.Errorf("unknown scorer type %q", config.Type)
+	}
+}
+
+type exactScorer struct {
+	name       string
+	ignoreCase bool
+}
+
+func (s *exactScorer) Name() string {
+	return s.name
+}
+
+func (s *exactScorer) Score(ctx context.Context, c *Case) (float64, error) {
+	output := strings.TrimSpace(c.Output)
+	expected := strings.TrimSpace(c.Expected)
+	if output == expected || (s.ignoreCase && strings.EqualFold(output, expected)) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+type containsScorer struct {
+	name       string
+	ignoreCase bool
+}
+
+func (s *containsScorer) Name() string {
+	return s.name
+}
+
+func (s *containsScorer) Score(ctx context.Context, c *Case) (float64, error) {
+	output := c.Output
+	expected := strings.TrimSpace(c.Expected)
+	if s.ignoreCase {
+		output = strings.ToLower(output)
+		expected = strings.ToLower(expected)
+	}
+	if strings.Contains(output, expected) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// regexScorer matches the output against a fixed pattern or, if no pattern is
+// configured, against the expected value interpreted as a regular expression.
+type regexScorer struct {
+	name       string
+	pattern    *regexp.Regexp
+	ignoreCase bool
+}
+
+func (s *regexScorer) Name() string {
+	return s.name
+}
+
+func (s *regexScorer) Score(ctx context.Context, c *Case) (float64, error) {
+	re := s.pattern
+	if re == nil {
+		pattern := strings.TrimSpace(c.Expected)
+		if s.ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return 0, fmt.Errorf("invalid expected pattern: %v", err)
+		}
+	}
+	if re.MatchString(c.Output) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+const judgePrompt = `You are grading the output of an AI system.
+
+Input:
+%s
+
+Reference answer:
+%s
+
+Output to grade:
+%s
+
+Criteria: %s
+
+Rate how well the output satisfies the criteria on a scale from 0 to 10.
+Respond with the number only.`
+
+var judgeScoreRegex = regexp.MustCompile(`\d+(\.\d+)?`)
+
+type llmScorer struct {
+	name     string
+	client   provider.Client
+	model    string
+	criteria string
+	seed     *int
+}
+
+func (s *llmScorer) Name() string {
+	return s.name
+}
+
+func (s *llmScorer) judge() Judge {
+	return Judge{Provider: s.client.Name(), Model: s.model, Temperature: 0, Seed: s.seed}
+}
+
+func (s *llmScorer) Score(ctx context.Context, c *Case) (float64, error) {
+	criteria := s.criteria
+	if criteria == "" {
+		criteria = "The output is correct and consistent with the reference answer."
+	}
+
+	inputs := []string{}
+	for key, value := range c.Inputs {
+		inputs = append(inputs, key+": "+value)
+	}
+	sort.Strings(inputs)
+
+	temperature := 0.0
+	answer, err := s.client.Chat(ctx, &provider.ChatRequest{
+		Model: s.model,
+		Messages: []provider.Message{
+			{Role: "user", Content: fmt.Sprintf(judgePrompt, strings.Join(inputs, "\n"), c.Expected, c.Output, criteria)},
+		},
+		Temperature: &temperature,
+		Seed:        s.seed,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	match := judgeScoreRegex.FindString(answer)
+	if match == "" {
+		return 0, fmt.Errorf("judge returned no score: %q", answer)
+	}
+
+	score, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, err
+	}
+	if score > 10 {
+		score = 10
+	}
+
+	return score / 10, nil
+}
@@ -0,0 +1,119 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Settings records what the outputs of a run were generated and scored with,
+// so that a run is only compared with a baseline as an equal if they match.
+type Settings struct {
+	// Preset is the preset that the chain was invoked with, if any.
+	Preset string `json:"preset,omitempty"`
+	// Params are the generation parameters that the chain was invoked with,
+	// those of the preset with the temperature and the seed of the
+	// evaluation. Parameters that are not set are those of the notebook.
+	Params map[string]any `json:"params,omitempty"`
+	// Models are the models that generated the outputs, as the gateway named
+	// them.
+	Models []string `json:"models,omitempty"`
+	// Judges are the LLMs of the llm scorers.
+	Judges []Judge `json:"judges,omitempty"`
+}
+
+// Judge is the LLM that an llm scorer asks for scores.
+type Judge struct {
+	Provider string `json:"provider"`
+	// Model is empty for the default model of the provider.
+	Model       string  `json:"model,omitempty"`
+	Temperature float64 `json:"temperature"`
+	Seed        *int    `json:"seed,omitempty"`
+}
+
+func (j Judge) String() string {
+	judge := j.Provider
+	if j.Model != "" {
+		judge += " " + j.Model
+	}
+	judge += fmt.Sprintf(" at temperature %g", j.Temperature)
+	if j.Seed != nil {
+		judge += fmt.Sprintf(" with seed %d", *j.Seed)
+	}
+	return judge
+}
+
+// settingsChanges describes how the settings of current differ from those of
+// baseline, e.g. "temperature 0.7 instead of 0". Reports without settings,
+// e.g. baselines of older versions, differ in nothing.
+func settingsChanges(baseline *Settings, current *Settings) []string {
+	if baseline == nil || current == nil {
+		return nil
+	}
+	changes := []string{}
+	if baseline.Preset != current.Preset {
+		changes = append(changes, fmt.Sprintf("preset %s instead of %s", orNone(current.Preset), orNone(baseline.Preset)))
+	}
+
+	keys := []string{}
+	for key := range baseline.Params {
+		keys = append(keys, key)
+	}
+	for key := range current.Params {
+		if _, ok := baseline.Params[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		before, after := paramText(baseline.Params, key), paramText(current.Params, key)
+		switch {
+		case before == after:
+		case before == "":
+			changes = append(changes, fmt.Sprintf("%s %s instead of the one of the notebook", key, after))
+		case after == "":
+			changes = append(changes, fmt.Sprintf("the %s of the notebook instead of %s", key, before))
+		default:
+			changes = append(changes, fmt.Sprintf("%s %s instead of %s", key, after, before))
+		}
+	}
+
+	// models are only named for the cases that ran
+	if len(baseline.Models) > 0 && len(current.Models) > 0 && strings.Join(baseline.Models, ", ") != strings.Join(current.Models, ", ") {
+		changes = append(changes, fmt.Sprintf("models %s instead of %s", strings.Join(current.Models, ", "), strings.Join(baseline.Models, ", ")))
+	}
+
+	judges := func(settings *Settings) string {
+		names := []string{}
+		for _, judge := range settings.Judges {
+			names = append(names, judge.String())
+		}
+		return orNone(strings.Join(names, ", "))
+	}
+	if before, after := judges(baseline), judges(current); before != after {
+		changes = append(changes, fmt.Sprintf("judges %s instead of %s", after, before))
+	}
+	return changes
+}
+
+// paramText returns the value of a generation parameter as JSON, or an empty
+// string if it is not set.
+func paramText(params map[string]any, key string) string {
+	value, ok := params[key]
+	if !ok {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func orNone(text string) string {
+	if text == "" {
+		return "none"
+	}
+	return text
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"langforge/analytics"
+	"langforge/provider"
+	"net/http"
+	"time"
+)
+
+// UsageHeader is the response header in which the worker reports the token
+// usage and cost of a chain invocation. The gateway removes it from responses
+// and prices the usage of models whose cost the worker does not know, such as
+// Anthropic and Gemini models, with the catalog of the provider package.
+const UsageHeader = "X-Langforge-Usage"
+
+// ModelHeader names the model of a chain response, if the worker reported
+// the usage of a single model, e.g. for evaluations to record the model
+// that their outputs were generated with.
+const ModelHeader = "X-Langforge-Model"
+
+// usage is the value of the usage header.
+type usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	// Model is the model that used the tokens, empty if the chain used
+	// several.
+	Model string `json:"model,omitempty"`
+}
+
+type usageKey struct{}
+
+// SetAnalytics records the metadata of all chain requests handled by the
+// gateway with the given writer. A nil writer disables analytics.
+func (g *Gateway) SetAnalytics(writer *analytics.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.analytics = writer
+}
+
+func (g *Gateway) currentAnalytics() *analytics.Writer {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.analytics
+}
+
+// measure serves a chain request and records its status, latency and usage,
+// and adds its cost to the spending of its API key.
+func (g *Gateway) measure(writer *analytics.Writer, w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	start := time.Now()
+	reported := &usage{}
+	r = r.WithContext(context.WithValue(r.Context(), usageKey{}, reported))
+
+	status := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	serve(status, r)
+
+	g.spend.add(requestKey(r), reported.Cost, time.Now())
+	writer.Record(analytics.Event{
+		Time:             start,
+		Chain:            chainName(r),
+		Variant:          requestVariant(r),
+		Status:           status.status,
+		LatencyMs:        time.Since(start).Milliseconds(),
+		PromptTokens:     reported.PromptTokens,
+		CompletionTokens: reported.CompletionTokens,
+		Cost:             reported.Cost,
+		Key:              requestKey(r),
+	})
+}
+
+// takeUsage removes the usage header from a worker response, names its model
+// in the model header and adds its value to the usage of the request being
+// measured, which is sent to the worker more than once if its output is
+// repaired.
+func takeUsage(resp *http.Response) {
+	header := resp.Header.Get(UsageHeader)
+	resp.Header.Del(UsageHeader)
+	if header == "" {
+		return
+	}
+	var invocation usage
+	if json.Unmarshal([]byte(header), &invocation) != nil {
+		return
+	}
+	if invocation.Model != "" {
+		resp.Header.Set(ModelHeader, invocation.Model)
+	}
+	reported, ok := resp.Request.Context().Value(usageKey{}).(*usage)
+	if !ok {
+		return
+	}
+	if invocation.Cost == 0 && invocation.Model != "" {
+		invocation.Cost, _ = provider.Cost(invocation.Model, invocation.PromptTokens, invocation.CompletionTokens)
+	}
+	if reported.PromptTokens+reported.CompletionTokens == 0 || reported.Model == invocation.Model {
+		reported.Model = invocation.Model
+	} else {
+		reported.Model = ""
+	}
+	reported.PromptTokens += invocation.PromptTokens
+	reported.CompletionTokens += invocation.CompletionTokens
+	reported.Cost += invocation.Cost
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes flushes through so that streamed responses are not buffered.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
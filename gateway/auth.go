@@ -0,0 +1,266 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"langforge/analytics"
+	"langforge/project"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKey is an API key that the gateway accepts, with the limits of a named
+// key.
+type apiKey struct {
+	// name identifies the client in analytics and rate limits, e.g. "team-a"
+	// or "key 2" for the second unnamed key, so that the key itself does not
+	// spread.
+	name string
+	key  string
+	// chains are the chains that the key may invoke, all if nil.
+	chains  map[string]bool
+	limiter *rateLimiter
+	budget  *project.KeyBudgetConfig
+	// admin keys may override the generation parameters of chains.
+	admin bool
+}
+
+// apiKeys expands the API keys of the auth configuration. It returns nil if
+// authentication is not configured and fails if none of the keys is set, so
+// that a missing environment variable does not open the gateway.
+func apiKeys(config *project.AuthConfig) ([]*apiKey, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	keys := []*apiKey{}
+	for _, key := range config.APIKeys {
+		if key = strings.TrimSpace(os.ExpandEnv(key)); key != "" {
+			keys = append(keys, &apiKey{name: fmt.Sprintf("key %d", len(keys)+1), key: key})
+		}
+	}
+
+	names := map[string]bool{}
+	for _, entry := range config.Keys {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("an API key of auth.keys in %s has no name", project.ConfigFileName)
+		}
+		if names[entry.Name] {
+			return nil, fmt.Errorf("the API key %s is listed twice in auth.keys of %s", entry.Name, project.ConfigFileName)
+		}
+		names[entry.Name] = true
+		if entry.RequestsPerMinute < 0 || entry.Burst < 0 {
+			return nil, fmt.Errorf("the rate limit of the API key %s must be a positive number", entry.Name)
+		}
+
+		key := &apiKey{name: entry.Name, key: strings.TrimSpace(os.ExpandEnv(entry.Key)), budget: entry.Budget, admin: entry.Admin}
+		if key.key == "" {
+			// like unnamed keys, keys whose variable is not set are left out
+			continue
+		}
+		if len(entry.Chains) > 0 {
+			key.chains = map[string]bool{}
+			for _, chain := range entry.Chains {
+				key.chains[chain] = true
+			}
+		}
+		if entry.RequestsPerMinute > 0 {
+			burst := entry.Burst
+			if burst == 0 {
+				burst = entry.RequestsPerMinute
+			}
+			key.limiter = newRateLimiter(entry.RequestsPerMinute, burst)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth is configured in %s but none of its API keys is set", project.ConfigFileName)
+	}
+	for i := range keys {
+		for _, other := range keys[:i] {
+			if keys[i].key == other.key {
+				return nil, fmt.Errorf("the API keys %s and %s of %s are the same", other.name, keys[i].name, project.ConfigFileName)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// authorize returns the API key of a request, sent either as a bearer token or
+// in the X-API-Key header, and removes the key from the request since it is
+// meant for the gateway, not for the chains. All requests are authorized,
+// without a key, if no keys are configured.
+func (g *Gateway) authorize(r *http.Request) (*apiKey, bool) {
+	key, ok := g.lookupKey(r)
+	r.Header.Del("Authorization")
+	r.Header.Del("X-API-Key")
+	return key, ok
+}
+
+// lookupKey returns the API key of a request like authorize, but leaves the
+// key in the request, e.g. to authenticate a request before its body is read.
+func (g *Gateway) lookupKey(r *http.Request) (*apiKey, bool) {
+	g.mu.RLock()
+	keys := g.apiKeys
+	g.mu.RUnlock()
+	if keys == nil {
+		return nil, true
+	}
+
+	token := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token == "" {
+		return nil, false
+	}
+
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key.key)) == 1 {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// deniesGeneration reports whether a request sends the generation header
+// without an admin key. Gateways without keys deny it to everyone, since they
+// may be reachable from anywhere, e.g. through a tunnel. Requests with an
+// invalid key are left to the auth middleware.
+func (g *Gateway) deniesGeneration(r *http.Request) bool {
+	if r.Header.Get(GenerationHeader) == "" {
+		return false
+	}
+	key, ok := g.lookupKey(r)
+	return ok && (key == nil || !key.admin)
+}
+
+// admit applies the limits of an API key to a request. It writes the error
+// response and returns false if the key may not invoke the chain of the
+// request, exceeds its rate limit or has used up its budget.
+func (g *Gateway) admit(w http.ResponseWriter, r *http.Request, key *apiKey) bool {
+	chain := chainName(r)
+	if chain != "" && key.chains != nil && !key.chains[chain] {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("the API key %s may not invoke the chain %s", key.name, chain))
+		return false
+	}
+	if key.limiter != nil {
+		if wait := key.limiter.take(key.name); wait > 0 {
+			writeRateLimited(w, wait)
+			return false
+		}
+	}
+	if chain != "" && key.budget != nil {
+		if period := g.spend.exceeded(key.name, key.budget, time.Now()); period != "" {
+			writeError(w, http.StatusTooManyRequests, fmt.Sprintf("the %s budget of the API key %s is used up", period, key.name))
+			return false
+		}
+	}
+	return true
+}
+
+// requestKey returns the name of the API key of a request, empty if the
+// gateway requires no key.
+func requestKey(r *http.Request) string {
+	name, _ := r.Context().Value(clientKey{}).(string)
+	return name
+}
+
+// LoadKeyCosts reads the cost that the API keys have spent in the current UTC
+// day and month from the analytics database, so that their budgets hold
+// across restarts. The gateway adds the cost of the requests it measures.
+func (g *Gateway) LoadKeyCosts(db *analytics.DB) error {
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	daily, err := db.KeyCosts(day)
+	if err != nil {
+		return err
+	}
+	monthly, err := db.KeyCosts(month)
+	if err != nil {
+		return err
+	}
+	g.spend.load(now, daily, monthly)
+	return nil
+}
+
+// HasKeyBudgets reports whether one of the API keys of config has a budget,
+// which is enforced with the costs recorded by analytics.
+func HasKeyBudgets(config *project.AuthConfig) bool {
+	if config == nil {
+		return false
+	}
+	for _, key := range config.Keys {
+		if key.Budget != nil && (key.Budget.Daily > 0 || key.Budget.Monthly > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// keySpend tracks the cost of the requests of each API key in the current UTC
+// day and month. It is kept across reloads of the configuration.
+type keySpend struct {
+	mu    sync.Mutex
+	day   string
+	month string
+	// daily and monthly are the costs by the name of the key.
+	daily   map[string]float64
+	monthly map[string]float64
+}
+
+func newKeySpend() *keySpend {
+	return &keySpend{daily: map[string]float64{}, monthly: map[string]float64{}}
+}
+
+// roll starts a new day or month. The caller holds the lock.
+func (s *keySpend) roll(now time.Time) {
+	now = now.UTC()
+	if day := now.Format("2006-01-02"); day != s.day {
+		s.day = day
+		s.daily = map[string]float64{}
+	}
+	if month := now.Format("2006-01"); month != s.month {
+		s.month = month
+		s.monthly = map[string]float64{}
+	}
+}
+
+func (s *keySpend) load(now time.Time, daily map[string]float64, monthly map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(now)
+	s.daily = daily
+	s.monthly = monthly
+}
+
+func (s *keySpend) add(name string, cost float64, now time.Time) {
+	if name == "" || cost == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(now)
+	s.daily[name] += cost
+	s.monthly[name] += cost
+}
+
+// exceeded returns "daily" or "monthly" if the key has spent a limit of its
+// budget, otherwise an empty string.
+func (s *keySpend) exceeded(name string, budget *project.KeyBudgetConfig, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(now)
+	switch {
+	case budget.Daily > 0 && s.daily[name] >= budget.Daily:
+		return "daily"
+	case budget.Monthly > 0 && s.monthly[name] >= budget.Monthly:
+		return "monthly"
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Backend is a worker the gateway proxies requests to. It counts the requests
+// in flight so that a replaced worker can be drained before it is stopped.
+type Backend struct {
+	Transport http.RoundTripper
+	proxy     *httputil.ReverseProxy
+	inflight  sync.WaitGroup
+}
+
+// Drain waits until all requests to the backend have completed or the timeout
+// expires. It reports whether the backend was drained.
+func (b *Backend) Drain(timeout time.Duration) bool {
+	drained := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// workerURL is the URL of proxied requests. Requests are sent to the worker
+// by the transport of the backend, so only the path is used.
+var workerURL = &url.URL{Scheme: "http", Host: "worker"}
+
+// SetBackend atomically routes all new requests to the worker reached through
+// transport and returns the previous backend, which still completes the
+// requests it is serving. The previous backend is nil for the first call.
+func (g *Gateway) SetBackend(transport http.RoundTripper) *Backend {
+	backend := &Backend{
+		Transport: transport,
+		proxy:     httputil.NewSingleHostReverseProxy(workerURL),
+	}
+	backend.proxy.Transport = transport
+	// pass streamed chain responses on as the worker produces them
+	backend.proxy.FlushInterval = -1
+	backend.proxy.ModifyResponse = g.filterResponse
+	backend.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, http.StatusBadGateway, "chain server is not available")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	previous := g.backend
+	g.backend = backend
+	return previous
+}
+
+// acquire returns the current backend and registers a request in flight,
+// which must be released with inflight.Done.
+func (g *Gateway) acquire() *Backend {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	// adding under the lock guarantees that no request is added to a backend
+	// after it has been replaced and is being drained
+	g.backend.inflight.Add(1)
+	return g.backend
+}
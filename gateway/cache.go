@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CacheHeader tells clients whether a chain response was served from the
+// response cache.
+const CacheHeader = "X-Langforge-Cache"
+
+// responseCache keeps the most recently used successful chain responses for
+// identical requests. Streamed responses are not cached.
+type responseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+}
+
+type cacheEntry struct {
+	key     string
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// requestHeaders are the response headers that describe the request rather
+// than the response. Hits keep those of their own request.
+var requestHeaders = map[string]bool{
+	RequestIDHeader:    true,
+	TraceparentHeader:  true,
+	CacheHeader:        true,
+	"Content-Length":   true,
+	"Content-Encoding": true,
+	"Vary":             true,
+}
+
+// newCacheMiddleware creates the response cache middleware. The options are
+// ttl, how long responses are kept, and maxEntries.
+func newCacheMiddleware(options map[string]string) (Middleware, error) {
+	ttl := 5 * time.Minute
+	if value := options["ttl"]; value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl %q: %v", value, err)
+		}
+	}
+	maxEntries, err := intOption(options, "maxEntries", 1000)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 || maxEntries <= 0 {
+		return nil, fmt.Errorf("ttl and maxEntries must be positive")
+	}
+
+	cache := &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+	return cache.middleware, nil
+}
+
+func (c *responseCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := chainName(r)
+		if name == "" || wantsStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// API keys do not share responses, which would spare their budgets
+		// and reveal the inputs of other clients
+		sum := sha256.Sum256(append([]byte(name+"\x00"+requestKey(r)+"\x00"+requestVariant(r)+"\x00"+requestPreset(r)+"\x00"+r.Header.Get(GenerationHeader)+"\x00"), body...))
+		key := string(sum[:])
+		if entry := c.get(key); entry != nil {
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(entry.body)))
+			w.Header().Set(CacheHeader, "hit")
+			w.Write(entry.body)
+			return
+		}
+
+		w.Header().Set(CacheHeader, "miss")
+		capturing := &capturingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(capturing, r)
+		if capturing.status == http.StatusOK && !capturing.truncated {
+			header := http.Header{}
+			for name, values := range w.Header() {
+				if !requestHeaders[name] {
+					header[name] = append([]string(nil), values...)
+				}
+			}
+			c.put(&cacheEntry{
+				key:     key,
+				header:  header,
+				body:    capturing.body.Bytes(),
+				expires: time.Now().Add(c.ttl),
+			})
+		}
+	})
+}
+
+func (c *responseCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.order.MoveToFront(element)
+	return entry
+}
+
+func (c *responseCache) put(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		c.order.Remove(element)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"langforge/project"
+	"math/rand"
+	"net/http"
+)
+
+// VariantHeader tells clients which version of a chain with a canary served
+// their request. Clients may also send it to request a version.
+const VariantHeader = "X-Langforge-Variant"
+
+// Versions of a chain with a canary.
+const (
+	VariantPrimary = "primary"
+	VariantCanary  = "canary"
+)
+
+// canary routes a share of the requests of a chain to an alternate chain or
+// to the same chain with other environment variables.
+type canary struct {
+	percent float64
+	chain   string
+	env     string
+}
+
+type variantKey struct{}
+
+// newCanary validates the canary of a chain and encodes its environment,
+// which is the environment of the chain with the variables of the canary.
+func newCanary(chain project.ChainConfig) (*canary, error) {
+	config := chain.Canary
+	if config.Percent < 0 || config.Percent > 100 {
+		return nil, fmt.Errorf("the canary of chain %s must receive between 0 and 100 percent of the requests", chain.Name)
+	}
+	if config.Chain == "" && len(config.Env) == 0 {
+		return nil, fmt.Errorf("the canary of chain %s needs an alternate chain or env variables", chain.Name)
+	}
+
+	env := map[string]string{}
+	for key, value := range chain.Env {
+		env[key] = value
+	}
+	for key, value := range config.Env {
+		env[key] = value
+	}
+	encoded, err := EncodeEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	return &canary{percent: config.Percent, chain: config.Chain, env: encoded}, nil
+}
+
+func (g *Gateway) chainCanary(name string) *canary {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.canaries[name]
+}
+
+// assignVariant picks the version of a chain with a canary that serves a
+// request, unless the client asked for one, and reports it in the response.
+func (g *Gateway) assignVariant(w http.ResponseWriter, r *http.Request) *http.Request {
+	name := chainName(r)
+	if name == "" {
+		return r
+	}
+	c := g.chainCanary(name)
+	if c == nil {
+		return r
+	}
+
+	variant := r.Header.Get(VariantHeader)
+	r.Header.Del(VariantHeader)
+	if variant != VariantPrimary && variant != VariantCanary {
+		variant = VariantPrimary
+		if rand.Float64()*100 < c.percent {
+			variant = VariantCanary
+		}
+	}
+	w.Header().Set(VariantHeader, variant)
+	return r.WithContext(context.WithValue(r.Context(), variantKey{}, variant))
+}
+
+// requestVariant returns the version of the chain serving a request, or an
+// empty string if the chain has no canary.
+func requestVariant(r *http.Request) string {
+	variant, _ := r.Context().Value(variantKey{}).(string)
+	return variant
+}
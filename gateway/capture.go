@@ -0,0 +1,214 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCaptureBody is the number of bytes of a request or response body that is
+// recorded. Longer bodies are truncated and cannot be replayed.
+const maxCaptureBody = 1 << 20
+
+// sensitiveHeaders are never written to a replay file.
+var sensitiveHeaders = []string{"authorization", "proxy-authorization", "cookie", "set-cookie"}
+
+// hopHeaders are recomputed when a request is replayed.
+var hopHeaders = []string{"content-length", "accept-encoding", "connection"}
+
+// Record is a captured chain request and the response of the gateway.
+type Record struct {
+	Time      time.Time         `json:"time"`
+	Chain     string            `json:"chain"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Header    map[string]string `json:"header,omitempty"`
+	Request   json.RawMessage   `json:"request"`
+	Status    int               `json:"status"`
+	Response  json.RawMessage   `json:"response"`
+	Duration  time.Duration     `json:"duration"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+// Recorder appends sanitized records of chain requests to a replay file.
+// Credentials are removed from the headers and PII is redacted from the
+// bodies, so that replay files can be shared to reproduce issues.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder opens the replay file at path for appending.
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Close closes the replay file.
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// Write appends a record to the replay file.
+func (r *Recorder) Write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+// LoadRecords reads all records of a replay file.
+func LoadRecords(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []*Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*maxCaptureBody)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(line, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// SetRecorder captures all chain requests handled by the gateway with the
+// given recorder. A nil recorder disables capturing.
+func (g *Gateway) SetRecorder(recorder *Recorder) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.recorder = recorder
+}
+
+func (g *Gateway) currentRecorder() *Recorder {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.recorder
+}
+
+// capture serves a chain request and records it afterwards.
+func (g *Gateway) capture(recorder *Recorder, w http.ResponseWriter, r *http.Request, body []byte, serve func(http.ResponseWriter)) {
+	record := &Record{
+		Time:   time.Now().UTC(),
+		Chain:  chainName(r),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: sanitizeHeader(r.Header),
+	}
+	record.Request, record.Truncated = sanitizeBody(body)
+
+	capturing := &capturingWriter{ResponseWriter: w, status: http.StatusOK}
+	serve(capturing)
+
+	record.Duration = time.Since(record.Time)
+	record.Status = capturing.status
+	var truncated bool
+	record.Response, truncated = sanitizeBody(capturing.body.Bytes())
+	record.Truncated = record.Truncated || truncated || capturing.truncated
+
+	if err := recorder.Write(record); err != nil {
+		// capturing must never break serving requests
+		fmt.Println("Error capturing request:", err)
+	}
+}
+
+// sanitizeHeader returns the request headers without credentials.
+func sanitizeHeader(header http.Header) map[string]string {
+	sanitized := map[string]string{}
+	for key, values := range header {
+		lower := strings.ToLower(key)
+		skip := strings.Contains(lower, "token") || strings.Contains(lower, "secret") || strings.Contains(lower, "key")
+		for _, name := range sensitiveHeaders {
+			if lower == name {
+				skip = true
+			}
+		}
+		for _, name := range hopHeaders {
+			if lower == name {
+				skip = true
+			}
+		}
+		if skip {
+			continue
+		}
+		sanitized[key] = strings.Join(values, ", ")
+	}
+	return sanitized
+}
+
+// sanitizeBody redacts PII from a JSON object body. Any other body is
+// recorded as a string.
+func sanitizeBody(body []byte) (json.RawMessage, bool) {
+	truncated := false
+	if len(body) > maxCaptureBody {
+		body = body[:maxCaptureBody]
+		truncated = true
+	}
+
+	var values map[string]any
+	if !truncated && json.Unmarshal(body, &values) == nil {
+		eachString(values, RedactPII)
+		if data, err := json.Marshal(values); err == nil {
+			return data, false
+		}
+	}
+
+	data, _ := json.Marshal(RedactPII(string(body)))
+	return data, truncated
+}
+
+// capturingWriter keeps a copy of the response while writing it to the client.
+type capturingWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *capturingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	if remaining := maxCaptureBody + 1 - w.body.Len(); remaining > 0 {
+		if len(data) > remaining {
+			w.body.Write(data[:remaining])
+			w.truncated = true
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush passes flushes through so that streamed responses are not buffered.
+func (w *capturingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"compress/gzip"
+	"fmt"
+	"langforge/project"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMinCompressSize is the size below which responses are not
+// compressed, since compression would barely make them smaller.
+const defaultMinCompressSize = 1024
+
+// compressor compresses the responses of the gateway with gzip.
+type compressor struct {
+	level   int
+	minSize int
+}
+
+func newCompressor(config *project.CompressionConfig) (*compressor, error) {
+	if config == nil {
+		return nil, nil
+	}
+	c := &compressor{level: gzip.DefaultCompression, minSize: defaultMinCompressSize}
+	if config.Level != 0 {
+		if config.Level < gzip.BestSpeed || config.Level > gzip.BestCompression {
+			return nil, fmt.Errorf("the compression level must be between 1 and 9, got %d", config.Level)
+		}
+		c.level = config.Level
+	}
+	if config.MinSize < 0 {
+		return nil, fmt.Errorf("the minimum size of compressed responses must not be negative")
+	}
+	if config.MinSize > 0 {
+		c.minSize = config.MinSize
+	}
+	return c, nil
+}
+
+// wrap returns a writer that compresses the response to r if the client
+// accepts gzip and the response is not streamed, and a function that
+// completes the response.
+func (c *compressor) wrap(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if r.Method == http.MethodHead || wantsStream(r) {
+		return w, func() {}
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		return w, func() {}
+	}
+	cw := &compressWriter{ResponseWriter: w, compressor: c}
+	return cw, cw.close
+}
+
+// acceptsGzip reports whether an Accept-Encoding header accepts gzip, e.g.
+// "gzip, deflate, br" or "*;q=0.5".
+func acceptsGzip(header string) bool {
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				q = parsed
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// response is compressed: when minSize bytes were written, or the response is
+// complete.
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *compressor
+	status     int
+	buffer     []byte
+	decided    bool
+	gzip       *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	header := w.Header()
+	compressible := status != http.StatusNoContent && status != http.StatusNotModified && status >= http.StatusOK &&
+		header.Get("Content-Encoding") == "" &&
+		!strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+	if !compressible {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.gzip != nil {
+			return w.gzip.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buffer = append(w.buffer, p...)
+	if len(w.buffer) >= w.compressor.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush passes flushes through once the response is known to be compressed
+// or not. Until then, flushes are held back: the proxy flushes every write of
+// the worker, and responses that are not streamed are complete soon.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		return
+	}
+	if w.gzip != nil {
+		w.gzip.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// decide writes the header of the response, compressed if compress is set,
+// and the buffered start of the body.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.compressor.level)
+		if err != nil {
+			return err
+		}
+		w.gzip = gz
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	var err error
+	if w.gzip != nil {
+		_, err = w.gzip.Write(buffer)
+	} else {
+		_, err = w.ResponseWriter.Write(buffer)
+	}
+	return err
+}
+
+// close completes the response: a response shorter than minSize is written
+// uncompressed.
+func (w *compressWriter) close() {
+	switch {
+	case w.status == 0:
+		// nothing was written, net/http answers with an empty 200
+	case !w.decided:
+		w.decide(false)
+	case w.gzip != nil:
+		w.gzip.Close()
+	}
+}
@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/provider"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// normalizeErrors gives the provider errors in the error responses and error
+// events of the worker a stable code and answers them with the status of the
+// code, so that clients do not depend on the messages of provider SDKs.
+func normalizeErrors(resp *http.Response) error {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = normalizeEvents(resp.Body)
+		return nil
+	}
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var failure provider.ErrorBody
+	if err := json.Unmarshal(body, &failure); err == nil && failure.Error != "" {
+		failure.Normalize()
+		if failure.Code != "" {
+			resp.StatusCode = failure.Code.Status()
+			resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		body, err = json.Marshal(&failure)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// normalizeEvents returns the server-sent events of body with the error
+// events normalized. Other events are passed on line by line as they arrive.
+func normalizeEvents(body io.ReadCloser) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		defer body.Close()
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		event := ""
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case line == "":
+				event = ""
+			case event == "error" && strings.HasPrefix(line, "data:"):
+				var failure provider.ErrorBody
+				data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+				if json.Unmarshal([]byte(data), &failure) == nil {
+					failure.Normalize()
+					if normalized, err := json.Marshal(&failure); err == nil {
+						line = "data: " + string(normalized)
+					}
+				}
+			}
+			if _, err := io.WriteString(writer, line+"\n"); err != nil {
+				return
+			}
+		}
+		writer.CloseWithError(scanner.Err())
+	}()
+	return reader
+}
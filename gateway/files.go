@@ -0,0 +1,433 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"langforge/models"
+	"langforge/project"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FilesPath is the path of the uploads, see project.FilesConfig.
+const FilesPath = "/files"
+
+const (
+	defaultMaxFileSize = 10 << 20
+	defaultFileTTL     = time.Hour
+)
+
+// defaultFileTypes are the extensions of the documents that the loaders of
+// the ingestion pipeline read.
+var defaultFileTypes = []string{"pdf", "txt", "md", "html", "htm", "csv", "json", "docx", "pptx", "xlsx"}
+
+// fileIDPattern matches the IDs of uploads, which chain inputs refer to.
+var fileIDPattern = regexp.MustCompile(`^file-[0-9a-f]{24}$`)
+
+// IngestFunc ingests an uploaded file at path into the vector store of the
+// project, with the ID of the upload in the metadata of its chunks, and
+// returns the number of chunks, 0 if it is not known.
+type IngestFunc func(ctx context.Context, path string, id string) (int, error)
+
+// SetIngester sets the function that POST /files/<id>/ingest ingests uploads
+// with, nil if the project has no vector store.
+func (g *Gateway) SetIngester(ingest IngestFunc) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ingest = ingest
+}
+
+// fileSettings are the limits of uploads.
+type fileSettings struct {
+	maxSize int64
+	types   map[string]bool
+	ttl     time.Duration
+}
+
+func newFileSettings(config *project.FilesConfig) (*fileSettings, error) {
+	if config == nil {
+		return nil, nil
+	}
+	s := &fileSettings{maxSize: defaultMaxFileSize, types: map[string]bool{}, ttl: defaultFileTTL}
+	if config.MaxSize != "" {
+		size, err := models.ParseSize(config.MaxSize)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid gateway.files.maxSize %q, use e.g. 10MB", config.MaxSize)
+		}
+		s.maxSize = size
+	}
+	if config.TTL != "" {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid gateway.files.ttl %q, use e.g. 1h", config.TTL)
+		}
+		s.ttl = ttl
+	}
+	types := config.Types
+	if len(types) == 0 {
+		types = defaultFileTypes
+	}
+	for _, extension := range types {
+		s.types[strings.ToLower(strings.TrimPrefix(extension, "."))] = true
+	}
+	return s, nil
+}
+
+// typeList returns the accepted extensions for error messages.
+func (s *fileSettings) typeList() string {
+	types := []string{}
+	for extension := range s.types {
+		types = append(types, extension)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// uploadedFile describes an upload in the responses of the endpoints.
+type uploadedFile struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	path      string
+	expiry    *time.Timer
+	// owner is the name of the API key that uploaded the file, empty if the
+	// gateway requires no key. Other keys cannot see the upload.
+	owner string
+}
+
+// fileStore keeps the uploads in a temporary directory until they expire.
+// Unlike the settings of the gateway, it is not replaced when they are
+// reloaded, so that uploads keep their IDs.
+type fileStore struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]*uploadedFile
+	// ingested are the IDs of the uploads that are ingested or being
+	// ingested.
+	ingested map[string]bool
+}
+
+func newFileStore() *fileStore {
+	return &fileStore{files: map[string]*uploadedFile{}, ingested: map[string]bool{}}
+}
+
+// add writes an upload of owner to the store. It fails if the upload is
+// larger than the maximum size of settings.
+func (s *fileStore) add(name string, owner string, content io.Reader, settings *fileSettings) (*uploadedFile, error) {
+	id, err := newFileID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.dir == "" {
+		s.dir, err = os.MkdirTemp("", "langforge-uploads-")
+	}
+	dir := s.dir
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// the file keeps its name, so that loaders recognize its type
+	fileDir := filepath.Join(dir, id)
+	if err := os.Mkdir(fileDir, 0700); err != nil {
+		return nil, err
+	}
+	file := &uploadedFile{ID: id, Name: name, path: filepath.Join(fileDir, name), owner: owner}
+	file.Type = mime.TypeByExtension(path.Ext(name))
+	if mediaType, _, err := mime.ParseMediaType(file.Type); err == nil {
+		file.Type = mediaType
+	} else {
+		file.Type = "application/octet-stream"
+	}
+
+	out, err := os.OpenFile(file.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err == nil {
+		file.Size, err = io.Copy(out, io.LimitReader(content, settings.maxSize+1))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil && file.Size > settings.maxSize {
+		err = errFileTooLarge
+	}
+	if err != nil {
+		os.RemoveAll(fileDir)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file.ExpiresAt = time.Now().Add(settings.ttl).UTC().Truncate(time.Second)
+	file.expiry = time.AfterFunc(settings.ttl, func() { s.remove(id) })
+	s.files[id] = file
+	return file, nil
+}
+
+var errFileTooLarge = errors.New("the file is too large")
+
+func newFileID() (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "file-" + hex.EncodeToString(id), nil
+}
+
+// get returns the upload with an ID, or nil if it does not exist, expired or
+// belongs to another owner.
+func (s *fileStore) get(id string, owner string) *uploadedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file := s.files[id]
+	if file == nil || file.owner != owner {
+		return nil
+	}
+	return file
+}
+
+// startIngest marks an upload as ingested and reports whether it was not
+// before, so that its chunks are only added to the vector store once.
+func (s *fileStore) startIngest(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ingested[id] {
+		return false
+	}
+	s.ingested[id] = true
+	return true
+}
+
+// failIngest allows the ingestion of an upload to be repeated after it failed.
+func (s *fileStore) failIngest(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ingested, id)
+}
+
+// remove deletes an upload and reports whether it existed.
+func (s *fileStore) remove(id string) bool {
+	s.mu.Lock()
+	file, ok := s.files[id]
+	delete(s.files, id)
+	delete(s.ingested, id)
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	file.expiry.Stop()
+	os.RemoveAll(filepath.Dir(file.path))
+	return true
+}
+
+// close deletes all uploads.
+func (s *fileStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, file := range s.files {
+		file.expiry.Stop()
+		delete(s.files, id)
+		delete(s.ingested, id)
+	}
+	if s.dir == "" {
+		return nil
+	}
+	dir := s.dir
+	s.dir = ""
+	return os.RemoveAll(dir)
+}
+
+// Close deletes the uploads of the gateway.
+func (g *Gateway) Close() error {
+	return g.files.close()
+}
+
+// serveFiles serves the uploads:
+//
+//	POST   /files             uploads the file of the multipart field "file"
+//	GET    /files/<id>        describes an upload
+//	DELETE /files/<id>        deletes an upload
+//	POST   /files/<id>/ingest ingests an upload into the vector store once
+//
+// Uploads are only found with the API key that uploaded them.
+func (g *Gateway) serveFiles(w http.ResponseWriter, r *http.Request, settings *fileSettings) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, FilesPath), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		g.uploadFile(w, r, settings)
+		return
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "upload files with POST "+FilesPath)
+		return
+	}
+
+	file := g.files.get(id, requestKey(r))
+	if file == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("file %s does not exist or has expired", id))
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeFile(w, http.StatusOK, file)
+	case action == "" && r.Method == http.MethodDelete:
+		g.files.remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	case action == "ingest" && r.Method == http.MethodPost:
+		g.ingestFile(w, r, file)
+	case action == "" || action == "ingest":
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown path %s", r.URL.Path))
+	}
+}
+
+func (g *Gateway) uploadFile(w http.ResponseWriter, r *http.Request, settings *fileSettings) {
+	// the limit leaves room for the other parts of the form
+	r.Body = http.MaxBytesReader(w, r.Body, settings.maxSize+1<<20)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "upload the file as the field \"file\" of a multipart/form-data request")
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			writeError(w, http.StatusBadRequest, "the request has no field \"file\"")
+			return
+		}
+		if err != nil {
+			writeUploadError(w, err, settings)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		name := path.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+		extension := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+		if name == "." || name == "/" || extension == "" {
+			writeError(w, http.StatusBadRequest, "the file needs a name with an extension")
+			return
+		}
+		if !settings.types[extension] {
+			writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("files of type %s are not accepted, upload one of %s", extension, settings.typeList()))
+			return
+		}
+
+		file, err := g.files.add(name, requestKey(r), part, settings)
+		if err != nil {
+			writeUploadError(w, err, settings)
+			return
+		}
+		fmt.Printf("Uploaded %s as %s (request %s)\n", name, file.ID, requestID(r))
+		writeFile(w, http.StatusCreated, file)
+		return
+	}
+}
+
+func writeUploadError(w http.ResponseWriter, err error, settings *fileSettings) {
+	var maxBytes *http.MaxBytesError
+	if errors.Is(err, errFileTooLarge) || errors.As(err, &maxBytes) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the file exceeds the maximum size of %s", models.FormatSize(settings.maxSize)))
+		return
+	}
+	writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to read the upload: %v", err))
+}
+
+func (g *Gateway) ingestFile(w http.ResponseWriter, r *http.Request, file *uploadedFile) {
+	g.mu.RLock()
+	ingest := g.ingest
+	g.mu.RUnlock()
+	if ingest == nil {
+		writeError(w, http.StatusNotImplemented, "the project has no vector store to ingest files into")
+		return
+	}
+	if !g.files.startIngest(file.ID) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("file %s is already ingested", file.ID))
+		return
+	}
+
+	chunks, err := ingest(r.Context(), file.path, file.ID)
+	if err != nil {
+		g.files.failIngest(file.ID)
+		fmt.Printf("Error ingesting %s (request %s): %v\n", file.ID, requestID(r), err)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("failed to ingest %s: %v", file.ID, err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": file.ID, "chunks": chunks})
+}
+
+func writeFile(w http.ResponseWriter, status int, file *uploadedFile) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(file)
+}
+
+// resolveFiles replaces the IDs of the uploads of owner in the inputs of a
+// chain, also in lists and objects, with the paths of the files and reports
+// whether it replaced any.
+func (g *Gateway) resolveFiles(inputs map[string]any, owner string) (bool, error) {
+	resolved := false
+	for key, value := range inputs {
+		path, ok, err := g.resolveFile(value, owner)
+		if err != nil {
+			return false, fmt.Errorf("input %s: %v", key, err)
+		}
+		if ok {
+			inputs[key] = path
+			resolved = true
+		}
+	}
+	return resolved, nil
+}
+
+// resolveFile returns the path of the upload that value refers to, or
+// resolves the uploads of a list or object in place.
+func (g *Gateway) resolveFile(value any, owner string) (any, bool, error) {
+	switch v := value.(type) {
+	case string:
+		if !fileIDPattern.MatchString(v) {
+			return nil, false, nil
+		}
+		file := g.files.get(v, owner)
+		if file == nil {
+			return nil, false, fmt.Errorf("file %s does not exist or has expired", v)
+		}
+		return file.path, true, nil
+	case []any:
+		resolved := false
+		for i, el := range v {
+			path, ok, err := g.resolveFile(el, owner)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				v[i] = path
+				resolved = true
+			}
+		}
+		return v, resolved, nil
+	case map[string]any:
+		resolved, err := g.resolveFiles(v, owner)
+		return v, resolved, err
+	}
+	return nil, false, nil
+}
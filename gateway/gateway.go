@@ -0,0 +1,523 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"langforge/analytics"
+	"langforge/models"
+	"langforge/project"
+	"langforge/schema"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvHeader carries the environment variables of a chain to the worker as
+// base64 encoded JSON. It is removed from all incoming requests.
+const EnvHeader = "X-Langforge-Env"
+
+// Gateway is the HTTP server in front of the Python worker. It validates chain
+// requests against the extracted schemas, applies the guardrails of each chain
+// and proxies requests to the worker.
+type Gateway struct {
+	mu         sync.RWMutex
+	backend    *Backend
+	guardrails map[string]*Guardrail
+	envs       map[string]string
+	canaries   map[string]*canary
+	// outputs are the structured outputs of the chains that declare one.
+	outputs map[string]*outputContract
+	// transforms post-process the outputs of the chains that declare them.
+	transforms map[string]*transformPipeline
+	// presets are the encoded parameters of the presets by name, and
+	// chainPresets the presets of the chains that declare one.
+	presets      map[string]string
+	chainPresets map[string]string
+	title        string
+	version      string
+	schemas      *schema.Schemas
+	recorder     *Recorder
+	analytics    *analytics.Writer
+	apiKeys      []*apiKey
+	spend        *keySpend
+	handler      http.Handler
+	compressor   *compressor
+	// openAI serves chains in the wire format of OpenAI, nil if it is off.
+	openAI *openAIFacade
+	// fileSettings are the limits of uploads, nil if they are off.
+	fileSettings *fileSettings
+	images       *imageSettings
+	files        *fileStore
+	ingest       IngestFunc
+	// readinessChecks are checked by the readiness probe besides the worker.
+	readinessChecks []ReadinessCheck
+}
+
+// New creates a gateway that forwards requests to the worker reached through
+// backend and applies the guardrails declared in the project configuration.
+func New(backend http.RoundTripper, config *project.Config) (*Gateway, error) {
+	g := &Gateway{spend: newKeySpend(), files: newFileStore()}
+	if err := g.Reload(config); err != nil {
+		return nil, err
+	}
+	g.SetBackend(backend)
+	return g, nil
+}
+
+// Reload applies the API keys, the middlewares, the compression of responses,
+// the OpenAI facade, the limits of uploads and images, the presets and the
+// guardrails, environment variables, structured outputs and transforms of the
+// chains in config to all subsequent requests.
+func (g *Gateway) Reload(config *project.Config) error {
+	guardrails := map[string]*Guardrail{}
+	envs := map[string]string{}
+	canaries := map[string]*canary{}
+	outputs := map[string]*outputContract{}
+	transforms := map[string]*transformPipeline{}
+	chainPresets := map[string]string{}
+
+	presets, err := encodePresets(config)
+	if err != nil {
+		return err
+	}
+
+	for _, chain := range config.Chains {
+		if chain.Preset != "" {
+			chainPresets[chain.Name] = chain.Preset
+		}
+
+		if len(chain.Env) > 0 {
+			env, err := EncodeEnv(chain.Env)
+			if err != nil {
+				return err
+			}
+			envs[chain.Name] = env
+		}
+
+		if chain.Canary != nil {
+			c, err := newCanary(chain)
+			if err != nil {
+				return err
+			}
+			canaries[chain.Name] = c
+		}
+
+		if chain.Output != nil {
+			contract, err := newOutputContract(chain)
+			if err != nil {
+				return err
+			}
+			outputs[chain.Name] = contract
+		}
+
+		if len(chain.Transforms) > 0 {
+			pipeline, err := newTransformPipeline(chain)
+			if err != nil {
+				return err
+			}
+			transforms[chain.Name] = pipeline
+		}
+
+		if chain.Guardrails == nil {
+			continue
+		}
+		guardrail, err := NewGuardrail(chain.Name, chain.Guardrails)
+		if err != nil {
+			return err
+		}
+		guardrails[chain.Name] = guardrail
+	}
+
+	keys, err := apiKeys(config.Auth)
+	if err != nil {
+		return err
+	}
+
+	handler, err := g.buildHandler(config.Gateway.Middleware)
+	if err != nil {
+		return err
+	}
+	compressor, err := newCompressor(config.Gateway.Server.Compression)
+	if err != nil {
+		return err
+	}
+	fileSettings, err := newFileSettings(config.Gateway.Files)
+	if err != nil {
+		return err
+	}
+	images, err := newImageSettings(config.Gateway.Images)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.guardrails = guardrails
+	g.envs = envs
+	g.canaries = canaries
+	g.outputs = outputs
+	g.transforms = transforms
+	g.presets = presets
+	g.chainPresets = chainPresets
+	g.apiKeys = keys
+	g.handler = handler
+	g.compressor = compressor
+	g.openAI = newOpenAIFacade(config.Gateway.OpenAI)
+	g.fileSettings = fileSettings
+	g.images = images
+	g.title = config.Name
+	g.version = config.Version
+	return nil
+}
+
+// EncodeEnv expands the environment variables of a chain with the environment
+// of the gateway and encodes them for the env header.
+func EncodeEnv(env map[string]string) (string, error) {
+	expanded := map[string]string{}
+	for key, value := range env {
+		expanded[key] = os.ExpandEnv(value)
+	}
+	data, err := json.Marshal(expanded)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// chainSettings returns the guardrail and the encoded environment of a chain.
+func (g *Gateway) chainSettings(name string) (*Guardrail, string) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.guardrails[name], g.envs[name]
+}
+
+// SetSchemas sets the chain schemas that requests are validated against and
+// that the OpenAPI document at /openapi.json is generated from.
+func (g *Gateway) SetSchemas(schemas *schema.Schemas) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.schemas = schemas
+}
+
+func (g *Gateway) chainSchema(name string) *schema.Chain {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.schemas == nil {
+		return nil
+	}
+	return g.schemas.Find(name)
+}
+
+func (g *Gateway) serveOpenAPI(w http.ResponseWriter) {
+	g.mu.RLock()
+	schemas, title, version := g.schemas, g.title, g.version
+	g.mu.RUnlock()
+	if schemas == nil {
+		writeError(w, http.StatusServiceUnavailable, "chain schemas are not available yet")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schema.OpenAPI(title, version, schemas))
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.RLock()
+	compressor := g.compressor
+	g.mu.RUnlock()
+	if compressor != nil {
+		var complete func()
+		w, complete = compressor.wrap(w, r)
+		defer complete()
+	}
+
+	// only the gateway may set the environment and parameters of a chain
+	r.Header.Del(EnvHeader)
+	r.Header.Del(ParamsHeader)
+
+	// probes bypass the middlewares, so that they need no API key and are
+	// neither logged nor counted
+	if r.Method == http.MethodGet && (r.URL.Path == LivenessPath || r.URL.Path == ReadinessPath) {
+		g.serveProbe(w, r)
+		return
+	}
+
+	r = startTrace(w, r)
+	// clients learn that their evaluation is not reproducible instead of
+	// recording a temperature and a seed that were not applied
+	if g.deniesGeneration(r) {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("only admin API keys may send the %s header, see auth.keys in %s", GenerationHeader, project.ConfigFileName))
+		return
+	}
+	g.mu.RLock()
+	handler := g.handler
+	facade := g.openAI
+	images := g.images
+	g.mu.RUnlock()
+	// inputs are read into memory as a whole, uploads of files have a limit
+	// of their own
+	if chainName(r) != "" || r.URL.Path == OpenAIChatPath {
+		r.Body = http.MaxBytesReader(w, r.Body, images.maxBodySize())
+	}
+	if facade != nil && r.Method == http.MethodPost && r.URL.Path == OpenAIChatPath {
+		g.serveOpenAIChat(w, r, facade, handler)
+		return
+	}
+	handler.ServeHTTP(w, g.assignPreset(w, g.assignVariant(w, r)))
+}
+
+// serve is the innermost handler of the gateway, which the middlewares wrap.
+func (g *Gateway) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/openapi.json" {
+		g.serveOpenAPI(w)
+		return
+	}
+	g.mu.RLock()
+	facade := g.openAI
+	fileSettings := g.fileSettings
+	g.mu.RUnlock()
+	if facade != nil && r.Method == http.MethodGet && r.URL.Path == OpenAIModelsPath {
+		g.serveOpenAIModels(w, r, facade)
+		return
+	}
+	if fileSettings != nil && (r.URL.Path == FilesPath || strings.HasPrefix(r.URL.Path, FilesPath+"/")) {
+		g.serveFiles(w, r, fileSettings)
+		return
+	}
+
+	backend := g.acquire()
+	defer backend.inflight.Done()
+	g.serveChain(w, r, chainName(r), backend)
+}
+
+// serveChain validates a request against the schema of its chain and proxies
+// it to the worker, routing requests assigned to a canary to its version of
+// the chain, checking image inputs, passing uploads to inputs that refer to
+// them, validating the outputs of chains with a structured output and
+// transforming the outputs of chains with transforms. Requests that do not
+// address a chain are proxied unchanged.
+func (g *Gateway) serveChain(w http.ResponseWriter, r *http.Request, name string, backend *Backend) {
+	_, env := g.chainSettings(name)
+	// the chain that serves the request, whose schema, contract and
+	// transforms apply
+	served := name
+	// a reload may have removed the canary since the variant was assigned,
+	// the primary chain serves the request then
+	if c := g.chainCanary(name); c != nil && requestVariant(r) == VariantCanary {
+		env = c.env
+		if c.chain != "" {
+			// analytics and capture record the requested chain
+			r = r.Clone(r.Context())
+			r.URL.Path = "/chat/" + c.chain
+			r.URL.RawPath = ""
+			served = c.chain
+		}
+	}
+	if env != "" {
+		r.Header.Set(EnvHeader, env)
+	}
+	if preset := requestPreset(r); preset != "" {
+		params, ok := g.presetParams(preset)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("preset %s is not declared", preset))
+			return
+		}
+		r.Header.Set(ParamsHeader, params)
+	}
+	if generation := r.Header.Get(GenerationHeader); generation != "" {
+		r.Header.Del(GenerationHeader)
+		params, err := applyGeneration(r.Header.Get(ParamsHeader), generation)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Header.Set(ParamsHeader, params)
+	}
+
+	if name == "" {
+		backend.proxy.ServeHTTP(w, r)
+		return
+	}
+	chainSchema := g.chainSchema(served)
+	contract := g.chainOutput(served)
+	transforms := g.chainTransforms(served)
+	g.mu.RLock()
+	images := g.images
+	uploads := g.fileSettings != nil
+	g.mu.RUnlock()
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var inputs map[string]any
+	if err := json.Unmarshal(body, &inputs); err == nil {
+		checked, status, err := images.check(inputs)
+		if err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		if chainSchema != nil && chainSchema.Input != nil {
+			if err := chainSchema.Input.Validate(checked); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if uploads {
+			resolved, err := g.resolveFiles(inputs, requestKey(r))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if resolved {
+				body, err = json.Marshal(inputs)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+			}
+		}
+	}
+	// requests that are not JSON objects are rejected by the worker
+
+	if transforms == nil {
+		g.proxyChain(w, r, body, contract, backend)
+		return
+	}
+	// transforms apply to complete outputs, so their chains do not stream
+	if wantsStream(r) {
+		r.Header.Set("Accept", "application/json")
+	}
+	response := newBufferedWriter()
+	g.proxyChain(response, r, body, contract, backend)
+	response.writeTo(w, transforms.transformBody(response.status, response.body.Bytes()))
+}
+
+// readBody reads the body of a request. If that fails, it writes the error
+// response, 413 for bodies above the limit of the gateway, and returns false.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the request body exceeds the maximum size of %s", models.FormatSize(tooLarge.Limit)))
+			return nil, false
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return nil, false
+	}
+	return body, true
+}
+
+// proxyChain proxies a chain request with its body to the worker.
+func (g *Gateway) proxyChain(w http.ResponseWriter, r *http.Request, body []byte, contract *outputContract, backend *Backend) {
+	if contract != nil {
+		g.serveStructured(w, r, body, contract, backend)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	backend.proxy.ServeHTTP(w, r)
+}
+
+// filterResponse takes the usage reported by the worker, normalizes the errors
+// of chains and applies the output guardrails to successful chain responses.
+func (g *Gateway) filterResponse(resp *http.Response) error {
+	takeUsage(resp)
+	if chainName(resp.Request) != "" {
+		if err := normalizeErrors(resp); err != nil {
+			return err
+		}
+	}
+
+	guardrail, _ := resp.Request.Context().Value(guardrailKey{}).(*Guardrail)
+	if guardrail == nil || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var outputs map[string]any
+	if err := json.Unmarshal(body, &outputs); err == nil {
+		if err := guardrail.CheckOutput(resp.Request.Context(), outputs); err != nil {
+			var violation *Violation
+			if !errors.As(err, &violation) {
+				return err
+			}
+			fmt.Printf("Guardrail rejected output of chain %s (request %s): %s\n", guardrail.chain, requestID(resp.Request), violation.Reason)
+			resp.StatusCode = violation.Status
+			resp.Status = fmt.Sprintf("%d %s", violation.Status, http.StatusText(violation.Status))
+			outputs = map[string]any{"error": violation.Reason}
+		}
+		body, err = json.Marshal(outputs)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func (g *Gateway) reject(w http.ResponseWriter, r *http.Request, err error) {
+	var violation *Violation
+	if !errors.As(err, &violation) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	fmt.Printf("Guardrail rejected input of chain %s (request %s): %s\n", chainName(r), requestID(r), violation.Reason)
+	writeError(w, violation.Status, violation.Reason)
+}
+
+// chainName returns the chain addressed by a request to /chat/<name>, or an
+// empty string for any other request.
+func chainName(r *http.Request) string {
+	if r.Method != http.MethodPost {
+		return ""
+	}
+	if !strings.HasPrefix(r.URL.Path, "/chat/") {
+		return ""
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/chat/")
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	return name
+}
+
+// wantsStream reports whether a chain request asks for the outputs to be
+// streamed as server-sent events.
+func wantsStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// FreePort returns a TCP port on the loopback interface that is currently unused.
+func FreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
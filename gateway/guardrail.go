@@ -0,0 +1,279 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/protocol"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Violation is returned when a guardrail rejects the inputs or outputs of a chain.
+type Violation struct {
+	Status int
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// Guardrail filters the inputs and outputs of a chain according to its
+// guardrails configuration.
+type Guardrail struct {
+	chain           string
+	maxPromptLength int
+	blocked         []*regexp.Regexp
+	pii             string
+	moderation      *moderator
+}
+
+// NewGuardrail compiles the guardrails configuration of a chain.
+func NewGuardrail(chain string, config *project.GuardrailConfig) (*Guardrail, error) {
+	g := &Guardrail{
+		chain:           chain,
+		maxPromptLength: config.MaxPromptLength,
+		pii:             config.PII,
+	}
+
+	switch g.pii {
+	case "", "off":
+		g.pii = "off"
+	case "block", "redact":
+	default:
+		return nil, fmt.Errorf("chain %s: unsupported pii mode %q, expected off, block or redact", chain, config.PII)
+	}
+
+	for _, pattern := range config.BlockedPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("chain %s: invalid blocked pattern %q: %v", chain, pattern, err)
+		}
+		g.blocked = append(g.blocked, re)
+	}
+
+	if config.Moderation != nil {
+		m, err := newModerator(config.Moderation)
+		if err != nil {
+			return nil, fmt.Errorf("chain %s: %v", chain, err)
+		}
+		g.moderation = m
+	}
+
+	return g, nil
+}
+
+// CheckInput validates the inputs of a chain request. With PII redaction enabled
+// the inputs are modified in place.
+func (g *Guardrail) CheckInput(ctx context.Context, inputs map[string]any) error {
+	if g.maxPromptLength > 0 {
+		length := 0
+		eachString(inputs, func(s string) string {
+			length += len([]rune(s))
+			return s
+		})
+		if length > g.maxPromptLength {
+			return &Violation{
+				Status: http.StatusRequestEntityTooLarge,
+				Reason: fmt.Sprintf("input is too long (%d characters, at most %d allowed)", length, g.maxPromptLength),
+			}
+		}
+	}
+
+	return g.check(ctx, "input", inputs)
+}
+
+// CheckOutput validates the outputs of a chain. With PII redaction enabled the
+// outputs are modified in place.
+func (g *Guardrail) CheckOutput(ctx context.Context, outputs map[string]any) error {
+	return g.check(ctx, "output", outputs)
+}
+
+func (g *Guardrail) check(ctx context.Context, stage string, values map[string]any) error {
+	texts := []string{}
+	eachString(values, func(s string) string {
+		texts = append(texts, s)
+		return s
+	})
+
+	for _, text := range texts {
+		for _, re := range g.blocked {
+			if re.MatchString(text) {
+				return &Violation{
+					Status: http.StatusUnprocessableEntity,
+					Reason: fmt.Sprintf("%s matches a blocked pattern", stage),
+				}
+			}
+		}
+	}
+
+	switch g.pii {
+	case "block":
+		for _, text := range texts {
+			if kinds := DetectPII(text); len(kinds) > 0 {
+				return &Violation{
+					Status: http.StatusUnprocessableEntity,
+					Reason: fmt.Sprintf("%s contains personal data (%s)", stage, strings.ToLower(strings.Join(kinds, ", "))),
+				}
+			}
+		}
+	case "redact":
+		eachString(values, RedactPII)
+	}
+
+	if g.moderation != nil && (stage == "input" || g.moderation.outputs) {
+		text := strings.Join(texts, "\n")
+		if g.pii == "redact" {
+			text = RedactPII(text)
+		}
+		flagged, reason, err := g.moderation.check(ctx, g.chain, stage, text)
+		if err != nil {
+			return &Violation{
+				Status: http.StatusServiceUnavailable,
+				Reason: fmt.Sprintf("moderation failed: %v", err),
+			}
+		}
+		if flagged {
+			message := fmt.Sprintf("%s was flagged by moderation", stage)
+			if reason != "" {
+				message += ": " + reason
+			}
+			return &Violation{Status: http.StatusUnprocessableEntity, Reason: message}
+		}
+	}
+
+	return nil
+}
+
+// eachString calls fn for every string value, including strings nested in
+// lists and objects such as the messages of the memory input, and replaces
+// the value with the result.
+func eachString(values map[string]any, fn func(string) string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		values[key] = mapStrings(values[key], fn)
+	}
+}
+
+// mapStrings returns value with fn applied to its strings, maps and lists are
+// changed in place. Images are not text, their data is left as it is.
+func mapStrings(value any, fn func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case []any:
+		for i, el := range v {
+			v[i] = mapStrings(el, fn)
+		}
+	case map[string]any:
+		if _, ok := protocol.ParseImage(v); !ok {
+			eachString(v, fn)
+		}
+	}
+	return value
+}
+
+// moderator calls an external moderation endpoint. The endpoint receives
+// {"input", "chain", "stage"} and answers either {"flagged", "reason"} or in
+// the format of the OpenAI moderation API.
+type moderator struct {
+	url     string
+	headers map[string]string
+	outputs bool
+	client  *http.Client
+}
+
+func newModerator(config *project.ModerationConfig) (*moderator, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("moderation url is missing")
+	}
+
+	timeout := 10 * time.Second
+	if config.Timeout != "" {
+		d, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation timeout %q: %v", config.Timeout, err)
+		}
+		timeout = d
+	}
+
+	return &moderator{
+		url:     config.URL,
+		headers: config.Headers,
+		outputs: config.Outputs,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (m *moderator) check(ctx context.Context, chain string, stage string, text string) (bool, string, error) {
+	body, err := json.Marshal(map[string]string{
+		"input": text,
+		"chain": chain,
+		"stage": stage,
+	})
+	if err != nil {
+		return false, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range m.headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("moderation endpoint returned %s", resp.Status)
+	}
+
+	var result struct {
+		Flagged bool   `json:"flagged"`
+		Reason  string `json:"reason"`
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, "", fmt.Errorf("invalid moderation response: %v", err)
+	}
+
+	if result.Flagged {
+		return true, result.Reason, nil
+	}
+	for _, r := range result.Results {
+		if !r.Flagged {
+			continue
+		}
+		categories := []string{}
+		for category, flagged := range r.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+		sort.Strings(categories)
+		return true, strings.Join(categories, ", "), nil
+	}
+
+	return false, "", nil
+}
@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Paths of the probes of the gateway, which are answered without an API key.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// readinessTimeout bounds the time the checks of a readiness probe may take.
+const readinessTimeout = 5 * time.Second
+
+// ReadinessCheck checks a backend that the chains depend on, such as the
+// vector store. The gateway only reports ready when all checks pass.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// SetReadinessChecks sets the checks of the readiness probe in addition to
+// the built-in check of the worker.
+func (g *Gateway) SetReadinessChecks(checks []ReadinessCheck) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.readinessChecks = checks
+}
+
+type checkResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// checkWorker checks that the worker has started and still answers.
+func (g *Gateway) checkWorker(ctx context.Context) error {
+	g.mu.RLock()
+	schemas, backend := g.schemas, g.backend
+	g.mu.RUnlock()
+	if schemas == nil {
+		return errors.New("the worker is starting")
+	}
+	if worker, ok := backend.Transport.(interface{ Done() <-chan struct{} }); ok {
+		select {
+		case <-worker.Done():
+			return errors.New("the worker is not available")
+		default:
+		}
+	}
+	return nil
+}
+
+// serveProbe answers the liveness and readiness probes. The readiness probe
+// runs the checks concurrently and answers 503 if any of them fails. Errors
+// are only reported to clients with an API key, since they may reveal
+// internal addresses.
+func (g *Gateway) serveProbe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Path == LivenessPath {
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	g.mu.RLock()
+	checks := append([]ReadinessCheck{{Name: "worker", Check: g.checkWorker}}, g.readinessChecks...)
+	g.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	results := make([]checkResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+			results[i] = checkResult{Name: check.Name, OK: true}
+			if err := check.Check(ctx); err != nil {
+				results[i] = checkResult{Name: check.Name, Error: err.Error()}
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	_, authorized := g.authorize(r)
+	ready := true
+	for i := range results {
+		ready = ready && results[i].OK
+		if !authorized {
+			results[i].Error = ""
+		}
+	}
+
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ready": ready, "checks": results})
+}
@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"fmt"
+	"langforge/models"
+	"langforge/project"
+	"langforge/protocol"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const defaultMaxImageSize = 5 << 20
+
+// bodyImages is the number of images of the maximum size that the body of a
+// chain request has room for, besides bodyHeadroom bytes of other inputs.
+const (
+	bodyImages   = 4
+	bodyHeadroom = 1 << 20
+)
+
+// defaultImageTypes are the media types of images that the multimodal models
+// of OpenAI, Anthropic and Gemini accept.
+var defaultImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// imageSettings are the limits of the image inputs of chain requests, see
+// project.ImagesConfig.
+type imageSettings struct {
+	maxSize int64
+	types   map[string]bool
+}
+
+func newImageSettings(config project.ImagesConfig) (*imageSettings, error) {
+	s := &imageSettings{maxSize: defaultMaxImageSize, types: map[string]bool{}}
+	if config.MaxSize != "" {
+		size, err := models.ParseSize(config.MaxSize)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid gateway.images.maxSize %q, use e.g. 5MB", config.MaxSize)
+		}
+		s.maxSize = size
+	}
+	types := config.Types
+	if len(types) == 0 {
+		types = defaultImageTypes
+	}
+	for _, mediaType := range types {
+		if !strings.HasPrefix(mediaType, "image/") {
+			return nil, fmt.Errorf("invalid gateway.images.types entry %q, use media types such as image/png", mediaType)
+		}
+		s.types[strings.ToLower(mediaType)] = true
+	}
+	return s, nil
+}
+
+// check validates the image inputs of a chain request: their media types must
+// be accepted and match their content, and they must not exceed the maximum
+// size. It returns the inputs with each image replaced by its data, which the
+// worker passes to the chain as a data URL, for the validation against the
+// schema of the chain, and the status of the response if an image is
+// rejected.
+func (s *imageSettings) check(inputs map[string]any) (map[string]any, int, error) {
+	keys := []string{}
+	for key, value := range inputs {
+		if _, ok := protocol.ParseImage(value); ok {
+			keys = append(keys, key)
+		} else if containsImage(value) {
+			// the workers only pass images of their own inputs to the chain
+			return nil, http.StatusBadRequest, fmt.Errorf("input %s: images are only accepted as inputs of their own, not in lists or objects", key)
+		}
+	}
+	if len(keys) == 0 {
+		return inputs, http.StatusOK, nil
+	}
+	sort.Strings(keys)
+
+	checked := map[string]any{}
+	for key, value := range inputs {
+		checked[key] = value
+	}
+	for _, key := range keys {
+		image, _ := protocol.ParseImage(inputs[key])
+		mediaType := strings.ToLower(image.MediaType)
+		switch {
+		case mediaType == "":
+			return nil, http.StatusBadRequest, fmt.Errorf("input %s: the image has no mediaType", key)
+		case !s.types[mediaType]:
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("input %s: images of type %s are not accepted, send one of %s", key, mediaType, s.typeList())
+		case int64(base64.StdEncoding.DecodedLen(len(image.Data))) > s.maxSize+2:
+			// the size of the decoded data is known up to the padding
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("input %s: the image exceeds the maximum size of %s", key, models.FormatSize(s.maxSize))
+		}
+
+		data, err := image.Decode()
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("input %s: the data of the image is not valid base64", key)
+		}
+		if int64(len(data)) > s.maxSize {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("input %s: the image exceeds the maximum size of %s", key, models.FormatSize(s.maxSize))
+		}
+		// images whose format is not sniffed, e.g. HEIC, are taken as declared
+		if detected := http.DetectContentType(data); detected != "application/octet-stream" && detected != mediaType {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("input %s: the image is declared as %s but its content is %s", key, mediaType, detected)
+		}
+		checked[key] = image.Data
+	}
+	return checked, http.StatusOK, nil
+}
+
+// containsImage reports whether an image is nested in a list or object.
+func containsImage(value any) bool {
+	switch v := value.(type) {
+	case []any:
+		for _, el := range v {
+			if _, ok := protocol.ParseImage(el); ok || containsImage(el) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, el := range v {
+			if _, ok := protocol.ParseImage(el); ok || containsImage(el) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxBodySize returns the size that the bodies of chain requests are limited
+// to, so that the gateway does not read arbitrarily large bodies into memory.
+func (s *imageSettings) maxBodySize() int64 {
+	encoded := (s.maxSize + 2) / 3 * 4
+	return bodyImages*encoded + bodyHeadroom
+}
+
+// typeList returns the accepted media types for error messages.
+func (s *imageSettings) typeList() string {
+	types := []string{}
+	for mediaType := range s.types {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
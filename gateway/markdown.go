@@ -0,0 +1,225 @@
+package gateway
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The Markdown that LLMs answer with is converted by markdownToHTML, which
+// supports headings, paragraphs, fenced code blocks, lists, block quotes,
+// horizontal rules, code spans, emphasis, links and images. HTML in the
+// Markdown is escaped, so that the output is safe to insert into a page.
+
+var (
+	headingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rulePattern        = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	listItemPattern    = regexp.MustCompile(`^\s{0,3}([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	fencePattern       = regexp.MustCompile("^\\s{0,3}(```+|~~~+)\\s*([\\w+#.-]*)")
+	codeSpanPattern    = regexp.MustCompile("`+([^`]+)`+")
+	imagePattern       = regexp.MustCompile(`!\[([^\[\]]*)\]\(([^()\s]+)\)`)
+	linkPattern        = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+	strongPattern      = regexp.MustCompile(`\*\*([^*\n]+)\*\*|\b__([^_\n]+)__\b`)
+	emphasisPattern    = regexp.MustCompile(`\*([^*\n]+)\*|\b_([^_\n]+)_\b`)
+	placeholderPattern = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// markdownToHTML converts Markdown to HTML.
+func markdownToHTML(text string) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\x00", ""), "\r\n", "\n")
+	var out strings.Builder
+	renderBlocks(&out, strings.Split(text, "\n"))
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// renderBlocks writes the blocks of lines to out.
+func renderBlocks(out *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case fencePattern.MatchString(line):
+			match := fencePattern.FindStringSubmatch(line)
+			fence := match[1]
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			i++ // the closing fence
+			if match[2] != "" {
+				fmt.Fprintf(out, "<pre><code class=\"language-%s\">", html.EscapeString(match[2]))
+			} else {
+				out.WriteString("<pre><code>")
+			}
+			out.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			if len(code) > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString("</code></pre>\n")
+
+		case headingPattern.MatchString(trimmed):
+			match := headingPattern.FindStringSubmatch(trimmed)
+			level := len(match[1])
+			fmt.Fprintf(out, "<h%d>%s</h%d>\n", level, renderInline(match[2]), level)
+			i++
+
+		case rulePattern.MatchString(line):
+			out.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			quoted := []string{}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quoted = append(quoted, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			out.WriteString("<blockquote>\n")
+			renderBlocks(out, quoted)
+			out.WriteString("</blockquote>\n")
+
+		case listItemPattern.MatchString(line):
+			i = renderList(out, lines, i)
+
+		default:
+			paragraph := []string{trimmed}
+			for i++; i < len(lines) && !startsBlock(lines[i]); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+			fmt.Fprintf(out, "<p>%s</p>\n", renderInline(strings.Join(paragraph, "\n")))
+		}
+	}
+}
+
+// startsBlock reports whether a line ends a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, ">") || fencePattern.MatchString(line) ||
+		headingPattern.MatchString(trimmed) || rulePattern.MatchString(line) || listItemPattern.MatchString(line)
+}
+
+// renderList writes the list that begins at lines[start] to out and returns
+// the index of the line after it. Other lines continue the previous item, and
+// the items of nested lists become items of the list.
+func renderList(out *strings.Builder, lines []string, start int) int {
+	first := listItemPattern.FindStringSubmatch(lines[start])
+	ordered := first[1][0] >= '0' && first[1][0] <= '9'
+	if ordered {
+		if number, _ := strconv.Atoi(strings.TrimRight(first[1], ".)")); number != 1 {
+			fmt.Fprintf(out, "<ol start=\"%d\">\n", number)
+		} else {
+			out.WriteString("<ol>\n")
+		}
+	} else {
+		out.WriteString("<ul>\n")
+	}
+
+	items := [][]string{}
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if match := listItemPattern.FindStringSubmatch(line); match != nil {
+			isOrdered := match[1][0] >= '0' && match[1][0] <= '9'
+			indented := len(line)-len(strings.TrimLeft(line, " \t")) >= 2
+			if isOrdered != ordered && !indented {
+				break
+			}
+			items = append(items, []string{match[2]})
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			// a blank line ends the list unless an item follows
+			if i+1 < len(lines) && listItemPattern.MatchString(lines[i+1]) {
+				continue
+			}
+			break
+		}
+		if len(line)-len(strings.TrimLeft(line, " \t")) == 0 && startsBlock(line) {
+			break
+		}
+		last := len(items) - 1
+		items[last] = append(items[last], strings.TrimSpace(line))
+	}
+
+	for _, item := range items {
+		fmt.Fprintf(out, "<li>%s</li>\n", renderInline(strings.Join(item, "\n")))
+	}
+	if ordered {
+		out.WriteString("</ol>\n")
+	} else {
+		out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// renderInline converts the code spans, links, images and emphasis of a
+// block of text and escapes the rest.
+func renderInline(text string) string {
+	// code spans and links are kept aside so that emphasis does not apply
+	// inside them, e.g. to the underscores of URLs
+	kept := []string{}
+	keep := func(fragment string) string {
+		kept = append(kept, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(kept)-1)
+	}
+
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(match string) string {
+		code := codeSpanPattern.FindStringSubmatch(match)[1]
+		return keep("<code>" + html.EscapeString(strings.TrimSpace(code)) + "</code>")
+	})
+	text = html.EscapeString(text)
+	text = imagePattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := imagePattern.FindStringSubmatch(match)
+		if !safeURL(parts[2]) {
+			return parts[1]
+		}
+		return keep(fmt.Sprintf(`<img src="%s" alt="%s">`, parts[2], parts[1]))
+	})
+	text = linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := linkPattern.FindStringSubmatch(match)
+		if !safeURL(parts[2]) {
+			return parts[1]
+		}
+		return keep(fmt.Sprintf(`<a href="%s">%s</a>`, parts[2], renderEmphasis(parts[1])))
+	})
+	text = renderEmphasis(text)
+
+	// links may keep code spans
+	for placeholderPattern.MatchString(text) {
+		text = placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+			index, _ := strconv.Atoi(strings.Trim(match, "\x00"))
+			return kept[index]
+		})
+	}
+	return text
+}
+
+func renderEmphasis(text string) string {
+	text = strongPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := strongPattern.FindStringSubmatch(match)
+		return "<strong>" + parts[1] + parts[2] + "</strong>"
+	})
+	return emphasisPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := emphasisPattern.FindStringSubmatch(match)
+		return "<em>" + parts[1] + parts[2] + "</em>"
+	})
+}
+
+// safeURL reports whether an escaped link target may be used in HTML, which
+// excludes URLs that run scripts but not the data URLs of images.
+func safeURL(target string) bool {
+	target = strings.ToLower(strings.TrimSpace(html.UnescapeString(target)))
+	if strings.HasPrefix(target, "data:image/") {
+		return true
+	}
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+		if strings.HasPrefix(target, scheme) {
+			return false
+		}
+	}
+	return true
+}
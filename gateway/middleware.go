@@ -0,0 +1,227 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware wraps the handler of the gateway. Middlewares see all requests;
+// ChainName tells chain requests apart from others.
+type Middleware func(next http.Handler) http.Handler
+
+// MiddlewareFactory creates a middleware from the options of its entry in the
+// gateway configuration.
+type MiddlewareFactory func(options map[string]string) (Middleware, error)
+
+// Names of the built-in middlewares.
+const (
+	MiddlewareAuth       = "auth"
+	MiddlewareAnalytics  = "analytics"
+	MiddlewareCapture    = "capture"
+	MiddlewareGuardrails = "guardrails"
+	MiddlewareRateLimit  = "ratelimit"
+	MiddlewareCache      = "cache"
+	MiddlewareLogging    = "logging"
+)
+
+// defaultMiddleware is the order of the built-in middlewares that always run.
+var defaultMiddleware = []string{MiddlewareAuth, MiddlewareAnalytics, MiddlewareCapture, MiddlewareGuardrails}
+
+var middlewares = map[string]MiddlewareFactory{}
+
+// RegisterMiddleware registers a custom middleware that projects enable by
+// listing its name in the gateway middleware of langforge.yaml. Custom builds
+// of langforge register their middlewares in an init function.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	if _, builtin := builtinMiddleware(nil, name, nil); builtin {
+		panic(fmt.Sprintf("gateway: %s is a built-in middleware", name))
+	}
+	if _, ok := middlewares[name]; ok {
+		panic(fmt.Sprintf("gateway: middleware %s is already registered", name))
+	}
+	middlewares[name] = factory
+}
+
+// ChainName returns the chain addressed by a request to /chat/<name>, or an
+// empty string for any other request.
+func ChainName(r *http.Request) string {
+	return chainName(r)
+}
+
+// builtinMiddleware returns the constructor of a built-in middleware and
+// whether name is one.
+func builtinMiddleware(g *Gateway, name string, options map[string]string) (func() (Middleware, error), bool) {
+	switch name {
+	case MiddlewareAuth:
+		return func() (Middleware, error) { return g.authMiddleware, nil }, true
+	case MiddlewareAnalytics:
+		return func() (Middleware, error) { return g.analyticsMiddleware, nil }, true
+	case MiddlewareCapture:
+		return func() (Middleware, error) { return g.captureMiddleware, nil }, true
+	case MiddlewareGuardrails:
+		return func() (Middleware, error) { return g.guardrailMiddleware, nil }, true
+	case MiddlewareRateLimit:
+		return func() (Middleware, error) { return g.newRateLimitMiddleware(options) }, true
+	case MiddlewareCache:
+		return func() (Middleware, error) { return newCacheMiddleware(options) }, true
+	case MiddlewareLogging:
+		return func() (Middleware, error) { return loggingMiddleware, nil }, true
+	}
+	return nil, false
+}
+
+// buildHandler wraps the handler of the gateway in the configured middlewares.
+// The built-in middlewares that always run but are not listed are placed
+// first, so that leaving them out never lets a request bypass them.
+func (g *Gateway) buildHandler(config []project.MiddlewareConfig) (http.Handler, error) {
+	listed := map[string]bool{}
+	for _, entry := range config {
+		if listed[entry.Name] {
+			return nil, fmt.Errorf("middleware %s is listed twice in %s", entry.Name, project.ConfigFileName)
+		}
+		listed[entry.Name] = true
+	}
+
+	entries := []project.MiddlewareConfig{}
+	for _, name := range defaultMiddleware {
+		if !listed[name] {
+			entries = append(entries, project.MiddlewareConfig{Name: name})
+		}
+	}
+	entries = append(entries, config...)
+
+	chain := []Middleware{}
+	for _, entry := range entries {
+		create, ok := builtinMiddleware(g, entry.Name, entry.Options)
+		if !ok {
+			factory, registered := middlewares[entry.Name]
+			if !registered {
+				return nil, fmt.Errorf("unknown middleware %q in %s", entry.Name, project.ConfigFileName)
+			}
+			create = func() (Middleware, error) { return factory(entry.Options) }
+		}
+		middleware, err := create()
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %v", entry.Name, err)
+		}
+		chain = append(chain, middleware)
+	}
+
+	var handler http.Handler = http.HandlerFunc(g.serve)
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return handler, nil
+}
+
+// clientKey is the context key of the identity of the client, the name of its
+// API key, set by the auth middleware.
+type clientKey struct{}
+
+func (g *Gateway) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := g.authorize(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "a valid API key is required")
+			return
+		}
+		if key != nil {
+			if !g.admit(w, r, key) {
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), clientKey{}, key.name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *Gateway) analyticsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := g.currentAnalytics()
+		if writer == nil || chainName(r) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		g.measure(writer, w, r, next.ServeHTTP)
+	})
+}
+
+func (g *Gateway) captureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := g.currentRecorder()
+		if recorder == nil || chainName(r) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		g.capture(recorder, w, r, body, func(w http.ResponseWriter) {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// guardrailKey is the context key of the guardrail whose output checks apply
+// to the response of a chain request.
+type guardrailKey struct{}
+
+// guardrailMiddleware applies the input guardrails of a chain and arranges for
+// its output guardrails to be applied to the response.
+func (g *Gateway) guardrailMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		guardrail, _ := g.chainSettings(chainName(r))
+		if guardrail == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// output guardrails check complete outputs, so their chains do not stream
+		if wantsStream(r) {
+			r.Header.Set("Accept", "application/json")
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+
+		var inputs map[string]any
+		if err := json.Unmarshal(body, &inputs); err == nil {
+			if err := guardrail.CheckInput(r.Context(), inputs); err != nil {
+				g.reject(w, r, err)
+				return
+			}
+			body, err = json.Marshal(inputs)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), guardrailKey{}, guardrail)))
+	})
+}
+
+// loggingMiddleware prints a line for each request once it has been served,
+// ending with its request ID.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		status := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(status, r)
+		fmt.Printf("%s %s %s %d %v %s\n", start.Format("15:04:05"), r.Method, r.URL.Path, status.status, time.Since(start).Round(time.Millisecond), requestID(r))
+	})
+}
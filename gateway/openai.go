@@ -0,0 +1,520 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"langforge/models"
+	"langforge/project"
+	"langforge/provider"
+	"langforge/schema"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paths of the OpenAI facade, see project.OpenAIConfig.
+const (
+	OpenAIChatPath   = "/v1/chat/completions"
+	OpenAIModelsPath = "/v1/models"
+)
+
+// inputKeys and outputKeys are the keys that the facade passes the message to
+// and takes the reply from when a chain has several inputs or outputs.
+var (
+	inputKeys  = []string{"input", "question", "query", "human_input", "text"}
+	outputKeys = []string{"text", "answer", "output", "result", "response"}
+)
+
+// openAIFacade serves chains in the wire format of the chat completions API.
+type openAIFacade struct {
+	// chains are the chains that are served, all if nil.
+	chains map[string]bool
+}
+
+func newOpenAIFacade(config *project.OpenAIConfig) *openAIFacade {
+	if config == nil {
+		return nil
+	}
+	f := &openAIFacade{}
+	if len(config.Chains) > 0 {
+		f.chains = map[string]bool{}
+		for _, chain := range config.Chains {
+			f.chains[chain] = true
+		}
+	}
+	return f
+}
+
+func (f *openAIFacade) serves(chain string) bool {
+	return f.chains == nil || f.chains[chain]
+}
+
+type openAIMessage struct {
+	Role string `json:"role"`
+	// Content is a string or a list of parts, of which the text parts are
+	// used.
+	Content any `json:"content"`
+}
+
+func (m openAIMessage) text() string {
+	switch content := m.Content.(type) {
+	case string:
+		return content
+	case []any:
+		texts := []string{}
+		for _, part := range content {
+			if part, ok := part.(map[string]any); ok && part["type"] == "text" {
+				if text, ok := part["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// serveOpenAIChat serves a chat completion with the chain named by the model
+// of the request. The request is handled as a request to /chat/<chain> by the
+// middlewares, so that API keys, analytics and guardrails apply, and the
+// response is translated back.
+func (g *Gateway) serveOpenAIChat(w http.ResponseWriter, r *http.Request, facade *openAIFacade, handler http.Handler) {
+	// clients without a valid key learn nothing about the chains, and keys
+	// that may only invoke some chains nothing about the others; the auth
+	// middleware checks the key again with its limits
+	key, ok := g.lookupKey(r)
+	if !ok {
+		writeOpenAIError(w, http.StatusUnauthorized, "a valid API key is required", "invalid_api_key")
+		return
+	}
+	var request struct {
+		Model    string          `json:"model"`
+		Messages []openAIMessage `json:"messages"`
+		Stream   bool            `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeOpenAIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the request body exceeds the maximum size of %s", models.FormatSize(tooLarge.Limit)), "")
+			return
+		}
+		writeOpenAIError(w, http.StatusBadRequest, "the request body is not a chat completion request: "+err.Error(), "")
+		return
+	}
+	if !facade.serves(request.Model) || strings.Contains(request.Model, "/") || (g.hasSchemas() && g.chainSchema(request.Model) == nil) ||
+		(key != nil && key.chains != nil && !key.chains[request.Model]) {
+		writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("the model %s does not exist, the models are the served chains", request.Model), "model_not_found")
+		return
+	}
+	inputs, err := chatInputs(g.chainSchema(request.Model), request.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	body, err := json.Marshal(inputs)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error(), "")
+		return
+	}
+
+	chain := r.Clone(r.Context())
+	chain.URL.Path = "/chat/" + request.Model
+	chain.URL.RawPath = ""
+	chain.Body = io.NopCloser(bytes.NewReader(body))
+	chain.ContentLength = int64(len(body))
+	chain.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	chain.Header.Set("Content-Type", "application/json")
+	chain.Header.Set("Accept", "application/json")
+	if request.Stream {
+		chain.Header.Set("Accept", "text/event-stream")
+	}
+
+	translator := &openAIWriter{
+		w:       w,
+		header:  http.Header{},
+		stream:  request.Stream,
+		id:      "chatcmpl-" + requestID(r),
+		model:   request.Model,
+		created: time.Now().Unix(),
+	}
+	handler.ServeHTTP(translator, g.assignPreset(translator, g.assignVariant(translator, chain)))
+	translator.finish()
+}
+
+// serveOpenAIModels lists the served chains as models, those that the API key
+// of the request may invoke.
+func (g *Gateway) serveOpenAIModels(w http.ResponseWriter, r *http.Request, facade *openAIFacade) {
+	g.mu.RLock()
+	schemas := g.schemas
+	var allowed map[string]bool
+	for _, key := range g.apiKeys {
+		if key.name == requestKey(r) {
+			allowed = key.chains
+		}
+	}
+	g.mu.RUnlock()
+
+	names := []string{}
+	switch {
+	case schemas != nil:
+		for _, chain := range schemas.Chains {
+			if facade.serves(chain.Name) {
+				names = append(names, chain.Name)
+			}
+		}
+	case facade.chains != nil:
+		for name := range facade.chains {
+			names = append(names, name)
+		}
+	default:
+		writeOpenAIError(w, http.StatusServiceUnavailable, "chain schemas are not available yet", "")
+		return
+	}
+	sort.Strings(names)
+
+	models := []map[string]any{}
+	for _, name := range names {
+		if allowed != nil && !allowed[name] {
+			continue
+		}
+		models = append(models, map[string]any{"id": name, "object": "model", "created": 0, "owned_by": "langforge"})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": models})
+}
+
+func (g *Gateway) hasSchemas() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.schemas != nil
+}
+
+// chatInputs returns the inputs of a chain for the messages of a chat
+// completion: the last user message is the input and the earlier user and
+// assistant messages are the memory of chains that have one. System messages
+// are left out, since chains bring their own prompts.
+func chatInputs(chain *schema.Chain, messages []openAIMessage) (map[string]any, error) {
+	last := -1
+	for i, message := range messages {
+		if message.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("messages must contain a user message")
+	}
+
+	key := "input"
+	hasMemory := false
+	if chain != nil && chain.Input != nil {
+		keys := []string{}
+		for name := range chain.Input.Properties {
+			if name == "memory" {
+				hasMemory = true
+				continue
+			}
+			keys = append(keys, name)
+		}
+		sort.Strings(keys)
+		key = pickKey(keys, inputKeys)
+		if key == "" {
+			return nil, fmt.Errorf("the chain %s has the inputs %s, none of which takes the message", chain.Name, strings.Join(keys, ", "))
+		}
+	}
+	inputs := map[string]any{key: messages[last].text()}
+
+	if !hasMemory {
+		return inputs, nil
+	}
+	// the memory alternates between user and assistant messages, starting
+	// with a user message; consecutive messages of a role are joined
+	memory := []string{}
+	role := ""
+	for _, message := range messages[:last] {
+		if message.Role != "user" && message.Role != "assistant" {
+			continue
+		}
+		switch {
+		case message.Role == role:
+			memory[len(memory)-1] += "\n\n" + message.text()
+		case len(memory) == 0 && message.Role == "assistant":
+		default:
+			memory = append(memory, message.text())
+			role = message.Role
+		}
+	}
+	if len(memory) > 0 {
+		inputs["memory"] = memory
+	}
+	return inputs, nil
+}
+
+// pickKey returns the only key, or the first of preferred that is a key.
+func pickKey(keys []string, preferred []string) string {
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	for _, name := range preferred {
+		for _, key := range keys {
+			if key == name {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// replyText returns the output of a chain that is the reply of the assistant:
+// the only output, or the first of outputKeys, or all outputs as JSON.
+func replyText(outputs map[string]any) string {
+	keys := []string{}
+	for key := range outputs {
+		keys = append(keys, key)
+	}
+	if key := pickKey(keys, outputKeys); key != "" {
+		if text, ok := outputs[key].(string); ok {
+			return text
+		}
+	}
+	data, _ := json.Marshal(outputs)
+	return string(data)
+}
+
+// openAIWriter translates the response of a chain to a chat completion, or to
+// chat completion chunks if the client streams. Token events of the chain
+// become chunks as they arrive; other responses are buffered and translated
+// once the chain is done.
+type openAIWriter struct {
+	w       http.ResponseWriter
+	header  http.Header
+	stream  bool
+	id      string
+	model   string
+	created int64
+
+	status int
+	// events is set while the events of the chain are translated.
+	events   bool
+	buffer   bytes.Buffer
+	event    string
+	streamed bool
+	done     bool
+}
+
+func (t *openAIWriter) Header() http.Header {
+	return t.header
+}
+
+func (t *openAIWriter) WriteHeader(status int) {
+	if t.status != 0 {
+		return
+	}
+	t.status = status
+	if t.stream && status == http.StatusOK && strings.HasPrefix(t.header.Get("Content-Type"), "text/event-stream") {
+		t.events = true
+		t.startStream()
+	}
+}
+
+func (t *openAIWriter) Write(p []byte) (int, error) {
+	if t.status == 0 {
+		t.WriteHeader(http.StatusOK)
+	}
+	t.buffer.Write(p)
+	if !t.events {
+		return len(p), nil
+	}
+	for {
+		line, err := t.buffer.ReadString('\n')
+		if err != nil {
+			// keep the incomplete line for the next write
+			t.buffer.Reset()
+			t.buffer.WriteString(line)
+			break
+		}
+		t.translateLine(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+// Flush passes flushes through while chunks are streamed.
+func (t *openAIWriter) Flush() {
+	if !t.events {
+		return
+	}
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// translateLine translates a line of the server-sent events of a chain.
+func (t *openAIWriter) translateLine(line string) {
+	switch {
+	case t.done:
+	case strings.HasPrefix(line, "event:"):
+		t.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+	case line == "":
+		t.event = ""
+	case strings.HasPrefix(line, "data:"):
+		data := []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		switch t.event {
+		case "token":
+			var token struct {
+				Token string `json:"token"`
+			}
+			if json.Unmarshal(data, &token) == nil && token.Token != "" {
+				t.streamed = true
+				t.writeChunk(map[string]any{"content": token.Token}, nil)
+			}
+		case "result":
+			outputs := map[string]any{}
+			json.Unmarshal(data, &outputs)
+			t.endStream(replyText(outputs))
+		case "error":
+			var failure provider.ErrorBody
+			json.Unmarshal(data, &failure)
+			t.writeData(openAIError(failure.Code.Status(), failure.Error, string(failure.Code)))
+			t.writeData("[DONE]")
+			t.done = true
+		}
+	}
+}
+
+// finish translates a buffered response, or ends a stream that the chain
+// broke off.
+func (t *openAIWriter) finish() {
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	if t.events {
+		if !t.done {
+			t.writeData(openAIError(http.StatusInternalServerError, "the chain ended without a result", ""))
+			t.writeData("[DONE]")
+		}
+		return
+	}
+
+	body := t.buffer.Bytes()
+	if t.status != http.StatusOK {
+		var failure provider.ErrorBody
+		if json.Unmarshal(body, &failure) != nil || failure.Error == "" {
+			failure.Error = strings.TrimSpace(string(body))
+		}
+		t.copyHeader()
+		writeOpenAIError(t.w, t.status, failure.Error, string(failure.Code))
+		return
+	}
+
+	outputs := map[string]any{}
+	if err := json.Unmarshal(body, &outputs); err != nil {
+		t.copyHeader()
+		writeOpenAIError(t.w, http.StatusBadGateway, "unexpected response of the chain: "+err.Error(), "")
+		return
+	}
+	reply := replyText(outputs)
+	if t.stream {
+		// e.g. chains with guardrails, which respond with complete outputs
+		t.startStream()
+		t.endStream(reply)
+		return
+	}
+	t.copyHeader()
+	t.w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(t.w).Encode(map[string]any{
+		"id":      t.id,
+		"object":  "chat.completion",
+		"created": t.created,
+		"model":   t.model,
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]any{"role": "assistant", "content": reply},
+			"finish_reason": "stop",
+		}},
+	})
+}
+
+// copyHeader passes the headers of the chain response, such as the variant
+// of a canary, to the client, without those of its body.
+func (t *openAIWriter) copyHeader() {
+	for name, values := range t.header {
+		switch name {
+		case "Content-Type", "Content-Length", UsageHeader:
+			continue
+		}
+		t.w.Header()[name] = values
+	}
+}
+
+func (t *openAIWriter) startStream() {
+	t.copyHeader()
+	t.w.Header().Set("Content-Type", "text/event-stream")
+	t.w.Header().Set("Cache-Control", "no-cache")
+	t.w.WriteHeader(http.StatusOK)
+	t.writeChunk(map[string]any{"role": "assistant", "content": ""}, nil)
+}
+
+// endStream sends the reply if its tokens were not streamed, and ends the
+// stream.
+func (t *openAIWriter) endStream(reply string) {
+	if !t.streamed && reply != "" {
+		t.writeChunk(map[string]any{"content": reply}, nil)
+	}
+	stop := "stop"
+	t.writeChunk(map[string]any{}, &stop)
+	t.writeData("[DONE]")
+	t.done = true
+}
+
+func (t *openAIWriter) writeChunk(delta map[string]any, finishReason *string) {
+	t.writeData(map[string]any{
+		"id":      t.id,
+		"object":  "chat.completion.chunk",
+		"created": t.created,
+		"model":   t.model,
+		"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": finishReason}},
+	})
+}
+
+// writeData writes a server-sent event without a name, as the chat
+// completions API does, with value as JSON or the text "[DONE]".
+func (t *openAIWriter) writeData(value any) {
+	data, ok := value.(string)
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		data = string(encoded)
+	}
+	io.WriteString(t.w, "data: "+data+"\n\n")
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// openAIError returns an error body of the chat completions API. Code is the
+// code of a provider error or empty.
+func openAIError(status int, message string, code string) map[string]any {
+	kind := "invalid_request_error"
+	switch {
+	case status == http.StatusUnauthorized:
+		kind = "authentication_error"
+	case status == http.StatusTooManyRequests:
+		kind = "rate_limit_error"
+	case status >= 500:
+		kind = "server_error"
+	}
+	var errorCode any
+	if code != "" {
+		errorCode = code
+	}
+	return map[string]any{"error": map[string]any{"message": message, "type": kind, "param": nil, "code": errorCode}}
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message string, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(openAIError(status, message, code))
+}
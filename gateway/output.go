@@ -0,0 +1,235 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"langforge/provider"
+	"langforge/schema"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultRepairPrompt is appended to the input of a chain whose output did
+// not match its schema, unless the chain declares its own.
+const defaultRepairPrompt = `Your previous answer did not match the required format: {error}.
+Answer again with only a JSON value, without any other text, that matches this JSON schema:
+{schema}`
+
+// outputContract is the structured output that a chain declares, see
+// project.OutputConfig.
+type outputContract struct {
+	chain        string
+	schema       *schema.Schema
+	schemaJSON   string
+	key          string
+	retries      int
+	repairPrompt string
+}
+
+func newOutputContract(chain project.ChainConfig) (*outputContract, error) {
+	config := chain.Output
+	if len(config.Schema) == 0 {
+		return nil, fmt.Errorf("chain %s: output.schema is missing", chain.Name)
+	}
+	if config.Retries < 0 {
+		return nil, fmt.Errorf("chain %s: output.retries must not be negative", chain.Name)
+	}
+
+	// the schema is declared in YAML, so it is converted to the JSON that
+	// schemas are decoded from
+	data, err := json.Marshal(config.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("chain %s: invalid output.schema: %v", chain.Name, err)
+	}
+	var s schema.Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("chain %s: invalid output.schema: %v", chain.Name, err)
+	}
+
+	repairPrompt := config.RepairPrompt
+	if repairPrompt == "" {
+		repairPrompt = defaultRepairPrompt
+	}
+	return &outputContract{
+		chain:        chain.Name,
+		schema:       &s,
+		schemaJSON:   string(data),
+		key:          config.Key,
+		retries:      config.Retries,
+		repairPrompt: repairPrompt,
+	}, nil
+}
+
+// check validates the outputs of a chain and returns them with the output of
+// the key parsed.
+func (c *outputContract) check(outputs map[string]any) (map[string]any, string, error) {
+	if c.key == "" {
+		data, _ := json.Marshal(outputs)
+		return outputs, string(data), c.schema.ValidateAs("outputs", outputs)
+	}
+
+	value, ok := outputs[c.key]
+	if !ok {
+		return nil, "", fmt.Errorf("the chain has no output %s", c.key)
+	}
+	text, isText := value.(string)
+	if isText {
+		if err := json.Unmarshal([]byte(stripCodeFence(text)), &value); err != nil {
+			return nil, text, fmt.Errorf("the output is not valid JSON: %v", err)
+		}
+	} else {
+		data, _ := json.Marshal(value)
+		text = string(data)
+	}
+	if err := c.schema.ValidateAs(c.key, value); err != nil {
+		return nil, text, err
+	}
+
+	checked := map[string]any{}
+	for key, output := range outputs {
+		checked[key] = output
+	}
+	checked[c.key] = value
+	return checked, text, nil
+}
+
+// stripCodeFence returns the content of a Markdown code block around text,
+// which models often wrap JSON in.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < 6 {
+		return text
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "```"), "```")
+	// the language of the block, e.g. json
+	if newline := strings.Index(text, "\n"); newline >= 0 && !strings.ContainsAny(text[:newline], "{[\"") {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// repair returns the body of the request with the repair prompt appended to
+// the text input of the chain, or the body unchanged if the chain has no
+// text input.
+func (c *outputContract) repair(body []byte, output string, violation error) []byte {
+	var inputs map[string]any
+	if err := json.Unmarshal(body, &inputs); err != nil {
+		return body
+	}
+	keys := []string{}
+	for key, value := range inputs {
+		if _, ok := value.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	key := pickKey(keys, inputKeys)
+	if key == "" {
+		return body
+	}
+
+	prompt := strings.NewReplacer("{error}", violation.Error(), "{schema}", c.schemaJSON, "{output}", output).Replace(c.repairPrompt)
+	inputs[key] = inputs[key].(string) + "\n\n" + prompt
+	repaired, err := json.Marshal(inputs)
+	if err != nil {
+		return body
+	}
+	return repaired
+}
+
+// chainOutput returns the structured output that a chain declares, or nil.
+func (g *Gateway) chainOutput(name string) *outputContract {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.outputs[name]
+}
+
+// serveStructured proxies a chain request whose chain declares a structured
+// output and validates the outputs of the worker. Invalid outputs are retried
+// with the repair prompt of the chain; when the retries are used up, the
+// caller receives an invalid_output error. Like chains with guardrails, the
+// chain responds with its complete outputs.
+func (g *Gateway) serveStructured(w http.ResponseWriter, r *http.Request, body []byte, contract *outputContract, backend *Backend) {
+	if wantsStream(r) {
+		r.Header.Set("Accept", "application/json")
+	}
+
+	attempt := body
+	var violation error
+	for i := 0; i <= contract.retries; i++ {
+		response := newBufferedWriter()
+		request := r.Clone(r.Context())
+		request.Body = io.NopCloser(bytes.NewReader(attempt))
+		request.ContentLength = int64(len(attempt))
+		request.Header.Set("Content-Length", strconv.Itoa(len(attempt)))
+		backend.proxy.ServeHTTP(response, request)
+
+		var outputs map[string]any
+		if response.status != http.StatusOK || json.Unmarshal(response.body.Bytes(), &outputs) != nil {
+			// errors and rejected outputs are not the model's to repair
+			response.writeTo(w, response.body.Bytes())
+			return
+		}
+
+		checked, output, err := contract.check(outputs)
+		if err == nil {
+			data, err := json.Marshal(checked)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			response.writeTo(w, data)
+			return
+		}
+
+		violation = err
+		fmt.Printf("Output of chain %s does not match its schema (request %s, attempt %d of %d): %v\n", contract.chain, requestID(r), i+1, contract.retries+1, err)
+		attempt = contract.repair(body, output, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(provider.InvalidOutput.Status())
+	json.NewEncoder(w).Encode(&provider.ErrorBody{
+		Error: fmt.Sprintf("the output of chain %s does not match its schema: %v", contract.chain, violation),
+		Code:  provider.InvalidOutput,
+	})
+}
+
+// bufferedWriter keeps a response of the worker so that its outputs can be
+// checked before anything is sent to the client.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedWriter() *bufferedWriter {
+	return &bufferedWriter{header: http.Header{}, status: http.StatusOK}
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+// writeTo sends the buffered response to the client with the given body.
+func (w *bufferedWriter) writeTo(client http.ResponseWriter, body []byte) {
+	for key, values := range w.header {
+		client.Header()[key] = values
+	}
+	client.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	client.WriteHeader(w.status)
+	client.Write(body)
+}
@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+)
+
+// piiPattern detects one kind of personal data. If valid is set, a match is only
+// reported when valid returns true for it.
+type piiPattern struct {
+	kind  string
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+// piiPatterns are applied in order, so more specific patterns come first.
+var piiPatterns = []piiPattern{
+	{kind: "EMAIL", re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{kind: "CREDIT_CARD", re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), valid: luhn},
+	{kind: "SSN", re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{kind: "IP_ADDRESS", re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+	{kind: "PHONE", re: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[ .-])\d{3,4}[ .-]?\d{3,4}\b`)},
+}
+
+// DetectPII returns the kinds of personal data found in text.
+func DetectPII(text string) []string {
+	kinds := []string{}
+	for _, p := range piiPatterns {
+		for _, match := range p.re.FindAllString(text, -1) {
+			if p.valid == nil || p.valid(match) {
+				kinds = append(kinds, p.kind)
+				break
+			}
+		}
+	}
+	return kinds
+}
+
+// RedactPII replaces personal data in text with a placeholder such as [EMAIL].
+func RedactPII(text string) string {
+	for _, p := range piiPatterns {
+		p := p
+		text = p.re.ReplaceAllStringFunc(text, func(match string) string {
+			if p.valid != nil && !p.valid(match) {
+				return match
+			}
+			return "[" + p.kind + "]"
+		})
+	}
+	return text
+}
+
+// luhn reports whether the digits in s have a valid Luhn checksum.
+func luhn(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return len(digits) >= 13 && sum%10 == 0
+}
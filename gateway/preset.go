@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"net/http"
+)
+
+// PresetHeader selects a preset of the project for a chain request. The
+// response names the preset that applied, if any.
+const PresetHeader = "X-Langforge-Preset"
+
+// ParamsHeader carries the generation parameters of a preset to the worker as
+// base64 encoded JSON. It is removed from all incoming requests.
+const ParamsHeader = "X-Langforge-Params"
+
+// GenerationHeader overrides the temperature and the seed of a chain request
+// with JSON, e.g. {"temperature": 0, "seed": 42}, over those of its preset,
+// so that evaluations sample reproducibly without a preset of their own. Only
+// requests with an admin key may send it, others are rejected with 403.
+const GenerationHeader = "X-Langforge-Generation"
+
+// Generation is the value of the generation header.
+type Generation struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+}
+
+type presetKey struct{}
+
+// EncodePreset encodes the parameters of a preset for the params header.
+func EncodePreset(preset *project.PresetConfig) (string, error) {
+	return encodeParams(PresetParams(preset))
+}
+
+// PresetParams returns the generation parameters of a preset by the names
+// that the worker sets on the LLMs, e.g. max_tokens.
+func PresetParams(preset *project.PresetConfig) map[string]any {
+	params := map[string]any{}
+	if preset.Temperature != nil {
+		params["temperature"] = *preset.Temperature
+	}
+	if preset.MaxTokens != nil {
+		params["max_tokens"] = *preset.MaxTokens
+	}
+	if preset.TopP != nil {
+		params["top_p"] = *preset.TopP
+	}
+	if len(preset.Stop) > 0 {
+		params["stop"] = preset.Stop
+	}
+	if preset.Seed != nil {
+		params["seed"] = *preset.Seed
+	}
+	return params
+}
+
+// Apply sets the temperature and the seed of g on params.
+func (g *Generation) Apply(params map[string]any) {
+	if g.Temperature != nil {
+		params["temperature"] = *g.Temperature
+	}
+	if g.Seed != nil {
+		params["seed"] = *g.Seed
+	}
+}
+
+func encodeParams(params map[string]any) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// applyGeneration returns the encoded parameters of a preset, or an empty
+// string for none, with the overrides of the generation header.
+func applyGeneration(params string, header string) (string, error) {
+	var generation Generation
+	if err := json.Unmarshal([]byte(header), &generation); err != nil {
+		return "", fmt.Errorf("invalid %s header: %v", GenerationHeader, err)
+	}
+	if generation.Temperature != nil && *generation.Temperature < 0 {
+		return "", fmt.Errorf("the temperature of the %s header must not be negative", GenerationHeader)
+	}
+	decoded := map[string]any{}
+	if params != "" {
+		data, err := base64.StdEncoding.DecodeString(params)
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return "", err
+		}
+	}
+	generation.Apply(decoded)
+	return encodeParams(decoded)
+}
+
+// encodePresets validates the presets of config and the presets that its
+// chains use, and encodes them by name.
+func encodePresets(config *project.Config) (map[string]string, error) {
+	presets := map[string]string{}
+	for i := range config.Presets {
+		preset := &config.Presets[i]
+		if preset.Name == "" {
+			return nil, fmt.Errorf("presets need a name")
+		}
+		if _, ok := presets[preset.Name]; ok {
+			return nil, fmt.Errorf("preset %s is declared twice", preset.Name)
+		}
+		if preset.Temperature != nil && *preset.Temperature < 0 {
+			return nil, fmt.Errorf("the temperature of preset %s must not be negative", preset.Name)
+		}
+		if preset.MaxTokens != nil && *preset.MaxTokens <= 0 {
+			return nil, fmt.Errorf("the maxTokens of preset %s must be positive", preset.Name)
+		}
+		if preset.TopP != nil && (*preset.TopP <= 0 || *preset.TopP > 1) {
+			return nil, fmt.Errorf("the topP of preset %s must be greater than 0 and at most 1", preset.Name)
+		}
+		encoded, err := EncodePreset(preset)
+		if err != nil {
+			return nil, err
+		}
+		presets[preset.Name] = encoded
+	}
+
+	for _, chain := range config.Chains {
+		if _, ok := presets[chain.Preset]; chain.Preset != "" && !ok {
+			return nil, fmt.Errorf("chain %s uses preset %s, which is not declared", chain.Name, chain.Preset)
+		}
+	}
+	return presets, nil
+}
+
+// assignPreset picks the preset of a chain request, the one the client asked
+// for or the preset of the chain, and reports it in the response.
+func (g *Gateway) assignPreset(w http.ResponseWriter, r *http.Request) *http.Request {
+	preset := r.Header.Get(PresetHeader)
+	r.Header.Del(PresetHeader)
+	name := chainName(r)
+	if name == "" {
+		return r
+	}
+	if preset == "" {
+		g.mu.RLock()
+		preset = g.chainPresets[name]
+		g.mu.RUnlock()
+		if preset == "" {
+			return r
+		}
+	}
+	w.Header().Set(PresetHeader, preset)
+	return r.WithContext(context.WithValue(r.Context(), presetKey{}, preset))
+}
+
+// requestPreset returns the preset of a request, or an empty string if none
+// applies.
+func requestPreset(r *http.Request) string {
+	preset, _ := r.Context().Value(presetKey{}).(string)
+	return preset
+}
+
+// presetParams returns the encoded parameters of a preset.
+func (g *Gateway) presetParams(preset string) (string, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	params, ok := g.presets[preset]
+	return params, ok
+}
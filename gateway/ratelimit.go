@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitClients is the number of clients whose buckets are kept before
+// full buckets are discarded.
+const maxRateLimitClients = 10000
+
+// rateLimiter keeps a token bucket per client, see Gateway.clientID.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimitMiddleware creates the rate limit middleware. The options are
+// requestsPerMinute and burst, the number of requests a client may send at
+// once, which defaults to requestsPerMinute.
+func (g *Gateway) newRateLimitMiddleware(options map[string]string) (Middleware, error) {
+	requestsPerMinute, err := intOption(options, "requestsPerMinute", 0)
+	if err != nil {
+		return nil, err
+	}
+	if requestsPerMinute <= 0 {
+		return nil, fmt.Errorf("requestsPerMinute must be set to a positive number")
+	}
+	burst, err := intOption(options, "burst", requestsPerMinute)
+	if err != nil {
+		return nil, err
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("burst must be a positive number")
+	}
+
+	limiter := newRateLimiter(requestsPerMinute, burst)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wait := limiter.take(g.clientID(r)); wait > 0 {
+				writeRateLimited(w, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// newRateLimiter creates a rate limiter that lets each client send
+// requestsPerMinute requests per minute and burst requests at once.
+func newRateLimiter(requestsPerMinute int, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(requestsPerMinute) / 60,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+	}
+}
+
+// writeRateLimited rejects a request that exceeds a rate limit, telling the
+// client how long to wait.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+}
+
+// take takes a token from the bucket of a client. It returns zero if the
+// request may proceed, otherwise how long the client has to wait.
+func (l *rateLimiter) take(client string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b := l.buckets[client]
+	if b == nil {
+		if len(l.buckets) >= maxRateLimitClients {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune discards the buckets of clients that have been idle long enough for
+// their bucket to be full again.
+func (l *rateLimiter) prune(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// clientID identifies the client of a request for rate limiting: by the name
+// of its API key, also if the rate limiter runs before the auth middleware,
+// and by its IP address only if it has none. Behind a tunnel or a reverse
+// proxy, clients without a key share the address of the proxy, and so their
+// limit.
+func (g *Gateway) clientID(r *http.Request) string {
+	if client, ok := r.Context().Value(clientKey{}).(string); ok {
+		return client
+	}
+	if key, ok := g.lookupKey(r); ok && key != nil {
+		return key.name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// intOption parses an integer option of a middleware.
+func intOption(options map[string]string, name string, fallback int) (int, error) {
+	value, ok := options[name]
+	if !ok || value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number, got %q", name, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ReplayResult is the response of a replayed request.
+type ReplayResult struct {
+	Status   int
+	Response json.RawMessage
+	Duration time.Duration
+	// Matches is true if the status and the response equal the recorded ones.
+	Matches bool
+}
+
+// Replay sends a recorded request to the server at baseURL.
+func Replay(ctx context.Context, baseURL string, record *Record) (*ReplayResult, error) {
+	if record.Truncated {
+		return nil, fmt.Errorf("the recorded request was truncated")
+	}
+
+	// bodies that are not JSON objects are recorded as strings
+	body := []byte(record.Request)
+	var text string
+	if json.Unmarshal(record.Request, &text) == nil {
+		body = []byte(text)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, record.Method, strings.TrimSuffix(baseURL, "/")+record.Path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range record.Header {
+		req.Header.Set(key, value)
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplayResult{Status: resp.StatusCode, Duration: time.Since(start)}
+	result.Response, _ = sanitizeBody(data)
+	result.Matches = result.Status == record.Status && jsonEqual(result.Response, record.Response)
+	return result, nil
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
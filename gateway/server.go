@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"langforge/project"
+	"net/http"
+	"time"
+)
+
+// ListenAndServe serves the gateway with a server of NewServer, over TLS if
+// the server has a certificate.
+func ListenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// NewServer returns the HTTP server that serves handler on addr with the
+// settings of config: over TLS and HTTP/2 if a certificate is configured, with
+// the configured timeouts and keep-alive. Unlike the other settings of the
+// gateway, they are not reloaded.
+func NewServer(addr string, handler http.Handler, config project.ServerConfig) (*http.Server, error) {
+	server := &http.Server{Addr: addr, Handler: handler}
+	if config.TLSCert != "" || config.TLSKey != "" {
+		if config.TLSCert == "" || config.TLSKey == "" {
+			return nil, errors.New("gateway.server needs both tlsCert and tlsKey to serve HTTPS")
+		}
+		certificate, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the TLS certificate of the gateway: %v", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+	for _, timeout := range []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"readHeaderTimeout", config.ReadHeaderTimeout, &server.ReadHeaderTimeout},
+		{"readTimeout", config.ReadTimeout, &server.ReadTimeout},
+		{"writeTimeout", config.WriteTimeout, &server.WriteTimeout},
+		{"idleTimeout", config.IdleTimeout, &server.IdleTimeout},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid gateway.server.%s %q, use e.g. 30s", timeout.name, timeout.value)
+		}
+		*timeout.target = d
+	}
+
+	if config.HTTP2 != nil && !*config.HTTP2 {
+		// a non-nil empty map turns off HTTP/2
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	if config.KeepAlive != nil {
+		server.SetKeepAlivesEnabled(*config.KeepAlive)
+	}
+	return server, nil
+}
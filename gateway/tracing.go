@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader identifies a request in the access log of the gateway, in
+// the response to the client and in the metadata of the traces of its chain,
+// e.g. in LangSmith. Clients may send their own ID; otherwise the gateway
+// assigns a UUID.
+const RequestIDHeader = "X-Request-Id"
+
+// TraceparentHeader carries the W3C trace context of a request. The gateway
+// continues the trace of an incoming traceparent, or starts one, and passes
+// its own span on to the worker.
+const TraceparentHeader = "Traceparent"
+
+// maxRequestIDLength limits the length of the request IDs of clients.
+const maxRequestIDLength = 128
+
+// Trace identifies a request and the distributed trace it belongs to.
+type Trace struct {
+	RequestID string
+	// TraceID and SpanID are lowercase hex, 32 and 16 characters long. SpanID
+	// is the span of the gateway, the parent of the spans of the worker.
+	TraceID string
+	SpanID  string
+	Flags   string
+}
+
+// Traceparent returns the traceparent header that passes the trace on.
+func (t *Trace) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%s", t.TraceID, t.SpanID, t.Flags)
+}
+
+type traceKey struct{}
+
+// RequestTrace returns the trace of a request that the gateway serves, or nil
+// for other requests.
+func RequestTrace(r *http.Request) *Trace {
+	trace, _ := r.Context().Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// requestID returns the request ID of a request for messages, or "-".
+func requestID(r *http.Request) string {
+	if trace := RequestTrace(r); trace != nil {
+		return trace.RequestID
+	}
+	return "-"
+}
+
+// startTrace assigns a request its trace, sets the headers that pass it on to
+// the worker and tells the client the request ID.
+func startTrace(w http.ResponseWriter, r *http.Request) *http.Request {
+	trace := &Trace{
+		RequestID: r.Header.Get(RequestIDHeader),
+		SpanID:    randomHex(8),
+		Flags:     "01",
+	}
+	if !validRequestID(trace.RequestID) {
+		trace.RequestID = newUUID()
+	}
+	if traceID, flags, ok := parseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+		trace.TraceID, trace.Flags = traceID, flags
+	} else {
+		// the trace state belongs to the trace of the traceparent
+		r.Header.Del("Tracestate")
+		trace.TraceID = randomHex(16)
+	}
+
+	r.Header.Set(RequestIDHeader, trace.RequestID)
+	r.Header.Set(TraceparentHeader, trace.Traceparent())
+	w.Header().Set(RequestIDHeader, trace.RequestID)
+	return r.WithContext(context.WithValue(r.Context(), traceKey{}, trace))
+}
+
+// validRequestID accepts IDs of printable ASCII characters without spaces, so
+// that they cannot forge lines of the access log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceparent returns the trace ID and the flags of a traceparent header
+// of version 00.
+func parseTraceparent(header string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", "", false
+	}
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) {
+		return "", "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", "", false
+	}
+	return traceID, flags, true
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newUUID returns a random UUID, the format of the run IDs of LangSmith.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The types of the transforms of chain outputs, see project.TransformConfig.
+const (
+	TransformMarkdown   = "markdown"
+	TransformCitations  = "citations"
+	TransformProfanity  = "profanity"
+	TransformWhitespace = "whitespace"
+)
+
+// defaultProfanity are the words that the profanity transform masks unless it
+// lists its own.
+var defaultProfanity = []string{
+	"asshole", "assholes", "bastard", "bastards", "bitch", "bitches", "bullshit",
+	"cunt", "cunts", "dick", "dickhead", "fuck", "fucked", "fucker", "fuckers",
+	"fucking", "fucks", "goddamn", "motherfucker", "motherfuckers", "shit",
+	"shits", "shitty", "wanker",
+}
+
+var (
+	// citationPattern matches Markdown links and citations such as
+	// [docs/guide.md], which are links without a target.
+	citationPattern = regexp.MustCompile(`\[([^\[\]\n]+)\](\(([^()\s]*)\))?`)
+	// hrefPattern matches the targets of HTML links, e.g. those of the
+	// markdown transform.
+	hrefPattern     = regexp.MustCompile(`href="([^"]*)"`)
+	blankRunPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// transformStep is a transform of the text outputs of a chain.
+type transformStep struct {
+	// outputs are the outputs that the step applies to, all if nil.
+	outputs map[string]bool
+	apply   func(string) string
+}
+
+// transformPipeline post-processes the outputs of a chain with its
+// transforms in order.
+type transformPipeline struct {
+	chain string
+	steps []transformStep
+}
+
+func newTransformPipeline(chain project.ChainConfig) (*transformPipeline, error) {
+	p := &transformPipeline{chain: chain.Name}
+	for i, config := range chain.Transforms {
+		step := transformStep{}
+		if len(config.Outputs) > 0 {
+			step.outputs = map[string]bool{}
+			for _, output := range config.Outputs {
+				step.outputs[output] = true
+			}
+		}
+
+		switch config.Type {
+		case TransformMarkdown:
+			step.apply = markdownToHTML
+		case TransformCitations:
+			if len(config.Links) == 0 {
+				return nil, fmt.Errorf("chain %s: transform %d: the citations transform needs links", chain.Name, i+1)
+			}
+			step.apply = newCitationRewriter(config.Links)
+		case TransformProfanity:
+			words := config.Words
+			if len(words) == 0 {
+				words = defaultProfanity
+			}
+			step.apply = newProfanityMask(words)
+		case TransformWhitespace:
+			step.apply = cleanWhitespace
+		default:
+			return nil, fmt.Errorf("chain %s: unsupported transform %q, expected %s, %s, %s or %s", chain.Name, config.Type, TransformMarkdown, TransformCitations, TransformProfanity, TransformWhitespace)
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// apply transforms the outputs in place.
+func (p *transformPipeline) apply(outputs map[string]any) {
+	for _, step := range p.steps {
+		if step.outputs == nil {
+			eachString(outputs, step.apply)
+			continue
+		}
+		selected := map[string]any{}
+		for key := range step.outputs {
+			if value, ok := outputs[key]; ok {
+				selected[key] = value
+			}
+		}
+		eachString(selected, step.apply)
+		for key, value := range selected {
+			outputs[key] = value
+		}
+	}
+}
+
+// transformBody returns the body of a chain response with its outputs
+// transformed. Errors and bodies that are not JSON objects are returned
+// unchanged.
+func (p *transformPipeline) transformBody(status int, body []byte) []byte {
+	var outputs map[string]any
+	if status != http.StatusOK || json.Unmarshal(body, &outputs) != nil {
+		return body
+	}
+	p.apply(outputs)
+
+	// the outputs of the markdown transform are HTML, which is kept readable
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(outputs); err != nil {
+		return body
+	}
+	return bytes.TrimSpace(buffer.Bytes())
+}
+
+// chainTransforms returns the transforms of a chain, or nil.
+func (g *Gateway) chainTransforms(name string) *transformPipeline {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.transforms[name]
+}
+
+// newCitationRewriter returns a transform that replaces the prefixes of the
+// targets of links and citations with those of links, the longest prefix
+// first. Citations whose target is rewritten become links.
+func newCitationRewriter(links map[string]string) func(string) string {
+	prefixes := make([]string, 0, len(links))
+	for prefix := range links {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	rewrite := func(target string) (string, bool) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(target, prefix) {
+				return links[prefix] + strings.TrimPrefix(target, prefix), true
+			}
+		}
+		return target, false
+	}
+
+	return func(text string) string {
+		text = citationPattern.ReplaceAllStringFunc(text, func(match string) string {
+			parts := citationPattern.FindStringSubmatch(match)
+			label, isLink, target := parts[1], parts[2] != "", parts[3]
+			if !isLink {
+				target = label
+			}
+			rewritten, ok := rewrite(target)
+			if !ok {
+				return match
+			}
+			return "[" + label + "](" + rewritten + ")"
+		})
+		return hrefPattern.ReplaceAllStringFunc(text, func(match string) string {
+			target := hrefPattern.FindStringSubmatch(match)[1]
+			rewritten, _ := rewrite(target)
+			return `href="` + rewritten + `"`
+		})
+	}
+}
+
+// newProfanityMask returns a transform that replaces the words, in any case,
+// with as many asterisks as they have letters.
+func newProfanityMask(words []string) func(string) string {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return func(text string) string { return text }
+	}
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	return func(text string) string {
+		return pattern.ReplaceAllStringFunc(text, func(word string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(word))
+		})
+	}
+}
+
+// cleanWhitespace removes trailing whitespace from the lines of text, keeps at
+// most one blank line between paragraphs and trims the text.
+func cleanWhitespace(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	text = blankRunPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -5,9 +5,12 @@ go 1.19
 require (
 	atomicgo.dev/keyboard v0.2.9
 	github.com/AlecAivazis/survey/v2 v2.3.6
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/joho/godotenv v1.5.1
 	github.com/pterm/pterm v0.12.55
 	github.com/spf13/cobra v1.6.1
+	golang.org/x/sys v0.6.0
+	golang.org/x/term v0.5.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -25,7 +28,5 @@ require (
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/term v0.5.0 // indirect
 	golang.org/x/text v0.8.0 // indirect
 )
@@ -0,0 +1,234 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"langforge/state"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Job states.
+const (
+	Queued      = "queued"
+	Running     = "running"
+	Succeeded   = "succeeded"
+	Failed      = "failed"
+	Interrupted = "interrupted"
+)
+
+// Job is a long-running operation that processes a list of items in batches.
+// Its progress is persisted after every batch so that an interrupted job can
+// be resumed where it stopped.
+type Job struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`
+	Status    string            `json:"status"`
+	Items     []string          `json:"items"`
+	Done      int               `json:"done"`
+	BatchSize int               `json:"batchSize"`
+	Params    map[string]string `json:"params,omitempty"`
+	PID       int               `json:"pid,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Created   time.Time         `json:"created"`
+	Started   *time.Time        `json:"started,omitempty"`
+	Finished  *time.Time        `json:"finished,omitempty"`
+}
+
+// Handler processes a batch of items of a job and writes its output to log.
+type Handler func(dir string, job *Job, batch []string, log io.Writer) error
+
+var handlers = map[string]Handler{}
+
+// Register registers the handler for jobs of the given kind.
+func Register(kind string, handler Handler) {
+	handlers[kind] = handler
+}
+
+// collection is the state collection that holds the jobs.
+const collection = "jobs"
+
+// Dir returns the directory that holds the logs of the jobs of the project in dir.
+func Dir(dir string) string {
+	return filepath.Join(project.StateDir(dir), "jobs")
+}
+
+func jobDir(dir string, id string) string {
+	return filepath.Join(Dir(dir), id)
+}
+
+// LogPath returns the path of the log file of a job.
+func LogPath(dir string, id string) string {
+	return filepath.Join(jobDir(dir, id), "job.log")
+}
+
+// Enqueue creates a queued job of the given kind for the project in dir.
+func Enqueue(dir string, kind string, items []string, batchSize int, params map[string]string) (*Job, error) {
+	if _, ok := handlers[kind]; !ok {
+		return nil, fmt.Errorf("unknown job kind %q", kind)
+	}
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	suffix := make([]byte, 2)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:        fmt.Sprintf("%s-%s-%s", kind, now.Format("20060102-150405"), hex.EncodeToString(suffix)),
+		Kind:      kind,
+		Status:    Queued,
+		Items:     items,
+		BatchSize: batchSize,
+		Params:    params,
+		Created:   now,
+	}
+
+	if err := os.MkdirAll(jobDir(dir, job.ID), 0755); err != nil {
+		return nil, err
+	}
+	return job, Save(dir, job)
+}
+
+// Save writes the job to the project's state store.
+func Save(dir string, job *Job) error {
+	store, err := state.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Put(collection, job.ID, job)
+}
+
+// Load reads the job with the given id. A running job whose process no longer
+// exists is reported as interrupted.
+func Load(dir string, id string) (*Job, error) {
+	store, err := state.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	job := &Job{}
+	if err := store.Get(collection, id, job); err != nil {
+		if err == state.ErrNotFound {
+			return nil, fmt.Errorf("job %s not found", id)
+		}
+		return nil, fmt.Errorf("failed to read job %s: %v", id, err)
+	}
+
+	checkAlive(job)
+	return job, nil
+}
+
+// List returns the jobs of the project in dir, most recent first.
+func List(dir string) ([]*Job, error) {
+	store, err := state.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	records, err := store.List(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*Job{}
+	for _, record := range records {
+		job := &Job{}
+		if err := json.Unmarshal(record.Value, job); err != nil {
+			continue
+		}
+		checkAlive(job)
+		list = append(list, job)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.After(list[j].Created)
+	})
+	return list, nil
+}
+
+// checkAlive reports a running job whose process no longer exists as interrupted.
+func checkAlive(job *Job) {
+	if job.Status == Running && !processAlive(job.PID) {
+		job.Status = Interrupted
+	}
+}
+
+// Run processes the remaining items of the job and calls onProgress after each
+// batch. Output of the handler is appended to the job's log file.
+func Run(dir string, job *Job, onProgress func(*Job)) error {
+	handler, ok := handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+
+	switch job.Status {
+	case Succeeded:
+		return fmt.Errorf("job %s has already finished", job.ID)
+	case Running:
+		return fmt.Errorf("job %s is already running in process %d", job.ID, job.PID)
+	}
+
+	log, err := os.OpenFile(LogPath(dir, job.ID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	now := time.Now()
+	if job.Started == nil {
+		job.Started = &now
+	} else {
+		fmt.Fprintf(log, "--- resumed at item %d of %d ---\n", job.Done+1, len(job.Items))
+	}
+	job.Status = Running
+	job.PID = os.Getpid()
+	job.Error = ""
+	job.Finished = nil
+	if err := Save(dir, job); err != nil {
+		return err
+	}
+
+	for job.Done < len(job.Items) {
+		end := job.Done + job.BatchSize
+		if end > len(job.Items) {
+			end = len(job.Items)
+		}
+
+		if err := handler(dir, job, job.Items[job.Done:end], log); err != nil {
+			finished := time.Now()
+			job.Status = Failed
+			job.Error = err.Error()
+			job.Finished = &finished
+			fmt.Fprintf(log, "--- failed: %v ---\n", err)
+			Save(dir, job)
+			return err
+		}
+
+		job.Done = end
+		if err := Save(dir, job); err != nil {
+			return err
+		}
+		if onProgress != nil {
+			onProgress(job)
+		}
+	}
+
+	finished := time.Now()
+	job.Status = Succeeded
+	job.Finished = &finished
+	return Save(dir, job)
+}
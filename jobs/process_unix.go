@@ -0,0 +1,20 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given id exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package jobs
+
+import "os"
+
+// processAlive reports whether a process with the given id exists. On Windows
+// FindProcess fails for processes that have exited.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
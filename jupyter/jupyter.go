@@ -0,0 +1,331 @@
+package jupyter
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"langforge/system"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// KernelPackage is the Python package that runs the kernels of Python
+// environments.
+const KernelPackage = "ipykernel"
+
+// Kernel is a kernel spec of the user, a kernel.json in a directory of the
+// kernels of Jupyter that is named like the kernel.
+type Kernel struct {
+	Name        string
+	DisplayName string
+	Language    string
+	// Python is the interpreter that the kernel runs, if it is a Python
+	// kernel.
+	Python string
+	// Project is the directory of the project whose environment the kernel
+	// runs, if langforge registered it.
+	Project string
+	// Dir is the directory of the kernel spec.
+	Dir string
+}
+
+// Missing reports whether the interpreter of the kernel no longer exists,
+// e.g. because its environment was removed.
+func (k *Kernel) Missing() bool {
+	if k.Python == "" {
+		return false
+	}
+	_, err := os.Stat(k.Python)
+	return err != nil
+}
+
+// Options configures the kernel that Install registers.
+type Options struct {
+	// Name is the name of the kernel, KernelName of the project by default.
+	Name string
+	// DisplayName is the name of the kernel in JupyterLab, "Python (name)"
+	// with the name of the project by default.
+	DisplayName string
+}
+
+// kernelSpec is the kernel.json of a kernel, with the fields that Jupyter
+// defines.
+type kernelSpec struct {
+	Argv        []string          `json:"argv"`
+	DisplayName string            `json:"display_name"`
+	Language    string            `json:"language"`
+	Env         map[string]string `json:"env,omitempty"`
+	Metadata    map[string]any    `json:"metadata,omitempty"`
+}
+
+// DataDir returns the Jupyter data directory of the user, which has the
+// kernels that all Jupyter installations of the user find: JUPYTER_DATA_DIR
+// or the default of the system.
+func DataDir() (string, error) {
+	if dir := os.Getenv("JUPYTER_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return filepath.Join(dir, "jupyter"), nil
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Jupyter"), nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "jupyter"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "jupyter"), nil
+}
+
+func kernelsDir() (string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kernels"), nil
+}
+
+// KernelName returns the default name of the kernel of a project, which
+// Jupyter restricts to letters, digits, dots, dashes and underscores and
+// compares case-insensitively.
+func KernelName(projectName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, projectName)
+	name = strings.Trim(name, "-.")
+	if name == "" {
+		name = "app"
+	}
+	return "langforge-" + name
+}
+
+func validName(name string) bool {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Install registers a kernel of the environment of the project in dir, its
+// virtual environment in .venv or conda environment in .conda, with the user's
+// Jupyter, and installs ipykernel into the environment unless it has it. The
+// kernel runs the interpreter of the environment with its executables in the
+// PATH and the IPython startup scripts of the project. Installing a kernel
+// with the name of an existing one replaces it.
+func Install(dir string, options Options) (*Kernel, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	envDir, err := python.ActivateProjectEnvironment(dir)
+	if err != nil {
+		return nil, err
+	}
+	if envDir == "" {
+		return nil, fmt.Errorf("the project has no environment in %s or %s, create it with 'langforge create'", python.VirtualEnvName, python.CondaEnvName)
+	}
+
+	projectName := filepath.Base(dir)
+	if config, err := project.LoadConfig(dir); err == nil && config.Name != "" {
+		projectName = config.Name
+	}
+	if options.Name == "" {
+		options.Name = KernelName(projectName)
+	}
+	if !validName(options.Name) {
+		return nil, fmt.Errorf("invalid kernel name %q, use letters, digits, dots, dashes and underscores", options.Name)
+	}
+	if options.DisplayName == "" {
+		options.DisplayName = fmt.Sprintf("Python (%s)", projectName)
+	}
+
+	if err := ensureKernelPackage(); err != nil {
+		return nil, err
+	}
+	pythonPath, err := system.FindPython()
+	if err != nil {
+		return nil, err
+	}
+
+	var binPaths []string
+	if system.IsCondaEnv(envDir) {
+		binPaths = system.CondaPaths(envDir)
+	} else {
+		venv, err := system.OpenVenv(envDir)
+		if err != nil {
+			return nil, err
+		}
+		binPaths = venv.BinPaths()
+	}
+	spec := kernelSpec{
+		Argv:        []string{pythonPath, "-m", "ipykernel_launcher", "-f", "{connection_file}"},
+		DisplayName: options.DisplayName,
+		Language:    "python",
+		// Jupyter expands ${PATH} to the PATH of the server
+		Env: map[string]string{
+			"PATH": strings.Join(append(binPaths, "${PATH}"), string(os.PathListSeparator)),
+		},
+		Metadata: map[string]any{
+			"debugger":  true,
+			"langforge": map[string]string{"project": dir, "environment": envDir},
+		},
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".ipython")); err == nil {
+		spec.Env["IPYTHONDIR"] = filepath.Join(dir, ".ipython")
+	}
+
+	kernels, err := kernelsDir()
+	if err != nil {
+		return nil, err
+	}
+	kernelDir := filepath.Join(kernels, strings.ToLower(options.Name))
+	if err := os.MkdirAll(kernelDir, 0755); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(kernelDir, "kernel.json"), data, 0644); err != nil {
+		return nil, err
+	}
+	return readKernel(kernelDir)
+}
+
+// ensureKernelPackage installs ipykernel into the active environment unless
+// it has it.
+func ensureKernelPackage() error {
+	installed, err := python.GetInstalledPackages()
+	if err != nil {
+		return err
+	}
+	for _, p := range installed {
+		if strings.EqualFold(p.Name, KernelPackage) {
+			return nil
+		}
+	}
+	fmt.Printf("Installing %s...\n", KernelPackage)
+	return python.InstallPackages([]string{KernelPackage})
+}
+
+// List returns the kernels of the user, sorted by name.
+func List() ([]*Kernel, error) {
+	kernels, err := kernelsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(kernels)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []*Kernel{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		kernel, err := readKernel(filepath.Join(kernels, entry.Name()))
+		if err != nil {
+			// not a kernel spec, Jupyter ignores it too
+			continue
+		}
+		result = append(result, kernel)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// Find returns the kernel of the user with the given name, or nil if there
+// is none.
+func Find(name string) (*Kernel, error) {
+	kernels, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, kernel := range kernels {
+		if strings.EqualFold(kernel.Name, name) {
+			return kernel, nil
+		}
+	}
+	return nil, nil
+}
+
+// ForProject returns the kernels that langforge registered for the project
+// in dir.
+func ForProject(dir string) ([]*Kernel, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	kernels, err := List()
+	if err != nil {
+		return nil, err
+	}
+	result := []*Kernel{}
+	for _, kernel := range kernels {
+		if kernel.Project != "" && filepath.Clean(kernel.Project) == dir {
+			result = append(result, kernel)
+		}
+	}
+	return result, nil
+}
+
+// Remove removes the kernel of the user with the given name.
+func Remove(name string) error {
+	kernel, err := Find(name)
+	if err != nil {
+		return err
+	}
+	if kernel == nil {
+		return fmt.Errorf("no kernel named %s", name)
+	}
+	return os.RemoveAll(kernel.Dir)
+}
+
+func readKernel(kernelDir string) (*Kernel, error) {
+	data, err := os.ReadFile(filepath.Join(kernelDir, "kernel.json"))
+	if err != nil {
+		return nil, err
+	}
+	spec := kernelSpec{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid kernel spec %s: %v", kernelDir, err)
+	}
+	kernel := &Kernel{
+		Name:        filepath.Base(kernelDir),
+		DisplayName: spec.DisplayName,
+		Language:    spec.Language,
+		Dir:         kernelDir,
+	}
+	if kernel.Language == "python" && len(spec.Argv) > 0 && filepath.IsAbs(spec.Argv[0]) {
+		kernel.Python = spec.Argv[0]
+	}
+	if metadata, ok := spec.Metadata["langforge"].(map[string]any); ok {
+		kernel.Project, _ = metadata["project"].(string)
+	}
+	return kernel, nil
+}
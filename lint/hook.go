@@ -0,0 +1,35 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const hookMarker = "# installed by langforge"
+
+const hookScript = `#!/bin/sh
+` + hookMarker + `
+exec langforge lint
+`
+
+// InstallHook installs a git pre-commit hook in the repository at dir that runs
+// "langforge lint". An existing hook that was not installed by langforge is
+// left untouched.
+func InstallHook(dir string) (string, error) {
+	gitDir := filepath.Join(dir, ".git")
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not the root of a git repository", dir)
+	}
+
+	path := filepath.Join(gitDir, "hooks", "pre-commit")
+	if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), hookMarker) {
+		return "", fmt.Errorf("a pre-commit hook already exists at %s, add \"langforge lint\" to it manually", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(hookScript), 0755)
+}
@@ -0,0 +1,160 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"langforge/python"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Tool describes how to run a linter or formatter in check and fix mode.
+type Tool struct {
+	Name string
+	// Python tools are run as modules of the project's interpreter and are
+	// installed on demand, the other tools are run with npx.
+	Python bool
+	Check  []string
+	Fix    []string
+}
+
+// Tools are the supported linters and formatters.
+var Tools = map[string]Tool{
+	"ruff":     {Name: "ruff", Python: true, Check: []string{"check"}, Fix: []string{"check", "--fix"}},
+	"black":    {Name: "black", Python: true, Check: []string{"--check"}, Fix: []string{}},
+	"eslint":   {Name: "eslint", Check: []string{}, Fix: []string{"--fix"}},
+	"prettier": {Name: "prettier", Check: []string{"--check"}, Fix: []string{"--write"}},
+}
+
+// Result is the outcome of running a tool.
+type Result struct {
+	Tool     string
+	Output   string
+	Duration time.Duration
+	Err      error
+}
+
+// OK reports whether the tool found no problems.
+func (r *Result) OK() bool {
+	return r.Err == nil
+}
+
+// Detect returns the tools to run for the project in dir: ruff and black for
+// Python projects, and eslint and prettier if package.json depends on them.
+func Detect(dir string) []string {
+	tools := []string{}
+
+	if hasPythonFiles(dir) {
+		tools = append(tools, "ruff", "black")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err == nil {
+		var pkg struct {
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			for _, name := range []string{"eslint", "prettier"} {
+				_, dev := pkg.DevDependencies[name]
+				_, dep := pkg.Dependencies[name]
+				if dev || dep {
+					tools = append(tools, name)
+				}
+			}
+		}
+	}
+
+	return tools
+}
+
+func hasPythonFiles(dir string) bool {
+	for _, pattern := range []string{"*.py", "*/*.py", "pyproject.toml", "requirements.txt"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			for _, match := range matches {
+				if slashed := filepath.ToSlash(match); !strings.Contains(slashed, "/.venv/") && !strings.Contains(slashed, "/.conda/") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// EnsureTools installs the Python tools among names that are missing.
+func EnsureTools(names []string) error {
+	packages, err := python.GetInstalledPackages()
+	if err != nil {
+		return err
+	}
+
+	installed := map[string]bool{}
+	for _, p := range packages {
+		installed[strings.ToLower(p.Name)] = true
+	}
+
+	missing := []string{}
+	for _, name := range names {
+		tool, ok := Tools[name]
+		if ok && tool.Python && !installed[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Installing %v...\n", missing)
+	return python.InstallPackages(missing)
+}
+
+// Run runs the tool on the given paths of the project in dir and captures its
+// output. With fix set, problems are fixed and files are formatted in place.
+func Run(dir string, name string, paths []string, fix bool) *Result {
+	result := &Result{Tool: name}
+
+	tool, ok := Tools[name]
+	if !ok {
+		result.Err = fmt.Errorf("unsupported linter %q", name)
+		return result
+	}
+
+	args := tool.Check
+	if fix {
+		args = tool.Fix
+	}
+	args = append(append([]string{}, args...), paths...)
+
+	var cmd *exec.Cmd
+	if tool.Python {
+		pythonPath, err := system.FindPython()
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		cmd = exec.Command(pythonPath, append([]string{"-m", tool.Name}, args...)...)
+	} else {
+		npx, err := exec.LookPath("npx")
+		if err != nil {
+			result.Err = fmt.Errorf("npx not found, install Node.js to run %s", tool.Name)
+			return result
+		}
+		cmd = exec.Command(npx, append([]string{"--no-install", tool.Name}, args...)...)
+	}
+
+	var output bytes.Buffer
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	result.Err = cmd.Run()
+	result.Duration = time.Since(start)
+	result.Output = strings.TrimSpace(output.String())
+	return result
+}
@@ -0,0 +1,452 @@
+package mockllm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the file of canned responses in the project directory.
+const FileName = "llm-mocks.yaml"
+
+// APIKey is the API key that clients of the mock server are configured with.
+const APIKey = "sk-langforge-mock"
+
+// embeddingDimensions is the length of mocked embeddings, the length of
+// OpenAI's text-embedding-ada-002 embeddings.
+const embeddingDimensions = 1536
+
+// Mock is a canned response for a prompt. The prompt is either given as text
+// or as the hash that the mock server logs for prompts without a response.
+type Mock struct {
+	Prompt   string `yaml:"prompt,omitempty"`
+	Hash     string `yaml:"hash,omitempty"`
+	Response string `yaml:"response"`
+}
+
+// Hash returns the key of a prompt. The prompt of a chat request consists of
+// its messages, one per line and prefixed with the role, e.g.
+// "system: You are a pirate.\nuser: Hello".
+func Hash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:12])
+}
+
+// Load reads the canned responses of the project in dir, keyed by prompt
+// hash. A missing file has no responses.
+func Load(dir string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	mocks := []Mock{}
+	if err := yaml.Unmarshal(data, &mocks); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", FileName, err)
+	}
+	responses := map[string]string{}
+	for _, mock := range mocks {
+		hash := mock.Hash
+		if mock.Prompt != "" {
+			hash = Hash(mock.Prompt)
+		}
+		if hash == "" {
+			return nil, fmt.Errorf("a mock in %s has neither a prompt nor a hash", FileName)
+		}
+		responses[hash] = mock.Response
+	}
+	return responses, nil
+}
+
+// Server is an OpenAI compatible API on the loopback interface that answers
+// completions with canned responses and embeddings with deterministic
+// vectors, so that applications run offline and reproducibly.
+type Server struct {
+	dir      string
+	listener net.Listener
+	server   *http.Server
+}
+
+// Start starts a mock server for the project in dir on a free port. The
+// responses are read from the project for each request, so that they can be
+// edited while the server runs.
+func Start(dir string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{dir: dir, listener: listener}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("/v1/completions", s.completions)
+	mux.HandleFunc("/v1/embeddings", s.embeddings)
+	mux.HandleFunc("/v1/models", s.models)
+	s.server = &http.Server{Handler: mux}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// URL returns the base URL of the API, to be used as OPENAI_API_BASE.
+func (s *Server) URL() string {
+	return fmt.Sprintf("http://%s/v1", s.listener.Addr().String())
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// Env returns the environment variables that point OpenAI clients of
+// LangChain and LangChain.js to the server.
+func (s *Server) Env() map[string]string {
+	return map[string]string{
+		"OPENAI_API_BASE": s.URL(),
+		"OPENAI_BASE_URL": s.URL(),
+		"OPENAI_API_KEY":  APIKey,
+	}
+}
+
+// respond returns the canned response for a prompt, or a placeholder that
+// names the hash of the prompt.
+func (s *Server) respond(prompt string) (string, error) {
+	responses, err := Load(s.dir)
+	if err != nil {
+		return "", err
+	}
+	hash := Hash(prompt)
+	if response, ok := responses[hash]; ok {
+		return response, nil
+	}
+	fmt.Printf("Mock LLM: no response in %s for prompt %s: %s\n", FileName, hash, summarize(prompt))
+	return "Mock response to prompt " + hash, nil
+}
+
+// summarize shortens a prompt to its first line for logging.
+func summarize(prompt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if len(line) > 80 {
+		line = line[:77] + "..."
+	}
+	return line
+}
+
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content any    `json:"content"`
+	} `json:"messages"`
+	N      int  `json:"n"`
+	Stream bool `json:"stream"`
+}
+
+// chatPrompt joins the messages of a chat request into its prompt.
+func chatPrompt(request *chatRequest) string {
+	lines := []string{}
+	for _, message := range request.Messages {
+		content, ok := message.Content.(string)
+		if !ok {
+			data, _ := json.Marshal(message.Content)
+			content = string(data)
+		}
+		lines = append(lines, message.Role+": "+content)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	request := &chatRequest{}
+	if !decode(w, r, request) {
+		return
+	}
+	prompt := chatPrompt(request)
+	response, err := s.respond(prompt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	n := request.N
+	if n < 1 {
+		n = 1
+	}
+
+	if request.Stream {
+		stream := newEventStream(w)
+		for i := 0; i < n; i++ {
+			stream.send(chunk(request.Model, "chat.completion.chunk", map[string]any{
+				"index": i, "delta": map[string]any{"role": "assistant", "content": ""}, "finish_reason": nil,
+			}))
+			for _, token := range tokens(response) {
+				stream.send(chunk(request.Model, "chat.completion.chunk", map[string]any{
+					"index": i, "delta": map[string]any{"content": token}, "finish_reason": nil,
+				}))
+			}
+			stream.send(chunk(request.Model, "chat.completion.chunk", map[string]any{
+				"index": i, "delta": map[string]any{}, "finish_reason": "stop",
+			}))
+		}
+		stream.done()
+		return
+	}
+
+	choices := []any{}
+	for i := 0; i < n; i++ {
+		choices = append(choices, map[string]any{
+			"index":         i,
+			"message":       map[string]any{"role": "assistant", "content": response},
+			"finish_reason": "stop",
+		})
+	}
+	writeJSON(w, map[string]any{
+		"id":      "chatcmpl-mock",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   request.Model,
+		"choices": choices,
+		"usage":   usage([]string{prompt}, strings.Repeat(response+" ", n)),
+	})
+}
+
+type completionRequest struct {
+	Model  string `json:"model"`
+	Prompt any    `json:"prompt"`
+	N      int    `json:"n"`
+	Stream bool   `json:"stream"`
+}
+
+// prompts returns the prompts of a completion request, which may be a string
+// or a list of strings.
+func (request *completionRequest) prompts() []string {
+	switch prompt := request.Prompt.(type) {
+	case string:
+		return []string{prompt}
+	case []any:
+		prompts := []string{}
+		for _, p := range prompt {
+			if text, ok := p.(string); ok {
+				prompts = append(prompts, text)
+			} else {
+				data, _ := json.Marshal(p)
+				prompts = append(prompts, string(data))
+			}
+		}
+		return prompts
+	}
+	data, _ := json.Marshal(request.Prompt)
+	return []string{string(data)}
+}
+
+func (s *Server) completions(w http.ResponseWriter, r *http.Request) {
+	request := &completionRequest{}
+	if !decode(w, r, request) {
+		return
+	}
+	n := request.N
+	if n < 1 {
+		n = 1
+	}
+
+	prompts := request.prompts()
+	responses := []string{}
+	for _, prompt := range prompts {
+		response, err := s.respond(prompt)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		responses = append(responses, response)
+	}
+
+	if request.Stream {
+		stream := newEventStream(w)
+		for p, response := range responses {
+			for i := 0; i < n; i++ {
+				for _, token := range tokens(response) {
+					stream.send(chunk(request.Model, "text_completion", map[string]any{
+						"index": p*n + i, "text": token, "logprobs": nil, "finish_reason": nil,
+					}))
+				}
+				stream.send(chunk(request.Model, "text_completion", map[string]any{
+					"index": p*n + i, "text": "", "logprobs": nil, "finish_reason": "stop",
+				}))
+			}
+		}
+		stream.done()
+		return
+	}
+
+	choices := []any{}
+	completion := ""
+	for p, response := range responses {
+		for i := 0; i < n; i++ {
+			choices = append(choices, map[string]any{
+				"index": p*n + i, "text": response, "logprobs": nil, "finish_reason": "stop",
+			})
+			completion += response + " "
+		}
+	}
+	writeJSON(w, map[string]any{
+		"id":      "cmpl-mock",
+		"object":  "text_completion",
+		"created": time.Now().Unix(),
+		"model":   request.Model,
+		"choices": choices,
+		"usage":   usage(prompts, completion),
+	})
+}
+
+func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	request := &struct {
+		Model string `json:"model"`
+		Input any    `json:"input"`
+	}{}
+	if !decode(w, r, request) {
+		return
+	}
+
+	// the input has the forms of the prompt of a completion request
+	inputs := (&completionRequest{Prompt: request.Input}).prompts()
+	data := []any{}
+	for i, input := range inputs {
+		data = append(data, map[string]any{"object": "embedding", "index": i, "embedding": embedding(input)})
+	}
+	writeJSON(w, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  request.Model,
+		"usage":  usage(inputs, ""),
+	})
+}
+
+// embedding returns a deterministic unit vector for a text, so that identical
+// texts are identical and different texts are unrelated.
+func embedding(text string) []float64 {
+	sum := sha256.Sum256([]byte(text))
+	var seed int64
+	for _, b := range sum[:8] {
+		seed = seed<<8 | int64(b)
+	}
+	random := rand.New(rand.NewSource(seed))
+
+	vector := make([]float64, embeddingDimensions)
+	norm := 0.0
+	for i := range vector {
+		vector[i] = random.NormFloat64()
+		norm += vector[i] * vector[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+func (s *Server) models(w http.ResponseWriter, r *http.Request) {
+	data := []any{}
+	for _, id := range []string{"gpt-3.5-turbo", "gpt-3.5-turbo-instruct", "gpt-4", "gpt-4o", "gpt-4o-mini", "text-davinci-003", "text-embedding-ada-002", "text-embedding-3-small"} {
+		data = append(data, map[string]any{"id": id, "object": "model", "owned_by": "langforge-mock"})
+	}
+	writeJSON(w, map[string]any{"object": "list", "data": data})
+}
+
+// tokens splits a response into the pieces it is streamed in.
+func tokens(response string) []string {
+	pieces := strings.SplitAfter(response, " ")
+	if len(pieces) > 0 && pieces[len(pieces)-1] == "" {
+		pieces = pieces[:len(pieces)-1]
+	}
+	return pieces
+}
+
+// usage estimates token counts by words, which is good enough for mocks.
+func usage(prompts []string, completion string) map[string]int {
+	promptTokens := 0
+	for _, prompt := range prompts {
+		promptTokens += len(strings.Fields(prompt))
+	}
+	completionTokens := len(strings.Fields(completion))
+	return map[string]int{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": completionTokens,
+		"total_tokens":      promptTokens + completionTokens,
+	}
+}
+
+func chunk(model string, object string, choice map[string]any) map[string]any {
+	return map[string]any{
+		"id":      "chatcmpl-mock",
+		"object":  object,
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []any{choice},
+	}
+}
+
+// eventStream writes server-sent events in the format of the OpenAI API.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	return &eventStream{w: w, flusher: flusher}
+}
+
+func (s *eventStream) send(value any) {
+	data, _ := json.Marshal(value)
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func (s *eventStream) done() {
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, value any) bool {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error in the format of the OpenAI API.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": "invalid_request_error"},
+	})
+}
@@ -0,0 +1,20 @@
+//go:build darwin
+
+package models
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// lastAccess returns the time a file was last read, or its modification time
+// if the file system does not record access times.
+func lastAccess(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if atime := time.Unix(stat.Atimespec.Sec, stat.Atimespec.Nsec); atime.After(info.ModTime()) {
+			return atime
+		}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// lastAccess returns the modification time of a file, since access times are
+// not read on this platform.
+func lastAccess(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package models
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// lastAccess returns the time a file was last read, or its modification time
+// if the file system does not record access times.
+func lastAccess(info os.FileInfo) time.Time {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		if atime := time.Unix(0, data.LastAccessTime.Nanoseconds()); atime.After(info.ModTime()) {
+			return atime
+		}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// huggingFaceCache returns the directory of the Hugging Face hub cache.
+func huggingFaceCache() string {
+	if dir := os.Getenv("HF_HUB_CACHE"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("HUGGINGFACE_HUB_CACHE"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("HF_HOME"); dir != "" {
+		return filepath.Join(dir, "hub")
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "huggingface", "hub")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "huggingface", "hub")
+}
+
+// scanHuggingFace lists the model repositories in the hub cache. The files of
+// a repository are stored in its blobs directory; the snapshots link to them.
+func scanHuggingFace() ([]*Model, error) {
+	cache := huggingFaceCache()
+	entries, err := os.ReadDir(cache)
+	if err != nil {
+		if os.IsNotExist(err) || cache == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	models := []*Model{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "models--") {
+			continue
+		}
+		model := &Model{
+			Source: SourceHuggingFace,
+			Name:   strings.ReplaceAll(strings.TrimPrefix(entry.Name(), "models--"), "--", "/"),
+			path:   filepath.Join(cache, entry.Name()),
+		}
+		blobs, err := os.ReadDir(filepath.Join(model.path, "blobs"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, blob := range blobs {
+			info, err := blob.Info()
+			if err != nil {
+				continue
+			}
+			model.Size += info.Size()
+			if used := lastAccess(info); used.After(model.LastUsed) {
+				model.LastUsed = used
+			}
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
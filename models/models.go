@@ -0,0 +1,146 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuotaEnv overrides the quota of the model cache configured in langforge.yaml.
+const QuotaEnv = "LANGFORGE_MODEL_QUOTA"
+
+// Sources of cached models.
+const (
+	SourceHuggingFace = "huggingface"
+	SourceOllama      = "ollama"
+)
+
+// Model is a model in the local cache of Hugging Face or Ollama.
+type Model struct {
+	Source string
+	Name   string
+	// Size is the disk space of the model's files. Ollama models share
+	// layers, so removing one may free less.
+	Size     int64
+	LastUsed time.Time
+	path     string
+	blobs    []string
+}
+
+// Scan lists the models in the local caches, least recently used first.
+// Caches that do not exist are skipped.
+func Scan() ([]*Model, error) {
+	models := []*Model{}
+	for _, scan := range []func() ([]*Model, error){scanHuggingFace, scanOllama} {
+		found, err := scan()
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, found...)
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].LastUsed.Before(models[j].LastUsed)
+	})
+	return models, nil
+}
+
+// Usage returns the disk space taken by the models, counting the layers that
+// Ollama models share once.
+func Usage(models []*Model) int64 {
+	var total int64
+	counted := map[string]bool{}
+	for _, model := range models {
+		if model.blobs == nil {
+			total += model.Size
+			continue
+		}
+		for _, blob := range model.blobs {
+			if counted[blob] {
+				continue
+			}
+			counted[blob] = true
+			if info, err := os.Stat(blob); err == nil {
+				total += info.Size()
+			}
+		}
+	}
+	return total
+}
+
+// Evictions suggests the least recently used models to remove so that the
+// cache fits into quota. models must be ordered as returned by Scan.
+func Evictions(models []*Model, quota int64) []*Model {
+	usage := Usage(models)
+	evict := []*Model{}
+	for _, model := range models {
+		if usage <= quota {
+			break
+		}
+		evict = append(evict, model)
+		usage -= model.Size
+	}
+	return evict
+}
+
+// Remove deletes a model from its cache.
+func Remove(model *Model) error {
+	switch model.Source {
+	case SourceHuggingFace:
+		return os.RemoveAll(model.path)
+	case SourceOllama:
+		return removeOllama(model)
+	}
+	return fmt.Errorf("unknown model source %s", model.Source)
+}
+
+// Quota returns the quota of the model cache in bytes from the environment or
+// the configured value, or zero if there is none.
+func Quota(configured string) (int64, error) {
+	if value := os.Getenv(QuotaEnv); value != "" {
+		configured = value
+	}
+	if configured == "" {
+		return 0, nil
+	}
+	return ParseSize(configured)
+}
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "20GB" or "512 MB". Units are powers of 1024.
+func ParseSize(text string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(text))
+	for _, unit := range units {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil || number < 0 {
+			break
+		}
+		return int64(number * float64(unit.size)), nil
+	}
+	return 0, fmt.Errorf("invalid size %q, use e.g. 20GB or 500MB", text)
+}
+
+// FormatSize formats a size in bytes with the largest fitting unit.
+func FormatSize(size int64) string {
+	for _, unit := range units {
+		if size >= unit.size && unit.size > 1 {
+			return fmt.Sprintf("%.1f %s", float64(size)/float64(unit.size), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ollamaModels returns the directory in which Ollama stores its models.
+func ollamaModels() string {
+	if dir := os.Getenv("OLLAMA_MODELS"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ollama", "models")
+}
+
+// ollamaManifest lists the layers of a model, which are stored as blobs.
+type ollamaManifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"layers"`
+}
+
+// scanOllama lists the models of Ollama from their manifests, which are
+// stored as manifests/<registry>/<namespace>/<model>/<tag>.
+func scanOllama() ([]*Model, error) {
+	root := ollamaModels()
+	manifests := filepath.Join(root, "manifests")
+	if _, err := os.Stat(manifests); err != nil {
+		if os.IsNotExist(err) || root == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	models := []*Model{}
+	err := filepath.WalkDir(manifests, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		manifest := &ollamaManifest{}
+		if json.Unmarshal(data, manifest) != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(manifests, path)
+		if err != nil {
+			return err
+		}
+		model := &Model{Source: SourceOllama, Name: ollamaName(filepath.ToSlash(rel)), path: path}
+		if info, err := entry.Info(); err == nil {
+			model.LastUsed = lastAccess(info)
+		}
+
+		digests := []string{manifest.Config.Digest}
+		for _, layer := range manifest.Layers {
+			digests = append(digests, layer.Digest)
+		}
+		for _, digest := range digests {
+			if digest == "" {
+				continue
+			}
+			blob := filepath.Join(root, "blobs", strings.Replace(digest, ":", "-", 1))
+			info, err := os.Stat(blob)
+			if err != nil {
+				continue
+			}
+			model.blobs = append(model.blobs, blob)
+			model.Size += info.Size()
+			if used := lastAccess(info); used.After(model.LastUsed) {
+				model.LastUsed = used
+			}
+		}
+		models = append(models, model)
+		return nil
+	})
+	return models, err
+}
+
+// ollamaName returns the name Ollama shows for the manifest at rel, e.g.
+// "llama3:8b" for "registry.ollama.ai/library/llama3/8b".
+func ollamaName(rel string) string {
+	parts := strings.Split(rel, "/")
+	if len(parts) < 2 {
+		return rel
+	}
+	tag := parts[len(parts)-1]
+	name := strings.Join(parts[:len(parts)-1], "/")
+	name = strings.TrimPrefix(name, "registry.ollama.ai/")
+	name = strings.TrimPrefix(name, "library/")
+	return name + ":" + tag
+}
+
+// removeOllama removes a model with the Ollama CLI, which deletes the layers
+// that no other model uses.
+func removeOllama(model *Model) error {
+	if _, err := exec.LookPath("ollama"); err != nil {
+		return fmt.Errorf("removing Ollama models requires the ollama command, which is not installed")
+	}
+	output, err := exec.Command("ollama", "rm", model.Name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ollama rm %s failed: %v\n%s", model.Name, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package netcheck
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target is a service that langforge or a LangChain application needs to reach.
+type Target struct {
+	Name string
+	URL  string
+}
+
+// Result is the outcome of checking a target. Kind classifies failures as
+// "dns", "proxy", "tls", "timeout" or "network".
+type Result struct {
+	Target  Target
+	Proxy   string
+	Status  int
+	Latency time.Duration
+	Kind    string
+	Err     error
+}
+
+// OK reports whether the target answered. Any HTTP status counts, since most
+// APIs reject unauthenticated requests.
+func (r *Result) OK() bool {
+	return r.Err == nil
+}
+
+// DefaultTargets returns the services checked by default. Index and API URLs
+// are taken from the environment if they are overridden there.
+func DefaultTargets(env map[string]string) []Target {
+	lookup := func(key string, fallback string) string {
+		if value := env[key]; value != "" {
+			return value
+		}
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	return []Target{
+		{Name: "PyPI", URL: lookup("PIP_INDEX_URL", "https://pypi.org/simple/")},
+		{Name: "PyPI files", URL: "https://files.pythonhosted.org/"},
+		{Name: "npm", URL: lookup("NPM_CONFIG_REGISTRY", "https://registry.npmjs.org/")},
+		{Name: "OpenAI", URL: strings.TrimSuffix(lookup("OPENAI_API_BASE", "https://api.openai.com/v1"), "/") + "/models"},
+		{Name: "Anthropic", URL: "https://api.anthropic.com/v1/models"},
+		{Name: "Hugging Face", URL: lookup("HF_ENDPOINT", "https://huggingface.co") + "/api/models?limit=1"},
+		{Name: "Docker Hub", URL: "https://registry-1.docker.io/v2/"},
+	}
+}
+
+// ProxySettings returns the proxy related environment variables that are set
+// as "KEY=value" pairs, with credentials removed from proxy URLs.
+func ProxySettings() []string {
+	settings := []string{}
+	for _, key := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			value = redact(u)
+		}
+		settings = append(settings, key+"="+value)
+	}
+	return settings
+}
+
+// CheckAll checks the targets concurrently and returns the results in the
+// order of the targets.
+func CheckAll(ctx context.Context, targets []Target, timeout time.Duration) []*Result {
+	results := make([]*Result, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			results[i] = Check(ctx, target, timeout)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Check sends a request to the target through the proxy configured in the
+// environment and measures the time until the response headers arrive.
+func Check(ctx context.Context, target Target, timeout time.Duration) *Result {
+	result := &Result{Target: target}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		result.Kind = "config"
+		result.Err = err
+		return result
+	}
+
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		result.Kind = "proxy"
+		result.Err = fmt.Errorf("invalid proxy setting: %v", err)
+		return result
+	}
+	if proxy != nil {
+		result.Proxy = redact(proxy)
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Kind = classify(err, proxy != nil)
+		result.Err = err
+		return result
+	}
+	resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		result.Kind = "proxy"
+		result.Err = errors.New("proxy authentication required")
+	}
+	return result
+}
+
+// Hint explains how to fix a failure of the given kind.
+func Hint(kind string) string {
+	switch kind {
+	case "dns":
+		return "The host name could not be resolved. Check your DNS settings or VPN connection."
+	case "proxy":
+		return "The proxy could not be reached or rejected the connection. Check HTTPS_PROXY/HTTP_PROXY and the proxy credentials."
+	case "tls":
+		return "The TLS certificate is not trusted. Behind an intercepting proxy, point SSL_CERT_FILE, REQUESTS_CA_BUNDLE and NODE_EXTRA_CA_CERTS to your company's CA bundle."
+	case "timeout":
+		return "The request timed out. A firewall may drop the traffic, or a proxy is required but not configured."
+	case "config":
+		return "The URL is invalid. Check the index and API URLs in your environment."
+	default:
+		return "The connection failed. Check your network connection and firewall."
+	}
+}
+
+func classify(err error, viaProxy bool) string {
+	var dnsErr *net.DNSError
+	var certErr *x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &certErr), errors.As(err, &hostErr), errors.As(err, &invalidErr):
+		return "tls"
+	case errors.As(err, &dnsErr):
+		if viaProxy {
+			return "proxy"
+		}
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded) || isTimeout(err):
+		return "timeout"
+	case viaProxy && errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return "proxy"
+	default:
+		return "network"
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// redact removes credentials from a proxy URL.
+func redact(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	copy := *u
+	copy.User = url.User("***")
+	return copy.String()
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// desktop shows events as notifications on the desktop of the machine, with
+// notify-send on Linux, osascript on macOS and a toast of PowerShell on
+// Windows and in WSL. It is meant for a gateway that runs on a developer's machine; a
+// service without a desktop session cannot show notifications.
+type desktop struct{}
+
+// powerShellAppID is the application that toasts are shown for. Windows only
+// shows toasts of registered applications.
+const powerShellAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:LANGFORGE_NOTIFICATION_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:LANGFORGE_NOTIFICATION_MESSAGE)) > $null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:LANGFORGE_NOTIFICATION_APP).Show($toast)`
+
+// appleScript reads the texts from the environment, so that they need no
+// quoting.
+const appleScript = `display notification (system attribute "LANGFORGE_NOTIFICATION_MESSAGE") with title "langforge" subtitle (system attribute "LANGFORGE_NOTIFICATION_TITLE")`
+
+func (desktop) Send(ctx context.Context, event *Event) error {
+	title := event.Title
+	if event.Project != "" {
+		title = fmt.Sprintf("%s (%s)", event.Title, event.Project)
+	}
+	message := event.Message
+	if message == "" {
+		message = event.Title
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "osascript", "-e", appleScript)
+	default:
+		if system.Platform().IsWSL() {
+			cmd = exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+			break
+		}
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return fmt.Errorf("desktop notification failed: notify-send not found, install libnotify")
+		}
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=langforge", title, message)
+	}
+	cmd.Env = append(os.Environ(),
+		"LANGFORGE_NOTIFICATION_TITLE="+title,
+		"LANGFORGE_NOTIFICATION_MESSAGE="+message,
+		"LANGFORGE_NOTIFICATION_APP="+powerShellAppID,
+	)
+	if system.Platform().IsWSL() {
+		// Windows programs only get the variables of WSL that WSLENV lists
+		wslenv := "LANGFORGE_NOTIFICATION_TITLE:LANGFORGE_NOTIFICATION_MESSAGE:LANGFORGE_NOTIFICATION_APP"
+		if existing := os.Getenv("WSLENV"); existing != "" {
+			wslenv = existing + ":" + wslenv
+		}
+		cmd.Env = append(cmd.Env, "WSLENV="+wslenv)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("desktop notification failed: %v: %s", err, text)
+		}
+		return fmt.Errorf("desktop notification failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of events. The part before the dot is the group of an event, which
+// channels may subscribe to as a whole.
+const (
+	// DeployCompleted and DeployFailed report the restart of a server on
+	// SIGHUP, e.g. by a deploy script.
+	DeployCompleted = "deploy.completed"
+	DeployFailed    = "deploy.failed"
+	// BudgetThreshold reports that the cost of the LLM requests reached a
+	// threshold of the budget of the project.
+	BudgetThreshold = "budget.threshold"
+	// WorkerCrashLoop reports that the worker crashed repeatedly.
+	WorkerCrashLoop = "worker.crash_loop"
+	// TaskFailed reports a failed run of a scheduled task.
+	TaskFailed = "schedule.failed"
+	// Test is sent by 'langforge notifications test'.
+	Test = "test"
+)
+
+// Event is a notification about something that happened in a project.
+type Event struct {
+	// Kind identifies the event, e.g. "schedule.failed".
+	Kind    string `json:"event"`
+	Project string `json:"project"`
+	Title   string `json:"title"`
+	Message string `json:"message,omitempty"`
+	// Fields hold details of the event, such as the name of a task.
+	Fields map[string]string `json:"fields,omitempty"`
+	Time   time.Time         `json:"time"`
+}
+
+// Channel delivers notifications.
+type Channel interface {
+	Send(ctx context.Context, event *Event) error
+}
+
+// sendTimeout bounds the time a channel may take to deliver a notification.
+const sendTimeout = 10 * time.Second
+
+// New returns the channel of a notification configuration.
+func New(config project.NotificationConfig) (Channel, error) {
+	if config.Type == "desktop" {
+		return desktop{}, nil
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("the %s notification channel has no url", config.Type)
+	}
+	switch config.Type {
+	case "slack":
+		return &webhook{url: config.URL, headers: config.Headers, payload: slackPayload}, nil
+	case "webhook":
+		return &webhook{url: config.URL, headers: config.Headers, payload: json.Marshal}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification channel %q, expected slack, webhook or desktop", config.Type)
+	}
+}
+
+// Subscribed reports whether the channel of config receives events of the
+// given kind.
+func Subscribed(config project.NotificationConfig, kind string) bool {
+	if len(config.Events) == 0 || kind == Test {
+		return true
+	}
+	for _, event := range config.Events {
+		if event == kind || strings.HasPrefix(kind, event+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Send sends the event to all channels that subscribed to it. Channels that
+// fail do not keep the others from being notified; their errors are returned
+// together.
+func Send(ctx context.Context, configs []project.NotificationConfig, event *Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	// several instances of a project may notify the same channel
+	if hostname, err := os.Hostname(); err == nil {
+		if event.Fields == nil {
+			event.Fields = map[string]string{}
+		}
+		if _, ok := event.Fields["host"]; !ok {
+			event.Fields["host"] = hostname
+		}
+	}
+	failures := []string{}
+	for _, config := range configs {
+		if !Subscribed(config, event.Kind) {
+			continue
+		}
+		channel, err := New(config)
+		if err == nil {
+			err = channel.Send(ctx, event)
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to send notifications: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// webhook posts events as JSON to a URL.
+type webhook struct {
+	url     string
+	headers map[string]string
+	payload func(v any) ([]byte, error)
+}
+
+func (w *webhook) Send(ctx context.Context, event *Event) error {
+	body, err := w.payload(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	url := os.ExpandEnv(w.url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// the error contains the URL, which holds the secret of a Slack webhook
+		return fmt.Errorf("notification to %s failed: %v", host(url), unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification to %s failed with %s", host(url), resp.Status)
+	}
+	return nil
+}
+
+// slackPayload formats an event as a message of an incoming webhook of Slack.
+func slackPayload(v any) ([]byte, error) {
+	event := v.(*Event)
+	text := fmt.Sprintf("*%s* (%s)", event.Title, event.Project)
+	if event.Message != "" {
+		text += "\n" + event.Message
+	}
+	keys := []string{}
+	for key := range event.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		text += fmt.Sprintf("\n%s: %s", key, event.Fields[key])
+	}
+	return json.Marshal(map[string]string{"text": text})
+}
+
+// Target describes where the channel of config delivers notifications
+// without revealing secrets, e.g. "hooks.slack.com".
+func Target(config project.NotificationConfig) string {
+	if config.Type == "desktop" {
+		return "this machine"
+	}
+	return host(os.ExpandEnv(config.URL))
+}
+
+func host(rawURL string) string {
+	rest := rawURL
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+func unwrapURLError(err error) error {
+	for {
+		wrapped, ok := err.(interface{ Unwrap() error })
+		if !ok || wrapped.Unwrap() == nil {
+			return err
+		}
+		err = wrapped.Unwrap()
+	}
+}
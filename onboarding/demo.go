@@ -0,0 +1,53 @@
+package onboarding
+
+import (
+	"embed"
+	"io/fs"
+	"langforge/bundle"
+	"langforge/project"
+	"os"
+	"path/filepath"
+)
+
+//go:embed files/demo/app.ipynb
+var demoFS embed.FS
+
+func init() {
+	files, err := fs.Sub(demoFS, "files/demo")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.Demo, files)
+}
+
+// DemoNotebook is the notebook of the demo project.
+const DemoNotebook = "app.ipynb"
+
+// DemoChain is the chain of the demo project, which answers questions with
+// an OpenAI chat model.
+const DemoChain = "qa"
+
+// WriteDemo writes the notebook and the langforge.yaml file of the demo
+// project to dir.
+func WriteDemo(dir string, name string) error {
+	files, err := bundle.FS(bundle.Demo)
+	if err != nil {
+		return err
+	}
+	notebook, err := fs.ReadFile(files, DemoNotebook)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, DemoNotebook), notebook, 0644); err != nil {
+		return err
+	}
+
+	return project.SaveConfig(dir, &project.Config{
+		Name: name,
+		Chains: []project.ChainConfig{{
+			Name:        DemoChain,
+			Notebook:    DemoNotebook,
+			Description: "Answers a question in one or two sentences",
+		}},
+	})
+}
@@ -0,0 +1,202 @@
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Tool is a program that LangForge applications need.
+type Tool struct {
+	Name string
+	// Purpose says what the tool is used for.
+	Purpose  string
+	Required bool
+	// Download is where the tool can be installed from by hand.
+	Download string
+	// Packages maps the names of package managers to the packages that
+	// install the tool.
+	Packages map[string][]string
+	detect   func() (version string, problem string)
+}
+
+// Tools are the tools that the setup checks, required ones first.
+var Tools = []Tool{
+	{
+		Name:     "python",
+		Purpose:  "runs notebooks and Python chains",
+		Required: true,
+		Download: "https://www.python.org/downloads/",
+		Packages: map[string][]string{
+			"brew":    {"python"},
+			"winget":  {"Python.Python.3.12"},
+			"apt-get": {"python3", "python3-venv", "python3-pip"},
+			"dnf":     {"python3", "python3-pip"},
+			"pacman":  {"python", "python-pip"},
+		},
+		detect: detectPython,
+	},
+	{
+		Name:     "node",
+		Purpose:  "runs TypeScript and JavaScript chains",
+		Download: "https://nodejs.org/",
+		Packages: map[string][]string{
+			"brew":    {"node"},
+			"winget":  {"OpenJS.NodeJS.LTS"},
+			"apt-get": {"nodejs", "npm"},
+			"dnf":     {"nodejs"},
+			"pacman":  {"nodejs", "npm"},
+		},
+		detect: func() (string, string) { return commandVersion("node", "--version") },
+	},
+	{
+		Name:     "git",
+		Purpose:  "versions projects and runs lint hooks",
+		Download: "https://git-scm.com/downloads",
+		Packages: map[string][]string{
+			"brew":    {"git"},
+			"winget":  {"Git.Git"},
+			"apt-get": {"git"},
+			"dnf":     {"git"},
+			"pacman":  {"git"},
+		},
+		detect: func() (string, string) { return commandVersion("git", "--version") },
+	},
+	{
+		Name:     "docker",
+		Purpose:  "runs vector stores and builds images",
+		Download: "https://docs.docker.com/get-docker/",
+		Packages: map[string][]string{
+			"brew":    {"--cask", "docker"},
+			"winget":  {"Docker.DockerDesktop"},
+			"apt-get": {"docker.io"},
+			"dnf":     {"moby-engine"},
+			"pacman":  {"docker"},
+		},
+		detect: func() (string, string) { return commandVersion("docker", "--version") },
+	},
+}
+
+// Status is the result of detecting a tool.
+type Status struct {
+	Tool    Tool
+	Version string
+	// Problem says why the tool cannot be used and is empty if it can.
+	Problem string
+}
+
+// OK reports whether the tool can be used.
+func (s *Status) OK() bool {
+	return s.Problem == ""
+}
+
+// DetectTools detects the tools.
+func DetectTools() []Status {
+	statuses := []Status{}
+	for _, tool := range Tools {
+		version, problem := tool.detect()
+		statuses = append(statuses, Status{Tool: tool, Version: version, Problem: problem})
+	}
+	return statuses
+}
+
+// commandVersion returns the version that a command prints, such as
+// "v18.16.0" for "node --version".
+func commandVersion(name string, args ...string) (string, string) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", "not installed"
+	}
+	output, err := exec.Command(path, args...).Output()
+	if err != nil {
+		return "", fmt.Sprintf("%s does not run: %v", path, err)
+	}
+	version := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	for _, field := range strings.Fields(version) {
+		field = strings.TrimSuffix(strings.TrimPrefix(field, "v"), ",")
+		if len(field) > 0 && field[0] >= '0' && field[0] <= '9' {
+			return field, ""
+		}
+	}
+	return version, ""
+}
+
+// MinPythonVersion is the oldest Python that LangChain supports.
+var MinPythonVersion = [2]int{3, 8}
+
+func detectPython() (string, string) {
+	pythonPath, err := system.FindPython(fmt.Sprintf(">=%d.%d", MinPythonVersion[0], MinPythonVersion[1]))
+	if errors.Is(err, system.ErrPythonNotFound) {
+		return "", "not installed"
+	} else if err != nil {
+		return "", fmt.Sprintf("Python %d.%d or newer is required", MinPythonVersion[0], MinPythonVersion[1])
+	}
+	version, err := system.PythonVersion(pythonPath)
+	if err != nil {
+		return "", err.Error()
+	}
+
+	// Debian and Ubuntu ship the venv module in a separate package
+	if err := exec.Command(pythonPath, "-c", "import venv, ensurepip").Run(); err != nil {
+		return version, "the venv module is missing"
+	}
+	return version, ""
+}
+
+// PackageManager installs tools with the package manager of the system.
+type PackageManager struct {
+	Name    string
+	install []string
+}
+
+// FindPackageManager returns the package manager of the system, or nil if
+// there is none that the setup supports: Homebrew on macOS, winget on
+// Windows and apt-get, dnf or pacman on Linux. Linux package managers run
+// with sudo unless langforge runs as root.
+func FindPackageManager() *PackageManager {
+	candidates := map[string][][]string{
+		"darwin":  {{"brew", "install"}},
+		"windows": {{"winget", "install", "--exact", "--id"}},
+		"linux":   {{"apt-get", "install", "-y"}, {"dnf", "install", "-y"}, {"pacman", "-S", "--noconfirm"}},
+	}
+	for _, install := range candidates[runtime.GOOS] {
+		if _, err := exec.LookPath(install[0]); err != nil {
+			continue
+		}
+		manager := &PackageManager{Name: install[0], install: install}
+		if runtime.GOOS == "linux" && os.Geteuid() != 0 {
+			manager.install = append([]string{"sudo"}, install...)
+		}
+		return manager
+	}
+	return nil
+}
+
+// InstallCommand returns the command that installs the tool, or nil if the
+// package manager has no package for it.
+func (m *PackageManager) InstallCommand(tool Tool) []string {
+	packages := tool.Packages[m.Name]
+	if len(packages) == 0 {
+		return nil
+	}
+	return append(append([]string{}, m.install...), packages...)
+}
+
+// Install runs the command that installs the tool in the terminal, so that
+// the package manager can ask for passwords and confirmations.
+func (m *PackageManager) Install(tool Tool) error {
+	command := m.InstallCommand(tool)
+	if command == nil {
+		return fmt.Errorf("%s has no package for %s", m.Name, tool.Name)
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if system.SkipDryRun(cmd) {
+		return nil
+	}
+	return cmd.Run()
+}
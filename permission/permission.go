@@ -0,0 +1,170 @@
+package permission
+
+import (
+	"fmt"
+	"langforge/system"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Class classifies operations by the harm they can do.
+type Class string
+
+// Classes of operations.
+const (
+	// Delete removes data that cannot be restored by langforge, such as
+	// environments, models or vector stores.
+	Delete Class = "delete"
+	// Overwrite replaces existing files.
+	Overwrite Class = "overwrite"
+	// Secrets copies secrets out of the project, e.g. into an archive.
+	Secrets Class = "secrets"
+)
+
+// Operation is an operation that requires permission. Description completes
+// the sentence "Confirmation is required to ...".
+type Operation struct {
+	Name        string
+	Class       Class
+	Description string
+}
+
+// Operations of langforge that require permission.
+var (
+	ResetVectorStore = Operation{Name: "vectorstore.reset", Class: Delete, Description: "delete the documents of the vector store"}
+	RemoveModels     = Operation{Name: "models.remove", Class: Delete, Description: "remove cached models"}
+	UninstallService = Operation{Name: "service.uninstall", Class: Delete, Description: "uninstall the gateway service"}
+	OverwriteFile    = Operation{Name: "file.overwrite", Class: Overwrite, Description: "overwrite existing files"}
+	ExportSecrets    = Operation{Name: "export.secrets", Class: Secrets, Description: "export the secrets of .env"}
+	ShipSecrets      = Operation{Name: "scan.secrets", Class: Secrets, Description: "package or export files with possible secrets"}
+)
+
+// Operations lists all operations that require permission.
+var Operations = []Operation{ResetVectorStore, RemoveModels, UninstallService, OverwriteFile, ExportSecrets, ShipSecrets}
+
+// Decision is what a policy requires before an operation runs.
+type Decision string
+
+// Decisions of a policy, from the most to the least permissive.
+const (
+	// Allow runs the operation without asking.
+	Allow Decision = "allow"
+	// Confirm asks for confirmation unless --force is given.
+	Confirm Decision = "confirm"
+	// AlwaysConfirm asks for confirmation even if --force is given, so the
+	// operation can only run in a terminal.
+	AlwaysConfirm Decision = "always-confirm"
+	// Deny never runs the operation.
+	Deny Decision = "deny"
+)
+
+var strictness = map[Decision]int{Allow: 0, Confirm: 1, AlwaysConfirm: 2, Deny: 3}
+
+// PolicyEnv names a policy file that applies in addition to the policy of
+// the machine.
+const PolicyEnv = "LANGFORGE_POLICY"
+
+// Policy decides which operations are allowed.
+type Policy struct {
+	// Sources lists the files the policy was read from.
+	Sources []string
+	files   []*policyFile
+}
+
+// policyFile holds the rules of a policy file. Rules map operation names or
+// classes to decisions; the rule of an operation takes precedence over the
+// rule of its class.
+type policyFile struct {
+	Rules map[string]Decision `yaml:"rules"`
+}
+
+// SystemPolicyPath returns the location of the policy of the machine, which
+// administrators use to lock down operations on shared machines.
+func SystemPolicyPath() string {
+	if runtime.GOOS == "windows" {
+		dir := os.Getenv("ProgramData")
+		if dir == "" {
+			dir = `C:\ProgramData`
+		}
+		return filepath.Join(dir, "langforge", "policy.yaml")
+	}
+	return "/etc/langforge/policy.yaml"
+}
+
+// LoadPolicy reads the policy of the machine and the policy named by
+// LANGFORGE_POLICY. Where both decide an operation, the stricter decision
+// applies, so that the policy of the machine cannot be loosened.
+func LoadPolicy() (*Policy, error) {
+	policy := &Policy{}
+	paths := []string{SystemPolicyPath()}
+	if path := os.Getenv(PolicyEnv); path != "" {
+		paths = append(paths, system.NativePath(path))
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) && path == SystemPolicyPath() {
+				continue
+			}
+			return nil, fmt.Errorf("error reading policy: %v", err)
+		}
+		file := &policyFile{}
+		if err := yaml.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: %v", path, err)
+		}
+		for name, decision := range file.Rules {
+			if _, ok := strictness[decision]; !ok {
+				return nil, fmt.Errorf("invalid policy %s: unknown decision %q for %s, use allow, confirm, always-confirm or deny", path, decision, name)
+			}
+			if !known(name) {
+				return nil, fmt.Errorf("invalid policy %s: unknown operation %q", path, name)
+			}
+		}
+		policy.files = append(policy.files, file)
+		policy.Sources = append(policy.Sources, path)
+	}
+	return policy, nil
+}
+
+// known returns whether name is an operation or a class.
+func known(name string) bool {
+	for _, operation := range Operations {
+		if name == operation.Name || name == string(operation.Class) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide returns the decision of the policy for an operation. Operations
+// without a rule require confirmation.
+func (p *Policy) Decide(operation Operation) Decision {
+	decided := false
+	strictest := Allow
+	for _, file := range p.files {
+		decision, ok := file.Rules[operation.Name]
+		if !ok {
+			decision, ok = file.Rules[string(operation.Class)]
+		}
+		if ok && (!decided || strictness[decision] > strictness[strictest]) {
+			decided = true
+			strictest = decision
+		}
+	}
+	if !decided {
+		return Confirm
+	}
+	return strictest
+}
+
+// Source describes where the policy was read from.
+func (p *Policy) Source() string {
+	if len(p.Sources) == 0 {
+		return "the default policy"
+	}
+	return strings.Join(p.Sources, " and ")
+}
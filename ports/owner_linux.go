@@ -0,0 +1,95 @@
+//go:build linux
+
+package ports
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listenState is the state of listening sockets in /proc/net/tcp.
+const listenState = "0A"
+
+// findOwner looks up the inode of the listening socket in /proc/net and the
+// process that has the socket open in /proc/*/fd.
+func findOwner(port int) (*Owner, error) {
+	inodes := map[string]bool{}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		if err := listeningInodes(table, port, inodes); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if len(inodes) == 0 {
+		return nil, nil
+	}
+
+	processes, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	for _, processDir := range processes {
+		// the descriptors of processes of other users cannot be read
+		fds, err := os.ReadDir(filepath.Join(processDir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(processDir, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			if inodes[strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")] {
+				return processOwner(processDir)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// listeningInodes adds the inodes of sockets that listen on the port to
+// inodes.
+func listeningInodes(table string, port int, inodes map[string]bool) error {
+	file, err := os.Open(table)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != listenState {
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		localPort, err := strconv.ParseInt(fields[1][i+1:], 16, 32)
+		if err == nil && int(localPort) == port {
+			inodes[fields[9]] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func processOwner(processDir string) (*Owner, error) {
+	pid, err := strconv.Atoi(filepath.Base(processDir))
+	if err != nil {
+		return nil, err
+	}
+	owner := &Owner{PID: pid}
+	if comm, err := os.ReadFile(filepath.Join(processDir, "comm")); err == nil {
+		owner.Name = strings.TrimSpace(string(comm))
+	}
+	if cmdline, err := os.ReadFile(filepath.Join(processDir, "cmdline")); err == nil {
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		owner.Command = strings.Join(args, " ")
+		// comm is truncated to 15 characters
+		if len(args) > 0 && args[0] != "" {
+			owner.Name = filepath.Base(args[0])
+		}
+	}
+	return owner, nil
+}
@@ -0,0 +1,50 @@
+//go:build !linux && !windows
+
+package ports
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// findOwner looks up the process with lsof, which ships with macOS and the
+// BSDs.
+func findOwner(port int) (*Owner, error) {
+	output, err := exec.Command("lsof", "-nP", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		// lsof exits with 1 if no process matches
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var owner *Owner
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			if owner != nil {
+				return owner, nil
+			}
+			pid, err := strconv.Atoi(line[1:])
+			if err != nil {
+				return nil, err
+			}
+			owner = &Owner{PID: pid}
+		case 'c':
+			if owner != nil {
+				owner.Name = line[1:]
+			}
+		}
+	}
+	if owner != nil {
+		if command, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(owner.PID)).Output(); err == nil {
+			owner.Command = strings.TrimSpace(string(command))
+		}
+	}
+	return owner, nil
+}
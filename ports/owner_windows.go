@@ -0,0 +1,55 @@
+//go:build windows
+
+package ports
+
+import (
+	"encoding/csv"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// findOwner looks up the process in the output of netstat and its name with
+// tasklist.
+func findOwner(port int) (*Owner, error) {
+	output, err := exec.Command("netstat", "-ano", "-p", "TCP").Output()
+	if err != nil {
+		return nil, err
+	}
+	ipv6, err := exec.Command("netstat", "-ano", "-p", "TCPv6").Output()
+	if err == nil {
+		output = append(output, ipv6...)
+	}
+
+	suffix := ":" + strconv.Itoa(port)
+	for _, line := range strings.Split(string(output), "\n") {
+		// Proto  Local Address  Foreign Address  State  PID, the state is
+		// localized, so listening sockets are recognized by their foreign
+		// port 0
+		fields := strings.Fields(line)
+		if len(fields) != 5 || !strings.HasSuffix(fields[1], suffix) || !strings.HasSuffix(fields[2], ":0") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil {
+			continue
+		}
+		owner := &Owner{PID: pid}
+		owner.Name = processName(pid)
+		return owner, nil
+	}
+	return nil, nil
+}
+
+// processName returns the image name of the process, e.g. "python.exe".
+func processName(pid int) string {
+	output, err := exec.Command("tasklist", "/FI", "PID eq "+strconv.Itoa(pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return ""
+	}
+	records, err := csv.NewReader(strings.NewReader(string(output))).ReadAll()
+	if err != nil || len(records) == 0 || len(records[0]) < 2 {
+		return ""
+	}
+	return records[0][0]
+}
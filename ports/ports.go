@@ -0,0 +1,99 @@
+package ports
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Owner is a process that listens on a port.
+type Owner struct {
+	PID int
+	// Name is the executable name of the process, e.g. "python3".
+	Name string
+	// Command is the command line of the process if it is known.
+	Command string
+}
+
+// IsLangforge reports whether the process is a langforge instance.
+func (o *Owner) IsLangforge() bool {
+	names := []string{"langforge"}
+	if executable, err := os.Executable(); err == nil {
+		names = append(names, filepath.Base(executable))
+	}
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".exe")
+		if strings.TrimSuffix(o.Name, ".exe") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the process, e.g. "langforge (pid 1234)".
+func (o *Owner) String() string {
+	name := o.Name
+	if name == "" {
+		name = "unknown process"
+	}
+	return fmt.Sprintf("%s (pid %d)", name, o.PID)
+}
+
+// Available reports whether the TCP port can be bound on all interfaces.
+func Available(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// NextFree returns the first available port after port.
+func NextFree(port int) (int, error) {
+	for candidate := port + 1; candidate <= 65535 && candidate <= port+100; candidate++ {
+		if Available(candidate) {
+			return candidate, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port found after %d", port)
+}
+
+// FindOwner returns the process that listens on the TCP port, or nil if no
+// process does or it cannot be determined, e.g. because it belongs to another
+// user.
+func FindOwner(port int) (*Owner, error) {
+	return findOwner(port)
+}
+
+// Stop terminates the process and waits until the port is available. The
+// process is killed if it does not exit within the timeout.
+func Stop(owner *Owner, port int, timeout time.Duration) error {
+	process, err := os.FindProcess(owner.PID)
+	if err != nil {
+		return err
+	}
+	if err := terminate(process); err != nil {
+		return fmt.Errorf("error stopping %s: %v", owner, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if Available(port) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	process.Kill()
+	for i := 0; i < 25; i++ {
+		if Available(port) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("port %d is still in use after stopping %s", port, owner)
+}
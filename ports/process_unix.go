@@ -0,0 +1,13 @@
+//go:build !windows
+
+package ports
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminate asks the process to exit.
+func terminate(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package ports
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// terminate asks the process to exit. taskkill without /F sends a close
+// request, which console processes may ignore until they are killed.
+func terminate(process *os.Process) error {
+	exec.Command("taskkill", "/PID", strconv.Itoa(process.Pid)).Run()
+	return nil
+}
@@ -0,0 +1,267 @@
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/provider"
+	"langforge/python"
+	"langforge/vectorstore"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Check is the outcome of one preflight check. A check without problems
+// passed, unless it was skipped.
+type Check struct {
+	Name     string
+	Problems []string
+	Hint     string
+	Skipped  string
+}
+
+// OK reports whether the check passed or was skipped.
+func (c *Check) OK() bool {
+	return len(c.Problems) == 0
+}
+
+// Options configures the preflight checks of a project.
+type Options struct {
+	Dir      string
+	Notebook string
+	Config   *project.Config
+	// Env holds the variables of the project's .env file.
+	Env map[string]string
+	// Required are environment variables that must be set, usually the API
+	// keys of the installed integrations.
+	Required []string
+	Timeout  time.Duration
+	// MockLLM is set if the provider is replaced by a mock server or a
+	// replayed cassette.
+	MockLLM bool
+}
+
+// Run runs all preflight checks for serving the notebook.
+func Run(ctx context.Context, options Options) []*Check {
+	env := mergedEnv(options.Env)
+
+	return []*Check{
+		checkEnv(options, env),
+		checkProvider(ctx, options, env),
+		checkVectorStore(options),
+		checkImports(options),
+	}
+}
+
+// mergedEnv returns the environment the worker sees: variables of the process
+// take precedence over those in .env, as with python-dotenv.
+func mergedEnv(dotenv map[string]string) map[string]string {
+	env := map[string]string{}
+	for key, value := range dotenv {
+		env[key] = value
+	}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+func checkEnv(options Options, env map[string]string) *Check {
+	check := &Check{
+		Name: "Environment variables",
+		Hint: "Set the missing variables in .env or run 'langforge keys'.",
+	}
+
+	required := map[string]bool{}
+	for _, key := range options.Required {
+		required[key] = true
+	}
+	// variables referenced in langforge.yaml
+	reference := func(value string) {
+		os.Expand(value, func(key string) string {
+			required[key] = true
+			return ""
+		})
+	}
+	for _, chain := range options.Config.Chains {
+		for _, value := range chain.Env {
+			reference(value)
+		}
+		if chain.Guardrails != nil && chain.Guardrails.Moderation != nil {
+			for _, value := range chain.Guardrails.Moderation.Headers {
+				reference(value)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(required))
+	for key := range required {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if env[key] == "" {
+			check.Problems = append(check.Problems, key+" is not set")
+		}
+	}
+	return check
+}
+
+// modelPattern finds model names passed to LangChain models in a notebook.
+var modelPattern = regexp.MustCompile(`\b(?:model_name|model)\s*=\s*["']([^"']+)["']`)
+
+func checkProvider(ctx context.Context, options Options, env map[string]string) *Check {
+	check := &Check{
+		Name: "Provider API key and models",
+		Hint: "Check the API keys of the providers, e.g. OPENAI_API_KEY, ANTHROPIC_API_KEY or GOOGLE_API_KEY, and the model names used in the notebook.",
+	}
+
+	if options.MockLLM {
+		check.Skipped = "LLM responses are mocked"
+		return check
+	}
+
+	// the models of all providers with a key, so that a notebook may use
+	// several
+	available := map[string]bool{}
+	checked := 0
+	for _, info := range provider.Providers {
+		if env[info.APIKey] == "" {
+			continue
+		}
+		checked++
+		client, err := provider.New(info.Name, env)
+		if err != nil {
+			check.Problems = append(check.Problems, err.Error())
+			continue
+		}
+		lister, ok := client.(provider.ModelLister)
+		if !ok {
+			continue
+		}
+		listCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+		models, err := lister.Models(listCtx)
+		cancel()
+		if err != nil {
+			check.Problems = append(check.Problems, err.Error())
+			continue
+		}
+		for _, model := range models {
+			available[model] = true
+		}
+	}
+	if checked == 0 {
+		check.Skipped = "no provider API key set"
+		return check
+	}
+	if len(check.Problems) > 0 || len(available) == 0 {
+		return check
+	}
+
+	data, err := os.ReadFile(options.Notebook)
+	if err != nil {
+		return check
+	}
+	seen := map[string]bool{}
+	for _, match := range modelPattern.FindAllStringSubmatch(string(data), -1) {
+		model := strings.TrimPrefix(match[1], "models/")
+		if seen[model] {
+			continue
+		}
+		seen[model] = true
+		if !available[model] && providerKeySet(model, env) {
+			check.Problems = append(check.Problems, fmt.Sprintf("model %q is not available for this API key", model))
+		}
+	}
+	return check
+}
+
+// providerKeySet reports whether the API key of the provider of a model is
+// set, guessed from the price catalog; models of unknown providers count as
+// set.
+func providerKeySet(model string, env map[string]string) bool {
+	price, ok := provider.Price(model)
+	if !ok {
+		return true
+	}
+	for _, info := range provider.Providers {
+		for _, p := range info.Models {
+			if p == price {
+				return env[info.APIKey] != ""
+			}
+		}
+	}
+	return true
+}
+
+func checkVectorStore(options Options) *Check {
+	check := &Check{
+		Name: "Vector store",
+		Hint: "Run 'langforge vectorstore init'.",
+	}
+
+	if options.Config.VectorStore == (project.VectorStoreConfig{}) {
+		check.Skipped = "not configured"
+		return check
+	}
+
+	store, err := vectorstore.New(options.Dir, options.Config)
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return check
+	}
+	status, err := store.Status()
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return check
+	}
+	if !status.Available {
+		check.Problems = append(check.Problems, fmt.Sprintf("%s is not available at %s", status.Type, status.Location))
+	}
+	return check
+}
+
+func checkImports(options Options) *Check {
+	check := &Check{
+		Name: "Worker imports",
+		Hint: "Install the missing packages with pip or 'langforge integrations'.",
+	}
+
+	if filepath.Ext(options.Notebook) != ".ipynb" {
+		check.Skipped = "not a notebook"
+		return check
+	}
+
+	script, err := python.PreflightImportsPy()
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return check
+	}
+
+	output, err := python.RunScript(script, options.Notebook)
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return check
+	}
+
+	var failures []struct {
+		Module string `json:"module"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(output, &failures); err != nil {
+		check.Problems = append(check.Problems, fmt.Sprintf("unexpected output of the import check: %v", err))
+		return check
+	}
+	for _, failure := range failures {
+		check.Problems = append(check.Problems, fmt.Sprintf("%s: %s", failure.Module, failure.Error))
+	}
+	return check
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package processes
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+var errUnsupported = errors.New("not supported")
+
+// alive reports whether a process with the PID exists, also if it belongs to
+// another user.
+func alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+func kill(pid int) {
+	syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// hangup sends SIGHUP, on which a gateway restarts its worker.
+func hangup(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
+
+// detach starts the command in a session of its own, so that it keeps running
+// when the terminal of langforge is closed.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
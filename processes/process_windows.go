@@ -0,0 +1,55 @@
+//go:build windows
+
+package processes
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+var errUnsupported = errors.New("not supported on Windows")
+
+// stillActive is the exit code of processes that have not exited.
+const stillActive = 259
+
+// alive reports whether a process with the PID is running.
+func alive(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// processes of other users cannot be opened
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(handle)
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// terminate ends the process and the processes it started. Console processes
+// ignore the close request of taskkill without /F, so they are killed.
+func terminate(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+func kill(pid int) {
+	terminate(pid)
+}
+
+// hangup is not supported, since Windows has no SIGHUP.
+func hangup(pid int) error {
+	return errUnsupported
+}
+
+// detach starts the command without a console, so that it keeps running when
+// the console of langforge is closed.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS,
+	}
+}
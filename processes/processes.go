@@ -0,0 +1,256 @@
+package processes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"langforge/project"
+	"langforge/state"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kinds of the processes that langforge starts.
+const (
+	// Serve is the gateway of 'langforge serve'.
+	Serve = "serve"
+	// Worker is the Python or Node.js server of the chains of a gateway or of
+	// 'langforge repl'.
+	Worker = "worker"
+	// Lab is the JupyterLab of 'langforge lab'.
+	Lab = "lab"
+	// Job is a job that runs in the background, see 'langforge jobs'.
+	Job = "job"
+)
+
+// collection holds the processes in the global state of the user.
+const collection = "processes"
+
+// Process is a long-running process that langforge started in a project.
+type Process struct {
+	PID  int    `json:"pid"`
+	Kind string `json:"kind"`
+	// Port is the port that the process serves on, or zero.
+	Port    int    `json:"port,omitempty"`
+	Project string `json:"project"`
+	Dir     string `json:"dir"`
+	// Parent is the langforge process that started the process, which
+	// stops it when it exits, or zero.
+	Parent int `json:"parent,omitempty"`
+	// Args are the arguments of the langforge command that starts the
+	// process again, or nil if it cannot be restarted on its own.
+	Args    []string  `json:"args,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+// Running reports whether the process is still running.
+func (p *Process) Running() bool {
+	return alive(p.PID)
+}
+
+// Orphaned reports whether the langforge process that started the process has
+// exited without stopping it, which leaves it running without a purpose.
+func (p *Process) Orphaned() bool {
+	return p.Parent != 0 && !alive(p.Parent)
+}
+
+// Register records a process that langforge started in the project in dir in
+// the registry of the user.
+func Register(p *Process) error {
+	dir, err := filepath.Abs(p.Dir)
+	if err != nil {
+		return err
+	}
+	p.Dir = dir
+	if p.Project == "" {
+		config, err := project.LoadConfig(dir)
+		if err != nil {
+			return err
+		}
+		p.Project = config.Name
+	}
+	if p.Started.IsZero() {
+		p.Started = time.Now()
+	}
+
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Put(collection, strconv.Itoa(p.PID), p)
+}
+
+// RegisterSelf records the running langforge command as a process of the
+// given kind, which is restarted with the same arguments, and returns a
+// function that removes it from the registry.
+func RegisterSelf(kind string, dir string, port int) (func(), error) {
+	p := &Process{
+		PID:  os.Getpid(),
+		Kind: kind,
+		Port: port,
+		Dir:  dir,
+		Args: os.Args[1:],
+	}
+	if err := Register(p); err != nil {
+		return func() {}, err
+	}
+	return func() { Unregister(p.PID) }, nil
+}
+
+// Unregister removes a process from the registry.
+func Unregister(pid int) error {
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Delete(collection, strconv.Itoa(pid))
+}
+
+// List returns the running processes of the registry, the oldest first.
+// Processes that have exited are removed.
+func List() ([]*Process, error) {
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	records, err := store.List(collection)
+	if err != nil {
+		return nil, err
+	}
+	processes := []*Process{}
+	for _, record := range records {
+		p := &Process{}
+		if err := json.Unmarshal(record.Value, p); err != nil {
+			return nil, err
+		}
+		if !p.Running() {
+			if err := store.Delete(collection, record.Key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		processes = append(processes, p)
+	}
+	sort.SliceStable(processes, func(i, j int) bool {
+		return processes[i].Started.Before(processes[j].Started)
+	})
+	return processes, nil
+}
+
+// Find returns the running process with the given PID from the registry.
+func Find(pid int) (*Process, error) {
+	processes, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range processes {
+		if p.PID == pid {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no process with pid %d was started by langforge, run 'langforge ps' to list them", pid)
+}
+
+// Stop asks the process to exit, kills it if it has not exited after the
+// timeout, and then stops the processes it started that are still running.
+func Stop(p *Process, timeout time.Duration) error {
+	if err := terminate(p.PID); err != nil && p.Running() {
+		return fmt.Errorf("error stopping %s: %v", p, err)
+	}
+	if !wait(p.PID, timeout) {
+		kill(p.PID)
+		if !wait(p.PID, 5*time.Second) {
+			return fmt.Errorf("%s did not exit", p)
+		}
+	}
+	Unregister(p.PID)
+
+	processes, err := List()
+	if err != nil {
+		return err
+	}
+	for _, child := range processes {
+		if child.Parent == p.PID {
+			if err := Stop(child, timeout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ErrNotRestartable is returned by Restart for processes that are restarted
+// by the process that started them, such as workers.
+var ErrNotRestartable = errors.New("the process cannot be restarted on its own")
+
+// Restart restarts a process. A gateway on Linux and macOS restarts its
+// worker without downtime on SIGHUP; other processes are stopped and started
+// again in the background, with their output written to a log in the state
+// directory of the project, which Restart returns.
+func Restart(p *Process, timeout time.Duration) (string, error) {
+	if len(p.Args) == 0 {
+		return "", ErrNotRestartable
+	}
+	if p.Kind == Serve {
+		if err := hangup(p.PID); err == nil {
+			return "", nil
+		} else if err != errUnsupported {
+			return "", err
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	stateDir, err := project.EnsureStateDir(p.Dir)
+	if err != nil {
+		return "", err
+	}
+	logPath := filepath.Join(stateDir, p.Kind+".log")
+	log, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer log.Close()
+
+	if err := Stop(p, timeout); err != nil {
+		return "", err
+	}
+	cmd := exec.Command(executable, p.Args...)
+	cmd.Dir = p.Dir
+	cmd.Stdout = log
+	cmd.Stderr = log
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	cmd.Process.Release()
+	return logPath, nil
+}
+
+// String describes the process, e.g. "serve of support-bot (pid 1234)".
+func (p *Process) String() string {
+	return fmt.Sprintf("%s of %s (pid %d)", p.Kind, p.Project, p.PID)
+}
+
+// wait waits until the process has exited or the timeout has passed, and
+// reports whether it has exited.
+func wait(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
@@ -0,0 +1,288 @@
+package processes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// States of a supervised process.
+const (
+	// Running is a child that is running.
+	Running = "running"
+	// Restarting is a child that crashed and is started again after a delay.
+	Restarting = "restarting"
+	// Stopped is a child that exited without an error or was stopped.
+	Stopped = "stopped"
+	// Failed is a child that crashed too often to be restarted again.
+	Failed = "failed"
+)
+
+const (
+	// defaultMaxRestarts is how many times a child is restarted within
+	// restartWindow unless the supervisor sets MaxRestarts.
+	defaultMaxRestarts = 5
+	// restartWindow is the time within which the restarts of a child are
+	// counted.
+	restartWindow = 10 * time.Minute
+	// maxRestartDelay bounds the delay before a restart.
+	maxRestartDelay = 30 * time.Second
+)
+
+// Supervisor runs a long-running child process, such as JupyterLab, and
+// restarts it when it crashes, waiting longer after each crash. A child that
+// exits without an error is not restarted. ExecuteCommands runs commands to
+// completion; a supervisor keeps a server running until it is stopped.
+type Supervisor struct {
+	// Name describes the child in errors, e.g. "JupyterLab".
+	Name string
+	// Command returns the command of each start of the child.
+	Command func() *exec.Cmd
+	// Output receives the output of the child, if set.
+	Output io.Writer
+	// LogPath is a file that the output of the child is appended to, if set.
+	LogPath string
+	// MaxRestarts is how many times the child is restarted within ten
+	// minutes before the supervisor gives up, by default 5.
+	MaxRestarts int
+	// OnRestart is called before the child is restarted after it crashed.
+	OnRestart func(crash error, delay time.Duration)
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	log      *os.File
+	state    string
+	started  time.Time
+	restarts []time.Time
+	lastExit error
+	// failure is why the supervisor gave up on the child.
+	failure  error
+	stopping bool
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+// SupervisorStatus describes a supervised child.
+type SupervisorStatus struct {
+	// State is Running, Restarting, Stopped or Failed.
+	State string
+	// PID is the process of the running child, or zero.
+	PID int
+	// Started is when the child was last started.
+	Started time.Time
+	// Restarts counts the restarts within the last ten minutes.
+	Restarts int
+	// LastExit is why the child last exited, nil if it has not exited or
+	// exited without an error.
+	LastExit error
+}
+
+// Start starts the child and supervises it in the background until it is
+// stopped, exits without an error or crashes too often.
+func (s *Supervisor) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return fmt.Errorf("%s was already started", s.Name)
+	}
+	if s.LogPath != "" {
+		if err := os.MkdirAll(filepath.Dir(s.LogPath), 0755); err != nil {
+			return err
+		}
+		log, err := os.OpenFile(s.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		s.log = log
+	}
+	if err := s.startLocked(); err != nil {
+		if s.log != nil {
+			s.log.Close()
+		}
+		return err
+	}
+	s.wake = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.supervise()
+	return nil
+}
+
+// startLocked starts the child. The caller holds mu.
+func (s *Supervisor) startLocked() error {
+	cmd := s.Command()
+	writers := []io.Writer{}
+	if s.Output != nil {
+		writers = append(writers, s.Output)
+	}
+	if s.log != nil {
+		writers = append(writers, s.log)
+	}
+	if len(writers) > 0 {
+		output := io.MultiWriter(writers...)
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", s.Name, err)
+	}
+	s.cmd = cmd
+	s.state = Running
+	s.started = time.Now()
+	return nil
+}
+
+// supervise waits for each run of the child and restarts it after crashes.
+func (s *Supervisor) supervise() {
+	defer close(s.done)
+	defer func() {
+		if s.log != nil {
+			s.log.Close()
+		}
+	}()
+
+	for {
+		s.mu.Lock()
+		cmd := s.cmd
+		s.mu.Unlock()
+		err := cmd.Wait()
+
+		s.mu.Lock()
+		s.cmd = nil
+		s.lastExit = err
+		if s.stopping || err == nil {
+			s.state = Stopped
+			s.mu.Unlock()
+			return
+		}
+		restart, delay := s.nextRestart()
+		if !restart {
+			s.state = Failed
+			s.failure = fmt.Errorf("%s crashed %d times within ten minutes, the last time with: %v", s.Name, len(s.restarts)+1, err)
+			s.mu.Unlock()
+			return
+		}
+		s.state = Restarting
+		s.mu.Unlock()
+
+		if s.OnRestart != nil {
+			s.OnRestart(err, delay)
+		}
+		select {
+		case <-s.wake:
+		case <-time.After(delay):
+		}
+
+		s.mu.Lock()
+		if s.stopping {
+			s.state = Stopped
+			s.mu.Unlock()
+			return
+		}
+		if err := s.startLocked(); err != nil {
+			s.state = Failed
+			s.failure = err
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// nextRestart reports whether the child is restarted after a crash and how
+// long to wait before. The caller holds mu.
+func (s *Supervisor) nextRestart() (bool, time.Duration) {
+	max := s.MaxRestarts
+	if max == 0 {
+		max = defaultMaxRestarts
+	}
+	now := time.Now()
+	recent := []time.Time{}
+	for _, t := range s.restarts {
+		if now.Sub(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	s.restarts = recent
+	if len(recent) >= max {
+		return false, 0
+	}
+	s.restarts = append(s.restarts, now)
+
+	// back off from a child that crashes right after it started
+	delay := time.Second << len(recent)
+	if delay > maxRestartDelay {
+		delay = maxRestartDelay
+	}
+	return true, delay
+}
+
+// Stop asks the child to exit, kills it if it has not exited after the
+// timeout, and ends the supervision.
+func (s *Supervisor) Stop(timeout time.Duration) error {
+	s.mu.Lock()
+	if s.done == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	if !s.stopping {
+		s.stopping = true
+		close(s.wake)
+	}
+	cmd := s.cmd
+	s.mu.Unlock()
+
+	if cmd != nil {
+		if err := terminate(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+		}
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+	}
+	if cmd != nil {
+		kill(cmd.Process.Pid)
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("%s did not exit", s.Name)
+	}
+}
+
+// Status describes the child.
+func (s *Supervisor) Status() SupervisorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := SupervisorStatus{
+		State:    s.state,
+		Started:  s.started,
+		Restarts: len(s.restarts),
+		LastExit: s.lastExit,
+	}
+	if s.cmd != nil {
+		status.PID = s.cmd.Process.Pid
+	}
+	return status
+}
+
+// Done is closed when the supervision of a started child has ended.
+func (s *Supervisor) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// Err returns why the supervision ended: nil if the child was stopped or
+// exited without an error, otherwise why it was not restarted.
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failure
+}
@@ -0,0 +1,316 @@
+package project
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFileName is the name of the manifest stored at the root of a project archive.
+const ManifestFileName = "langforge-export.yaml"
+
+// Manifest describes the contents of a project archive and the toolchain
+// that was used to create it.
+type Manifest struct {
+	Name          string   `yaml:"name"`
+	Python        string   `yaml:"python,omitempty"`
+	Platform      string   `yaml:"platform,omitempty"`
+	ApiKeys       []string `yaml:"apiKeys,omitempty"`
+	IncludesEnv   bool     `yaml:"includesEnv"`
+	ExportedFiles int      `yaml:"exportedFiles"`
+}
+
+// defaultExcludes are directory and file names that are never exported.
+var defaultExcludes = map[string]bool{
+	".git":               true,
+	".venv":              true,
+	".conda":             true,
+	StateDirName:         true,
+	"__pycache__":        true,
+	".ipynb_checkpoints": true,
+	"node_modules":       true,
+}
+
+// ArchiveOptions controls which files are written to a project archive.
+type ArchiveOptions struct {
+	IncludeEnv bool
+	Exclude    []string
+	// Contents are files that the archive stores with the given contents
+	// instead of those in the project, by their slash separated path in the
+	// project, e.g. a freshly pinned requirements.txt.
+	Contents map[string][]byte
+}
+
+func (o ArchiveOptions) excluded(rel string, name string) bool {
+	if rel == ".env" {
+		return !o.IncludeEnv
+	}
+	if defaultExcludes[name] {
+		return true
+	}
+	for _, pattern := range o.Exclude {
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteArchive writes the project in dir to a gzipped tar archive at path. The
+// manifest is stored as the first entry of the archive.
+func WriteArchive(dir string, path string, manifest *Manifest, options ArchiveOptions) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	files := []string{}
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if p == absPath || options.excluded(filepath.ToSlash(rel), info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := options.Contents[filepath.ToSlash(rel)]; ok {
+			return nil
+		}
+		if info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0 {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	contents := []string{}
+	for name := range options.Contents {
+		contents = append(contents, name)
+	}
+	sort.Strings(contents)
+
+	manifest.IncludesEnv = options.IncludeEnv
+	manifest.ExportedFiles = len(files) + len(contents)
+
+	manifestData, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name: ManifestFileName,
+		Mode: 0644,
+		Size: int64(len(manifestData)),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifestData); err != nil {
+		return err
+	}
+
+	for _, rel := range files {
+		err = addFileToArchive(tw, filepath.Join(dir, rel), filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range contents {
+		data := options.Contents[name]
+		err = tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFileToArchive(tw *tar.Writer, path string, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// ExtractArchive extracts a project archive into dir and returns its manifest.
+func ExtractArchive(path string, dir string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %v", path, err)
+	}
+	defer gz.Close()
+
+	// links are resolved against the real directory, e.g. on macOS, where
+	// /tmp is a link to /private/tmp
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	foundManifest := false
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Name == ManifestFileName {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := yaml.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", ManifestFileName, err)
+			}
+			foundManifest = true
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("invalid file path in archive: %s", header.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		// an earlier link of the archive must not lead the entry out of dir
+		parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+		if err != nil {
+			return nil, err
+		}
+		if !withinDir(root, parent) {
+			return nil, fmt.Errorf("invalid file path in archive, it leads out of the project through a link: %s", header.Name)
+		}
+		target = filepath.Join(parent, filepath.Base(target))
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return nil, fmt.Errorf("invalid file path in archive, it replaces a link: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink:
+			link := filepath.FromSlash(header.Linkname)
+			if filepath.IsAbs(link) || !withinDir(root, filepath.Join(parent, link)) {
+				return nil, fmt.Errorf("invalid link in archive, %s points out of the project to %s", header.Name, header.Linkname)
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0777)
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !foundManifest {
+		return nil, fmt.Errorf("%s is not a LangForge project archive", path)
+	}
+
+	return manifest, nil
+}
+
+// withinDir reports whether path is root or inside it. Both are absolute
+// and clean.
+func withinDir(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,701 @@
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the project configuration file that lives
+// in the root directory of a LangForge application.
+const ConfigFileName = "langforge.yaml"
+
+// Config represents the contents of a project's langforge.yaml file.
+type Config struct {
+	Name        string            `yaml:"name"`
+	Version     string            `yaml:"version,omitempty"`
+	Chains      []ChainConfig     `yaml:"chains,omitempty"`
+	Package     PackageConfig     `yaml:"package,omitempty"`
+	Evals       []EvalConfig      `yaml:"evals,omitempty"`
+	Datasets    []DatasetConfig   `yaml:"datasets,omitempty"`
+	VectorStore VectorStoreConfig `yaml:"vectorstore,omitempty"`
+	Lint        LintConfig        `yaml:"lint,omitempty"`
+	State       StateConfig       `yaml:"state,omitempty"`
+	Auth        *AuthConfig       `yaml:"auth,omitempty"`
+	Gateway     GatewayConfig     `yaml:"gateway,omitempty"`
+	Models      ModelsConfig      `yaml:"models,omitempty"`
+	Schedule    []TaskConfig      `yaml:"schedule,omitempty"`
+	// Notifications are the channels that notifications are sent to, e.g.
+	// when a scheduled task fails.
+	Notifications []NotificationConfig `yaml:"notifications,omitempty"`
+	Budget        BudgetConfig         `yaml:"budget,omitempty"`
+	Presets       []PresetConfig       `yaml:"presets,omitempty"`
+	Worker        WorkerConfig         `yaml:"worker,omitempty"`
+	Overlays      OverlaysConfig       `yaml:"overlays,omitempty"`
+}
+
+// ChainConfig declares a chain that is defined in one of the project's notebooks.
+type ChainConfig struct {
+	Name        string           `yaml:"name"`
+	Notebook    string           `yaml:"notebook"`
+	Description string           `yaml:"description,omitempty"`
+	Guardrails  *GuardrailConfig `yaml:"guardrails,omitempty"`
+	// Env holds environment variables that are only set while the chain runs.
+	// Values are expanded with the environment of the serve command.
+	Env    map[string]string `yaml:"env,omitempty"`
+	Canary *CanaryConfig     `yaml:"canary,omitempty"`
+	// Warmup lists sample inputs that the chain is invoked with when a
+	// server starts, before it reports ready.
+	Warmup []map[string]any `yaml:"warmup,omitempty"`
+	// Preset names the preset that requests use unless they select another.
+	Preset string `yaml:"preset,omitempty"`
+	// Output declares the structured output of the chain, which the gateway
+	// validates.
+	Output *OutputConfig `yaml:"output,omitempty"`
+	// Transforms post-process the outputs of the chain, in order, before the
+	// gateway returns them.
+	Transforms []TransformConfig `yaml:"transforms,omitempty"`
+}
+
+// TransformConfig is a step of the post-processing of the outputs of a chain,
+// which keeps presentation out of its prompts. Type is one of "markdown",
+// which converts Markdown to HTML, "citations", which rewrites the targets of
+// links and citations such as [docs/guide.md] that begin with a key of Links
+// to begin with its value, "profanity", which masks profane words with
+// asterisks, those of Words instead of a default English list if it is set,
+// or "whitespace", which removes trailing whitespace from lines, repeated
+// blank lines and whitespace around the text. Outputs limits the step to
+// these outputs, all text outputs if empty.
+type TransformConfig struct {
+	Type    string            `yaml:"type"`
+	Outputs []string          `yaml:"outputs,omitempty"`
+	Links   map[string]string `yaml:"links,omitempty"`
+	Words   []string          `yaml:"words,omitempty"`
+}
+
+// OutputConfig declares the JSON schema that the outputs of a chain must
+// match. Key names the output whose text holds the JSON, e.g. the answer of
+// an LLM, which is returned parsed; without Key, the outputs themselves are
+// validated. Invalid outputs are retried up to Retries times with
+// RepairPrompt appended to the input of the chain, where {error}, {schema}
+// and {output} are replaced with the violation, the schema and the invalid
+// output.
+type OutputConfig struct {
+	Schema       map[string]any `yaml:"schema"`
+	Key          string         `yaml:"key,omitempty"`
+	Retries      int            `yaml:"retries,omitempty"`
+	RepairPrompt string         `yaml:"repairPrompt,omitempty"`
+}
+
+// CanaryConfig routes a percentage of the requests of a chain to an alternate
+// version: another chain of the notebook, the same chain with other env
+// variables (e.g. a different model), or both. Env is merged over the env of
+// the chain.
+type CanaryConfig struct {
+	Percent float64           `yaml:"percent"`
+	Chain   string            `yaml:"chain,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+}
+
+// GuardrailConfig configures the filters the serve gateway applies to the
+// inputs and outputs of a chain. PII is one of "off", "block" or "redact".
+type GuardrailConfig struct {
+	MaxPromptLength int               `yaml:"maxPromptLength,omitempty"`
+	BlockedPatterns []string          `yaml:"blockedPatterns,omitempty"`
+	PII             string            `yaml:"pii,omitempty"`
+	Moderation      *ModerationConfig `yaml:"moderation,omitempty"`
+}
+
+// ModerationConfig points the gateway to an external moderation endpoint.
+// Header values may reference environment variables, e.g. "Bearer ${API_KEY}".
+type ModerationConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Timeout string            `yaml:"timeout,omitempty"`
+	Outputs bool              `yaml:"outputs,omitempty"`
+}
+
+// PackageConfig holds the settings used when exporting the project as a Python package.
+type PackageConfig struct {
+	Name         string   `yaml:"name,omitempty"`
+	Version      string   `yaml:"version,omitempty"`
+	Description  string   `yaml:"description,omitempty"`
+	Dependencies []string `yaml:"dependencies,omitempty"`
+	Index        string   `yaml:"index,omitempty"`
+}
+
+// EvalConfig declares an evaluation that runs a chain against a dataset.
+type EvalConfig struct {
+	Name        string         `yaml:"name"`
+	Chain       string         `yaml:"chain"`
+	Dataset     string         `yaml:"dataset"`
+	ExpectedKey string         `yaml:"expectedKey,omitempty"`
+	OutputKey   string         `yaml:"outputKey,omitempty"`
+	Scorers     []ScorerConfig `yaml:"scorers,omitempty"`
+	Threshold   float64        `yaml:"threshold,omitempty"`
+	// Baseline is the report that runs are compared with, by default
+	// evals/baselines/<name>.json.
+	Baseline string `yaml:"baseline,omitempty"`
+	// Preset is the preset that the chain is invoked with, by default the
+	// preset of the chain. Temperature and Seed override those of the
+	// preset, so that runs sample alike and their scores can be compared.
+	// The seed is also passed to the llm scorers, which always judge at
+	// temperature 0.
+	Preset      string   `yaml:"preset,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	Seed        *int     `yaml:"seed,omitempty"`
+}
+
+// ScorerConfig configures a scorer of an evaluation. Type is one of "exact",
+// "contains", "regex" or "llm". Name keys the scores of the scorer in reports,
+// by default its type, and must be unique within the evaluation, so scorers
+// of the same type need names.
+type ScorerConfig struct {
+	Name       string `yaml:"name,omitempty"`
+	Type       string `yaml:"type"`
+	Pattern    string `yaml:"pattern,omitempty"`
+	IgnoreCase bool   `yaml:"ignoreCase,omitempty"`
+	Provider   string `yaml:"provider,omitempty"`
+	Model      string `yaml:"model,omitempty"`
+	Criteria   string `yaml:"criteria,omitempty"`
+}
+
+// DatasetConfig registers a dataset by name. Source is either a path relative to
+// the project directory or a URL that is downloaded into the project's cache.
+type DatasetConfig struct {
+	Name        string `yaml:"name"`
+	Source      string `yaml:"source"`
+	SHA256      string `yaml:"sha256,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// VectorStoreConfig configures the project's local vector store. Type is "chroma",
+// "qdrant" or "pgvector", Mode is "embedded" (a directory used by the client
+// library), "docker" (a local server container) or "external" (a server at URL
+// that langforge does not manage). Docs is the directory whose documents
+// are ingested; with AutoIngest set, "serve --dev" re-ingests them on changes.
+type VectorStoreConfig struct {
+	Type       string `yaml:"type,omitempty"`
+	Mode       string `yaml:"mode,omitempty"`
+	Path       string `yaml:"path,omitempty"`
+	URL        string `yaml:"url,omitempty"`
+	Collection string `yaml:"collection,omitempty"`
+	Ingest     string `yaml:"ingest,omitempty"`
+	Docs       string `yaml:"docs,omitempty"`
+	AutoIngest bool   `yaml:"autoIngest,omitempty"`
+	// EmbeddingsCache keeps the embeddings of chunks by the hash of their
+	// text, so that re-ingesting unchanged chunks does not embed them again.
+	EmbeddingsCache *EmbeddingsCacheConfig `yaml:"embeddingsCache,omitempty"`
+	// Ingestion declares how 'langforge ingest' ingests documents, in place
+	// of an ingest script.
+	Ingestion *IngestionConfig `yaml:"ingestion,omitempty"`
+}
+
+// IngestionConfig declares the sources of documents of a project, how they
+// are loaded and split into chunks, and the model that embeds the chunks.
+// ChunkSize and ChunkOverlap, in characters, apply to the sources that do not
+// set their own.
+type IngestionConfig struct {
+	Embeddings   EmbeddingsConfig  `yaml:"embeddings,omitempty"`
+	ChunkSize    int               `yaml:"chunkSize,omitempty"`
+	ChunkOverlap *int              `yaml:"chunkOverlap,omitempty"`
+	Sources      []IngestionSource `yaml:"sources,omitempty"`
+	Tune         *TuneConfig       `yaml:"tune,omitempty"`
+}
+
+// TuneConfig configures the benchmark of chunking configurations of "langforge
+// ingest tune": every combination of Strategies ("recursive", "character",
+// "markdown" or "token"), ChunkSizes and ChunkOverlaps is scored on the
+// questions of a dataset, by path or registered name, or of the dataset of
+// the evaluation Eval. QuestionKey is the field of the question, and K the
+// number of chunks retrieved per question.
+type TuneConfig struct {
+	Eval          string   `yaml:"eval,omitempty"`
+	Dataset       string   `yaml:"dataset,omitempty"`
+	QuestionKey   string   `yaml:"questionKey,omitempty"`
+	Strategies    []string `yaml:"strategies,omitempty"`
+	ChunkSizes    []int    `yaml:"chunkSizes,omitempty"`
+	ChunkOverlaps []int    `yaml:"chunkOverlaps,omitempty"`
+	K             int      `yaml:"k,omitempty"`
+}
+
+// EmbeddingsConfig selects the embedding model of the ingestion: "openai",
+// "azure-openai", "huggingface", "ollama", "cohere", "gemini" or the LangChain
+// embeddings class as "module:Class". Options are passed to the class, e.g.
+// {model: text-embedding-3-small}.
+type EmbeddingsConfig struct {
+	Model   string         `yaml:"model,omitempty"`
+	Options map[string]any `yaml:"options,omitempty"`
+}
+
+// IngestionSource is a directory or file of documents, or the objects under a
+// prefix of an object store: s3://bucket/prefix, gs://bucket/prefix or
+// azure://account/container/prefix. Include and Exclude
+// are glob patterns relative to Path, where "**" matches any number of
+// directories and patterns without a slash match file names. Loader is
+// "auto" (by file extension), "text", "markdown", "pdf", "html", "csv" or a
+// LangChain document loader as "module:Class". Metadata is added to every
+// chunk; its values may contain {path}, {dir}, {name}, {stem} and {ext} of the
+// document. FrontMatter moves the YAML front matter of documents into the
+// metadata of their chunks. Credentials are passed to the client of an object
+// store, e.g. aws_access_key_id, and may reference variables of the
+// environment, the .env file or the keys of the user as ${NAME}; without
+// them the client finds its credentials as usual.
+type IngestionSource struct {
+	Name         string            `yaml:"name,omitempty"`
+	Path         string            `yaml:"path"`
+	Include      []string          `yaml:"include,omitempty"`
+	Exclude      []string          `yaml:"exclude,omitempty"`
+	Loader       string            `yaml:"loader,omitempty"`
+	ChunkSize    int               `yaml:"chunkSize,omitempty"`
+	ChunkOverlap *int              `yaml:"chunkOverlap,omitempty"`
+	Metadata     map[string]string `yaml:"metadata,omitempty"`
+	FrontMatter  bool              `yaml:"frontMatter,omitempty"`
+	Credentials  map[string]string `yaml:"credentials,omitempty"`
+}
+
+// EmbeddingsCacheConfig configures the embeddings cache of a project, a SQLite
+// database at Path, by default in the state directory.
+type EmbeddingsCacheConfig struct {
+	Path string `yaml:"path,omitempty"`
+}
+
+// LintConfig selects the linters and formatters run by "langforge lint" and the
+// paths they are run on. By default they are detected from the project.
+type LintConfig struct {
+	Tools []string `yaml:"tools,omitempty"`
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+// StateConfig selects the backend that stores the project's state, such as job
+// history. Backend is "file" (JSON files, the default) or "sqlite" (a database
+// managed with the sqlite3 command). Path overrides the location of the store.
+type StateConfig struct {
+	Backend string `yaml:"backend,omitempty"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+// AuthConfig requires clients of the serve gateway to send one of the API keys
+// as a bearer token. Keys may reference environment variables, e.g.
+// "${LANGFORGE_API_KEY}". Keys lists named keys with their own limits, e.g.
+// for the teams that a deployed demo is shared with.
+type AuthConfig struct {
+	APIKeys []string       `yaml:"apiKeys,omitempty"`
+	Keys    []APIKeyConfig `yaml:"keys,omitempty"`
+}
+
+// APIKeyConfig is a named API key of the serve gateway. Requests with the key
+// may only invoke Chains, all chains if empty, and at most RequestsPerMinute
+// per minute with bursts of Burst requests. Once the cost of its requests
+// reaches a limit of Budget, the key is rejected until the next UTC day or
+// month. Analytics break the usage down by the name of the key. Only admin
+// keys may override the temperature and the seed of chains, as langforge eval
+// does.
+type APIKeyConfig struct {
+	Name              string           `yaml:"name"`
+	Key               string           `yaml:"key"`
+	Chains            []string         `yaml:"chains,omitempty"`
+	RequestsPerMinute int              `yaml:"requestsPerMinute,omitempty"`
+	Burst             int              `yaml:"burst,omitempty"`
+	Budget            *KeyBudgetConfig `yaml:"budget,omitempty"`
+	Admin             bool             `yaml:"admin,omitempty"`
+}
+
+// KeyBudgetConfig limits the cost of the requests of an API key in US dollars
+// per UTC day and month.
+type KeyBudgetConfig struct {
+	Daily   float64 `yaml:"daily,omitempty"`
+	Monthly float64 `yaml:"monthly,omitempty"`
+}
+
+// GatewayConfig configures the serve gateway. Middleware lists the middlewares
+// that handle each request, outermost first. The built-in middlewares "auth",
+// "analytics", "capture" and "guardrails" always run; those that are not
+// listed run before the listed ones in this order. "ratelimit", "cache",
+// "logging" and middlewares compiled into custom builds of langforge only run
+// if they are listed.
+type GatewayConfig struct {
+	Middleware []MiddlewareConfig `yaml:"middleware,omitempty"`
+	Server     ServerConfig       `yaml:"server,omitempty"`
+	OpenAI     *OpenAIConfig      `yaml:"openai,omitempty"`
+	Files      *FilesConfig       `yaml:"files,omitempty"`
+	Images     ImagesConfig       `yaml:"images,omitempty"`
+}
+
+// ImagesConfig limits the image inputs of chain requests, which are objects
+// {"type": "image", "mediaType": "image/png", "data": "<base64>"}. MaxSize
+// limits the size of each image, by default 5MB, and Types their media types,
+// by default PNG, JPEG, GIF and WebP.
+type ImagesConfig struct {
+	MaxSize string   `yaml:"maxSize,omitempty"`
+	Types   []string `yaml:"types,omitempty"`
+}
+
+// FilesConfig accepts uploads at /files. An upload is passed to a chain by
+// giving its ID as an input, which the chain receives as the path of the
+// file, or ingested into the vector store once. MaxSize limits the size of
+// uploads, by default 10MB, and Types their extensions, by default those of
+// the documents that the ingestion pipeline loads. Uploads are deleted after
+// TTL, by default 1h.
+type FilesConfig struct {
+	MaxSize string   `yaml:"maxSize,omitempty"`
+	Types   []string `yaml:"types,omitempty"`
+	TTL     string   `yaml:"ttl,omitempty"`
+}
+
+// OpenAIConfig serves chains in the wire format of the chat completions API
+// of OpenAI at /v1/chat/completions, with the model naming the chain, so that
+// OpenAI clients and chat UIs can use them unmodified; /v1/models lists them.
+// Chains limits the chains that are served, all if empty. The last user
+// message is the input of a chain, the earlier messages are its memory if it
+// has one.
+type OpenAIConfig struct {
+	Chains []string `yaml:"chains,omitempty"`
+}
+
+// ServerConfig configures the HTTP server of the gateway. The timeouts are
+// durations such as "30s"; those that are not set do not time out. HTTP/2 is
+// served over TLS, with TLSCert and TLSKey, unless HTTP2 is false.
+// Compression compresses the responses that are not streamed.
+type ServerConfig struct {
+	TLSCert           string             `yaml:"tlsCert,omitempty"`
+	TLSKey            string             `yaml:"tlsKey,omitempty"`
+	HTTP2             *bool              `yaml:"http2,omitempty"`
+	KeepAlive         *bool              `yaml:"keepAlive,omitempty"`
+	ReadHeaderTimeout string             `yaml:"readHeaderTimeout,omitempty"`
+	ReadTimeout       string             `yaml:"readTimeout,omitempty"`
+	WriteTimeout      string             `yaml:"writeTimeout,omitempty"`
+	IdleTimeout       string             `yaml:"idleTimeout,omitempty"`
+	Compression       *CompressionConfig `yaml:"compression,omitempty"`
+}
+
+// CompressionConfig compresses responses with gzip at Level, 1 (fastest) to 9
+// (smallest) or the default of gzip if zero, if they have at least MinSize
+// bytes, by default 1024.
+type CompressionConfig struct {
+	Level   int `yaml:"level,omitempty"`
+	MinSize int `yaml:"minSize,omitempty"`
+}
+
+// MiddlewareConfig is an entry of the gateway's middleware list. An entry may
+// also be written as just the name of the middleware.
+type MiddlewareConfig struct {
+	Name    string            `yaml:"name"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// UnmarshalYAML accepts both a middleware name and a mapping with a name and
+// options.
+func (m *MiddlewareConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		m.Name = node.Value
+		return nil
+	}
+	type plain MiddlewareConfig
+	return node.Decode((*plain)(m))
+}
+
+// ModelsConfig limits the disk space of the local model caches of Hugging Face
+// and Ollama. Quota is a size such as "50GB"; the LANGFORGE_MODEL_QUOTA
+// environment variable overrides it.
+type ModelsConfig struct {
+	Quota string `yaml:"quota,omitempty"`
+}
+
+// TaskConfig declares a task that the serve command runs on a schedule. Cron
+// is a cron expression in local time, e.g. "0 3 * * *", and Command the
+// arguments of the langforge command that is run, e.g. "vectorstore ingest".
+// Timeout is a duration such as "30m" after which the task is stopped.
+type TaskConfig struct {
+	Name    string `yaml:"name"`
+	Cron    string `yaml:"cron"`
+	Command string `yaml:"command"`
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// NotificationConfig is a channel that notifications are sent to. Type is
+// "slack" (an incoming webhook of Slack), "webhook" (a JSON POST request) or
+// "desktop" (a notification on the desktop of the machine, which needs no
+// URL). The URL and header values may reference environment variables.
+// Events restricts the channel to some events, given by their name, e.g.
+// "deploy.failed", or their group, e.g. "deploy"; by default it receives all.
+type NotificationConfig struct {
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Events  []string          `yaml:"events,omitempty"`
+}
+
+// BudgetConfig limits the cost of the LLM requests of the project, as
+// recorded by analytics, in US dollars per UTC day and month. A notification
+// is sent when the cost reaches each of Thresholds, percentages of a limit
+// that default to 80 and 100.
+type BudgetConfig struct {
+	Daily      float64   `yaml:"daily,omitempty"`
+	Monthly    float64   `yaml:"monthly,omitempty"`
+	Thresholds []float64 `yaml:"thresholds,omitempty"`
+}
+
+// PresetConfig is a named set of generation parameters that the LLMs of a
+// chain are called with instead of those of the notebook, e.g. to try another
+// temperature without editing code. Parameters that are not set keep the
+// values of the notebook. Seed asks the LLMs that support it, e.g. those of
+// OpenAI, for reproducible samples.
+type PresetConfig struct {
+	Name        string   `yaml:"name"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	MaxTokens   *int     `yaml:"maxTokens,omitempty"`
+	TopP        *float64 `yaml:"topP,omitempty"`
+	Stop        []string `yaml:"stop,omitempty"`
+	Seed        *int     `yaml:"seed,omitempty"`
+}
+
+// WorkerConfig limits the resources of the worker of the serve command and
+// restarts it when it exits. Memory is a size such as "2GB", CPUs a number of
+// CPUs such as 1.5. Restart is "never" (the default), "on-failure" or
+// "always"; a worker is restarted at most MaxRestarts times, by default 5,
+// within ten minutes.
+type WorkerConfig struct {
+	Memory      string  `yaml:"memory,omitempty"`
+	CPUs        float64 `yaml:"cpus,omitempty"`
+	Restart     string  `yaml:"restart,omitempty"`
+	MaxRestarts int     `yaml:"maxRestarts,omitempty"`
+}
+
+// OverlaysConfig declares the dependencies that are installed on top of the
+// base dependencies of a project on machines with an NVIDIA GPU and on those
+// without, e.g. the CUDA or the CPU builds of torch and faiss-gpu or
+// faiss-cpu, so that the project installs on GPU workstations and laptops
+// alike.
+type OverlaysConfig struct {
+	GPU OverlayConfig `yaml:"gpu,omitempty"`
+	CPU OverlayConfig `yaml:"cpu,omitempty"`
+}
+
+// OverlayConfig is a dependency overlay. Requirements is a requirements file
+// that pip installs together with requirements.txt, by default
+// requirements-gpu.txt or requirements-cpu.txt if it exists; it may set the
+// index of the wheels, e.g. --extra-index-url
+// https://download.pytorch.org/whl/cu121. Extra is the optional dependency
+// group that uv and poetry (an extra) or pipenv (a category) install along
+// with the locked dependencies of projects with a lockfile; those tools do not
+// install the requirements file.
+type OverlayConfig struct {
+	Requirements string `yaml:"requirements,omitempty"`
+	Extra        string `yaml:"extra,omitempty"`
+}
+
+// ConfigPath returns the path of the langforge.yaml file in the given directory.
+func ConfigPath(dir string) string {
+	return filepath.Join(dir, ConfigFileName)
+}
+
+// LoadConfig reads the langforge.yaml file from the given directory. If the file
+// does not exist, a default configuration named after the directory is returned.
+func LoadConfig(dir string) (*Config, error) {
+	config := &Config{
+		Name: filepath.Base(dir),
+	}
+
+	data, err := os.ReadFile(ConfigPath(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ConfigFileName, err)
+	}
+
+	return config, nil
+}
+
+// MarshalConfig returns the configuration in the format of the langforge.yaml file.
+func MarshalConfig(config *Config) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UpdateConfig returns existing, the contents of a langforge.yaml file, with
+// the values of config. Unlike MarshalConfig, it keeps the comments, the order
+// of the keys and the formatting of the scalars that did not change, so that
+// commands can update the file that users wrote by hand.
+func UpdateConfig(existing []byte, config *Config) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ConfigFileName, err)
+	}
+	var updated yaml.Node
+	if err := updated.Encode(config); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return MarshalConfig(config)
+	}
+	mergeNode(doc.Content[0], &updated)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeNode changes dst, a node of an existing file, to the value of src and
+// keeps the comments of dst and those of its children that src still has.
+// Keys of mappings keep their order, new keys are appended. Items of
+// sequences are matched by their name, if they are named mappings like the
+// chains, or by their position.
+func mergeNode(dst *yaml.Node, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		values := map[string]*yaml.Node{}
+		order := []string{}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			values[src.Content[i].Value] = src.Content[i+1]
+			order = append(order, src.Content[i].Value)
+		}
+		content := []*yaml.Node{}
+		merged := map[string]bool{}
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key := dst.Content[i].Value
+			value, ok := values[key]
+			if !ok || merged[key] {
+				continue
+			}
+			mergeNode(dst.Content[i+1], value)
+			content = append(content, dst.Content[i], dst.Content[i+1])
+			merged[key] = true
+		}
+		for i, key := range order {
+			if !merged[key] {
+				content = append(content, src.Content[2*i], src.Content[2*i+1])
+			}
+		}
+		dst.Content = content
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		named := map[string]*yaml.Node{}
+		for _, item := range dst.Content {
+			if name := nodeName(item); name != "" {
+				named[name] = item
+			}
+		}
+		content := []*yaml.Node{}
+		for i, item := range src.Content {
+			var old *yaml.Node
+			if name := nodeName(item); name != "" {
+				old = named[name]
+				delete(named, name)
+			} else if i < len(dst.Content) && nodeName(dst.Content[i]) == "" {
+				old = dst.Content[i]
+			}
+			if old == nil {
+				content = append(content, item)
+				continue
+			}
+			mergeNode(old, item)
+			content = append(content, old)
+		}
+		dst.Content = content
+	case dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && dst.Value == src.Value:
+		// unchanged, keep the quoting of the file
+	default:
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+	}
+}
+
+// nodeName returns the name of a mapping with a name key, or an empty string.
+func nodeName(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "name" && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// SaveConfig writes the configuration to the langforge.yaml file in the given
+// directory. The comments and the formatting of an existing file are kept,
+// see UpdateConfig.
+func SaveConfig(dir string, config *Config) error {
+	existing, err := os.ReadFile(ConfigPath(dir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var data []byte
+	if existing != nil {
+		data, err = UpdateConfig(existing, config)
+	} else {
+		data, err = MarshalConfig(config)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(ConfigPath(dir), data, 0644)
+}
+
+// FindChain returns the chain with the given name, or nil if it is not declared.
+func (c *Config) FindChain(name string) *ChainConfig {
+	for i := range c.Chains {
+		if c.Chains[i].Name == name {
+			return &c.Chains[i]
+		}
+	}
+	return nil
+}
+
+// FindPreset returns the preset with the given name, or nil if it is not declared.
+func (c *Config) FindPreset(name string) *PresetConfig {
+	for i := range c.Presets {
+		if c.Presets[i].Name == name {
+			return &c.Presets[i]
+		}
+	}
+	return nil
+}
+
+// FindEval returns the evaluation with the given name, or nil if it is not declared.
+func (c *Config) FindEval(name string) *EvalConfig {
+	for i := range c.Evals {
+		if c.Evals[i].Name == name {
+			return &c.Evals[i]
+		}
+	}
+	return nil
+}
+
+// FindDataset returns the dataset with the given name, or nil if it is not registered.
+func (c *Config) FindDataset(name string) *DatasetConfig {
+	for i := range c.Datasets {
+		if c.Datasets[i].Name == name {
+			return &c.Datasets[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StateDirName is the name of the directory in which LangForge keeps
+// generated files and other state that belongs to a project.
+const StateDirName = ".langforge"
+
+// StateDir returns the path of the project's state directory.
+func StateDir(dir string) string {
+	return filepath.Join(dir, StateDirName)
+}
+
+// EnsureStateDir creates the given subdirectory of the project's state directory
+// if it does not exist yet and returns its path.
+func EnsureStateDir(dir string, elem ...string) (string, error) {
+	path := filepath.Join(append([]string{StateDir(dir)}, elem...)...)
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,168 @@
+package projects
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"langforge/project"
+	"langforge/state"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Collections of the global state.
+const (
+	collection         = "projects"
+	settingsCollection = "settings"
+	currentKey         = "current-project"
+)
+
+// Project is a project that langforge was used in.
+type Project struct {
+	Name     string    `json:"name"`
+	Dir      string    `json:"dir"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// key identifies a project in the global state by its directory, since the
+// names of projects need not be unique.
+func key(dir string) string {
+	sum := sha256.Sum256([]byte(dir))
+	return hex.EncodeToString(sum[:8])
+}
+
+// IsProject returns whether dir is the root directory of a project.
+func IsProject(dir string) bool {
+	_, err := os.Stat(project.ConfigPath(dir))
+	return err == nil
+}
+
+// Touch records that the project in dir was used.
+func Touch(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return err
+	}
+
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Put(collection, key(dir), &Project{Name: config.Name, Dir: dir, LastUsed: time.Now()})
+}
+
+// List returns the recorded projects, most recently used first.
+func List() ([]*Project, error) {
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return list(store)
+}
+
+func list(store state.Store) ([]*Project, error) {
+	records, err := store.List(collection)
+	if err != nil {
+		return nil, err
+	}
+	projects := []*Project{}
+	for _, record := range records {
+		p := &Project{}
+		if err := json.Unmarshal(record.Value, p); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	sort.SliceStable(projects, func(i, j int) bool {
+		return projects[i].LastUsed.After(projects[j].LastUsed)
+	})
+	return projects, nil
+}
+
+// Find returns the recorded project with the given name or directory.
+func Find(name string) (*Project, error) {
+	projects, err := List()
+	if err != nil {
+		return nil, err
+	}
+	if dir, err := filepath.Abs(name); err == nil {
+		for _, p := range projects {
+			if p.Dir == dir {
+				return p, nil
+			}
+		}
+		if IsProject(dir) {
+			return &Project{Name: filepath.Base(dir), Dir: dir}, nil
+		}
+	}
+
+	found := []*Project{}
+	for _, p := range projects {
+		if p.Name == name {
+			found = append(found, p)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("unknown project %q, run 'langforge projects list' to see the recent projects", name)
+	case 1:
+		return found[0], nil
+	}
+	dirs := []string{}
+	for _, p := range found {
+		dirs = append(dirs, p.Dir)
+	}
+	return nil, fmt.Errorf("there are several projects named %q, use the directory of one instead: %s", name, strings.Join(dirs, ", "))
+}
+
+// Current returns the project selected with Switch, or nil.
+func Current() (*Project, error) {
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	var dir string
+	if err := store.Get(settingsCollection, currentKey, &dir); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	projects, err := list(store)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if p.Dir == dir {
+			return p, nil
+		}
+	}
+	return &Project{Name: filepath.Base(dir), Dir: dir}, nil
+}
+
+// Switch selects the project that commands use when they run outside of a
+// project directory. A nil project clears the selection.
+func Switch(p *Project) error {
+	store, err := state.OpenGlobal()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if p == nil {
+		return store.Delete(settingsCollection, currentKey)
+	}
+	return store.Put(settingsCollection, currentKey, p.Dir)
+}
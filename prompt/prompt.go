@@ -0,0 +1,265 @@
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DirName is the name of the directory that holds a project's prompt templates.
+const DirName = "prompts"
+
+// Extensions are the file extensions that are recognized as prompt templates.
+var Extensions = []string{".prompt", ".md", ".txt"}
+
+// ModelHints are optional hints about the model a prompt was written for.
+type ModelHints struct {
+	Name        string   `yaml:"name,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	MaxTokens   int      `yaml:"maxTokens,omitempty"`
+}
+
+// Prompt is a prompt template loaded from a file in the prompts directory.
+// Metadata is read from an optional YAML front-matter block delimited by "---".
+type Prompt struct {
+	Name        string     `yaml:"name"`
+	Version     int        `yaml:"version"`
+	Description string     `yaml:"description"`
+	Variables   []string   `yaml:"variables"`
+	Model       ModelHints `yaml:"model"`
+	Template    string     `yaml:"-"`
+	Path        string     `yaml:"-"`
+}
+
+// Dir returns the prompts directory of the project in dir.
+func Dir(dir string) string {
+	return filepath.Join(dir, DirName)
+}
+
+// ParseFile reads a prompt template from path. The name and version default to
+// the file name, which may carry a version suffix, e.g. "qa@2.prompt".
+func ParseFile(path string) (*Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Base(path)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	p := &Prompt{Path: path}
+	if name, version, ok := strings.Cut(stem, "@"); ok {
+		p.Name = name
+		p.Version, err = strconv.Atoi(strings.TrimPrefix(version, "v"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in prompt file name %s", base)
+		}
+	} else {
+		p.Name = stem
+	}
+
+	frontMatter, body := splitFrontMatter(data)
+	if frontMatter != nil {
+		if err := yaml.Unmarshal(frontMatter, p); err != nil {
+			return nil, fmt.Errorf("failed to parse front matter of %s: %v", path, err)
+		}
+	}
+
+	if p.Version == 0 {
+		p.Version = 1
+	}
+	p.Template = string(body)
+
+	if p.Variables == nil {
+		p.Variables = TemplateVariables(p.Template)
+	}
+
+	return p, nil
+}
+
+func splitFrontMatter(data []byte) ([]byte, []byte) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	normalized := bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(normalized, []byte("---\n")) {
+		return nil, data
+	}
+
+	rest := normalized[len("---\n"):]
+	end := bytes.Index(rest, []byte("\n---"))
+	if end == -1 {
+		return nil, data
+	}
+
+	frontMatter := rest[:end]
+	body := rest[end+len("\n---"):]
+	if i := bytes.IndexByte(body, '\n'); i != -1 {
+		body = body[i+1:]
+	} else {
+		body = nil
+	}
+
+	return frontMatter, body
+}
+
+// LoadAll loads all prompt templates (all versions) from the prompts directory
+// of the project in dir, sorted by name and version.
+func LoadAll(dir string) ([]*Prompt, error) {
+	prompts := []*Prompt{}
+
+	err := filepath.Walk(Dir(dir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == Dir(dir) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || !hasPromptExtension(path) {
+			return nil
+		}
+		p, err := ParseFile(path)
+		if err != nil {
+			return err
+		}
+		prompts = append(prompts, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(prompts, func(i, j int) bool {
+		if prompts[i].Name != prompts[j].Name {
+			return prompts[i].Name < prompts[j].Name
+		}
+		return prompts[i].Version < prompts[j].Version
+	})
+
+	return prompts, nil
+}
+
+func hasPromptExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Load returns the prompt referenced by ref from the project in dir. A reference
+// is either a plain name, which selects the latest version, or "name@version".
+func Load(dir string, ref string) (*Prompt, error) {
+	name, versionString, hasVersion := strings.Cut(ref, "@")
+	version := 0
+	if hasVersion {
+		var err error
+		version, err = strconv.Atoi(strings.TrimPrefix(versionString, "v"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt version %q", versionString)
+		}
+	}
+
+	prompts, err := LoadAll(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Prompt
+	for _, p := range prompts {
+		if p.Name != name {
+			continue
+		}
+		if hasVersion && p.Version == version {
+			return p, nil
+		}
+		if !hasVersion && (found == nil || p.Version > found.Version) {
+			found = p
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("prompt %q not found in %s", ref, Dir(dir))
+	}
+
+	return found, nil
+}
+
+// TemplateVariables returns the names of the variables used in a template. Variables
+// use LangChain's f-string syntax, e.g. "{question}"; "{{" and "}}" are literal braces.
+func TemplateVariables(template string) []string {
+	variables := []string{}
+	seen := make(map[string]bool)
+
+	scanTemplate(template, func(literal string) {}, func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			variables = append(variables, name)
+		}
+	})
+
+	return variables
+}
+
+// Render substitutes the given variables into the prompt template. It returns
+// an error if a variable used by the template is missing.
+func (p *Prompt) Render(vars map[string]string) (string, error) {
+	var out strings.Builder
+	missing := []string{}
+
+	scanTemplate(p.Template, func(literal string) {
+		out.WriteString(literal)
+	}, func(name string) {
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return
+		}
+		out.WriteString(value)
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing variables for prompt %q: %s", p.Name, strings.Join(missing, ", "))
+	}
+
+	return out.String(), nil
+}
+
+func scanTemplate(template string, onLiteral func(string), onVariable func(string)) {
+	for len(template) > 0 {
+		i := strings.IndexAny(template, "{}")
+		if i == -1 {
+			onLiteral(template)
+			return
+		}
+
+		onLiteral(template[:i])
+		template = template[i:]
+
+		switch {
+		case strings.HasPrefix(template, "{{"):
+			onLiteral("{")
+			template = template[2:]
+		case strings.HasPrefix(template, "}}"):
+			onLiteral("}")
+			template = template[2:]
+		case template[0] == '{':
+			end := strings.IndexByte(template, '}')
+			if end == -1 {
+				onLiteral(template)
+				return
+			}
+			onVariable(strings.TrimSpace(template[1:end]))
+			template = template[end+1:]
+		default:
+			onLiteral("}")
+			template = template[1:]
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package prompt
+
+import (
+	"langforge/watcher"
+	"strings"
+)
+
+// IsTemplatePath reports whether the slash-separated path relative to the
+// project directory is a prompt template.
+func IsTemplatePath(rel string) bool {
+	return strings.HasPrefix(rel, DirName+"/") && hasPromptExtension(rel)
+}
+
+// Watch watches the prompts directory of the project in dir and calls onChange
+// whenever a prompt template is added, removed or modified. It returns when
+// stop is closed.
+func Watch(dir string, stop <-chan struct{}, onChange func()) error {
+	options := watcher.Options{Filter: IsTemplatePath}
+	return watcher.Watch(dir, options, stop, func(changed []string) {
+		onChange()
+	})
+}
@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ImageType is the type of the image inputs of chain requests.
+const ImageType = "image"
+
+// Image is an image input of a chain request:
+//
+//	{"type": "image", "mediaType": "image/png", "data": "<base64>"}
+//
+// Data may also be a data URL, which gives the media type.
+type Image struct {
+	MediaType string
+	Data      string
+}
+
+// ParseImage returns the image of an input value and whether the value is an
+// image object.
+func ParseImage(value any) (*Image, bool) {
+	object, ok := value.(map[string]any)
+	if !ok || object["type"] != ImageType {
+		return nil, false
+	}
+	image := &Image{}
+	image.MediaType, _ = object["mediaType"].(string)
+	image.Data, _ = object["data"].(string)
+	if header, data, found := strings.Cut(image.Data, ","); found && strings.HasPrefix(header, "data:") {
+		mediaType, encoding, _ := strings.Cut(strings.TrimPrefix(header, "data:"), ";")
+		if encoding == "base64" {
+			if image.MediaType == "" {
+				image.MediaType = mediaType
+			}
+			image.Data = data
+		}
+	}
+	return image, true
+}
+
+// Decode returns the content of the image.
+func (i *Image) Decode() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(i.Data)
+}
+
+// Attachment is the binary content of an image input of a request message.
+// In the body of the request, the image is replaced with
+//
+//	{"type": "image", "mediaType": "image/png", "attachment": <index>}
+//
+// so that it is not encoded twice in the frame.
+type Attachment struct {
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+}
+
+// extractAttachments moves the images of a JSON object of chain inputs into
+// attachments. Only images that are inputs of their own are moved, the
+// gateway rejects those in lists or objects. Bodies without images, and
+// images that do not decode, are left unchanged.
+func extractAttachments(body []byte) ([]byte, []Attachment) {
+	if !bytes.Contains(body, []byte(`"`+ImageType+`"`)) {
+		return body, nil
+	}
+	var inputs map[string]any
+	if err := json.Unmarshal(body, &inputs); err != nil {
+		return body, nil
+	}
+
+	keys := make([]string, 0, len(inputs))
+	for key := range inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attachments := []Attachment{}
+	for _, key := range keys {
+		image, ok := ParseImage(inputs[key])
+		if !ok {
+			continue
+		}
+		data, err := image.Decode()
+		if err != nil {
+			continue
+		}
+		inputs[key] = map[string]any{"type": ImageType, "mediaType": image.MediaType, "attachment": len(attachments)}
+		attachments = append(attachments, Attachment{ContentType: image.MediaType, Data: data})
+	}
+	if len(attachments) == 0 {
+		return body, nil
+	}
+	extracted, err := json.Marshal(inputs)
+	if err != nil {
+		return body, nil
+	}
+	return extracted, attachments
+}
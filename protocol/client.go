@@ -0,0 +1,451 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// dialInterval is how often the client tries to connect to a worker that
+	// is still starting.
+	dialInterval = 200 * time.Millisecond
+	// pingInterval is how often the client checks the health of the worker.
+	pingInterval = 10 * time.Second
+	// pingTimeout is how long the worker may leave pings unanswered before
+	// the connection is closed.
+	pingTimeout = 30 * time.Second
+)
+
+// ErrNotConnected is returned for requests sent before the worker has started.
+var ErrNotConnected = errors.New("the worker is not connected")
+
+// Client is a connection to a worker. It connects in the background as soon
+// as the worker listens and sends HTTP requests to it as protocol messages,
+// so that it can be used as the transport of an HTTP client or reverse proxy.
+// Requests are multiplexed on a single connection; a request whose context
+// is canceled, or whose response body is closed early, is canceled in the
+// worker.
+type Client struct {
+	addr      string
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+	conn      net.Conn
+	calls     map[uint64]*call
+	nextID    uint64
+	lastPong  time.Time
+	err       error
+	connected chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// call is a request waiting for its response.
+type call struct {
+	head chan *Message
+	body *stream
+}
+
+// NewClient returns a client for the worker listening on addr, e.g.
+// "127.0.0.1:4321", and starts connecting to it.
+func NewClient(addr string) *Client {
+	c := &Client{
+		addr:      addr,
+		calls:     map[uint64]*call{},
+		connected: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.connect()
+	return c
+}
+
+// connect dials the worker until it accepts the connection and exchanges hello
+// messages with it.
+func (c *Client) connect() {
+	var conn net.Conn
+	for {
+		var err error
+		conn, err = net.DialTimeout("tcp", c.addr, time.Second)
+		if err == nil {
+			break
+		}
+		select {
+		case <-c.done:
+			return
+		case <-time.After(dialInterval):
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	err := WriteMessage(conn, &Message{Type: TypeHello, Version: Version})
+	var hello *Message
+	if err == nil {
+		hello, err = ReadMessage(conn)
+	}
+	if err == nil && hello.Type == TypeError {
+		err = fmt.Errorf("the worker rejected the connection: %s", hello.Error)
+	} else if err == nil && (hello.Type != TypeHello || hello.Version != Version) {
+		err = fmt.Errorf("the worker speaks protocol version %d, langforge requires version %d", hello.Version, Version)
+	}
+	if err != nil {
+		conn.Close()
+		c.fail(err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	c.mu.Lock()
+	c.conn = conn
+	c.lastPong = time.Now()
+	c.mu.Unlock()
+	close(c.connected)
+
+	go c.ping()
+	c.read(conn)
+}
+
+// read dispatches the messages of the worker until the connection fails.
+func (c *Client) read(conn net.Conn) {
+	for {
+		message, err := ReadMessage(conn)
+		if err != nil {
+			select {
+			case <-c.done:
+			default:
+				if err == io.EOF {
+					err = errors.New("the worker closed the connection")
+				}
+				c.fail(err)
+			}
+			return
+		}
+
+		if message.Type == TypePong {
+			c.mu.Lock()
+			c.lastPong = time.Now()
+			c.mu.Unlock()
+			continue
+		}
+
+		c.mu.Lock()
+		call := c.calls[message.ID]
+		if call != nil && (message.Type == TypeEnd || message.Type == TypeError) {
+			delete(c.calls, message.ID)
+		}
+		c.mu.Unlock()
+		// messages of canceled requests are dropped
+		if call == nil {
+			continue
+		}
+
+		switch message.Type {
+		case TypeResponse:
+			call.head <- message
+		case TypeChunk:
+			call.body.write(message.Data)
+		case TypeEnd:
+			call.body.finish(nil)
+		case TypeError:
+			select {
+			case call.head <- message:
+			default:
+			}
+			call.body.finish(errors.New(message.Error))
+		}
+	}
+}
+
+// ping checks the health of the worker and closes the connection if it stops
+// answering.
+func (c *Client) ping() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		silent := time.Since(c.lastPong)
+		c.mu.Unlock()
+		if silent > pingTimeout {
+			c.fail(fmt.Errorf("the worker did not answer health pings for %v", silent.Round(time.Second)))
+			return
+		}
+		c.send(&Message{Type: TypePing, ID: c.id()})
+	}
+}
+
+func (c *Client) id() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return c.nextID
+}
+
+func (c *Client) send(message *Message) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return WriteMessage(conn, message)
+}
+
+// cancel stops waiting for a request and asks the worker to abort it.
+func (c *Client) cancel(id uint64) {
+	c.mu.Lock()
+	_, pending := c.calls[id]
+	delete(c.calls, id)
+	c.mu.Unlock()
+	if pending {
+		c.send(&Message{Type: TypeCancel, ID: id})
+	}
+}
+
+// fail closes the connection and fails all pending requests with err.
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		conn := c.conn
+		calls := c.calls
+		c.calls = map[uint64]*call{}
+		c.mu.Unlock()
+
+		close(c.done)
+		if conn != nil {
+			conn.Close()
+		}
+		for _, call := range calls {
+			call.body.finish(err)
+		}
+	})
+}
+
+// Connected is closed once the worker has accepted the connection.
+func (c *Client) Connected() <-chan struct{} {
+	return c.connected
+}
+
+// Done is closed when the connection to the worker is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the reason the connection was lost, or nil while it is open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection to the worker.
+func (c *Client) Close() error {
+	c.fail(errors.New("the connection to the worker was closed"))
+	return nil
+}
+
+// Wait waits until the worker has accepted the connection.
+func (c *Client) Wait(ctx context.Context) error {
+	select {
+	case <-c.connected:
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RoundTrip sends an HTTP request to the worker. The response body is
+// streamed as the worker produces it.
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case <-c.connected:
+	case <-c.done:
+		return nil, c.Err()
+	default:
+		return nil, ErrNotConnected
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id := c.id()
+	call := &call{head: make(chan *Message, 1), body: newStream()}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.calls[id] = call
+	c.mu.Unlock()
+
+	var attachments []Attachment
+	if req.Method == http.MethodPost {
+		body, attachments = extractAttachments(body)
+	}
+	if attachments != nil {
+		// the body no longer has the length of the client's
+		req.Header.Del("Content-Length")
+	}
+	err := c.send(&Message{
+		Type:        TypeRequest,
+		ID:          id,
+		Method:      req.Method,
+		Path:        req.URL.RequestURI(),
+		Header:      req.Header,
+		Body:        body,
+		Attachments: attachments,
+	})
+	if err != nil {
+		c.cancel(id)
+		return nil, err
+	}
+
+	var head *Message
+	select {
+	case head = <-call.head:
+	case <-req.Context().Done():
+		c.cancel(id)
+		return nil, req.Context().Err()
+	case <-c.done:
+		return nil, c.Err()
+	}
+	if head.Type == TypeError {
+		return nil, errors.New(head.Error)
+	}
+
+	// cancel the request in the worker when the client goes away
+	go func() {
+		select {
+		case <-req.Context().Done():
+			c.cancel(id)
+			call.body.finish(req.Context().Err())
+		case <-call.body.done:
+		}
+	}()
+
+	header := http.Header(head.Header)
+	if header == nil {
+		header = http.Header{}
+	}
+	contentLength := int64(-1)
+	if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = n
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", head.Status, http.StatusText(head.Status)),
+		StatusCode:    head.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          &responseBody{client: c, id: id, stream: call.body},
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+// responseBody cancels the request if it is closed before the worker has
+// sent the whole body.
+type responseBody struct {
+	client *Client
+	id     uint64
+	stream *stream
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	return b.stream.Read(p)
+}
+
+func (b *responseBody) Close() error {
+	select {
+	case <-b.stream.done:
+	default:
+		b.client.cancel(b.id)
+		b.stream.finish(errors.New("response body closed"))
+	}
+	return nil
+}
+
+// stream is an unbounded buffer of body chunks, so that a slow reader does not
+// hold up the responses to other requests on the connection.
+type stream struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	chunks [][]byte
+	err    error
+	done   chan struct{}
+}
+
+func newStream() *stream {
+	s := &stream{done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *stream) write(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil && len(data) > 0 {
+		s.chunks = append(s.chunks, data)
+		s.cond.Broadcast()
+	}
+}
+
+// finish ends the stream. A nil error ends it successfully with io.EOF once
+// the remaining chunks are read.
+func (s *stream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if err == nil {
+		err = io.EOF
+	} else {
+		s.chunks = nil
+	}
+	s.err = err
+	close(s.done)
+	s.cond.Broadcast()
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.chunks) == 0 && s.err == nil {
+		s.cond.Wait()
+	}
+	if len(s.chunks) == 0 {
+		return 0, s.err
+	}
+	n := copy(p, s.chunks[0])
+	if n == len(s.chunks[0]) {
+		s.chunks = s.chunks[1:]
+	} else {
+		s.chunks[0] = s.chunks[0][n:]
+	}
+	return n, nil
+}
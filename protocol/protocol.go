@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Version is the version of the protocol between the gateway and the worker.
+// Both sides send it in their hello message and the worker rejects versions
+// it does not implement. Version 3 added the attachments of requests.
+const Version = 3
+
+// maxFrameSize limits the size of a single message.
+const maxFrameSize = 64 << 20
+
+// Message types. The gateway sends hello, request, cancel and ping messages;
+// the worker answers with hello, response, chunk, end, error and pong
+// messages. A request is answered by a response message with the status and
+// headers, any number of chunk messages with the body and an end message, or
+// by an error message at any point. The images of chain inputs are sent as
+// attachments of the request message.
+const (
+	TypeHello    = "hello"
+	TypeRequest  = "request"
+	TypeCancel   = "cancel"
+	TypePing     = "ping"
+	TypePong     = "pong"
+	TypeResponse = "response"
+	TypeChunk    = "chunk"
+	TypeEnd      = "end"
+	TypeError    = "error"
+)
+
+// Message is a frame of the protocol. Fields that do not apply to a type are
+// omitted. Binary fields are base64 encoded in JSON.
+type Message struct {
+	Type string `json:"type"`
+	// ID identifies the request, or the ping, a message belongs to.
+	ID      uint64              `json:"id,omitempty"`
+	Version int                 `json:"version,omitempty"`
+	Method  string              `json:"method,omitempty"`
+	Path    string              `json:"path,omitempty"`
+	Header  map[string][]string `json:"header,omitempty"`
+	Body    []byte              `json:"body,omitempty"`
+	// Attachments are the images of the inputs of a chain request.
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Status      int          `json:"status,omitempty"`
+	Data        []byte       `json:"data,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// WriteMessage writes a message as a frame: its length as a 4 byte big endian
+// integer followed by its JSON encoding.
+func WriteMessage(w io.Writer, message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum frame size", len(data))
+	}
+
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = w.Write(frame)
+	return err
+}
+
+// ReadMessage reads a frame written by WriteMessage.
+func ReadMessage(r io.Reader) (*Message, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds the maximum frame size", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	message := &Message{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("invalid message: %v", err)
+	}
+	return message, nil
+}
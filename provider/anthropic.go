@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultAnthropicBaseURL is the base URL of the Anthropic API.
+const DefaultAnthropicBaseURL = "https://api.anthropic.com"
+
+// anthropicVersion is the version of the Anthropic API that the client speaks.
+const anthropicVersion = "2023-06-01"
+
+// defaultAnthropicMaxTokens limits completions, which the Anthropic API
+// requires.
+const defaultAnthropicMaxTokens = 1024
+
+// Anthropic is a client for the Anthropic messages API.
+type Anthropic struct {
+	apiKey  string
+	baseURL string
+	http    *http.Client
+}
+
+// NewAnthropic returns an Anthropic client. If baseURL is empty, the official
+// API is used. Like OpenAI clients, clients of the same API key share a
+// limiter.
+func NewAnthropic(apiKey string, baseURL string) *Anthropic {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicBaseURL
+	}
+	c := &Anthropic{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+	c.http = &http.Client{
+		Transport: &rateLimitTransport{
+			base:    http.DefaultTransport,
+			limiter: SharedLimiter(c.limiterKey(), 0),
+		},
+	}
+	return c
+}
+
+func (c *Anthropic) limiterKey() string {
+	return "anthropic " + c.baseURL + " " + c.apiKey
+}
+
+// SetLimiter paces the requests of the client with the given limiter.
+func (c *Anthropic) SetLimiter(limiter *Limiter) {
+	c.http.Transport.(*rateLimitTransport).limiter = limiter
+}
+
+func (c *Anthropic) Name() string {
+	return "anthropic"
+}
+
+type anthropicChatRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens"`
+}
+
+type anthropicChatResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Error *anthropicError `json:"error"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func newAnthropicError(status int, failure *anthropicError) *Error {
+	if failure == nil {
+		return newError("anthropic", status, "", "", "")
+	}
+	return newError("anthropic", status, failure.Type, failure.Type, failure.Message)
+}
+
+func (c *Anthropic) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func (c *Anthropic) Chat(ctx context.Context, request *ChatRequest) (string, error) {
+	model := request.Model
+	if model == "" {
+		model = "claude-3-5-haiku-20241022"
+	}
+	maxTokens := request.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+
+	// the API takes the system prompt apart from the conversation
+	chat := &anthropicChatRequest{Model: model, Temperature: request.Temperature, MaxTokens: maxTokens}
+	for _, message := range request.Messages {
+		if message.Role == "system" {
+			chat.System = strings.TrimSpace(chat.System + "\n\n" + message.Content)
+			continue
+		}
+		chat.Messages = append(chat.Messages, message)
+	}
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var response anthropicChatResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newError("anthropic", resp.StatusCode, "", "", strings.TrimSpace(string(data)))
+		}
+		return "", fmt.Errorf("anthropic: unexpected response (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if response.Error != nil || resp.StatusCode != http.StatusOK {
+		return "", newAnthropicError(resp.StatusCode, response.Error)
+	}
+
+	text := ""
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+	return text, nil
+}
+
+// Models returns the IDs of the models available to the API key. It fails if
+// the API key is invalid.
+func (c *Anthropic) Models(ctx context.Context) ([]string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/v1/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response anthropicChatResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		if response.Error == nil && resp.StatusCode == http.StatusUnauthorized {
+			response.Error = &anthropicError{Type: "authentication_error", Message: "invalid API key"}
+		}
+		return nil, newAnthropicError(resp.StatusCode, response.Error)
+	}
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("anthropic: unexpected models response: %v", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, model := range response.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Info describes an LLM provider that applications can be created with.
+type Info struct {
+	Name  string
+	Title string
+	// Aliases are further names of the provider, e.g. "google" for "gemini".
+	Aliases []string
+	// APIKey is the environment variable of the API key.
+	APIKey string
+	// KeysURL is the page where users create API keys.
+	KeysURL string
+	// ChatModel is the chat model of new applications.
+	ChatModel string
+	// Models are the prices of the chat models of the provider.
+	Models []ModelPrice
+}
+
+// ModelPrice is the price of a model in USD per million tokens. Models whose
+// name starts with Model, e.g. dated versions, have the same price.
+type ModelPrice struct {
+	Model  string
+	Input  float64
+	Output float64
+}
+
+// Providers are the providers that applications can be created with. The
+// first is the default.
+var Providers = []*Info{
+	{
+		Name:      "openai",
+		Title:     "OpenAI",
+		APIKey:    "OPENAI_API_KEY",
+		KeysURL:   "https://platform.openai.com/api-keys",
+		ChatModel: "gpt-4o-mini",
+		Models: []ModelPrice{
+			{"gpt-4o-mini", 0.15, 0.60},
+			{"gpt-4o", 2.50, 10},
+			{"gpt-4-turbo", 10, 30},
+			{"gpt-4", 30, 60},
+			{"gpt-3.5-turbo", 0.50, 1.50},
+			{"o1-mini", 3, 12},
+			{"o1", 15, 60},
+		},
+	},
+	{
+		Name:      "anthropic",
+		Title:     "Anthropic",
+		Aliases:   []string{"claude"},
+		APIKey:    "ANTHROPIC_API_KEY",
+		KeysURL:   "https://console.anthropic.com/settings/keys",
+		ChatModel: "claude-3-5-haiku-20241022",
+		Models: []ModelPrice{
+			{"claude-3-5-haiku", 0.80, 4},
+			{"claude-3-5-sonnet", 3, 15},
+			{"claude-3-opus", 15, 75},
+			{"claude-3-sonnet", 3, 15},
+			{"claude-3-haiku", 0.25, 1.25},
+		},
+	},
+	{
+		Name:      "gemini",
+		Title:     "Google Gemini",
+		Aliases:   []string{"google"},
+		APIKey:    "GOOGLE_API_KEY",
+		KeysURL:   "https://aistudio.google.com/app/apikey",
+		ChatModel: "gemini-1.5-flash",
+		Models: []ModelPrice{
+			{"gemini-1.5-flash-8b", 0.0375, 0.15},
+			{"gemini-1.5-flash", 0.075, 0.30},
+			{"gemini-1.5-pro", 1.25, 5},
+			{"gemini-2.0-flash", 0.10, 0.40},
+		},
+	},
+}
+
+// Lookup returns the provider with the given name or alias.
+func Lookup(name string) (*Info, error) {
+	name = strings.ToLower(name)
+	names := []string{}
+	for _, info := range Providers {
+		if info.Name == name {
+			return info, nil
+		}
+		for _, alias := range info.Aliases {
+			if alias == name {
+				return info, nil
+			}
+		}
+		names = append(names, info.Name)
+	}
+	return nil, fmt.Errorf("unknown provider %q, the providers are %s", name, strings.Join(names, ", "))
+}
+
+// Price returns the price of a model, matching the longest model name of the
+// catalog that the model starts with. The "models/" prefix of Gemini models is
+// ignored.
+func Price(model string) (ModelPrice, bool) {
+	model = strings.TrimPrefix(strings.ToLower(model), "models/")
+	best := ModelPrice{}
+	for _, info := range Providers {
+		for _, price := range info.Models {
+			if strings.HasPrefix(model, price.Model) && len(price.Model) > len(best.Model) {
+				best = price
+			}
+		}
+	}
+	return best, best.Model != ""
+}
+
+// Cost returns the cost in USD of a request to a model with the given numbers
+// of prompt and completion tokens, and false if the price of the model is
+// unknown.
+func Cost(model string, promptTokens int, completionTokens int) (float64, bool) {
+	price, ok := Price(model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6, true
+}
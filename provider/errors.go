@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Code identifies a kind of LLM provider error independently of the provider,
+// so that callers can branch on it instead of parsing messages.
+type Code string
+
+const (
+	// RateLimited is returned when the provider throttles the account. The
+	// request may succeed when it is retried later.
+	RateLimited Code = "rate_limited"
+	// ContextLengthExceeded is returned when the prompt and the completion do
+	// not fit the context window of the model.
+	ContextLengthExceeded Code = "context_length_exceeded"
+	// ContentFiltered is returned when the provider's content filter rejects
+	// the prompt or the completion.
+	ContentFiltered Code = "content_filtered"
+	// AuthenticationFailed is returned when the provider rejects the API key.
+	AuthenticationFailed Code = "authentication_failed"
+	// ProviderFailed is returned for other errors reported by the provider.
+	ProviderFailed Code = "provider_error"
+	// InvalidOutput is returned by the gateway when the output of the model
+	// does not match the output schema of the chain, also after the retries
+	// with a repair prompt.
+	InvalidOutput Code = "invalid_output"
+)
+
+// Codes are all codes of provider errors.
+var Codes = []Code{RateLimited, ContextLengthExceeded, ContentFiltered, AuthenticationFailed, ProviderFailed, InvalidOutput}
+
+// Status returns the HTTP status with which the gateway answers requests that
+// fail with an error of the code. Errors that the caller cannot fix, such as
+// an invalid API key of the application, are bad gateway errors.
+func (c Code) Status() int {
+	switch c {
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case ContextLengthExceeded, ContentFiltered:
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// Retryable reports whether a request that failed with an error of the code
+// may succeed if it is sent again.
+func (c Code) Retryable() bool {
+	return c == RateLimited
+}
+
+// Error is an error reported by an LLM provider.
+type Error struct {
+	Code     Code
+	Provider string
+	// Status is the HTTP status of the provider's response.
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Provider, e.Message, e.Code)
+}
+
+// ErrorCode returns the code of the error.
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+// CodeOf returns the code of the first error in err's chain that has one, such
+// as an Error or an error of a chain that failed with a provider error, or an
+// empty code.
+func CodeOf(err error) Code {
+	var coded interface{ ErrorCode() Code }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return ""
+}
+
+// newError returns the error of a failed response of a provider.
+func newError(provider string, status int, kind string, code string, message string) *Error {
+	if message == "" {
+		message = fmt.Sprintf("request failed with status %d", status)
+	}
+	classified := Classify(status, kind, code, message)
+	if classified == "" {
+		classified = ProviderFailed
+	}
+	return &Error{Code: classified, Provider: provider, Status: status, Message: message}
+}
+
+// Classify returns the code of a provider error given the HTTP status of the
+// provider's response, the type of the error (an error type of the provider's
+// API or the class of an SDK exception), the provider's error code and the
+// message, any of which may be empty. It returns an empty code if the error
+// does not look like an error of a provider.
+func Classify(status int, kind string, code string, message string) Code {
+	kind, code, message = strings.ToLower(kind), strings.ToLower(code), strings.ToLower(message)
+	matches := func(text string, patterns ...string) bool {
+		for _, pattern := range patterns {
+			if strings.Contains(text, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case code == "context_length_exceeded" || code == "string_above_max_length" ||
+		matches(message, "maximum context length", "context length", "context window", "prompt is too long", "too many tokens"):
+		return ContextLengthExceeded
+	case matches(code, "content_filter", "content_policy") || matches(kind, "contentfilter", "content_filter") ||
+		matches(message, "content management policy", "content filter", "content_policy_violation", "safety system"):
+		return ContentFiltered
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		code == "invalid_api_key" || matches(kind, "authentication", "permissiondenied") ||
+		matches(message, "incorrect api key", "invalid api key", "invalid x-api-key", "api key not valid"):
+		return AuthenticationFailed
+	case code == "insufficient_quota" || matches(message, "exceeded your current quota"):
+		// quotas are not restored by retrying
+		return ProviderFailed
+	case status == http.StatusTooManyRequests || code == "rate_limit_exceeded" ||
+		matches(kind, "ratelimit", "rate_limit") || matches(message, "rate limit", "too many requests", "overloaded"):
+		return RateLimited
+	case matches(kind, "openai", "anthropic", "apierror", "apistatuserror", "serviceunavailable"):
+		return ProviderFailed
+	}
+	return ""
+}
+
+// ErrorBody is the JSON body of the error responses of workers and of the
+// gateway. Workers describe exceptions of provider SDKs with Type,
+// UpstreamStatus and UpstreamCode, which the gateway replaces with Code.
+type ErrorBody struct {
+	Error          string `json:"error"`
+	Code           Code   `json:"code,omitempty"`
+	Type           string `json:"type,omitempty"`
+	UpstreamStatus int    `json:"upstreamStatus,omitempty"`
+	UpstreamCode   string `json:"upstreamCode,omitempty"`
+}
+
+// Normalize sets the code of an error response from the details of the
+// worker and removes them.
+func (b *ErrorBody) Normalize() {
+	if b.Code == "" {
+		b.Code = Classify(b.UpstreamStatus, b.Type, b.UpstreamCode, b.Error)
+	}
+	b.Type, b.UpstreamStatus, b.UpstreamCode = "", 0, ""
+}
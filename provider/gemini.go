@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGeminiBaseURL is the base URL of the Gemini API.
+const DefaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// Gemini is a client for the Gemini API of Google AI Studio.
+type Gemini struct {
+	apiKey  string
+	baseURL string
+	http    *http.Client
+}
+
+// NewGemini returns a Gemini client. If baseURL is empty, the official API is
+// used. Like OpenAI clients, clients of the same API key share a limiter.
+func NewGemini(apiKey string, baseURL string) *Gemini {
+	if baseURL == "" {
+		baseURL = DefaultGeminiBaseURL
+	}
+	c := &Gemini{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+	c.http = &http.Client{
+		Transport: &rateLimitTransport{
+			base:    http.DefaultTransport,
+			limiter: SharedLimiter(c.limiterKey(), 0),
+		},
+	}
+	return c
+}
+
+func (c *Gemini) limiterKey() string {
+	return "gemini " + c.baseURL + " " + c.apiKey
+}
+
+// SetLimiter paces the requests of the client with the given limiter.
+func (c *Gemini) SetLimiter(limiter *Limiter) {
+	c.http.Transport.(*rateLimitTransport).limiter = limiter
+}
+
+func (c *Gemini) Name() string {
+	return "gemini"
+}
+
+type geminiContent struct {
+	Role  string `json:"role,omitempty"`
+	Parts []struct {
+		Text string `json:"text"`
+	} `json:"parts"`
+}
+
+func newGeminiContent(role string, text string) *geminiContent {
+	content := &geminiContent{Role: role}
+	content.Parts = append(content.Parts, struct {
+		Text string `json:"text"`
+	}{text})
+	return content
+}
+
+type geminiChatRequest struct {
+	SystemInstruction *geminiContent   `json:"systemInstruction,omitempty"`
+	Contents          []*geminiContent `json:"contents"`
+	GenerationConfig  struct {
+		Temperature     *float64 `json:"temperature,omitempty"`
+		MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+		Seed            *int     `json:"seed,omitempty"`
+	} `json:"generationConfig"`
+}
+
+type geminiChatResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	Error *geminiError `json:"error"`
+}
+
+type geminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func newGeminiError(status int, failure *geminiError) *Error {
+	if failure == nil {
+		return newError("gemini", status, "", "", "")
+	}
+	return newError("gemini", status, failure.Status, "", failure.Message)
+}
+
+// newRequest returns a request of the API. The API key is sent in a header
+// rather than in the URL, so that it does not show up in errors.
+func (c *Gemini) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func (c *Gemini) Chat(ctx context.Context, request *ChatRequest) (string, error) {
+	model := strings.TrimPrefix(request.Model, "models/")
+	if model == "" {
+		model = "gemini-1.5-flash"
+	}
+
+	// the API calls the assistant the model and takes the system prompt
+	// apart from the conversation
+	chat := &geminiChatRequest{}
+	chat.GenerationConfig.Temperature = request.Temperature
+	chat.GenerationConfig.MaxOutputTokens = request.MaxTokens
+	chat.GenerationConfig.Seed = request.Seed
+	system := ""
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system":
+			system = strings.TrimSpace(system + "\n\n" + message.Content)
+		case "assistant":
+			chat.Contents = append(chat.Contents, newGeminiContent("model", message.Content))
+		default:
+			chat.Contents = append(chat.Contents, newGeminiContent("user", message.Content))
+		}
+	}
+	if system != "" {
+		chat.SystemInstruction = newGeminiContent("", system)
+	}
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/models/"+url.PathEscape(model)+":generateContent", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var response geminiChatResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newError("gemini", resp.StatusCode, "", "", strings.TrimSpace(string(data)))
+		}
+		return "", fmt.Errorf("gemini: unexpected response (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if response.Error != nil || resp.StatusCode != http.StatusOK {
+		return "", newGeminiError(resp.StatusCode, response.Error)
+	}
+	if len(response.Candidates) == 0 {
+		return "", fmt.Errorf("gemini: response contains no candidates")
+	}
+	candidate := response.Candidates[0]
+	if candidate.FinishReason == "SAFETY" {
+		return "", &Error{Code: ContentFiltered, Provider: "gemini", Status: resp.StatusCode, Message: "the response was blocked by the safety settings"}
+	}
+
+	text := ""
+	for _, part := range candidate.Content.Parts {
+		text += part.Text
+	}
+	return text, nil
+}
+
+// Models returns the names of the models available to the API key, without
+// the "models/" prefix. It fails if the API key is invalid.
+func (c *Gemini) Models(ctx context.Context) ([]string, error) {
+	models := []string{}
+	pageToken := ""
+	for {
+		path := "/models?pageSize=1000"
+		if pageToken != "" {
+			path += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+			NextPageToken string       `json:"nextPageToken"`
+			Error         *geminiError `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, newGeminiError(resp.StatusCode, response.Error)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gemini: unexpected models response: %v", err)
+		}
+
+		for _, model := range response.Models {
+			models = append(models, strings.TrimPrefix(model.Name, "models/"))
+		}
+		if response.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
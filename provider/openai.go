@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultOpenAIBaseURL is the base URL of the OpenAI API.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI is a client for the OpenAI chat completions API.
+type OpenAI struct {
+	apiKey  string
+	baseURL string
+	http    *http.Client
+}
+
+// NewOpenAI returns an OpenAI client. If baseURL is empty, the official API is used.
+// Requests are paced by a limiter that is shared by all clients of the same
+// API key and retried when they are rate limited.
+func NewOpenAI(apiKey string, baseURL string) *OpenAI {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	c := &OpenAI{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+	c.http = &http.Client{
+		Transport: &rateLimitTransport{
+			base:    http.DefaultTransport,
+			limiter: SharedLimiter(c.limiterKey(), 0),
+		},
+	}
+	return c
+}
+
+// limiterKey identifies the account of the client, rate limits apply per API key.
+func (c *OpenAI) limiterKey() string {
+	return "openai " + c.baseURL + " " + c.apiKey
+}
+
+// SetLimiter paces the requests of the client with the given limiter.
+func (c *OpenAI) SetLimiter(limiter *Limiter) {
+	c.http.Transport.(*rateLimitTransport).limiter = limiter
+}
+
+func (c *OpenAI) Name() string {
+	return "openai"
+}
+
+type openAIChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Seed        *int      `json:"seed,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Error *openAIError `json:"error"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	// Code is a string, or a number for some compatible servers
+	Code any `json:"code"`
+}
+
+// newOpenAIError returns the error of a failed response of the API.
+func newOpenAIError(status int, failure *openAIError) *Error {
+	if failure == nil {
+		return newError("openai", status, "", "", "")
+	}
+	code := ""
+	if failure.Code != nil {
+		code = fmt.Sprint(failure.Code)
+	}
+	return newError("openai", status, failure.Type, code, failure.Message)
+}
+
+func (c *OpenAI) Chat(ctx context.Context, request *ChatRequest) (string, error) {
+	model := request.Model
+	if model == "" {
+		model = "gpt-3.5-turbo"
+	}
+
+	body, err := json.Marshal(&openAIChatRequest{
+		Model:       model,
+		Messages:    request.Messages,
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+		Seed:        request.Seed,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var response openAIChatResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newError("openai", resp.StatusCode, "", "", strings.TrimSpace(string(data)))
+		}
+		return "", fmt.Errorf("openai: unexpected response (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if response.Error != nil || resp.StatusCode != http.StatusOK {
+		return "", newOpenAIError(resp.StatusCode, response.Error)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("openai: response contains no choices")
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// Models returns the IDs of the models available to the API key. It fails if
+// the API key is invalid.
+func (c *OpenAI) Models(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response openAIChatResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		if response.Error == nil && resp.StatusCode == http.StatusUnauthorized {
+			response.Error = &openAIError{Message: "invalid API key"}
+		}
+		return nil, newOpenAIError(resp.StatusCode, response.Error)
+	}
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("openai: unexpected models response: %v", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, model := range response.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
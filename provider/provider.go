@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Message is a single message of a chat conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest is a provider independent chat completion request.
+type ChatRequest struct {
+	Model       string
+	Messages    []Message
+	Temperature *float64
+	MaxTokens   int
+	// Seed asks for reproducible samples. OpenAI and Gemini sample the same
+	// answer for the same seed on a best-effort basis, Anthropic has no seed.
+	Seed *int
+}
+
+// Client sends chat completion requests to an LLM provider.
+type Client interface {
+	Name() string
+	Chat(ctx context.Context, request *ChatRequest) (string, error)
+}
+
+// ModelLister is implemented by clients that can list the models available to
+// their API key.
+type ModelLister interface {
+	Models(ctx context.Context) ([]string, error)
+}
+
+// New returns a client for the provider with the given name or alias, see
+// Providers. API keys are looked up in env, which is usually the project's
+// .env file. Clients of the same account share a rate limiter, whose rate can
+// be set with OPENAI_REQUESTS_PER_MINUTE, ANTHROPIC_REQUESTS_PER_MINUTE or
+// GEMINI_REQUESTS_PER_MINUTE.
+func New(name string, env map[string]string) (Client, error) {
+	if name == "" {
+		name = Providers[0].Name
+	}
+	info, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := env[info.APIKey]
+	if apiKey == "" {
+		return nil, fmt.Errorf("%s is not set", info.APIKey)
+	}
+
+	var client interface {
+		Client
+		limiterKey() string
+		SetLimiter(limiter *Limiter)
+	}
+	switch info.Name {
+	case "openai":
+		client = NewOpenAI(apiKey, env["OPENAI_API_BASE"])
+	case "anthropic":
+		client = NewAnthropic(apiKey, env["ANTHROPIC_BASE_URL"])
+	case "gemini":
+		client = NewGemini(apiKey, "")
+	}
+
+	variable := strings.ToUpper(info.Name) + "_REQUESTS_PER_MINUTE"
+	if value := env[variable]; value != "" {
+		requestsPerMinute, err := strconv.Atoi(value)
+		if err != nil || requestsPerMinute < 0 {
+			return nil, fmt.Errorf("%s must be a number of requests", variable)
+		}
+		client.SetLimiter(SharedLimiter(client.limiterKey(), requestsPerMinute))
+	}
+	return client, nil
+}
@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRetries is the number of times a rate limited request is retried.
+const maxRetries = 5
+
+// maxBackoff caps the delay between retries if the provider does not send a
+// Retry-After header.
+const maxBackoff = 30 * time.Second
+
+// Limiter is a token bucket that paces the requests to a provider account.
+// When the provider reports that the account is rate limited, all requests
+// sharing the limiter are paused, so that concurrent callers such as a batch
+// evaluation back off together instead of retrying into more 429 responses.
+type Limiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second, 0 for no client-side pacing
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter returns a limiter that allows requestsPerMinute requests per
+// minute. With zero requests per minute, requests are only paused when the
+// provider asks for it.
+func NewLimiter(requestsPerMinute int) *Limiter {
+	l := &Limiter{last: time.Now()}
+	l.setRate(requestsPerMinute)
+	l.tokens = l.burst
+	return l
+}
+
+func (l *Limiter) setRate(requestsPerMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// allow a burst of one second worth of requests
+	l.rate = float64(requestsPerMinute) / 60
+	l.burst = l.rate
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*Limiter{}
+)
+
+// SharedLimiter returns the limiter for the given account key, creating it on
+// first use. A non-zero rate replaces the rate of an existing limiter.
+func SharedLimiter(key string, requestsPerMinute int) *Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiter, ok := limiters[key]
+	if !ok {
+		limiter = NewLimiter(requestsPerMinute)
+		limiters[key] = limiter
+	} else if requestsPerMinute > 0 {
+		limiter.setRate(requestsPerMinute)
+	}
+	return limiter
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token and returns zero, or returns how long to wait before
+// trying again.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate == 0 {
+		return 0
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Pause holds back all requests for the given duration.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// NewRateLimitTransport returns a transport that paces the requests of base
+// with limiter and retries requests that were rejected because of rate limits
+// or overload, e.g. for clients of other APIs than those of the providers.
+func NewRateLimitTransport(base http.RoundTripper, limiter *Limiter) http.RoundTripper {
+	return &rateLimitTransport{base: base, limiter: limiter}
+}
+
+// rateLimitTransport waits for the limiter before each request and retries
+// requests that were rejected because of rate limits or overload.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			// the provider may announce that the next request will be limited
+			if resp.Header.Get("X-Ratelimit-Remaining-Requests") == "0" {
+				if reset, err := time.ParseDuration(resp.Header.Get("X-Ratelimit-Reset-Requests")); err == nil {
+					t.limiter.Pause(reset)
+				}
+			}
+			return resp, nil
+		}
+
+		if attempt == maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		t.limiter.Pause(retryDelay(resp, attempt))
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryDelay returns the delay requested by the Retry-After header of a
+// response, or an exponential backoff with jitter if there is none.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date)
+		}
+	}
+
+	backoff := time.Second << attempt
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+}
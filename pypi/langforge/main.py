@@ -19,14 +19,12 @@ def get_path():
         print(f'Unsupported platform: {platform_system}', file=sys.stderr)
         sys.exit(1)   
       
+    # Windows reports AMD64 and ARM64 in upper case
     machine = platform.machine()
     arch = None
-    if machine in ['x86_64', 'AMD64']:
+    if machine.lower() in ['x86_64', 'amd64']:
         arch = 'amd64'
-    elif machine in ['arm64', 'aarch64']:
-        if os_name == 'windows':
-            print(f'Unsupported windows architecture: {machine}', file=sys.stderr)
-            sys.exit(1)  
+    elif machine.lower() in ['arm64', 'aarch64']:
         arch = 'arm64'
     else:
         print(f'Unsupported architecture: {machine}', file=sys.stderr)
@@ -0,0 +1,90 @@
+package python
+
+import (
+	"fmt"
+	"langforge/system"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the directories of the environments of a project.
+const (
+	VirtualEnvName = ".venv"
+	CondaEnvName   = ".conda"
+)
+
+// CreateCondaEnv creates a conda environment at envPath with Python and pip,
+// using conda, mamba or micromamba. python is the version of Python, e.g.
+// "3.11", or a constraint such as ">=3.10"; if it is empty, the newest Python
+// of the channel is installed.
+func CreateCondaEnv(envPath string, python string) error {
+	conda, err := system.FindConda()
+	if err != nil {
+		return err
+	}
+	envAbsPath, err := filepath.Abs(envPath)
+	if err != nil {
+		return err
+	}
+
+	spec := "python"
+	switch {
+	case python == "":
+	case strings.ContainsAny(python[:1], "<>=!"):
+		spec += python
+	default:
+		spec += "=" + python
+	}
+	cmd := conda.CreateCommand(envAbsPath, spec, "pip")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if system.SkipDryRun(cmd) {
+		return nil
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed to create the environment: %v", conda.Name, err)
+	}
+	return nil
+}
+
+// ActivateCondaEnv activates the conda environment at envPath for langforge
+// and the processes it starts. Like 'conda activate', it sets CONDA_PREFIX
+// and puts the directories of the environment first in the PATH; the
+// activation scripts of packages are not run.
+func ActivateCondaEnv(envPath string) error {
+	envAbsPath, err := filepath.Abs(envPath)
+	if err != nil {
+		return err
+	}
+	if !system.IsCondaEnv(envAbsPath) && system.IsDryRun() {
+		return nil
+	}
+	if !system.IsCondaEnv(envAbsPath) {
+		return fmt.Errorf("%s is not a conda environment", envPath)
+	}
+
+	paths := append(system.CondaPaths(envAbsPath), os.Getenv("PATH"))
+	system.Setenv("PATH", strings.Join(paths, string(os.PathListSeparator)))
+	system.Setenv("CONDA_PREFIX", envAbsPath)
+	system.Setenv("CONDA_DEFAULT_ENV", envAbsPath)
+	system.Unsetenv("VIRTUAL_ENV")
+	system.Unsetenv("PYTHONHOME")
+	return nil
+}
+
+// ActivateProjectEnvironment activates the environment of the project in
+// dir, a virtual environment in .venv or a conda environment in .conda, and
+// returns its path. If the project has neither, it returns an empty path and
+// langforge uses the current environment.
+func ActivateProjectEnvironment(dir string) (string, error) {
+	venvDir := filepath.Join(dir, VirtualEnvName)
+	if _, err := os.Stat(venvDir); err == nil {
+		return venvDir, ActivateEnvironment(venvDir)
+	}
+	condaDir := filepath.Join(dir, CondaEnvName)
+	if system.IsCondaEnv(condaDir) {
+		return condaDir, ActivateCondaEnv(condaDir)
+	}
+	return "", nil
+}
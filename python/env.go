@@ -1,11 +1,12 @@
 package python
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"langforge/system"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 )
 
@@ -13,33 +14,38 @@ import (
 // If a directory is provided, the environment is looked for within that directory. Otherwise,
 // it is looked for in the default environment directory.
 func ActivateEnvironment(envName string, envDir ...string) error {
-	var activateScript string
+	envPath := envName
+	if len(envDir) > 0 {
+		envPath = filepath.Join(envDir[0], envName)
+	}
 
-	if system.IsWindows() {
-		if system.IsPowerShell() {
-			if len(envDir) > 0 {
-				activateScript = path.Join(envDir[0], envName, "Scripts", "Activate.ps1")
-			} else {
-				activateScript = path.Join(envName, "Scripts", "Activate.ps1")
-			}
-		} else {
-			if len(envDir) > 0 {
-				activateScript = filepath.Join(envDir[0], envName, "Scripts", "activate.bat")
-			} else {
-				activateScript = filepath.Join(envName, "Scripts", "activate.bat")
-			}
-		}
+	// Python for Windows creates environments with a Scripts directory, the
+	// Pythons of MSYS2 and Cygwin create a bin directory as on Linux
+	venv := &system.Venv{Path: envPath}
+	windowsLayout := system.IsWindows() && !venv.HasPOSIXLayout()
 
-	} else {
-		if len(envDir) > 0 {
-			activateScript = path.Join(envDir[0], envName, "bin", "activate")
-		} else {
-			activateScript = path.Join(envName, "bin", "activate")
+	var activateScript string
+	switch {
+	case windowsLayout && system.IsPowerShell():
+		activateScript = filepath.Join(envPath, "Scripts", "Activate.ps1")
+	case windowsLayout:
+		// also from Git Bash, whose paths would not work for langforge
+		activateScript = filepath.Join(envPath, "Scripts", "activate.bat")
+	default:
+		// the activate script of the login shell, e.g. activate.fish, which
+		// environments of older tools may not have
+		activateScript = filepath.Join(envPath, "bin", system.LoginShell().ActivateScript())
+		if _, err := os.Stat(activateScript); err != nil {
+			activateScript = filepath.Join(envPath, "bin", "activate")
 		}
 	}
 
 	// Check if the environment exists
 	if _, err := os.Stat(activateScript); err != nil {
+		if os.IsNotExist(err) && system.IsDryRun() {
+			// a dry run did not create it, the current environment stays
+			return nil
+		}
 		if os.IsNotExist(err) {
 			return fmt.Errorf("environment %q not found", envName)
 		}
@@ -47,20 +53,25 @@ func ActivateEnvironment(envName string, envDir ...string) error {
 	}
 
 	// Activate the environment
-	if system.IsWindows() {
-		if system.IsPowerShell() {
-			if err := system.ShellSourcePowerShell(activateScript); err != nil {
-				return fmt.Errorf("failed to activate environment %q: %v", envName, err)
-			}
-		} else {
-			if err := system.ShellSourceBatch(activateScript); err != nil {
-				return fmt.Errorf("failed to activate environment %q: %v", envName, err)
-			}
-		}
-	} else {
-		if err := system.ShellSourceUnix(activateScript); err != nil {
-			return fmt.Errorf("failed to activate environment %q: %v", envName, err)
-		}
+	var env *system.Environment
+	var err error
+	switch {
+	case windowsLayout && system.IsPowerShell():
+		env, err = system.ShellSourcePowerShell(activateScript)
+	case windowsLayout:
+		env, err = system.ShellSourceBatch(activateScript)
+	case system.IsWindows():
+		// the activate script of a bin directory sets POSIX paths, which
+		// would not work for langforge
+		_, err = system.ActivateVenv(envPath)
+	default:
+		env, err = system.ShellSourceUnix(activateScript)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to activate environment %q: %v", envName, err)
+	}
+	if env != nil {
+		env.Apply()
 	}
 
 	return nil
@@ -75,10 +86,6 @@ func CreateVirtualEnv(envName string, envDir ...string) error {
 	if len(envDir) > 0 {
 		envPath = filepath.Join(envDir[0], envName)
 	}
-	envAbsPath, err := filepath.Abs(envPath)
-	if err != nil {
-		return err
-	}
 
 	// Find the path to the Python interpreter
 	pythonPath, err := system.FindPython()
@@ -86,43 +93,84 @@ func CreateVirtualEnv(envName string, envDir ...string) error {
 		return err
 	}
 
-	// Create the virtual environment using the venv module
-	var cmd *exec.Cmd
-
-	if system.IsWindows() {
-		cmd = exec.Command(pythonPath, "-m", "venv", "--clear", envAbsPath)
-	} else {
-		cmd = exec.Command(pythonPath, "-m", "venv", "--clear", "--symlinks", envAbsPath)
-	}
+	return CreateVirtualEnvWithPython(pythonPath, envPath)
+}
 
-	// Set the command's Stdout and Stderr fields to os.Stdout and os.Stderr respectively
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// CreateVirtualEnvWithPython creates the virtual environment at envPath with
+// the given Python interpreter instead of the one found in the PATH.
+func CreateVirtualEnvWithPython(pythonPath string, envPath string) error {
+	_, err := system.CreateVenv(envPath, pythonPath)
+	return err
+}
 
-	// Start the command
-	if err := cmd.Run(); err != nil {
+func WriteRequirementsTxt(path string) error {
+	output, err := FreezeRequirements()
+	if err != nil {
 		return err
 	}
 
+	err = os.WriteFile(path, output, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write requirements.txt: %v", err)
+	}
+
 	return nil
 }
 
-func WriteRequirementsTxt(path string) error {
-	pipPath, err := system.FindPip()
+// FreezeRequirements returns the installed packages of the active
+// environment pinned to their versions, in the format of requirements.txt.
+func FreezeRequirements() ([]byte, error) {
+	name, args, err := pipCommand("freeze", "--local")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the output of pip freeze: %v", err)
+	}
+	return output, nil
+}
+
+// RunScript runs a Python script that is passed via stdin with the given arguments
+// and returns its standard output.
+func RunScript(script []byte, args ...string) ([]byte, error) {
+	pythonPath, err := system.FindPython()
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.Command(pipPath, "freeze", "--local")
+	cmd := exec.Command(pythonPath, append([]string{"-"}, args...)...)
+	cmd.Stdin = bytes.NewReader(script)
+	cmd.Stderr = os.Stderr
+
 	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to get the output of pip freeze: %v", err)
+		return nil, fmt.Errorf("failed to run python script: %v", err)
 	}
 
-	err = os.WriteFile(path, output, 0644)
+	return output, nil
+}
+
+// StreamScript runs a Python script that is passed via stdin with the given
+// arguments until it exits or ctx is canceled, and passes the lines of its
+// standard output to output as they are written.
+func StreamScript(ctx context.Context, script []byte, output func(line string), args ...string) error {
+	pythonPath, err := system.FindPython()
 	if err != nil {
-		return fmt.Errorf("failed to write requirements.txt: %v", err)
+		return err
 	}
 
+	runner := &system.Runner{
+		Command: func(name string, arg ...string) *exec.Cmd {
+			cmd := exec.Command(name, arg...)
+			cmd.Stdin = bytes.NewReader(script)
+			return cmd
+		},
+		Stdout: output,
+	}
+	if err := runner.Run(ctx, pythonPath, append([]string{"-"}, args...)...); err != nil {
+		return fmt.Errorf("failed to run python script: %w", err)
+	}
 	return nil
 }
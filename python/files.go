@@ -3,16 +3,113 @@ package python
 import (
 	"embed"
 	"io/fs"
+	"langforge/bundle"
 )
 
-//go:embed files/integrations.yaml
+//go:embed files/integrations/integrations.yaml
 //go:embed files/startup/00-dotenv.py
 //go:embed files/startup/10-extension-support.py
 //go:embed files/startup/20-utilities.py
+//go:embed files/startup/30-prompts.py
+//go:embed files/startup/40-embeddings.py
 //go:embed files/server.py
 //go:embed files/langforge-0.1.0-py3-none-any.whl
+//go:embed files/package/chains.py.tmpl
+//go:embed files/package/pyproject.toml.tmpl
+//go:embed files/vectorstore/status.py
+//go:embed files/vectorstore/stores.py
+//go:embed files/vectorstore/migrate.py
+//go:embed files/vectorstore/ingest.py
+//go:embed files/vectorstore/loaders.py
+//go:embed files/vectorstore/tune.py
+//go:embed files/vectorstore/objects.py
+//go:embed files/vectorstore/list.py
+//go:embed files/vectorstore/imports.py
+//go:embed files/preflight/imports.py
 var embeddedFS embed.FS
 
+func init() {
+	integrations, err := fs.Sub(embeddedFS, "files/integrations")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.Integrations, integrations)
+	packages, err := fs.Sub(embeddedFS, "files/package")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.Packages, packages)
+}
+
 func ServerPy() ([]byte, error) {
 	return fs.ReadFile(embeddedFS, "files/server.py")
 }
+
+// PromptsPy returns the Python helper that loads prompt templates from the
+// project's prompts directory.
+func PromptsPy() ([]byte, error) {
+	return fs.ReadFile(embeddedFS, "files/startup/30-prompts.py")
+}
+
+// EmbeddingsPy returns the Python helper that caches the embeddings of an
+// embedding model in the project's embeddings cache.
+func EmbeddingsPy() ([]byte, error) {
+	return fs.ReadFile(embeddedFS, "files/startup/40-embeddings.py")
+}
+
+// VectorStoreStatusPy returns the Python script that reports the collections of
+// an embedded vector store as JSON.
+func VectorStoreStatusPy() ([]byte, error) {
+	return fs.ReadFile(embeddedFS, "files/vectorstore/status.py")
+}
+
+// VectorStoreMigratePy returns the Python script that copies a collection from
+// one vector store to another and reports its progress as JSON lines.
+func VectorStoreMigratePy() ([]byte, error) {
+	return concatFiles("files/vectorstore/stores.py", "files/vectorstore/migrate.py")
+}
+
+// VectorStoreIngestPy returns the Python script that ingests the documents of
+// the ingestion sources of a project and reports its progress as JSON lines.
+func VectorStoreIngestPy() ([]byte, error) {
+	return concatFiles("files/startup/40-embeddings.py", "files/vectorstore/stores.py", "files/vectorstore/objects.py", "files/vectorstore/loaders.py",
+		"files/vectorstore/ingest.py")
+}
+
+// VectorStoreTunePy returns the Python script that benchmarks the retrieval of
+// chunking configurations and reports their metrics as JSON lines.
+func VectorStoreTunePy() ([]byte, error) {
+	return concatFiles("files/startup/40-embeddings.py", "files/vectorstore/loaders.py", "files/vectorstore/tune.py")
+}
+
+// VectorStoreListPy returns the Python script that lists the objects of an
+// ingestion source in an object store as JSON lines.
+func VectorStoreListPy() ([]byte, error) {
+	return concatFiles("files/vectorstore/objects.py", "files/vectorstore/list.py")
+}
+
+// VectorStoreImportsPy returns the Python script that imports the modules
+// passed as arguments and reports those that fail as JSON.
+func VectorStoreImportsPy() ([]byte, error) {
+	return fs.ReadFile(embeddedFS, "files/vectorstore/imports.py")
+}
+
+// concatFiles joins embedded Python files into a single script, whose later
+// parts use the definitions of the earlier ones.
+func concatFiles(names ...string) ([]byte, error) {
+	script := []byte{}
+	for _, name := range names {
+		data, err := fs.ReadFile(embeddedFS, name)
+		if err != nil {
+			return nil, err
+		}
+		script = append(append(script, data...), "\n\n"...)
+	}
+	return script, nil
+}
+
+// PreflightImportsPy returns the Python script that imports the modules used by
+// a notebook and the server and reports failures as JSON.
+func PreflightImportsPy() ([]byte, error) {
+	return fs.ReadFile(embeddedFS, "files/preflight/imports.py")
+}
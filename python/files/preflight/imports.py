@@ -0,0 +1,49 @@
+import ast
+import importlib
+import json
+import sys
+
+# modules the langforge server needs in addition to the notebook's imports
+SERVER_MODULES = ["dotenv", "jupyter_notebook_parser", "langchain"]
+
+notebook = sys.argv[1]
+
+with open(notebook, encoding="utf-8") as f:
+    cells = json.load(f).get("cells", [])
+
+source = []
+for cell in cells:
+    if cell.get("cell_type") != "code":
+        continue
+    lines = cell.get("source", [])
+    if isinstance(lines, str):
+        lines = lines.splitlines(True)
+    source.extend(line for line in lines if not line.lstrip().startswith(("%", "!")))
+    source.append("\n")
+
+failures = []
+modules = list(SERVER_MODULES)
+try:
+    tree = ast.parse("".join(source))
+    for node in ast.walk(tree):
+        if isinstance(node, ast.Import):
+            modules.extend(alias.name for alias in node.names)
+        elif isinstance(node, ast.ImportFrom) and node.level == 0 and node.module:
+            modules.append(node.module)
+except SyntaxError as e:
+    failures.append({"module": notebook, "error": "syntax error in line %s: %s" % (e.lineno, e.msg)})
+
+seen = set()
+failed = set()
+for module in modules:
+    # report a missing package once rather than for each of its modules
+    if module in seen or module.split(".")[0] in failed:
+        continue
+    seen.add(module)
+    try:
+        importlib.import_module(module)
+    except Exception as e:
+        failed.add(module.split(".")[0])
+        failures.append({"module": module, "error": "%s: %s" % (type(e).__name__, e)})
+
+print(json.dumps(failures))
@@ -1,6 +1,5 @@
 import sys
 from jupyter_notebook_parser import JupyterNotebookParser # type: ignore
-from flask import Flask, jsonify, request # type: ignore
 import langchain.chains.base # type: ignore
 import copy
 from langchain.schema import ( # type: ignore
@@ -10,16 +9,43 @@ from langchain.schema import ( # type: ignore
 from dotenv import load_dotenv # type: ignore
 import os
 import argparse
-from waitress import serve # type: ignore
 import logging
+import base64
+import contextlib
+import inspect
+import json
+import socket
+import struct
+import threading
+from urllib.parse import urlsplit
+
+try:
+    from langchain.callbacks import get_openai_callback # type: ignore
+except ImportError:
+    get_openai_callback = None
+
+try:
+    from langchain.callbacks.base import BaseCallbackHandler # type: ignore
+except ImportError:
+    BaseCallbackHandler = None
+
+try:
+    from langchain.schema.language_model import BaseLanguageModel # type: ignore
+except ImportError:
+    try:
+        from langchain.base_language import BaseLanguageModel # type: ignore
+    except ImportError:
+        BaseLanguageModel = None
 
 parser = argparse.ArgumentParser(description="LangForge server script")
 parser.add_argument("filename", help="File name")
 parser.add_argument("--port", type=int, default=2204, help="Port number (default: 2204)")
+parser.add_argument("--host", default="0.0.0.0", help="Host address (default: 0.0.0.0)")
 args = parser.parse_args()
 
 filename = args.filename
 port = args.port
+host = args.host
 
 env_path = os.path.join(os.getcwd(), '.env')
 load_dotenv(env_path)
@@ -37,48 +63,385 @@ code = "\n".join([line for line in code.split('\n') if not line.startswith('%')]
 
 exec(code, globals(), locals())
 
-app = Flask(__name__)
+PROTOCOL_VERSION = 3
+
 logger = logging.getLogger("langforge")
 logger.setLevel(logging.INFO)
 
-@app.before_request
-def before_request():
-    logger.info(f"{request.method} {request.path} - {request.remote_addr}")
 
+# os.environ is shared by all threads, so chains with their own environment
+# variables run one at a time
+env_lock = threading.Lock()
+
+
+@contextlib.contextmanager
+def chain_env(request):
+    header = request.header("X-Langforge-Env")
+    if not header:
+        yield
+        return
+    env = json.loads(base64.b64decode(header))
+    with env_lock:
+        previous = {k: os.environ.get(k) for k in env}
+        os.environ.update(env)
+        try:
+            yield
+        finally:
+            for k, v in previous.items():
+                if v is None:
+                    os.environ.pop(k, None)
+                else:
+                    os.environ[k] = v
+
+
+# the attributes that LLMs of different providers have for the parameters of
+# presets, of which the first an LLM has is set
+PRESET_ATTRIBUTES = {
+    "temperature": ["temperature"],
+    "max_tokens": ["max_tokens", "max_tokens_to_sample", "max_output_tokens", "max_new_tokens", "num_predict"],
+    "top_p": ["top_p"],
+    "stop": ["stop", "stop_sequences"],
+    "seed": ["seed", "random_seed"],
+}
+
+
+def find_llms(value, found=None, seen=None):
+    if found is None:
+        found, seen = [], set()
+    if id(value) in seen:
+        return found
+    seen.add(id(value))
+    if isinstance(value, BaseLanguageModel):
+        found.append(value)
+    elif isinstance(value, (list, tuple)):
+        for item in value:
+            find_llms(item, found, seen)
+    elif isinstance(value, dict):
+        for item in value.values():
+            find_llms(item, found, seen)
+    elif hasattr(value, "__fields__") and not isinstance(value, type):
+        # chains, agents and retrievers are pydantic models
+        for item in vars(value).values():
+            find_llms(item, found, seen)
+    return found
+
+
+def apply_preset(var, request):
+    """Sets the generation parameters of the preset of the request on the
+    LLMs of var, which is a copy of the chain. LLMs without an attribute for
+    a parameter keep their own."""
+    header = request.header("X-Langforge-Params")
+    if not header or BaseLanguageModel is None:
+        return
+    params = json.loads(base64.b64decode(header))
+    for llm in find_llms(var):
+        for param, value in params.items():
+            for attribute in PRESET_ATTRIBUTES.get(param, []):
+                if hasattr(llm, attribute):
+                    setattr(llm, attribute, value)
+                    break
+
+
+@contextlib.contextmanager
+def usage_callback():
+    # reports the cost of OpenAI models, the gateway prices other models
+    if get_openai_callback is None:
+        yield None
+        return
+    with get_openai_callback() as cb:
+        yield cb
+
+
+class Usage:
+    """The tokens that the LLMs of a chain invocation used and their models,
+    so that the gateway can price the models of other providers than OpenAI,
+    e.g. of Anthropic and Gemini."""
+
+    def __init__(self):
+        self.prompt_tokens = 0
+        self.completion_tokens = 0
+        self.models = set()
+        self.run_models = {}
+
+    def start(self, run_id, invocation_params):
+        params = invocation_params or {}
+        model = params.get("model") or params.get("model_name")
+        if model:
+            self.run_models[run_id] = model
+
+    def add(self, run_id, response):
+        # OpenAI and Anthropic report the usage of a call in llm_output,
+        # newer integrations, e.g. of Gemini, on the messages
+        output = response.llm_output or {}
+        usage = output.get("token_usage") or output.get("usage") or {}
+        if not isinstance(usage, dict):
+            usage = {}
+        prompt = usage.get("prompt_tokens") or usage.get("input_tokens") or 0
+        completion = usage.get("completion_tokens") or usage.get("output_tokens") or 0
+        model = output.get("model_name") or output.get("model") or self.run_models.pop(run_id, None)
+        if not prompt and not completion:
+            for generations in response.generations:
+                for generation in generations:
+                    message = getattr(generation, "message", None)
+                    metadata = getattr(message, "usage_metadata", None) or {}
+                    prompt += metadata.get("input_tokens") or 0
+                    completion += metadata.get("output_tokens") or 0
+        if not prompt and not completion:
+            return
+        self.prompt_tokens += prompt
+        self.completion_tokens += completion
+        if model:
+            self.models.add(model)
+
+
+def usage_header(cb, usage):
+    if cb is not None and cb.total_cost:
+        return json.dumps({
+            "prompt_tokens": cb.prompt_tokens,
+            "completion_tokens": cb.completion_tokens,
+            "cost": cb.total_cost,
+        })
+    if not usage.prompt_tokens and not usage.completion_tokens:
+        return None
+    header = {
+        "prompt_tokens": usage.prompt_tokens,
+        "completion_tokens": usage.completion_tokens,
+        "cost": 0,
+    }
+    # the gateway prices the tokens if they were used by a single model
+    if len(usage.models) == 1:
+        header["model"] = next(iter(usage.models))
+    return json.dumps(header)
+
+
+def is_chain(var):
+    return isinstance(var, langchain.chains.base.Chain) or issubclass(type(var), langchain.chains.base.Chain)
+
+
+def field_descriptions(model):
+    try:
+        schema = model.schema()
+    except Exception:
+        return {}
+    return {k: v.get("description") for k, v in schema.get("properties", {}).items() if v.get("description")}
+
+
+def keys_schema(title, keys, descriptions, memory=False):
+    properties = {}
+    for key in keys:
+        properties[key] = {"type": "string"}
+        if key in descriptions:
+            properties[key]["description"] = descriptions[key]
+    if memory:
+        properties["memory"] = {
+            "type": "array",
+            "items": {"type": "string"},
+            "description": "Previous messages, alternating between human and AI",
+        }
+    return {
+        "title": title,
+        "type": "object",
+        "properties": properties,
+        "required": list(keys),
+        "additionalProperties": False,
+    }
+
+
+class Cancelled(Exception):
+    pass
+
+
+class Request:
+    def __init__(self, connection, message):
+        self.connection = connection
+        self.id = message["id"]
+        self.method = message.get("method", "GET")
+        url = urlsplit(message.get("path", "/"))
+        self.path = url.path
+        self.headers = {k.lower(): v[0] for k, v in (message.get("header") or {}).items() if v}
+        self.body = base64.b64decode(message.get("body") or "")
+        self.attachments = message.get("attachments") or []
+        self.cancelled = threading.Event()
+        self.started = False
+
+    def header(self, name):
+        return self.headers.get(name.lower())
+
+    def start(self, status, headers):
+        self.started = True
+        self.connection.send({
+            "type": "response",
+            "id": self.id,
+            "status": status,
+            "header": {k: [v] for k, v in headers.items()},
+        })
+
+    def write(self, data):
+        if isinstance(data, str):
+            data = data.encode("utf-8")
+        self.connection.send({"type": "chunk", "id": self.id, "data": base64.b64encode(data).decode("ascii")})
+
+    def end(self):
+        self.connection.send({"type": "end", "id": self.id})
+
+    def fail(self, message):
+        self.connection.send({"type": "error", "id": self.id, "error": message})
+
+    def respond(self, status, value, headers=None):
+        body = json.dumps(value).encode("utf-8")
+        headers = dict(headers or {})
+        headers["Content-Type"] = "application/json"
+        headers["Content-Length"] = str(len(body))
+        self.start(status, headers)
+        self.write(body)
+        self.end()
+
+
+# callbacks are how a running chain notices that its request was canceled,
+# how the tokens of streaming LLMs are passed on and how their usage is counted
+if BaseCallbackHandler is not None:
+    class RequestCallbackHandler(BaseCallbackHandler):
+        raise_error = True
+
+        def __init__(self, request, on_token=None, usage=None):
+            self.request = request
+            self.on_token = on_token
+            self.usage = usage
+
+        def check(self):
+            if self.request.cancelled.is_set():
+                raise Cancelled()
+
+        def on_llm_start(self, *args, **kwargs):
+            self.check()
+            if self.usage is not None:
+                self.usage.start(kwargs.get("run_id"), kwargs.get("invocation_params"))
 
-@app.route('/chat/<name>', methods=['POST'])
-def chat(name):
+        def on_llm_end(self, response, **kwargs):
+            if self.usage is not None:
+                self.usage.add(kwargs.get("run_id"), response)
+
+        def on_llm_new_token(self, token, **kwargs):
+            self.check()
+            if self.on_token is not None:
+                self.on_token(token)
+
+        def on_chain_start(self, *args, **kwargs):
+            self.check()
+
+        def on_tool_start(self, *args, **kwargs):
+            self.check()
+
+        def on_agent_action(self, *args, **kwargs):
+            self.check()
+else:
+    RequestCallbackHandler = None
+
+
+def request_metadata(request):
+    """Returns the IDs that the gateway assigned to the request, which are
+    attached to the traces of the chain, e.g. in LangSmith, to correlate them
+    with the access log of the gateway."""
+    metadata = {}
+    request_id = request.header("X-Request-Id")
+    if request_id:
+        metadata["request_id"] = request_id
+    traceparent = (request.header("Traceparent") or "").split("-")
+    if len(traceparent) == 4:
+        metadata["trace_id"] = traceparent[1]
+        metadata["parent_span_id"] = traceparent[2]
+    return metadata
+
+
+def run_chain(var, args, request, on_token=None, usage=None):
+    parameters = inspect.signature(var.__call__).parameters
+    kwargs = {}
+    if RequestCallbackHandler is not None and "callbacks" in parameters:
+        kwargs["callbacks"] = [RequestCallbackHandler(request, on_token, usage)]
+    if "metadata" in parameters:
+        kwargs["metadata"] = request_metadata(request)
+    return var(args, **kwargs)
+
+
+def schemas(request):
+    chains = []
+    for name, var in list(globals().items()):
+        if name.startswith('_') or not is_chain(var):
+            continue
+        input_descriptions = field_descriptions(getattr(var, 'input_schema', None))
+        output_descriptions = field_descriptions(getattr(var, 'output_schema', None))
+        memory = getattr(var, 'memory', None) is not None
+        chains.append({
+            "name": name,
+            "description": (type(var).__doc__ or "").strip().split("\n")[0],
+            "input": keys_schema(name + "Input", var.input_keys, input_descriptions, memory),
+            "output": keys_schema(name + "Output", var.output_keys, output_descriptions),
+        })
+    request.respond(200, {"chains": chains})
+
+
+def error_body(e):
+    """Returns the error response for an exception. Exceptions of provider SDKs
+    carry the status and error code of the provider, from which the gateway
+    derives a stable error code."""
+    body = {"error": str(e), "type": type(e).__name__}
+    status = getattr(e, "status_code", None) or getattr(e, "http_status", None)
+    if isinstance(status, int):
+        body["upstreamStatus"] = status
+    code = getattr(e, "code", None)
+    if isinstance(code, str):
+        body["upstreamCode"] = code
+    return body
+
+
+def server_sent_event(event, value):
+    return "event: %s\ndata: %s\n\n" % (event, json.dumps(value))
+
+
+def chat(request, name):
     found = False
     var = None
 
     if name in globals():
         var = globals()[name]
-        if isinstance(var, langchain.chains.base.Chain) or issubclass(type(var), langchain.chains.base.Chain):
+        if is_chain(var):
             found = True
-             
+
     if not found:
-        return jsonify({"error": 'Variable %s not found' % name}), 404    
+        return request.respond(404, {"error": 'Variable %s not found' % name})
 
-    data = request.get_json()
+    try:
+        data = json.loads(request.body)
+    except ValueError:
+        data = None
     if data is None:
-        return jsonify({"error": "Invalid JSON or no JSON provided"}), 400
+        return request.respond(400, {"error": "Invalid JSON or no JSON provided"})
 
     if not isinstance(data, dict):
-        return jsonify({"error": "JSON data should be an object"}), 400
+        return request.respond(400, {"error": "JSON data should be an object"})
+
+    # images are sent as attachments, which the chain receives as data URLs
+    for k, v in data.items():
+        if isinstance(v, dict) and v.get("type") == "image" and isinstance(v.get("attachment"), int):
+            if not 0 <= v["attachment"] < len(request.attachments):
+                return request.respond(400, {"error": "Invalid input %s" % k})
+            attachment = request.attachments[v["attachment"]]
+            data[k] = "data:%s;base64,%s" % (attachment["contentType"], attachment["data"])
 
     for k, v in data.items():
         # if v is an array
         if isinstance(v, list) and k == "memory":
             for el in v:
                 if not isinstance(el, str):
-                    return jsonify({"error": "Invalid input %s" % k}), 400
+                    return request.respond(400, {"error": "Invalid input %s" % k})
             continue
         elif not isinstance(v, str):
-            return jsonify({"error": "Invalid input %s" % k}), 400
+            return request.respond(400, {"error": "Invalid input %s" % k})
         if k not in var.input_keys:
-            return jsonify({"error": "Invalid input %s" % k}), 400
-        
+            return request.respond(400, {"error": "Invalid input %s" % k})
+
     var = copy.deepcopy(var)
+    apply_preset(var, request)
 
     if 'memory' in data:
         if hasattr(var, 'memory'):
@@ -90,13 +453,147 @@ def chat(name):
         if k == 'memory':
             continue
         args[k] = v
-    
-    result = var(args)
+
+    # with Accept: text/event-stream, the tokens of LLMs that stream are sent
+    # as token events followed by a result event with the outputs
+    stream = "text/event-stream" in (request.header("Accept") or "")
+    on_token = None
+    if stream:
+        request.start(200, {"Content-Type": "text/event-stream", "Cache-Control": "no-cache"})
+        on_token = lambda token: request.write(server_sent_event("token", {"token": token}))
+
+    usage = Usage()
+    try:
+        with chain_env(request), usage_callback() as cb:
+            result = run_chain(var, args, request, on_token, usage)
+    except Cancelled:
+        raise
+    except Exception as e:
+        if not stream:
+            raise
+        logger.exception("chain %s failed (request %s)", name, request.header("X-Request-Id"))
+        request.write(server_sent_event("error", error_body(e)))
+        return request.end()
+
     json_result = dict()
     for k, v in result.items():
         if isinstance(v, str):
             json_result[k] = v
-    return jsonify(json_result)
 
-print("Running on all addresses (0.0.0.0), port %s, filename %s" % (port, filename))
-serve(app, host='0.0.0.0', port=port)
+    if stream:
+        request.write(server_sent_event("result", json_result))
+        return request.end()
+
+    headers = {}
+    header = usage_header(cb, usage)
+    if header:
+        headers["X-Langforge-Usage"] = header
+    request.respond(200, json_result, headers)
+
+
+def handle(request):
+    logger.info(f"{request.method} {request.path}")
+    try:
+        if request.method == "GET" and request.path == "/schemas":
+            schemas(request)
+        elif request.method == "POST" and request.path.startswith("/chat/"):
+            chat(request, request.path[len("/chat/"):])
+        else:
+            request.respond(404, {"error": "Not found"})
+    except Cancelled:
+        logger.info(f"{request.method} {request.path} canceled")
+        request.fail("the request was canceled")
+    except Exception as e:
+        logger.exception("%s %s failed", request.method, request.path)
+        if request.started:
+            request.fail(str(e))
+        else:
+            request.respond(500, error_body(e))
+    finally:
+        request.connection.finish(request)
+
+
+class Connection:
+    """A connection to the langforge gateway.
+
+    Messages are frames of a 4 byte big endian length followed by a JSON
+    object. Requests are handled in threads, so that pings and cancellations
+    are answered while chains run.
+    """
+
+    def __init__(self, sock):
+        self.sock = sock
+        self.reader = sock.makefile("rb")
+        self.write_lock = threading.Lock()
+        self.requests = {}
+        self.requests_lock = threading.Lock()
+
+    def send(self, message):
+        data = json.dumps(message).encode("utf-8")
+        with self.write_lock:
+            try:
+                self.sock.sendall(struct.pack(">I", len(data)) + data)
+            except OSError:
+                # the gateway went away, the request is canceled below
+                pass
+
+    def receive(self):
+        header = self.reader.read(4)
+        if len(header) < 4:
+            return None
+        (size,) = struct.unpack(">I", header)
+        data = self.reader.read(size)
+        if len(data) < size:
+            return None
+        return json.loads(data)
+
+    def finish(self, request):
+        with self.requests_lock:
+            self.requests.pop(request.id, None)
+
+    def serve(self):
+        hello = self.receive()
+        if hello is None or hello.get("type") != "hello":
+            return
+        if hello.get("version") != PROTOCOL_VERSION:
+            self.send({"type": "error", "error": "unsupported protocol version %s, the worker implements version %d" % (hello.get("version"), PROTOCOL_VERSION)})
+            return
+        self.send({"type": "hello", "version": PROTOCOL_VERSION})
+
+        while True:
+            message = self.receive()
+            if message is None:
+                break
+            kind = message.get("type")
+            if kind == "ping":
+                self.send({"type": "pong", "id": message.get("id")})
+            elif kind == "cancel":
+                with self.requests_lock:
+                    request = self.requests.get(message.get("id"))
+                if request is not None:
+                    request.cancelled.set()
+            elif kind == "request":
+                request = Request(self, message)
+                with self.requests_lock:
+                    self.requests[request.id] = request
+                threading.Thread(target=handle, args=(request,), daemon=True).start()
+
+        # cancel the requests of a gateway that disconnected
+        with self.requests_lock:
+            for request in self.requests.values():
+                request.cancelled.set()
+
+
+def accept(sock):
+    try:
+        Connection(sock).serve()
+    finally:
+        sock.close()
+
+
+listener = socket.create_server((host, port))
+print("Running on %s, port %s, filename %s" % (host, port, filename))
+while True:
+    conn, _ = listener.accept()
+    conn.setsockopt(socket.IPPROTO_TCP, socket.TCP_NODELAY, 1)
+    threading.Thread(target=accept, args=(conn,), daemon=True).start()
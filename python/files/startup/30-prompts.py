@@ -0,0 +1,49 @@
+def load_prompt(ref, directory=None):
+    "load a prompt template from the prompts/ directory"
+
+    import os
+    import re
+    import yaml  # type: ignore
+    from langchain.prompts import PromptTemplate  # type: ignore
+
+    if directory is None:
+        directory = os.path.join(os.getcwd(), "prompts")
+
+    name, _, version = ref.partition("@")
+    version = int(version.lstrip("v")) if version else None
+
+    candidates = []
+    for root, _, files in os.walk(directory):
+        for filename in files:
+            stem, ext = os.path.splitext(filename)
+            if ext.lower() not in (".prompt", ".md", ".txt"):
+                continue
+
+            with open(os.path.join(root, filename), "r", encoding="utf-8-sig") as f:
+                text = f.read().replace("\r\n", "\n")
+
+            file_name, _, file_version = stem.partition("@")
+            meta = {}
+            if text.startswith("---\n"):
+                end = text.find("\n---", 4)
+                if end != -1:
+                    meta = yaml.safe_load(text[4:end]) or {}
+                    text = text[end + 4:]
+                    text = text[text.find("\n") + 1:] if "\n" in text else ""
+
+            prompt_name = meta.get("name", file_name)
+            prompt_version = meta.get("version") or (int(file_version.lstrip("v")) if file_version else 1)
+            if prompt_name == name:
+                candidates.append((int(prompt_version), text, meta))
+
+    if version is not None:
+        candidates = [c for c in candidates if c[0] == version]
+    if not candidates:
+        raise KeyError("Prompt %s not found in %s" % (ref, directory))
+
+    _, template, meta = max(candidates, key=lambda c: c[0])
+    variables = meta.get("variables")
+    if variables is None:
+        variables = sorted(set(re.findall(r"(?<!\{)\{\s*([^{}\s]+)\s*\}(?!\})", template)))
+
+    return PromptTemplate(template=template, input_variables=variables)
@@ -0,0 +1,132 @@
+def cached_embeddings(embeddings, path=None):
+    "cache the embeddings of texts by their content in the embeddings cache of the project"
+
+    import hashlib
+    import os
+    import sqlite3
+    import struct
+    import sys
+    import threading
+
+    if path is None:
+        path = os.environ.get("LANGFORGE_EMBEDDINGS_CACHE")
+    if not path or getattr(embeddings, "langforge_cache", None):
+        return embeddings
+
+    try:
+        from langchain.embeddings.base import Embeddings  # type: ignore
+    except ImportError:
+        Embeddings = object
+
+    # vectors of different models, or of another model version, must not mix
+    model = "%s.%s" % (type(embeddings).__module__, type(embeddings).__name__)
+    for attribute in ("model", "model_name", "model_id", "deployment"):
+        value = getattr(embeddings, attribute, None)
+        if isinstance(value, str) and value:
+            model += ":" + value
+            break
+
+    # SQLite allows 999 parameters per statement in older versions
+    batch_size = 500
+
+    class CachedEmbeddings(Embeddings):
+        langforge_cache = path
+
+        def __init__(self):
+            self.embeddings = embeddings
+            self.model = model
+            self.lock = threading.Lock()
+            self.connection = None
+            self.failed = False
+
+        def __getattr__(self, name):
+            if name == "embeddings":
+                raise AttributeError(name)
+            return getattr(self.embeddings, name)
+
+        def _connect(self):
+            if self.connection is None:
+                directory = os.path.dirname(path)
+                if directory:
+                    os.makedirs(directory, exist_ok=True)
+                connection = sqlite3.connect(path, timeout=30, check_same_thread=False)
+                # the worker reads while an ingest script writes
+                connection.execute("PRAGMA journal_mode=WAL")
+                connection.execute(
+                    "CREATE TABLE IF NOT EXISTS embeddings ("
+                    "model TEXT NOT NULL, kind TEXT NOT NULL, hash TEXT NOT NULL, vector BLOB NOT NULL, "
+                    "created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (model, kind, hash))"
+                )
+                connection.commit()
+                self.connection = connection
+            return self.connection
+
+        def _disable(self, error):
+            # a broken cache costs money, but must not break ingestion
+            if not self.failed:
+                print("The embeddings cache %s is not used: %s" % (path, error), file=sys.stderr)
+            self.failed = True
+
+        def _lookup(self, kind, hashes):
+            found = {}
+            if self.failed:
+                return found
+            try:
+                with self.lock:
+                    connection = self._connect()
+                    for i in range(0, len(hashes), batch_size):
+                        batch = hashes[i:i + batch_size]
+                        rows = connection.execute(
+                            "SELECT hash, vector FROM embeddings WHERE model = ? AND kind = ? AND hash IN (%s)"
+                            % ",".join("?" * len(batch)),
+                            [self.model, kind] + batch,
+                        )
+                        for hash, vector in rows:
+                            found[hash] = list(struct.unpack("<%dd" % (len(vector) // 8), vector))
+            except sqlite3.Error as error:
+                self._disable(error)
+            return found
+
+        def _store(self, kind, entries):
+            if self.failed:
+                return
+            try:
+                with self.lock:
+                    connection = self._connect()
+                    with connection:
+                        connection.executemany(
+                            "INSERT OR REPLACE INTO embeddings (model, kind, hash, vector) VALUES (?, ?, ?, ?)",
+                            [
+                                (self.model, kind, hash, struct.pack("<%dd" % len(vector), *vector))
+                                for hash, vector in entries
+                            ],
+                        )
+            except sqlite3.Error as error:
+                self._disable(error)
+
+        def embed_documents(self, texts):
+            hashes = [hashlib.sha256(text.encode("utf-8")).hexdigest() for text in texts]
+            found = self._lookup("document", list(set(hashes)))
+
+            missing = {}
+            for hash, text in zip(hashes, texts):
+                if hash not in found:
+                    missing[hash] = text
+            if missing:
+                vectors = self.embeddings.embed_documents(list(missing.values()))
+                entries = list(zip(missing.keys(), vectors))
+                self._store("document", entries)
+                found.update(entries)
+            return [list(found[hash]) for hash in hashes]
+
+        def embed_query(self, text):
+            # some models embed queries unlike documents of the same text
+            hash = hashlib.sha256(text.encode("utf-8")).hexdigest()
+            found = self._lookup("query", [hash])
+            if hash in found:
+                return found[hash]
+            vector = self.embeddings.embed_query(text)
+            self._store("query", [(hash, vector)])
+            return vector
+
+    return CachedEmbeddings()
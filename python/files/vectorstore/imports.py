@@ -0,0 +1,16 @@
+import importlib
+import json
+import sys
+
+# Imports the modules given as arguments and reports those that fail to
+# import as a JSON object of their errors, e.g. a package whose native
+# library is missing.
+
+errors = {}
+for module in sys.argv[1:]:
+    try:
+        importlib.import_module(module)
+    except Exception as error:
+        errors[module] = "%s: %s" % (type(error).__name__, error)
+
+print(json.dumps(errors))
@@ -0,0 +1,88 @@
+import json
+import sys
+import tempfile
+
+from langchain.text_splitter import RecursiveCharacterTextSplitter  # type: ignore
+
+# Ingests the documents of the sources of the ingestion section of
+# langforge.yaml: loads each changed document, splits it into chunks, embeds
+# the chunks and writes them to the vector store in place of the chunks of the
+# previous run. Chunks have the ids "<source>:<document>#<n>", so the chunks
+# that a document had before are known from their number. Progress is
+# reported on stdout as JSON lines, one per document. The documents of sources
+# in object stores are downloaded into a temporary directory to be loaded.
+# A document that no loader of its type can load is reported with the error
+# of each loader, and the other documents are ingested.
+
+with open(sys.argv[1], encoding="utf-8") as f:
+    spec = json.load(f)
+
+
+def report(**fields):
+    print(json.dumps(fields), flush=True)
+
+
+def chunk_id(source, rel, n):
+    return "%s:%s#%d" % (source, rel, n)
+
+
+def ingest(source, document, splitter, store, embeddings, objects):
+    try:
+        if objects is None:
+            documents = load_documents(source, document)
+        else:
+            with tempfile.TemporaryDirectory() as directory:
+                path = download_object(objects, document["key"], directory)
+                documents = load_documents(source, dict(document, path=path))
+    except LoadError:
+        raise
+    except Exception as error:
+        raise LoadError("%s: %s" % (type(error).__name__, error))
+    chunks = splitter.split_documents(documents)
+    batch_size = spec["batchSize"]
+    for i in range(0, len(chunks), batch_size):
+        batch = chunks[i:i + batch_size]
+        vectors = embeddings.embed_documents([chunk.page_content for chunk in batch])
+        store.write(
+            [
+                (chunk_id(source["name"], document["rel"], i + j), vector, chunk.page_content, chunk.metadata)
+                for j, (chunk, vector) in enumerate(zip(batch, vectors))
+            ]
+        )
+    # the chunks of the previous version that the new one does not overwrite
+    stale = [chunk_id(source["name"], document["rel"], n) for n in range(len(chunks), document["previous"])]
+    if stale:
+        store.delete(stale)
+    return len(chunks)
+
+
+store = STORES[spec["store"]["type"]](spec["store"], spec["collection"], spec["batchSize"])
+embeddings_spec = spec["embeddings"]
+embeddings = load_class(embeddings_spec["model"], EMBEDDINGS)(**embeddings_spec["options"])
+if spec["embeddingsCache"]:
+    embeddings = cached_embeddings(embeddings, spec["embeddingsCache"])
+
+for source in spec["sources"]:
+    splitter = RecursiveCharacterTextSplitter(chunk_size=source["chunkSize"], chunk_overlap=source["chunkOverlap"])
+    objects = None
+    objects_error = None
+    if source.get("location") and source["documents"]:
+        try:
+            objects = object_store(source["location"], source["credentials"])
+        except ImportError as error:
+            objects_error = "cannot access the objects of %s: %s" % (source["name"], error)
+    for document in source["deleted"]:
+        ids = [chunk_id(source["name"], document["rel"], n) for n in range(document["previous"])]
+        if ids:
+            store.delete(ids)
+        report(source=source["name"], document=document["rel"], deleted=True)
+    for document in source["documents"]:
+        if objects_error:
+            report(source=source["name"], document=document["rel"], error=objects_error)
+            continue
+        try:
+            chunks = ingest(source, document, splitter, store, embeddings, objects)
+        except LoadError as error:
+            report(source=source["name"], document=document["rel"], error=str(error))
+            continue
+        report(source=source["name"], document=document["rel"], hash=document["hash"], chunks=chunks)
@@ -0,0 +1,16 @@
+import json
+import sys
+
+# Lists the objects of an ingestion source in an object store as JSON lines
+# with their keys and ETags. The spec, which holds the credentials of the
+# source, is read from the file at argv[1] rather than the command line.
+
+with open(sys.argv[1], encoding="utf-8") as f:
+    spec = json.load(f)
+
+objects = object_store(spec["location"], spec["credentials"])
+for key, etag in objects.list(spec["location"]["prefix"]):
+    # directory markers of the consoles of the stores
+    if key.endswith("/"):
+        continue
+    print(json.dumps({"key": key, "etag": etag}), flush=True)
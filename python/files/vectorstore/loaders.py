@@ -0,0 +1,193 @@
+import importlib
+import json
+import os
+import pathlib
+import subprocess
+import tempfile
+
+# The embeddings and document loaders of the ingestion pipeline, shared by the
+# scripts that ingest documents and that benchmark chunking. The scripts load
+# the spec, which has the path of LibreOffice, before they load documents.
+
+EMBEDDINGS = {
+    "openai": ("langchain.embeddings", "OpenAIEmbeddings"),
+    "azure-openai": ("langchain.embeddings", "AzureOpenAIEmbeddings"),
+    "huggingface": ("langchain.embeddings", "HuggingFaceEmbeddings"),
+    "ollama": ("langchain.embeddings", "OllamaEmbeddings"),
+    "cohere": ("langchain.embeddings", "CohereEmbeddings"),
+    "gemini": ("langchain_google_genai", "GoogleGenerativeAIEmbeddings"),
+}
+
+
+
+def load_pptx(path):
+    from langchain.schema import Document  # type: ignore
+    from pptx import Presentation  # type: ignore
+
+    documents = []
+    for number, slide in enumerate(Presentation(path).slides, 1):
+        texts = [shape.text_frame.text for shape in slide.shapes if shape.has_text_frame and shape.text_frame.text.strip()]
+        if texts:
+            documents.append(Document(page_content="\n".join(texts), metadata={"page": number}))
+    return documents
+
+
+def load_xlsx(path):
+    from langchain.schema import Document  # type: ignore
+    from openpyxl import load_workbook  # type: ignore
+
+    documents = []
+    workbook = load_workbook(path, read_only=True, data_only=True)
+    try:
+        for sheet in workbook.worksheets:
+            rows = ["\t".join("" if value is None else str(value) for value in row) for row in sheet.iter_rows(values_only=True)]
+            rows = [row for row in rows if row.strip()]
+            if rows:
+                documents.append(Document(page_content="\n".join(rows), metadata={"sheet": sheet.title}))
+    finally:
+        workbook.close()
+    return documents
+
+
+# the loaders of each type of document in the order they are tried: when one
+# lacks its package or fails on a document, the next one loads it
+LOADERS = {
+    "text": [("langchain.document_loaders", "TextLoader")],
+    "markdown": [("langchain.document_loaders", "TextLoader")],
+    "pdf": [
+        ("langchain.document_loaders", "PyPDFLoader"),
+        ("langchain.document_loaders", "PDFMinerLoader"),
+        ("langchain.document_loaders", "UnstructuredPDFLoader"),
+    ],
+    "html": [("langchain.document_loaders", "BSHTMLLoader"), ("langchain.document_loaders", "UnstructuredHTMLLoader")],
+    "csv": [("langchain.document_loaders", "CSVLoader")],
+    "docx": [("langchain.document_loaders", "Docx2txtLoader"), ("langchain.document_loaders", "UnstructuredWordDocumentLoader")],
+    "pptx": [load_pptx, ("langchain.document_loaders", "UnstructuredPowerPointLoader")],
+    "xlsx": [load_xlsx, ("langchain.document_loaders", "UnstructuredExcelLoader")],
+}
+
+EXTENSIONS = {
+    ".pdf": "pdf",
+    ".html": "html",
+    ".htm": "html",
+    ".csv": "csv",
+    ".md": "markdown",
+    ".markdown": "markdown",
+    ".docx": "docx",
+    ".doc": "docx",
+    ".odt": "docx",
+    ".rtf": "docx",
+    ".pptx": "pptx",
+    ".ppt": "pptx",
+    ".odp": "pptx",
+    ".xlsx": "xlsx",
+    ".xls": "xlsx",
+    ".ods": "xlsx",
+}
+
+# the formats that LibreOffice converts to those that the loaders read
+CONVERSIONS = {
+    ".doc": "docx",
+    ".odt": "docx",
+    ".rtf": "docx",
+    ".ppt": "pptx",
+    ".odp": "pptx",
+    ".xls": "xlsx",
+    ".ods": "xlsx",
+}
+
+
+class LoadError(Exception):
+    pass
+
+
+def load_class(name, known):
+    # a known name or "module:Class"
+    module, _, attribute = name.partition(":")
+    if name in known:
+        module, attribute = known[name]
+    elif not attribute:
+        raise ValueError("unknown class %s, use one of %s or module:Class" % (name, ", ".join(sorted(known))))
+    return getattr(importlib.import_module(module), attribute)
+
+
+def load_documents(source, document):
+    path = document["path"]
+    extension = os.path.splitext(path)[1].lower()
+    loader = source["loader"]
+    if loader == "auto":
+        loader = EXTENSIONS.get(extension, "text")
+    with tempfile.TemporaryDirectory() as directory:
+        if extension in CONVERSIONS and loader == EXTENSIONS[extension]:
+            path = convert(path, CONVERSIONS[extension], directory)
+        documents = load_file(loader, path)
+
+    metadata = expand_metadata(source["metadata"], document["rel"])
+    for doc in documents:
+        if source["frontMatter"]:
+            doc.page_content, front_matter = split_front_matter(doc.page_content)
+            doc.metadata.update(front_matter)
+        doc.metadata.update(metadata)
+        doc.metadata["source"] = document["rel"]
+        # e.g. dates of front matter, which the stores cannot keep
+        doc.metadata = json.loads(json.dumps(doc.metadata, default=str))
+    return documents
+
+
+def load_file(loader, path):
+    if loader not in LOADERS:
+        return load_class(loader, {})(path).load()
+    errors = []
+    for candidate in LOADERS[loader]:
+        try:
+            if callable(candidate):
+                return candidate(path)
+            cls = getattr(importlib.import_module(candidate[0]), candidate[1])
+            if loader in ("text", "markdown"):
+                return cls(path, encoding="utf-8").load()
+            return cls(path).load()
+        except Exception as error:
+            name = candidate.__name__ if callable(candidate) else candidate[1]
+            errors.append("%s: %s: %s" % (name, type(error).__name__, error))
+    raise LoadError("; ".join(errors))
+
+
+def convert(path, extension, directory):
+    libreoffice = spec["libreoffice"]
+    if not libreoffice:
+        raise LoadError("%s documents are converted with LibreOffice, which is not installed" % os.path.splitext(path)[1])
+    # a profile of its own, since LibreOffice does not convert while another
+    # instance uses the profile of the user
+    profile = pathlib.Path(directory, "profile").as_uri()
+    result = subprocess.run(
+        [libreoffice, "-env:UserInstallation=" + profile, "--headless", "--convert-to", extension, "--outdir", directory, path],
+        capture_output=True,
+        text=True,
+        timeout=300,
+    )
+    converted = os.path.join(directory, os.path.splitext(os.path.basename(path))[0] + "." + extension)
+    if result.returncode != 0 or not os.path.exists(converted):
+        raise LoadError("LibreOffice failed to convert the document: %s" % (result.stderr.strip() or result.stdout.strip()))
+    return converted
+
+
+def expand_metadata(metadata, rel):
+    directory, name = os.path.split(rel)
+    stem, ext = os.path.splitext(name)
+    fields = {"path": rel, "dir": directory, "name": name, "stem": stem, "ext": ext.lstrip(".")}
+    return {key: value.format(**fields) for key, value in metadata.items()}
+
+
+def split_front_matter(text):
+    import yaml  # type: ignore
+
+    if not text.startswith("---\n"):
+        return text, {}
+    end = text.find("\n---", 4)
+    if end == -1:
+        return text, {}
+    meta = yaml.safe_load(text[4:end]) or {}
+    if not isinstance(meta, dict):
+        return text, {}
+    rest = text[end + 4:]
+    return rest[rest.find("\n") + 1:] if "\n" in rest else "", meta
@@ -0,0 +1,26 @@
+import json
+import sys
+
+# Copies the records of a collection from one vector store to another. Records
+# are read and written in the layout of the LangChain integration of each
+# store, so that chains read the migrated collection like an ingested one.
+# Progress is reported on stdout as JSON lines.
+
+spec = json.loads(sys.argv[1])
+collection = spec["collection"]
+batch_size = spec["batchSize"]
+
+
+def report(**fields):
+    print(json.dumps(fields), flush=True)
+
+
+source = STORES[spec["source"]["type"]](spec["source"], collection, batch_size)
+target = STORES[spec["target"]["type"]](spec["target"], collection, batch_size)
+
+report(total=source.count())
+migrated = 0
+for records in source.read():
+    target.write(records)
+    migrated += len(records)
+    report(migrated=migrated)
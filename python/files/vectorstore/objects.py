@@ -0,0 +1,96 @@
+import os
+
+# Clients of the object stores that ingestion sources can be in. Each lists the
+# objects under a prefix with their ETags, which change whenever the contents
+# of an object do, and downloads objects. The credentials of a source are
+# passed to the client; without them it finds its credentials as usual, e.g.
+# in the AWS profile or the application default credentials of Google Cloud.
+
+
+class S3Objects:
+    def __init__(self, location, credentials):
+        import boto3  # type: ignore
+
+        # e.g. aws_access_key_id, aws_secret_access_key, region_name and
+        # endpoint_url for S3-compatible stores
+        self.client = boto3.client("s3", **credentials)
+        self.bucket = location["bucket"]
+
+    def list(self, prefix):
+        paginator = self.client.get_paginator("list_objects_v2")
+        for page in paginator.paginate(Bucket=self.bucket, Prefix=prefix):
+            for obj in page.get("Contents", []):
+                yield obj["Key"], obj["ETag"].strip('"')
+
+    def download(self, key, path):
+        self.client.download_file(self.bucket, key, path)
+
+
+class GCSObjects:
+    def __init__(self, location, credentials):
+        from google.cloud import storage  # type: ignore
+
+        options = dict(credentials)
+        # the key file of a service account, or project
+        key_file = options.pop("credentials_file", None)
+        if key_file:
+            client = storage.Client.from_service_account_json(key_file, **options)
+        else:
+            client = storage.Client(**options)
+        self.client = client
+        self.bucket = client.bucket(location["bucket"])
+
+    def list(self, prefix):
+        for blob in self.client.list_blobs(self.bucket, prefix=prefix):
+            yield blob.name, blob.etag
+
+    def download(self, key, path):
+        self.bucket.blob(key).download_to_filename(path)
+
+
+class AzureObjects:
+    def __init__(self, location, credentials):
+        from azure.storage.blob import BlobServiceClient  # type: ignore
+
+        options = dict(credentials)
+        connection_string = options.pop("connection_string", None)
+        if connection_string:
+            service = BlobServiceClient.from_connection_string(connection_string, **options)
+        else:
+            credential = options.pop("account_key", None) or options.pop("sas_token", None)
+            if credential is None:
+                try:
+                    from azure.identity import DefaultAzureCredential  # type: ignore
+
+                    credential = DefaultAzureCredential()
+                except ImportError:
+                    pass
+            account_url = options.pop("account_url", "https://%s.blob.core.windows.net" % location["account"])
+            service = BlobServiceClient(account_url, credential=credential, **options)
+        self.container = service.get_container_client(location["bucket"])
+
+    def list(self, prefix):
+        for blob in self.container.list_blobs(name_starts_with=prefix or None):
+            yield blob.name, blob.etag.strip('"')
+
+    def download(self, key, path):
+        with open(path, "wb") as f:
+            self.container.download_blob(key).readinto(f)
+
+
+OBJECT_STORES = {
+    "s3": S3Objects,
+    "gs": GCSObjects,
+    "azure": AzureObjects,
+}
+
+
+def object_store(location, credentials):
+    return OBJECT_STORES[location["scheme"]](location, credentials or {})
+
+
+def download_object(objects, key, directory):
+    # the file keeps the name of the object, whose extension selects the loader
+    path = os.path.join(directory, os.path.basename(key) or "object")
+    objects.download(key, path)
+    return path
@@ -0,0 +1,26 @@
+import json
+import sys
+
+store_type = sys.argv[1]
+path = sys.argv[2]
+
+collections = []
+
+if store_type == "chroma":
+    import chromadb  # type: ignore
+
+    client = chromadb.PersistentClient(path=path)
+    for collection in client.list_collections():
+        name = collection if isinstance(collection, str) else collection.name
+        collections.append({"name": name, "count": client.get_collection(name).count()})
+elif store_type == "qdrant":
+    from qdrant_client import QdrantClient  # type: ignore
+
+    client = QdrantClient(path=path)
+    for collection in client.get_collections().collections:
+        count = client.count(collection_name=collection.name, exact=True).count
+        collections.append({"name": collection.name, "count": count})
+else:
+    raise ValueError("unknown vector store type %s" % store_type)
+
+print(json.dumps(collections))
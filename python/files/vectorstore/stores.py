@@ -0,0 +1,330 @@
+import json
+import uuid
+
+# Reads and writes the records of a collection of a vector store in the layout
+# of the LangChain integration of the store, so that chains read them like
+# documents ingested with LangChain. A record is a tuple of id, embedding,
+# document and metadata.
+
+
+def chroma_client(store):
+    import chromadb  # type: ignore
+
+    if store["mode"] == "embedded":
+        return chromadb.PersistentClient(path=store["path"])
+    from urllib.parse import urlparse
+
+    url = urlparse(store["url"])
+    return chromadb.HttpClient(
+        host=url.hostname,
+        port=url.port or (443 if url.scheme == "https" else 8000),
+        ssl=url.scheme == "https",
+    )
+
+
+def qdrant_client(store):
+    from qdrant_client import QdrantClient  # type: ignore
+
+    if store["mode"] == "embedded":
+        return QdrantClient(path=store["path"])
+    return QdrantClient(url=store["url"])
+
+
+def postgres_connection(store):
+    try:
+        import psycopg  # type: ignore
+    except ImportError:
+        import psycopg2 as psycopg  # type: ignore
+
+    url = store["url"]
+    # SQLAlchemy URLs of langchain, e.g. postgresql+psycopg://
+    if url.startswith("postgresql+") or url.startswith("postgres+"):
+        url = "postgresql://" + url.split("://", 1)[1]
+    return psycopg.connect(url)
+
+
+def scalar_metadata(metadata):
+    # chroma only stores strings, numbers and booleans
+    result = {}
+    for key, value in (metadata or {}).items():
+        if value is None:
+            continue
+        if isinstance(value, (str, int, float, bool)):
+            result[key] = value
+        else:
+            result[key] = json.dumps(value)
+    return result or None
+
+
+def point_id(record_id):
+    # qdrant only accepts unsigned integers and UUIDs as ids
+    if isinstance(record_id, int) and record_id >= 0:
+        return record_id
+    try:
+        return str(uuid.UUID(str(record_id)))
+    except ValueError:
+        return str(uuid.uuid5(uuid.NAMESPACE_URL, str(record_id)))
+
+
+class ChromaStore:
+    def __init__(self, store, collection, batch_size):
+        self.client = chroma_client(store)
+        self.name = collection
+        self.batch_size = batch_size
+        self.collection = None
+
+    def count(self):
+        self.collection = self.client.get_collection(self.name)
+        return self.collection.count()
+
+    def read(self):
+        offset = 0
+        while True:
+            result = self.collection.get(
+                include=["embeddings", "documents", "metadatas"],
+                limit=self.batch_size,
+                offset=offset,
+            )
+            ids = result["ids"]
+            if not ids:
+                return
+            yield [
+                (
+                    ids[i],
+                    [float(x) for x in result["embeddings"][i]],
+                    result["documents"][i],
+                    result["metadatas"][i],
+                )
+                for i in range(len(ids))
+            ]
+            offset += len(ids)
+
+    def write(self, records):
+        if self.collection is None:
+            self.collection = self.client.get_or_create_collection(self.name)
+        self.collection.upsert(
+            ids=[str(r[0]) for r in records],
+            embeddings=[r[1] for r in records],
+            documents=[r[2] or "" for r in records],
+            metadatas=[scalar_metadata(r[3]) for r in records],
+        )
+
+    def delete(self, ids):
+        if self.collection is None:
+            self.collection = self.client.get_or_create_collection(self.name)
+        self.collection.delete(ids=[str(i) for i in ids])
+
+
+class QdrantStore:
+    def __init__(self, store, collection, batch_size):
+        self.client = qdrant_client(store)
+        self.name = collection
+        self.batch_size = batch_size
+        self.created = False
+
+    def count(self):
+        return self.client.count(collection_name=self.name, exact=True).count
+
+    def read(self):
+        offset = None
+        while True:
+            points, offset = self.client.scroll(
+                collection_name=self.name,
+                limit=self.batch_size,
+                offset=offset,
+                with_payload=True,
+                with_vectors=True,
+            )
+            records = []
+            for point in points:
+                vector = point.vector
+                if isinstance(vector, dict):
+                    # named vectors, langchain uses the unnamed one by default
+                    vector = vector.get("", next(iter(vector.values())))
+                payload = point.payload or {}
+                records.append(
+                    (
+                        point.id,
+                        [float(x) for x in vector],
+                        payload.get("page_content"),
+                        payload.get("metadata"),
+                    )
+                )
+            if records:
+                yield records
+            if offset is None:
+                return
+
+    def write(self, records):
+        from qdrant_client import models  # type: ignore
+
+        if not self.created:
+            if not self.client.collection_exists(self.name):
+                self.client.create_collection(
+                    collection_name=self.name,
+                    vectors_config=models.VectorParams(
+                        size=len(records[0][1]), distance=models.Distance.COSINE
+                    ),
+                )
+            self.created = True
+        self.client.upsert(
+            collection_name=self.name,
+            points=[
+                models.PointStruct(
+                    id=point_id(r[0]),
+                    vector=r[1],
+                    payload={"page_content": r[2], "metadata": r[3] or {}},
+                )
+                for r in records
+            ],
+        )
+
+    def delete(self, ids):
+        from qdrant_client import models  # type: ignore
+
+        if not self.client.collection_exists(self.name):
+            return
+        self.client.delete(
+            collection_name=self.name,
+            points_selector=models.PointIdsList(points=[point_id(i) for i in ids]),
+        )
+
+
+class PgvectorStore:
+    def __init__(self, store, collection, batch_size):
+        self.connection = postgres_connection(store)
+        self.name = collection
+        self.batch_size = batch_size
+        self.collection_id = None
+
+    def columns(self):
+        with self.connection.cursor() as cursor:
+            cursor.execute(
+                "SELECT column_name FROM information_schema.columns"
+                " WHERE table_name = 'langchain_pg_embedding'"
+            )
+            return {row[0] for row in cursor.fetchall()}
+
+    def find_collection(self):
+        with self.connection.cursor() as cursor:
+            cursor.execute(
+                "SELECT uuid FROM langchain_pg_collection WHERE name = %s",
+                (self.name,),
+            )
+            row = cursor.fetchone()
+            return row[0] if row else None
+
+    def count(self):
+        self.collection_id = self.find_collection()
+        if self.collection_id is None:
+            raise ValueError("collection %s does not exist" % self.name)
+        with self.connection.cursor() as cursor:
+            cursor.execute(
+                "SELECT count(*) FROM langchain_pg_embedding WHERE collection_id = %s",
+                (self.collection_id,),
+            )
+            return cursor.fetchone()[0]
+
+    def read(self):
+        # langchain_community keeps the ids of documents in custom_id,
+        # langchain_postgres in id
+        key = "custom_id" if "custom_id" in self.columns() else "id"
+        order = "uuid" if key == "custom_id" else "id"
+        offset = 0
+        while True:
+            with self.connection.cursor() as cursor:
+                cursor.execute(
+                    "SELECT %s, embedding::text, document, cmetadata"
+                    " FROM langchain_pg_embedding WHERE collection_id = %%s"
+                    " ORDER BY %s LIMIT %%s OFFSET %%s" % (key, order),
+                    (self.collection_id, self.batch_size, offset),
+                )
+                rows = cursor.fetchall()
+            if not rows:
+                return
+            yield [
+                (
+                    row[0],
+                    json.loads(row[1]),
+                    row[2],
+                    json.loads(row[3]) if isinstance(row[3], str) else row[3],
+                )
+                for row in rows
+            ]
+            offset += len(rows)
+
+    def prepare(self):
+        with self.connection.cursor() as cursor:
+            cursor.execute("CREATE EXTENSION IF NOT EXISTS vector")
+            cursor.execute(
+                "CREATE TABLE IF NOT EXISTS langchain_pg_collection"
+                " (uuid uuid PRIMARY KEY, name varchar UNIQUE NOT NULL, cmetadata json)"
+            )
+            cursor.execute(
+                "CREATE TABLE IF NOT EXISTS langchain_pg_embedding"
+                " (id varchar PRIMARY KEY,"
+                " collection_id uuid REFERENCES langchain_pg_collection (uuid) ON DELETE CASCADE,"
+                " embedding vector, document varchar, cmetadata jsonb)"
+            )
+        self.collection_id = self.find_collection()
+        if self.collection_id is None:
+            self.collection_id = str(uuid.uuid4())
+            with self.connection.cursor() as cursor:
+                cursor.execute(
+                    "INSERT INTO langchain_pg_collection (uuid, name, cmetadata)"
+                    " VALUES (%s, %s, %s)",
+                    (self.collection_id, self.name, "{}"),
+                )
+        self.legacy = "custom_id" in self.columns()
+
+    def write(self, records):
+        if self.collection_id is None:
+            self.prepare()
+        with self.connection.cursor() as cursor:
+            for record_id, embedding, document, metadata in records:
+                values = (
+                    json.dumps(embedding),
+                    document,
+                    json.dumps(metadata or {}),
+                )
+                if self.legacy:
+                    cursor.execute(
+                        "DELETE FROM langchain_pg_embedding"
+                        " WHERE collection_id = %s AND custom_id = %s",
+                        (self.collection_id, str(record_id)),
+                    )
+                    cursor.execute(
+                        "INSERT INTO langchain_pg_embedding"
+                        " (uuid, collection_id, embedding, document, cmetadata, custom_id)"
+                        " VALUES (%s, %s, %s::vector, %s, %s, %s)",
+                        (str(uuid.uuid4()), self.collection_id) + values + (str(record_id),),
+                    )
+                else:
+                    cursor.execute(
+                        "INSERT INTO langchain_pg_embedding"
+                        " (id, collection_id, embedding, document, cmetadata)"
+                        " VALUES (%s, %s, %s::vector, %s, %s)"
+                        " ON CONFLICT (id) DO UPDATE SET collection_id = EXCLUDED.collection_id,"
+                        " embedding = EXCLUDED.embedding, document = EXCLUDED.document,"
+                        " cmetadata = EXCLUDED.cmetadata",
+                        (str(record_id), self.collection_id) + values,
+                    )
+        self.connection.commit()
+
+    def delete(self, ids):
+        if self.collection_id is None:
+            self.prepare()
+        key = "custom_id" if self.legacy else "id"
+        with self.connection.cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM langchain_pg_embedding WHERE collection_id = %%s AND %s = ANY(%%s)" % key,
+                (self.collection_id, [str(i) for i in ids]),
+            )
+        self.connection.commit()
+
+
+STORES = {
+    "chroma": ChromaStore,
+    "qdrant": QdrantStore,
+    "pgvector": PgvectorStore,
+}
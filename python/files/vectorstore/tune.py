@@ -0,0 +1,100 @@
+import json
+import sys
+
+import numpy  # type: ignore
+
+# Benchmarks chunking configurations for retrieval: loads the documents of the
+# sources once, then for each configuration splits them into chunks, embeds
+# the chunks and retrieves the k chunks most similar to the question of each
+# example. A chunk is relevant if its document is among the sources of the
+# example or, for examples without sources, if it contains the expected
+# answer. The hit rate, the mean reciprocal rank of the first relevant chunk
+# and the recall of the relevant documents are reported on stdout as JSON
+# lines, one per configuration, after the documents that cannot be loaded.
+
+with open(sys.argv[1], encoding="utf-8") as f:
+    spec = json.load(f)
+
+
+def report(**fields):
+    print(json.dumps(fields), flush=True)
+
+
+def splitter(strategy, size, overlap):
+    from langchain import text_splitter  # type: ignore
+
+    if strategy == "recursive":
+        return text_splitter.RecursiveCharacterTextSplitter(chunk_size=size, chunk_overlap=overlap)
+    if strategy == "character":
+        return text_splitter.CharacterTextSplitter(separator="\n\n", chunk_size=size, chunk_overlap=overlap)
+    if strategy == "markdown":
+        return text_splitter.MarkdownTextSplitter(chunk_size=size, chunk_overlap=overlap)
+    if strategy == "token":
+        return text_splitter.TokenTextSplitter(chunk_size=size, chunk_overlap=overlap)
+    raise ValueError("unknown chunking strategy %s" % strategy)
+
+
+def normalize(text):
+    return " ".join(text.split()).lower()
+
+
+def matches(document, sources):
+    return any(document == source or document.endswith("/" + source) for source in sources)
+
+
+def relevant(example, chunk):
+    if example["sources"]:
+        return matches(chunk.metadata["source"], example["sources"])
+    return normalize(example["expected"]) in normalize(chunk.page_content)
+
+
+def unit_vectors(vectors):
+    matrix = numpy.array(vectors, dtype=float)
+    norms = numpy.linalg.norm(matrix, axis=1, keepdims=True)
+    norms[norms == 0] = 1
+    return matrix / norms
+
+
+def evaluate(chunks, vectors, questions, k):
+    hits, reciprocal_ranks, recalls = 0, 0.0, 0.0
+    similarities = questions @ vectors.T
+    for example, row in zip(spec["examples"], similarities):
+        ranked = [chunks[i] for i in numpy.argsort(-row)[:k]]
+        rank = next((n for n, chunk in enumerate(ranked, 1) if relevant(example, chunk)), 0)
+        if rank:
+            hits += 1
+            reciprocal_ranks += 1 / rank
+        if example["sources"]:
+            found = {source for source in example["sources"] if any(matches(chunk.metadata["source"], [source]) for chunk in ranked)}
+            recalls += len(found) / len(example["sources"])
+        elif rank:
+            recalls += 1
+    n = len(spec["examples"])
+    return {"hitRate": hits / n, "mrr": reciprocal_ranks / n, "recall": recalls / n}
+
+
+embeddings_spec = spec["embeddings"]
+embeddings = load_class(embeddings_spec["model"], EMBEDDINGS)(**embeddings_spec["options"])
+if spec["embeddingsCache"]:
+    embeddings = cached_embeddings(embeddings, spec["embeddingsCache"])
+
+documents = []
+for source in spec["sources"]:
+    for document in source["documents"]:
+        try:
+            documents.extend(load_documents(source, document))
+        except Exception as error:
+            report(source=source["name"], document=document["rel"], error=str(error))
+
+questions = unit_vectors([embeddings.embed_query(example["question"]) for example in spec["examples"]])
+for configuration in spec["configurations"]:
+    chunks = splitter(configuration["strategy"], configuration["chunkSize"], configuration["chunkOverlap"]).split_documents(documents)
+    if not chunks:
+        report(configuration=configuration, chunks=0, hitRate=0, mrr=0, recall=0)
+        continue
+    vectors = []
+    batch_size = spec["batchSize"]
+    for i in range(0, len(chunks), batch_size):
+        vectors.extend(embeddings.embed_documents([chunk.page_content for chunk in chunks[i:i + batch_size]]))
+    metrics = evaluate(chunks, unit_vectors(vectors), questions, spec["k"])
+    report(configuration=configuration, chunks=len(chunks), **metrics)
@@ -1,6 +1,7 @@
 package python
 
 import (
+	"langforge/envfile"
 	"langforge/environment"
 	"langforge/system"
 	"path/filepath"
@@ -9,15 +10,25 @@ import (
 type PythonHandler struct {
 	integrations []*environment.Integration
 	dir          string
+	arch         string
 }
 
 func NewPythonHandler(dir string) environment.EnvironmentHandler {
 	return &PythonHandler{
-		integrations: environment.CopyIntegrations(availableIntegrations),
+		integrations: environment.CopyIntegrations(integrations()),
 		dir:          dir,
 	}
 }
 
+// pythonArch returns the architecture of the Python interpreter that packages
+// are installed for.
+func (h *PythonHandler) pythonArch() string {
+	if h.arch == "" {
+		h.arch = Arch()
+	}
+	return h.arch
+}
+
 func (h *PythonHandler) DetermineInstalledIntegrations() error {
 	packages, err := GetInstalledPackages()
 	if err != nil {
@@ -48,7 +59,7 @@ func (h *PythonHandler) DetermineInstalledIntegrations() error {
 				}
 			}
 		} else {
-			for _, packageName := range m.Packages {
+			for _, packageName := range m.PackagesFor(h.pythonArch()) {
 				if !packagesMap[packageName] {
 					m.Installed = false
 					break
@@ -83,39 +94,63 @@ func (h *PythonHandler) ExecuteChanges() error {
 		}
 	}
 
+	arch := h.pythonArch()
+
 	pre := []string{}
 	packages := []string{}
+	noWheels := []string{}
 	post := []string{}
 	uninstallPackages := []string{}
 	removeApiKeys := []string{}
 
 	for _, integration := range install {
 		pre = append(pre, integration.PreInstallCommands...)
-		packages = append(packages, integration.Packages...)
+		packages = append(packages, integration.PackagesFor(arch)...)
+		if !integration.HasWheels(arch) {
+			noWheels = append(noWheels, integration.PackagesFor(arch)...)
+		}
 		post = append(post, integration.PostInstallCommands...)
 	}
 
 	for _, integration := range uninstall {
-		uninstallPackages = append(uninstallPackages, integration.Packages...)
+		uninstallPackages = append(uninstallPackages, integration.PackagesFor(arch)...)
 		removeApiKeys = append(removeApiKeys, integration.ApiKeys...)
 	}
 
-	err = UninstallPackages(uninstallPackages)
+	CheckArchitecture(arch, packages, noWheels)
+
+	// Ctrl-C stops the running command instead of leaving it behind
+	ctx, stop := system.InterruptContext()
+	defer stop()
+
+	err = managePackages(ctx, h.dir, uninstallPackages, "uninstall -y")
 	if err != nil {
 		return err
 	}
 
-	err = system.ExecuteCommands(pre, h.dir)
+	// the commands of integrations run in the project's virtual environment
+	// even if langforge was started outside of it
+	runner := &system.Runner{Dir: h.dir}
+	if venv, err := system.OpenVenv(filepath.Join(h.dir, VirtualEnvName)); err == nil {
+		runner = venv.Runner(h.dir)
+	}
+	if dotEnv, err := envfile.Load(h.dir); err == nil {
+		if masker := envfile.NewMasker(dotEnv); !masker.Empty() {
+			runner.Mask = masker.Mask
+		}
+	}
+
+	err = runner.RunCommands(ctx, pre)
 	if err != nil {
 		return err
 	}
 
-	err = InstallPackages(packages)
+	err = managePackages(ctx, h.dir, packages, "install")
 	if err != nil {
 		return err
 	}
 
-	err = system.ExecuteCommands(post, h.dir)
+	err = runner.RunCommands(ctx, post)
 	if err != nil {
 		return err
 	}
@@ -124,9 +159,12 @@ func (h *PythonHandler) ExecuteChanges() error {
 		integration.Installed = integration.Selected
 	}
 
-	err = WriteRequirementsTxt(filepath.Join(h.dir, "requirements.txt"))
-	if err != nil {
-		panic(err)
+	// the lockfile of poetry, uv or pipenv already records the packages
+	if manager, _ := system.DetectPythonProjectManager(h.dir); manager == "" {
+		err = WriteRequirementsTxt(filepath.Join(h.dir, "requirements.txt"))
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	wasJupyterLabInstalled := false
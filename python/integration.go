@@ -2,22 +2,38 @@ package python
 
 import (
 	"io/fs"
+	"langforge/bundle"
 	"langforge/environment"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
 
-var availableIntegrations []*environment.Integration
+var (
+	integrationsOnce      sync.Once
+	availableIntegrations []*environment.Integration
+)
 
-func init() {
-	// Read the embedded YAML file
-	data, err := fs.ReadFile(embeddedFS, "files/integrations.yaml")
-	if err != nil {
-		panic(err)
-	}
+// integrations returns the integrations of the index of langforge, or of the
+// offline bundle of the user if it has one.
+func integrations() []*environment.Integration {
+	integrationsOnce.Do(func() {
+		data, err := readIntegrationsYaml()
+		if err != nil {
+			panic(err)
+		}
+		err = yaml.Unmarshal(data, &availableIntegrations)
+		if err != nil {
+			panic(err)
+		}
+	})
+	return availableIntegrations
+}
 
-	err = yaml.Unmarshal(data, &availableIntegrations)
+func readIntegrationsYaml() ([]byte, error) {
+	files, err := bundle.FS(bundle.Integrations)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return fs.ReadFile(files, "integrations.yaml")
 }
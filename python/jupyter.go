@@ -2,6 +2,7 @@ package python
 
 import (
 	"io/fs"
+	"langforge/system"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -51,10 +52,17 @@ func InstallLangforgeJupyterExtension(dir string) error {
 		return err
 	}
 
-	cmd := exec.Command("pip", "install", packageFileName.Name())
+	name, args, err := pipCommand("install", packageFileName.Name())
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(name, args...)
 	cmd.Dir = dir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if system.SkipDryRun(cmd) {
+		return nil
+	}
 	err = cmd.Run()
 	if err != nil {
 		return err
@@ -79,6 +87,16 @@ func WriteIPythonStartupScripts(dir string) error {
 		return err
 	}
 
+	err = writeIPythonStartupScript(dir, "30-prompts.py")
+	if err != nil {
+		return err
+	}
+
+	err = writeIPythonStartupScript(dir, "40-embeddings.py")
+	if err != nil {
+		return err
+	}
+
 	err = writeIntegrationsYaml(dir)
 	if err != nil {
 		return err
@@ -116,9 +134,9 @@ func writeIntegrationsYaml(dir string) error {
 	}
 
 	iPythonStartupFile := filepath.Join(iPythonStartupDir, "integrations.yaml")
-	iPythonStartupFileContents, err := fs.ReadFile(embeddedFS, "files/integrations.yaml")
+	iPythonStartupFileContents, err := readIntegrationsYaml()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	err = os.WriteFile(iPythonStartupFile, iPythonStartupFileContents, 0644)
@@ -0,0 +1,99 @@
+package python
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/system"
+	"os"
+	"path/filepath"
+)
+
+// Overlay is the dependency overlay of a project for the hardware of a
+// machine, see project.OverlaysConfig.
+type Overlay struct {
+	// Hardware is system.HardwareGPU or system.HardwareCPU.
+	Hardware string
+	// Description describes the hardware, e.g. the name of the GPU.
+	Description string
+	// Requirements is the requirements file that pip installs together with
+	// requirements.txt, or empty.
+	Requirements string
+	// Extra is the extra or category that the tool of a lockfile installs,
+	// or empty.
+	Extra string
+}
+
+// overlayRequirements returns the default requirements file of the overlay
+// for hardware, e.g. requirements-gpu.txt.
+func overlayRequirements(hardware string) string {
+	return "requirements-" + hardware + ".txt"
+}
+
+// DetectOverlay returns the overlay of the project in dir for the hardware
+// of the machine, see system.DetectHardware, or nil if the project has no
+// overlay for it. The hardware is only detected for projects with overlays.
+func DetectOverlay(dir string) (*Overlay, error) {
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	declared := config.Overlays != project.OverlaysConfig{}
+	for _, hardware := range []string{system.HardwareGPU, system.HardwareCPU} {
+		if fileExists(filepath.Join(dir, overlayRequirements(hardware))) {
+			declared = true
+		}
+	}
+	if !declared {
+		return nil, nil
+	}
+
+	hardware, description, err := system.DetectHardware()
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := projectOverlay(dir, config, hardware)
+	if overlay != nil {
+		overlay.Description = description
+	}
+	return overlay, err
+}
+
+// ProjectOverlay returns the overlay of the project in dir for hardware,
+// system.HardwareGPU or system.HardwareCPU, or nil if it has none.
+func ProjectOverlay(dir string, hardware string) (*Overlay, error) {
+	if hardware != system.HardwareGPU && hardware != system.HardwareCPU {
+		return nil, fmt.Errorf("unknown hardware %q, expected %s or %s", hardware, system.HardwareGPU, system.HardwareCPU)
+	}
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	return projectOverlay(dir, config, hardware)
+}
+
+func projectOverlay(dir string, config *project.Config, hardware string) (*Overlay, error) {
+	declared := config.Overlays.CPU
+	if hardware == system.HardwareGPU {
+		declared = config.Overlays.GPU
+	}
+
+	overlay := &Overlay{Hardware: hardware, Extra: declared.Extra}
+	if declared.Requirements != "" {
+		path := filepath.Join(dir, declared.Requirements)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("the requirements of the %s overlay do not exist: %v", hardware, err)
+		}
+		overlay.Requirements = path
+	} else if path := filepath.Join(dir, overlayRequirements(hardware)); fileExists(path) {
+		overlay.Requirements = path
+	}
+	if overlay.Requirements == "" && overlay.Extra == "" {
+		return nil, nil
+	}
+	return overlay, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
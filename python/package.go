@@ -2,10 +2,12 @@ package python
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"langforge/project"
 	"langforge/system"
-	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -15,17 +17,31 @@ type PythonPackage struct {
 	Version string
 }
 
+// pipCommand returns the command line that runs a pip command in the Python
+// environment of langforge with uv or pip, see system.FindPythonInstaller.
+func pipCommand(args ...string) (string, []string, error) {
+	pythonPath, err := system.FindPython()
+	if err != nil {
+		return "", nil, err
+	}
+	installer, err := system.FindPythonInstaller()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to locate pip: %v", err)
+	}
+	name, args := installer.PipCommand(pythonPath, args...)
+	return name, args, nil
+}
+
 // GetInstalledPackages retrieves a list of currently installed Python packages
 // with their name and version. It returns an error if it fails to locate pip or
 // execute the pip command.
 func GetInstalledPackages() ([]PythonPackage, error) {
-	pipPath, err := system.FindPip()
+	// Build the pip command.
+	name, args, err := pipCommand("list", "--format=freeze")
 	if err != nil {
-		return nil, fmt.Errorf("failed to locate pip: %v", err)
+		return nil, err
 	}
-
-	// Build the pip command.
-	cmd := exec.Command(pipPath, "list", "--format=freeze")
+	cmd := exec.Command(name, args...)
 
 	// Run the command and capture its output.
 	var stdout bytes.Buffer
@@ -56,8 +72,13 @@ func GetInstalledPackages() ([]PythonPackage, error) {
 // managePackages is a helper function to handle common tasks for installing
 // and uninstalling Python packages. It takes a list of packages and an action
 // ("install" or "uninstall") as arguments. It returns an error if it fails to
-// locate the Python interpreter or execute the pip command.
-func managePackages(packages []string, action string) error {
+// locate the Python interpreter or execute the pip command, or if ctx is
+// canceled, which stops pip.
+//
+// The packages of a project in dir with a lockfile are added to or removed
+// from its dependencies with poetry, uv or pipenv instead, see
+// system.FindPythonProjectManager; an empty dir installs with pip.
+func managePackages(ctx context.Context, dir string, packages []string, action string) error {
 	if len(packages) == 0 {
 		return nil
 	}
@@ -72,34 +93,124 @@ func managePackages(packages []string, action string) error {
 		packages = append(packages, pkg)
 	}
 
-	// Find the path to the Python interpreter
-	pythonPath, err := system.FindPython()
+	if dir != "" {
+		manager, err := system.FindPythonProjectManager(dir)
+		if err != nil {
+			return err
+		}
+		if manager != nil {
+			runner := &system.Runner{Dir: dir, Retry: &system.InstallRetry}
+			if action == "install" {
+				return manager.Add(ctx, runner, packages...)
+			}
+			return manager.Remove(ctx, runner, packages...)
+		}
+	}
+
+	// Manage the packages using uv or pip
+	name, args, err := pipCommand(append(strings.Split(action, " "), packages...)...)
 	if err != nil {
 		return err
 	}
-
-	// Manage the packages using pip
-	args := append([]string{"-m", "pip"}, strings.Split(action, " ")...)
-	args = append(args, packages...)
-	args = append(args, "--disable-pip-version-check")
-	cmd := exec.Command(pythonPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
-
-	return err
+	// registries that do not answer fail installs now and then
+	return (&system.Runner{Retry: &system.InstallRetry}).Run(ctx, name, args...)
 }
 
 // InstallPackages installs the specified Python packages. It returns an error
 // if it fails to locate the Python interpreter, execute the pip command or
-// manage packages.
+// manage packages. Ctrl-C stops pip.
 func InstallPackages(packages []string) error {
-	return managePackages(packages, "install")
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	return managePackages(ctx, "", packages, "install")
 }
 
 // UninstallPackages uninstalls the specified Python packages. It returns an error
 // if it fails to locate the Python interpreter, execute the pip command or
-// manage packages.
+// manage packages. Ctrl-C stops pip.
 func UninstallPackages(packages []string) error {
-	return managePackages(packages, "uninstall -y")
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	return managePackages(ctx, "", packages, "uninstall -y")
+}
+
+// AddProjectPackages installs packages that the project in dir depends on,
+// e.g. "openai==1.51.2". In projects with a lockfile of poetry, uv or pipenv
+// they are added to the dependencies and the lockfile with the tool of the
+// project, since the next install of the tool would otherwise remove them
+// again. Ctrl-C stops the install.
+func AddProjectPackages(dir string, packages []string) error {
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	return managePackages(ctx, dir, packages, "install")
+}
+
+// InstallRequirements installs the packages listed in the given requirements
+// file. Ctrl-C stops pip.
+func InstallRequirements(path string) error {
+	ctx, stop := system.InterruptContext()
+	defer stop()
+	return RequirementsStep(path).Run(ctx, &system.Runner{Retry: &system.InstallRetry})
+}
+
+// DependenciesStep returns a step that installs the Python dependencies of
+// the project in dir, e.g. next to its npm dependencies: with poetry, uv or
+// pipenv at the versions of the lockfile if the project has one, otherwise
+// with pip from requirements.txt. The overlay of the hardware of the machine,
+// if not nil, is installed with them, see DetectOverlay. The tools of
+// lockfiles only install the extra of an overlay, not its requirements file.
+// It returns false if the project has neither, and an error if the tool of
+// the lockfile is not installed or the overlay has no extra for it.
+func DependenciesStep(dir string, overlay *Overlay) (system.Step, bool, error) {
+	manager, err := system.FindPythonProjectManager(dir)
+	if err != nil {
+		return system.Step{}, false, err
+	}
+	if manager != nil {
+		if overlay != nil && overlay.Requirements != "" {
+			requirements, _ := filepath.Rel(dir, overlay.Requirements)
+			if overlay.Extra == "" {
+				return system.Step{}, false, fmt.Errorf("the %s overlay installs %s, but %s installs the dependencies of the project from its lockfile; declare the overlay as an extra and set overlays.%s.extra in %s", overlay.Hardware, requirements, manager.Name, overlay.Hardware, project.ConfigFileName)
+			}
+			system.ReportMessage(system.StreamStderr, fmt.Sprintf("Warning: %s installs the extra %s of the %s overlay, %s is not installed.", manager.Name, overlay.Extra, overlay.Hardware, requirements))
+		}
+		if overlay != nil {
+			manager.Extra = overlay.Extra
+		}
+		return system.Step{Name: manager.Name, Run: manager.Install}, true, nil
+	}
+
+	paths := []string{}
+	if requirementsPath := filepath.Join(dir, "requirements.txt"); fileExists(requirementsPath) {
+		paths = append(paths, requirementsPath)
+	}
+	// pip resolves the overlay together with the base requirements, e.g. the
+	// CUDA build of torch of the overlay for the torch of requirements.txt
+	if overlay != nil && overlay.Requirements != "" {
+		paths = append(paths, overlay.Requirements)
+	}
+	if len(paths) == 0 {
+		return system.Step{}, false, nil
+	}
+	return RequirementsStep(paths...), true, nil
+}
+
+// RequirementsStep returns a step named "pip" that installs the packages
+// listed in the given requirements files, e.g. next to the npm dependencies
+// of a project.
+func RequirementsStep(paths ...string) system.Step {
+	return system.Step{
+		Name: "pip",
+		Run: func(ctx context.Context, runner *system.Runner) error {
+			args := []string{"install"}
+			for _, path := range paths {
+				args = append(args, "-r", path)
+			}
+			name, args, err := pipCommand(args...)
+			if err != nil {
+				return err
+			}
+			return runner.Run(ctx, name, args...)
+		},
+	}
 }
@@ -0,0 +1,318 @@
+package python
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"langforge/bundle"
+	"langforge/project"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// PackageSpec describes the Python package generated from a LangForge project.
+type PackageSpec struct {
+	Name         string
+	Module       string
+	Version      string
+	Description  string
+	Dependencies []string
+	Chains       []PackageChain
+}
+
+// PackageChain is a chain that is exposed as an entry point of the generated package.
+type PackageChain struct {
+	Name     string
+	Function string
+	// Notebook is the path of the notebook relative to the project, with
+	// forward slashes, which the package keeps so that notebooks of the same
+	// name in different directories do not collide.
+	Notebook    string
+	Description string
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// pythonKeywords are the keywords of Python 3, which are not valid names of
+// modules and functions.
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"async": true, "await": true, "break": true, "class": true, "continue": true,
+	"def": true, "del": true, "elif": true, "else": true, "except": true,
+	"finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true,
+	"not": true, "or": true, "pass": true, "raise": true, "return": true,
+	"try": true, "while": true, "with": true, "yield": true,
+}
+
+// pythonIdentifier derives a Python identifier from name: characters that
+// are not allowed become underscores, and names that start with a digit or
+// are keywords get an underscore, e.g. _2_step for 2-step and class_ for
+// class. It returns an empty string for an empty name.
+func pythonIdentifier(name string) string {
+	identifier := nonIdentifierChars.ReplaceAllString(name, "_")
+	switch {
+	case identifier == "":
+	case identifier[0] >= '0' && identifier[0] <= '9':
+		identifier = "_" + identifier
+	case pythonKeywords[identifier]:
+		identifier += "_"
+	}
+	return identifier
+}
+
+// docstring escapes text for a Python docstring in triple double quotes.
+func docstring(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	return strings.ReplaceAll(text, `"`, `\"`)
+}
+
+// NewPackageSpec builds the package description for the project in dir from its
+// langforge.yaml configuration. Dependencies default to the contents of the
+// project's requirements.txt if none are configured.
+func NewPackageSpec(dir string, config *project.Config) (*PackageSpec, error) {
+	name := config.Package.Name
+	if name == "" {
+		name = config.Name
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("the package has no name, set package.name or name in %s", project.ConfigFileName)
+	}
+
+	version := config.Package.Version
+	if version == "" {
+		version = config.Version
+	}
+	if version == "" {
+		version = "0.1.0"
+	}
+
+	module := pythonIdentifier(strings.ToLower(name))
+
+	dependencies := config.Package.Dependencies
+	if len(dependencies) == 0 {
+		var err error
+		dependencies, err = readRequirementsTxt(filepath.Join(dir, "requirements.txt"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spec := &PackageSpec{
+		Name:         name,
+		Module:       module,
+		Version:      version,
+		Description:  config.Package.Description,
+		Dependencies: dependencies,
+	}
+
+	functions := map[string]string{}
+	for _, chain := range config.Chains {
+		function := pythonIdentifier(chain.Name)
+		if function == "" {
+			return nil, fmt.Errorf("a chain of %s has no name", project.ConfigFileName)
+		}
+		if other, ok := functions[function]; ok {
+			return nil, fmt.Errorf("the chains %q and %q would both be the function %s of the package, rename one of them", other, chain.Name, function)
+		}
+		functions[function] = chain.Name
+		if chain.Notebook == "" {
+			return nil, fmt.Errorf("chain %q does not declare a notebook", chain.Name)
+		}
+		notebook := filepath.Clean(chain.Notebook)
+		if filepath.IsAbs(notebook) || notebook == ".." || strings.HasPrefix(notebook, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("the notebook of chain %q is not in the project: %s", chain.Name, chain.Notebook)
+		}
+		spec.Chains = append(spec.Chains, PackageChain{
+			Name:        chain.Name,
+			Function:    function,
+			Notebook:    filepath.ToSlash(notebook),
+			Description: chain.Description,
+		})
+	}
+
+	return spec, nil
+}
+
+func readRequirementsTxt(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	requirements := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		requirements = append(requirements, line)
+	}
+
+	return requirements, scanner.Err()
+}
+
+// WritePackageSources generates the sources of the Python package in buildDir:
+// a pyproject.toml with one entry point per chain, a module that loads the chains
+// from their notebooks and a copy of the notebooks themselves.
+func WritePackageSources(dir string, buildDir string, spec *PackageSpec) error {
+	err := os.RemoveAll(buildDir)
+	if err != nil {
+		return err
+	}
+
+	moduleDir := filepath.Join(buildDir, spec.Module)
+	notebookDir := filepath.Join(moduleDir, "notebooks")
+	err = os.MkdirAll(notebookDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = renderTemplate("pyproject.toml.tmpl", filepath.Join(buildDir, "pyproject.toml"), spec)
+	if err != nil {
+		return err
+	}
+
+	err = renderTemplate("chains.py.tmpl", filepath.Join(moduleDir, "chains.py"), spec)
+	if err != nil {
+		return err
+	}
+
+	initPy := []byte("from .chains import CHAINS, load_chain\n")
+	err = os.WriteFile(filepath.Join(moduleDir, "__init__.py"), initPy, 0644)
+	if err != nil {
+		return err
+	}
+
+	copied := make(map[string]bool)
+	for _, chain := range spec.Chains {
+		if copied[chain.Notebook] {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(chain.Notebook)))
+		if err != nil {
+			return fmt.Errorf("failed to read notebook for chain %q: %v", chain.Name, err)
+		}
+		target := filepath.Join(notebookDir, filepath.FromSlash(chain.Notebook))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		err = os.WriteFile(target, contents, 0644)
+		if err != nil {
+			return err
+		}
+		copied[chain.Notebook] = true
+	}
+
+	return nil
+}
+
+func renderTemplate(name string, path string, data any) error {
+	files, err := bundle.FS(bundle.Packages)
+	if err != nil {
+		return err
+	}
+	contents, err := fs.ReadFile(files, name)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(filepath.Base(name)).Funcs(template.FuncMap{"docstring": docstring}).Parse(string(contents))
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// BuildWheel builds a wheel from the package sources in buildDir and places it in distDir.
+// It returns the paths of the wheels in distDir that belong to the package.
+func BuildWheel(spec *PackageSpec, buildDir string, distDir string) ([]string, error) {
+	pythonPath, err := system.FindPython()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(distDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(pythonPath, "-m", "pip", "wheel", "--no-deps", "--wheel-dir", distDir, buildDir, "--disable-pip-version-check")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to build wheel: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(distDir, "*.whl"))
+	if err != nil {
+		return nil, err
+	}
+
+	// wheel file names use the normalized distribution name, e.g. my_app-0.1.0-py3-none-any.whl
+	prefix := strings.ToLower(regexp.MustCompile(`[-_.]+`).ReplaceAllString(spec.Name, "_") + "-" + spec.Version + "-")
+	wheels := []string{}
+	for _, file := range files {
+		if strings.HasPrefix(strings.ToLower(filepath.Base(file)), prefix) {
+			wheels = append(wheels, file)
+		}
+	}
+
+	if len(wheels) == 0 {
+		return nil, fmt.Errorf("no wheel for %s %s found in %s", spec.Name, spec.Version, distDir)
+	}
+
+	return wheels, nil
+}
+
+// PublishWheels uploads the given wheels to a package index using twine. If index
+// is empty, twine's default repository (PyPI) is used. Credentials are read by twine
+// from the TWINE_USERNAME and TWINE_PASSWORD environment variables.
+func PublishWheels(wheels []string, index string) error {
+	if len(wheels) == 0 {
+		return fmt.Errorf("no wheels to publish")
+	}
+
+	err := InstallPackages([]string{"twine"})
+	if err != nil {
+		return err
+	}
+
+	pythonPath, err := system.FindPython()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-m", "twine", "upload", "--non-interactive"}
+	if index != "" {
+		args = append(args, "--repository-url", index)
+	}
+	args = append(args, wheels...)
+
+	cmd := exec.Command(pythonPath, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to publish package: %v", err)
+	}
+
+	return nil
+}
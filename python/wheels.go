@@ -0,0 +1,247 @@
+package python
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/system"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// plainRequirement matches requirements that name a PyPI project, optionally with
+// extras and a version specifier. URLs and VCS references are not checked.
+var plainRequirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*(==\s*([^\s;,]+))?`)
+
+// wheelQueries is the number of packages that PackagesWithoutWheels looks up
+// on PyPI at the same time.
+const wheelQueries = 8
+
+// PackagesWithoutWheels queries PyPI and returns the packages that have no binary
+// wheel for the given operating system and architecture, i.e. that pip would
+// have to build from source. Packages that cannot be checked are skipped.
+func PackagesWithoutWheels(packages []string, goos string, arch string) []string {
+	client := &http.Client{Timeout: 5 * time.Second}
+	lacking := make([]bool, len(packages))
+
+	var unreachable int32
+	var wg sync.WaitGroup
+	queries := make(chan int)
+	for worker := 0; worker < wheelQueries; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queries {
+				// PyPI is not reachable, don't wait for every package
+				if atomic.LoadInt32(&unreachable) != 0 {
+					continue
+				}
+				m := plainRequirement.FindStringSubmatch(strings.TrimSpace(packages[i]))
+				files, err := releaseFiles(client, m[1], m[4])
+				if err != nil {
+					if _, ok := err.(*url.Error); ok {
+						atomic.StoreInt32(&unreachable, 1)
+					}
+					continue
+				}
+				lacking[i] = true
+				for _, file := range files {
+					if wheelSupports(file, goos, arch) {
+						lacking[i] = false
+						break
+					}
+				}
+			}
+		}()
+	}
+	for i, pkg := range packages {
+		if strings.Contains(pkg, "@") || strings.Contains(pkg, "://") {
+			continue
+		}
+		if plainRequirement.MatchString(strings.TrimSpace(pkg)) {
+			queries <- i
+		}
+	}
+	close(queries)
+	wg.Wait()
+
+	missing := []string{}
+	for i, pkg := range packages {
+		if lacking[i] {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing
+}
+
+// releaseFiles returns the file names of a release on PyPI, or of the latest
+// release if version is empty.
+func releaseFiles(client *http.Client, name string, version string) ([]string, error) {
+	endpoint := fmt.Sprintf("https://pypi.org/pypi/%s/json", name)
+	if version != "" {
+		endpoint = fmt.Sprintf("https://pypi.org/pypi/%s/%s/json", name, version)
+	}
+
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+
+	var release struct {
+		URLs []struct {
+			Filename string `json:"filename"`
+		} `json:"urls"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, u := range release.URLs {
+		files = append(files, u.Filename)
+	}
+	return files, nil
+}
+
+// wheelSupports reports whether the wheel file can be installed on the given
+// operating system and architecture based on its platform tag.
+func wheelSupports(filename string, goos string, arch string) bool {
+	if !strings.HasSuffix(filename, ".whl") {
+		return false
+	}
+	tag := strings.TrimSuffix(filename, ".whl")
+	if i := strings.LastIndex(tag, "-"); i >= 0 {
+		tag = tag[i+1:]
+	}
+
+	for _, platform := range strings.Split(tag, ".") {
+		if platform == "any" {
+			return true
+		}
+
+		var osOK, archOK bool
+		switch goos {
+		case "darwin":
+			osOK = strings.HasPrefix(platform, "macosx")
+			archOK = strings.HasSuffix(platform, "universal2") ||
+				(arch == "arm64" && strings.HasSuffix(platform, "arm64")) ||
+				(arch == "amd64" && (strings.HasSuffix(platform, "x86_64") || strings.HasSuffix(platform, "intel")))
+		case "windows":
+			osOK = strings.HasPrefix(platform, "win")
+			archOK = (arch == "arm64" && platform == "win_arm64") || (arch == "amd64" && platform == "win_amd64")
+		default:
+			osOK = strings.Contains(platform, "linux")
+			archOK = (arch == "arm64" && strings.HasSuffix(platform, "aarch64")) || (arch == "amd64" && strings.HasSuffix(platform, "x86_64"))
+		}
+		if osOK && archOK {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckArchitecture warns about situations on ARM machines that commonly cause
+// install failures: a Python interpreter running under emulation and packages
+// without ARM wheels. arch is the architecture of the Python interpreter and
+// noWheels lists packages that are known to have no wheels for it. Only the
+// packages that are not installed yet are looked up on PyPI. The warnings are
+// reported as messages of the system reporter.
+func CheckArchitecture(arch string, packages []string, noWheels []string) {
+	warn := func(format string, args ...any) {
+		system.ReportMessage(system.StreamStderr, fmt.Sprintf(format, args...))
+	}
+	if system.MachineArch() == "arm64" && arch == "amd64" {
+		warn("Warning: your Python interpreter is an x86-64 build running under emulation (%s).", system.Emulator())
+		warn("Native arm64 wheels will not be used. Install an arm64 Python for better compatibility and performance.")
+	}
+
+	if arch != "arm64" || len(packages) == 0 {
+		return
+	}
+
+	missing := append([]string{}, noWheels...)
+	missing = append(missing, PackagesWithoutWheels(notInstalled(packages), runtime.GOOS, arch)...)
+	if len(missing) == 0 {
+		return
+	}
+
+	warn("Warning: the following packages have no prebuilt %s wheels and will be built from source:", arch)
+	for _, pkg := range uniqueStrings(missing) {
+		warn("  - %s", pkg)
+	}
+	warn("If the installation fails, you can work in an x86-64 container instead, e.g.:")
+	warn("  docker run --rm -it --platform %s -v \"$PWD\":/app -w /app python:3.11 bash", system.DockerPlatform("amd64"))
+}
+
+// notInstalled returns the requirements of packages that the environment does
+// not satisfy yet, by name and, if they pin one, version. All of them if the
+// installed packages cannot be listed.
+func notInstalled(packages []string) []string {
+	installed, err := GetInstalledPackages()
+	if err != nil {
+		return packages
+	}
+	versions := map[string]string{}
+	for _, pkg := range installed {
+		versions[normalizePackageName(pkg.Name)] = pkg.Version
+	}
+
+	result := []string{}
+	for _, pkg := range packages {
+		m := plainRequirement.FindStringSubmatch(strings.TrimSpace(pkg))
+		if m != nil {
+			version, ok := versions[normalizePackageName(m[1])]
+			if ok && (m[4] == "" || m[4] == version) {
+				continue
+			}
+		}
+		result = append(result, pkg)
+	}
+	return result
+}
+
+// normalizePackageName returns the normalized form of a package name, in
+// which runs of -, _ and . are a single - and letters are lowercase, as in
+// PEP 503.
+func normalizePackageName(name string) string {
+	return strings.ToLower(packageNameSeparators.ReplaceAllString(name, "-"))
+}
+
+var packageNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// Arch returns the architecture of the Python interpreter in the current
+// environment, falling back to the machine's architecture.
+func Arch() string {
+	pythonPath, err := system.FindPython()
+	if err != nil {
+		return system.MachineArch()
+	}
+	arch, err := system.PythonArch(pythonPath)
+	if err != nil {
+		return system.MachineArch()
+	}
+	return arch
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package repl
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// maxHistory is the number of inputs that are kept in the history.
+const maxHistory = 1000
+
+// history keeps the inputs of the project's REPL sessions in a file, one
+// JSON string per line, so that inputs may span several lines.
+type history struct {
+	path    string
+	entries []string
+}
+
+// loadHistory reads the history at path. A missing or unreadable history is
+// empty.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			h.entries = append(h.entries, entry)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// add appends an input to the history.
+func (h *history) add(entry string) error {
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// get returns the entry with the given number, counting from 1, or the last
+// entry for 0.
+func (h *history) get(n int) (string, bool) {
+	if n == 0 {
+		n = len(h.entries)
+	}
+	if n < 1 || n > len(h.entries) {
+		return "", false
+	}
+	return h.entries[n-1], true
+}
@@ -0,0 +1,123 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// lineReader reads the lines that the user enters. pasted is set for lines
+// that are part of a paste, which continue the input.
+type lineReader interface {
+	ReadLine(prompt string) (line string, pasted bool, err error)
+	Close()
+}
+
+// newLineReader returns a reader with line editing and history for terminals
+// and a plain reader otherwise, e.g. for inputs piped to langforge.
+func newLineReader(in *os.File, out io.Writer) lineReader {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		return &plainReader{scanner: scanner}
+	}
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, "")
+	t.SetBracketedPasteMode(true)
+	return &terminalReader{fd: fd, terminal: t}
+}
+
+// terminalReader reads lines in raw mode, in which the terminal edits them.
+// The terminal is only in raw mode while a line is read, so that interrupts
+// stop running chains.
+type terminalReader struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, bool, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", false, err
+	}
+	defer term.Restore(r.fd, state)
+
+	if width, height, err := term.GetSize(r.fd); err == nil {
+		r.terminal.SetSize(width, height)
+	}
+	r.terminal.SetPrompt(prompt)
+	line, err := r.terminal.ReadLine()
+	if err == term.ErrPasteIndicator {
+		return line, true, nil
+	}
+	return line, false, err
+}
+
+func (r *terminalReader) Close() {
+	r.terminal.SetBracketedPasteMode(false)
+}
+
+// plainReader reads lines without prompting.
+type plainReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, bool, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", false, err
+		}
+		return "", false, io.EOF
+	}
+	return r.scanner.Text(), false, nil
+}
+
+func (r *plainReader) Close() {}
+
+// readInput reads an input, which continues on the next line if a line ends
+// with a backslash, spans the lines between two """ lines, or is pasted.
+func readInput(reader lineReader, prompt string) (string, error) {
+	line, pasted, err := reader.ReadLine(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{}
+	if strings.TrimSpace(line) == `"""` {
+		for {
+			line, _, err := reader.ReadLine(continuationPrompt)
+			if err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(line) == `"""` {
+				return strings.Join(lines, "\n"), nil
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	for {
+		switch {
+		case strings.HasSuffix(line, `\`):
+			lines = append(lines, strings.TrimSuffix(line, `\`))
+		case pasted:
+			lines = append(lines, line)
+		default:
+			lines = append(lines, line)
+			return strings.TrimRight(strings.Join(lines, "\n"), "\n"), nil
+		}
+		line, pasted, err = reader.ReadLine(continuationPrompt)
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// continuationPrompt is the prompt of the lines that continue an input.
+const continuationPrompt = "... "
@@ -0,0 +1,348 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/client"
+	"langforge/schema"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryFileName is the name of the file in the project's state directory
+// that holds the inputs of REPL sessions.
+const HistoryFileName = "repl_history"
+
+// memoryKey is the input of chains with memory that holds the conversation.
+const memoryKey = "memory"
+
+// Help describes the commands of a session.
+const Help = `Enter the input of the chain to invoke it. Inputs continue on the next line
+after a line ending with \, and span all lines between two """ lines.
+Chains with several inputs ask for each of them, or take a JSON object.
+
+  :chains         list the chains
+  :chain <name>   switch to another chain
+  :reset          forget the conversation of a chain with memory
+  :history [n]    show the last n inputs (default 20)
+  !!, !<n>        repeat the last input or input n
+  :help           show this help
+  :quit           leave the REPL (or Ctrl-D)
+
+Ctrl-C stops a running chain.`
+
+// Session invokes the chains of a worker with the inputs the user enters.
+type Session struct {
+	// Client sends the requests to the worker.
+	Client *client.Client
+	// Schemas are the schemas of the chains of the worker.
+	Schemas *schema.Schemas
+	// Headers returns the headers to send with the requests of a chain, e.g.
+	// its env variables.
+	Headers func(chain string) map[string]string
+	// HistoryPath is the file that keeps the inputs across sessions.
+	HistoryPath string
+
+	chain   *schema.Chain
+	headers []string
+	memory  []any
+	out     io.Writer
+	reader  lineReader
+	history *history
+}
+
+// Run reads inputs from in and invokes chain with them until the user quits
+// or in ends.
+func (s *Session) Run(chain string, in *os.File, out io.Writer) error {
+	s.out = out
+	s.history = loadHistory(s.HistoryPath)
+	s.reader = newLineReader(in, out)
+	defer s.reader.Close()
+
+	if err := s.use(chain); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, `Type :help for help, :quit to leave.`)
+
+	for {
+		input, err := readInput(s.reader, s.chain.Name+"> ")
+		if err == io.EOF {
+			fmt.Fprintln(s.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		// repeated inputs are shown and recorded like typed ones
+		if strings.HasPrefix(input, "!") {
+			repeated, ok := s.repeat(input)
+			if !ok {
+				fmt.Fprintf(s.out, "No input %s in the history.\n", strings.TrimPrefix(input, "!"))
+				continue
+			}
+			input = repeated
+			fmt.Fprintln(s.out, input)
+		}
+
+		if strings.HasPrefix(input, ":") {
+			if quit := s.command(input); quit {
+				return nil
+			}
+			continue
+		}
+
+		if err := s.history.add(input); err != nil {
+			fmt.Fprintf(s.out, "Error saving the history: %v\n", err)
+		}
+		s.invoke(input)
+	}
+}
+
+// use switches to the chain with the given name.
+func (s *Session) use(name string) error {
+	chain := s.Schemas.Find(name)
+	if chain == nil {
+		return fmt.Errorf("unknown chain %q, the chains are %s", name, strings.Join(s.chainNames(), ", "))
+	}
+	s.chain = chain
+	s.memory = nil
+
+	headers := map[string]string{}
+	if s.Headers != nil {
+		headers = s.Headers(name)
+	}
+	for _, key := range s.headers {
+		s.Client.SetHeader(key, "")
+	}
+	s.headers = nil
+	for key, value := range headers {
+		s.Client.SetHeader(key, value)
+		s.headers = append(s.headers, key)
+	}
+
+	keys := s.inputKeys()
+	if len(keys) == 1 {
+		fmt.Fprintf(s.out, "Chain %s takes the input %s.\n", name, keys[0])
+	} else {
+		fmt.Fprintf(s.out, "Chain %s takes the inputs %s.\n", name, strings.Join(keys, ", "))
+	}
+	if s.hasMemory() {
+		fmt.Fprintln(s.out, "The conversation is kept as the memory of the chain, :reset forgets it.")
+	}
+	return nil
+}
+
+func (s *Session) chainNames() []string {
+	names := []string{}
+	for _, chain := range s.Schemas.Chains {
+		names = append(names, chain.Name)
+	}
+	return names
+}
+
+// inputKeys returns the inputs of the chain, other than its memory, in the
+// order the chain declares them.
+func (s *Session) inputKeys() []string {
+	keys := []string{}
+	input := s.chain.Input
+	if input == nil {
+		return []string{"input"}
+	}
+	seen := map[string]bool{memoryKey: true}
+	for _, key := range input.Required {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	rest := []string{}
+	for key := range input.Properties {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+	if len(keys) == 0 {
+		return []string{"input"}
+	}
+	return keys
+}
+
+func (s *Session) hasMemory() bool {
+	if s.chain.Input == nil {
+		return false
+	}
+	_, ok := s.chain.Input.Properties[memoryKey]
+	return ok
+}
+
+// repeat returns the input of a history reference such as !! or !3.
+func (s *Session) repeat(reference string) (string, bool) {
+	reference = strings.TrimPrefix(reference, "!")
+	if reference == "!" {
+		return s.history.get(0)
+	}
+	n, err := strconv.Atoi(reference)
+	if err != nil {
+		return "", false
+	}
+	return s.history.get(n)
+}
+
+// command runs a REPL command and returns whether the session ends.
+func (s *Session) command(input string) bool {
+	fields := strings.Fields(strings.TrimPrefix(input, ":"))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "quit", "exit", "q":
+		return true
+	case "help", "h":
+		fmt.Fprintln(s.out, Help)
+	case "chains":
+		for _, chain := range s.Schemas.Chains {
+			marker := "  "
+			if chain == s.chain {
+				marker = "* "
+			}
+			fmt.Fprintf(s.out, "%s%s", marker, chain.Name)
+			if chain.Description != "" {
+				fmt.Fprintf(s.out, "  %s", chain.Description)
+			}
+			fmt.Fprintln(s.out)
+		}
+	case "chain":
+		if len(fields) < 2 {
+			fmt.Fprintf(s.out, "Usage: :chain <name>, the chains are %s\n", strings.Join(s.chainNames(), ", "))
+			break
+		}
+		if err := s.use(fields[1]); err != nil {
+			fmt.Fprintln(s.out, err)
+		}
+	case "reset":
+		s.memory = nil
+		fmt.Fprintln(s.out, "Forgot the conversation.")
+	case "history":
+		n := 20
+		if len(fields) > 1 {
+			if parsed, err := strconv.Atoi(fields[1]); err == nil && parsed > 0 {
+				n = parsed
+			}
+		}
+		start := len(s.history.entries) - n
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i < len(s.history.entries); i++ {
+			entry := strings.ReplaceAll(s.history.entries[i], "\n", "\n      ")
+			fmt.Fprintf(s.out, "%5d %s\n", i+1, entry)
+		}
+	default:
+		fmt.Fprintf(s.out, "Unknown command :%s, type :help for help.\n", fields[0])
+	}
+	return false
+}
+
+// inputs returns the inputs of a request, asking for further inputs of chains
+// with several of them. ok is false if the user abandoned the input.
+func (s *Session) inputs(first string) (map[string]any, bool) {
+	keys := s.inputKeys()
+	inputs := map[string]any{}
+	if strings.HasPrefix(strings.TrimSpace(first), "{") {
+		if err := json.Unmarshal([]byte(first), &inputs); err != nil {
+			fmt.Fprintf(s.out, "Invalid JSON input: %v\n", err)
+			return nil, false
+		}
+	} else {
+		inputs[keys[0]] = first
+		for _, key := range keys[1:] {
+			value, err := readInput(s.reader, key+"> ")
+			if err != nil {
+				fmt.Fprintln(s.out)
+				return nil, false
+			}
+			inputs[key] = value
+		}
+	}
+	if s.hasMemory() {
+		inputs[memoryKey] = append([]any{}, s.memory...)
+	}
+	return inputs, true
+}
+
+// invoke sends an input to the chain and prints the tokens as they are
+// streamed and the outputs.
+func (s *Session) invoke(input string) {
+	inputs, ok := s.inputs(input)
+	if !ok {
+		return
+	}
+
+	// Ctrl-C cancels the request instead of ending langforge
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	started := time.Now()
+	streamed := false
+	outputs, err := s.Client.Stream(ctx, s.chain.Name, inputs, func(token string) {
+		streamed = true
+		fmt.Fprint(s.out, token)
+	})
+	if streamed {
+		fmt.Fprintln(s.out)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(s.out, "Canceled.")
+		} else {
+			fmt.Fprintln(s.out, "Error:", err)
+		}
+		return
+	}
+
+	text, single := "", len(outputs) == 1
+	if single {
+		text, _ = client.OutputText(outputs, "")
+	}
+	switch {
+	case single && !streamed:
+		fmt.Fprintln(s.out, text)
+	case !single:
+		keys := []string{}
+		for key := range outputs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, _ := client.OutputText(outputs, key)
+			fmt.Fprintf(s.out, "%s: %s\n", key, value)
+		}
+	}
+	fmt.Fprintf(s.out, "(%.1fs)\n", time.Since(started).Seconds())
+
+	if question, ok := inputs[s.inputKeys()[0]].(string); ok && s.hasMemory() && single {
+		s.memory = append(s.memory, question, text)
+	}
+}
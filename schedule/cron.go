@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression.
+type Cron struct {
+	expr     string
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// a day matches if it matches either the day of month or the day of the
+	// week when both are restricted, like in cron
+	anyDay     bool
+	anyWeekday bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseCron parses a cron expression of five fields, minute, hour, day of
+// month, month and day of week, e.g. "30 2 * * 1-5". Fields are "*", numbers,
+// ranges such as "1-5", steps such as "*/15" or "0-30/10" and lists of them
+// separated by commas. Months and days of the week may be given by their
+// first three letters, and Sunday is 0 or 7. The macros "@hourly", "@daily",
+// "@weekly", "@monthly" and "@yearly" are also accepted.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", expr)
+	}
+
+	c := &Cron{expr: expr}
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute in cron expression %q: %v", expr, err)
+	}
+	if c.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour in cron expression %q: %v", expr, err)
+	}
+	if c.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month in cron expression %q: %v", expr, err)
+	}
+	if c.months, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month in cron expression %q: %v", expr, err)
+	}
+	if c.weekdays, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week in cron expression %q: %v", expr, err)
+	}
+	// 7 is another name for Sunday
+	if c.weekdays&(1<<7) != 0 {
+		c.weekdays |= 1
+	}
+	c.anyDay = fields[2] == "*"
+	c.anyWeekday = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField returns the values of a field as a bit set. names are the
+// names of the values starting at min.
+func parseCronField(field string, min int, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			step = n
+			part = part[:i]
+		}
+
+		first, last := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if first, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			if last, err = parseCronValue(bounds[1], min, max, names); err != nil {
+				return 0, err
+			}
+			if first > last {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := parseCronValue(part, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			first = value
+			// "5/10" counts from 5 to the end of the range
+			if step == 1 {
+				last = value
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, min int, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			return min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%d is not between %d and %d", n, min, max)
+	}
+	return n, nil
+}
+
+// String returns the expression, with macros expanded.
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Matches reports whether the expression matches the minute of t.
+func (c *Cron) Matches(t time.Time) bool {
+	return c.minutes&(1<<uint(t.Minute())) != 0 &&
+		c.hours&(1<<uint(t.Hour())) != 0 &&
+		c.months&(1<<uint(t.Month())) != 0 &&
+		c.matchesDay(t)
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if !c.anyDay && !c.anyWeekday {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Next returns the first minute after t that the expression matches, or the
+// zero time if it matches none within five years, e.g. for "0 0 31 2 *".
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0 || !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,339 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"langforge/envfile"
+	"langforge/jobs"
+	"langforge/notify"
+	"langforge/project"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobKind is the kind of the jobs that record the runs of scheduled tasks.
+const JobKind = "task"
+
+// Triggers of the runs of a task, recorded in the "trigger" parameter of
+// their jobs.
+const (
+	Scheduled = "schedule"
+	Manual    = "manual"
+)
+
+func init() {
+	jobs.Register(JobKind, runTask)
+}
+
+// Task is a scheduled task of the project configuration.
+type Task struct {
+	Name    string
+	Command string
+	Cron    *Cron
+	Timeout time.Duration
+}
+
+// ParseTasks parses the scheduled tasks of a project configuration.
+func ParseTasks(configs []project.TaskConfig) ([]*Task, error) {
+	tasks := []*Task{}
+	names := map[string]bool{}
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("a scheduled task has no name")
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("the scheduled task %s is declared twice", config.Name)
+		}
+		names[config.Name] = true
+
+		cron, err := ParseCron(config.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("scheduled task %s: %v", config.Name, err)
+		}
+		args, err := SplitArgs(config.Command)
+		if err != nil {
+			return nil, fmt.Errorf("scheduled task %s: %v", config.Name, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("scheduled task %s has no command", config.Name)
+		}
+		task := &Task{Name: config.Name, Command: config.Command, Cron: cron}
+		if config.Timeout != "" {
+			task.Timeout, err = time.ParseDuration(config.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("scheduled task %s: invalid timeout %q: %v", config.Name, config.Timeout, err)
+			}
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// FindTask returns the task with the given name, or nil.
+func FindTask(tasks []*Task, name string) *Task {
+	for _, task := range tasks {
+		if task.Name == name {
+			return task
+		}
+	}
+	return nil
+}
+
+// SplitArgs splits a command into its arguments at spaces outside of single
+// and double quotes.
+func SplitArgs(command string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command %q", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// Enqueue creates the job that records a run of the task.
+func Enqueue(dir string, task *Task, trigger string) (*jobs.Job, error) {
+	params := map[string]string{"task": task.Name, "trigger": trigger}
+	if task.Timeout > 0 {
+		params["timeout"] = task.Timeout.String()
+	}
+	return jobs.Enqueue(dir, JobKind, []string{task.Command}, 1, params)
+}
+
+// History returns the runs of the task with the given name, or of all tasks
+// if name is empty, most recent first.
+func History(dir string, name string) ([]*jobs.Job, error) {
+	list, err := jobs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	runs := []*jobs.Job{}
+	for _, job := range list {
+		if job.Kind == JobKind && (name == "" || job.Params["task"] == name) {
+			runs = append(runs, job)
+		}
+	}
+	return runs, nil
+}
+
+// runTask runs the langforge command of a task in the project directory. Its
+// input is empty, so that the command does not wait for answers.
+func runTask(dir string, job *jobs.Job, batch []string, log io.Writer) error {
+	args, err := SplitArgs(batch[0])
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if timeout := job.Params["timeout"]; timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return err
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	// the logs of tasks are kept, without the secrets of the .env file
+	dotEnv, err := envfile.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "$ langforge %s\n", batch[0])
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader("")
+	tail := &tailWriter{}
+	cmd.Stdout = envfile.NewMasker(dotEnv).Writer(io.MultiWriter(log, tail))
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", job.Params["timeout"])
+		}
+		if line := tail.lastLine(); line != "" {
+			return fmt.Errorf("%v: %s", err, line)
+		}
+		return err
+	}
+	return nil
+}
+
+// tailWriter keeps the end of the output of a task, whose last line usually
+// explains a failure.
+type tailWriter struct {
+	data []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.data = append(w.data, p...)
+	if len(w.data) > 4096 {
+		w.data = w.data[len(w.data)-4096:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) lastLine() string {
+	lines := strings.Split(strings.TrimSpace(string(w.data)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Scheduler runs the scheduled tasks of a project at the minutes their cron
+// expressions match. A task is skipped while its previous run has not
+// finished. Failed runs are reported to the notification channels of the
+// project.
+type Scheduler struct {
+	dir           string
+	mu            sync.Mutex
+	tasks         []*Task
+	notifications []project.NotificationConfig
+	name          string
+	running       map[string]bool
+	stop          chan struct{}
+	// Output receives the messages of the scheduler.
+	Output io.Writer
+}
+
+// NewScheduler returns a scheduler for the tasks of the project in dir.
+func NewScheduler(dir string, config *project.Config) (*Scheduler, error) {
+	s := &Scheduler{
+		dir:     dir,
+		running: map[string]bool{},
+		stop:    make(chan struct{}),
+		Output:  os.Stdout,
+	}
+	if err := s.Update(config); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Update replaces the tasks and the notification channels with those of
+// config. Runs in progress continue.
+func (s *Scheduler) Update(config *project.Config) error {
+	tasks, err := ParseTasks(config.Schedule)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = tasks
+	s.notifications = config.Notifications
+	s.name = config.Name
+	return nil
+}
+
+// Tasks returns the scheduled tasks.
+func (s *Scheduler) Tasks() []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks
+}
+
+// Start runs the tasks in the background until Stop is called.
+func (s *Scheduler) Start() {
+	go func() {
+		for {
+			next := time.Now().Truncate(time.Minute).Add(time.Minute)
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(time.Until(next)):
+			}
+
+			for _, task := range s.Tasks() {
+				if task.Cron.Matches(next) {
+					s.start(task)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops scheduling tasks. Runs in progress are not stopped.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *Scheduler) start(task *Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[task.Name] {
+		fmt.Fprintf(s.Output, "Skipping the scheduled task %s, its previous run has not finished.\n", task.Name)
+		return
+	}
+	s.running[task.Name] = true
+	go func() {
+		s.run(task)
+		s.mu.Lock()
+		delete(s.running, task.Name)
+		s.mu.Unlock()
+	}()
+}
+
+func (s *Scheduler) run(task *Task) {
+	job, err := Enqueue(s.dir, task, Scheduled)
+	if err != nil {
+		fmt.Fprintf(s.Output, "Error starting the scheduled task %s: %v\n", task.Name, err)
+		return
+	}
+
+	fmt.Fprintf(s.Output, "Running the scheduled task %s (job %s)\n", task.Name, job.ID)
+	err = jobs.Run(s.dir, job, nil)
+	if err == nil {
+		fmt.Fprintf(s.Output, "The scheduled task %s finished.\n", task.Name)
+		return
+	}
+	fmt.Fprintf(s.Output, "The scheduled task %s failed: %v\n", task.Name, err)
+
+	s.mu.Lock()
+	notifications, name := s.notifications, s.name
+	s.mu.Unlock()
+	event := &notify.Event{
+		Kind:    notify.TaskFailed,
+		Project: name,
+		Title:   fmt.Sprintf("Scheduled task %s failed", task.Name),
+		Message: err.Error(),
+		Fields: map[string]string{
+			"task":    task.Name,
+			"command": "langforge " + task.Command,
+			"job":     job.ID,
+		},
+	}
+	if err := notify.Send(context.Background(), notifications, event); err != nil {
+		fmt.Fprintln(s.Output, err)
+	}
+}
@@ -0,0 +1,90 @@
+package schema
+
+import "langforge/provider"
+
+// OpenAPI returns an OpenAPI 3.0 document that describes the chat endpoints of
+// the given chains.
+func OpenAPI(title string, version string, schemas *Schemas) map[string]any {
+	if version == "" {
+		version = "0.1.0"
+	}
+
+	codes := []string{}
+	for _, code := range provider.Codes {
+		codes = append(codes, string(code))
+	}
+	errorSchema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{"type": "string"},
+			"code": map[string]any{
+				"type":        "string",
+				"description": "Identifies errors of the LLM provider",
+				"enum":        codes,
+			},
+		},
+		"required": []string{"error"},
+	}
+
+	paths := map[string]any{}
+	components := map[string]any{"Error": errorSchema}
+
+	for _, chain := range schemas.Chains {
+		inputName := chain.Name + "Input"
+		outputName := chain.Name + "Output"
+		components[inputName] = chain.Input
+		components[outputName] = chain.Output
+
+		operation := map[string]any{
+			"operationId": chain.Name,
+			"summary":     "Run the " + chain.Name + " chain",
+			"requestBody": map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": ref(inputName),
+					},
+				},
+			},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "The outputs of the chain",
+					"content": map[string]any{
+						"application/json": map[string]any{
+							"schema": ref(outputName),
+						},
+					},
+				},
+				"default": map[string]any{
+					"description": "An error",
+					"content": map[string]any{
+						"application/json": map[string]any{
+							"schema": ref("Error"),
+						},
+					},
+				},
+			},
+		}
+		if chain.Description != "" {
+			operation["description"] = chain.Description
+		}
+
+		paths["/chat/"+chain.Name] = map[string]any{"post": operation}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": components,
+		},
+	}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
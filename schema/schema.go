@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the name of the file in the project's state directory that holds
+// the extracted chain schemas.
+const FileName = "schemas.json"
+
+// Schema is a JSON schema. Only the keywords used for chain inputs and outputs
+// are modeled.
+type Schema struct {
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// Chain holds the input and output schema of a chain served by the worker.
+type Chain struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Input       *Schema `json:"input"`
+	Output      *Schema `json:"output"`
+}
+
+// Schemas are the chain schemas of a project.
+type Schemas struct {
+	Extracted time.Time `json:"extracted"`
+	Chains    []*Chain  `json:"chains"`
+}
+
+// Find returns the schema of the chain with the given name, or nil.
+func (s *Schemas) Find(name string) *Chain {
+	for _, chain := range s.Chains {
+		if chain.Name == name {
+			return chain
+		}
+	}
+	return nil
+}
+
+// Fetch asks the worker reached through transport for the schemas of the
+// chains it serves.
+func Fetch(ctx context.Context, transport http.RoundTripper) (*Schemas, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://worker/schemas", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker returned %s for /schemas", resp.Status)
+	}
+
+	schemas := &Schemas{}
+	if err := json.NewDecoder(resp.Body).Decode(schemas); err != nil {
+		return nil, fmt.Errorf("invalid schemas from worker: %v", err)
+	}
+	schemas.Extracted = time.Now()
+	return schemas, nil
+}
+
+// WaitAndFetch polls the worker until it answers or the timeout expires.
+func WaitAndFetch(ctx context.Context, transport http.RoundTripper, timeout time.Duration) (*Schemas, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		schemas, err := Fetch(ctx, transport)
+		if err == nil {
+			return schemas, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// Path returns the path of the schemas file of the project in dir.
+func Path(dir string) string {
+	return filepath.Join(project.StateDir(dir), FileName)
+}
+
+// Save stores the schemas in the project's state directory.
+func Save(dir string, schemas *Schemas) error {
+	if _, err := project.EnsureStateDir(dir); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schemas, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(Path(dir), data, 0644)
+}
+
+// Load reads the schemas from the project's state directory. It returns an
+// error satisfying os.IsNotExist if they have not been extracted yet.
+func Load(dir string) (*Schemas, error) {
+	data, err := os.ReadFile(Path(dir))
+	if err != nil {
+		return nil, err
+	}
+	schemas := &Schemas{}
+	if err := json.Unmarshal(data, schemas); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", FileName, err)
+	}
+	return schemas, nil
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Validate checks a decoded JSON value against the schema and returns a
+// description of the first violation, or nil if the value is valid.
+func (s *Schema) Validate(value any) error {
+	return s.validate("input", "", value)
+}
+
+// ValidateAs checks a value like Validate, calling it name rather than input
+// in the description of a violation of the value itself, e.g. "output".
+func (s *Schema) ValidateAs(name string, value any) error {
+	return s.validate(name, "", value)
+}
+
+func (s *Schema) validate(root string, path string, value any) error {
+	name := path
+	if name == "" {
+		name = root
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", name)
+		}
+		for _, key := range s.Required {
+			if _, ok := object[key]; !ok {
+				return fmt.Errorf("%s is required", join(path, key))
+			}
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := s.Properties[key]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s is not allowed", join(path, key))
+				}
+				continue
+			}
+			if err := property.validate(root, join(path, key), object[key]); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", name)
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				if err := s.Items.validate(root, fmt.Sprintf("%s[%d]", name, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+	case "number", "integer":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s must be a number", name)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+	}
+	return nil
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
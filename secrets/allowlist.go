@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"bufio"
+	"langforge/watcher"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AllowlistFileName is the name of the file in the project that allows false
+// positives of the scan.
+const AllowlistFileName = ".secretsignore"
+
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Allowlist allows findings by the fingerprint of the secret, wherever it
+// occurs, and files by gitignore-style patterns.
+type Allowlist struct {
+	fingerprints map[string]bool
+	paths        *watcher.Ignore
+}
+
+// LoadAllowlist reads the allowlist of the project in dir. A missing file
+// allows nothing. Each line of the file is either the fingerprint of a
+// finding, as printed by 'langforge scan secrets', or a gitignore-style
+// pattern of files that are not scanned:
+//
+//	# test fixtures with fake keys
+//	tests/fixtures/
+//	3f9a1c2b7d4e8f60
+func LoadAllowlist(dir string) (*Allowlist, error) {
+	allowlist := &Allowlist{
+		fingerprints: map[string]bool{},
+		paths:        watcher.NewIgnore(nil),
+	}
+
+	file, err := os.Open(filepath.Join(dir, AllowlistFileName))
+	if os.IsNotExist(err) {
+		return allowlist, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if fingerprintPattern.MatchString(line) {
+			allowlist.fingerprints[line] = true
+		} else {
+			allowlist.paths.Add(line)
+		}
+	}
+	return allowlist, scanner.Err()
+}
+
+// Allows reports whether the secret with the fingerprint is allowed.
+func (a *Allowlist) Allows(fingerprint string) bool {
+	return a != nil && a.fingerprints[fingerprint]
+}
+
+// MatchPath reports whether the slash-separated path relative to the project
+// is not scanned.
+func (a *Allowlist) MatchPath(rel string, isDir bool) bool {
+	return a != nil && a.paths.Match(rel, isDir)
+}
@@ -0,0 +1,234 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxFileSize is the size above which files are not scanned, since they are
+// data rather than code or configuration.
+const maxFileSize = 4 * 1024 * 1024
+
+// skippedDirs are never scanned. Unlike the other files of the state
+// directory, the generated package sources are scanned, because they are
+// shipped in the wheel.
+var skippedDirs = map[string]bool{
+	".git":               true,
+	".venv":              true,
+	".conda":             true,
+	"__pycache__":        true,
+	".ipynb_checkpoints": true,
+	"node_modules":       true,
+}
+
+// generatedDirs are the directories of the state directory that are scanned.
+var generatedDirs = []string{"package"}
+
+// Finding is a possible secret in a file of the project.
+type Finding struct {
+	// Path is the slash-separated path relative to the project.
+	Path string
+	// Cell is the number of the notebook cell, counting from 1, or 0 for
+	// other files.
+	Cell int
+	// Line counts from 1, in the cell for notebooks.
+	Line int
+	Rule string
+	// Secret is the masked secret.
+	Secret string
+	// Fingerprint identifies the secret in the allowlist without revealing it.
+	Fingerprint string
+}
+
+// Location returns the path and line of the finding.
+func (f *Finding) Location() string {
+	if f.Cell > 0 {
+		return fmt.Sprintf("%s (cell %d, line %d)", f.Path, f.Cell, f.Line)
+	}
+	return fmt.Sprintf("%s:%d", f.Path, f.Line)
+}
+
+// Fingerprint returns the fingerprint of a secret.
+func Fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Scan scans the files and notebooks of the project in dir and the package
+// sources generated by 'langforge package' for secrets, skipping what the
+// allowlist allows. The .env file is not scanned, since it is the place for
+// secrets and is neither packaged nor exported by default.
+func Scan(dir string, allowlist *Allowlist) ([]Finding, error) {
+	findings := []Finding{}
+	roots := []string{dir}
+	for _, name := range generatedDirs {
+		roots = append(roots, filepath.Join(project.StateDir(dir), name))
+	}
+
+	for _, root := range roots {
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == root {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == "." {
+				return nil
+			}
+			if info.IsDir() {
+				if skippedDirs[info.Name()] || (root == dir && info.Name() == project.StateDirName) || allowlist.MatchPath(rel, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() || info.Size() > maxFileSize || rel == ".env" || allowlist.MatchPath(rel, false) {
+				return nil
+			}
+
+			fileFindings, err := scanFile(p, rel)
+			if err != nil {
+				return err
+			}
+			for _, finding := range fileFindings {
+				if !allowlist.Allows(finding.Fingerprint) {
+					findings = append(findings, finding)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Path < findings[j].Path
+	})
+	return findings, nil
+}
+
+func scanFile(path string, rel string) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// binary files such as wheels and archives are skipped
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	if strings.HasSuffix(rel, ".ipynb") {
+		findings, ok := scanNotebook(data, rel)
+		if ok {
+			return findings, nil
+		}
+	}
+	return scanText(bytes.NewReader(data), rel, 0)
+}
+
+// scanNotebook scans the sources and the text outputs of the cells of a
+// notebook. It returns false if the notebook cannot be parsed.
+func scanNotebook(data []byte, rel string) ([]Finding, bool) {
+	var notebook struct {
+		Cells []struct {
+			Source  notebookText `json:"source"`
+			Outputs []struct {
+				Text notebookText            `json:"text"`
+				Data map[string]notebookText `json:"data"`
+			} `json:"outputs"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &notebook); err != nil {
+		return nil, false
+	}
+
+	findings := []Finding{}
+	for i, cell := range notebook.Cells {
+		texts := []string{string(cell.Source)}
+		for _, output := range cell.Outputs {
+			texts = append(texts, string(output.Text), string(output.Data["text/plain"]))
+		}
+		for _, text := range texts {
+			cellFindings, _ := scanText(strings.NewReader(text), rel, i+1)
+			findings = append(findings, cellFindings...)
+		}
+	}
+	return findings, true
+}
+
+// notebookText is a multi-line string of a notebook, which is stored either
+// as a string or as a list of lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		// outputs such as images are not text
+		return nil
+	}
+	*t = notebookText(text)
+	return nil
+}
+
+func scanText(r io.Reader, rel string, cell int) ([]Finding, error) {
+	findings := []Finding{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		add := func(rule string, secret string) {
+			findings = append(findings, Finding{
+				Path:        rel,
+				Cell:        cell,
+				Line:        line,
+				Rule:        rule,
+				Secret:      mask(secret),
+				Fingerprint: Fingerprint(secret),
+			})
+		}
+
+		matched := false
+		for _, rule := range Rules {
+			for _, secret := range rule.Pattern.FindAllString(text, -1) {
+				matched = true
+				if !isPlaceholder(secret) {
+					add(rule.Name, secret)
+				}
+			}
+		}
+		if matched {
+			continue
+		}
+		for _, match := range assignmentPattern.FindAllStringSubmatch(text, -1) {
+			if looksRandom(match[2]) {
+				add(EntropyRule, match[2])
+			}
+		}
+	}
+	return findings, scanner.Err()
+}
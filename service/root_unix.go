@@ -0,0 +1,11 @@
+//go:build !windows
+
+package service
+
+import "os"
+
+// isRoot reports whether langforge runs with root privileges, in which case
+// system services are installed instead of user services.
+func isRoot() bool {
+	return os.Geteuid() == 0
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Spec describes a command that is run as a service and started on boot.
+type Spec struct {
+	// Name identifies the service, e.g. "langforge-myapp".
+	Name        string
+	Description string
+	// Dir is the working directory of the command.
+	Dir  string
+	Args []string
+	// Env holds environment variables of the command.
+	Env map[string]string
+	// LogPath is the file that receives the output of the command.
+	LogPath string
+}
+
+// Status is the state of an installed service.
+type Status struct {
+	Installed bool
+	Running   bool
+	// Location is the path of the unit, plist or task name.
+	Location string
+	// Detail is additional output of the service manager.
+	Detail string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Name returns the service name of the project with the given name.
+func Name(projectName string) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(projectName, "-"), "-")
+	if name == "" {
+		name = "app"
+	}
+	return "langforge-" + strings.ToLower(name)
+}
+
+// run runs a command of the service manager and includes its output in errors.
+func run(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
@@ -0,0 +1,136 @@
+//go:build darwin
+
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// label returns the launchd label of the service.
+func label(name string) string {
+	return "dev.langforge." + strings.TrimPrefix(name, "langforge-")
+}
+
+// plistPath returns the path of the launchd property list. Without root
+// privileges the service is installed as a launch agent of the user.
+func plistPath(name string) (string, error) {
+	if isRoot() {
+		return filepath.Join("/Library/LaunchDaemons", label(name)+".plist"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", label(name)+".plist"), nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func plist(spec *Spec) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "  <key>Label</key>\n  <string>%s</string>\n", xmlEscape(label(spec.Name)))
+	b.WriteString("  <key>ProgramArguments</key>\n  <array>\n")
+	for _, arg := range spec.Args {
+		fmt.Fprintf(&b, "    <string>%s</string>\n", xmlEscape(arg))
+	}
+	b.WriteString("  </array>\n")
+	fmt.Fprintf(&b, "  <key>WorkingDirectory</key>\n  <string>%s</string>\n", xmlEscape(spec.Dir))
+
+	if len(spec.Env) > 0 {
+		keys := []string{}
+		for key := range spec.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("  <key>EnvironmentVariables</key>\n  <dict>\n")
+		for _, key := range keys {
+			fmt.Fprintf(&b, "    <key>%s</key>\n    <string>%s</string>\n", xmlEscape(key), xmlEscape(spec.Env[key]))
+		}
+		b.WriteString("  </dict>\n")
+	}
+
+	if spec.LogPath != "" {
+		fmt.Fprintf(&b, "  <key>StandardOutPath</key>\n  <string>%s</string>\n", xmlEscape(spec.LogPath))
+		fmt.Fprintf(&b, "  <key>StandardErrorPath</key>\n  <string>%s</string>\n", xmlEscape(spec.LogPath))
+	}
+	b.WriteString("  <key>RunAtLoad</key>\n  <true/>\n")
+	b.WriteString("  <key>KeepAlive</key>\n  <dict>\n    <key>SuccessfulExit</key>\n    <false/>\n  </dict>\n")
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// Install writes a launchd property list for the service and loads it, which
+// starts the service now and on every boot or login. It returns the path of
+// the property list.
+func Install(spec *Spec) (string, error) {
+	path, err := plistPath(spec.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	// reload an existing service so that changes take effect
+	if _, err := os.Stat(path); err == nil {
+		run("launchctl", "unload", path)
+	}
+	if err := os.WriteFile(path, []byte(plist(spec)), 0644); err != nil {
+		return "", err
+	}
+
+	_, err = run("launchctl", "load", "-w", path)
+	return path, err
+}
+
+// Uninstall unloads the service and removes its property list.
+func Uninstall(name string) error {
+	path, err := plistPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+
+	if _, err := run("launchctl", "unload", "-w", path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// GetStatus reports whether the service is installed and running.
+func GetStatus(name string) (*Status, error) {
+	path, err := plistPath(name)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Location: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return status, nil
+	}
+	status.Installed = true
+
+	output, err := run("launchctl", "list", label(name))
+	if err != nil {
+		status.Detail = "the service is not loaded"
+		return status, nil
+	}
+	// a loaded service that is running has a "PID" entry
+	status.Running = strings.Contains(output, `"PID" =`)
+	return status, nil
+}
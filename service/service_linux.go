@@ -0,0 +1,146 @@
+//go:build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unitPath returns the path of the systemd unit. Without root privileges the
+// service is installed as a user unit.
+func unitPath(name string) (string, error) {
+	if isRoot() {
+		return filepath.Join("/etc/systemd/system", name+".service"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "systemd", "user", name+".service"), nil
+}
+
+func systemctl(args ...string) (string, error) {
+	if !isRoot() {
+		args = append([]string{"--user"}, args...)
+	}
+	return run("systemctl", args...)
+}
+
+// systemdQuote quotes a word of an ExecStart line or an Environment assignment.
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\$%;") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	return `"` + s + `"`
+}
+
+func unit(spec *Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\nAfter=network-online.target\nWants=network-online.target\n\n", spec.Description)
+	fmt.Fprintf(&b, "[Service]\nType=simple\nWorkingDirectory=%s\n", systemdQuote(spec.Dir))
+
+	keys := []string{}
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(key+"="+spec.Env[key]))
+	}
+
+	words := []string{}
+	for _, arg := range spec.Args {
+		words = append(words, systemdQuote(arg))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(words, " "))
+	if spec.LogPath != "" {
+		fmt.Fprintf(&b, "StandardOutput=append:%s\nStandardError=append:%s\n", spec.LogPath, spec.LogPath)
+	}
+	b.WriteString("Restart=on-failure\nRestartSec=5\n\n")
+
+	b.WriteString("[Install]\n")
+	if isRoot() {
+		b.WriteString("WantedBy=multi-user.target\n")
+	} else {
+		b.WriteString("WantedBy=default.target\n")
+	}
+	return b.String()
+}
+
+// Install writes a systemd unit for the service, enables it and starts it.
+// It returns the path of the unit.
+func Install(spec *Spec) (string, error) {
+	path, err := unitPath(spec.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(unit(spec)), 0644); err != nil {
+		return "", err
+	}
+
+	if _, err := systemctl("daemon-reload"); err != nil {
+		return path, err
+	}
+	if _, err := systemctl("enable", "--now", spec.Name+".service"); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// Uninstall stops and disables the service and removes its unit.
+func Uninstall(name string) error {
+	path, err := unitPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+
+	if _, err := systemctl("disable", "--now", name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	_, err = systemctl("daemon-reload")
+	return err
+}
+
+// GetStatus reports whether the service is installed and running.
+func GetStatus(name string) (*Status, error) {
+	path, err := unitPath(name)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Location: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return status, nil
+	}
+	status.Installed = true
+
+	// is-active fails for inactive services, its output is the state
+	output, _ := systemctl("is-active", name+".service")
+	state := strings.TrimSpace(output)
+	status.Running = state == "active"
+	status.Detail = "systemd state: " + state
+
+	if !isRoot() {
+		if output, err := run("loginctl", "show-user", strconv.Itoa(os.Getuid()), "--property=Linger"); err == nil && strings.TrimSpace(output) != "Linger=yes" {
+			status.Detail += "\nUser services only start on boot with lingering enabled: loginctl enable-linger " + os.Getenv("USER")
+		}
+	}
+	return status, nil
+}
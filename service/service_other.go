@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Install is not supported on this platform.
+func Install(spec *Spec) (string, error) {
+	return "", fmt.Errorf("installing services is not supported on %s", runtime.GOOS)
+}
+
+// Uninstall is not supported on this platform.
+func Uninstall(name string) error {
+	return fmt.Errorf("uninstalling services is not supported on %s", runtime.GOOS)
+}
+
+// GetStatus is not supported on this platform.
+func GetStatus(name string) (*Status, error) {
+	return nil, fmt.Errorf("services are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,128 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Windows services must implement the service control protocol, which the
+// langforge binary does not. Services are therefore registered as scheduled
+// tasks that run a script at startup, or at logon without administrator
+// privileges, and that are started right away.
+
+// isRoot reports whether langforge runs with administrator privileges, in
+// which case the task runs as SYSTEM at startup.
+func isRoot() bool {
+	return exec.Command("net", "session").Run() == nil
+}
+
+// scriptPath returns the path of the script that the task runs.
+func scriptPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "langforge", "services", name+".cmd"), nil
+}
+
+func script(spec *Spec) string {
+	var b strings.Builder
+	b.WriteString("@echo off\r\n")
+	fmt.Fprintf(&b, "cd /d %s\r\n", system.QuoteCmd(spec.Dir))
+
+	keys := []string{}
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "set %s\r\n", system.QuoteCmd(key+"="+spec.Env[key]))
+	}
+
+	words := []string{}
+	for _, arg := range spec.Args {
+		words = append(words, system.QuoteCmd(arg))
+	}
+	line := strings.Join(words, " ")
+	if spec.LogPath != "" {
+		line += " >> " + system.QuoteCmd(spec.LogPath) + " 2>&1"
+	}
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
+
+// Install writes the script of the service, registers it as a scheduled task
+// and starts it. It returns the name of the task.
+func Install(spec *Spec) (string, error) {
+	path, err := scriptPath(spec.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(script(spec)), 0644); err != nil {
+		return "", err
+	}
+
+	args := []string{"/Create", "/F", "/TN", spec.Name, "/TR", `"` + path + `"`}
+	if isRoot() {
+		args = append(args, "/SC", "ONSTART", "/RU", "SYSTEM")
+	} else {
+		args = append(args, "/SC", "ONLOGON")
+	}
+	if _, err := run("schtasks", args...); err != nil {
+		return spec.Name, err
+	}
+	_, err = run("schtasks", "/Run", "/TN", spec.Name)
+	return spec.Name, err
+}
+
+// Uninstall stops the task, deletes it and removes its script.
+func Uninstall(name string) error {
+	if _, err := run("schtasks", "/Query", "/TN", name); err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+
+	// ending a task that does not run fails
+	run("schtasks", "/End", "/TN", name)
+	if _, err := run("schtasks", "/Delete", "/F", "/TN", name); err != nil {
+		return err
+	}
+
+	path, err := scriptPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GetStatus reports whether the task is registered and running.
+func GetStatus(name string) (*Status, error) {
+	status := &Status{Location: name}
+	output, err := run("schtasks", "/Query", "/TN", name, "/FO", "LIST")
+	if err != nil {
+		return status, nil
+	}
+	status.Installed = true
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if found && strings.TrimSpace(key) == "Status" {
+			value = strings.TrimSpace(value)
+			status.Running = value == "Running"
+			status.Detail = "task status: " + value
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,496 @@
+import net from "node:net";
+import path from "node:path";
+import { pathToFileURL } from "node:url";
+
+const PROTOCOL_VERSION = 3;
+
+let entry = null;
+let host = "127.0.0.1";
+let port = 2204;
+const argv = process.argv.slice(2);
+for (let i = 0; i < argv.length; i++) {
+  if (argv[i] === "--host") {
+    host = argv[++i];
+  } else if (argv[i] === "--port") {
+    port = Number(argv[++i]);
+  } else {
+    entry = argv[i];
+  }
+}
+if (!entry) {
+  console.error("usage: worker.mjs <module> [--host host] [--port port]");
+  process.exit(2);
+}
+
+// the exports of the module are the chains of the application
+const app = await import(pathToFileURL(path.resolve(entry)).href);
+
+function isChain(value) {
+  return value !== null && typeof value === "object" && typeof value.invoke === "function";
+}
+
+function findChain(name) {
+  if (name === "default" || name.startsWith("_") || !Object.hasOwn(app, name)) {
+    return null;
+  }
+  return isChain(app[name]) ? app[name] : null;
+}
+
+function inputKeys(chain) {
+  return chain.inputKeys ?? chain.first?.inputVariables ?? chain.inputVariables ?? [];
+}
+
+function outputKeys(chain) {
+  return chain.outputKeys ?? ["output"];
+}
+
+function keysSchema(title, keys) {
+  const properties = {};
+  for (const key of keys) {
+    properties[key] = { type: "string" };
+  }
+  return {
+    title,
+    type: "object",
+    properties,
+    required: [...keys],
+    additionalProperties: false,
+  };
+}
+
+// Runnables may return a string or a message instead of an object of outputs
+function outputs(chain, result) {
+  if (typeof result === "string") {
+    return { [outputKeys(chain)[0]]: result };
+  }
+  if (result !== null && typeof result === "object" && typeof result.content === "string" && !chain.outputKeys) {
+    return { output: result.content };
+  }
+  const json = {};
+  for (const [key, value] of Object.entries(result ?? {})) {
+    if (typeof value === "string") {
+      json[key] = value;
+    }
+  }
+  return json;
+}
+
+// process.env is shared by all requests, so chains with their own
+// environment variables run one at a time
+let envQueue = Promise.resolve();
+
+function withEnv(header, fn) {
+  if (!header) {
+    return fn();
+  }
+  const env = JSON.parse(Buffer.from(header, "base64").toString("utf8"));
+  const run = envQueue.then(async () => {
+    const previous = {};
+    for (const [key, value] of Object.entries(env)) {
+      previous[key] = process.env[key];
+      process.env[key] = value;
+    }
+    try {
+      return await fn();
+    } finally {
+      for (const [key, value] of Object.entries(previous)) {
+        if (value === undefined) {
+          delete process.env[key];
+        } else {
+          process.env[key] = value;
+        }
+      }
+    }
+  });
+  envQueue = run.catch(() => {});
+  return run;
+}
+
+// the properties that LLMs of different providers have for the parameters of
+// presets, of which the first an LLM has is set
+const presetProperties = {
+  temperature: ["temperature"],
+  max_tokens: ["maxTokens", "maxTokensToSample", "maxOutputTokens", "numPredict"],
+  top_p: ["topP"],
+  stop: ["stop", "stopSequences"],
+  seed: ["seed"],
+};
+
+function findLLMs(value, found = [], seen = new Set()) {
+  if (value === null || typeof value !== "object" || ArrayBuffer.isView(value) || seen.has(value)) {
+    return found;
+  }
+  seen.add(value);
+  if (typeof value._llmType === "function") {
+    found.push(value);
+  } else {
+    for (const item of Array.isArray(value) ? value : Object.values(value)) {
+      findLLMs(item, found, seen);
+    }
+  }
+  return found;
+}
+
+// chains are shared by all requests, so chains with the parameters of a
+// preset run one at a time, like chains with their own environment
+let presetQueue = Promise.resolve();
+
+function withPreset(chain, header, fn) {
+  if (!header) {
+    return fn();
+  }
+  const params = JSON.parse(Buffer.from(header, "base64").toString("utf8"));
+  const run = presetQueue.then(async () => {
+    const previous = [];
+    for (const llm of findLLMs(chain)) {
+      for (const [param, value] of Object.entries(params)) {
+        const property = (presetProperties[param] ?? []).find((name) => name in llm);
+        if (property) {
+          previous.push([llm, property, llm[property]]);
+          llm[property] = value;
+        }
+      }
+    }
+    try {
+      return await fn();
+    } finally {
+      for (const [llm, property, value] of previous.reverse()) {
+        llm[property] = value;
+      }
+    }
+  });
+  presetQueue = run.catch(() => {});
+  return run;
+}
+
+// errorBody returns the error response for an exception. Errors of provider
+// SDKs carry the status and error code of the provider, from which the gateway
+// derives a stable error code.
+function errorBody(error) {
+  const body = { error: String(error?.message ?? error), type: error?.constructor?.name ?? "Error" };
+  const status = error?.status ?? error?.response?.status;
+  if (Number.isInteger(status)) {
+    body.upstreamStatus = status;
+  }
+  const code = error?.code ?? error?.error?.code;
+  if (typeof code === "string") {
+    body.upstreamCode = code;
+  }
+  return body;
+}
+
+// requestMetadata returns the IDs that the gateway assigned to the request,
+// which are attached to the traces of the chain, e.g. in LangSmith, to
+// correlate them with the access log of the gateway.
+function requestMetadata(request) {
+  const metadata = {};
+  const requestId = request.header("X-Request-Id");
+  if (requestId) {
+    metadata.request_id = requestId;
+  }
+  const traceparent = (request.header("Traceparent") ?? "").split("-");
+  if (traceparent.length === 4) {
+    metadata.trace_id = traceparent[1];
+    metadata.parent_span_id = traceparent[2];
+  }
+  return metadata;
+}
+
+function serverSentEvent(event, value) {
+  return `event: ${event}\ndata: ${JSON.stringify(value)}\n\n`;
+}
+
+class Request {
+  constructor(connection, message) {
+    this.connection = connection;
+    this.id = message.id;
+    this.method = message.method ?? "GET";
+    this.path = new URL(message.path ?? "/", "http://worker").pathname;
+    this.headers = {};
+    for (const [key, values] of Object.entries(message.header ?? {})) {
+      if (values.length > 0) {
+        this.headers[key.toLowerCase()] = values[0];
+      }
+    }
+    this.body = Buffer.from(message.body ?? "", "base64");
+    this.attachments = message.attachments ?? [];
+    this.controller = new AbortController();
+    this.started = false;
+  }
+
+  header(name) {
+    return this.headers[name.toLowerCase()];
+  }
+
+  start(status, headers) {
+    this.started = true;
+    const header = {};
+    for (const [key, value] of Object.entries(headers)) {
+      header[key] = [value];
+    }
+    this.connection.send({ type: "response", id: this.id, status, header });
+  }
+
+  write(data) {
+    this.connection.send({ type: "chunk", id: this.id, data: Buffer.from(data).toString("base64") });
+  }
+
+  end() {
+    this.connection.send({ type: "end", id: this.id });
+  }
+
+  fail(message) {
+    this.connection.send({ type: "error", id: this.id, error: message });
+  }
+
+  respond(status, value, headers = {}) {
+    const body = Buffer.from(JSON.stringify(value));
+    this.start(status, { ...headers, "Content-Type": "application/json", "Content-Length": String(body.length) });
+    this.write(body);
+    this.end();
+  }
+}
+
+function schemas(request) {
+  const chains = [];
+  for (const name of Object.keys(app)) {
+    const chain = findChain(name);
+    if (chain) {
+      chains.push({
+        name,
+        description: "",
+        input: keysSchema(name + "Input", inputKeys(chain)),
+        output: keysSchema(name + "Output", outputKeys(chain)),
+      });
+    }
+  }
+  request.respond(200, { chains });
+}
+
+async function chat(request, name) {
+  const chain = findChain(name);
+  if (!chain) {
+    return request.respond(404, { error: `Variable ${name} not found` });
+  }
+
+  let data;
+  try {
+    data = JSON.parse(request.body.toString("utf8"));
+  } catch {
+    return request.respond(400, { error: "Invalid JSON or no JSON provided" });
+  }
+  if (data === null || typeof data !== "object" || Array.isArray(data)) {
+    return request.respond(400, { error: "JSON data should be an object" });
+  }
+
+  // images are sent as attachments, which the chain receives as data URLs
+  for (const [key, value] of Object.entries(data)) {
+    if (value !== null && typeof value === "object" && value.type === "image" && Number.isInteger(value.attachment)) {
+      const attachment = request.attachments[value.attachment];
+      if (!attachment) {
+        return request.respond(400, { error: `Invalid input ${key}` });
+      }
+      data[key] = `data:${attachment.contentType};base64,${attachment.data}`;
+    }
+  }
+
+  const keys = inputKeys(chain);
+  for (const [key, value] of Object.entries(data)) {
+    // memory is not supported, chains are shared by all requests
+    if (typeof value !== "string" || (keys.length > 0 && !keys.includes(key))) {
+      return request.respond(400, { error: `Invalid input ${key}` });
+    }
+  }
+
+  // with Accept: text/event-stream, the tokens of LLMs that stream are sent
+  // as token events followed by a result event with the outputs
+  const stream = (request.header("Accept") ?? "").includes("text/event-stream");
+  // the gateway prices the tokens by the model if they were used by a single
+  // model
+  const usage = { prompt_tokens: 0, completion_tokens: 0 };
+  const models = new Set();
+  const runModels = new Map();
+  const startLLM = (llm, input, runId, parentRunId, extraParams) => {
+    const params = extraParams?.invocation_params ?? {};
+    const model = params.model ?? params.modelName ?? params.model_name;
+    if (model) {
+      runModels.set(runId, model);
+    }
+  };
+  const callbacks = [
+    {
+      handleLLMStart: startLLM,
+      handleChatModelStart: startLLM,
+      handleLLMNewToken(token) {
+        if (stream && !request.controller.signal.aborted) {
+          request.write(serverSentEvent("token", { token }));
+        }
+      },
+      handleLLMEnd(output, runId) {
+        // OpenAI and Gemini report tokenUsage, Anthropic the usage of its API,
+        // newer integrations the usage_metadata of the messages
+        const tokens = output?.llmOutput?.tokenUsage;
+        const api = output?.llmOutput?.usage;
+        let prompt = tokens?.promptTokens ?? api?.input_tokens ?? 0;
+        let completion = tokens?.completionTokens ?? api?.output_tokens ?? 0;
+        if (!prompt && !completion) {
+          for (const generation of (output?.generations ?? []).flat()) {
+            prompt += generation?.message?.usage_metadata?.input_tokens ?? 0;
+            completion += generation?.message?.usage_metadata?.output_tokens ?? 0;
+          }
+        }
+        const model = runModels.get(runId);
+        runModels.delete(runId);
+        if (prompt || completion) {
+          usage.prompt_tokens += prompt;
+          usage.completion_tokens += completion;
+          if (model) {
+            models.add(model);
+          }
+        }
+      },
+    },
+  ];
+
+  if (stream) {
+    request.start(200, { "Content-Type": "text/event-stream", "Cache-Control": "no-cache" });
+  }
+
+  let result;
+  try {
+    result = await withEnv(request.header("X-Langforge-Env"), () =>
+      withPreset(chain, request.header("X-Langforge-Params"), () =>
+        chain.invoke(data, { callbacks, signal: request.controller.signal, metadata: requestMetadata(request) }),
+      ),
+    );
+  } catch (error) {
+    if (!stream || request.controller.signal.aborted) {
+      throw error;
+    }
+    console.error(`chain ${name} failed (request ${request.header("X-Request-Id")}):`, error);
+    request.write(serverSentEvent("error", errorBody(error)));
+    return request.end();
+  }
+  if (request.controller.signal.aborted) {
+    throw new Error("the request was canceled");
+  }
+
+  if (stream) {
+    request.write(serverSentEvent("result", outputs(chain, result)));
+    return request.end();
+  }
+
+  const headers = {};
+  if (usage.prompt_tokens || usage.completion_tokens) {
+    if (models.size === 1) {
+      usage.model = [...models][0];
+    }
+    headers["X-Langforge-Usage"] = JSON.stringify(usage);
+  }
+  request.respond(200, outputs(chain, result), headers);
+}
+
+async function handle(request) {
+  try {
+    if (request.method === "GET" && request.path === "/schemas") {
+      schemas(request);
+    } else if (request.method === "POST" && request.path.startsWith("/chat/")) {
+      await chat(request, request.path.slice("/chat/".length));
+    } else {
+      request.respond(404, { error: "Not found" });
+    }
+  } catch (error) {
+    const message = String(error?.message ?? error);
+    if (request.controller.signal.aborted) {
+      request.fail("the request was canceled");
+    } else if (request.started) {
+      console.error(`${request.method} ${request.path} failed:`, error);
+      request.fail(message);
+    } else {
+      console.error(`${request.method} ${request.path} failed:`, error);
+      request.respond(500, errorBody(error));
+    }
+  } finally {
+    request.connection.requests.delete(request.id);
+  }
+}
+
+// A connection to the langforge gateway. Messages are frames of a 4 byte big
+// endian length followed by a JSON object.
+class Connection {
+  constructor(socket) {
+    this.socket = socket;
+    this.buffer = Buffer.alloc(0);
+    this.requests = new Map();
+    this.ready = false;
+    socket.setNoDelay(true);
+    socket.on("data", (data) => this.receive(data));
+    socket.on("error", () => {});
+    socket.on("close", () => {
+      // cancel the requests of a gateway that disconnected
+      for (const request of this.requests.values()) {
+        request.controller.abort();
+      }
+    });
+  }
+
+  send(message) {
+    if (this.socket.destroyed) {
+      return;
+    }
+    const data = Buffer.from(JSON.stringify(message));
+    const header = Buffer.alloc(4);
+    header.writeUInt32BE(data.length);
+    this.socket.write(Buffer.concat([header, data]));
+  }
+
+  receive(data) {
+    this.buffer = Buffer.concat([this.buffer, data]);
+    while (this.buffer.length >= 4) {
+      const size = this.buffer.readUInt32BE(0);
+      if (this.buffer.length < 4 + size) {
+        break;
+      }
+      const message = JSON.parse(this.buffer.subarray(4, 4 + size).toString("utf8"));
+      this.buffer = this.buffer.subarray(4 + size);
+      this.dispatch(message);
+    }
+  }
+
+  dispatch(message) {
+    if (!this.ready) {
+      if (message.type !== "hello") {
+        this.socket.destroy();
+      } else if (message.version !== PROTOCOL_VERSION) {
+        this.send({
+          type: "error",
+          error: `unsupported protocol version ${message.version}, the worker implements version ${PROTOCOL_VERSION}`,
+        });
+        this.socket.end();
+      } else {
+        this.ready = true;
+        this.send({ type: "hello", version: PROTOCOL_VERSION });
+      }
+      return;
+    }
+
+    switch (message.type) {
+      case "ping":
+        this.send({ type: "pong", id: message.id });
+        break;
+      case "cancel":
+        this.requests.get(message.id)?.controller.abort();
+        break;
+      case "request": {
+        const request = new Request(this, message);
+        this.requests.set(request.id, request);
+        handle(request);
+        break;
+      }
+    }
+  }
+}
+
+net.createServer((socket) => new Connection(socket)).listen(port, host, () => {
+  console.log(`Running on ${host}, port ${port}, module ${entry}`);
+});
@@ -0,0 +1,90 @@
+package shim
+
+import (
+	_ "embed"
+	"fmt"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+//go:embed files/worker.mjs
+var nodeWorker string
+
+// Node is the shim that serves the chains exported by a JavaScript or
+// TypeScript module of a LangChain.js application.
+var Node = &Shim{
+	FileName: "worker.mjs",
+	comment:  "//",
+	body: func() (string, error) {
+		return nodeWorker, nil
+	},
+	command: nodeCommand,
+}
+
+// IsNode reports whether the entry point of an application is a JavaScript or
+// TypeScript module.
+func IsNode(entry string) bool {
+	switch strings.ToLower(filepath.Ext(entry)) {
+	case ".js", ".mjs", ".cjs", ".ts", ".mts", ".cts":
+		return true
+	}
+	return false
+}
+
+func isTypeScript(entry string) bool {
+	switch strings.ToLower(filepath.Ext(entry)) {
+	case ".ts", ".mts", ".cts":
+		return true
+	}
+	return false
+}
+
+// usesBun reports whether the project in dir is managed with bun.
+func usesBun(dir string) bool {
+	manager, _ := system.DetectNodePackageManager(dir)
+	return manager == system.Bun
+}
+
+// nodeCommand runs the shim with bun if the project uses it, otherwise with
+// Node.js. Node.js runs TypeScript entry points with the project's tsx.
+func nodeCommand(dir string, shimPath string, entry string, port int) (*exec.Cmd, error) {
+	args := []string{shimPath, entry, "--host", "127.0.0.1", "--port", strconv.Itoa(port)}
+
+	bunPath, bunErr := system.FindBun()
+	if bunErr == nil && usesBun(dir) {
+		return exec.Command(bunPath, args...), nil
+	}
+
+	nodePath, err := system.FindNode()
+	if err != nil {
+		if bunErr == nil {
+			return exec.Command(bunPath, args...), nil
+		}
+		return nil, fmt.Errorf("neither Node.js nor bun was found, install one of them to serve %s", entry)
+	}
+
+	if !isTypeScript(entry) {
+		return exec.Command(nodePath, args...), nil
+	}
+
+	tsx := filepath.Join(dir, "node_modules", ".bin", "tsx")
+	if runtime.GOOS == "windows" {
+		tsx += ".cmd"
+	}
+	if _, err := os.Stat(tsx); err == nil {
+		return exec.Command(tsx, args...), nil
+	}
+	if bunErr == nil {
+		return exec.Command(bunPath, args...), nil
+	}
+	add := "npm install --save-dev tsx"
+	if installer, err := system.FindNodePackageManager(dir); err == nil {
+		add = installer.AddCommand(true, "tsx")
+	}
+	return nil, fmt.Errorf("Node.js needs tsx to run %s, install it with '%s' or use bun", entry, add)
+}
@@ -0,0 +1,39 @@
+package shim
+
+import (
+	"langforge/python"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Python is the shim that serves the chains of a Jupyter notebook.
+var Python = &Shim{
+	FileName: "worker.py",
+	comment:  "#",
+	body:     pythonBody,
+	command: func(dir string, shimPath string, entry string, port int) (*exec.Cmd, error) {
+		return exec.Command("python", shimPath, entry, "--host", "127.0.0.1", "--port", strconv.Itoa(port)), nil
+	},
+}
+
+func pythonBody() (string, error) {
+	prompts, err := python.PromptsPy()
+	if err != nil {
+		return "", err
+	}
+	embeddings, err := python.EmbeddingsPy()
+	if err != nil {
+		return "", err
+	}
+	server, err := python.ServerPy()
+	if err != nil {
+		return "", err
+	}
+
+	// the shim lives in the state directory, but modules of the project must
+	// be importable as if the notebook ran in the project directory
+	preamble := "import os\nimport sys\nsys.path[0] = os.getcwd()\n"
+	helpers := strings.TrimSpace(string(prompts)) + "\n\n" + strings.TrimSpace(string(embeddings))
+	return preamble + "\n" + helpers + "\n\n" + strings.TrimSpace(string(server)) + "\n", nil
+}
@@ -0,0 +1,208 @@
+package shim
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"langforge/project"
+	"langforge/protocol"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Shim is the worker that langforge generates into a project. It loads the
+// chains of the application and serves them to the gateway with the worker
+// protocol. The header of the generated file records the protocol version and
+// a hash of the code, so that outdated and edited shims are detected.
+type Shim struct {
+	// FileName is the name of the shim in the project's state directory.
+	FileName string
+	// comment starts a comment line in the shim's language.
+	comment string
+	body    func() (string, error)
+	command func(dir string, shimPath string, entry string, port int) (*exec.Cmd, error)
+}
+
+// State describes the shim of a project compared to the shim that this
+// version of langforge generates.
+type State int
+
+const (
+	// Current is a shim generated by this version of langforge.
+	Current State = iota
+	// Missing means the project has no shim yet.
+	Missing
+	// Outdated is an unmodified shim of another langforge version that speaks
+	// the same protocol.
+	Outdated
+	// Modified is a shim that was edited after it was generated.
+	Modified
+	// Incompatible is a shim that speaks another protocol version than the
+	// gateway.
+	Incompatible
+)
+
+// Status is the result of checking the shim of a project.
+type Status struct {
+	State State
+	// Protocol is the protocol version of the shim.
+	Protocol int
+	// Modified is set if the shim was edited, also for incompatible shims.
+	Modified bool
+	// Generated is set if Ensure wrote the shim.
+	Generated bool
+}
+
+// For returns the shim that serves the entry point of an application: Python
+// for notebooks and Node for JavaScript and TypeScript modules.
+func For(entry string) *Shim {
+	if IsNode(entry) {
+		return Node
+	}
+	return Python
+}
+
+// RelPath returns the path of the shim relative to the project directory.
+func (s *Shim) RelPath() string {
+	return filepath.Join(project.StateDirName, s.FileName)
+}
+
+// Path returns the path of the shim of the project in dir.
+func (s *Shim) Path(dir string) string {
+	return filepath.Join(dir, s.RelPath())
+}
+
+func hashBody(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Content returns the shim that this version of langforge generates.
+func (s *Shim) Content() (string, error) {
+	body, err := s.body()
+	if err != nil {
+		return "", err
+	}
+	header := s.comment + " Generated by langforge. The file is updated with langforge unless it was\n" +
+		s.comment + " edited; regenerate it with 'langforge serve --regenerate-worker'.\n" +
+		s.comment + " langforge-protocol: " + strconv.Itoa(protocol.Version) + "\n" +
+		s.comment + " langforge-shim: " + hashBody(body) + "\n"
+	return header + body, nil
+}
+
+// Generate writes the shim of this version of langforge into the project in
+// dir, replacing an existing shim.
+func (s *Shim) Generate(dir string) error {
+	content, err := s.Content()
+	if err != nil {
+		return err
+	}
+	return s.Write(dir, content)
+}
+
+// Write writes content as the shim of the project in dir, e.g. a generated
+// shim with some of the changes of an edited one.
+func (s *Shim) Write(dir string, content string) error {
+	if _, err := project.EnsureStateDir(dir); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path(dir), []byte(content), 0644)
+}
+
+// Check compares the shim of the project in dir with the shim that this
+// version of langforge generates.
+func (s *Shim) Check(dir string) (*Status, error) {
+	data, err := os.ReadFile(s.Path(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Status{State: Missing}, nil
+		}
+		return nil, err
+	}
+
+	// the header consists of the comment lines at the start of the file
+	protocolMarker := s.comment + " langforge-protocol: "
+	hashMarker := s.comment + " langforge-shim: "
+	status := &Status{}
+	hash := ""
+	bodyStart := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, s.comment) {
+			break
+		}
+		bodyStart += len(line) + 1
+		if strings.HasPrefix(line, protocolMarker) {
+			status.Protocol, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, protocolMarker)))
+		} else if strings.HasPrefix(line, hashMarker) {
+			hash = strings.TrimSpace(strings.TrimPrefix(line, hashMarker))
+		}
+	}
+	if bodyStart > len(data) {
+		bodyStart = len(data)
+	}
+
+	status.Modified = hash == "" || hashBody(string(data[bodyStart:])) != hash
+	expected, err := s.body()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case status.Protocol != protocol.Version:
+		status.State = Incompatible
+	case status.Modified:
+		status.State = Modified
+	case hash != hashBody(expected):
+		status.State = Outdated
+	default:
+		status.State = Current
+	}
+	return status, nil
+}
+
+// Ensure generates the shim of the project in dir if it is missing or an
+// unmodified shim of another langforge version. The returned status is the
+// state before the shim was generated. Modified and incompatible shims are
+// left to the caller.
+func (s *Shim) Ensure(dir string) (*Status, error) {
+	status, err := s.Check(dir)
+	if err != nil {
+		return nil, err
+	}
+	if status.State != Missing && status.State != Outdated {
+		return status, nil
+	}
+	if err := s.Generate(dir); err != nil {
+		return nil, err
+	}
+	status.Generated = true
+	return status, nil
+}
+
+// Command returns the command that runs the shim of the project in dir for
+// the entry point of the application and listens on the loopback interface.
+func (s *Shim) Command(dir string, entry string, port int) (*exec.Cmd, error) {
+	cmd, err := s.command(dir, s.RelPath(), entry, port)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = dir
+	return cmd, nil
+}
+
+// Message describes what Ensure did, or returns an empty string.
+func (s *Shim) Message(status *Status) string {
+	if !status.Generated {
+		return ""
+	}
+	if status.State == Outdated {
+		return fmt.Sprintf("Updated the worker %s for this version of langforge.", s.RelPath())
+	}
+	return fmt.Sprintf("Generated the worker %s.", s.RelPath())
+}
@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DB is a SQLite database that is accessed with the sqlite3 command, so no
+// driver has to be compiled into langforge.
+type DB struct {
+	sqlite string
+	path   string
+}
+
+// Open returns the database at path and creates its directory if necessary.
+// The database file itself is created by the first statement.
+func Open(path string) (*DB, error) {
+	sqlite, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3 not found, install sqlite3 to use a SQLite database")
+	}
+	if err := checkVersion(sqlite); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &DB{sqlite: sqlite, path: path}, nil
+}
+
+// minVersion is the oldest version of sqlite3 with the -json output mode.
+var minVersion = [3]int{3, 33, 0}
+
+// checkedVersions caches the results of checkVersion by the path of sqlite3.
+var checkedVersions sync.Map
+
+// checkVersion fails if the sqlite3 at path is older than minVersion.
+func checkVersion(path string) error {
+	if err, ok := checkedVersions.Load(path); ok {
+		err, _ := err.(error)
+		return err
+	}
+	err := versionError(path)
+	checkedVersions.Store(path, err)
+	return err
+}
+
+func versionError(path string) error {
+	output, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		return fmt.Errorf("failed to get the version of sqlite3: %v", err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return fmt.Errorf("failed to get the version of sqlite3")
+	}
+	version := [3]int{}
+	for i, part := range strings.SplitN(fields[0], ".", 3) {
+		version[i], _ = strconv.Atoi(part)
+	}
+	for i := range version {
+		if version[i] != minVersion[i] {
+			if version[i] < minVersion[i] {
+				return fmt.Errorf("sqlite3 %s is too old, langforge needs 3.33 or newer", fields[0])
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the database file.
+func (db *DB) Path() string {
+	return db.path
+}
+
+// Exec runs SQL statements. They are passed on stdin, which is not limited
+// in size, and run in a single transaction.
+func (db *DB) Exec(sql string) error {
+	_, err := db.run("BEGIN;\n" + sql + "\nCOMMIT;")
+	return err
+}
+
+// Query runs an SQL query and decodes its rows into rows, which must be a
+// pointer to a slice of structs or maps. Columns are matched to struct
+// fields by their json tags.
+func (db *DB) Query(sql string, rows any) error {
+	output, err := db.run(sql)
+	if err != nil {
+		return err
+	}
+
+	// sqlite3 prints nothing for an empty result
+	if len(output) == 0 {
+		output = []byte("[]")
+	}
+	if err := json.Unmarshal(output, rows); err != nil {
+		return fmt.Errorf("failed to parse sqlite3 output: %v", err)
+	}
+	return nil
+}
+
+func (db *DB) run(sql string) ([]byte, error) {
+	cmd := exec.Command(db.sqlite, "-batch", "-bail", "-json", "-cmd", ".timeout 5000", db.path)
+	cmd.Stdin = strings.NewReader(sql)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3 failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return bytes.TrimSpace(output), nil
+}
+
+// Quote returns s as an SQL string literal. Strings with control characters,
+// e.g. NUL, which ends the statement for the sqlite3 shell, are written as
+// hex.
+func Quote(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return "CAST(X'" + hex.EncodeToString([]byte(s)) + "' AS TEXT)"
+		}
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileStore keeps each record in a JSON file named after its key in a
+// directory per collection.
+type fileStore struct {
+	root string
+}
+
+func newFileStore(root string) (*fileStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{root: root}, nil
+}
+
+func (s *fileStore) path(collection string, key string) (string, error) {
+	for _, name := range []string{collection, key} {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", fmt.Errorf("invalid state key %q", name)
+		}
+	}
+	return filepath.Join(s.root, collection, key+".json"), nil
+}
+
+func (s *fileStore) Get(collection string, key string, value any) error {
+	path, err := s.path(collection, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (s *fileStore) Put(collection string, key string, value any) error {
+	path, err := s.path(collection, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// write atomically, a crash must not leave a truncated record behind
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *fileStore) Delete(collection string, key string) error {
+	path, err := s.path(collection, key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) List(collection string) ([]Record, error) {
+	entries, err := os.ReadDir(filepath.Join(s.root, collection))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.root, collection, name))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, Record{Key: strings.TrimSuffix(name, ".json"), Value: data})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key < records[j].Key
+	})
+	return records, nil
+}
+
+func (s *fileStore) Collections() ([]string, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			collections = append(collections, entry.Name())
+		}
+	}
+	return collections, nil
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/sqlite"
+)
+
+// sqliteStore keeps all records in a single table of a SQLite database.
+type sqliteStore struct {
+	db *sqlite.DB
+}
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS records (
+	collection TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	updated TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (collection, key)
+);`
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%v or use the file state backend", err)
+	}
+
+	if err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+type sqliteRecord struct {
+	Collection string `json:"collection"`
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+}
+
+func (s *sqliteStore) Get(collection string, key string, value any) error {
+	rows := []sqliteRecord{}
+	err := s.db.Query(fmt.Sprintf("SELECT value FROM records WHERE collection = %s AND key = %s;",
+		sqlite.Quote(collection), sqlite.Quote(key)), &rows)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return ErrNotFound
+	}
+	return json.Unmarshal([]byte(rows[0].Value), value)
+}
+
+func (s *sqliteStore) Put(collection string, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO records (collection, key, value, updated) VALUES (%s, %s, %s, CURRENT_TIMESTAMP);",
+		sqlite.Quote(collection), sqlite.Quote(key), sqlite.Quote(string(data))))
+}
+
+func (s *sqliteStore) Delete(collection string, key string) error {
+	return s.db.Exec(fmt.Sprintf("DELETE FROM records WHERE collection = %s AND key = %s;",
+		sqlite.Quote(collection), sqlite.Quote(key)))
+}
+
+func (s *sqliteStore) List(collection string) ([]Record, error) {
+	rows := []sqliteRecord{}
+	err := s.db.Query(fmt.Sprintf("SELECT key, value FROM records WHERE collection = %s ORDER BY key;",
+		sqlite.Quote(collection)), &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, row := range rows {
+		records = append(records, Record{Key: row.Key, Value: json.RawMessage(row.Value)})
+	}
+	return records, nil
+}
+
+func (s *sqliteStore) Collections() ([]string, error) {
+	rows := []sqliteRecord{}
+	if err := s.db.Query("SELECT DISTINCT collection FROM records ORDER BY collection;", &rows); err != nil {
+		return nil, err
+	}
+
+	collections := []string{}
+	for _, row := range rows {
+		collections = append(collections, row.Collection)
+	}
+	return collections, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"langforge/project"
+	"langforge/system"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by Get if a collection has no record with the key.
+var ErrNotFound = errors.New("record not found")
+
+// Record is a JSON document stored under a key in a collection.
+type Record struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// Store persists the state of a project, e.g. job history, as JSON records
+// grouped into collections.
+type Store interface {
+	// Get reads the record with the given key into value.
+	Get(collection string, key string, value any) error
+	// Put creates or replaces the record with the given key.
+	Put(collection string, key string, value any) error
+	// Delete removes the record with the given key if it exists.
+	Delete(collection string, key string) error
+	// List returns all records of a collection ordered by key.
+	List(collection string) ([]Record, error)
+	// Collections returns the names of the collections that hold records.
+	Collections() ([]string, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Backends lists the supported state backends.
+var Backends = []string{"file", "sqlite"}
+
+// Resolve fills in defaults for the state configuration of the project in dir.
+func Resolve(dir string, config project.StateConfig) project.StateConfig {
+	if config.Backend == "" {
+		config.Backend = "file"
+	}
+	if config.Path == "" {
+		switch config.Backend {
+		case "sqlite":
+			config.Path = filepath.Join(project.StateDirName, "state.db")
+		default:
+			config.Path = filepath.Join(project.StateDirName, "state")
+		}
+	}
+	if !filepath.IsAbs(config.Path) {
+		config.Path = filepath.Join(dir, config.Path)
+	}
+	return config
+}
+
+// New opens the state store of the project in dir with the given configuration.
+func New(dir string, config project.StateConfig) (Store, error) {
+	config = Resolve(dir, config)
+	switch config.Backend {
+	case "file":
+		return newFileStore(config.Path)
+	case "sqlite":
+		return newSQLiteStore(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", config.Backend)
+	}
+}
+
+// Open opens the state store configured in the langforge.yaml file of the
+// project in dir.
+func Open(dir string) (Store, error) {
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	return New(dir, config.State)
+}
+
+// HomeEnv names the directory of the global state of the user, instead of
+// the langforge directory in the user's configuration directory.
+const HomeEnv = "LANGFORGE_HOME"
+
+// GlobalDir returns the directory of the state of the user that does not
+// belong to a project, such as the recently used projects. Every user of a
+// machine has their own.
+func GlobalDir() (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return system.NativePath(dir), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "langforge"), nil
+}
+
+// OpenGlobal opens the global state store of the user, which always uses the
+// file backend.
+func OpenGlobal() (Store, error) {
+	dir, err := GlobalDir()
+	if err != nil {
+		return nil, err
+	}
+	return newFileStore(filepath.Join(dir, "state"))
+}
+
+// Copy copies all records of src into dst and returns their number.
+func Copy(dst Store, src Store) (int, error) {
+	collections, err := src.Collections()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, collection := range collections {
+		records, err := src.List(collection)
+		if err != nil {
+			return count, err
+		}
+		for _, record := range records {
+			if err := dst.Put(collection, record.Key, record.Value); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
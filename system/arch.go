@@ -0,0 +1,85 @@
+package system
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// NormalizeArch maps the architecture names used by Go, Python and uname to
+// "amd64", "arm64" or "386". Other names are returned unchanged.
+func NormalizeArch(arch string) string {
+	switch strings.ToLower(strings.TrimSpace(arch)) {
+	case "x86_64", "amd64", "x64":
+		return "amd64"
+	case "arm64", "aarch64", "armv8", "armv8l":
+		return "arm64"
+	case "386", "x86", "i386", "i686", "win32":
+		return "386"
+	default:
+		return strings.ToLower(strings.TrimSpace(arch))
+	}
+}
+
+// IsRosetta reports whether the current process is an x86-64 binary that is
+// translated by Rosetta 2 on an Apple Silicon Mac.
+func IsRosetta() bool {
+	if runtime.GOOS != "darwin" {
+		return false
+	}
+	output, err := exec.Command("sysctl", "-n", "sysctl.proc_translated").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "1"
+}
+
+// MachineArch returns the native architecture of the machine. Unlike
+// runtime.GOARCH it reports "arm64" when langforge runs under Rosetta or the
+// x64 emulation of Windows on ARM.
+func MachineArch() string {
+	if IsRosetta() {
+		return "arm64"
+	}
+	if arch := nativeArch(); arch != "" {
+		return arch
+	}
+	return NormalizeArch(runtime.GOARCH)
+}
+
+// Emulator returns the name of the emulation layer that runs x86-64 programs
+// on ARM machines of the current operating system.
+func Emulator() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "Rosetta"
+	case "windows":
+		return "x64 emulation of Windows on ARM"
+	default:
+		return "emulation"
+	}
+}
+
+// pythonArchScript prints the architecture of the interpreter. On Windows,
+// platform.machine() of recent Python versions reports the architecture of
+// the machine even for interpreters that run under emulation, whereas the
+// platform of sysconfig, e.g. win-amd64, is the one of the build.
+const pythonArchScript = `import platform, sys, sysconfig
+if sys.platform == "win32":
+    print(sysconfig.get_platform().split("-")[-1])
+else:
+    print(platform.machine())`
+
+// PythonArch returns the architecture the given Python interpreter was built
+// for. On ARM machines this differs from MachineArch for x86-64 interpreters
+// that run under emulation.
+func PythonArch(pythonPath string) (string, error) {
+	output, err := exec.Command(pythonPath, "-c", pythonArchScript).Output()
+	if err != nil {
+		return "", err
+	}
+	return NormalizeArch(string(output)), nil
+}
+
+// DockerPlatform returns the docker platform, e.g. "linux/arm64", that matches
+// the given architecture.
+func DockerPlatform(arch string) string {
+	return "linux/" + NormalizeArch(arch)
+}
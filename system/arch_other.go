@@ -0,0 +1,9 @@
+//go:build !windows
+
+package system
+
+// nativeArch returns the architecture of Windows, which langforge is not
+// running on.
+func nativeArch() string {
+	return ""
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package system
+
+import (
+	"debug/pe"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// nativeArch returns the architecture of Windows, which differs from
+// runtime.GOARCH when an x86-64 build of langforge runs under emulation on
+// Windows on ARM.
+func nativeArch() string {
+	var processMachine, nativeMachine uint16
+	if err := windows.IsWow64Process2(windows.CurrentProcess(), &processMachine, &nativeMachine); err == nil {
+		switch nativeMachine {
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			return "arm64"
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			return "amd64"
+		case pe.IMAGE_FILE_MACHINE_I386:
+			return "386"
+		}
+	}
+	// IsWow64Process2 requires Windows 10 1709, older versions only emulate
+	// 32-bit processes
+	if arch := os.Getenv("PROCESSOR_ARCHITEW6432"); arch != "" {
+		return NormalizeArch(arch)
+	}
+	return NormalizeArch(os.Getenv("PROCESSOR_ARCHITECTURE"))
+}
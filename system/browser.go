@@ -0,0 +1,34 @@
+package system
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser opens url in the default browser of the user, without waiting
+// for it to close.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+		// WSL has no browser of its own, wslview of wslu opens the one of
+		// Windows and rundll32.exe is there without it
+		if Platform().IsWSL() {
+			if _, err := exec.LookPath("wslview"); err == nil {
+				cmd = exec.Command("wslview", url)
+			} else {
+				cmd = exec.Command("rundll32.exe", "url.dll,FileProtocolHandler", url)
+			}
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package system
+
+import "os/exec"
+
+// cmdExe returns a command that runs line with cmd.exe, which only exists on
+// Windows and in WSL.
+func cmdExe(line string) *exec.Cmd {
+	return exec.Command("cmd.exe", "/D", "/S", "/C", line)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package system
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// cmdExe returns a command that runs line with cmd.exe. The line is passed
+// verbatim, since cmd.exe does not follow the argument quoting rules that
+// os/exec applies.
+func cmdExe(line string) *exec.Cmd {
+	cmd := exec.Command("cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `/D /S /C "` + line + `"`}
+	return cmd
+}
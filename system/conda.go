@@ -0,0 +1,90 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Conda is a conda compatible package manager: conda, mamba or micromamba.
+type Conda struct {
+	// Name is "conda", "mamba" or "micromamba".
+	Name string
+	Path string
+}
+
+// FindConda returns the conda of the active conda environment, or else the
+// first of conda, mamba and micromamba in the PATH. micromamba is a single
+// binary that is often installed in containers and CI instead of conda.
+func FindConda() (*Conda, error) {
+	// conda sets CONDA_EXE and micromamba MAMBA_EXE in activated shells, even
+	// if the binary itself is not in the PATH
+	for _, variable := range []string{"CONDA_EXE", "MAMBA_EXE"} {
+		if path := os.Getenv(variable); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				return &Conda{Name: condaName(path), Path: path}, nil
+			}
+		}
+	}
+	for _, name := range []string{"conda", "mamba", "micromamba"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return &Conda{Name: name, Path: path}, nil
+		}
+	}
+	return nil, errors.New("conda, mamba or micromamba not found")
+}
+
+func condaName(path string) string {
+	name := filepath.Base(path)
+	name = name[:len(name)-len(filepath.Ext(name))]
+	switch name {
+	case "mamba", "micromamba":
+		return name
+	default:
+		return "conda"
+	}
+}
+
+// CreateCommand returns the command that creates a conda environment at
+// prefix with the given packages, e.g. "python=3.11" and "pip".
+func (c *Conda) CreateCommand(prefix string, packages ...string) *exec.Cmd {
+	args := []string{"create", "--yes", "--prefix", prefix}
+	// micromamba has no default channels
+	if c.Name == "micromamba" {
+		args = append(args, "--channel", "conda-forge")
+	}
+	return exec.Command(c.Path, append(args, packages...)...)
+}
+
+// IsCondaEnv reports whether dir is a conda environment.
+func IsCondaEnv(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "conda-meta"))
+	return err == nil && info.IsDir()
+}
+
+// ActiveCondaEnv returns the directory of the conda environment that
+// langforge was started in, or an empty string.
+func ActiveCondaEnv() string {
+	prefix := os.Getenv("CONDA_PREFIX")
+	if prefix == "" || !IsCondaEnv(prefix) {
+		return ""
+	}
+	return prefix
+}
+
+// CondaPaths returns the directories of a conda environment that activation
+// adds to the PATH.
+func CondaPaths(prefix string) []string {
+	if IsWindows() {
+		return []string{
+			prefix,
+			filepath.Join(prefix, "Library", "mingw-w64", "bin"),
+			filepath.Join(prefix, "Library", "usr", "bin"),
+			filepath.Join(prefix, "Library", "bin"),
+			filepath.Join(prefix, "Scripts"),
+			filepath.Join(prefix, "bin"),
+		}
+	}
+	return []string{filepath.Join(prefix, "bin")}
+}
@@ -0,0 +1,272 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"langforge/netcheck"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Statuses of diagnostics.
+const (
+	DiagnosticOK      = "ok"
+	DiagnosticWarning = "warning"
+	DiagnosticError   = "error"
+	DiagnosticSkipped = "skipped"
+)
+
+// Disk space below which Diagnose warns and fails: the environment of a
+// LangChain project with a local embedding model takes a few gigabytes.
+const (
+	lowDiskSpace      = 2 << 30
+	criticalDiskSpace = 500 << 20
+)
+
+// Diagnostic is the outcome of one check of Diagnose. Problem says what is
+// wrong if the status is a warning or an error, and Hint how to fix it.
+type Diagnostic struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+	Problem string `json:"problem,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// DiagnoseReport is the machine-readable report of Diagnose, e.g. for bug
+// reports and for gating CI jobs.
+type DiagnoseReport struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Platform describes the platform for users, e.g. "Ubuntu 22.04.3 LTS
+	// (WSL 2) amd64", see PlatformInfo.
+	Platform    string       `json:"platform"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// OK reports whether no check failed. Warnings do not count as failures.
+func (r *DiagnoseReport) OK() bool {
+	for _, d := range r.Diagnostics {
+		if d.Status == DiagnosticError {
+			return false
+		}
+	}
+	return true
+}
+
+// DiagnoseOptions configures Diagnose.
+type DiagnoseOptions struct {
+	// Paths are the directories that langforge writes to, e.g. the project
+	// and the directory of the global state. Their free space is checked
+	// too.
+	Paths []string
+	// Offline skips the checks of the package registries.
+	Offline bool
+	// Timeout limits each request to a registry.
+	Timeout time.Duration
+	// Env may override the URLs of the registries, see
+	// netcheck.DefaultTargets.
+	Env map[string]string
+}
+
+// Diagnose checks the tools and resources that langforge needs: the Python
+// interpreter, its venv module and installer of packages, Node.js and npm,
+// access to PyPI and npm, the free disk space and whether the paths of
+// options can be written to.
+func Diagnose(ctx context.Context, options DiagnoseOptions) *DiagnoseReport {
+	report := &DiagnoseReport{OS: runtime.GOOS, Arch: runtime.GOARCH, Platform: Platform().String()}
+	pythonPath, python := diagnosePython()
+	report.Diagnostics = append(report.Diagnostics,
+		python,
+		diagnoseVenv(pythonPath),
+		diagnosePythonInstaller(pythonPath),
+		diagnoseCommand("Node.js", "node", "Node.js runs TypeScript and JavaScript chains; install it from https://nodejs.org/ if you need them."),
+		diagnoseCommand("npm", "npm", "npm comes with Node.js, install it from https://nodejs.org/."),
+	)
+	report.Diagnostics = append(report.Diagnostics, diagnoseRegistries(ctx, options)...)
+	for _, path := range options.Paths {
+		report.Diagnostics = append(report.Diagnostics, diagnoseDiskSpace(path), diagnoseWritable(path))
+	}
+	return report
+}
+
+func diagnosePython() (string, Diagnostic) {
+	d := Diagnostic{Name: "Python", Hint: "Install Python 3.8 or newer from https://www.python.org/downloads/ or run 'langforge setup'."}
+	pythonPath, err := FindPython(DefaultPythonConstraint)
+	if errors.Is(err, ErrPythonNotFound) {
+		d.Status, d.Problem = DiagnosticError, "no Python interpreter found"
+		return "", d
+	} else if err != nil {
+		d.Status, d.Problem = DiagnosticError, err.Error()
+		return "", d
+	}
+	version, err := PythonVersion(pythonPath)
+	if err != nil {
+		d.Status, d.Problem = DiagnosticError, err.Error()
+		return "", d
+	}
+	d.Status, d.Detail, d.Hint = DiagnosticOK, fmt.Sprintf("%s (%s)", version, pythonPath), ""
+	return pythonPath, d
+}
+
+func diagnoseVenv(pythonPath string) Diagnostic {
+	d := Diagnostic{Name: "venv"}
+	if pythonPath == "" {
+		d.Status, d.Detail = DiagnosticSkipped, "no Python interpreter"
+		return d
+	}
+	// Debian and Ubuntu ship the venv module in a separate package
+	if output, err := exec.Command(pythonPath, "-c", "import venv, ensurepip").CombinedOutput(); err != nil {
+		d.Status, d.Problem = DiagnosticError, "the venv module cannot create environments: "+lastLine(output, err)
+		d.Hint = "Install the venv module of your Python, e.g. 'sudo apt-get install python3-venv' on Debian and Ubuntu."
+		return d
+	}
+	d.Status = DiagnosticOK
+	return d
+}
+
+func diagnosePythonInstaller(pythonPath string) Diagnostic {
+	d := Diagnostic{Name: "pip"}
+	if pythonPath == "" {
+		d.Status, d.Detail = DiagnosticSkipped, "no Python interpreter"
+		return d
+	}
+	installer, err := FindPythonInstaller()
+	if err != nil {
+		d.Status, d.Problem = DiagnosticError, err.Error()
+		d.Hint = fmt.Sprintf("Install pip with '%s -m ensurepip --upgrade' or install uv from https://docs.astral.sh/uv/.", pythonPath)
+		return d
+	}
+	d.Status, d.Detail = DiagnosticOK, strings.TrimSpace(installer.Name+" "+installer.Version)
+	return d
+}
+
+// diagnoseCommand checks a tool that only some projects need, so that it
+// missing is a warning.
+func diagnoseCommand(name string, command string, hint string) Diagnostic {
+	d := Diagnostic{Name: name}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		d.Status, d.Problem, d.Hint = DiagnosticWarning, command+" not found", hint
+		return d
+	}
+	output, err := exec.Command(path, "--version").CombinedOutput()
+	if err != nil {
+		d.Status, d.Problem, d.Hint = DiagnosticError, fmt.Sprintf("%s does not run: %s", path, lastLine(output, err)), hint
+		return d
+	}
+	d.Status, d.Detail = DiagnosticOK, fmt.Sprintf("%s (%s)", strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0]), path)
+	return d
+}
+
+// diagnoseRegistries checks the package registries that installs download
+// from, with the proxy settings of the environment.
+func diagnoseRegistries(ctx context.Context, options DiagnoseOptions) []Diagnostic {
+	targets := []netcheck.Target{}
+	for _, target := range netcheck.DefaultTargets(options.Env) {
+		if target.Name == "PyPI" || target.Name == "npm" {
+			targets = append(targets, target)
+		}
+	}
+	diagnostics := []Diagnostic{}
+	if options.Offline {
+		for _, target := range targets {
+			diagnostics = append(diagnostics, Diagnostic{Name: "Network: " + target.Name, Status: DiagnosticSkipped, Detail: "offline"})
+		}
+		return diagnostics
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	for _, result := range netcheck.CheckAll(ctx, targets, timeout) {
+		d := Diagnostic{Name: "Network: " + result.Target.Name, Detail: result.Target.URL}
+		if result.OK() {
+			d.Status = DiagnosticOK
+			d.Detail = fmt.Sprintf("%s (%v)", result.Target.URL, result.Latency.Round(time.Millisecond))
+		} else {
+			d.Status, d.Problem = DiagnosticError, fmt.Sprintf("%s: %v", result.Kind, result.Err)
+			d.Hint = "Run 'langforge net check' for details; set HTTPS_PROXY if you are behind a proxy."
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+func diagnoseDiskSpace(path string) Diagnostic {
+	d := Diagnostic{Name: "Disk space: " + path}
+	free, err := freeDiskSpace(existingParent(path))
+	if err != nil {
+		d.Status, d.Problem = DiagnosticWarning, err.Error()
+		return d
+	}
+	d.Detail = formatBytes(free) + " free"
+	switch {
+	case free < criticalDiskSpace:
+		d.Status, d.Problem = DiagnosticError, "only "+d.Detail
+	case free < lowDiskSpace:
+		d.Status, d.Problem = DiagnosticWarning, "only "+d.Detail
+	default:
+		d.Status = DiagnosticOK
+	}
+	if d.Problem != "" {
+		d.Hint = "Free up disk space; Python environments with LangChain and local models need a few GB."
+	}
+	return d
+}
+
+// diagnoseWritable checks that a file can be created in path, or in the
+// closest existing parent of path if langforge has not created it yet.
+func diagnoseWritable(path string) Diagnostic {
+	d := Diagnostic{Name: "Writable: " + path}
+	dir := existingParent(path)
+	file, err := os.CreateTemp(dir, ".langforge-doctor-*")
+	if err != nil {
+		d.Status, d.Problem = DiagnosticError, fmt.Sprintf("%s is not writable: %v", dir, err)
+		d.Hint = "Fix the permissions of the directory or set LANGFORGE_HOME to a writable directory."
+		return d
+	}
+	file.Close()
+	os.Remove(file.Name())
+	d.Status = DiagnosticOK
+	return d
+}
+
+// existingParent returns path or its closest parent that exists.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// lastLine returns the last line of the output of a failed command, usually
+// the error, or the error of the command if it printed nothing.
+func lastLine(output []byte, err error) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	return err.Error()
+}
+
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.0f MB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%d KB", n>>10)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package system
+
+import "syscall"
+
+// freeDiskSpace returns the bytes of the file system of path that are
+// available to unprivileged users.
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
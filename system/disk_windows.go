@@ -0,0 +1,19 @@
+//go:build windows
+
+package system
+
+import "golang.org/x/sys/windows"
+
+// freeDiskSpace returns the bytes of the volume of path that are available
+// to the user, taking quotas into account.
+func freeDiskSpace(path string) (uint64, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,115 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// dryRun is set by the global --dry-run flag of langforge.
+var dryRun bool
+
+// SetDryRun enables or disables dry runs, in which langforge prints the
+// commands that change the machine, like installs, instead of running them.
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// IsDryRun reports whether langforge runs dry, see SetDryRun.
+func IsDryRun() bool {
+	return dryRun
+}
+
+// SkipDryRun reports whether cmd must not run because langforge runs dry. It
+// then prints the command line, the working directory and the environment
+// variables that cmd sets, changes or removes compared to the environment of
+// langforge, as messages of the reporter. Commands that only read, e.g.
+// "pip list", run in dry runs too.
+func SkipDryRun(cmd *exec.Cmd) bool {
+	if !dryRun {
+		return false
+	}
+
+	reportMessage(StreamStdout, "[dry-run] "+CommandLine(cmd.Path, cmd.Args[1:]...))
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	reportMessage(StreamStdout, "          in "+dir)
+	if cmd.Env != nil {
+		for _, change := range environmentDiff(os.Environ(), cmd.Env) {
+			reportMessage(StreamStdout, "          "+change)
+		}
+	}
+	return true
+}
+
+// CommandLine returns a command line that runs the executable name with the
+// arguments in the shell of the platform, e.g. to show users what langforge
+// runs.
+func CommandLine(name string, args ...string) string {
+	quote := QuotePOSIX
+	if IsWindows() {
+		quote = func(s string) string {
+			if s != "" && !strings.ContainsAny(s, " \t\"&|<>^()%") {
+				return s
+			}
+			return QuoteCmd(s)
+		}
+	}
+	words := []string{quote(name)}
+	for _, arg := range args {
+		words = append(words, quote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// environmentDiff returns the changes from the environment before to after,
+// "+NAME=value" for added, "~NAME=value" for changed and "-NAME" for removed
+// variables, sorted by name.
+func environmentDiff(before []string, after []string) []string {
+	key := func(name string) string {
+		// environment variables are case-insensitive on Windows
+		if IsWindows() {
+			return strings.ToUpper(name)
+		}
+		return name
+	}
+	old := map[string]string{}
+	for _, entry := range before {
+		name, value, _ := strings.Cut(entry, "=")
+		old[key(name)] = value
+	}
+
+	changes := map[string]string{}
+	seen := map[string]bool{}
+	for _, entry := range after {
+		name, value, _ := strings.Cut(entry, "=")
+		seen[key(name)] = true
+		previous, ok := old[key(name)]
+		switch {
+		case !ok:
+			changes[name] = "+" + name + "=" + value
+		case previous != value:
+			changes[name] = "~" + name + "=" + value
+		}
+	}
+	for _, entry := range before {
+		name, _, _ := strings.Cut(entry, "=")
+		if !seen[key(name)] {
+			changes[name] = "-" + name
+		}
+	}
+
+	names := []string{}
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	diff := []string{}
+	for _, name := range names {
+		diff = append(diff, changes[name])
+	}
+	return diff
+}
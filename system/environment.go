@@ -0,0 +1,176 @@
+package system
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Environment is the environment that a script, such as the activate script
+// of a virtual environment, leaves behind when it is sourced in the
+// environment of langforge. Sourcing does not change the environment of
+// langforge: the result is attached to the commands that need it with
+// Environ, or applied to langforge with Apply.
+type Environment struct {
+	// Vars are the variables after the script ran.
+	Vars map[string]string
+	// Changed are the variables that the script set to a new value.
+	Changed map[string]string
+	// Removed are the variables that the script unset.
+	Removed []string
+}
+
+// shellVariables are maintained by sh itself rather than set by scripts.
+var shellVariables = map[string]bool{"_": true, "SHLVL": true, "PWD": true, "OLDPWD": true}
+
+// captureEnvironment reads the "key=value" lines that a shell printed after
+// running a script and compares them to the environment of langforge.
+func captureEnvironment(output io.Reader, ignore map[string]bool) (*Environment, error) {
+	env := &Environment{Vars: map[string]string{}, Changed: map[string]string{}}
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		// cmd.exe keeps the working directories of drives in variables
+		// like "=C:", which have no name
+		if len(parts) == 2 && parts[0] != "" {
+			env.Vars[parts[0]] = parts[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	current := environMap(os.Environ())
+	for key, value := range env.Vars {
+		if ignore[key] {
+			continue
+		}
+		if previous, ok := current[envKey(key)]; !ok || previous != value {
+			env.Changed[key] = value
+		}
+	}
+	captured := map[string]bool{}
+	for key := range env.Vars {
+		captured[envKey(key)] = true
+	}
+	for _, entry := range os.Environ() {
+		key := strings.SplitN(entry, "=", 2)[0]
+		if key != "" && !captured[envKey(key)] && !ignore[key] {
+			env.Removed = append(env.Removed, key)
+		}
+	}
+	sort.Strings(env.Removed)
+	return env, nil
+}
+
+// Environ returns the variables as "key=value" entries, sorted by key, for
+// the Env of an exec.Cmd.
+func (e *Environment) Environ() []string {
+	keys := make([]string, 0, len(e.Vars))
+	for key := range e.Vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	environ := make([]string, 0, len(keys))
+	for _, key := range keys {
+		environ = append(environ, key+"="+e.Vars[key])
+	}
+	return environ
+}
+
+// Apply makes the changes of the script in the environment of langforge and
+// the processes it starts, and returns a function that restores the previous
+// environment.
+func (e *Environment) Apply() (restore func()) {
+	type previous struct {
+		value string
+		set   bool
+	}
+	saved := map[string]previous{}
+	save := func(key string) {
+		value, set := os.LookupEnv(key)
+		saved[key] = previous{value, set}
+	}
+
+	for key, value := range e.Changed {
+		save(key)
+		Setenv(key, value)
+	}
+	for _, key := range e.Removed {
+		save(key)
+		Unsetenv(key)
+	}
+
+	return func() {
+		for key, p := range saved {
+			if p.set {
+				Setenv(key, p.value)
+			} else {
+				Unsetenv(key)
+			}
+		}
+	}
+}
+
+// MergeEnviron returns environ, a list of "key=value" entries such as
+// os.Environ(), with the variables of env set, for the Env of an exec.Cmd or
+// a Runner. Unlike os.Setenv, it leaves the environment of langforge alone,
+// so that commands with different environments can run concurrently.
+func MergeEnviron(environ []string, env map[string]string) []string {
+	overridden := map[string]bool{}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		overridden[envKey(key)] = true
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(environ)+len(keys))
+	for _, entry := range environ {
+		if !overridden[envKey(strings.SplitN(entry, "=", 2)[0])] {
+			result = append(result, entry)
+		}
+	}
+	for _, key := range keys {
+		result = append(result, key+"="+env[key])
+	}
+	return result
+}
+
+// environValue returns the value of a variable of environ; the last entry
+// wins, like in the environment of a process.
+func environValue(environ []string, key string) (string, bool) {
+	value, found := "", false
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && envKey(parts[0]) == envKey(key) {
+			value, found = parts[1], true
+		}
+	}
+	return value, found
+}
+
+// environMap returns a map of "key=value" entries, with the keys as envKey
+// returns them.
+func environMap(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			env[envKey(parts[0])] = parts[1]
+		}
+	}
+	return env
+}
+
+// envKey returns the key under which a variable is compared: the names of
+// variables are case-insensitive on Windows.
+func envKey(key string) string {
+	if IsWindows() {
+		return strings.ToUpper(key)
+	}
+	return key
+}
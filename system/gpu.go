@@ -0,0 +1,52 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The kinds of hardware that select the dependency overlays of a project.
+const (
+	HardwareGPU = "gpu"
+	HardwareCPU = "cpu"
+)
+
+// HardwareEnv overrides the detected hardware with HardwareGPU or
+// HardwareCPU, e.g. in a container build that sees no GPU but whose image
+// runs on one.
+const HardwareEnv = "LANGFORGE_HARDWARE"
+
+// DetectHardware returns HardwareGPU if the machine has an NVIDIA GPU whose
+// driver answers nvidia-smi, otherwise HardwareCPU, and a description of it
+// for messages, e.g. "NVIDIA GeForce RTX 4090". Apple Silicon GPUs need no
+// other wheels than the CPU, so Macs are CPU machines.
+func DetectHardware() (string, string, error) {
+	if hardware := strings.ToLower(strings.TrimSpace(os.Getenv(HardwareEnv))); hardware != "" {
+		if hardware != HardwareGPU && hardware != HardwareCPU {
+			return "", "", fmt.Errorf("invalid %s %q, expected %s or %s", HardwareEnv, os.Getenv(HardwareEnv), HardwareGPU, HardwareCPU)
+		}
+		return hardware, HardwareEnv + "=" + hardware, nil
+	}
+
+	// nvidia-smi hangs now and then while the driver initializes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=name", "--format=csv,noheader").Output()
+	if err != nil {
+		return HardwareCPU, "no NVIDIA GPU", nil
+	}
+	names := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return HardwareCPU, "no NVIDIA GPU", nil
+	}
+	return HardwareGPU, strings.Join(names, ", "), nil
+}
@@ -0,0 +1,122 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Names of the tools that install Python packages.
+const (
+	InstallerUv     = "uv"
+	InstallerPip    = "pip"
+	InstallerPipx   = "pipx"
+	InstallerPoetry = "poetry"
+)
+
+// InstallerEnvVar selects the installer of Python packages, e.g. "pip" to
+// keep langforge from using uv.
+const InstallerEnvVar = "LANGFORGE_INSTALLER"
+
+// PythonInstaller is a tool that installs Python packages.
+type PythonInstaller struct {
+	Name string
+	// Path is the executable of the tool. pip runs as "python -m pip", so its
+	// path is that of the Python interpreter.
+	Path    string
+	Version string
+}
+
+var installerVersionRegex = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// FindPythonInstallers returns the tools that install Python packages and are
+// available: uv, pip of the Python interpreter, pipx and poetry, in this
+// order. pipx installs applications and poetry the dependencies of its own
+// projects, so they are reported but not used by langforge.
+func FindPythonInstallers() []*PythonInstaller {
+	installers := []*PythonInstaller{}
+	for _, name := range []string{InstallerUv, InstallerPip, InstallerPipx, InstallerPoetry} {
+		if installer := findInstaller(name); installer != nil {
+			installers = append(installers, installer)
+		}
+	}
+	return installers
+}
+
+// FindPythonInstaller returns the tool that installs packages into the Python
+// environment of langforge: the first available of prefer, or uv if it is
+// available and pip otherwise, since uv installs packages many times faster.
+// LANGFORGE_INSTALLER overrides the preference.
+func FindPythonInstaller(prefer ...string) (*PythonInstaller, error) {
+	if name := os.Getenv(InstallerEnvVar); name != "" {
+		prefer = []string{name}
+	}
+	if len(prefer) == 0 {
+		prefer = []string{InstallerUv, InstallerPip}
+	}
+	for _, name := range prefer {
+		switch name {
+		case InstallerUv, InstallerPip:
+		case InstallerPipx, InstallerPoetry:
+			return nil, fmt.Errorf("%s cannot install packages into the environment of the project, use uv or pip", name)
+		default:
+			return nil, fmt.Errorf("unknown Python installer %q, expected uv or pip", name)
+		}
+		if installer := findInstaller(name); installer != nil {
+			return installer, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found", strings.Join(prefer, " or "))
+}
+
+// findInstaller returns the installer with the given name, or nil if it is
+// not available.
+func findInstaller(name string) *PythonInstaller {
+	path := ""
+	args := []string{"--version"}
+	if name == InstallerPip {
+		pythonPath, err := FindPython()
+		if err != nil {
+			return nil
+		}
+		path = pythonPath
+		args = []string{"-m", "pip", "--version"}
+	} else {
+		var err error
+		if path, err = exec.LookPath(name); err != nil {
+			return nil
+		}
+	}
+
+	// e.g. "uv 0.4.18", "pip 24.2 from ..." or "Poetry (version 1.8.3)"
+	output, err := exec.Command(path, args...).Output()
+	if err != nil {
+		return nil
+	}
+	return &PythonInstaller{
+		Name:    name,
+		Path:    path,
+		Version: installerVersionRegex.FindString(string(output)),
+	}
+}
+
+// PipCommand returns the command line that runs a pip command, e.g. "install"
+// followed by packages, for the Python interpreter at python. uv runs the
+// command with "uv pip", without the options that it does not have.
+func (i *PythonInstaller) PipCommand(python string, args ...string) (string, []string) {
+	if i.Name != InstallerUv {
+		return python, append(append([]string{"-m", "pip"}, args...), "--disable-pip-version-check")
+	}
+
+	uvArgs := []string{"pip"}
+	for _, arg := range args {
+		// uv does not ask for confirmation and only lists the environment
+		if arg == "-y" || arg == "--yes" || arg == "--local" {
+			continue
+		}
+		uvArgs = append(uvArgs, arg)
+	}
+	return i.Path, append(uvArgs, "--python", python)
+}
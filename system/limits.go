@@ -0,0 +1,53 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Limits are the resources that a process and the processes it starts may
+// use together. Zero values do not limit the resource.
+type Limits struct {
+	// Memory is the memory in bytes.
+	Memory int64
+	// CPUs is the CPU time in CPUs, e.g. 1.5 for one and a half CPUs.
+	CPUs float64
+}
+
+// IsZero reports whether the limits do not limit anything.
+func (l Limits) IsZero() bool {
+	return l.Memory == 0 && l.CPUs == 0
+}
+
+// ResourceGroup holds a process and the processes it starts within limits: a
+// cgroup on Linux and a Job Object on Windows.
+type ResourceGroup struct {
+	limits Limits
+	group  resourceGroup
+}
+
+// LimitProcess places the started command in a new resource group with the
+// given limits. The processes that the command starts are in the group too.
+// Close removes the group after the command has exited.
+func LimitProcess(cmd *exec.Cmd, limits Limits) (*ResourceGroup, error) {
+	if cmd.Process == nil {
+		return nil, fmt.Errorf("%s was not started", cmd.Path)
+	}
+	group, err := newResourceGroup(cmd.Process.Pid, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to limit the resources of %s on %s: %v", cmd.Path, runtime.GOOS, err)
+	}
+	return &ResourceGroup{limits: limits, group: group}, nil
+}
+
+// OutOfMemory reports whether a process of the group reached the memory
+// limit, after which Linux kills it and Windows fails its allocations.
+func (g *ResourceGroup) OutOfMemory() bool {
+	return g.limits.Memory > 0 && g.group.outOfMemory()
+}
+
+// Close stops the processes that are left in the group and removes it.
+func (g *ResourceGroup) Close() error {
+	return g.group.close()
+}
@@ -0,0 +1,294 @@
+//go:build linux
+
+package system
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// cgroupRoot is where the cgroup hierarchies are mounted: the unified cgroup
+// v2 hierarchy, or a directory per controller of cgroup v1.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// resourceGroup is a cgroup next to the cgroup of langforge, with a directory
+// in each hierarchy that it is limited in: one with cgroup v2, and those of
+// the memory and cpu controllers with cgroup v1.
+type resourceGroup struct {
+	paths []string
+	// memory is the directory with the memory files, if memory is limited.
+	memory string
+	v1     bool
+}
+
+var (
+	cgroupBaseOnce sync.Once
+	cgroupBase     string
+	cgroupBaseErr  error
+)
+
+func newResourceGroup(pid int, limits Limits) (resourceGroup, error) {
+	name := fmt.Sprintf("langforge-worker-%d", pid)
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return newResourceGroupV1(name, pid, limits)
+	}
+
+	cgroupBaseOnce.Do(func() {
+		cgroupBase, cgroupBaseErr = prepareCgroupBase()
+	})
+	if cgroupBaseErr != nil {
+		return resourceGroup{}, cgroupBaseErr
+	}
+	path := filepath.Join(cgroupBase, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return resourceGroup{}, err
+	}
+	g := resourceGroup{paths: []string{path}}
+	files := []string{}
+	if limits.Memory > 0 {
+		g.memory = path
+		files = append(files, "memory.max", strconv.FormatInt(limits.Memory, 10))
+	}
+	if limits.CPUs > 0 {
+		files = append(files, "cpu.max", fmt.Sprintf("%d 100000", int64(limits.CPUs*100000)))
+	}
+	// the limits apply before the process is moved into the group
+	files = append(files, "cgroup.procs", strconv.Itoa(pid))
+	for i := 0; i < len(files); i += 2 {
+		if err := os.WriteFile(filepath.Join(path, files[i]), []byte(files[i+1]), 0644); err != nil {
+			g.close()
+			return resourceGroup{}, err
+		}
+	}
+	if limits.Memory > 0 {
+		// without swap, the limit is reached instead of swapping; kernels
+		// without swap accounting have no such file
+		os.WriteFile(filepath.Join(path, "memory.swap.max"), []byte("0"), 0644)
+	}
+	return g, nil
+}
+
+// cgroupV1Setting holds the files that limit a group in the hierarchy of a
+// controller of cgroup v1, pairs of file names and values.
+type cgroupV1Setting struct {
+	controller string
+	files      []string
+}
+
+// newResourceGroupV1 creates the group in the hierarchies of the memory and
+// cpu controllers of cgroup v1, next to the cgroups of langforge.
+func newResourceGroupV1(name string, pid int, limits Limits) (resourceGroup, error) {
+	settings := []cgroupV1Setting{}
+	if limits.Memory > 0 {
+		memory := strconv.FormatInt(limits.Memory, 10)
+		settings = append(settings, cgroupV1Setting{"memory", []string{"memory.limit_in_bytes", memory}})
+	}
+	if limits.CPUs > 0 {
+		quota := strconv.FormatInt(int64(limits.CPUs*100000), 10)
+		settings = append(settings, cgroupV1Setting{"cpu", []string{"cpu.cfs_period_us", "100000", "cpu.cfs_quota_us", quota}})
+	}
+
+	g := resourceGroup{v1: true}
+	for _, setting := range settings {
+		own, err := ownCgroupV1(setting.controller)
+		if err != nil {
+			g.close()
+			return resourceGroup{}, err
+		}
+		path := filepath.Join(own, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			g.close()
+			return resourceGroup{}, fmt.Errorf("cannot create a cgroup in %s (%v); run langforge as root", own, err)
+		}
+		g.paths = append(g.paths, path)
+		if setting.controller == "memory" {
+			g.memory = path
+		}
+
+		files := append(append([]string{}, setting.files...), "cgroup.procs", strconv.Itoa(pid))
+		for i := 0; i < len(files); i += 2 {
+			if err := os.WriteFile(filepath.Join(path, files[i]), []byte(files[i+1]), 0644); err != nil {
+				g.close()
+				return resourceGroup{}, err
+			}
+		}
+		if setting.controller == "memory" {
+			// the limit of memory and swap, which must not be below the memory
+			// limit; kernels without swap accounting have no such file
+			os.WriteFile(filepath.Join(path, "memory.memsw.limit_in_bytes"), []byte(setting.files[1]), 0644)
+		}
+	}
+	return g, nil
+}
+
+// prepareCgroupBase returns the cgroup v2 that the groups of workers are
+// created in: the cgroup of langforge, with the memory and cpu controllers
+// enabled for its children. A cgroup other than the root cannot enable
+// controllers while it has processes, so langforge moves into a child of its
+// own first; this works if langforge is the only process of its cgroup, e.g.
+// when it is started with 'systemd-run --user --scope -p Delegate=yes'.
+func prepareCgroupBase() (string, error) {
+	base, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+	if err := enableControllers(base); err == nil {
+		return base, nil
+	}
+
+	leaf := filepath.Join(base, "langforge")
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return "", delegationError(base, err)
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		os.Remove(leaf)
+		return "", delegationError(base, err)
+	}
+	if err := enableControllers(base); err != nil {
+		return "", delegationError(base, err)
+	}
+	return base, nil
+}
+
+func delegationError(cgroup string, err error) error {
+	return fmt.Errorf("cannot create cgroups in %s (%v); run langforge as root or as the only process of a delegated cgroup, e.g. with 'systemd-run --user --scope -p Delegate=yes langforge serve'", cgroup, err)
+}
+
+// ownCgroup returns the directory of the cgroup v2 of langforge.
+func ownCgroup() (string, error) {
+	path, err := ownCgroupPath(func(controllers []string) bool {
+		// the unified hierarchy has the ID 0 and no controllers
+		return len(controllers) == 1 && controllers[0] == ""
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cgroupRoot, path), nil
+}
+
+// ownCgroupV1 returns the directory of the cgroup of langforge in the cgroup
+// v1 hierarchy of a controller, which is mounted at cgroupRoot/controller.
+func ownCgroupV1(controller string) (string, error) {
+	path, err := ownCgroupPath(func(controllers []string) bool {
+		for _, c := range controllers {
+			if c == controller {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return "", fmt.Errorf("the %s controller of cgroups is not available: %v", controller, err)
+	}
+	return filepath.Join(cgroupRoot, controller, path), nil
+}
+
+// ownCgroupPath returns the path of langforge in the first hierarchy of
+// /proc/self/cgroup whose controllers match, e.g. "4:memory:/user.slice".
+func ownCgroupPath(match func(controllers []string) bool) (string, error) {
+	file, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) == 3 && match(strings.Split(fields[1], ",")) {
+			return fields[2], nil
+		}
+	}
+	return "", errors.New("langforge is not in such a cgroup")
+}
+
+// enableControllers enables the memory and cpu controllers for the children
+// of the cgroup v2 at path.
+func enableControllers(path string) error {
+	data, err := os.ReadFile(filepath.Join(path, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(data))
+	missing := []string{}
+	for _, controller := range []string{"memory", "cpu"} {
+		found := false
+		for _, e := range enabled {
+			found = found || e == controller
+		}
+		if !found {
+			missing = append(missing, "+"+controller)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(path, "cgroup.subtree_control"), []byte(strings.Join(missing, " ")), 0644)
+}
+
+// outOfMemory reports whether the kernel killed a process of the group
+// because the group reached its memory limit. cgroup v1 counts the kills
+// since Linux 4.13.
+func (g resourceGroup) outOfMemory() bool {
+	if g.memory == "" {
+		return false
+	}
+	events := "memory.events"
+	if g.v1 {
+		events = "memory.oom_control"
+	}
+	data, err := os.ReadFile(filepath.Join(g.memory, events))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" && fields[1] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+func (g resourceGroup) close() error {
+	var err error
+	for _, path := range g.paths {
+		if e := removeCgroup(path, g.v1); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// removeCgroup kills the processes left in a cgroup and removes it.
+func removeCgroup(path string, v1 bool) error {
+	if v1 {
+		if data, err := os.ReadFile(filepath.Join(path, "cgroup.procs")); err == nil {
+			for _, line := range strings.Fields(string(data)) {
+				if pid, err := strconv.Atoi(line); err == nil {
+					syscall.Kill(pid, syscall.SIGKILL)
+				}
+			}
+		}
+	} else {
+		// cgroup.kill exists since Linux 5.14
+		os.WriteFile(filepath.Join(path, "cgroup.kill"), []byte("1"), 0644)
+	}
+
+	var err error
+	// killed processes leave the group shortly after
+	for i := 0; i < 40; i++ {
+		if err = os.Remove(path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+
+package system
+
+import "errors"
+
+// resourceGroup is not supported on this platform.
+type resourceGroup struct{}
+
+func newResourceGroup(pid int, limits Limits) (resourceGroup, error) {
+	return resourceGroup{}, errors.New("resource limits are only supported on Linux and Windows")
+}
+
+func (g resourceGroup) outOfMemory() bool {
+	return false
+}
+
+func (g resourceGroup) close() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build windows
+
+package system
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// resourceGroup is a Job Object that ends its processes when it is closed.
+type resourceGroup struct {
+	job windows.Handle
+}
+
+// jobObjectCPURateControlInformation is JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+// with a CPU rate, which golang.org/x/sys does not declare.
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32
+}
+
+const (
+	jobObjectCPURateControlEnable  = 0x1
+	jobObjectCPURateControlHardCap = 0x4
+)
+
+func newResourceGroup(pid int, limits Limits) (resourceGroup, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return resourceGroup{}, err
+	}
+	g := resourceGroup{job: job}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if limits.Memory > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = uintptr(limits.Memory)
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		g.close()
+		return resourceGroup{}, err
+	}
+
+	if limits.CPUs > 0 {
+		// the rate is in hundredths of a percent of all CPUs
+		rate := uint32(limits.CPUs / float64(runtime.NumCPU()) * 10000)
+		switch {
+		case rate < 1:
+			rate = 1
+		case rate > 10000:
+			rate = 10000
+		}
+		cpu := jobObjectCPURateControlInformation{
+			ControlFlags: jobObjectCPURateControlEnable | jobObjectCPURateControlHardCap,
+			CPURate:      rate,
+		}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation, uintptr(unsafe.Pointer(&cpu)), uint32(unsafe.Sizeof(cpu))); err != nil {
+			g.close()
+			return resourceGroup{}, err
+		}
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		g.close()
+		return resourceGroup{}, err
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		g.close()
+		return resourceGroup{}, err
+	}
+	return g, nil
+}
+
+// outOfMemory reports whether the processes of the job came close to the
+// memory limit. Windows fails the allocations beyond the limit instead of
+// killing a process, so the process crashes with an allocation that would
+// have exceeded it.
+func (g resourceGroup) outOfMemory() bool {
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	err := windows.QueryInformationJobObject(g.job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil)
+	if err != nil || info.JobMemoryLimit == 0 {
+		return false
+	}
+	return info.PeakJobMemoryUsed >= info.JobMemoryLimit/10*9
+}
+
+func (g resourceGroup) close() error {
+	return windows.CloseHandle(g.job)
+}
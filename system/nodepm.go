@@ -0,0 +1,196 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the Node.js package managers.
+const (
+	Npm  = "npm"
+	Yarn = "yarn"
+	Pnpm = "pnpm"
+	Bun  = "bun"
+)
+
+// NodeInstaller installs the JavaScript dependencies of a project with a
+// package manager.
+type NodeInstaller interface {
+	// Name is the name of the package manager, e.g. "pnpm".
+	Name() string
+	// Install installs the dependencies of package.json. If the project has
+	// a lockfile of the package manager, the versions of the lockfile are
+	// installed as with 'npm ci', and the install fails rather than updating
+	// a lockfile that does not match package.json.
+	Install(ctx context.Context, runner *Runner) error
+	// InstallCommand returns the command line that Install runs, to tell
+	// users how to install the dependencies.
+	InstallCommand() string
+	// Add adds packages to the dependencies of package.json, or to the dev
+	// dependencies if dev is set, and installs them.
+	Add(ctx context.Context, runner *Runner, dev bool, packages ...string) error
+	// AddExact adds packages with versions, e.g. "openai@4.67.3", to the
+	// dependencies of package.json at exactly these versions rather than
+	// ranges of compatible ones, and installs them.
+	AddExact(ctx context.Context, runner *Runner, packages ...string) error
+	// AddCommand returns the command line that Add runs, to tell users how to
+	// add packages.
+	AddCommand(dev bool, packages ...string) string
+}
+
+// nodeLockfiles are the lockfiles of the package managers, the first match
+// of which determines the package manager of a project.
+var nodeLockfiles = []struct {
+	name    string
+	manager string
+}{
+	{"pnpm-lock.yaml", Pnpm},
+	{"yarn.lock", Yarn},
+	{"bun.lockb", Bun},
+	{"bun.lock", Bun},
+	{"bunfig.toml", Bun},
+	{"package-lock.json", Npm},
+	{"npm-shrinkwrap.json", Npm},
+}
+
+// DetectNodePackageManager returns the package manager of the project in dir
+// and what it was detected from: the packageManager field of package.json,
+// e.g. "pnpm@9.1.0", or a lockfile. Projects without either use npm.
+func DetectNodePackageManager(dir string) (string, string) {
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			PackageManager string `json:"packageManager"`
+		}
+		if json.Unmarshal(data, &pkg) == nil && pkg.PackageManager != "" {
+			name := strings.SplitN(pkg.PackageManager, "@", 2)[0]
+			switch name {
+			case Npm, Yarn, Pnpm, Bun:
+				return name, "the packageManager of package.json"
+			}
+		}
+	}
+	for _, lockfile := range nodeLockfiles {
+		if _, err := os.Stat(filepath.Join(dir, lockfile.name)); err == nil {
+			return lockfile.manager, lockfile.name
+		}
+	}
+	return Npm, ""
+}
+
+// NodeInstallCommand returns the command line that installs the
+// dependencies of the project in dir with its package manager, see
+// DetectNodePackageManager, e.g. for a Dockerfile. Unlike FindNodePackageManager,
+// it does not need the package manager to be installed.
+func NodeInstallCommand(dir string) string {
+	name, _ := DetectNodePackageManager(dir)
+	return (&nodeInstaller{name: name, dir: dir}).InstallCommand()
+}
+
+// FindNodePackageManager returns the installer of the package manager that
+// the project in dir uses, see DetectNodePackageManager. Another package
+// manager would ignore the lockfile of the project, so it is an error if the
+// package manager of the project is not installed.
+func FindNodePackageManager(dir string) (NodeInstaller, error) {
+	name, source := DetectNodePackageManager(dir)
+	path, err := exec.LookPath(name)
+	if err != nil {
+		switch {
+		case source == "":
+			return nil, fmt.Errorf("npm not found, install Node.js")
+		case name == Yarn || name == Pnpm:
+			return nil, fmt.Errorf("the project uses %s according to %s, but %s is not installed; enable it with 'corepack enable'", name, source, name)
+		default:
+			return nil, fmt.Errorf("the project uses %s according to %s, but %s is not installed", name, source, name)
+		}
+	}
+	return &nodeInstaller{name: name, path: path, dir: dir}, nil
+}
+
+// nodeInstaller runs the commands of a package manager, which all take the
+// same form.
+type nodeInstaller struct {
+	name string
+	path string
+	dir  string
+}
+
+func (i *nodeInstaller) Name() string {
+	return i.name
+}
+
+func (i *nodeInstaller) Install(ctx context.Context, runner *Runner) error {
+	return runner.Run(ctx, i.path, i.installArgs()...)
+}
+
+func (i *nodeInstaller) InstallCommand() string {
+	return i.name + " " + strings.Join(i.installArgs(), " ")
+}
+
+// installArgs returns the arguments of a frozen install if the project has a
+// lockfile of the package manager, which a lockfile of another package
+// manager does not count as.
+func (i *nodeInstaller) installArgs() []string {
+	locked := false
+	for _, lockfile := range nodeLockfiles {
+		if lockfile.manager != i.name || lockfile.name == "bunfig.toml" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(i.dir, lockfile.name)); err == nil {
+			locked = true
+		}
+	}
+	switch {
+	case !locked:
+		return []string{"install"}
+	case i.name == Npm:
+		return []string{"ci"}
+	case i.name == Yarn:
+		// yarn 2 and later replaced --frozen-lockfile with --immutable
+		if _, err := os.Stat(filepath.Join(i.dir, ".yarnrc.yml")); err == nil {
+			return []string{"install", "--immutable"}
+		}
+		return []string{"install", "--frozen-lockfile"}
+	default:
+		return []string{"install", "--frozen-lockfile"}
+	}
+}
+
+func (i *nodeInstaller) Add(ctx context.Context, runner *Runner, dev bool, packages ...string) error {
+	return runner.Run(ctx, i.path, i.addArgs(dev, packages)...)
+}
+
+func (i *nodeInstaller) AddExact(ctx context.Context, runner *Runner, packages ...string) error {
+	args := i.addArgs(false, nil)
+	switch i.name {
+	case Yarn, Bun:
+		args = append(args, "--exact")
+	default:
+		args = append(args, "--save-exact")
+	}
+	return runner.Run(ctx, i.path, append(args, packages...)...)
+}
+
+func (i *nodeInstaller) AddCommand(dev bool, packages ...string) string {
+	return i.name + " " + strings.Join(i.addArgs(dev, packages), " ")
+}
+
+func (i *nodeInstaller) addArgs(dev bool, packages []string) []string {
+	args := []string{"add"}
+	if i.name == Npm {
+		args = []string{"install"}
+	}
+	if dev {
+		switch i.name {
+		case Yarn, Bun:
+			args = append(args, "--dev")
+		default:
+			args = append(args, "--save-dev")
+		}
+	}
+	return append(args, packages...)
+}
@@ -0,0 +1,180 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Step is a unit of a parallel run, e.g. installing the Python or the Node.js
+// dependencies of a project.
+type Step struct {
+	// Name identifies the step in the DependsOn of other steps and prefixes
+	// its output.
+	Name string
+	// DependsOn names the steps that must succeed before the step starts.
+	DependsOn []string
+	// Run runs the step with a runner like the one of the parallel run, whose
+	// output is prefixed with the name of the step.
+	Run func(ctx context.Context, runner *Runner) error
+}
+
+// CommandsStep returns a step that runs command lines one after the other,
+// like RunCommands.
+func CommandsStep(name string, commands []string, dependsOn ...string) Step {
+	return Step{
+		Name:      name,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, runner *Runner) error {
+			return runner.RunCommands(ctx, commands)
+		},
+	}
+}
+
+// RunParallel runs steps concurrently, at most concurrency at a time or all
+// at once if concurrency is not positive. A step starts when the steps it
+// depends on have succeeded. When a step fails, the running steps are
+// canceled, no further steps are started and the error of the failed step is
+// returned.
+func (r *Runner) RunParallel(ctx context.Context, steps []Step, concurrency int) error {
+	index, err := checkSteps(steps)
+	if err != nil {
+		return err
+	}
+	if concurrency <= 0 || concurrency > len(steps) {
+		concurrency = len(steps)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		step int
+		err  error
+	}
+	results := make(chan result)
+	started := make([]bool, len(steps))
+	succeeded := make([]bool, len(steps))
+	ready := func(step Step) bool {
+		for _, name := range step.DependsOn {
+			if !succeeded[index[name]] {
+				return false
+			}
+		}
+		return true
+	}
+	var output sync.Mutex
+
+	running := 0
+	var failure error
+	for {
+		for i, step := range steps {
+			if failure != nil || running == concurrency {
+				break
+			}
+			if started[i] || !ready(step) {
+				continue
+			}
+			started[i] = true
+			running++
+			runner := r
+			if len(steps) > 1 {
+				runner = r.prefixed(step.Name, &output)
+			}
+			go func(i int, step Step) {
+				results <- result{i, step.Run(ctx, runner)}
+			}(i, step)
+		}
+		if running == 0 {
+			return failure
+		}
+
+		res := <-results
+		running--
+		if res.err == nil {
+			succeeded[res.step] = true
+		} else if failure == nil {
+			failure = fmt.Errorf("%s failed: %w", steps[res.step].Name, res.err)
+			cancel()
+		}
+	}
+}
+
+// ExecuteCommandsParallel runs groups of shell commands concurrently, at most
+// concurrency groups at a time, and the commands of a group one after the
+// other, like ExecuteCommands. The output of a command is prefixed with the
+// number of its group.
+func ExecuteCommandsParallel(groups [][]string, dir string, concurrency int) error {
+	steps := []Step{}
+	for i, commands := range groups {
+		steps = append(steps, CommandsStep(fmt.Sprint(i+1), commands))
+	}
+	ctx, stop := InterruptContext()
+	defer stop()
+	return (&Runner{Dir: dir}).RunParallel(ctx, steps, concurrency)
+}
+
+// checkSteps verifies that the steps have unique names and depend on steps
+// that exist without cycles, and returns their indexes by name.
+func checkSteps(steps []Step) (map[string]int, error) {
+	index := map[string]int{}
+	for i, step := range steps {
+		if _, ok := index[step.Name]; ok {
+			return nil, fmt.Errorf("step %q is declared twice", step.Name)
+		}
+		index[step.Name] = i
+	}
+	for _, step := range steps {
+		for _, name := range step.DependsOn {
+			if _, ok := index[name]; !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", step.Name, name)
+			}
+		}
+	}
+
+	// 0: not visited, 1: on the current path, 2: done
+	marks := make([]int, len(steps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case 1:
+			return fmt.Errorf("step %q depends on itself", steps[i].Name)
+		case 2:
+			return nil
+		}
+		marks[i] = 1
+		for _, name := range steps[i].DependsOn {
+			if err := visit(index[name]); err != nil {
+				return err
+			}
+		}
+		marks[i] = 2
+		return nil
+	}
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// prefixed returns a copy of the runner whose output lines are prefixed with
+// the name of a step. Lines are passed on one at a time, so that the lines of
+// concurrent steps do not mix.
+func (r *Runner) prefixed(name string, output *sync.Mutex) *Runner {
+	prefix := func(callback func(line string), stream string) func(line string) {
+		return func(line string) {
+			output.Lock()
+			defer output.Unlock()
+			if callback != nil {
+				callback("[" + name + "] " + line)
+			} else {
+				report(Event{Kind: EventOutput, Stream: stream, Line: "[" + name + "] " + line})
+			}
+		}
+	}
+	runner := *r
+	runner.Stdout = prefix(r.Stdout, StreamStdout)
+	runner.Stderr = prefix(r.Stderr, StreamStderr)
+	return &runner
+}
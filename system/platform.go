@@ -0,0 +1,291 @@
+package system
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PlatformInfo describes the platform that langforge runs on, see Platform.
+type PlatformInfo struct {
+	// OS is runtime.GOOS. WSL runs the Linux build, so it is "linux" there.
+	OS string `json:"os"`
+	// Arch is the native architecture of the machine, see MachineArch.
+	Arch string `json:"arch"`
+	// WSL is 1 or 2 in the Windows Subsystem for Linux, otherwise 0.
+	WSL int `json:"wsl,omitempty"`
+	// WSLDistro is the name of the WSL distribution, e.g. "Ubuntu", as
+	// Windows knows it. It is empty if WSL did not pass it to langforge,
+	// e.g. under sudo.
+	WSLDistro string `json:"wsl_distro,omitempty"`
+	// Distro is the ID of the Linux distribution, e.g. "ubuntu", and
+	// DistroVersion its version, e.g. "22.04", from /etc/os-release.
+	Distro        string `json:"distro,omitempty"`
+	DistroVersion string `json:"distro_version,omitempty"`
+	// DistroName is the name of the distribution for users, e.g.
+	// "Ubuntu 22.04.3 LTS".
+	DistroName string `json:"distro_name,omitempty"`
+}
+
+// IsWindows reports whether the platform is Windows. WSL is not Windows.
+func (p PlatformInfo) IsWindows() bool {
+	return p.OS == "windows"
+}
+
+// IsWSL reports whether the platform is WSL 1 or WSL 2.
+func (p PlatformInfo) IsWSL() bool {
+	return p.WSL != 0
+}
+
+// IsMacOS reports whether the platform is macOS.
+func (p PlatformInfo) IsMacOS() bool {
+	return p.OS == "darwin"
+}
+
+// IsLinux reports whether the platform is Linux, including WSL.
+func (p PlatformInfo) IsLinux() bool {
+	return p.OS == "linux"
+}
+
+// String describes the platform for users, e.g. "macOS arm64" or
+// "Ubuntu 22.04.3 LTS (WSL 2) amd64".
+func (p PlatformInfo) String() string {
+	name := p.OS
+	switch {
+	case p.IsWindows():
+		name = "Windows"
+	case p.IsMacOS():
+		name = "macOS"
+	case p.IsLinux() && p.DistroName != "":
+		name = p.DistroName
+	case p.IsLinux():
+		name = "Linux"
+	}
+	if p.IsWSL() {
+		name += " (WSL " + strconv.Itoa(p.WSL) + ")"
+	}
+	return name + " " + p.Arch
+}
+
+var (
+	platformOnce sync.Once
+	platform     PlatformInfo
+)
+
+// Platform returns the platform that langforge runs on. It is detected once.
+func Platform() PlatformInfo {
+	platformOnce.Do(func() {
+		platform = PlatformInfo{OS: runtime.GOOS, Arch: MachineArch()}
+		if platform.IsLinux() {
+			platform.Distro, platform.DistroVersion, platform.DistroName = linuxDistro()
+			platform.WSL = detectWSL(kernelRelease(), os.Getenv, fileExists)
+			if platform.IsWSL() {
+				platform.WSLDistro = os.Getenv("WSL_DISTRO_NAME")
+			}
+		}
+	})
+	return platform
+}
+
+// kernelRelease returns the release of the Linux kernel, e.g.
+// "5.15.133.1-microsoft-standard-WSL2".
+func kernelRelease() string {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// detectWSL returns the version of WSL from the release of the kernel and
+// the marks that WSL leaves in the environment and the file system, or 0
+// outside of WSL. The kernel alone is not enough: containers of Docker
+// Desktop run on the kernel of WSL 2 without being in WSL, and WSL 2 can run
+// custom kernels that do not mention Microsoft.
+func detectWSL(release string, getenv func(string) string, exists func(string) bool) int {
+	marked := getenv("WSL_DISTRO_NAME") != "" || getenv("WSL_INTEROP") != "" ||
+		exists("/proc/sys/fs/binfmt_misc/WSLInterop") || exists("/run/WSL")
+	if !marked {
+		// sudo drops the variables and wsl.conf can disable interop, but
+		// wslpath is always there
+		marked = strings.Contains(strings.ToLower(release), "microsoft") &&
+			(exists("/usr/bin/wslpath") || exists("/bin/wslpath"))
+	}
+	if !marked {
+		return 0
+	}
+	// WSL 1 has no Linux kernel and reports e.g. "4.4.0-19041-Microsoft"
+	if strings.HasSuffix(release, "-Microsoft") {
+		return 1
+	}
+	return 2
+}
+
+// linuxDistro returns the ID, version and name of the Linux distribution
+// from os-release, or empty strings if it has none.
+func linuxDistro() (string, string, string) {
+	for _, name := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		file, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+		values := map[string]string{}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok || strings.HasPrefix(key, "#") {
+				continue
+			}
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else {
+				value = strings.Trim(value, `'"`)
+			}
+			values[key] = value
+		}
+		name := values["PRETTY_NAME"]
+		if name == "" {
+			name = strings.TrimSpace(values["NAME"] + " " + values["VERSION_ID"])
+		}
+		return values["ID"], values["VERSION_ID"], name
+	}
+	return "", "", ""
+}
+
+// ErrNoWSL is returned by WindowsPath and WSLPath on platforms that are
+// neither Windows nor WSL.
+var ErrNoWSL = errors.New("paths are only translated on Windows and in WSL")
+
+// WindowsPath returns the path that Windows programs use for path, e.g.
+// C:\Users\ada for /mnt/c/Users/ada or \\wsl.localhost\Ubuntu\home\ada for
+// /home/ada in WSL, so that it can be passed to a Windows program that WSL
+// runs, like explorer.exe. On Windows it returns the absolute path.
+func WindowsPath(p string) (string, error) {
+	info := Platform()
+	switch {
+	case info.IsWindows():
+		return filepath.Abs(p)
+	case !info.IsWSL():
+		return "", ErrNoWSL
+	}
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	// older versions of wslpath only translate paths that exist
+	if output, err := exec.Command("wslpath", "-w", p).Output(); err == nil {
+		if translated := strings.TrimSpace(string(output)); translated != "" {
+			return translated, nil
+		}
+	}
+	if translated, ok := wslToWindows(p, wslMountRoot(), info.WSLDistro); ok {
+		return translated, nil
+	}
+	return "", fmt.Errorf("cannot translate %s into a Windows path", p)
+}
+
+// WSLPath returns the path that programs in WSL use for path, a Windows
+// path like C:\Users\ada, e.g. /mnt/c/Users/ada, so that it can be passed to
+// a command that runs in WSL, like "wsl.exe -e python". Paths in WSL are
+// returned unchanged.
+func WSLPath(p string) (string, error) {
+	info := Platform()
+	if !info.IsWindows() && !info.IsWSL() {
+		return "", ErrNoWSL
+	}
+	if strings.HasPrefix(p, "/") {
+		return p, nil
+	}
+	var cmd *exec.Cmd
+	if info.IsWindows() {
+		cmd = exec.Command("wsl.exe", "-e", "wslpath", "-u", p)
+	} else {
+		cmd = exec.Command("wslpath", "-u", p)
+	}
+	if output, err := cmd.Output(); err == nil {
+		if translated := strings.TrimSpace(string(output)); translated != "" {
+			return translated, nil
+		}
+	}
+	root := "/mnt/"
+	if info.IsWSL() {
+		root = wslMountRoot()
+	}
+	if translated, ok := windowsToWSL(p, root); ok {
+		return translated, nil
+	}
+	return "", fmt.Errorf("cannot translate %s into a WSL path", p)
+}
+
+// wslMountRoot returns the directory that WSL mounts the Windows drives in,
+// the root of the automount section of /etc/wsl.conf, /mnt/ by default.
+func wslMountRoot() string {
+	root := "/mnt/"
+	file, err := os.Open("/etc/wsl.conf")
+	if err != nil {
+		return root
+	}
+	defer file.Close()
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "automount" && strings.TrimSpace(key) == "root" {
+			root = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	return root
+}
+
+// wslToWindows translates the absolute WSL path p into a Windows path
+// without wslpath: drives under root, e.g. /mnt/c/Users, to C:\Users and
+// other paths to the share of the distribution, if it is known.
+func wslToWindows(p string, root string, distro string) (string, bool) {
+	if rest := strings.TrimPrefix(p, root); rest != p && len(rest) > 0 && isDriveLetter(rest[0]) && (len(rest) == 1 || rest[1] == '/') {
+		drive := strings.ToUpper(rest[:1]) + ":"
+		return drive + `\` + strings.ReplaceAll(strings.TrimPrefix(rest[1:], "/"), "/", `\`), true
+	}
+	if distro == "" {
+		return "", false
+	}
+	return `\\wsl.localhost\` + distro + strings.ReplaceAll(p, "/", `\`), true
+}
+
+// windowsToWSL translates the Windows path p into a WSL path without
+// wslpath: drive paths, e.g. C:\Users, to /mnt/c/Users with root /mnt/, and
+// paths of the shares of WSL distributions, \\wsl.localhost\Ubuntu\home and
+// \\wsl$\Ubuntu\home, to /home.
+func windowsToWSL(p string, root string) (string, bool) {
+	slashed := strings.ReplaceAll(p, `\`, "/")
+	if len(slashed) >= 2 && isDriveLetter(slashed[0]) && slashed[1] == ':' {
+		return strings.TrimSuffix(root+strings.ToLower(slashed[:1])+"/"+strings.TrimPrefix(slashed[2:], "/"), "/"), true
+	}
+	for _, share := range []string{"//wsl.localhost/", "//wsl$/"} {
+		if len(slashed) > len(share) && strings.EqualFold(slashed[:len(share)], share) {
+			_, rest, _ := strings.Cut(slashed[len(share):], "/")
+			return "/" + rest, true
+		}
+	}
+	return "", false
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package system
+
+// ancestorNames returns the executable names of the parent processes of
+// langforge, which are only needed on Windows.
+func ancestorNames() []string {
+	return nil
+}
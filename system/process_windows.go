@@ -0,0 +1,45 @@
+//go:build windows
+
+package system
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxAncestors limits how many parent processes are inspected.
+const maxAncestors = 8
+
+// ancestorNames returns the executable names of the parent processes of
+// langforge, the closest first.
+func ancestorNames() []string {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil
+	}
+	defer windows.CloseHandle(snapshot)
+
+	processes := map[uint32]windows.ProcessEntry32{}
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		processes[entry.ProcessID] = entry
+	}
+
+	names := []string{}
+	// the IDs of exited parents may have been reused, which can form cycles
+	seen := map[uint32]bool{}
+	current, ok := processes[uint32(os.Getpid())]
+	for ok && len(names) < maxAncestors {
+		seen[current.ProcessID] = true
+		parent, found := processes[current.ParentProcessID]
+		if !found || parent.ProcessID == 0 || seen[parent.ProcessID] {
+			break
+		}
+		names = append(names, windows.UTF16ToString(parent.ExeFile[:]))
+		current = parent
+	}
+	return names
+}
@@ -0,0 +1,164 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultPythonConstraint is the version constraint of FindPython when none
+// is given: the oldest Python that LangChain supports.
+const DefaultPythonConstraint = ">=3.8"
+
+// ErrPythonNotFound is returned by FindPython if there is no Python
+// interpreter in the PATH or, on Windows, known to the py launcher or the
+// registry.
+var ErrPythonNotFound = errors.New("python interpreter not found")
+
+// newestPythonMinor bounds the versioned interpreters, such as "python3.12",
+// that FindPython looks for.
+const newestPythonMinor = 20
+
+// pythonCandidates returns the names of the interpreters that FindPython
+// probes, in the order of preference.
+func pythonCandidates() []string {
+	candidates := []string{"python3", "python"}
+	for minor := newestPythonMinor; minor >= 0; minor-- {
+		candidates = append(candidates, fmt.Sprintf("python3.%d", minor))
+	}
+	return candidates
+}
+
+var pythonVersions sync.Map
+
+// cachedPythonVersion returns the version of the interpreter, which is only
+// asked once per run since FindPython is called by most commands.
+func cachedPythonVersion(pythonPath string) (string, error) {
+	if version, ok := pythonVersions.Load(pythonPath); ok {
+		return version.(string), nil
+	}
+	version, err := PythonVersion(pythonPath)
+	if err != nil {
+		return "", err
+	}
+	pythonVersions.Store(pythonPath, version)
+	return version, nil
+}
+
+// ParseVersionConstraint parses a comma-separated list of version
+// requirements such as ">=3.9,<3.13" and returns a function that reports
+// whether a version satisfies all of them. The operators are >=, >, <=, <,
+// == and !=; "==3.11" and "==3.11.*" match any 3.11 release.
+func ParseVersionConstraint(constraint string) (func(version string) bool, error) {
+	type requirement struct {
+		op      string
+		version []int
+	}
+
+	requirements := []requirement{}
+	for _, clause := range strings.Split(constraint, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		op := ""
+		for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<"} {
+			if strings.HasPrefix(clause, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("invalid version constraint %q: expected one of >=, >, <=, <, == or !=", clause)
+		}
+		version := parseVersion(strings.TrimSuffix(strings.TrimSpace(clause[len(op):]), ".*"))
+		if version == nil {
+			return nil, fmt.Errorf("invalid version constraint %q", clause)
+		}
+		requirements = append(requirements, requirement{op, version})
+	}
+
+	return func(version string) bool {
+		v := parseVersion(version)
+		if v == nil {
+			return false
+		}
+		for _, r := range requirements {
+			var ok bool
+			switch r.op {
+			case ">=":
+				ok = compareVersions(v, r.version) >= 0
+			case ">":
+				ok = compareVersions(v, r.version) > 0
+			case "<=":
+				ok = compareVersions(v, r.version) <= 0
+			case "<":
+				ok = compareVersions(v, r.version) < 0
+			case "==":
+				ok = hasVersionPrefix(v, r.version)
+			case "!=":
+				ok = !hasVersionPrefix(v, r.version)
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// parseVersion parses the numeric release of a version such as "3.12.0rc1",
+// or returns nil if it does not start with a number.
+func parseVersion(version string) []int {
+	parts := []int{}
+	for _, field := range strings.Split(version, ".") {
+		digits := 0
+		for digits < len(field) && field[digits] >= '0' && field[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 {
+			break
+		}
+		n, err := strconv.Atoi(field[:digits])
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+		if digits < len(field) {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return parts
+}
+
+// compareVersions compares two releases, padding the shorter one with zeros.
+func compareVersions(a []int, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := 0, 0
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func hasVersionPrefix(version []int, prefix []int) bool {
+	if len(version) < len(prefix) {
+		version = append(version, make([]int, len(prefix)-len(version))...)
+	}
+	return compareVersions(version[:len(prefix)], prefix) == 0
+}
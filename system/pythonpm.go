@@ -0,0 +1,142 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// InstallerPipenv is the name of pipenv, which manages the dependencies of
+// projects with a Pipfile.
+const InstallerPipenv = "pipenv"
+
+// pythonLockfiles are the lockfiles of the tools that manage the dependencies
+// of Python projects, the first match of which determines the tool of a
+// project.
+var pythonLockfiles = []struct {
+	name    string
+	manager string
+}{
+	{"poetry.lock", InstallerPoetry},
+	{"uv.lock", InstallerUv},
+	{"Pipfile.lock", InstallerPipenv},
+}
+
+// PythonProjectManager is the tool that manages the dependencies of a Python
+// project with a lockfile: poetry, uv or pipenv. Installing the project with
+// pip would ignore the versions of the lockfile, and adding packages with pip
+// would leave them out of it, so that the next install of the tool removes
+// them again.
+type PythonProjectManager struct {
+	Name string
+	Path string
+	// Lockfile is the lockfile that the tool was detected from.
+	Lockfile string
+	// Extra is the optional dependency group that Install installs along
+	// with the dependencies, e.g. the GPU overlay of the project: an extra
+	// of uv and poetry or a category of pipenv.
+	Extra string
+}
+
+// DetectPythonProjectManager returns the tool that manages the dependencies
+// of the Python project in dir and its lockfile, or empty strings if the
+// project has no lockfile.
+func DetectPythonProjectManager(dir string) (string, string) {
+	for _, lockfile := range pythonLockfiles {
+		if _, err := os.Stat(filepath.Join(dir, lockfile.name)); err == nil {
+			return lockfile.manager, lockfile.name
+		}
+	}
+	return "", ""
+}
+
+// FindPythonProjectManager returns the tool that manages the dependencies of
+// the Python project in dir, or nil if the project has no lockfile. It is an
+// error if the tool of the lockfile is not installed.
+func FindPythonProjectManager(dir string) (*PythonProjectManager, error) {
+	name, lockfile := DetectPythonProjectManager(dir)
+	if name == "" {
+		return nil, nil
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("the project uses %s according to %s, but %s is not installed", name, lockfile, name)
+	}
+	return &PythonProjectManager{Name: name, Path: path, Lockfile: lockfile}, nil
+}
+
+// Install installs the dependencies of the project at the versions of the
+// lockfile. It fails rather than updating the lockfile if the lockfile does
+// not match the dependencies of the project, e.g. after pyproject.toml was
+// edited by hand.
+func (m *PythonProjectManager) Install(ctx context.Context, runner *Runner) error {
+	return m.run(ctx, runner, m.installArgs())
+}
+
+// InstallCommand returns the command line that Install runs, to tell users
+// how to install the project.
+func (m *PythonProjectManager) InstallCommand() string {
+	words := []string{m.Name}
+	for _, arg := range m.installArgs() {
+		words = append(words, QuotePOSIX(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+func (m *PythonProjectManager) installArgs() []string {
+	switch {
+	case m.Name == InstallerPoetry && m.Extra != "":
+		return []string{"install", "--no-root", "--extras", m.Extra}
+	case m.Name == InstallerPoetry:
+		// the dependencies, not the project itself, which is rarely a package
+		return []string{"install", "--no-root"}
+	case m.Name == InstallerUv && m.Extra != "":
+		return []string{"sync", "--locked", "--extra", m.Extra}
+	case m.Name == InstallerUv:
+		return []string{"sync", "--locked"}
+	case m.Extra != "":
+		// the default category of the Pipfile is packages
+		return []string{"install", "--deploy", "--categories", "packages " + m.Extra}
+	default:
+		return []string{"install", "--deploy"}
+	}
+}
+
+// Add adds packages, e.g. "openai==1.51.2", to the dependencies of the
+// project and its lockfile, and installs them.
+func (m *PythonProjectManager) Add(ctx context.Context, runner *Runner, packages ...string) error {
+	args := []string{"add"}
+	if m.Name == InstallerPipenv {
+		args = []string{"install"}
+	}
+	return m.run(ctx, runner, append(args, packages...))
+}
+
+// Remove removes packages from the dependencies of the project and its
+// lockfile, and uninstalls them.
+func (m *PythonProjectManager) Remove(ctx context.Context, runner *Runner, packages ...string) error {
+	args := []string{"remove"}
+	if m.Name == InstallerPipenv {
+		args = []string{"uninstall"}
+	}
+	return m.run(ctx, runner, append(args, packages...))
+}
+
+// run runs the tool in the active virtual environment, e.g. the project
+// environment of langforge. Poetry and pipenv use an active environment on
+// their own, uv only the environment of UV_PROJECT_ENVIRONMENT.
+func (m *PythonProjectManager) run(ctx context.Context, runner *Runner, args []string) error {
+	if active := os.Getenv("VIRTUAL_ENV"); m.Name == InstallerUv && active != "" {
+		r := *runner
+		env := r.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		r.Env = append(append([]string{}, env...), "UV_PROJECT_ENVIRONMENT="+active)
+		runner = &r
+	}
+	return runner.Run(ctx, m.Path, args...)
+}
@@ -0,0 +1,74 @@
+package system
+
+import (
+	"strings"
+)
+
+// QuotePOSIX quotes s for use as a single word in a POSIX shell command line.
+// The result is safe for paths with spaces, parentheses, quotes and unicode.
+func QuotePOSIX(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, needsPOSIXQuoting) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func needsPOSIXQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("-_./:=@%+,", r):
+		return false
+	default:
+		return true
+	}
+}
+
+// QuoteFish quotes s for use as a single word in a fish command line. Unlike
+// in POSIX shells, backslashes and single quotes are escaped with a backslash
+// inside single quotes.
+func QuoteFish(s string) string {
+	if s != "" && strings.IndexFunc(s, needsPOSIXQuoting) < 0 {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// QuoteCmd quotes s for use as a single word in a cmd.exe command line, e.g.
+// "C:\Program Files (x86)\app\activate.bat". Parentheses, ampersands and
+// carets are literal inside double quotes; embedded double quotes are
+// doubled. Backslashes before a double quote or the closing quote are
+// doubled as well, so that programs do not take them for the escape of the
+// quote, see SplitCmd. Percent signs cannot be escaped inside quotes and are
+// expanded by cmd.exe if they enclose the name of an environment variable.
+func QuoteCmd(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for _, r := range s {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			b.WriteString(strings.Repeat(`\`, 2*backslashes))
+			b.WriteString(`""`)
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			b.WriteRune(r)
+		}
+		backslashes = 0
+	}
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// QuotePowerShell quotes s as a verbatim PowerShell string, so that neither
+// variables nor backticks in it are interpreted.
+func QuotePowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,136 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+// quoteCases are the arguments that the quoting functions of the shells are
+// tested with, together with their quoted forms.
+var quoteCases = []struct {
+	name       string
+	arg        string
+	posix      string
+	fish       string
+	cmd        string
+	powershell string
+}{
+	{"empty", "", `''`, `''`, `""`, `''`},
+	{"plain", "requests==2.31.0", `requests==2.31.0`, `requests==2.31.0`, `"requests==2.31.0"`, `'requests==2.31.0'`},
+	{"space", "my package", `'my package'`, `'my package'`, `"my package"`, `'my package'`},
+	{"program files", `C:\Program Files (x86)\app\activate.bat`, `'C:\Program Files (x86)\app\activate.bat'`, `'C:\\Program Files (x86)\\app\\activate.bat'`, `"C:\Program Files (x86)\app\activate.bat"`, `'C:\Program Files (x86)\app\activate.bat'`},
+	{"percent", "%PATH%", `%PATH%`, `%PATH%`, `"%PATH%"`, `'%PATH%'`},
+	{"caret", "a^b", `'a^b'`, `'a^b'`, `"a^b"`, `'a^b'`},
+	{"exclamation", "hello!", `'hello!'`, `'hello!'`, `"hello!"`, `'hello!'`},
+	{"ampersand", "a&b|c", `'a&b|c'`, `'a&b|c'`, `"a&b|c"`, `'a&b|c'`},
+	{"double quote", `say "hi"`, `'say "hi"'`, `'say "hi"'`, `"say ""hi"""`, `'say "hi"'`},
+	{"single quote", "it's", `'it'\''s'`, `'it\'s'`, `"it's"`, `'it''s'`},
+	{"dollar", "$HOME`id`", `'$HOME` + "`id`" + `'`, `'$HOME` + "`id`" + `'`, `"$HOME` + "`id`" + `"`, `'$HOME` + "`id`" + `'`},
+	{"trailing backslash", `C:\dir\`, `'C:\dir\'`, `'C:\\dir\\'`, `"C:\dir\\"`, `'C:\dir\'`},
+	{"backslash before quote", `a\"b`, `'a\"b'`, `'a\\"b'`, `"a\\""b"`, `'a\"b'`},
+	{"unicode", "naïve 名前", `'naïve 名前'`, `'naïve 名前'`, `"naïve 名前"`, `'naïve 名前'`},
+}
+
+func TestQuote(t *testing.T) {
+	for _, c := range quoteCases {
+		if got := QuotePOSIX(c.arg); got != c.posix {
+			t.Errorf("%s: QuotePOSIX(%q) = %s, want %s", c.name, c.arg, got, c.posix)
+		}
+		if got := QuoteFish(c.arg); got != c.fish {
+			t.Errorf("%s: QuoteFish(%q) = %s, want %s", c.name, c.arg, got, c.fish)
+		}
+		if got := QuoteCmd(c.arg); got != c.cmd {
+			t.Errorf("%s: QuoteCmd(%q) = %s, want %s", c.name, c.arg, got, c.cmd)
+		}
+		if got := QuotePowerShell(c.arg); got != c.powershell {
+			t.Errorf("%s: QuotePowerShell(%q) = %s, want %s", c.name, c.arg, got, c.powershell)
+		}
+	}
+}
+
+// TestQuoteRoundTrip checks that the words quoted for a shell are split back
+// into the same arguments.
+func TestQuoteRoundTrip(t *testing.T) {
+	args := []string{"pip"}
+	for _, c := range quoteCases {
+		args = append(args, c.arg)
+	}
+
+	posix := QuotePOSIX(args[0])
+	cmd := QuoteCmd(args[0])
+	for _, arg := range args[1:] {
+		posix += " " + QuotePOSIX(arg)
+		cmd += " " + QuoteCmd(arg)
+	}
+
+	if got, err := SplitPOSIX(posix); err != nil {
+		t.Errorf("SplitPOSIX(%s) failed: %v", posix, err)
+	} else if !reflect.DeepEqual(got, args) {
+		t.Errorf("SplitPOSIX(%s) = %q, want %q", posix, got, args)
+	}
+	if got, err := SplitCmd(cmd); err != nil {
+		t.Errorf("SplitCmd(%s) failed: %v", cmd, err)
+	} else if !reflect.DeepEqual(got, args) {
+		t.Errorf("SplitCmd(%s) = %q, want %q", cmd, got, args)
+	}
+}
+
+func TestSplitCmd(t *testing.T) {
+	cases := []struct {
+		line string
+		args []string
+	}{
+		{`pip install "C:\Program Files\pkg"`, []string{"pip", "install", `C:\Program Files\pkg`}},
+		{`echo ""`, []string{"echo", ""}},
+		{`echo a^&b`, []string{"echo", "a&b"}},
+		{`echo "a^b"`, []string{"echo", "a^b"}},
+		{`echo %PATH% !x!`, []string{"echo", "%PATH%", "!x!"}},
+		{`echo \\server\share`, []string{"echo", `\\server\share`}},
+		{`echo "C:\dir\\"`, []string{"echo", `C:\dir\`}},
+		{`echo \"quoted\"`, []string{"echo", `"quoted"`}},
+		{`echo "say ""hi"""`, []string{"echo", `say "hi"`}},
+	}
+	for _, c := range cases {
+		got, err := SplitCmd(c.line)
+		if err != nil {
+			t.Errorf("SplitCmd(%s) failed: %v", c.line, err)
+		} else if !reflect.DeepEqual(got, c.args) {
+			t.Errorf("SplitCmd(%s) = %q, want %q", c.line, got, c.args)
+		}
+	}
+
+	for _, line := range []string{`echo a | more`, `echo a > out.txt`, `echo "open`, `echo a^`} {
+		if _, err := SplitCmd(line); err == nil {
+			t.Errorf("SplitCmd(%s) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestSplitPOSIX(t *testing.T) {
+	cases := []struct {
+		line string
+		args []string
+	}{
+		{`pip install "my package" --target '/tmp/a b'`, []string{"pip", "install", "my package", "--target", "/tmp/a b"}},
+		{`echo '' ""`, []string{"echo", "", ""}},
+		{`echo a\ b`, []string{"echo", "a b"}},
+		{`echo "a \"b\" \\ c"`, []string{"echo", `a "b" \ c`}},
+		{`echo 'it'\''s'`, []string{"echo", "it's"}},
+		{`echo %PATH% ^ !x`, []string{"echo", "%PATH%", "^", "!x"}},
+		{`echo a # comment`, []string{"echo", "a"}},
+	}
+	for _, c := range cases {
+		got, err := SplitPOSIX(c.line)
+		if err != nil {
+			t.Errorf("SplitPOSIX(%s) failed: %v", c.line, err)
+		} else if !reflect.DeepEqual(got, c.args) {
+			t.Errorf("SplitPOSIX(%s) = %q, want %q", c.line, got, c.args)
+		}
+	}
+
+	for _, line := range []string{`echo a | more`, `echo $HOME`, "echo `id`", `echo "$HOME"`, `echo 'open`, `echo a\`} {
+		if _, err := SplitPOSIX(line); err == nil {
+			t.Errorf("SplitPOSIX(%s) succeeded, want an error", line)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Kinds of the events that the operations of the system package report.
+const (
+	// EventCommandStart is reported before a command runs, e.g. an install
+	// of a Runner or a sourced script, and EventCommandEnd after it exited.
+	// Commands that only read versions or paths are not reported.
+	EventCommandStart = "command_start"
+	EventCommandEnd   = "command_end"
+	// EventOutput is a line of the output of a command.
+	EventOutput = "output"
+	// EventEnvChange is a change of an environment variable of langforge,
+	// e.g. when a virtual environment is activated.
+	EventEnvChange = "env_change"
+	// EventProgress is the progress of a download.
+	EventProgress = "progress"
+	// EventMessage is a message for the user, e.g. that a failed command is
+	// retried or what a dry run would run.
+	EventMessage = "message"
+)
+
+// The streams of output events and messages.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Event is something that an operation of the system package did. Which
+// fields are set depends on the kind of the event.
+type Event struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// Command is the command line of command events and of the command that
+	// wrote an output line, empty for the prefixed lines of parallel steps.
+	Command string `json:"command,omitempty"`
+	// Dir is the working directory of a command.
+	Dir string `json:"dir,omitempty"`
+	// Stream is StreamStdout or StreamStderr for output events and messages.
+	Stream string `json:"stream,omitempty"`
+	// Line is a line of output or a message, without the line ending.
+	Line string `json:"line,omitempty"`
+	// Error is why the command of a command end event failed, empty if it
+	// succeeded, and Duration how long it ran.
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	// Key is the variable of an env change event and Value its new value,
+	// unless Unset reports that it was removed.
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	Unset bool   `json:"unset,omitempty"`
+	// Name is what a progress event downloads, of which Done of Total bytes
+	// are done. Total is -1 if the size is not known.
+	Name  string `json:"name,omitempty"`
+	Done  int64  `json:"done,omitempty"`
+	Total int64  `json:"total,omitempty"`
+}
+
+// Reporter receives the events of the system package, e.g. to show the
+// installs of langforge in the UI of an application that embeds it. Report
+// is called from different goroutines.
+type Reporter interface {
+	Report(event Event)
+}
+
+// ConsoleReporter writes output lines and messages to Stdout and Stderr and
+// ignores the other events. It is the reporter of langforge unless
+// SetReporter replaces it. If Stdout and Stderr are files, commands write to
+// them directly, so that e.g. pip shows its progress bars in a terminal.
+type ConsoleReporter struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	mu     sync.Mutex
+}
+
+// Report writes output lines and messages.
+func (c *ConsoleReporter) Report(event Event) {
+	if event.Kind != EventOutput && event.Kind != EventMessage {
+		return
+	}
+	w := c.Stdout
+	if event.Stream == StreamStderr {
+		w = c.Stderr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintln(w, event.Line)
+}
+
+// files returns the files that commands write their output to directly, or
+// nil if Stdout or Stderr is not a file.
+func (c *ConsoleReporter) files() (*os.File, *os.File) {
+	stdout, _ := c.Stdout.(*os.File)
+	stderr, _ := c.Stderr.(*os.File)
+	if stdout == nil || stderr == nil {
+		return nil, nil
+	}
+	return stdout, stderr
+}
+
+// JSONReporter writes every event as a line of JSON to W, e.g. for a process
+// that runs langforge and shows its progress.
+type JSONReporter struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Report writes the event.
+func (j *JSONReporter) Report(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// command lines are easier to read without the escapes of HTML
+	encoder := json.NewEncoder(j.W)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(event)
+}
+
+var (
+	reporterMu sync.RWMutex
+	reporter   Reporter = &ConsoleReporter{Stdout: os.Stdout, Stderr: os.Stderr}
+)
+
+// SetReporter sets the reporter that the system package reports its events
+// to. nil restores the console reporter of langforge.
+func SetReporter(r Reporter) {
+	if r == nil {
+		r = &ConsoleReporter{Stdout: os.Stdout, Stderr: os.Stderr}
+	}
+	reporterMu.Lock()
+	defer reporterMu.Unlock()
+	reporter = r
+}
+
+func currentReporter() Reporter {
+	reporterMu.RLock()
+	defer reporterMu.RUnlock()
+	return reporter
+}
+
+// report passes an event to the reporter.
+func report(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	currentReporter().Report(event)
+}
+
+// reportMessage reports a message for the user.
+func reportMessage(stream string, message string) {
+	report(Event{Kind: EventMessage, Stream: stream, Line: message})
+}
+
+// ReportMessage reports a message for the user, e.g. a warning of another
+// package of langforge, so that it reaches the reporter like the messages of
+// the system package.
+func ReportMessage(stream string, message string) {
+	reportMessage(stream, message)
+}
+
+// commandFiles returns the files that commands write their output to
+// directly, or nil if the reporter receives their output as events.
+func commandFiles() (*os.File, *os.File) {
+	if console, ok := currentReporter().(*ConsoleReporter); ok {
+		return console.files()
+	}
+	return nil, nil
+}
+
+// reportLines returns a callback that reports the lines of a stream of a
+// command as output events.
+func reportLines(command string, stream string) func(line string) {
+	return func(line string) {
+		report(Event{Kind: EventOutput, Command: command, Stream: stream, Line: line})
+	}
+}
+
+// reportCommand reports the start of cmd and returns a function that reports
+// its end with the error it exited with.
+func reportCommand(cmd *exec.Cmd) func(err error) {
+	command := CommandLine(cmd.Path, cmd.Args[1:]...)
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	start := time.Now()
+	report(Event{Kind: EventCommandStart, Time: start, Command: command, Dir: dir})
+	return func(err error) {
+		end := Event{Kind: EventCommandEnd, Command: command, Dir: dir, Duration: time.Since(start)}
+		if err != nil {
+			end.Error = err.Error()
+		}
+		report(end)
+	}
+}
+
+// stderrWriter returns where a command that is not run by a Runner writes
+// its stderr: the stderr of the console, or a writer that reports its lines,
+// and a function that reports the last line after the command exited.
+func stderrWriter(cmd *exec.Cmd) (io.Writer, func()) {
+	if _, stderr := commandFiles(); stderr != nil {
+		return stderr, func() {}
+	}
+	lines := newLineWriter(reportLines(CommandLine(cmd.Path, cmd.Args[1:]...), StreamStderr))
+	return lines, lines.flush
+}
+
+// Setenv sets an environment variable of langforge like os.Setenv and
+// reports the change, if the value changes.
+func Setenv(key string, value string) error {
+	if previous, ok := os.LookupEnv(key); ok && previous == value {
+		return nil
+	}
+	if err := os.Setenv(key, value); err != nil {
+		return err
+	}
+	report(Event{Kind: EventEnvChange, Key: key, Value: value})
+	return nil
+}
+
+// Unsetenv removes an environment variable of langforge like os.Unsetenv
+// and reports the change, if the variable was set.
+func Unsetenv(key string) error {
+	if _, ok := os.LookupEnv(key); !ok {
+		return nil
+	}
+	if err := os.Unsetenv(key); err != nil {
+		return err
+	}
+	report(Event{Kind: EventEnvChange, Key: key, Unset: true})
+	return nil
+}
+
+// progressInterval is the least time between the progress events of a
+// download.
+const progressInterval = 100 * time.Millisecond
+
+// progressReader reports the bytes read from a download.
+type progressReader struct {
+	r      io.Reader
+	name   string
+	total  int64
+	done   int64
+	last   time.Time
+	closed bool
+}
+
+// ProgressReader returns a reader of r, the content of a download of name
+// with total bytes, -1 if not known, that reports the progress of the
+// download when it begins, at most every 100ms while it is read, and when r
+// ends.
+func ProgressReader(name string, total int64, r io.Reader) io.Reader {
+	if total < 0 {
+		total = -1
+	}
+	p := &progressReader{r: r, name: name, total: total, last: time.Now()}
+	p.report()
+	return p
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if err == io.EOF && !p.closed {
+		p.closed = true
+		p.report()
+	} else if n > 0 && time.Since(p.last) >= progressInterval {
+		p.last = time.Now()
+		p.report()
+	}
+	return n, err
+}
+
+func (p *progressReader) report() {
+	report(Event{Kind: EventProgress, Name: p.name, Done: p.done, Total: p.total})
+}
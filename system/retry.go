@@ -0,0 +1,95 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy retries the commands of a Runner that fail, e.g. installs that
+// fail because a package registry did not answer.
+type RetryPolicy struct {
+	// Attempts is how often a command runs at most, including the first run.
+	Attempts int
+	// Backoff is the wait before the second run, which doubles before each
+	// further run.
+	Backoff time.Duration
+	// RetryOn decides from the error and the last lines of the output of a
+	// failed run whether to run the command again. If nil, all failures are
+	// retried.
+	RetryOn func(err error, output []string) bool
+}
+
+// InstallRetry is the policy of the commands that install packages from a
+// registry, like pip and npm: three runs, if a run failed because of the
+// network.
+var InstallRetry = RetryPolicy{
+	Attempts: 3,
+	Backoff:  2 * time.Second,
+	RetryOn:  RetryOnNetworkErrors,
+}
+
+// networkErrors are parts of the messages of pip, uv, npm, yarn, pnpm and bun
+// for failures that another try may not have, in lower case.
+var networkErrors = []string{
+	"connectionerror",
+	"readtimeouterror",
+	"connection reset",
+	"connection refused",
+	"connection aborted",
+	"temporary failure in name resolution",
+	"max retries exceeded",
+	"timed out",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+	"error sending request",
+	"failed to fetch",
+	"etimedout",
+	"esockettimedout",
+	"econnreset",
+	"econnrefused",
+	"eai_again",
+	"socket hang up",
+	"err_socket_timeout",
+	"err_pnpm_meta_fetch_fail",
+}
+
+// RetryOnNetworkErrors reports whether a command failed because of the
+// network, judged by its output, or ran into its timeout.
+func RetryOnNetworkErrors(err error, output []string) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	for _, line := range output {
+		line = strings.ToLower(line)
+		for _, message := range networkErrors {
+			if strings.Contains(line, message) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryOutputLines is how many of the last lines of its output a failed run
+// is judged by.
+const retryOutputLines = 100
+
+// outputTail keeps the last lines of the output of a command, which its
+// stdout and stderr add concurrently.
+type outputTail struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (t *outputTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, line)
+	if len(t.lines) > retryOutputLines {
+		t.lines = t.lines[len(t.lines)-retryOutputLines:]
+	}
+}
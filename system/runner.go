@@ -0,0 +1,311 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// killDelay is how long a canceled command may take to exit after it was
+// asked to, before it is killed.
+const killDelay = 5 * time.Second
+
+// Runner runs commands, e.g. pip or npm installs, that can be canceled with a
+// context. Every command runs in its own process group, which is stopped as a
+// whole on cancellation, so that the processes the command started do not
+// keep running. The zero Runner runs commands in the current directory with
+// the output of langforge.
+type Runner struct {
+	// Dir is the working directory of the commands.
+	Dir string
+	// Env is the environment of the commands, or that of langforge if nil.
+	// Executables are looked up in its PATH.
+	Env []string
+	// Command returns the command that runs an executable, exec.Command if
+	// nil. Venv.Runner looks up executables in the virtual environment.
+	Command func(name string, arg ...string) *exec.Cmd
+	// Stdout and Stderr receive the output of the commands line by line,
+	// without line endings. They are called from different goroutines. If
+	// nil, the output is written to the stdout and stderr of langforge.
+	Stdout func(line string)
+	Stderr func(line string)
+	// Timeout limits each run of a command; a command that runs longer is
+	// stopped like a canceled one. Zero does not limit it.
+	Timeout time.Duration
+	// Retry runs a command that fails again, or only once if nil.
+	Retry *RetryPolicy
+	// Mask replaces the secrets in a line of output, e.g. the API keys of the
+	// .env file that the environment of the commands has. With Mask, the
+	// output is written line by line also without Stdout and Stderr.
+	Mask func(line string) string
+}
+
+// Run runs a command until it exits or ctx is canceled. A canceled command is
+// asked to exit and killed if it does not within five seconds; the error then
+// wraps the error of ctx, or context.DeadlineExceeded if the command ran into
+// the timeout of the runner. A failed command is run again according to the
+// retry policy of the runner, unless ctx was canceled.
+func (r *Runner) Run(ctx context.Context, name string, args ...string) error {
+	attempts := 1
+	if r.Retry != nil && r.Retry.Attempts > 1 {
+		attempts = r.Retry.Attempts
+	}
+	for attempt := 1; ; attempt++ {
+		var output *outputTail
+		if attempt < attempts && r.Retry.RetryOn != nil {
+			output = &outputTail{}
+		}
+		err := r.run(ctx, output, name, args)
+		if err == nil || attempt == attempts || ctx.Err() != nil {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return err
+		}
+		if output != nil && !r.Retry.RetryOn(err, output.lines) {
+			return err
+		}
+
+		delay := r.Retry.Backoff << (attempt - 1)
+		message := fmt.Sprintf("%s failed: %v; retrying in %s (attempt %d of %d)", filepath.Base(name), err, delay, attempt+1, attempts)
+		if r.Stderr != nil {
+			r.Stderr(message)
+		} else {
+			reportMessage(StreamStderr, message)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// run runs a command once and adds the lines of its output to output unless
+// it is nil.
+func (r *Runner) run(ctx context.Context, output *outputTail, name string, args []string) error {
+	newCommand := r.Command
+	if newCommand == nil {
+		newCommand = exec.Command
+		if path, ok := environValue(r.Env, "PATH"); ok {
+			newCommand = commandInPath(path)
+		}
+	}
+	cmd := newCommand(name, args...)
+	cmd.Dir = r.Dir
+	if r.Env != nil {
+		cmd.Env = r.Env
+	}
+	setProcessGroup(cmd)
+	if SkipDryRun(cmd) {
+		return nil
+	}
+
+	// without callbacks, the output goes to the console or to the reporter
+	stdoutFile, stderrFile := commandFiles()
+	stdoutCallback, stderrCallback := r.Stdout, r.Stderr
+	if stdoutCallback == nil && stdoutFile == nil {
+		stdoutCallback = reportLines(CommandLine(cmd.Path, cmd.Args[1:]...), StreamStdout)
+	}
+	if stderrCallback == nil && stderrFile == nil {
+		stderrCallback = reportLines(CommandLine(cmd.Path, cmd.Args[1:]...), StreamStderr)
+	}
+	if r.Mask != nil {
+		stdoutCallback = maskLines(r.Mask, stdoutCallback, stdoutFile)
+		stderrCallback = maskLines(r.Mask, stderrCallback, stderrFile)
+	}
+	stdout := newLineWriter(tee(stdoutCallback, output))
+	stderr := newLineWriter(tee(stderrCallback, output))
+	cmd.Stdout = outputWriter(stdoutFile, stdoutCallback, stdout)
+	cmd.Stderr = outputWriter(stderrFile, stderrCallback, stderr)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	runCtx := ctx
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	reportEnd := reportCommand(cmd)
+	if err := cmd.Start(); err != nil {
+		reportEnd(err)
+		return err
+	}
+
+	exited := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-exited:
+			return
+		case <-runCtx.Done():
+		}
+		stopProcessGroup(cmd, false)
+		select {
+		case <-exited:
+		case <-time.After(killDelay):
+			stopProcessGroup(cmd, true)
+		}
+	}()
+
+	err := cmd.Wait()
+	close(exited)
+	<-stopped
+	stdout.flush()
+	stderr.flush()
+
+	switch {
+	case ctx.Err() != nil:
+		err = fmt.Errorf("%s was canceled: %w", filepath.Base(name), ctx.Err())
+	case runCtx.Err() != nil:
+		err = fmt.Errorf("%s timed out after %s: %w", filepath.Base(name), r.Timeout, runCtx.Err())
+	}
+	reportEnd(err)
+	return err
+}
+
+// commandInPath returns a function like exec.Command that looks up
+// executables in path, a list of directories like PATH, instead of in the
+// PATH of langforge.
+func commandInPath(path string) func(name string, arg ...string) *exec.Cmd {
+	return func(name string, arg ...string) *exec.Cmd {
+		if !strings.ContainsAny(name, `/\`) {
+			for _, dir := range filepath.SplitList(path) {
+				if dir == "" {
+					continue
+				}
+				// LookPath adds the extensions of PATHEXT on Windows
+				if found, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+					name = found
+					break
+				}
+			}
+		}
+		return exec.Command(name, arg...)
+	}
+}
+
+// tee returns a callback that passes lines to callback and adds them to
+// output, either of which may be nil.
+func tee(callback func(line string), output *outputTail) func(line string) {
+	switch {
+	case output == nil:
+		return callback
+	case callback == nil:
+		return output.add
+	}
+	return func(line string) {
+		callback(line)
+		output.add(line)
+	}
+}
+
+// maskLines returns a callback that masks lines and passes them to callback,
+// or writes them to file if it is nil.
+func maskLines(mask func(line string) string, callback func(line string), file *os.File) func(line string) {
+	return func(line string) {
+		line = mask(line)
+		if callback != nil {
+			callback(line)
+		} else {
+			fmt.Fprintln(file, line)
+		}
+	}
+}
+
+// outputWriter returns where a command writes its stdout or stderr: lines,
+// which passes the lines to the callback of the runner and to the output of
+// the run, and also file if the runner has no callback.
+func outputWriter(file *os.File, callback func(line string), lines *lineWriter) io.Writer {
+	switch {
+	case lines == nil:
+		return file
+	case callback == nil:
+		return io.MultiWriter(file, lines)
+	}
+	return lines
+}
+
+// RunCommands runs command lines one after the other, e.g. the pre and post
+// install commands of integrations, and stops at the first that fails. A
+// command line is split into arguments with the quoting rules of the shell of
+// the platform, see SplitCommand, but is not run by a shell. All command
+// lines are checked before the first one runs.
+func (r *Runner) RunCommands(ctx context.Context, commands []string) error {
+	argvs := [][]string{}
+	for _, command := range commands {
+		argv, err := SplitCommand(command)
+		if err != nil {
+			return err
+		}
+		if len(argv) > 0 {
+			argvs = append(argvs, argv)
+		}
+	}
+	for _, argv := range argvs {
+		if err := r.Run(ctx, argv[0], argv[1:]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InterruptContext returns a context that is canceled when langforge is
+// interrupted, e.g. with Ctrl-C, to cancel the commands of a Runner. While it
+// is not stopped, interrupts do not end langforge.
+func InterruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// lineWriter passes the lines written to it to a callback.
+type lineWriter struct {
+	mu       sync.Mutex
+	buffer   []byte
+	callback func(line string)
+}
+
+func newLineWriter(callback func(line string)) *lineWriter {
+	if callback == nil {
+		return nil
+	}
+	return &lineWriter{callback: callback}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buffer = append(w.buffer, p...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			break
+		}
+		w.callback(strings.TrimSuffix(string(w.buffer[:i]), "\r"))
+		w.buffer = w.buffer[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush passes on the last line if it did not end with a line break.
+func (w *lineWriter) flush() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buffer) > 0 {
+		w.callback(strings.TrimSuffix(string(w.buffer), "\r"))
+		w.buffer = nil
+	}
+}
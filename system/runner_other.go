@@ -0,0 +1,26 @@
+//go:build !windows
+
+package system
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a process group of its own.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// stopProcessGroup sends SIGTERM to the process group of a command, or
+// SIGKILL if force is set.
+func stopProcessGroup(cmd *exec.Cmd, force bool) {
+	signal := syscall.SIGTERM
+	if force {
+		signal = syscall.SIGKILL
+	}
+	syscall.Kill(-cmd.Process.Pid, signal)
+}
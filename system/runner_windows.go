@@ -0,0 +1,25 @@
+//go:build windows
+
+package system
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a process group of its own, which
+// does not receive the Ctrl-C of the console of langforge.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// stopProcessGroup ends a command and the processes it started. Console
+// processes ignore the close request of taskkill without /F, so they are
+// always killed.
+func stopProcessGroup(cmd *exec.Cmd, force bool) {
+	exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
@@ -0,0 +1,244 @@
+package system
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sources of the runtimes found by DiscoverRuntimes.
+const (
+	SourcePath     = "PATH"
+	SourcePyenv    = "pyenv"
+	SourceConda    = "conda"
+	SourceNvm      = "nvm"
+	SourceRegistry = "registry"
+	// SourcePyLauncher is the py launcher of Windows.
+	SourcePyLauncher = "py launcher"
+)
+
+// Runtime is a Python or Node.js interpreter installed on the machine.
+type Runtime struct {
+	// Name is "python" or "node".
+	Name    string
+	Version string
+	Path    string
+	// Arch is the architecture the interpreter was built for, see
+	// NormalizeArch.
+	Arch string
+	// Source says where the interpreter was found: in the PATH, a pyenv
+	// version, a conda environment, an nvm version, the py launcher or the
+	// Windows registry.
+	Source string
+}
+
+// pythonRuntimeScript prints the version and the architecture of a Python
+// interpreter, in a way that also works with Python 2.
+const pythonRuntimeScript = `import platform, sys, sysconfig
+print(platform.python_version())
+if sys.platform == "win32":
+    print(sysconfig.get_platform().split("-")[-1])
+else:
+    print(platform.machine())`
+
+// DiscoverRuntimes returns the Python and Node.js interpreters of the machine,
+// found in the PATH, the versions of pyenv and nvm, the conda environments, the
+// py launcher and the registry of Windows. Each interpreter is asked for its version and
+// architecture; interpreters that fail to answer are left out. An interpreter
+// that is found several times is listed once, with the first source that
+// found it. The runtimes are sorted by name and by version, newest first.
+func DiscoverRuntimes() []Runtime {
+	candidates := []Runtime{}
+	add := func(name string, source string, paths ...string) {
+		for _, path := range paths {
+			candidates = append(candidates, Runtime{Name: name, Path: path, Source: source})
+		}
+	}
+
+	pyenvRoot := pyenvRoot()
+	pythonNames := pythonCandidates()
+	if IsWindows() {
+		pythonNames = []string{"python", "python3"}
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || isPyenvShim(dir, pyenvRoot) {
+			continue
+		}
+		for _, name := range pythonNames {
+			if path, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+				add("python", SourcePath, path)
+			}
+		}
+		if path, err := exec.LookPath(filepath.Join(dir, "node")); err == nil {
+			add("node", SourcePath, path)
+		}
+	}
+
+	add("python", SourcePyenv, globExecutables(filepath.Join(pyenvRoot, "versions", "*"), "python")...)
+	for _, env := range condaEnvironments() {
+		add("python", SourceConda, globExecutables(env, "python")...)
+	}
+	add("node", SourceNvm, nvmNodes()...)
+	add("python", SourcePyLauncher, launcherPythons()...)
+	add("python", SourceRegistry, registryPythons()...)
+
+	// the same interpreter is often reachable through several links
+	seen := map[string]bool{}
+	unique := []Runtime{}
+	for _, candidate := range candidates {
+		resolved, err := filepath.EvalSymlinks(candidate.Path)
+		if err != nil {
+			continue
+		}
+		if seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		unique = append(unique, candidate)
+	}
+
+	var wg sync.WaitGroup
+	for i := range unique {
+		wg.Add(1)
+		go func(r *Runtime) {
+			defer wg.Done()
+			r.Version, r.Arch = probeRuntime(r.Name, r.Path)
+		}(&unique[i])
+	}
+	wg.Wait()
+
+	runtimes := []Runtime{}
+	for _, r := range unique {
+		if r.Version != "" {
+			runtimes = append(runtimes, r)
+		}
+	}
+	sort.SliceStable(runtimes, func(i, j int) bool {
+		if runtimes[i].Name != runtimes[j].Name {
+			return runtimes[i].Name > runtimes[j].Name
+		}
+		return compareVersions(parseVersion(runtimes[i].Version), parseVersion(runtimes[j].Version)) > 0
+	})
+	return runtimes
+}
+
+// FilterRuntimes returns the runtimes with the given name.
+func FilterRuntimes(runtimes []Runtime, name string) []Runtime {
+	filtered := []Runtime{}
+	for _, r := range runtimes {
+		if r.Name == name {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// probeRuntime returns the version and the architecture of an interpreter, or
+// empty strings if it does not run.
+func probeRuntime(name string, path string) (string, string) {
+	var output []byte
+	var err error
+	if name == "node" {
+		output, err = exec.Command(path, "-p", `process.versions.node + "\n" + process.arch`).Output()
+	} else {
+		output, err = exec.Command(path, "-c", pythonRuntimeScript).Output()
+	}
+	if err != nil {
+		return "", ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) < 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(lines[0]), NormalizeArch(lines[1])
+}
+
+// globExecutables returns the interpreters called name in the directories
+// that match pattern, in their bin directory on Unix and at their top level
+// on Windows.
+func globExecutables(pattern string, name string) []string {
+	dirs, _ := filepath.Glob(pattern)
+	executables := []string{}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, "bin", name)
+		if IsWindows() {
+			path = filepath.Join(dir, name+".exe")
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			executables = append(executables, path)
+		}
+	}
+	return executables
+}
+
+// pyenvRoot returns the directory of pyenv, or of pyenv-win on Windows.
+func pyenvRoot() string {
+	if root := os.Getenv("PYENV_ROOT"); root != "" {
+		return root
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if IsWindows() {
+		return filepath.Join(home, ".pyenv", "pyenv-win")
+	}
+	return filepath.Join(home, ".pyenv")
+}
+
+// isPyenvShim reports whether dir holds the shims of pyenv, which run the
+// interpreter of the selected pyenv version. The versions are listed
+// themselves instead.
+func isPyenvShim(dir string, root string) bool {
+	return root != "" && filepath.Clean(dir) == filepath.Join(root, "shims")
+}
+
+// condaEnvironments returns the directories of the conda environments that
+// conda has recorded in ~/.conda/environments.txt, and the base environment
+// of the conda in use.
+func condaEnvironments() []string {
+	envs := []string{}
+	if exe := os.Getenv("CONDA_EXE"); exe != "" {
+		envs = append(envs, filepath.Dir(filepath.Dir(exe)))
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return envs
+	}
+	file, err := os.Open(filepath.Join(home, ".conda", "environments.txt"))
+	if err != nil {
+		return envs
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			envs = append(envs, line)
+		}
+	}
+	return envs
+}
+
+// nvmNodes returns the Node.js versions installed with nvm, or with
+// nvm-windows on Windows.
+func nvmNodes() []string {
+	if IsWindows() {
+		if home := os.Getenv("NVM_HOME"); home != "" {
+			return globExecutables(filepath.Join(home, "v*"), "node")
+		}
+		return nil
+	}
+	dir := os.Getenv("NVM_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Join(home, ".nvm")
+	}
+	return globExecutables(filepath.Join(dir, "versions", "node", "*"), "node")
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package system
+
+// registryPythons returns the interpreters registered in the Windows
+// registry, which only exists on Windows.
+func registryPythons() []string {
+	return nil
+}
+
+// launcherPythons returns the interpreters that the py launcher of Windows
+// lists.
+func launcherPythons() []string {
+	return nil
+}
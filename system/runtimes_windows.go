@@ -0,0 +1,112 @@
+//go:build windows
+
+package system
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// registryPythons returns the interpreters that installers register under
+// Software\Python in the registry of the user and of the machine (PEP 514),
+// including the 32-bit view of 64-bit Windows.
+func registryPythons() []string {
+	roots := []struct {
+		key    registry.Key
+		access uint32
+	}{
+		{registry.CURRENT_USER, 0},
+		{registry.LOCAL_MACHINE, registry.WOW64_64KEY},
+		{registry.LOCAL_MACHINE, registry.WOW64_32KEY},
+	}
+
+	paths := []string{}
+	for _, root := range roots {
+		companies, err := registry.OpenKey(root.key, `Software\Python`, registry.ENUMERATE_SUB_KEYS|root.access)
+		if err != nil {
+			continue
+		}
+		companyNames, _ := companies.ReadSubKeyNames(-1)
+		companies.Close()
+
+		for _, company := range companyNames {
+			// the py launcher registers itself next to the interpreters
+			if company == "PyLauncher" {
+				continue
+			}
+			tags, err := registry.OpenKey(root.key, `Software\Python\`+company, registry.ENUMERATE_SUB_KEYS|root.access)
+			if err != nil {
+				continue
+			}
+			tagNames, _ := tags.ReadSubKeyNames(-1)
+			tags.Close()
+
+			for _, tag := range tagNames {
+				if path := registryExecutable(root.key, `Software\Python\`+company+`\`+tag+`\InstallPath`, root.access); path != "" {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// registryExecutable returns the interpreter of an InstallPath key, which
+// names it in ExecutablePath or else holds the installation directory.
+func registryExecutable(root registry.Key, path string, access uint32) string {
+	key, err := registry.OpenKey(root, path, registry.QUERY_VALUE|access)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+
+	executable, _, err := key.GetStringValue("ExecutablePath")
+	if err != nil || executable == "" {
+		dir, _, err := key.GetStringValue("")
+		if err != nil || dir == "" {
+			return ""
+		}
+		executable = filepath.Join(dir, "python.exe")
+	}
+	if _, err := os.Stat(executable); err != nil {
+		return ""
+	}
+	return executable
+}
+
+// launcherPythons returns the interpreters that the py launcher lists with
+// "py -0p", one per line after its tag, e.g.
+//
+//	-V:3.12 *        C:\Users\me\AppData\Local\Programs\Python\Python312\python.exe
+//	-3.9-64          C:\Python39\python.exe
+//
+// Older launchers use the second form; "*" marks the default.
+func launcherPythons() []string {
+	py, err := exec.LookPath("py")
+	if err != nil {
+		return nil
+	}
+	output, err := exec.Command(py, "-0p").Output()
+	if err != nil {
+		return nil
+	}
+
+	paths := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		// paths can contain spaces
+		path := strings.TrimSpace(strings.TrimSpace(line)[len(fields[0]):])
+		path = strings.TrimSpace(strings.TrimPrefix(path, "*"))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
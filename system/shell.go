@@ -0,0 +1,161 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Shell is a kind of shell that langforge is started from.
+type Shell string
+
+const (
+	// ShellPOSIX is any shell on Linux and macOS, including WSL.
+	ShellPOSIX      Shell = "posix"
+	ShellCmd        Shell = "cmd"
+	ShellPowerShell Shell = "powershell"
+	// ShellMSYS is the bash of Git for Windows (Git Bash) or of MSYS2.
+	ShellMSYS   Shell = "msys"
+	ShellCygwin Shell = "cygwin"
+)
+
+var (
+	shellOnce sync.Once
+	shell     Shell
+)
+
+// UnixShell is a shell that scripts, like the activate scripts of virtual
+// environments, are sourced with on Linux and macOS.
+type UnixShell string
+
+const (
+	Sh   UnixShell = "sh"
+	Bash UnixShell = "bash"
+	Zsh  UnixShell = "zsh"
+	// Fish has a syntax of its own, e.g. 'set -x' instead of 'export', so
+	// its scripts cannot be sourced by sh and sh scripts not by fish.
+	Fish UnixShell = "fish"
+)
+
+// LoginShell returns the shell of the user according to $SHELL if it is
+// bash, zsh or fish and installed, and Sh otherwise.
+func LoginShell() UnixShell {
+	switch s := UnixShell(filepath.Base(os.Getenv("SHELL"))); s {
+	case Bash, Zsh, Fish:
+		if _, err := exec.LookPath(s.path()); err == nil {
+			return s
+		}
+	}
+	return Sh
+}
+
+// ScriptShell returns the shell that sources script: the shell its extension
+// names, e.g. fish for activate.fish, or else the login shell, unless that is
+// fish.
+func ScriptShell(script string) UnixShell {
+	switch s := UnixShell(strings.TrimPrefix(filepath.Ext(script), ".")); s {
+	case Sh, Bash, Zsh, Fish:
+		return s
+	}
+	if s := LoginShell(); s != Fish {
+		return s
+	}
+	return Sh
+}
+
+// ActivateScript returns the name of the activate script that virtual
+// environments have for the shell in their bin directory.
+func (s UnixShell) ActivateScript() string {
+	if s == Fish {
+		return "activate.fish"
+	}
+	return "activate"
+}
+
+// path returns the executable of the shell: that of $SHELL if it is the
+// login shell, which need not be in the PATH.
+func (s UnixShell) path() string {
+	if login := os.Getenv("SHELL"); filepath.Base(login) == string(s) {
+		if _, err := exec.LookPath(login); err == nil {
+			return login
+		}
+	}
+	return string(s)
+}
+
+// DetectShell returns the shell langforge was started from. On Windows this
+// is the closest shell among the parent processes, since langforge is usually
+// started through the launcher of its npm or pip package.
+func DetectShell() Shell {
+	shellOnce.Do(func() {
+		shell = detectShell(runtime.GOOS, ancestorNames(), os.Getenv("MSYSTEM"))
+	})
+	return shell
+}
+
+// detectShell returns the shell for the executable names of the parent
+// processes. MSYS shells set MSYSTEM, which Cygwin shells do not.
+func detectShell(goos string, ancestors []string, msystem string) Shell {
+	if goos != "windows" {
+		return ShellPOSIX
+	}
+	for _, name := range ancestors {
+		switch strings.TrimSuffix(strings.ToLower(name), ".exe") {
+		case "powershell", "pwsh":
+			return ShellPowerShell
+		case "cmd":
+			return ShellCmd
+		case "bash", "sh", "zsh", "dash", "fish", "mintty":
+			if msystem != "" {
+				return ShellMSYS
+			}
+			return ShellCygwin
+		}
+	}
+	if msystem != "" {
+		return ShellMSYS
+	}
+	return ShellCmd
+}
+
+// IsPOSIXShellOnWindows reports whether langforge was started from Git Bash,
+// MSYS2 or Cygwin, whose paths differ from those of Windows.
+func IsPOSIXShellOnWindows() bool {
+	switch DetectShell() {
+	case ShellMSYS, ShellCygwin:
+		return true
+	}
+	return false
+}
+
+// NativePath converts a path of an MSYS or Cygwin shell, e.g. /c/Users/me or
+// /cygdrive/c/Users/me, into a Windows path when langforge was started from
+// such a shell. Git Bash converts the paths in arguments itself but not those
+// in all environment variables, and Cygwin converts none. Other paths are
+// returned unchanged.
+func NativePath(path string) string {
+	if !IsPOSIXShellOnWindows() {
+		return path
+	}
+	return windowsPath(path)
+}
+
+// windowsPath converts a path that starts with a drive as /c or /cygdrive/c.
+func windowsPath(path string) string {
+	rest := strings.TrimPrefix(path, "/cygdrive")
+	if len(rest) < 2 || rest[0] != '/' || !isDriveLetter(rest[1]) || (len(rest) > 2 && rest[2] != '/') {
+		return path
+	}
+	tail := strings.ReplaceAll(rest[2:], "/", `\`)
+	if tail == "" {
+		tail = `\`
+	}
+	return strings.ToUpper(rest[1:2]) + ":" + tail
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,170 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitCommand splits a command line into the executable and its arguments
+// with the rules of the shell of the platform: SplitCmd on Windows and
+// SplitPOSIX elsewhere.
+func SplitCommand(line string) ([]string, error) {
+	if IsWindows() {
+		return SplitCmd(line)
+	}
+	return SplitPOSIX(line)
+}
+
+// SplitPOSIX splits a command line into words like a POSIX shell, e.g.
+// `pip install "my package" --target '/tmp/a b'`. Single quotes keep
+// everything literal, double quotes keep everything but backslash escapes
+// literal and a backslash outside quotes escapes the next character. Words
+// that start with # begin a comment. Since the words are not run by a shell,
+// pipes, redirections, command lists and variable or command substitutions
+// are reported as errors instead of being passed on literally.
+func SplitPOSIX(line string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '#' && !inWord:
+			return words, nil
+		case r == '\\':
+			inWord = true
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("%q ends with a backslash", line)
+			}
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+			}
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%q has an unterminated single quote", line)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				switch c := runes[i]; {
+				case c == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]):
+					i++
+					if runes[i] != '\n' {
+						word.WriteRune(runes[i])
+					}
+				case c == '$' || c == '`':
+					return nil, shellSyntaxError(line, c)
+				default:
+					word.WriteRune(c)
+				}
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("%q has an unterminated double quote", line)
+			}
+		case strings.ContainsRune("|&;<>()$`", r):
+			return nil, shellSyntaxError(line, r)
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// SplitCmd splits a command line into arguments like programs on Windows
+// parse their command line after cmd.exe has passed it on, e.g.
+// `pip install "C:\Program Files\pkg"`. Arguments are separated by spaces and
+// tabs outside double quotes, a caret outside quotes escapes the next
+// character, "" inside quotes is a literal double quote, and backslashes are
+// only special before a double quote: 2n backslashes followed by a quote
+// become n backslashes and the quote starts or ends a quoted part, 2n+1
+// become n backslashes and a literal quote. Pipes, redirections and command
+// lists are reported as errors, since the arguments are not run by cmd.exe.
+func SplitCmd(line string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg, quoted := false, false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case (r == ' ' || r == '\t') && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			inArg = true
+			backslashes := 1
+			for i+1 < len(runes) && runes[i+1] == '\\' {
+				backslashes++
+				i++
+			}
+			if i+1 < len(runes) && runes[i+1] == '"' {
+				arg.WriteString(strings.Repeat(`\`, backslashes/2))
+				if backslashes%2 == 1 {
+					arg.WriteRune('"')
+					i++
+				}
+				continue
+			}
+			arg.WriteString(strings.Repeat(`\`, backslashes))
+		case r == '"':
+			inArg = true
+			if quoted && i+1 < len(runes) && runes[i+1] == '"' {
+				arg.WriteRune('"')
+				i++
+				continue
+			}
+			quoted = !quoted
+		case r == '^' && !quoted:
+			inArg = true
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("%q ends with a caret", line)
+			}
+			arg.WriteRune(runes[i])
+		case strings.ContainsRune("|&<>", r) && !quoted:
+			return nil, shellSyntaxError(line, r)
+		default:
+			inArg = true
+			arg.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("%q has an unterminated double quote", line)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func shellSyntaxError(line string, r rune) error {
+	return fmt.Errorf("%q uses the shell syntax %q, which is not supported since commands are not run by a shell; escape or quote it to pass it literally", line, r)
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,37 +1,171 @@
 package system
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 )
 
-// FindPython searches for the Python interpreter in the system's PATH.
-// It looks for a binary called "python3" first, and if that's not found, it
-// looks for a binary called "python". If neither binary is found, an error is returned.
+// FindPython searches for a Python interpreter that satisfies the version
+// constraints, such as ">=3.9,<3.13", or DefaultPythonConstraint if none are
+// given, and returns the first of FindPythons.
+func FindPython(constraints ...string) (string, error) {
+	pythons, err := FindPythons(constraints...)
+	if err != nil {
+		return "", err
+	}
+	return pythons[0].Path, nil
+}
+
+// FindPythons returns the Python interpreters that satisfy the version
+// constraints, or DefaultPythonConstraint if none are given, in the order of
+// preference. Each candidate is asked for its version with "--version".
 //
-// Returns the path to the Python interpreter binary and nil error if
-// it is found, or empty string and non-nil error if it is not found.
-func FindPython() (string, error) {
-	// try to find python3 first
-	pythonPath, err := exec.LookPath("python3")
-	if err == nil {
-		return pythonPath, nil
+// The candidates are the interpreters in the PATH: "python3" and "python" are
+// preferred, so that an activated virtual environment is used, followed by
+// the newest versioned interpreter such as "python3.12". On Windows, where
+// Pythons of the Microsoft Store and of the official installer are often not
+// in the PATH, the interpreters that the py launcher lists ("py -0p") and
+// those registered in the registry (PEP 514) are candidates too, and the
+// newest one is preferred after the activated virtual or conda environment.
+//
+// ErrPythonNotFound is returned if there is no interpreter at all, and an
+// error listing the rejected interpreters if none of them satisfies the
+// constraints.
+func FindPythons(constraints ...string) ([]Runtime, error) {
+	constraint := strings.Join(constraints, ",")
+	if constraint == "" {
+		constraint = DefaultPythonConstraint
+	}
+	matches, err := ParseVersionConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []Runtime{}
+	for _, name := range pythonCandidates() {
+		if pythonPath, err := exec.LookPath(name); err == nil {
+			candidates = append(candidates, Runtime{Name: "python", Path: pythonPath, Source: SourcePath})
+		}
+	}
+	if IsWindows() {
+		candidates = append(candidates, windowsPythons()...)
+	}
+
+	pythons := []Runtime{}
+	rejected := []string{}
+	seen := []string{}
+	for _, candidate := range candidates {
+		if containsPath(seen, candidate.Path) {
+			continue
+		}
+		seen = append(seen, candidate.Path)
+
+		// e.g. the stub of the Microsoft Store, which only opens the store
+		version, err := cachedPythonVersion(candidate.Path)
+		if err != nil {
+			continue
+		}
+		if !matches(version) {
+			rejected = append(rejected, fmt.Sprintf("%s is %s", candidate.Path, version))
+			continue
+		}
+		candidate.Version = version
+		pythons = append(pythons, candidate)
+	}
+
+	if len(pythons) == 0 {
+		if len(rejected) == 0 {
+			return nil, ErrPythonNotFound
+		}
+		return nil, fmt.Errorf("no python interpreter satisfies %s (%s)", constraint, strings.Join(rejected, ", "))
 	}
+	if IsWindows() {
+		sort.SliceStable(pythons, func(i, j int) bool {
+			if active := inActiveEnvironment(pythons[i].Path); active != inActiveEnvironment(pythons[j].Path) {
+				return active
+			}
+			return compareVersions(parseVersion(pythons[i].Version), parseVersion(pythons[j].Version)) > 0
+		})
+	}
+	return pythons, nil
+}
+
+var (
+	windowsPythonsOnce sync.Once
+	windowsPythonList  []Runtime
+)
+
+// windowsPythons returns the interpreters of the py launcher and of the
+// registry, which are only looked up once per run.
+func windowsPythons() []Runtime {
+	windowsPythonsOnce.Do(func() {
+		for _, pythonPath := range launcherPythons() {
+			windowsPythonList = append(windowsPythonList, Runtime{Name: "python", Path: pythonPath, Source: SourcePyLauncher})
+		}
+		for _, pythonPath := range registryPythons() {
+			windowsPythonList = append(windowsPythonList, Runtime{Name: "python", Path: pythonPath, Source: SourceRegistry})
+		}
+	})
+	return windowsPythonList
+}
+
+// inActiveEnvironment reports whether an interpreter belongs to the activated
+// virtual environment or conda environment.
+func inActiveEnvironment(pythonPath string) bool {
+	for _, key := range []string{"VIRTUAL_ENV", "CONDA_PREFIX"} {
+		if env := os.Getenv(key); env != "" && isInDir(pythonPath, env) {
+			return true
+		}
+	}
+	return false
+}
 
-	// if python3 not found, try to find python
-	pythonPath, err = exec.LookPath("python")
-	if err == nil {
-		return pythonPath, nil
+// isInDir reports whether path is inside dir.
+func isInDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// containsPath reports whether paths contains path, see samePath.
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if samePath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// PythonVersion returns the version of the given Python interpreter as reported
+// by "python --version", e.g. "3.11.4".
+func PythonVersion(pythonPath string) (string, error) {
+	// Python 2 prints its version to stderr, so capture both streams
+	output, err := exec.Command(pythonPath, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine python version: %v", err)
 	}
 
-	// if neither are found, return an error
-	return "", errors.New("python interpreter not found")
+	version := strings.TrimSpace(string(output))
+	version = strings.TrimPrefix(version, "Python ")
+	return version, nil
+}
+
+// NodeVersion returns the version of the Node.js interpreter at nodePath,
+// e.g. "20.11.1".
+func NodeVersion(nodePath string) (string, error) {
+	output, err := exec.Command(nodePath, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine node.js version: %v", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "v"), nil
 }
 
 // FindNode searches for the Node.js interpreter in the system's PATH.
@@ -50,6 +184,16 @@ func FindNode() (string, error) {
 	return nodePath, nil
 }
 
+// FindBun searches for the bun JavaScript runtime in the system's PATH.
+func FindBun() (string, error) {
+	bunPath, err := exec.LookPath("bun")
+	if err != nil {
+		return "", errors.New("bun not found")
+	}
+
+	return bunPath, nil
+}
+
 // FindPip searches for the location of the pip command in the system. It first searches for pip3, then for pip,
 // returning the location of the command if found. If the command is not found, it returns an error.
 //
@@ -72,172 +216,141 @@ func FindPip() (string, error) {
 }
 
 // ShellSourceUnix emulates the action of the "source" command in bash by executing
-// a shell script and setting environment variables based on its output. The
-// script file is passed in as an argument to the function. It returns an error
-// if the script fails to execute.
+// a shell script and capturing the environment variables that it leaves behind.
+// The environment of langforge is not changed; the returned environment can be
+// attached to commands or applied with Apply. It returns an error if the script
+// fails to execute. The script is sourced by the shell of ScriptShell, e.g.
+// fish for activate.fish.
 //
 // Parameters:
 //   - script: the path to the shell script to execute.
 //
 // Returns:
-//   - nil error if the script is executed successfully and the environment variables
-//     are set, or a non-nil error if the script fails to execute.
-func ShellSourceUnix(script string) error {
-	cmd := exec.Command("sh", "-c", ". "+script+" && env")
-
-	output, err := cmd.Output()
-	if err != nil {
-		return errors.New("Failed to execute shell script: " + err.Error())
-	}
+//   - the environment after the script ran and nil error if the script is executed
+//     successfully, or a non-nil error if the script fails to execute.
+func ShellSourceUnix(script string) (*Environment, error) {
+	return ShellSource(ScriptShell(script), script)
+}
 
-	env := make(map[string]string)
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			env[parts[0]] = parts[1]
-		}
+// ShellSource sources a script with the given shell and captures the
+// environment variables that it leaves behind, like ShellSourceUnix. The
+// startup files of zsh and fish are not read, so that only the script changes
+// the environment.
+func ShellSource(shell UnixShell, script string) (*Environment, error) {
+	var cmd *exec.Cmd
+	switch shell {
+	case Fish:
+		cmd = exec.Command(shell.path(), "--no-config", "-c", "source "+QuoteFish(script)+"; and env")
+	case Zsh:
+		cmd = exec.Command(shell.path(), "-f", "-c", ". "+QuotePOSIX(script)+" && env")
+	default:
+		cmd = exec.Command(shell.path(), "-c", ". "+QuotePOSIX(script)+" && env")
 	}
 
-	for key, value := range env {
-		os.Setenv(key, value)
+	reportEnd := reportCommand(cmd)
+	output, err := cmd.Output()
+	reportEnd(err)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute shell script with %s: %v", shell, err)
 	}
 
-	return nil
+	return captureEnvironment(bytes.NewReader(output), shellVariables)
 }
 
 // ShellSourceBatch emulates the action of executing a .bat file in the
-// Command Prompt (cmd.exe) and setting environment variables based on its output.
-// The .bat file is passed in as an argument to the function. It returns an error
-// if the .bat file fails to execute.
+// Command Prompt (cmd.exe) and captures the environment variables that it
+// leaves behind, like ShellSourceUnix. It returns an error if the .bat file
+// fails to execute.
 //
 // Parameters:
 //   - script: the path to the .bat file to execute.
 //
 // Returns:
-//   - nil error if the .bat file is executed successfully and the environment variables
-//     are set, or a non-nil error if the .bat file fails to execute.
-func ShellSourceBatch(script string) error {
-	cmd := exec.Command("cmd.exe", "/C", script+" && set")
+//   - the environment after the .bat file ran and nil error if it is executed
+//     successfully, or a non-nil error if the .bat file fails to execute.
+func ShellSourceBatch(script string) (*Environment, error) {
+	// switch to UTF-8 so that non-ASCII paths and values survive "set"
+	cmd := cmdExe("chcp 65001 >nul && call " + QuoteCmd(script) + " && set")
 
 	var out bytes.Buffer
 	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
+	stderr, flush := stderrWriter(cmd)
+	cmd.Stderr = stderr
 
+	reportEnd := reportCommand(cmd)
 	err := cmd.Run()
+	flush()
+	reportEnd(err)
 	if err != nil {
-		return errors.New("Failed to execute .bat file: " + err.Error())
-	}
-
-	env := make(map[string]string)
-	scanner := bufio.NewScanner(&out)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			env[parts[0]] = parts[1]
-		}
-	}
-
-	for key, value := range env {
-		os.Setenv(key, value)
+		return nil, errors.New("Failed to execute .bat file: " + err.Error())
 	}
 
-	return nil
+	return captureEnvironment(&out, nil)
 }
 
 // ShellSourcePowerShell emulates the action of executing a .ps1 file in PowerShell
-// and setting environment variables based on its output. The .ps1 file is passed
-// in as an argument to the function. It returns an error if the .ps1 file fails to execute.
+// and captures the environment variables that it leaves behind, like
+// ShellSourceUnix. It returns an error if the .ps1 file fails to execute.
 //
 // Parameters:
 //   - script: the path to the .ps1 file to execute.
 //
 // Returns:
-//   - nil error if the .ps1 file is executed successfully and the environment variables
-//     are set, or a non-nil error if the .ps1 file fails to execute.
-func ShellSourcePowerShell(script string) error {
-	cmd := exec.Command("powershell.exe", "-ExecutionPolicy", "Bypass", "-Command", "& {"+script+"; Get-ChildItem Env: | ForEach-Object { $_.Name + '=' + $_.Value }}")
+//   - the environment after the .ps1 file ran and nil error if it is executed
+//     successfully, or a non-nil error if the .ps1 file fails to execute.
+func ShellSourcePowerShell(script string) (*Environment, error) {
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-ExecutionPolicy", "Bypass", "-Command",
+		"[Console]::OutputEncoding = [System.Text.Encoding]::UTF8; & "+QuotePowerShell(script)+"; Get-ChildItem Env: | ForEach-Object { $_.Name + '=' + $_.Value }")
 
 	var out bytes.Buffer
 	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
+	stderr, flush := stderrWriter(cmd)
+	cmd.Stderr = stderr
 
+	reportEnd := reportCommand(cmd)
 	err := cmd.Run()
+	flush()
+	reportEnd(err)
 	if err != nil {
-		return errors.New("Failed to execute .ps1 file: " + err.Error())
+		return nil, errors.New("Failed to execute .ps1 file: " + err.Error())
 	}
 
-	env := make(map[string]string)
-	scanner := bufio.NewScanner(&out)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			env[parts[0]] = parts[1]
-		}
-	}
-
-	for key, value := range env {
-		os.Setenv(key, value)
-	}
-
-	return nil
+	return captureEnvironment(&out, nil)
 }
 
-// ExecuteCommands takes a list of shell commands as input, removes duplicates,
-// and executes them sequentially. It returns an error if any of the commands fail
-// to execute. The stdout and stderr of the executed commands are redirected to
-// the current process's stdout and stderr.
+// ExecuteCommands takes a list of command lines as input and executes them
+// sequentially with a Runner, see Runner.RunCommands for how they are split
+// into arguments. It returns an error if any of the commands fail
+// to execute, or if langforge is interrupted, which stops the running command.
+// The stdout and stderr of the executed commands are redirected to the current
+// process's stdout and stderr.
 func ExecuteCommands(commands []string, dir string) error {
+	ctx, stop := InterruptContext()
+	defer stop()
+	return (&Runner{Dir: dir}).RunCommands(ctx, commands)
+}
 
-	if len(commands) == 0 {
-		return nil
-	}
-
-	for _, command := range commands {
-		parts := strings.Split(command, " ")
-		cmdName := parts[0]
-		args := []string{}
-		if len(parts) > 1 {
-			args = parts[1:]
-		}
-		cmd := exec.Command(cmdName, args...)
-		cmd.Dir = dir
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err := cmd.Run()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+// ExecuteCommandsWithEnv executes commands like ExecuteCommands in the
+// environment of langforge with the variables of env set, e.g. the PATH and
+// VIRTUAL_ENV of a virtual environment. Executables are looked up in the PATH
+// of that environment. The environment of langforge is not changed, so that
+// commands with different environments can run at the same time, e.g. in a
+// server.
+func ExecuteCommandsWithEnv(commands []string, dir string, env map[string]string) error {
+	ctx, stop := InterruptContext()
+	defer stop()
+	return (&Runner{Dir: dir, Env: MergeEnviron(os.Environ(), env)}).RunCommands(ctx, commands)
 }
 
+// IsWindows reports whether langforge runs on Windows, including when it is
+// started from Git Bash, MSYS2 or Cygwin. WSL runs the Linux build, see
+// Platform to tell it apart from other Linux platforms.
 func IsWindows() bool {
-	if runtime.GOOS == "windows" {
-		// return false in WSL
-		cmd := exec.Command("uname", "-a")
-		if output, err := cmd.Output(); err == nil {
-			if strings.Contains(strings.ToLower(string(output)), "microsoft") {
-				return false
-			}
-		}
-		return true
-	}
-	return false
+	return runtime.GOOS == "windows"
 }
 
+// IsPowerShell reports whether langforge was started from PowerShell or
+// PowerShell Core.
 func IsPowerShell() bool {
-	parentProcessID := os.Getppid()
-
-	cmd := exec.Command("wmic", "process", "where", fmt.Sprintf("ProcessId=%d", parentProcessID), "get", "CommandLine")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-
-	commandLine := strings.ToLower(string(output))
-
-	return strings.Contains(commandLine, "powershell.exe")
+	return DetectShell() == ShellPowerShell
 }
@@ -0,0 +1,194 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Venv is a Python virtual environment.
+type Venv struct {
+	// Path is the absolute path of the environment.
+	Path string
+}
+
+// IsVenv reports whether dir is a virtual environment.
+func IsVenv(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "pyvenv.cfg"))
+	return err == nil
+}
+
+// CreateVenv creates an isolated virtual environment at path, without the
+// packages of the interpreter's site-packages, and replaces an environment
+// that exists there. If pythonPath is empty, the Python interpreter in the
+// PATH is used.
+func CreateVenv(path string, pythonPath string) (*Venv, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if pythonPath == "" {
+		if pythonPath, err = FindPython(); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{"-m", "venv", "--clear", absPath}
+	if !IsWindows() {
+		args = []string{"-m", "venv", "--clear", "--symlinks", absPath}
+	}
+	// dry runs skip the command and return the environment it would create
+	ctx, stop := InterruptContext()
+	defer stop()
+	if err := (&Runner{}).Run(ctx, pythonPath, args...); err != nil {
+		return nil, fmt.Errorf("failed to create the virtual environment %s: %v", path, err)
+	}
+	return &Venv{Path: absPath}, nil
+}
+
+// OpenVenv returns the virtual environment at path.
+func OpenVenv(path string) (*Venv, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsVenv(absPath) {
+		return nil, fmt.Errorf("%s is not a virtual environment", path)
+	}
+	return &Venv{Path: absPath}, nil
+}
+
+// BinDir returns the directory of the executables of the environment:
+// Scripts with Python for Windows, bin on other systems and with the Pythons
+// of MSYS2 and Cygwin.
+func (v *Venv) BinDir() string {
+	if IsWindows() && !v.HasPOSIXLayout() {
+		return filepath.Join(v.Path, "Scripts")
+	}
+	return filepath.Join(v.Path, "bin")
+}
+
+// HasPOSIXLayout reports whether the environment has a bin directory instead
+// of the Scripts directory of environments on Windows.
+func (v *Venv) HasPOSIXLayout() bool {
+	if _, err := os.Stat(filepath.Join(v.Path, "Scripts")); err == nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(v.Path, "bin", "activate"))
+	return err == nil
+}
+
+// BinPaths returns the directories that activating the environment adds to
+// the PATH.
+func (v *Venv) BinPaths() []string {
+	return []string{v.BinDir()}
+}
+
+// Executable returns the path of an executable of the environment, e.g.
+// "python" or "pip", or an empty string if the environment has none.
+func (v *Venv) Executable(name string) string {
+	path := filepath.Join(v.BinDir(), name)
+	if IsWindows() && filepath.Ext(name) == "" {
+		path += ".exe"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Python returns the path of the Python interpreter of the environment.
+func (v *Venv) Python() string {
+	return v.Executable("python")
+}
+
+// Environ returns env, a list of "key=value" entries such as os.Environ(),
+// as the activate script of the environment would change it.
+func (v *Venv) Environ(env []string) []string {
+	result := []string{}
+	path := ""
+	for _, entry := range env {
+		key := strings.SplitN(entry, "=", 2)[0]
+		switch {
+		case strings.EqualFold(key, "PATH"):
+			path = strings.TrimPrefix(entry[len(key):], "=")
+		case strings.EqualFold(key, "VIRTUAL_ENV"), strings.EqualFold(key, "PYTHONHOME"):
+		default:
+			result = append(result, entry)
+		}
+	}
+	paths := append(v.BinPaths(), path)
+	return append(result,
+		"VIRTUAL_ENV="+v.Path,
+		"PATH="+strings.Join(paths, string(os.PathListSeparator)),
+	)
+}
+
+// Command returns a command that runs the executable name in the environment,
+// e.g. "pip". Executables that the environment does not have are looked up
+// in the PATH.
+func (v *Venv) Command(name string, args ...string) *exec.Cmd {
+	if path := v.Executable(name); path != "" {
+		name = path
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Env = v.Environ(os.Environ())
+	return cmd
+}
+
+// Runner returns a runner for commands in dir that run in the environment.
+func (v *Venv) Runner(dir string) *Runner {
+	return &Runner{Dir: dir, Command: v.Command}
+}
+
+// ActivateVenv activates the virtual environment at path for langforge and
+// the processes it starts, like its activate script, and returns it.
+func ActivateVenv(path string) (*Venv, error) {
+	v, err := OpenVenv(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range v.Environ(os.Environ()) {
+		parts := strings.SplitN(entry, "=", 2)
+		Setenv(parts[0], parts[1])
+	}
+	Unsetenv("PYTHONHOME")
+	return v, nil
+}
+
+// RemoveVenv deletes the virtual environment at path. If it is active, it is
+// deactivated first. Directories that are not virtual environments are not
+// deleted.
+func RemoveVenv(path string) error {
+	v, err := OpenVenv(path)
+	if err != nil {
+		return err
+	}
+
+	if active := os.Getenv("VIRTUAL_ENV"); active != "" && samePath(active, v.Path) {
+		binDir := v.BinDir()
+		paths := []string{}
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			if !samePath(dir, binDir) {
+				paths = append(paths, dir)
+			}
+		}
+		Setenv("PATH", strings.Join(paths, string(os.PathListSeparator)))
+		Unsetenv("VIRTUAL_ENV")
+	}
+
+	if err := os.RemoveAll(v.Path); err != nil {
+		return fmt.Errorf("failed to remove the virtual environment %s: %v", path, err)
+	}
+	return nil
+}
+
+func samePath(a string, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if IsWindows() {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
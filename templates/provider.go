@@ -0,0 +1,108 @@
+package templates
+
+import (
+	"fmt"
+	"langforge/provider"
+	"strconv"
+)
+
+// Provider is the LLM provider of a project, with the LangChain integration
+// that the files of templates use, e.g. {{.Provider.PythonImport}} in a
+// notebook and {{.Provider.NpmPackage}} in package.json.
+type Provider struct {
+	*provider.Info
+	// PythonPackage is the requirement of the LangChain integration.
+	PythonPackage string
+	PythonImport  string
+	PythonClass   string
+	// PythonEmbeddings is the package, import and expression of the
+	// embeddings of the provider, and EmbeddingsModel the embeddings of the
+	// ingestion in langforge.yaml; providers without embeddings use local
+	// Hugging Face models.
+	PythonEmbeddingsPackage string
+	PythonEmbeddingsImport  string
+	PythonEmbeddings        string
+	EmbeddingsModel         string
+	EmbeddingsOptions       map[string]string
+	// NpmPackage is the package of the LangChain.js integration.
+	NpmPackage     string
+	NpmVersion     string
+	TypeScriptType string
+	// streaming is set if the chat models take a streaming parameter.
+	streaming bool
+}
+
+// providers are the LangChain integrations of the providers of
+// provider.Providers.
+var providers = map[string]*Provider{
+	"openai": {
+		PythonPackage:           "openai",
+		PythonImport:            "from langchain.chat_models import ChatOpenAI",
+		PythonClass:             "ChatOpenAI",
+		PythonEmbeddingsPackage: "tiktoken",
+		PythonEmbeddingsImport:  "from langchain.embeddings import OpenAIEmbeddings",
+		PythonEmbeddings:        "OpenAIEmbeddings()",
+		EmbeddingsModel:         "openai",
+		NpmPackage:              "@langchain/openai",
+		NpmVersion:              "^0.3.0",
+		TypeScriptType:          "ChatOpenAI",
+		streaming:               true,
+	},
+	"anthropic": {
+		PythonPackage:           "langchain-anthropic",
+		PythonImport:            "from langchain_anthropic import ChatAnthropic",
+		PythonClass:             "ChatAnthropic",
+		PythonEmbeddingsPackage: "sentence-transformers",
+		PythonEmbeddingsImport:  "from langchain.embeddings import HuggingFaceEmbeddings",
+		PythonEmbeddings:        "HuggingFaceEmbeddings()",
+		EmbeddingsModel:         "huggingface",
+		NpmPackage:              "@langchain/anthropic",
+		NpmVersion:              "^0.3.0",
+		TypeScriptType:          "ChatAnthropic",
+		streaming:               true,
+	},
+	"gemini": {
+		PythonPackage:          "langchain-google-genai",
+		PythonImport:           "from langchain_google_genai import ChatGoogleGenerativeAI",
+		PythonClass:            "ChatGoogleGenerativeAI",
+		PythonEmbeddingsImport: "from langchain_google_genai import GoogleGenerativeAIEmbeddings",
+		PythonEmbeddings:       `GoogleGenerativeAIEmbeddings(model="models/text-embedding-004")`,
+		EmbeddingsModel:        "gemini",
+		EmbeddingsOptions:      map[string]string{"model": "models/text-embedding-004"},
+		NpmPackage:             "@langchain/google-genai",
+		NpmVersion:             "^0.1.0",
+		TypeScriptType:         "ChatGoogleGenerativeAI",
+	},
+}
+
+// LookupProvider returns the provider with the given name or alias.
+func LookupProvider(name string) (*Provider, error) {
+	info, err := provider.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	integration, ok := providers[info.Name]
+	if !ok {
+		return nil, fmt.Errorf("templates do not support the provider %s", info.Name)
+	}
+	p := *integration
+	p.Info = info
+	return &p, nil
+}
+
+// PythonChatModel returns the Python expression that creates the chat model of
+// the provider with the given temperature.
+func (p *Provider) PythonChatModel(temperature float64) string {
+	expression := fmt.Sprintf("%s(model=%q, temperature=%s", p.PythonClass, p.ChatModel, strconv.FormatFloat(temperature, 'f', -1, 64))
+	if p.streaming {
+		expression += ", streaming=True"
+	}
+	return expression + ")"
+}
+
+// TypeScriptChatModel returns the TypeScript expression that creates the chat
+// model of the provider with the given temperature. LangChain.js models all
+// stream.
+func (p *Provider) TypeScriptChatModel(temperature float64) string {
+	return fmt.Sprintf("new %s({ model: %q, temperature: %s, streaming: true })", p.TypeScriptType, p.ChatModel, strconv.FormatFloat(temperature, 'f', -1, 64))
+}
@@ -0,0 +1,357 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"langforge/bundle"
+	"langforge/state"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed all:files
+var embeddedFS embed.FS
+
+func init() {
+	files, err := fs.Sub(embeddedFS, "files")
+	if err != nil {
+		panic(err)
+	}
+	bundle.Register(bundle.Projects, files)
+}
+
+// Languages of the templates.
+const (
+	Python     = "python"
+	TypeScript = "typescript"
+)
+
+// Sources of the templates.
+const (
+	// SourceBuiltin templates are part of langforge, or of the bundle of the
+	// user's configuration.
+	SourceBuiltin = "built-in"
+	// SourceUser templates are in the templates directory of the global
+	// directory of the user.
+	SourceUser = "user"
+	// SourceDir templates are given by the path of their directory.
+	SourceDir = "directory"
+)
+
+// ManifestName is the name of the file that describes a template. It is not
+// copied into projects.
+const ManifestName = "template.yaml"
+
+// DirName is the name of the directory in the global directory of the user
+// that holds the templates of the user, one per subdirectory.
+const DirName = "templates"
+
+// templateSuffix marks the files of a template that are rendered with
+// text/template. The suffix is removed from the name of the file.
+const templateSuffix = ".tmpl"
+
+// Template is the skeleton of a new project.
+type Template struct {
+	Name        string `yaml:"-"`
+	Description string `yaml:"description"`
+	// Language is python or typescript.
+	Language string `yaml:"language"`
+	// Entry is the notebook or module with the chains of the project.
+	Entry string `yaml:"entry"`
+	// APIKeys are the keys that the project needs in its .env file, besides
+	// the key of its provider.
+	APIKeys []string `yaml:"apiKeys,omitempty"`
+	// Providers are the LLM providers that the template can be rendered
+	// with, the first by default. Templates without providers do not use
+	// {{.Provider}}.
+	Providers []string `yaml:"providers,omitempty"`
+	// Source says where the template was found.
+	Source string `yaml:"-"`
+	files  fs.FS
+}
+
+// Data is passed to the files of a template that are rendered.
+type Data struct {
+	// Name is the name of the project.
+	Name string
+	// PackageName is the name of the project as a package name, e.g. in
+	// package.json.
+	PackageName string
+	// Provider is the LLM provider of the project, nil if the template has
+	// no providers.
+	Provider *Provider
+}
+
+// NewData returns the data of a project with the given name and provider,
+// which may be nil.
+func NewData(name string, provider *Provider) Data {
+	return Data{Name: name, PackageName: PackageName(name), Provider: provider}
+}
+
+var invalidPackageChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// PackageName converts the name of a project into a name that npm and pip
+// accept: lowercase, without spaces and other special characters.
+func PackageName(name string) string {
+	name = invalidPackageChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-._")
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+// List returns the templates of the user and the built-in ones, ordered by
+// name. A template of the user replaces the built-in one of the same name.
+func List() ([]*Template, error) {
+	byName := map[string]*Template{}
+
+	builtin, err := bundle.FS(bundle.Projects)
+	if err != nil {
+		return nil, err
+	}
+	if err := addTemplates(byName, builtin, SourceBuiltin); err != nil {
+		return nil, err
+	}
+	if dir, err := userDir(); err == nil {
+		if _, err := os.Stat(dir); err == nil {
+			if err := addTemplates(byName, os.DirFS(dir), SourceUser); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	list := []*Template{}
+	for _, t := range byName {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Find returns the template with the given name, or the template in the
+// directory if name is a path.
+func Find(name string) (*Template, error) {
+	if strings.ContainsAny(name, `/\`) || name == "." {
+		t, err := load(os.DirFS(name), filepath.Base(filepath.Clean(name)), SourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a template: %v", name, err)
+		}
+		return t, nil
+	}
+
+	list, err := List()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, t := range list {
+		if t.Name == name {
+			return t, nil
+		}
+		names = append(names, t.Name)
+	}
+	return nil, fmt.Errorf("unknown template %q, available templates: %s", name, strings.Join(names, ", "))
+}
+
+// Provider returns the provider that the template is rendered with: the one
+// with the given name, or the preferred one if the template supports it, or
+// the first of the template. It returns nil for templates without providers
+// and fails if the template does not support the named provider.
+func (t *Template) Provider(name string, preferred string) (*Provider, error) {
+	if len(t.Providers) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("the template %s does not support choosing a provider", t.Name)
+		}
+		return nil, nil
+	}
+	supports := func(name string) (*Provider, bool) {
+		p, err := LookupProvider(name)
+		if err != nil {
+			return nil, false
+		}
+		for _, supported := range t.Providers {
+			if supported == p.Name {
+				return p, true
+			}
+		}
+		return nil, false
+	}
+	if name != "" {
+		if p, ok := supports(name); ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("the template %s does not support the provider %s, it supports %s", t.Name, name, strings.Join(t.Providers, ", "))
+	}
+	if p, ok := supports(preferred); ok {
+		return p, nil
+	}
+	return LookupProvider(t.Providers[0])
+}
+
+// Keys returns the API keys that a project created from the template with
+// the provider needs in its .env file.
+func (t *Template) Keys(p *Provider) []string {
+	keys := append([]string{}, t.APIKeys...)
+	if p != nil && p.APIKey != "" {
+		keys = append(keys, p.APIKey)
+	}
+	return keys
+}
+
+// userDir returns the directory of the templates of the user.
+func userDir() (string, error) {
+	dir, err := state.GlobalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DirName), nil
+}
+
+// addTemplates adds the templates in the subdirectories of files.
+func addTemplates(byName map[string]*Template, files fs.FS, source string) error {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(files, path.Join(entry.Name(), ManifestName)); err != nil {
+			continue
+		}
+		sub, err := fs.Sub(files, entry.Name())
+		if err != nil {
+			return err
+		}
+		t, err := load(sub, entry.Name(), source)
+		if err != nil {
+			return fmt.Errorf("template %s: %v", entry.Name(), err)
+		}
+		byName[t.Name] = t
+	}
+	return nil
+}
+
+// load reads the manifest of the template in files.
+func load(files fs.FS, name string, source string) (*Template, error) {
+	data, err := fs.ReadFile(files, ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ManifestName, err)
+	}
+	switch t.Language {
+	case Python, TypeScript:
+	default:
+		return nil, fmt.Errorf("invalid language %q in %s, expected %s or %s", t.Language, ManifestName, Python, TypeScript)
+	}
+	if t.Entry == "" {
+		return nil, fmt.Errorf("%s has no entry", ManifestName)
+	}
+	for _, name := range t.Providers {
+		if _, err := LookupProvider(name); err != nil {
+			return nil, fmt.Errorf("invalid providers in %s: %v", ManifestName, err)
+		}
+	}
+	t.Name = name
+	t.Source = source
+	t.files = files
+	return t, nil
+}
+
+// Render writes the files of the template into dir and returns their paths
+// relative to dir. Files ending in .tmpl are rendered with data and written
+// without the suffix; all other files are copied. Existing files are an
+// error, nothing is written then.
+func (t *Template) Render(dir string, data Data) ([]string, error) {
+	type file struct {
+		path    string
+		content []byte
+		mode    os.FileMode
+	}
+
+	files := []file{}
+	err := fs.WalkDir(t.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || name == ManifestName {
+			return nil
+		}
+		content, err := fs.ReadFile(t.files, name)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(name, templateSuffix) {
+			name = strings.TrimSuffix(name, templateSuffix)
+			content, err = render(name, content, data)
+			if err != nil {
+				return err
+			}
+		}
+		mode := os.FileMode(0644)
+		if info, err := entry.Info(); err == nil && info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		files = append(files, file{path: filepath.FromSlash(name), content: content, mode: mode})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f.path)); err == nil {
+			return nil, fmt.Errorf("%s already exists", filepath.Join(dir, f.path))
+		}
+	}
+	paths := []string{}
+	for _, f := range files {
+		target := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, f.content, f.mode); err != nil {
+			return nil, err
+		}
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+// funcs are the functions of the files of templates: json writes a value as
+// JSON, e.g. code with quotes in the cells of notebooks.
+var funcs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+func render(name string, content []byte, data Data) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %v", name, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package testrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/envfile"
+	"langforge/python"
+	"langforge/system"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Options configures a test run. Runner is "pytest", "vitest" or "jest"; if it
+// is empty the runner is detected from the project.
+type Options struct {
+	Runner   string
+	JUnit    string
+	Coverage bool
+	Args     []string
+}
+
+// Detect returns the test runner of the project in dir. TypeScript projects
+// whose package.json depends on vitest or jest use that runner, all other
+// projects use pytest.
+func Detect(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return "pytest"
+	}
+
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return "pytest"
+	}
+
+	for _, runner := range []string{"vitest", "jest"} {
+		if _, ok := pkg.DevDependencies[runner]; ok {
+			return runner
+		}
+		if _, ok := pkg.Dependencies[runner]; ok {
+			return runner
+		}
+	}
+	return "pytest"
+}
+
+// Command builds the command that runs the tests of the project in dir. The
+// project's .env is added to the environment of the command without
+// overriding variables that are already set.
+func Command(dir string, options Options) (*exec.Cmd, error) {
+	runner := options.Runner
+	if runner == "" {
+		runner = Detect(dir)
+	}
+
+	junit := options.JUnit
+	if junit != "" && !filepath.IsAbs(junit) {
+		junit = filepath.Join(dir, junit)
+	}
+	if junit != "" {
+		if err := os.MkdirAll(filepath.Dir(junit), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	var cmd *exec.Cmd
+	switch runner {
+	case "pytest":
+		if err := ensurePytest(options.Coverage); err != nil {
+			return nil, err
+		}
+		pythonPath, err := system.FindPython()
+		if err != nil {
+			return nil, err
+		}
+		args := []string{"-m", "pytest"}
+		if junit != "" {
+			args = append(args, "--junitxml="+junit)
+		}
+		if options.Coverage {
+			args = append(args, "--cov=.", "--cov-report=term", "--cov-report=xml")
+		}
+		cmd = exec.Command(pythonPath, append(args, options.Args...)...)
+	case "vitest":
+		args := []string{"vitest", "run"}
+		if junit != "" {
+			args = append(args, "--reporter=default", "--reporter=junit", "--outputFile.junit="+junit)
+		}
+		if options.Coverage {
+			args = append(args, "--coverage")
+		}
+		npx, err := exec.LookPath("npx")
+		if err != nil {
+			return nil, fmt.Errorf("npx not found, install Node.js to run %s", runner)
+		}
+		cmd = exec.Command(npx, append(args, options.Args...)...)
+	case "jest":
+		args := []string{"jest", "--ci"}
+		if junit != "" {
+			args = append(args, "--reporters=default", "--reporters=jest-junit")
+		}
+		if options.Coverage {
+			args = append(args, "--coverage")
+		}
+		npx, err := exec.LookPath("npx")
+		if err != nil {
+			return nil, fmt.Errorf("npx not found, install Node.js to run %s", runner)
+		}
+		cmd = exec.Command(npx, append(args, options.Args...)...)
+	default:
+		return nil, fmt.Errorf("unsupported test runner %q, expected pytest, vitest or jest", runner)
+	}
+
+	env, err := envfile.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	// the output of failed tests may show the API keys of the .env file
+	masker := envfile.NewMasker(env)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = masker.Writer(os.Stdout)
+	cmd.Stderr = masker.Writer(os.Stderr)
+	cmd.Env = os.Environ()
+	if runner == "jest" && junit != "" {
+		cmd.Env = append(cmd.Env, "JEST_JUNIT_OUTPUT_FILE="+junit)
+	}
+	cmd.Env = envfile.Environ(cmd.Env, env)
+
+	return cmd, nil
+}
+
+// ensurePytest installs pytest, and pytest-cov for coverage, if they are missing.
+func ensurePytest(coverage bool) error {
+	packages, err := python.GetInstalledPackages()
+	if err != nil {
+		return err
+	}
+
+	installed := map[string]bool{}
+	for _, p := range packages {
+		installed[p.Name] = true
+	}
+
+	missing := []string{}
+	if !installed["pytest"] {
+		missing = append(missing, "pytest")
+	}
+	if coverage && !installed["pytest-cov"] {
+		missing = append(missing, "pytest-cov")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Installing %v...\n", missing)
+	return python.InstallPackages(missing)
+}
@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"fmt"
+	"langforge/diff"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// PrintPatch prints a patch with removed lines in red and added lines in green.
+func PrintPatch(patch *diff.Patch) {
+	fmt.Print(colorDiff(patch.String()))
+}
+
+func colorDiff(text string) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++"):
+			out.WriteString(pterm.Bold.Sprint(line))
+		case strings.HasPrefix(line, "@@"):
+			out.WriteString(pterm.FgCyan.Sprint(line))
+		case strings.HasPrefix(line, "-"):
+			out.WriteString(pterm.FgRed.Sprint(line))
+		case strings.HasPrefix(line, "+"):
+			out.WriteString(pterm.FgGreen.Sprint(line))
+		default:
+			out.WriteString(line)
+		}
+	}
+	return out.String()
+}
+
+// SelectHunks shows the hunks of a patch one at a time and returns the hunks
+// the user chose to apply.
+func SelectHunks(patch *diff.Patch) ([]*diff.Hunk, error) {
+	const (
+		apply     = "Apply this change"
+		skip      = "Skip this change"
+		applyRest = "Apply this and all remaining changes to the file"
+		skipRest  = "Skip this and all remaining changes to the file"
+	)
+
+	fmt.Print(pterm.Bold.Sprintf("--- %s\n+++ %s\n", patch.OldName, patch.NewName))
+	selected := []*diff.Hunk{}
+	for i, hunk := range patch.Hunks {
+		fmt.Print(colorDiff(hunk.String()))
+		choice, err := EditSelect(fmt.Sprintf("Change %d of %d", i+1, len(patch.Hunks)), []string{apply, skip, applyRest, skipRest}, false)
+		if err != nil {
+			return nil, err
+		}
+		switch choice {
+		case 0:
+			selected = append(selected, hunk)
+		case 2:
+			return append(selected, patch.Hunks[i:]...), nil
+		case 3:
+			return selected, nil
+		}
+	}
+	return selected, nil
+}
+
+// ReviewPatch shows a patch and asks whether to apply all of its changes,
+// choose them one at a time or apply none. It returns the new contents of the
+// file and whether any change was applied.
+func ReviewPatch(patch *diff.Patch) (string, bool, error) {
+	const (
+		all    = "Apply all changes"
+		choose = "Choose the changes to apply"
+		none   = "Apply no changes"
+	)
+
+	PrintPatch(patch)
+	choice, err := EditSelect(fmt.Sprintf("Apply the changes to %s?", strings.TrimPrefix(patch.NewName, "b/")), []string{all, choose, none}, false)
+	if err != nil {
+		return "", false, err
+	}
+
+	hunks := patch.Hunks
+	switch choice {
+	case 1:
+		hunks, err = SelectHunks(patch)
+		if err != nil {
+			return "", false, err
+		}
+	case 2:
+		hunks = nil
+	}
+	return patch.Apply(hunks), len(hunks) > 0, nil
+}
@@ -31,3 +31,17 @@ func PromptPassword(message string) (string, error) {
 	}
 	return password, nil
 }
+
+// PromptString asks for a line of text. An empty answer is the default value.
+func PromptString(message string, defaultValue string) (string, error) {
+	var value string
+	prompt := &survey.Input{
+		Message: message,
+		Default: defaultValue,
+	}
+	err := survey.AskOne(prompt, &value)
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/pterm/pterm"
 )
@@ -31,3 +32,34 @@ func EmptyLine() {
 func Bold(text string, args ...any) string {
 	return pterm.Bold.Sprintf(text, args...)
 }
+
+// PrintTable prints rows of data as a table with a header row.
+func PrintTable(header []string, rows [][]string) error {
+	data := pterm.TableData{header}
+	data = append(data, rows...)
+	return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+// IsInteractive reports whether stdin is a terminal, so that the user can be
+// prompted.
+func IsInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// StartProgress shows a progress bar with the given title in terminals and
+// returns nil otherwise.
+func StartProgress(title string, total int) *pterm.ProgressbarPrinter {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 || total == 0 {
+		return nil
+	}
+	bar, err := pterm.DefaultProgressbar.WithTotal(total).WithTitle(title).Start()
+	if err != nil {
+		return nil
+	}
+	return bar
+}
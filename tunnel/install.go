@@ -0,0 +1,212 @@
+package tunnel
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/system"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// cloudflaredVersion is the release of cloudflared that langforge downloads.
+// Its assets are verified against the SHA-256 checksums that GitHub records
+// for the release.
+const cloudflaredVersion = "2024.12.2"
+
+var (
+	cloudflaredDownloads = "https://github.com/cloudflare/cloudflared/releases/download/" + cloudflaredVersion + "/"
+	cloudflaredRelease   = "https://api.github.com/repos/cloudflare/cloudflared/releases/tags/" + cloudflaredVersion
+)
+
+// cloudflaredAsset returns the name of the release asset for this platform and
+// whether it is a gzipped tar archive.
+func cloudflaredAsset() (string, bool, error) {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/amd64", "linux/arm64", "linux/arm", "linux/386":
+		return "cloudflared-linux-" + runtime.GOARCH, false, nil
+	case "darwin/amd64", "darwin/arm64":
+		return "cloudflared-darwin-" + runtime.GOARCH + ".tgz", true, nil
+	case "windows/amd64", "windows/386":
+		return "cloudflared-windows-" + runtime.GOARCH + ".exe", false, nil
+	case "windows/arm64":
+		// there is no ARM build, Windows on ARM runs the x64 one under emulation
+		return "cloudflared-windows-amd64.exe", false, nil
+	}
+	return "", false, fmt.Errorf("cloudflared is not available for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// cloudflaredPath returns the path of the pinned cloudflared in the langforge
+// cache directory.
+func cloudflaredPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	name := "cloudflared-" + cloudflaredVersion
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(cacheDir, "langforge", "bin", name), nil
+}
+
+// installCloudflared returns the path of cloudflared in the langforge cache
+// directory. If it is not there, it asks confirm whether to download it and
+// verifies the download before it is made executable.
+func installCloudflared(confirm func(question string) bool) (string, error) {
+	target, err := cloudflaredPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+
+	asset, archive, err := cloudflaredAsset()
+	if err != nil {
+		return "", err
+	}
+	if confirm == nil || !confirm(fmt.Sprintf("cloudflared is not installed. Download cloudflared %s from GitHub?", cloudflaredVersion)) {
+		return "", fmt.Errorf("cloudflared is not installed, see https://developers.cloudflare.com/cloudflare-one/connections/connect-networks/downloads/")
+	}
+	checksum, err := cloudflaredChecksum(asset)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Downloading %s...\n", asset)
+
+	resp, err := http.Get(cloudflaredDownloads + asset)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	download, err := os.CreateTemp(filepath.Dir(target), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(download.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(download, hash), system.ProgressReader(asset, resp.ContentLength, resp.Body))
+	if closeErr := download.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		return "", fmt.Errorf("the SHA-256 checksum of %s is %s instead of %s", asset, sum, checksum)
+	}
+
+	binary := download.Name()
+	if archive {
+		binary, err = extractCloudflared(download.Name())
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(binary)
+	}
+	if err := os.Chmod(binary, 0755); err != nil {
+		return "", err
+	}
+	return target, os.Rename(binary, target)
+}
+
+// cloudflaredChecksum returns the SHA-256 checksum of a release asset as hex:
+// the digest that GitHub records for the asset or, for releases from before
+// GitHub recorded them, the one in the release notes.
+func cloudflaredChecksum(asset string) (string, error) {
+	resp, err := http.Get(cloudflaredRelease)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s of the cloudflared release", resp.Status)
+	}
+	var release struct {
+		Body   string `json:"body"`
+		Assets []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to read the cloudflared release: %v", err)
+	}
+	for _, a := range release.Assets {
+		if a.Name == asset && strings.HasPrefix(a.Digest, "sha256:") {
+			return strings.ToLower(strings.TrimPrefix(a.Digest, "sha256:")), nil
+		}
+	}
+	pattern := regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(asset) + `:\s*([0-9a-fA-F]{64})\s*$`)
+	if m := pattern.FindStringSubmatch(release.Body); m != nil {
+		return strings.ToLower(m[1]), nil
+	}
+	return "", fmt.Errorf("the cloudflared release %s has no checksum for %s", cloudflaredVersion, asset)
+}
+
+// extractCloudflared extracts cloudflared from the downloaded archive at path
+// into a temporary file next to it and returns the path of that file.
+func extractCloudflared(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	body, err := extractFile(file, "cloudflared")
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), ".cloudflared-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// extractFile returns a reader for the file with the given name in a gzipped
+// tar archive.
+func extractFile(r io.Reader, name string) (io.Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in the archive", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(header.Name) == name && header.Typeflag == tar.TypeReg {
+			return archive, nil
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package tunnel
+
+import (
+	"os"
+	"syscall"
+)
+
+// stop asks the tunnel client to close the tunnel and exit.
+func stop(process *os.Process) {
+	process.Signal(syscall.SIGTERM)
+}
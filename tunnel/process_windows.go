@@ -0,0 +1,12 @@
+//go:build windows
+
+package tunnel
+
+import "os"
+
+// stop terminates the tunnel client. Windows has no signal to request a
+// graceful exit, the tunnel is closed by the provider when the client
+// disconnects.
+func stop(process *os.Process) {
+	process.Kill()
+}
@@ -0,0 +1,161 @@
+package tunnel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Providers lists the supported tunnel clients in order of preference.
+var Providers = []string{"cloudflared", "ngrok"}
+
+// startTimeout is how long a tunnel client may take to report its URL.
+const startTimeout = 30 * time.Second
+
+// Tunnel is a running tunnel client that exposes a local port at a public
+// HTTPS URL.
+type Tunnel struct {
+	Provider string
+	URL      string
+	cmd      *exec.Cmd
+	done     chan struct{}
+}
+
+var (
+	cloudflaredURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+	ngrokURL       = regexp.MustCompile(`url=(https://\S+)`)
+)
+
+// Start exposes the local port through a tunnel. The provider is
+// "cloudflared", "ngrok" or empty to use whichever client is installed.
+// Without an installed client, cloudflared is downloaded if confirm agrees,
+// since its quick tunnels do not need an account.
+func Start(provider string, port int, confirm func(question string) bool) (*Tunnel, error) {
+	path, provider, err := findClient(provider, confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	local := fmt.Sprintf("http://localhost:%d", port)
+	var cmd *exec.Cmd
+	var pattern *regexp.Regexp
+	switch provider {
+	case "cloudflared":
+		cmd = exec.Command(path, "tunnel", "--no-autoupdate", "--url", local)
+		pattern = cloudflaredURL
+	case "ngrok":
+		cmd = exec.Command(path, "http", local, "--log", "stdout", "--log-format", "logfmt")
+		pattern = ngrokURL
+	}
+
+	// both clients log to either stream, the URL is taken from the first line
+	// that contains it
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting %s: %v", provider, err)
+	}
+
+	t := &Tunnel{Provider: provider, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		writer.Close()
+		close(t.done)
+	}()
+
+	found := make(chan string, 1)
+	var mu sync.Mutex
+	output := []string{}
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if m := pattern.FindStringSubmatch(line); m != nil {
+				select {
+				case found <- m[len(m)-1]:
+				default:
+				}
+			}
+			mu.Lock()
+			if len(output) < 20 {
+				output = append(output, line)
+			}
+			mu.Unlock()
+		}
+		// keep the client from blocking on a full pipe
+		io.Copy(io.Discard, reader)
+	}()
+
+	select {
+	case t.URL = <-found:
+		return t, nil
+	case <-t.done:
+		mu.Lock()
+		defer mu.Unlock()
+		return nil, fmt.Errorf("%s exited: %s", provider, strings.Join(output, "\n"))
+	case <-time.After(startTimeout):
+		t.Close()
+		return nil, fmt.Errorf("%s did not report a URL within %v", provider, startTimeout)
+	}
+}
+
+// Close stops the tunnel client and waits for it to exit.
+func (t *Tunnel) Close() {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	stop(t.cmd.Process)
+	select {
+	case <-t.done:
+	case <-time.After(5 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.done
+	}
+}
+
+// Done is closed when the tunnel client exits.
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// findClient returns the path of the tunnel client to use.
+func findClient(provider string, confirm func(question string) bool) (string, string, error) {
+	switch provider {
+	case "", "auto":
+		for _, name := range Providers {
+			if path, err := exec.LookPath(name); err == nil {
+				return path, name, nil
+			}
+		}
+		path, err := installCloudflared(confirm)
+		if err != nil {
+			return "", "", fmt.Errorf("no tunnel client found and downloading cloudflared failed: %v", err)
+		}
+		return path, "cloudflared", nil
+	case "cloudflared":
+		if path, err := exec.LookPath(provider); err == nil {
+			return path, provider, nil
+		}
+		path, err := installCloudflared(confirm)
+		if err != nil {
+			return "", "", fmt.Errorf("error downloading cloudflared: %v", err)
+		}
+		return path, provider, nil
+	case "ngrok":
+		path, err := exec.LookPath(provider)
+		if err != nil {
+			return "", "", fmt.Errorf("ngrok is not installed, see https://ngrok.com/download")
+		}
+		return path, provider, nil
+	default:
+		return "", "", fmt.Errorf("unknown tunnel provider %q, supported providers: %s", provider, strings.Join(Providers, ", "))
+	}
+}
@@ -0,0 +1,181 @@
+package userconfig
+
+import (
+	"langforge/state"
+	"langforge/system"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the configuration file in the global directory.
+const FileName = "config.yaml"
+
+// KeysFileName is the name of the file in the global directory that holds the
+// API keys of the user. New projects start with them.
+const KeysFileName = "keys.env"
+
+// Config is the configuration of the user that applies to all projects.
+type Config struct {
+	// Onboarded is set once the first-run setup was completed or declined.
+	Onboarded bool `yaml:"onboarded"`
+	// CreateEnvironment is the default answer to whether 'langforge create'
+	// creates a virtual environment.
+	CreateEnvironment *bool `yaml:"createEnvironment,omitempty"`
+	// Provider is the LLM provider of new applications whose template
+	// supports it, e.g. "anthropic"; OpenAI if empty.
+	Provider string `yaml:"provider,omitempty"`
+	// Bundle is the offline bundle that langforge reads its templates and
+	// integration index from, e.g. on an air-gapped machine.
+	Bundle BundleConfig `yaml:"bundle,omitempty"`
+}
+
+// BundleConfig configures the offline bundle of the user.
+type BundleConfig struct {
+	// Path is the path of the bundle written by 'langforge bundle export'.
+	Path string `yaml:"path,omitempty"`
+	// PublicKeys are the base64-encoded keys whose signatures of bundles are
+	// trusted.
+	PublicKeys []string `yaml:"publicKeys,omitempty"`
+}
+
+// Path returns the path of the configuration file.
+func Path() (string, error) {
+	dir, err := state.GlobalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Exists reports whether the configuration file exists, i.e. whether
+// langforge was set up on this machine.
+func Exists() bool {
+	path, err := Path()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Load reads the configuration of the user. A missing file is an empty
+// configuration.
+func Load() (*Config, error) {
+	config := &Config{}
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Save writes the configuration of the user.
+func Save(config *Config) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ShouldCreateEnvironment returns the default answer to whether a new project
+// gets a virtual environment, which is yes unless configured otherwise.
+func (c *Config) ShouldCreateEnvironment() bool {
+	return c.CreateEnvironment == nil || *c.CreateEnvironment
+}
+
+func keysPath() (string, error) {
+	dir, err := state.GlobalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, KeysFileName), nil
+}
+
+// Keys returns the API keys of the user.
+func Keys() (map[string]string, error) {
+	path, err := keysPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	return system.ReadEnv(path)
+}
+
+// SetKey stores an API key of the user. The file is only readable by the
+// user.
+func SetKey(name string, value string) error {
+	keys, err := Keys()
+	if err != nil {
+		return err
+	}
+	keys[name] = value
+
+	path, err := keysPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// create the file before writing the keys, so they are never readable
+	// by others
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	return system.WriteEnv(path, keys)
+}
+
+// ApplyKeys sets the API keys of the .env file at path that are missing or
+// empty to the keys of the user and returns the names of the keys it set.
+func ApplyKeys(dotEnvPath string, apiKeys []string) ([]string, error) {
+	keys, err := Keys()
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	env := map[string]string{}
+	if _, err := os.Stat(dotEnvPath); err == nil {
+		env, err = system.ReadEnv(dotEnvPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	applied := []string{}
+	for _, key := range apiKeys {
+		if env[key] == "" && keys[key] != "" {
+			env[key] = keys[key]
+			applied = append(applied, key)
+		}
+	}
+	if len(applied) == 0 {
+		return nil, nil
+	}
+	return applied, system.WriteEnv(dotEnvPath, env)
+}
@@ -0,0 +1,229 @@
+package vectorstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"langforge/watcher"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ingestState maps the documents that have been ingested, relative to the
+// project directory, to the SHA-256 of their contents.
+type ingestState map[string]string
+
+func ingestStatePath(config project.VectorStoreConfig) string {
+	return filepath.Clean(config.Path) + ".ingest.json"
+}
+
+func loadIngestState(config project.VectorStoreConfig) (ingestState, error) {
+	state := ingestState{}
+	data, err := os.ReadFile(ingestStatePath(config))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to read ingest state: %v", err)
+	}
+	return state, nil
+}
+
+func saveIngestState(config project.VectorStoreConfig, state ingestState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ingestStatePath(config)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(ingestStatePath(config), data, 0644)
+}
+
+func removeIngestState(config project.VectorStoreConfig) error {
+	err := os.Remove(ingestStatePath(config))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// the state of the sources of the ingestion pipeline
+	return os.RemoveAll(filepath.Clean(config.Path) + ".sources")
+}
+
+// snapshotDocuments hashes all documents in the docs directory.
+func snapshotDocuments(dir string, config project.VectorStoreConfig) (ingestState, error) {
+	state := ingestState{}
+	docs := filepath.Join(dir, config.Docs)
+	if _, err := os.Stat(docs); os.IsNotExist(err) {
+		return state, nil
+	}
+
+	err := filepath.Walk(docs, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := fileHash(path)
+		if err != nil {
+			return err
+		}
+		state[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	return state, err
+}
+
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WatchDocuments watches the docs directory of the project in dir and runs the
+// ingest script whenever documents are added, modified or removed. The script
+// receives the absolute paths of the affected documents, separated by the
+// path list separator, in LANGFORGE_INGEST_CHANGED and LANGFORGE_INGEST_DELETED
+// so it can update the store incrementally. Projects with an ingestion
+// pipeline instead run it whenever the documents of its sources change.
+// WatchDocuments blocks until stop is closed.
+func WatchDocuments(dir string, store Store, stop <-chan struct{}) error {
+	config := store.Config()
+	if HasPipeline(config) {
+		return watchSources(dir, store, stop)
+	}
+	if config.Ingest == "" {
+		return fmt.Errorf("no ingest script configured")
+	}
+
+	prefix := strings.Trim(filepath.ToSlash(config.Docs), "/") + "/"
+	options := watcher.Options{
+		Filter: func(rel string) bool {
+			return strings.HasPrefix(rel, prefix) || rel == "."
+		},
+	}
+
+	return watcher.Watch(dir, options, stop, func(paths []string) {
+		if err := ingestChanges(dir, store, paths); err != nil {
+			fmt.Println("Error re-ingesting documents:", err)
+		}
+	})
+}
+
+// ingestChanges runs the ingest script for the documents whose contents differ
+// from the recorded state.
+func ingestChanges(dir string, store Store, paths []string) error {
+	config := store.Config()
+
+	state, err := loadIngestState(config)
+	if err != nil {
+		return err
+	}
+
+	current, err := snapshotDocuments(dir, config)
+	if err != nil {
+		return err
+	}
+
+	changed := []string{}
+	deleted := []string{}
+	for rel, hash := range current {
+		if state[rel] != hash {
+			changed = append(changed, rel)
+		}
+	}
+	for rel := range state {
+		if _, ok := current[rel]; !ok {
+			deleted = append(deleted, rel)
+		}
+	}
+	if len(changed) == 0 && len(deleted) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+
+	fmt.Printf("Documents changed (%d updated, %d removed), running %s...\n", len(changed), len(deleted), config.Ingest)
+	env := map[string]string{
+		"LANGFORGE_INGEST_CHANGED": joinPaths(dir, changed),
+		"LANGFORGE_INGEST_DELETED": joinPaths(dir, deleted),
+	}
+	if err := runIngestScript(dir, store, config.Ingest, env, os.Stdout); err != nil {
+		return err
+	}
+
+	return saveIngestState(config, current)
+}
+
+// watchSources runs the ingestion pipeline whenever the documents of its
+// sources in the project change. Sources in object stores and services cannot
+// be watched, their changes are ingested by 'langforge ingest'.
+func watchSources(dir string, store Store, stop <-chan struct{}) error {
+	sources, err := resolveSources(store.Config().Ingestion)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	prefixes := []string{}
+	for _, source := range sources {
+		if source.location == nil && source.connector == nil {
+			names = append(names, source.Name)
+			prefixes = append(prefixes, filepath.ToSlash(filepath.Clean(source.Path)))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	options := watcher.Options{
+		Filter: func(rel string) bool {
+			for _, prefix := range prefixes {
+				if rel == "." || rel == prefix || strings.HasPrefix(rel, prefix+"/") || prefix == "." {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	return watcher.Watch(dir, options, stop, func(paths []string) {
+		results, err := Ingest(context.Background(), dir, store, IngestOptions{Sources: names})
+		if err != nil {
+			fmt.Println("Error re-ingesting documents:", err)
+			return
+		}
+		for _, result := range results {
+			if result.Ingested > 0 || result.Deleted > 0 {
+				fmt.Printf("Re-ingested %s: %d documents updated, %d removed.\n", result.Source, result.Ingested, result.Deleted)
+			}
+			for _, failure := range result.Failed {
+				fmt.Printf("Error re-ingesting %s: %s\n", failure.Document, failure.Message)
+			}
+		}
+	})
+}
+
+func joinPaths(dir string, rels []string) string {
+	paths := make([]string, len(rels))
+	for i, rel := range rels {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(rel))
+	}
+	return strings.Join(paths, string(os.PathListSeparator))
+}
@@ -0,0 +1,146 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/connectors"
+	"langforge/project"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fullSyncInterval is how often the sources of connectors are listed
+// completely rather than since their cursor, which finds the documents that
+// were removed in ways that the services do not report as changes.
+const fullSyncInterval = 24 * time.Hour
+
+// syncState mirrors the documents of the source of a connector, by their
+// IDs, as of the cursor of its last sync.
+type syncState struct {
+	Cursor       string                         `json:"cursor"`
+	LastComplete time.Time                      `json:"lastComplete"`
+	Documents    map[string]connectors.Document `json:"documents"`
+}
+
+func syncStatePath(config project.VectorStoreConfig, source string) string {
+	return strings.TrimSuffix(sourceStatePath(config, source), ".json") + ".sync.json"
+}
+
+func loadSyncState(config project.VectorStoreConfig, source string) (*syncState, error) {
+	state := &syncState{}
+	data, err := os.ReadFile(syncStatePath(config, source))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("failed to read the sync state of %s: %v", source, err)
+		}
+	}
+	if state.Documents == nil {
+		state.Documents = map[string]connectors.Document{}
+	}
+	return state, nil
+}
+
+func saveSyncState(config project.VectorStoreConfig, source string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := syncStatePath(config, source)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// syncConnector updates the mirror of the documents of the source of a
+// connector and returns the versions of those that it includes, by their
+// URLs, with the documents themselves. The mirror is saved right away: the
+// state of the source tells which versions were ingested.
+func (s ingestSource) syncConnector(ctx context.Context, connector connectors.Connector, config project.VectorStoreConfig, full bool) (map[string]string, map[string]connectors.Document, error) {
+	state, err := loadSyncState(config, s.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursor := state.Cursor
+	if full || time.Since(state.LastComplete) > fullSyncInterval {
+		cursor = ""
+	}
+	changes, err := connector.Changes(ctx, cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sync the ingestion source %s: %w", s.Name, err)
+	}
+	if changes.Complete {
+		state.Documents = map[string]connectors.Document{}
+		state.LastComplete = time.Now()
+	}
+	for _, id := range changes.Removed {
+		delete(state.Documents, id)
+	}
+	for _, document := range changes.Documents {
+		state.Documents[document.ID] = document
+	}
+	state.Cursor = changes.Cursor
+	if err := saveSyncState(config, s.Name, state); err != nil {
+		return nil, nil, err
+	}
+
+	versions := map[string]string{}
+	documents := map[string]connectors.Document{}
+	for _, document := range state.Documents {
+		if s.matches(document.Path) {
+			versions[document.URL] = document.Version
+			documents[document.URL] = document
+		}
+	}
+	return versions, documents, nil
+}
+
+// fetchDocuments writes the documents of the source of a connector into
+// files in a new directory of the state directory of the project in dir, for
+// the ingest script to load, and returns the directory and the files. The
+// files keep the extensions of the documents, which select their loaders.
+func (s ingestSource) fetchDocuments(ctx context.Context, connector connectors.Connector, dir string, documents []connectors.Document) (string, []string, error) {
+	stateDir, err := project.EnsureStateDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	fetchDir, err := os.MkdirTemp(stateDir, "fetch-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	paths := make([]string, len(documents))
+	for i, document := range documents {
+		file := filepath.Join(fetchDir, strconv.Itoa(i), path.Base(document.Path))
+		if err := fetchDocument(ctx, connector, document, file); err != nil {
+			os.RemoveAll(fetchDir)
+			return "", nil, fmt.Errorf("failed to fetch %s of the ingestion source %s: %w", document.Path, s.Name, err)
+		}
+		paths[i] = file
+	}
+	return fetchDir, paths, nil
+}
+
+func fetchDocument(ctx context.Context, connector connectors.Connector, document connectors.Document, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = connector.Fetch(ctx, document, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
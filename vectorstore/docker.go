@@ -0,0 +1,225 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"langforge/project"
+	"langforge/system"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dockerStore is a vector store server running in a local docker container
+// whose data is kept in the project's vector store directory.
+type dockerStore struct {
+	config    project.VectorStoreConfig
+	container string
+}
+
+var invalidContainerChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+func (s *dockerStore) containerName() string {
+	return invalidContainerChars.ReplaceAllString(s.container, "-")
+}
+
+func (s *dockerStore) port() string {
+	if u, err := url.Parse(s.config.URL); err == nil && u.Port() != "" {
+		return u.Port()
+	}
+	if s.config.Type == "qdrant" {
+		return "6333"
+	}
+	return "8000"
+}
+
+func (s *dockerStore) running() bool {
+	output, err := exec.Command("docker", "inspect", "-f", "{{.State.Running}}", s.containerName()).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+func (s *dockerStore) Init() error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker not found, install docker or use the embedded vector store mode")
+	}
+
+	if s.running() {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.config.Path, 0755); err != nil {
+		return err
+	}
+
+	// remove a stopped container with the same name
+	exec.Command("docker", "rm", "-f", s.containerName()).Run()
+
+	args := []string{"run", "-d", "--name", s.containerName()}
+	switch s.config.Type {
+	case "qdrant":
+		args = append(args, "-p", s.port()+":6333", "-v", s.config.Path+":/qdrant/storage", "qdrant/qdrant")
+	case "chroma":
+		args = append(args, "-p", s.port()+":8000", "-v", s.config.Path+":/chroma/chroma", "-e", "IS_PERSISTENT=TRUE", "chromadb/chroma")
+	}
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if system.SkipDryRun(cmd) {
+		return nil
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to start %s container: %v", s.config.Type, err)
+	}
+
+	// wait for the server to accept requests
+	for i := 0; i < 30; i++ {
+		if _, err := serverCollections(s.config); err == nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("%s did not become ready at %s", s.config.Type, s.config.URL)
+}
+
+func (s *dockerStore) Reset() error {
+	if s.running() {
+		collections, err := serverCollections(s.config)
+		if err != nil {
+			return err
+		}
+		for _, collection := range collections {
+			if err := s.deleteCollection(collection.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	exec.Command("docker", "rm", "-f", s.containerName()).Run()
+	if err := removeIngestState(s.config); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.config.Path)
+}
+
+func (s *dockerStore) Status() (*Status, error) {
+	status := &Status{
+		Type:        s.config.Type,
+		Mode:        s.config.Mode,
+		Location:    s.config.URL,
+		Collections: []CollectionStatus{},
+	}
+
+	collections, err := serverCollections(s.config)
+	if err != nil {
+		return status, nil
+	}
+
+	status.Available = true
+	status.Collections = collections
+	return status, nil
+}
+
+func (s *dockerStore) Config() project.VectorStoreConfig {
+	return s.config
+}
+
+func (s *dockerStore) Env() map[string]string {
+	env := baseEnv(s.config)
+	env["LANGFORGE_VECTORSTORE_URL"] = s.config.URL
+	return env
+}
+
+// serverCollections returns the collections of a Chroma or Qdrant server.
+func serverCollections(config project.VectorStoreConfig) ([]CollectionStatus, error) {
+	collections := []CollectionStatus{}
+
+	switch config.Type {
+	case "qdrant":
+		var list struct {
+			Result struct {
+				Collections []struct {
+					Name string `json:"name"`
+				} `json:"collections"`
+			} `json:"result"`
+		}
+		if err := getJSON(config, "/collections", &list); err != nil {
+			return nil, err
+		}
+		for _, c := range list.Result.Collections {
+			var info struct {
+				Result struct {
+					PointsCount int `json:"points_count"`
+				} `json:"result"`
+			}
+			if err := getJSON(config, "/collections/"+url.PathEscape(c.Name), &info); err != nil {
+				return nil, err
+			}
+			collections = append(collections, CollectionStatus{Name: c.Name, Count: info.Result.PointsCount})
+		}
+	case "chroma":
+		var list []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := getJSON(config, "/api/v1/collections", &list); err != nil {
+			return nil, err
+		}
+		for _, c := range list {
+			var count int
+			if err := getJSON(config, "/api/v1/collections/"+url.PathEscape(c.ID)+"/count", &count); err != nil {
+				return nil, err
+			}
+			collections = append(collections, CollectionStatus{Name: c.Name, Count: count})
+		}
+	}
+
+	return collections, nil
+}
+
+func (s *dockerStore) deleteCollection(name string) error {
+	path := "/collections/" + url.PathEscape(name)
+	if s.config.Type == "chroma" {
+		path = "/api/v1/collections/" + url.PathEscape(name)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, strings.TrimSuffix(s.config.URL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to delete collection %q: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// getJSON decodes the JSON response of the store server to a GET request.
+func getJSON(config project.VectorStoreConfig, path string, v any) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(config.URL, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return json.Unmarshal(data, v)
+}
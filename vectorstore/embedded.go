@@ -0,0 +1,72 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"os"
+)
+
+// embeddedStore is a vector store that lives in a directory and is accessed
+// in-process by the chromadb or qdrant-client library.
+type embeddedStore struct {
+	config project.VectorStoreConfig
+}
+
+func (s *embeddedStore) Init() error {
+	if err := ensureClient(s.config.Type); err != nil {
+		return err
+	}
+	return os.MkdirAll(s.config.Path, 0755)
+}
+
+func (s *embeddedStore) Reset() error {
+	if err := removeIngestState(s.config); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.config.Path)
+}
+
+func (s *embeddedStore) Status() (*Status, error) {
+	status := &Status{
+		Type:        s.config.Type,
+		Mode:        s.config.Mode,
+		Location:    s.config.Path,
+		Collections: []CollectionStatus{},
+	}
+
+	if _, err := os.Stat(s.config.Path); err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return nil, err
+	}
+
+	script, err := python.VectorStoreStatusPy()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := python.RunScript(script, s.config.Type, s.config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(output, &status.Collections); err != nil {
+		return nil, fmt.Errorf("failed to read vector store status: %v", err)
+	}
+	status.Available = true
+
+	return status, nil
+}
+
+func (s *embeddedStore) Config() project.VectorStoreConfig {
+	return s.config
+}
+
+func (s *embeddedStore) Env() map[string]string {
+	env := baseEnv(s.config)
+	env["LANGFORGE_VECTORSTORE_PATH"] = s.config.Path
+	return env
+}
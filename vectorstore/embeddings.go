@@ -0,0 +1,104 @@
+package vectorstore
+
+import (
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"langforge/sqlite"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EmbeddingsCacheFileName is the name of the embeddings cache in the project's
+// state directory.
+const EmbeddingsCacheFileName = "embeddings.db"
+
+// embeddingsModule is the module of the Python helper that ingest scripts
+// import, e.g. 'from langforge_embeddings import cached_embeddings'. Workers
+// have the helper built in.
+const embeddingsModule = "langforge_embeddings"
+
+// CachedModel holds the number and size of the embeddings of a model in the
+// embeddings cache. Queries and documents are counted separately, since some
+// models embed them differently.
+type CachedModel struct {
+	Model string `json:"model"`
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// EmbeddingsCachePath returns the path of the embeddings cache of a resolved
+// vector store configuration, or "" if the cache is off.
+func EmbeddingsCachePath(config project.VectorStoreConfig) string {
+	if config.EmbeddingsCache == nil {
+		return ""
+	}
+	return config.EmbeddingsCache.Path
+}
+
+// EmbeddingsCacheStats returns the models in the embeddings cache at path.
+// The cache is written by the Python helper, so it does not exist until
+// something was embedded.
+func EmbeddingsCacheStats(path string) ([]CachedModel, error) {
+	models := []CachedModel{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return models, nil
+	}
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Query(`SELECT model, kind, count(*) AS count, sum(length(vector)) AS bytes
+FROM embeddings GROUP BY model, kind ORDER BY model, kind;`, &models)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// ClearEmbeddingsCache deletes the embeddings of model from the cache at
+// path, or the whole cache if model is empty.
+func ClearEmbeddingsCache(path string, model string) error {
+	if model == "" {
+		// the write-ahead log and its index belong to the database
+		for _, file := range []string{path, path + "-wal", path + "-shm"} {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return err
+	}
+	return db.Exec(fmt.Sprintf("DELETE FROM embeddings WHERE model = %s;", sqlite.Quote(model)))
+}
+
+// embeddingsEnv returns the environment that lets the ingest script of the
+// project in dir import the helper of the embeddings cache: the directory of
+// the module on PYTHONPATH.
+func embeddingsEnv(dir string) (map[string]string, error) {
+	moduleDir, err := project.EnsureStateDir(dir, "python")
+	if err != nil {
+		return nil, err
+	}
+	code, err := python.EmbeddingsPy()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(moduleDir, embeddingsModule+".py"), code, 0644); err != nil {
+		return nil, err
+	}
+
+	paths := []string{moduleDir}
+	if existing := os.Getenv("PYTHONPATH"); existing != "" {
+		paths = append(paths, existing)
+	}
+	return map[string]string{"PYTHONPATH": strings.Join(paths, string(os.PathListSeparator))}, nil
+}
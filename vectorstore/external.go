@@ -0,0 +1,63 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"langforge/project"
+	"time"
+)
+
+// externalStore is a vector store server that langforge does not manage, such
+// as the store of a deployment. It is only checked and ingested into.
+type externalStore struct {
+	config project.VectorStoreConfig
+}
+
+func (s *externalStore) check() error {
+	check, err := healthCheck(s.config)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return check.Check(ctx)
+}
+
+func (s *externalStore) Init() error {
+	return s.check()
+}
+
+func (s *externalStore) Reset() error {
+	return fmt.Errorf("the %s store at %s is external, reset it where it is managed", s.config.Type, s.config.URL)
+}
+
+func (s *externalStore) Status() (*Status, error) {
+	status := &Status{
+		Type:        s.config.Type,
+		Mode:        s.config.Mode,
+		Location:    s.config.URL,
+		Collections: []CollectionStatus{},
+	}
+	if s.config.Type == "pgvector" {
+		status.Available = s.check() == nil
+		return status, nil
+	}
+
+	collections, err := serverCollections(s.config)
+	if err != nil {
+		return status, nil
+	}
+	status.Available = true
+	status.Collections = collections
+	return status, nil
+}
+
+func (s *externalStore) Config() project.VectorStoreConfig {
+	return s.config
+}
+
+func (s *externalStore) Env() map[string]string {
+	env := baseEnv(s.config)
+	env["LANGFORGE_VECTORSTORE_URL"] = s.config.URL
+	return env
+}
@@ -0,0 +1,92 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"langforge/project"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// HealthCheck checks that the backend of a vector store is usable, so that a
+// deployment does not report ready before retrieval works.
+type HealthCheck interface {
+	Check(ctx context.Context) error
+}
+
+// HealthCheckFunc adapts a function to a HealthCheck.
+type HealthCheckFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f HealthCheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// HealthCheckFactory creates the health check of the server of a vector store
+// from its resolved configuration.
+type HealthCheckFactory func(config project.VectorStoreConfig) (HealthCheck, error)
+
+var healthChecks = map[string]HealthCheckFactory{
+	"chroma": func(config project.VectorStoreConfig) (HealthCheck, error) {
+		return httpCheck(config, "/api/v1/heartbeat"), nil
+	},
+	"qdrant": func(config project.VectorStoreConfig) (HealthCheck, error) {
+		return httpCheck(config, "/collections"), nil
+	},
+	"pgvector": newPostgresCheck,
+}
+
+// RegisterHealthCheck registers the health check of the servers of a type of
+// vector store. Custom builds of langforge register the checks of further
+// stores in an init function.
+func RegisterHealthCheck(storeType string, factory HealthCheckFactory) {
+	if _, ok := healthChecks[storeType]; ok {
+		panic(fmt.Sprintf("vectorstore: a health check for %s is already registered", storeType))
+	}
+	healthChecks[storeType] = factory
+}
+
+// NewHealthCheck returns the health check of the vector store configured for
+// the project in dir. Embedded stores are healthy once their directory has
+// been created; the servers of docker and external stores are asked whether
+// they are usable.
+func NewHealthCheck(dir string, config project.VectorStoreConfig) (HealthCheck, error) {
+	return healthCheck(Resolve(dir, config))
+}
+
+func healthCheck(config project.VectorStoreConfig) (HealthCheck, error) {
+	if config.Mode == "embedded" {
+		return HealthCheckFunc(func(ctx context.Context) error {
+			if _, err := os.Stat(config.Path); err != nil {
+				return fmt.Errorf("%s is not initialized at %s", config.Type, config.Path)
+			}
+			return nil
+		}), nil
+	}
+
+	factory, ok := healthChecks[config.Type]
+	if !ok {
+		return nil, fmt.Errorf("no health check for vector store type %q", config.Type)
+	}
+	return factory(config)
+}
+
+// httpCheck checks that the server answers a GET request to path.
+func httpCheck(config project.VectorStoreConfig, path string) HealthCheck {
+	return HealthCheckFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(config.URL, "/")+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s is not reachable at %s: %v", config.Type, config.URL, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s at %s answered %s with %s", config.Type, config.URL, path, resp.Status)
+		}
+		return nil
+	})
+}
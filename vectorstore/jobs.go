@@ -0,0 +1,75 @@
+package vectorstore
+
+import (
+	"fmt"
+	"io"
+	"langforge/jobs"
+	"langforge/project"
+	"sort"
+)
+
+// IngestJobKind is the kind of the jobs that ingest documents in batches.
+const IngestJobKind = "ingest"
+
+func init() {
+	jobs.Register(IngestJobKind, runIngestBatch)
+}
+
+// EnqueueIngest creates a job that ingests all documents in the docs directory
+// of the project in dir, batchSize documents at a time.
+func EnqueueIngest(dir string, store Store, batchSize int) (*jobs.Job, error) {
+	config := store.Config()
+	if config.Ingest == "" && HasPipeline(config) {
+		return nil, fmt.Errorf("the ingestion sources are ingested with 'langforge ingest'")
+	}
+	if config.Ingest == "" {
+		return nil, fmt.Errorf("no ingest script configured")
+	}
+
+	documents, err := snapshotDocuments(dir, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("no documents found in %s", config.Docs)
+	}
+
+	items := make([]string, 0, len(documents))
+	for rel := range documents {
+		items = append(items, rel)
+	}
+	sort.Strings(items)
+
+	return jobs.Enqueue(dir, IngestJobKind, items, batchSize, nil)
+}
+
+// runIngestBatch runs the ingest script for a batch of documents. After the
+// last batch the ingested documents are recorded for incremental ingestion.
+func runIngestBatch(dir string, job *jobs.Job, batch []string, log io.Writer) error {
+	config, err := project.LoadConfig(dir)
+	if err != nil {
+		return err
+	}
+	store, err := New(dir, config)
+	if err != nil {
+		return err
+	}
+
+	env := map[string]string{
+		"LANGFORGE_INGEST_CHANGED": joinPaths(dir, batch),
+		"LANGFORGE_INGEST_DELETED": "",
+		"LANGFORGE_JOB_ID":         job.ID,
+	}
+	if err := runIngestScript(dir, store, store.Config().Ingest, env, log); err != nil {
+		return err
+	}
+
+	if job.Done+len(batch) < len(job.Items) {
+		return nil
+	}
+	state, err := snapshotDocuments(dir, store.Config())
+	if err != nil {
+		return err
+	}
+	return saveIngestState(store.Config(), state)
+}
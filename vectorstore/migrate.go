@@ -0,0 +1,150 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"strings"
+)
+
+// clientPackages are the Python packages that access the stores of a type, the
+// first of which is installed if none is.
+var clientPackages = map[string][]string{
+	"chroma":   {"chromadb"},
+	"qdrant":   {"qdrant-client"},
+	"pgvector": {"psycopg[binary]", "psycopg2", "psycopg2-binary"},
+}
+
+// ensureClient installs the Python client of a type of store unless one is
+// installed.
+func ensureClient(storeType string) error {
+	return ensurePackage(clientPackages[storeType])
+}
+
+// ensurePackage installs the first of the candidates, Python packages that
+// provide the same modules, unless one is installed.
+func ensurePackage(candidates []string) error {
+	installed, err := python.GetInstalledPackages()
+	if err != nil {
+		return err
+	}
+	for _, p := range installed {
+		for _, name := range candidates {
+			if strings.EqualFold(p.Name, strings.SplitN(name, "[", 2)[0]) {
+				return nil
+			}
+		}
+	}
+
+	fmt.Printf("Installing %s...\n", candidates[0])
+	return python.InstallPackages(candidates[:1])
+}
+
+// Endpoint returns the configuration of a store of the given type to migrate
+// from or to in the project in dir. The store configured in the project is
+// used if it has the type and url is empty. Otherwise the store is external
+// at url or, without url, embedded in the project's vector store directory.
+func Endpoint(dir string, projectConfig *project.Config, storeType string, url string) (Store, error) {
+	configured := Resolve(dir, projectConfig.VectorStore)
+	config := project.VectorStoreConfig{
+		Type:       storeType,
+		URL:        url,
+		Collection: configured.Collection,
+	}
+	if url != "" {
+		config.Mode = "external"
+	}
+	if storeType == configured.Type && url == "" {
+		config = configured
+	}
+	return New(dir, &project.Config{Name: projectConfig.Name, VectorStore: config})
+}
+
+// MigrateOptions configures a migration between vector stores.
+type MigrateOptions struct {
+	// Collection is the collection to copy, which has the same name in the
+	// target store.
+	Collection string
+	// BatchSize is the number of records that are read and written at a time.
+	BatchSize int
+	// Progress is called after every batch with the number of records that
+	// have been copied and the number of records of the collection.
+	Progress func(migrated int, total int)
+}
+
+// Migrate copies the ids, embeddings, documents and metadata of a collection
+// from the source store to the target store batch by batch, and returns the
+// number of copied records. Records are upserted, so a migration that was
+// interrupted can be run again. Records are written in the layout of the
+// LangChain integration of the target store.
+func Migrate(ctx context.Context, source Store, target Store, options MigrateOptions) (int, error) {
+	from, to := source.Config(), target.Config()
+	if from.Type == to.Type && from.Mode == to.Mode && from.Path == to.Path && from.URL == to.URL {
+		return 0, fmt.Errorf("the source and the target are the same %s store", from.Type)
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+
+	if err := ensureClient(from.Type); err != nil {
+		return 0, err
+	}
+	if err := ensureClient(to.Type); err != nil {
+		return 0, err
+	}
+	status, err := source.Status()
+	if err != nil {
+		return 0, err
+	}
+	if !status.Available {
+		return 0, fmt.Errorf("the %s store at %s is not available", status.Type, status.Location)
+	}
+	if err := target.Init(); err != nil {
+		return 0, err
+	}
+
+	script, err := python.VectorStoreMigratePy()
+	if err != nil {
+		return 0, err
+	}
+	endpoint := func(config project.VectorStoreConfig) map[string]string {
+		return map[string]string{"type": config.Type, "mode": config.Mode, "path": config.Path, "url": config.URL}
+	}
+	spec, err := json.Marshal(map[string]interface{}{
+		"source":     endpoint(from),
+		"target":     endpoint(to),
+		"collection": options.Collection,
+		"batchSize":  options.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	total, migrated := 0, 0
+	err = python.StreamScript(ctx, script, func(line string) {
+		var report struct {
+			Total    *int `json:"total"`
+			Migrated *int `json:"migrated"`
+		}
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			// output of the clients
+			fmt.Println(line)
+			return
+		}
+		if report.Total != nil {
+			total = *report.Total
+		}
+		if report.Migrated != nil {
+			migrated = *report.Migrated
+		}
+		if options.Progress != nil {
+			options.Progress(migrated, total)
+		}
+	}, string(spec))
+	if err != nil {
+		return migrated, fmt.Errorf("migration from %s to %s failed after %d records: %w", from.Type, to.Type, migrated, err)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,180 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"langforge/system"
+	"langforge/userconfig"
+	"os"
+	"strings"
+)
+
+// objectPackages are the Python packages of the clients of the object stores
+// that ingestion sources can be in, by the scheme of their URLs.
+var objectPackages = map[string][]string{
+	"s3":    {"boto3"},
+	"gs":    {"google-cloud-storage"},
+	"azure": {"azure-storage-blob"},
+}
+
+// objectLocation is the prefix of an object store that an ingestion source
+// ingests the objects under. Bucket is the container of Azure.
+type objectLocation struct {
+	Scheme  string `json:"scheme"`
+	Account string `json:"account,omitempty"`
+	Bucket  string `json:"bucket"`
+	Prefix  string `json:"prefix"`
+}
+
+// URL returns the URL of the object with the given key, which identifies its
+// document in the store and the state of the source.
+func (l *objectLocation) URL(key string) string {
+	if l.Scheme == "azure" {
+		return fmt.Sprintf("azure://%s/%s/%s", l.Account, l.Bucket, key)
+	}
+	return fmt.Sprintf("%s://%s/%s", l.Scheme, l.Bucket, key)
+}
+
+// parseObjectURL parses the path of an ingestion source, returning nil if it
+// is a path of the file system rather than the URL of an object store:
+// s3://bucket/prefix, gs://bucket/prefix or azure://account/container/prefix.
+// The prefix is a directory of the store, so it ends with a slash unless it
+// is empty.
+func parseObjectURL(path string) (*objectLocation, error) {
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok {
+		return nil, nil
+	}
+	if _, known := objectPackages[scheme]; !known {
+		return nil, fmt.Errorf("unsupported object store %s://, use s3://, gs:// or azure://", scheme)
+	}
+
+	location := &objectLocation{Scheme: scheme}
+	if scheme == "azure" {
+		location.Account, rest, _ = strings.Cut(rest, "/")
+	}
+	location.Bucket, location.Prefix, _ = strings.Cut(rest, "/")
+	if location.Bucket == "" || (scheme == "azure" && location.Account == "") {
+		if scheme == "azure" {
+			return nil, fmt.Errorf("invalid object store URL %s, expected azure://account/container/prefix", path)
+		}
+		return nil, fmt.Errorf("invalid object store URL %s, expected %s://bucket/prefix", path, scheme)
+	}
+	if location.Prefix != "" && !strings.HasSuffix(location.Prefix, "/") {
+		location.Prefix += "/"
+	}
+	return location, nil
+}
+
+// expandCredentials expands the references to variables in the credentials
+// of a source with the environment, the .env file of the project in dir and
+// the keys of the user, in this order. A reference to a variable that is not
+// set is an error, since the client would fail with a less clear one.
+func expandCredentials(dir string, source string, credentials map[string]string) (map[string]string, error) {
+	if len(credentials) == 0 {
+		return map[string]string{}, nil
+	}
+	dotEnv, err := system.GetEnv(dir)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := userconfig.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := map[string]string{}
+	missing := []string{}
+	for name, value := range credentials {
+		expanded[name] = os.Expand(value, func(key string) string {
+			if value, ok := os.LookupEnv(key); ok {
+				return value
+			}
+			if value, ok := dotEnv[key]; ok {
+				return value
+			}
+			if value, ok := keys[key]; ok {
+				return value
+			}
+			missing = append(missing, key)
+			return ""
+		})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the credentials of the ingestion source %s reference %s, which is not set; set it in .env or with 'langforge keys'", source, strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// listObjects returns the ETags of the objects of a source in an object store
+// that it includes, by their URLs. The ETag of an object changes whenever its
+// contents do.
+func (s ingestSource) listObjects(ctx context.Context, dir string) (map[string]string, error) {
+	if err := ensurePackage(objectPackages[s.location.Scheme]); err != nil {
+		return nil, err
+	}
+
+	// the credentials are not passed on the command line, where other users
+	// could see them
+	spec, err := json.Marshal(map[string]interface{}{
+		"location":    s.location,
+		"credentials": s.credentials,
+	})
+	if err != nil {
+		return nil, err
+	}
+	specFile, err := writeSpecFile(dir, "list-*.json", spec)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(specFile)
+
+	script, err := python.VectorStoreListPy()
+	if err != nil {
+		return nil, err
+	}
+	objects := map[string]string{}
+	err = python.StreamScript(ctx, script, func(line string) {
+		var object struct {
+			Key  string `json:"key"`
+			ETag string `json:"etag"`
+		}
+		if err := json.Unmarshal([]byte(line), &object); err != nil || object.Key == "" {
+			fmt.Println(line)
+			return
+		}
+		if s.matches(strings.TrimPrefix(object.Key, s.location.Prefix)) {
+			objects[s.location.URL(object.Key)] = object.ETag
+		}
+	}, specFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the objects of the ingestion source %s: %w", s.Name, err)
+	}
+	return objects, nil
+}
+
+// writeSpecFile writes the spec of a Python script to a new file in the state
+// directory of the project in dir, which only the user can read, and returns
+// its path.
+func writeSpecFile(dir string, pattern string, spec []byte) (string, error) {
+	stateDir, err := project.EnsureStateDir(dir)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp(stateDir, pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(spec)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,183 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"langforge/python"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// documentParser is a Python package that a loader of the ingest script
+// parses a format of documents with, and the module it provides.
+type documentParser struct {
+	Package string
+	Module  string
+}
+
+// documentParsers are the parsers of the types of documents that need
+// packages besides langchain, in the order the loaders of the ingest script
+// try them.
+var documentParsers = map[string][]documentParser{
+	"pdf":  {{"pypdf", "pypdf"}, {"pdfminer.six", "pdfminer"}, {"unstructured[pdf]", "unstructured"}},
+	"html": {{"beautifulsoup4", "bs4"}, {"unstructured", "unstructured"}},
+	"docx": {{"docx2txt", "docx2txt"}, {"unstructured[docx]", "unstructured"}},
+	"pptx": {{"python-pptx", "pptx"}, {"unstructured[pptx]", "unstructured"}},
+	"xlsx": {{"openpyxl", "openpyxl"}, {"unstructured[xlsx]", "unstructured"}},
+}
+
+// documentTypes are the types of documents by their extensions, as in the
+// EXTENSIONS of the ingest script.
+var documentTypes = map[string]string{
+	".pdf":  "pdf",
+	".html": "html",
+	".htm":  "html",
+	".docx": "docx",
+	".doc":  "docx",
+	".odt":  "docx",
+	".rtf":  "docx",
+	".pptx": "pptx",
+	".ppt":  "pptx",
+	".odp":  "pptx",
+	".xlsx": "xlsx",
+	".xls":  "xlsx",
+	".ods":  "xlsx",
+}
+
+// convertedExtensions are the extensions of the documents that the ingest
+// script converts with LibreOffice before loading them.
+var convertedExtensions = map[string]bool{".doc": true, ".odt": true, ".rtf": true, ".ppt": true, ".odp": true, ".xls": true, ".ods": true}
+
+// documentType returns the type of document that the loader of a source
+// loads the document as, and whether it is converted first.
+func documentType(loader string, document ingestDocument) (string, bool) {
+	name := document.Path
+	if name == "" {
+		name = document.Key
+	}
+	ext := strings.ToLower(path.Ext(filepath.ToSlash(name)))
+	if loader == "auto" {
+		loader = documentTypes[ext]
+	}
+	return loader, convertedExtensions[ext] && documentTypes[ext] == loader
+}
+
+// ensureParsers makes sure that the documents to ingest can be parsed: for
+// each type of document among them whose parsers all fail to import, it
+// installs the first parser and checks that it imports, and it looks for
+// LibreOffice if documents need to be converted. A type that cannot be
+// parsed is reported rather than failing the ingestion, since the ingest
+// script reports its documents as failed and ingests the others. It returns
+// the path of LibreOffice, empty if it is not needed or not installed.
+func ensureParsers(specs []ingestSpec) (string, error) {
+	types := map[string]bool{}
+	convert := false
+	for _, spec := range specs {
+		for _, document := range spec.Documents {
+			kind, converted := documentType(spec.Loader, document)
+			if _, ok := documentParsers[kind]; ok {
+				types[kind] = true
+			}
+			convert = convert || converted
+		}
+	}
+
+	if len(types) > 0 {
+		modules := []string{}
+		for kind := range types {
+			for _, parser := range documentParsers[kind] {
+				modules = append(modules, parser.Module)
+			}
+		}
+		failed, err := importModules(modules)
+		if err != nil {
+			return "", err
+		}
+		sorted := make([]string, 0, len(types))
+		for kind := range types {
+			sorted = append(sorted, kind)
+		}
+		sort.Strings(sorted)
+		for _, kind := range sorted {
+			parsers := documentParsers[kind]
+			if hasParser(parsers, failed) {
+				continue
+			}
+			parser := parsers[0]
+			fmt.Printf("Installing %s to parse %s documents...\n", parser.Package, kind)
+			if err := python.InstallPackages([]string{parser.Package}); err != nil {
+				fmt.Printf("Error installing %s, the %s documents cannot be ingested: %v\n", parser.Package, kind, err)
+				continue
+			}
+			installed, err := importModules([]string{parser.Module})
+			if err != nil {
+				return "", err
+			}
+			if message, ok := installed[parser.Module]; ok {
+				fmt.Printf("%s does not import, the %s documents cannot be ingested: %s\n", parser.Package, kind, message)
+			}
+		}
+	}
+
+	if !convert {
+		return "", nil
+	}
+	libreoffice := findLibreOffice()
+	if libreoffice == "" {
+		fmt.Println("LibreOffice is not installed, which converts .doc, .ppt, .xls and OpenDocument documents before they are ingested. Install it from https://www.libreoffice.org.")
+	}
+	return libreoffice, nil
+}
+
+func hasParser(parsers []documentParser, failed map[string]string) bool {
+	for _, parser := range parsers {
+		if _, ok := failed[parser.Module]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// importModules imports Python modules and returns the errors of those that
+// fail to import.
+func importModules(modules []string) (map[string]string, error) {
+	script, err := python.VectorStoreImportsPy()
+	if err != nil {
+		return nil, err
+	}
+	output, err := python.RunScript(script, modules...)
+	if err != nil {
+		return nil, err
+	}
+	failed := map[string]string{}
+	if err := json.Unmarshal(output, &failed); err != nil {
+		return nil, fmt.Errorf("unexpected output of the import check: %v", err)
+	}
+	return failed, nil
+}
+
+// findLibreOffice returns the path of soffice, the command of LibreOffice,
+// which is not in the PATH by default on macOS and Windows.
+func findLibreOffice() string {
+	for _, name := range []string{"soffice", "libreoffice"} {
+		if file, err := exec.LookPath(name); err == nil {
+			return file
+		}
+	}
+	candidates := []string{"/Applications/LibreOffice.app/Contents/MacOS/soffice"}
+	for _, variable := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+		if dir := os.Getenv(variable); dir != "" {
+			candidates = append(candidates, filepath.Join(dir, "LibreOffice", "program", "soffice.exe"))
+		}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,523 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/connectors"
+	"langforge/project"
+	"langforge/python"
+	"langforge/watcher"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 100
+	defaultEmbeddings   = "openai"
+	// ingestBatchSize is the number of chunks that are embedded and written
+	// at a time.
+	ingestBatchSize = 64
+)
+
+// ingestLoaders are the loaders of documents that the ingestion pipeline
+// knows by name.
+var ingestLoaders = map[string]bool{"auto": true, "text": true, "markdown": true, "pdf": true, "html": true, "csv": true,
+	"docx": true, "pptx": true, "xlsx": true}
+
+// IngestOptions configures a run of the ingestion pipeline.
+type IngestOptions struct {
+	// Sources are the names of the sources to ingest, all if empty.
+	Sources []string
+	// Full ingests all documents, also those that did not change since they
+	// were ingested.
+	Full bool
+	// Progress is called after each document that was ingested, with the
+	// number of its chunks, or deleted from the store.
+	Progress func(source string, document string, chunks int, deleted bool)
+	// Failure is called after each document that could not be loaded.
+	Failure func(source string, document string, message string)
+}
+
+// IngestResult counts the documents of a source in a run of the pipeline.
+type IngestResult struct {
+	Source    string
+	Ingested  int
+	Deleted   int
+	Unchanged int
+	Chunks    int
+	Failed    []IngestFailure
+}
+
+// IngestFailure is a document that could not be loaded, with the errors of
+// the loaders that were tried. It is ingested again in the next run.
+type IngestFailure struct {
+	Document string
+	Message  string
+}
+
+// HasPipeline reports whether a vector store configuration declares sources
+// for the ingestion pipeline.
+func HasPipeline(config project.VectorStoreConfig) bool {
+	return config.Ingestion != nil && len(config.Ingestion.Sources) > 0
+}
+
+// ingestSource is a source of the ingestion with its defaults filled in.
+// Sources in object stores have a location and sources in services the
+// location of their connector, and both their credentials once they were
+// expanded.
+type ingestSource struct {
+	project.IngestionSource
+	chunkOverlap int
+	location     *objectLocation
+	connector    *connectors.Location
+	credentials  map[string]string
+}
+
+func resolveSources(ingestion *project.IngestionConfig) ([]ingestSource, error) {
+	chunkSize, chunkOverlap := defaultChunkSize, defaultChunkOverlap
+	if ingestion.ChunkSize != 0 {
+		chunkSize = ingestion.ChunkSize
+	}
+	if ingestion.ChunkOverlap != nil {
+		chunkOverlap = *ingestion.ChunkOverlap
+	}
+
+	sources := []ingestSource{}
+	names := map[string]bool{}
+	for _, s := range ingestion.Sources {
+		source := ingestSource{IngestionSource: s, chunkOverlap: chunkOverlap}
+		if source.Path == "" {
+			return nil, fmt.Errorf("a source of vectorstore.ingestion has no path")
+		}
+		connector, err := connectors.Parse(source.Path)
+		if err != nil {
+			return nil, err
+		}
+		source.connector = connector
+		if connector == nil {
+			source.location, err = parseObjectURL(source.Path)
+			if err != nil {
+				return nil, err
+			}
+		} else if len(source.Credentials) == 0 {
+			source.Credentials = connectors.DefaultCredentials(connector.Kind)
+		}
+		remote := source.location != nil || source.connector != nil
+		if len(source.Credentials) > 0 && !remote {
+			return nil, fmt.Errorf("the ingestion source %s has credentials, which only apply to object stores and services", source.Path)
+		}
+		if source.Name == "" && remote {
+			source.Name = strings.TrimSuffix(source.Path, "/")
+		} else if source.Name == "" {
+			source.Name = filepath.ToSlash(filepath.Clean(source.Path))
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("there are two ingestion sources named %s", source.Name)
+		}
+		names[source.Name] = true
+		if source.Loader == "" {
+			source.Loader = "auto"
+		}
+		if !ingestLoaders[source.Loader] && !strings.Contains(source.Loader, ":") {
+			return nil, fmt.Errorf("unknown loader %q of the ingestion source %s, use auto, text, markdown, pdf, html, csv, docx, pptx, xlsx or module:Class", source.Loader, source.Name)
+		}
+		if source.ChunkSize == 0 {
+			source.ChunkSize = chunkSize
+		}
+		if source.ChunkOverlap != nil {
+			source.chunkOverlap = *source.ChunkOverlap
+		}
+		if source.ChunkSize < 0 || source.chunkOverlap < 0 || source.chunkOverlap >= source.ChunkSize {
+			return nil, fmt.Errorf("the chunks of the ingestion source %s must be larger than their overlap", source.Name)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// selectSources returns the sources with the given names, all without names.
+func selectSources(sources []ingestSource, names []string) ([]ingestSource, error) {
+	if len(names) == 0 {
+		return sources, nil
+	}
+	selected := []ingestSource{}
+	for _, name := range names {
+		found := false
+		for _, source := range sources {
+			if source.Name == name {
+				selected = append(selected, source)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown ingestion source %s", name)
+		}
+	}
+	return selected, nil
+}
+
+// embeddingsSpec returns the embedding model of the ingestion for the spec of
+// the Python scripts.
+func embeddingsSpec(ingestion *project.IngestionConfig) map[string]interface{} {
+	embeddings := map[string]interface{}{"model": defaultEmbeddings, "options": map[string]any{}}
+	if ingestion.Embeddings.Model != "" {
+		embeddings["model"] = ingestion.Embeddings.Model
+	}
+	if ingestion.Embeddings.Options != nil {
+		embeddings["options"] = ingestion.Embeddings.Options
+	}
+	return embeddings
+}
+
+// matches reports whether a document of the source, relative to its path, is
+// included and not excluded. Patterns without a slash match file names.
+func (s ingestSource) matches(rel string) bool {
+	match := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if !strings.Contains(pattern, "/") && watcher.GlobMatch(pattern, path.Base(rel)) {
+				return true
+			}
+			if watcher.GlobMatch(strings.TrimPrefix(pattern, "/"), rel) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(s.Include) == 0 || match(s.Include)) && !match(s.Exclude)
+}
+
+// snapshot hashes the documents of the source, by their paths relative to the
+// project directory.
+func (s ingestSource) snapshot(dir string) (map[string]string, error) {
+	documents := map[string]string{}
+	root := filepath.Join(dir, s.Path)
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("the ingestion source %s: %v", s.Name, err)
+	}
+	if !info.IsDir() {
+		// a single document
+		hash, err := fileHash(root)
+		if err != nil {
+			return nil, err
+		}
+		documents[filepath.ToSlash(filepath.Clean(s.Path))] = hash
+		return documents, nil
+	}
+
+	err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil || !s.matches(filepath.ToSlash(rel)) {
+			return err
+		}
+		hash, err := fileHash(file)
+		if err != nil {
+			return err
+		}
+		projectRel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		documents[filepath.ToSlash(projectRel)] = hash
+		return nil
+	})
+	return documents, err
+}
+
+// sourceState records the documents of a source that were ingested into a
+// collection, with the number of their chunks, whose ids derive from it.
+type sourceState struct {
+	Collection string                      `json:"collection"`
+	Documents  map[string]ingestedDocument `json:"documents"`
+}
+
+type ingestedDocument struct {
+	Hash   string `json:"hash"`
+	Chunks int    `json:"chunks"`
+}
+
+// sourceStatePath returns the file of the state of a source, next to the
+// state of the ingest script, so that resetting the store removes both. The
+// names of sources in object stores are URLs, whose colons Windows does not
+// allow in file names.
+func sourceStatePath(config project.VectorStoreConfig, source string) string {
+	return filepath.Join(filepath.Clean(config.Path)+".sources", strings.ReplaceAll(url.PathEscape(source), ":", "%3A")+".json")
+}
+
+func loadSourceState(config project.VectorStoreConfig, source string) (*sourceState, error) {
+	state := &sourceState{Collection: config.Collection, Documents: map[string]ingestedDocument{}}
+	data, err := os.ReadFile(sourceStatePath(config, source))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	loaded := &sourceState{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		return nil, fmt.Errorf("failed to read the ingestion state of %s: %v", source, err)
+	}
+	// the documents of another collection are not in this one
+	if loaded.Collection == config.Collection && loaded.Documents != nil {
+		state.Documents = loaded.Documents
+	}
+	return state, nil
+}
+
+func saveSourceState(config project.VectorStoreConfig, source string, state *sourceState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := sourceStatePath(config, source)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// ingestDocument is a document in the spec of the ingest script, a file at
+// Path or the object with Key in an object store. Previous is the number of
+// chunks that it had in the store before.
+type ingestDocument struct {
+	Path     string `json:"path,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Rel      string `json:"rel"`
+	Hash     string `json:"hash"`
+	Previous int    `json:"previous"`
+}
+
+type ingestSpec struct {
+	Name         string            `json:"name"`
+	Loader       string            `json:"loader"`
+	ChunkSize    int               `json:"chunkSize"`
+	ChunkOverlap int               `json:"chunkOverlap"`
+	Metadata     map[string]string `json:"metadata"`
+	FrontMatter  bool              `json:"frontMatter"`
+	Documents    []ingestDocument  `json:"documents"`
+	Deleted      []ingestDocument  `json:"deleted"`
+	Location     *objectLocation   `json:"location,omitempty"`
+	Credentials  map[string]string `json:"credentials,omitempty"`
+}
+
+// Ingest runs the ingestion pipeline of the project in dir: it loads the
+// documents of the sources of vectorstore.ingestion that changed since they
+// were ingested, splits them into chunks, embeds the chunks and replaces
+// their previous chunks in the store. The chunks of removed documents are
+// deleted. The state of each source is saved after each document, so an
+// interrupted run continues where it stopped.
+func Ingest(ctx context.Context, dir string, store Store, options IngestOptions) ([]IngestResult, error) {
+	config := store.Config()
+	if !HasPipeline(config) {
+		return nil, fmt.Errorf("no ingestion sources configured in vectorstore.ingestion")
+	}
+	sources, err := resolveSources(config.Ingestion)
+	if err != nil {
+		return nil, err
+	}
+	sources, err = selectSources(sources, options.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]*sourceState{}
+	results := []IngestResult{}
+	specs := []ingestSpec{}
+	pending := 0
+	for _, source := range sources {
+		state, err := loadSourceState(config, source.Name)
+		if err != nil {
+			return nil, err
+		}
+		states[source.Name] = state
+		source.credentials, err = expandCredentials(dir, source.Name, source.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		var current map[string]string
+		var connector connectors.Connector
+		var documents map[string]connectors.Document
+		switch {
+		case source.location != nil:
+			current, err = source.listObjects(ctx, dir)
+		case source.connector != nil:
+			connector, err = connectors.New(source.connector, source.credentials)
+			if err == nil {
+				current, documents, err = source.syncConnector(ctx, connector, config, options.Full)
+			}
+		default:
+			current, err = source.snapshot(dir)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		spec := ingestSpec{
+			Name:         source.Name,
+			Loader:       source.Loader,
+			ChunkSize:    source.ChunkSize,
+			ChunkOverlap: source.chunkOverlap,
+			Metadata:     source.Metadata,
+			FrontMatter:  source.FrontMatter,
+			Documents:    []ingestDocument{},
+			Deleted:      []ingestDocument{},
+			Location:     source.location,
+		}
+		if source.location != nil {
+			spec.Credentials = source.credentials
+		}
+		if spec.Metadata == nil {
+			spec.Metadata = map[string]string{}
+		}
+		result := IngestResult{Source: source.Name}
+		for rel, hash := range current {
+			previous := state.Documents[rel]
+			if previous.Hash == hash && !options.Full {
+				result.Unchanged++
+				continue
+			}
+			document := ingestDocument{Rel: rel, Hash: hash, Previous: previous.Chunks}
+			switch {
+			case source.location != nil:
+				document.Key = strings.TrimPrefix(rel, source.location.URL(""))
+			case source.connector == nil:
+				document.Path = filepath.Join(dir, filepath.FromSlash(rel))
+			}
+			spec.Documents = append(spec.Documents, document)
+		}
+		for rel, previous := range state.Documents {
+			if _, ok := current[rel]; !ok {
+				spec.Deleted = append(spec.Deleted, ingestDocument{Rel: rel, Previous: previous.Chunks})
+			}
+		}
+		sort.Slice(spec.Documents, func(i, j int) bool { return spec.Documents[i].Rel < spec.Documents[j].Rel })
+		sort.Slice(spec.Deleted, func(i, j int) bool { return spec.Deleted[i].Rel < spec.Deleted[j].Rel })
+		if connector != nil && len(spec.Documents) > 0 {
+			fetch := make([]connectors.Document, len(spec.Documents))
+			for i, document := range spec.Documents {
+				fetch[i] = documents[document.Rel]
+			}
+			fetchDir, paths, err := source.fetchDocuments(ctx, connector, dir, fetch)
+			if err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(fetchDir)
+			for i, file := range paths {
+				spec.Documents[i].Path = file
+			}
+		}
+		pending += len(spec.Documents) + len(spec.Deleted)
+		specs = append(specs, spec)
+		results = append(results, result)
+	}
+	if pending == 0 {
+		return results, nil
+	}
+
+	var saveErr error
+	err = runIngestion(ctx, dir, config, specs, func(report ingestReport) {
+		state := states[report.Source]
+		result := &results[0]
+		for i := range results {
+			if results[i].Source == report.Source {
+				result = &results[i]
+			}
+		}
+		if report.Error != "" {
+			// the document keeps its previous chunks and is retried in the
+			// next run
+			result.Failed = append(result.Failed, IngestFailure{Document: report.Document, Message: report.Error})
+			if options.Failure != nil {
+				options.Failure(report.Source, report.Document, report.Error)
+			}
+			return
+		}
+		if report.Deleted {
+			delete(state.Documents, report.Document)
+			result.Deleted++
+		} else {
+			state.Documents[report.Document] = ingestedDocument{Hash: report.Hash, Chunks: report.Chunks}
+			result.Ingested++
+			result.Chunks += report.Chunks
+		}
+		if err := saveSourceState(config, report.Source, state); err != nil && saveErr == nil {
+			saveErr = err
+		}
+		if options.Progress != nil {
+			options.Progress(report.Source, report.Document, report.Chunks, report.Deleted)
+		}
+	})
+	if err != nil {
+		return results, err
+	}
+	return results, saveErr
+}
+
+// ingestReport is a line of the output of the ingest script about a document
+// that it ingested, deleted or failed to load.
+type ingestReport struct {
+	Source   string `json:"source"`
+	Document string `json:"document"`
+	Hash     string `json:"hash"`
+	Chunks   int    `json:"chunks"`
+	Deleted  bool   `json:"deleted"`
+	Error    string `json:"error"`
+}
+
+// runIngestion runs the ingest script of the pipeline on the documents of
+// specs and calls report for each of them.
+func runIngestion(ctx context.Context, dir string, config project.VectorStoreConfig, specs []ingestSpec, report func(ingestReport)) error {
+	if err := ensureClient(config.Type); err != nil {
+		return err
+	}
+	libreoffice, err := ensureParsers(specs)
+	if err != nil {
+		return err
+	}
+	spec, err := json.Marshal(map[string]interface{}{
+		"store":           map[string]string{"type": config.Type, "mode": config.Mode, "path": config.Path, "url": config.URL},
+		"collection":      config.Collection,
+		"batchSize":       ingestBatchSize,
+		"embeddings":      embeddingsSpec(config.Ingestion),
+		"embeddingsCache": EmbeddingsCachePath(config),
+		"sources":         specs,
+		"libreoffice":     libreoffice,
+	})
+	if err != nil {
+		return err
+	}
+	// the spec lists every document, which could exceed the limits of
+	// command lines, and the credentials of the sources
+	specFile, err := writeSpecFile(dir, "ingest-*.json", spec)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(specFile)
+
+	script, err := python.VectorStoreIngestPy()
+	if err != nil {
+		return err
+	}
+	err = python.StreamScript(ctx, script, func(line string) {
+		var r ingestReport
+		if err := json.Unmarshal([]byte(line), &r); err != nil || r.Source == "" {
+			// output of the loaders and clients
+			fmt.Println(line)
+			return
+		}
+		report(r)
+	}, specFile)
+	if err != nil {
+		return fmt.Errorf("ingestion failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"langforge/project"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Codes of the startup messages of the PostgreSQL protocol.
+const (
+	postgresProtocol   = 3 << 16
+	postgresSSLRequest = 80877103
+)
+
+// newPostgresCheck returns the health check of a pgvector store, which starts
+// a connection to the PostgreSQL server of the URL. The check passes once the
+// server accepts the user and the database, when it asks for credentials or
+// is ready for queries; credentials are not sent.
+func newPostgresCheck(config project.VectorStoreConfig) (HealthCheck, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, fmt.Errorf("the URL of a pgvector store must be a postgresql:// URL")
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "5432")
+	}
+	user := u.User.Username()
+	if user == "" {
+		user = "postgres"
+	}
+	database := strings.TrimPrefix(u.Path, "/")
+	if database == "" {
+		database = user
+	}
+	sslMode := u.Query().Get("sslmode")
+
+	return HealthCheckFunc(func(ctx context.Context) error {
+		err := postgresHandshake(ctx, host, u.Hostname(), sslMode, user, database)
+		if err != nil {
+			return fmt.Errorf("pgvector is not usable at %s: %v", host, err)
+		}
+		return nil
+	}), nil
+}
+
+func postgresHandshake(ctx context.Context, addr string, hostname string, sslMode string, user string, database string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	// like libpq, prefer TLS unless it is disabled
+	switch sslMode {
+	case "disable", "allow":
+	default:
+		if err := writeStartup(conn, postgresSSLRequest, nil); err != nil {
+			return err
+		}
+		answer := make([]byte, 1)
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return err
+		}
+		switch {
+		case answer[0] == 'S':
+			tlsConn := tls.Client(conn, &tls.Config{
+				ServerName:         hostname,
+				InsecureSkipVerify: sslMode != "verify-ca" && sslMode != "verify-full",
+			})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				return err
+			}
+			defer tlsConn.Close()
+			conn = tlsConn
+		case sslMode == "require" || sslMode == "verify-ca" || sslMode == "verify-full":
+			return errors.New("the server does not support SSL")
+		}
+	}
+
+	params := []byte{}
+	for _, param := range []string{"user", user, "database", database} {
+		params = append(append(params, param...), 0)
+	}
+	params = append(params, 0)
+	if err := writeStartup(conn, postgresProtocol, params); err != nil {
+		return err
+	}
+
+	for {
+		kind, body, err := readPostgresMessage(conn)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case 'E':
+			return fmt.Errorf("the server rejected the connection: %s", postgresErrorMessage(body))
+		case 'R':
+			// any request other than AuthenticationOk asks for credentials
+			if len(body) < 4 || binary.BigEndian.Uint32(body) != 0 {
+				conn.Write([]byte{'X', 0, 0, 0, 4})
+				return nil
+			}
+		case 'Z':
+			conn.Write([]byte{'X', 0, 0, 0, 4})
+			return nil
+		}
+	}
+}
+
+func writeStartup(w io.Writer, code uint32, params []byte) error {
+	message := make([]byte, 8, 8+len(params))
+	binary.BigEndian.PutUint32(message, uint32(8+len(params)))
+	binary.BigEndian.PutUint32(message[4:], code)
+	_, err := w.Write(append(message, params...))
+	return err
+}
+
+func readPostgresMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > 1<<20 {
+		return 0, nil, fmt.Errorf("the server is not a PostgreSQL server")
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// postgresErrorMessage returns the message field of an ErrorResponse.
+func postgresErrorMessage(body []byte) string {
+	for _, field := range bytes.Split(body, []byte{0}) {
+		if len(field) > 1 && field[0] == 'M' {
+			return string(field[1:])
+		}
+	}
+	return "unknown error"
+}
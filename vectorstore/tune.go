@@ -0,0 +1,248 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"langforge/project"
+	"langforge/python"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TuneStrategies are the chunking strategies that the benchmark compares, the
+// text splitters of LangChain: recursive splits at paragraphs, lines and
+// words, character at paragraphs, markdown at the headings of Markdown and
+// token counts tokens of tiktoken rather than characters.
+var TuneStrategies = []string{"recursive", "character", "markdown", "token"}
+
+var defaultTuneChunkSizes = []int{500, 1000, 2000}
+
+// defaultTuneK is the number of chunks retrieved per question.
+const defaultTuneK = 5
+
+// TuneExample is a question of the benchmark with the answer that a relevant
+// chunk contains or the documents, by their paths in the project, that are
+// relevant to it.
+type TuneExample struct {
+	Question string   `json:"question"`
+	Expected string   `json:"expected"`
+	Sources  []string `json:"sources"`
+}
+
+// TuneOptions configures a benchmark of chunking configurations.
+type TuneOptions struct {
+	// Sources are the names of the sources whose documents are chunked, all
+	// if empty.
+	Sources       []string
+	Strategies    []string
+	ChunkSizes    []int
+	ChunkOverlaps []int
+	// K is the number of chunks retrieved per question.
+	K        int
+	Examples []TuneExample
+	// Progress is called with the result of each configuration.
+	Progress func(result TuneResult)
+	// Failure is called for each document that could not be loaded.
+	Failure func(source string, document string, message string)
+}
+
+// TuneResult holds the retrieval metrics of a chunking configuration: the
+// share of questions with a relevant chunk among the first K, the mean
+// reciprocal rank of the first relevant chunk and the share of the relevant
+// documents that were retrieved.
+type TuneResult struct {
+	Strategy     string  `json:"strategy"`
+	ChunkSize    int     `json:"chunkSize"`
+	ChunkOverlap int     `json:"chunkOverlap"`
+	Chunks       int     `json:"chunks"`
+	HitRate      float64 `json:"hitRate"`
+	MRR          float64 `json:"mrr"`
+	Recall       float64 `json:"recall"`
+}
+
+// Tune benchmarks chunking configurations of the ingestion of the project in
+// dir: the documents of its sources are split with every combination of the
+// strategies, chunk sizes and overlaps of options, the chunks are embedded
+// with the embedding model of the ingestion and the chunks most similar to
+// the questions of the examples are scored against them. The store is not
+// changed, but the embeddings cache is used. Only the sources in the project
+// are read.
+func Tune(ctx context.Context, dir string, config project.VectorStoreConfig, options TuneOptions) ([]TuneResult, error) {
+	if !HasPipeline(config) {
+		return nil, fmt.Errorf("no ingestion sources configured in vectorstore.ingestion")
+	}
+	if len(options.Examples) == 0 {
+		return nil, fmt.Errorf("the benchmark has no questions")
+	}
+	sources, err := resolveSources(config.Ingestion)
+	if err != nil {
+		return nil, err
+	}
+	sources, err = selectSources(sources, options.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	configurations, err := tuneConfigurations(config.Ingestion, options)
+	if err != nil {
+		return nil, err
+	}
+	k := options.K
+	if k <= 0 {
+		k = defaultTuneK
+	}
+
+	specs := []ingestSpec{}
+	for _, source := range sources {
+		if source.location != nil || source.connector != nil {
+			fmt.Printf("Skipping the ingestion source %s, which is not in the project.\n", source.Name)
+			continue
+		}
+		current, err := source.snapshot(dir)
+		if err != nil {
+			return nil, err
+		}
+		spec := ingestSpec{
+			Name:        source.Name,
+			Loader:      source.Loader,
+			Metadata:    source.Metadata,
+			FrontMatter: source.FrontMatter,
+			Documents:   []ingestDocument{},
+		}
+		if spec.Metadata == nil {
+			spec.Metadata = map[string]string{}
+		}
+		for rel := range current {
+			spec.Documents = append(spec.Documents, ingestDocument{Rel: rel, Path: filepath.Join(dir, filepath.FromSlash(rel))})
+		}
+		sort.Slice(spec.Documents, func(i, j int) bool { return spec.Documents[i].Rel < spec.Documents[j].Rel })
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("none of the ingestion sources is in the project")
+	}
+
+	libreoffice, err := ensureParsers(specs)
+	if err != nil {
+		return nil, err
+	}
+	for _, configuration := range configurations {
+		if configuration.Strategy == "token" {
+			if err := ensurePackage([]string{"tiktoken"}); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	spec, err := json.Marshal(map[string]interface{}{
+		"batchSize":       ingestBatchSize,
+		"embeddings":      embeddingsSpec(config.Ingestion),
+		"embeddingsCache": EmbeddingsCachePath(config),
+		"libreoffice":     libreoffice,
+		"sources":         specs,
+		"examples":        options.Examples,
+		"configurations":  configurations,
+		"k":               k,
+	})
+	if err != nil {
+		return nil, err
+	}
+	specFile, err := writeSpecFile(dir, "tune-*.json", spec)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(specFile)
+
+	script, err := python.VectorStoreTunePy()
+	if err != nil {
+		return nil, err
+	}
+	results := []TuneResult{}
+	err = python.StreamScript(ctx, script, func(line string) {
+		var report struct {
+			Source        string      `json:"source"`
+			Document      string      `json:"document"`
+			Error         string      `json:"error"`
+			Configuration *TuneResult `json:"configuration"`
+			Chunks        int         `json:"chunks"`
+			HitRate       float64     `json:"hitRate"`
+			MRR           float64     `json:"mrr"`
+			Recall        float64     `json:"recall"`
+		}
+		if err := json.Unmarshal([]byte(line), &report); err != nil || (report.Error == "" && report.Configuration == nil) {
+			// output of the loaders and the embedding model
+			fmt.Println(line)
+			return
+		}
+		if report.Error != "" {
+			if options.Failure != nil {
+				options.Failure(report.Source, report.Document, report.Error)
+			}
+			return
+		}
+		result := *report.Configuration
+		result.Chunks = report.Chunks
+		result.HitRate = report.HitRate
+		result.MRR = report.MRR
+		result.Recall = report.Recall
+		results = append(results, result)
+		if options.Progress != nil {
+			options.Progress(result)
+		}
+	}, specFile)
+	if err != nil {
+		return results, fmt.Errorf("the benchmark failed: %w", err)
+	}
+	return results, nil
+}
+
+// tuneConfigurations returns the combinations of the strategies, chunk sizes
+// and overlaps of the options, by default the recursive strategy with chunks
+// of 500, 1000 and 2000 characters and the overlap of the ingestion.
+// Overlaps that are not smaller than the chunks are left out.
+func tuneConfigurations(ingestion *project.IngestionConfig, options TuneOptions) ([]TuneResult, error) {
+	strategies := options.Strategies
+	if len(strategies) == 0 {
+		strategies = TuneStrategies[:1]
+	}
+	for _, strategy := range strategies {
+		known := false
+		for _, s := range TuneStrategies {
+			known = known || s == strategy
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown chunking strategy %q, use one of %v", strategy, TuneStrategies)
+		}
+	}
+	sizes := options.ChunkSizes
+	if len(sizes) == 0 {
+		sizes = defaultTuneChunkSizes
+	}
+	overlaps := options.ChunkOverlaps
+	if len(overlaps) == 0 {
+		overlap := defaultChunkOverlap
+		if ingestion.ChunkOverlap != nil {
+			overlap = *ingestion.ChunkOverlap
+		}
+		overlaps = []int{overlap}
+	}
+
+	configurations := []TuneResult{}
+	for _, strategy := range strategies {
+		for _, size := range sizes {
+			for _, overlap := range overlaps {
+				if size <= 0 || overlap < 0 || overlap >= size {
+					continue
+				}
+				configurations = append(configurations, TuneResult{Strategy: strategy, ChunkSize: size, ChunkOverlap: overlap})
+			}
+		}
+	}
+	if len(configurations) == 0 {
+		return nil, fmt.Errorf("no chunking configuration has chunks larger than their overlap")
+	}
+	return configurations, nil
+}
@@ -0,0 +1,71 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// uploadSource is the source of the chunks of single files, whose chunk ids
+// begin with it.
+const uploadSource = "uploads"
+
+// IngestFile ingests the file at path, e.g. an upload of the gateway, into the
+// store of the project in dir and returns the number of its chunks. The chunks
+// have id in their "file" metadata, so that chains can retrieve from the file
+// alone. Projects with an ingestion pipeline load, split and embed it with
+// the settings of the pipeline; otherwise the ingest script receives it in
+// LANGFORGE_INGEST_CHANGED and id in LANGFORGE_INGEST_FILE_ID, and the number
+// of chunks is not known. The file is not recorded in the state of the
+// ingestion, so its chunks stay in the store when the file is removed.
+func IngestFile(ctx context.Context, dir string, store Store, path string, id string) (int, error) {
+	config := store.Config()
+	if !HasPipeline(config) {
+		env := map[string]string{
+			"LANGFORGE_INGEST_CHANGED": path,
+			"LANGFORGE_INGEST_DELETED": "",
+			"LANGFORGE_INGEST_FILE_ID": id,
+		}
+		return 0, runIngestScript(dir, store, config.Ingest, env, os.Stdout)
+	}
+
+	hash, err := fileHash(path)
+	if err != nil {
+		return 0, err
+	}
+	chunkSize, chunkOverlap := defaultChunkSize, defaultChunkOverlap
+	if config.Ingestion.ChunkSize != 0 {
+		chunkSize = config.Ingestion.ChunkSize
+	}
+	if config.Ingestion.ChunkOverlap != nil {
+		chunkOverlap = *config.Ingestion.ChunkOverlap
+	}
+	spec := ingestSpec{
+		Name:         uploadSource,
+		Loader:       "auto",
+		ChunkSize:    chunkSize,
+		ChunkOverlap: chunkOverlap,
+		Metadata:     map[string]string{"file": id},
+		Documents:    []ingestDocument{{Path: path, Rel: id + "/" + filepath.Base(path), Hash: hash}},
+		Deleted:      []ingestDocument{},
+	}
+
+	chunks := 0
+	var loadErr error
+	err = runIngestion(ctx, dir, config, []ingestSpec{spec}, func(report ingestReport) {
+		if report.Error != "" {
+			loadErr = errors.New(report.Error)
+			return
+		}
+		chunks += report.Chunks
+	})
+	if err != nil {
+		return 0, err
+	}
+	if loadErr != nil {
+		return 0, fmt.Errorf("failed to load %s: %v", filepath.Base(path), loadErr)
+	}
+	return chunks, nil
+}
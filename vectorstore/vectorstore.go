@@ -0,0 +1,180 @@
+package vectorstore
+
+import (
+	"fmt"
+	"io"
+	"langforge/envfile"
+	"langforge/project"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// CollectionStatus holds the number of documents (embeddings) in a collection.
+type CollectionStatus struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Status describes the state of a project's vector store.
+type Status struct {
+	Type        string
+	Mode        string
+	Location    string
+	Available   bool
+	Collections []CollectionStatus
+}
+
+// Store manages the lifecycle of a local vector store.
+type Store interface {
+	// Init creates the store's storage or starts its server.
+	Init() error
+	// Reset deletes all data of the store.
+	Reset() error
+	// Status reports whether the store is available and how many documents it holds.
+	Status() (*Status, error)
+	// Env returns the environment variables that tell ingest scripts and chains
+	// how to connect to the store.
+	Env() map[string]string
+	// Config returns the resolved configuration of the store.
+	Config() project.VectorStoreConfig
+}
+
+// Resolve fills in defaults for the vector store configuration of the project in dir.
+func Resolve(dir string, config project.VectorStoreConfig) project.VectorStoreConfig {
+	if config.Type == "" {
+		config.Type = "chroma"
+	}
+	if config.Mode == "" {
+		config.Mode = "embedded"
+		if config.Type == "pgvector" {
+			config.Mode = "external"
+		}
+	}
+	if config.Path == "" {
+		config.Path = filepath.Join(project.StateDirName, "vectorstore", config.Type)
+	}
+	if !filepath.IsAbs(config.Path) {
+		config.Path = filepath.Join(dir, config.Path)
+	}
+	if config.URL == "" {
+		switch config.Type {
+		case "qdrant":
+			config.URL = "http://localhost:6333"
+		case "chroma":
+			config.URL = "http://localhost:8000"
+		case "pgvector":
+			config.URL = "postgresql://localhost:5432/postgres"
+		}
+	}
+	if config.Collection == "" {
+		config.Collection = "langchain"
+	}
+	if config.Docs == "" {
+		config.Docs = "docs"
+	}
+	if config.Ingest == "" {
+		if _, err := os.Stat(filepath.Join(dir, "ingest.py")); err == nil {
+			config.Ingest = "ingest.py"
+		}
+	}
+	if config.EmbeddingsCache != nil {
+		// a copy, the configuration of the project is not changed
+		cache := *config.EmbeddingsCache
+		if cache.Path == "" {
+			cache.Path = filepath.Join(project.StateDirName, EmbeddingsCacheFileName)
+		}
+		if !filepath.IsAbs(cache.Path) {
+			cache.Path = filepath.Join(dir, cache.Path)
+		}
+		config.EmbeddingsCache = &cache
+	}
+	return config
+}
+
+// New returns the vector store of the project in dir.
+func New(dir string, projectConfig *project.Config) (Store, error) {
+	config := Resolve(dir, projectConfig.VectorStore)
+
+	if config.Type != "chroma" && config.Type != "qdrant" && config.Type != "pgvector" {
+		return nil, fmt.Errorf("unsupported vector store type %q, expected chroma, qdrant or pgvector", config.Type)
+	}
+	if config.Type == "pgvector" && config.Mode != "external" {
+		return nil, fmt.Errorf("pgvector stores are only supported in the external mode")
+	}
+
+	switch config.Mode {
+	case "embedded":
+		return &embeddedStore{config: config}, nil
+	case "docker":
+		return &dockerStore{config: config, container: "langforge-" + projectConfig.Name + "-" + config.Type}, nil
+	case "external":
+		return &externalStore{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unsupported vector store mode %q, expected embedded, docker or external", config.Mode)
+	}
+}
+
+func baseEnv(config project.VectorStoreConfig) map[string]string {
+	env := map[string]string{
+		"LANGFORGE_VECTORSTORE_TYPE":       config.Type,
+		"LANGFORGE_VECTORSTORE_MODE":       config.Mode,
+		"LANGFORGE_VECTORSTORE_COLLECTION": config.Collection,
+	}
+	if path := EmbeddingsCachePath(config); path != "" {
+		env["LANGFORGE_EMBEDDINGS_CACHE"] = path
+	}
+	return env
+}
+
+// RunIngest runs the project's ingest script with the store's connection
+// settings added to its environment and records the ingested documents.
+func RunIngest(dir string, store Store, script string) error {
+	if err := runIngestScript(dir, store, script, nil, os.Stdout); err != nil {
+		return err
+	}
+
+	state, err := snapshotDocuments(dir, store.Config())
+	if err != nil {
+		return err
+	}
+	return saveIngestState(store.Config(), state)
+}
+
+func runIngestScript(dir string, store Store, script string, extraEnv map[string]string, output io.Writer) error {
+	if script == "" {
+		return fmt.Errorf("no ingest script configured")
+	}
+
+	dotEnv, err := envfile.Load(dir)
+	if err != nil {
+		return err
+	}
+	masker := envfile.NewMasker(dotEnv)
+	cmd := exec.Command("python", script)
+	cmd.Dir = dir
+	cmd.Stdout = masker.Writer(output)
+	cmd.Stderr = cmd.Stdout
+	cmd.Env = os.Environ()
+	for key, value := range store.Env() {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	for key, value := range extraEnv {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	if EmbeddingsCachePath(store.Config()) != "" {
+		env, err := embeddingsEnv(dir)
+		if err != nil {
+			return err
+		}
+		for key, value := range env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	cmd.Env = envfile.Environ(cmd.Env, dotEnv)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ingest script %s failed: %v", script, err)
+	}
+	return nil
+}
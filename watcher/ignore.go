@@ -0,0 +1,139 @@
+package watcher
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIgnore lists the directories that are never watched.
+var DefaultIgnore = []string{
+	".git/",
+	".venv/",
+	".conda/",
+	".langforge/",
+	"__pycache__/",
+	".ipynb_checkpoints/",
+	"node_modules/",
+	"*.pyc",
+	"*.swp",
+	"*~",
+}
+
+// Ignore matches paths against gitignore-style patterns.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// NewIgnore compiles the given gitignore-style patterns.
+func NewIgnore(patterns []string) *Ignore {
+	ignore := &Ignore{}
+	for _, pattern := range patterns {
+		ignore.Add(pattern)
+	}
+	return ignore
+}
+
+// LoadIgnore returns the default patterns combined with the patterns of the
+// .gitignore file in dir, if there is one.
+func LoadIgnore(dir string) *Ignore {
+	ignore := NewIgnore(DefaultIgnore)
+
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return ignore
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		ignore.Add(scanner.Text())
+	}
+	return ignore
+}
+
+// Add adds a gitignore-style pattern. Empty lines and comments are skipped.
+func (i *Ignore) Add(pattern string) {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+
+	rule := ignoreRule{}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	// a pattern with a slash in the beginning or middle is relative to the root
+	if strings.Contains(pattern, "/") {
+		rule.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	rule.pattern = pattern
+
+	i.rules = append(i.rules, rule)
+}
+
+// Match reports whether the slash-separated path relative to the watched
+// directory is ignored. The last matching pattern wins, like in git.
+func (i *Ignore) Match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range i.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.match(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) match(rel string) bool {
+	if !r.anchored {
+		return GlobMatch(r.pattern, path.Base(rel))
+	}
+	return GlobMatch(r.pattern, rel)
+}
+
+// GlobMatch matches a slash-separated path against a pattern where "**"
+// matches any number of path segments and every other segment is matched with
+// path.Match.
+func GlobMatch(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
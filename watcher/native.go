@@ -0,0 +1,155 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// nativeBackend watches every directory of the tree with the notifications of
+// the operating system through fsnotify: inotify on Linux, kqueue on macOS and
+// the BSDs and ReadDirectoryChangesW on Windows.
+type nativeBackend struct {
+	dir     string
+	ignore  *Ignore
+	watcher *fsnotify.Watcher
+}
+
+func newNativeBackend(dir string, ignore *Ignore) (backend, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &nativeBackend{
+		dir:     dir,
+		ignore:  ignore,
+		watcher: watcher,
+	}
+
+	if err := b.addTree(""); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// addTree adds watches for the directory rel and all its subdirectories.
+// fsnotify does not watch trees recursively.
+func (b *nativeBackend) addTree(rel string) error {
+	if err := b.addWatch(rel); err != nil {
+		return err
+	}
+	var err error
+	walk(filepath.Join(b.dir, filepath.FromSlash(rel)), b.ignore, func(sub string, entry fs.DirEntry) {
+		if entry.IsDir() && err == nil {
+			err = b.addWatch(joinRel(rel, sub))
+		}
+	})
+	return err
+}
+
+func (b *nativeBackend) addWatch(rel string) error {
+	err := b.watcher.Add(filepath.Join(b.dir, filepath.FromSlash(rel)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed in the meantime
+			return nil
+		}
+		if errors.Is(err, syscall.ENOSPC) {
+			return errors.New("inotify watch limit reached, raise fs.inotify.max_user_watches or add patterns to .gitignore")
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *nativeBackend) run(stop <-chan struct{}, changes chan<- string) error {
+	defer b.watcher.Close()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err, ok := <-b.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// events were lost, report the whole tree as changed
+				if !send(stop, changes, ".") {
+					return nil
+				}
+				continue
+			}
+			return err
+		case event, ok := <-b.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !b.handle(event, stop, changes) {
+				return nil
+			}
+		}
+	}
+}
+
+// handle reports the change of an event. It returns false if stop was closed
+// in the meantime.
+func (b *nativeBackend) handle(event fsnotify.Event, stop <-chan struct{}, changes chan<- string) bool {
+	// changes of permissions and times alone are not changes of the content
+	if event.Op == fsnotify.Chmod {
+		return true
+	}
+	rel, err := filepath.Rel(b.dir, event.Name)
+	if err != nil || rel == "." {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+
+	isDirectory := false
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil {
+			isDirectory = info.IsDir()
+		}
+	}
+	if b.ignore.Match(rel, isDirectory) {
+		return true
+	}
+
+	if isDirectory {
+		b.addTree(rel)
+		// report files that were created before the watch was added
+		sent := true
+		walk(filepath.Join(b.dir, filepath.FromSlash(rel)), b.ignore, func(sub string, entry fs.DirEntry) {
+			if sent && !entry.IsDir() {
+				sent = send(stop, changes, joinRel(rel, sub))
+			}
+		})
+		return sent
+	}
+
+	return send(stop, changes, rel)
+}
+
+// send reports the change of rel unless stop is closed before the change is
+// received, in which case it returns false.
+func send(stop <-chan struct{}, changes chan<- string, rel string) bool {
+	select {
+	case changes <- rel:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func joinRel(dir string, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
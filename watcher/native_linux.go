@@ -0,0 +1,36 @@
+//go:build linux
+
+package watcher
+
+import (
+	"langforge/system"
+	"strings"
+	"syscall"
+)
+
+// File system types on which inotify does not report changes made by other
+// machines or by the Windows host.
+var remoteFilesystems = map[int64]bool{
+	0x6969:     true, // NFS
+	0x517b:     true, // SMB
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+	0x01021997: true, // 9p, used for Windows drives in WSL 2
+	0x65735546: true, // FUSE
+}
+
+// nativeSupported reports whether inotify can be used for dir.
+func nativeSupported(dir string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false
+	}
+	if remoteFilesystems[int64(stat.Type)] {
+		return false
+	}
+	// Windows drives mounted into WSL 1 (drvfs)
+	if strings.HasPrefix(dir, "/mnt/") && system.Platform().WSL == 1 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package watcher
+
+// nativeSupported reports whether native notifications can be used for dir.
+// kqueue and ReadDirectoryChangesW report the changes of local and network
+// drives alike, platforms that fsnotify does not support fail to create the
+// watcher and are polled.
+func nativeSupported(dir string) bool {
+	return true
+}
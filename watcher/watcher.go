@@ -0,0 +1,178 @@
+package watcher
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Options configures a watch.
+type Options struct {
+	// Debounce is the quiet period after the last change before onChange is
+	// called, so that a burst of writes results in a single notification.
+	Debounce time.Duration
+	// Interval is the polling interval if native notifications are unavailable.
+	Interval time.Duration
+	// Ignore holds additional gitignore-style patterns. The default patterns
+	// and the project's .gitignore are always applied.
+	Ignore []string
+	// Filter, if set, restricts notifications to the paths it returns true for.
+	Filter func(rel string) bool
+	// Poll forces polling instead of native file system notifications.
+	Poll bool
+}
+
+// backend reports the slash-separated paths, relative to the watched
+// directory, of files and directories that change.
+type backend interface {
+	run(stop <-chan struct{}, changes chan<- string) error
+}
+
+// Watch watches the directory tree rooted at dir and calls onChange with the
+// sorted, relative paths of the files that changed. It uses the native file
+// system notifications of Linux, macOS and Windows and falls back to polling
+// where they are unavailable, e.g. on network drives and Windows drives
+// mounted into WSL. Watch blocks until stop is closed.
+func Watch(dir string, options Options, stop <-chan struct{}, onChange func(changed []string)) error {
+	if options.Debounce <= 0 {
+		options.Debounce = 200 * time.Millisecond
+	}
+	if options.Interval <= 0 {
+		options.Interval = time.Second
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	ignore := LoadIgnore(dir)
+	for _, pattern := range options.Ignore {
+		ignore.Add(pattern)
+	}
+
+	var b backend
+	if !options.Poll && nativeSupported(dir) {
+		b, err = newNativeBackend(dir, ignore)
+	}
+	if b == nil || err != nil {
+		b = &pollBackend{dir: dir, ignore: ignore, interval: options.Interval}
+	}
+
+	changes := make(chan string, 64)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- b.run(stop, changes)
+	}()
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(options.Debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-failed:
+			return err
+		case rel := <-changes:
+			if options.Filter != nil && !options.Filter(rel) {
+				continue
+			}
+			pending[rel] = true
+			timer.Reset(options.Debounce)
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+			changed := make([]string, 0, len(pending))
+			for rel := range pending {
+				changed = append(changed, rel)
+			}
+			sort.Strings(changed)
+			pending = map[string]bool{}
+			onChange(changed)
+		}
+	}
+}
+
+// pollBackend detects changes by comparing snapshots of the directory tree.
+type pollBackend struct {
+	dir      string
+	ignore   *Ignore
+	interval time.Duration
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (b *pollBackend) run(stop <-chan struct{}, changes chan<- string) error {
+	last := b.snapshot()
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			current := b.snapshot()
+			for rel, state := range current {
+				if previous, ok := last[rel]; !ok || previous != state {
+					if !send(stop, changes, rel) {
+						return nil
+					}
+				}
+			}
+			for rel := range last {
+				if _, ok := current[rel]; !ok {
+					if !send(stop, changes, rel) {
+						return nil
+					}
+				}
+			}
+			last = current
+		}
+	}
+}
+
+func (b *pollBackend) snapshot() map[string]fileState {
+	files := map[string]fileState{}
+	walk(b.dir, b.ignore, func(rel string, entry fs.DirEntry) {
+		if entry.IsDir() {
+			return
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return
+		}
+		files[rel] = fileState{modTime: info.ModTime(), size: info.Size()}
+	})
+	return files
+}
+
+// walk calls fn for every file and directory below root that is not ignored.
+func walk(root string, ignore *Ignore, fn func(rel string, entry fs.DirEntry)) {
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if ignore.Match(rel, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fn(rel, entry)
+		return nil
+	})
+}